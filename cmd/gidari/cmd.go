@@ -10,22 +10,95 @@ package main
 import (
 	"context"
 	_ "embed" // Embed external data.
+	"errors"
 	"log"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/alpstable/gidari"
 	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/internal/control"
+	"github.com/alpstable/gidari/internal/events"
+	"github.com/alpstable/gidari/internal/monitor"
+	"github.com/alpstable/gidari/internal/web"
+	"github.com/alpstable/gidari/tools"
 	"github.com/alpstable/gidari/version"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
-func main() {
+// exitInterrupted is the exit status when a run is stopped by SIGINT or SIGTERM after committing its progress.
+const exitInterrupted = 130
+
+// dashboardInterval is how often the "--tui" dashboard is redrawn.
+const dashboardInterval = time.Second
+
+// eventsNDJSON is the "--events" format that writes the events of the run to stdout as newline-delimited JSON.
+const eventsNDJSON = "ndjson"
+
+// options are the command line flags.
+type options struct {
 	// configFilepath is the path to the configuration file.
-	var configFilepath string
+	configFilepath string
 
 	// verbose is a flag that enables verbose logging.
-	var verbose bool
+	verbose bool
+
+	// maxMemory is the hard memory limit for the transport, e.g. "2GiB".
+	maxMemory string
+
+	// resume will skip the requests completed by a previous, interrupted run.
+	resume bool
+
+	// preflight will check every source and storage target before the run starts.
+	preflight bool
+
+	// tui will show a live dashboard of the run on the terminal in place of the log.
+	tui bool
+
+	// events is the format of the structured events written to stdout in place of the log, if it is set.
+	events string
+
+	// truncate are the tables that are truncated in the same transaction as their first load of the run.
+	truncate []string
+
+	// record and replay are the cassette files that the HTTP interactions of the run are recorded to, or replayed
+	// from instead of the web API.
+	record, replay string
+
+	// deadLetterFile overrides the "deadLetter.file" of the configuration.
+	deadLetterFile string
+
+	// export are the settings of the "export" command. The "since", "until" and "slice" flags are parsed into it.
+	export              gidari.ExportOptions
+	since, until, slice string
+
+	// docs are the settings of the "docs" command, which writes to the "docsFile", or stdout if it is not set.
+	docs     gidari.DocsOptions
+	docsFile string
+
+	// fixtures are the settings of the "fixtures" command.
+	fixtures gidari.FixturesOptions
+
+	// diffRuns are the settings of the "diff-runs" command.
+	diffRuns gidari.DiffRunsOptions
+
+	// retryFailed are the settings of the "retry-failed" command.
+	retryFailed gidari.RetryFailedOptions
+
+	// schemaFile is the file that the "config-schema" command writes to, or stdout if it is not set.
+	schemaFile string
+
+	// exampleFile is the file that the "example" command writes to, or stdout if it is not set, and exampleDatabase
+	// is the SQLite database file of the configuration that it writes.
+	exampleFile, exampleDatabase string
+}
+
+func main() {
+	var opts options
 
 	cmd := &cobra.Command{
 		Long: "Gidari is a tool for querying web APIs and persisting resultant data onto local storage\n" +
@@ -37,25 +110,170 @@ func main() {
 		Deprecated: "",
 		Version:    version.Gidari,
 
-		Run: func(_ *cobra.Command, args []string) { run(configFilepath, verbose, args) },
+		Run: func(_ *cobra.Command, args []string) { run(opts, args) },
 	}
 
-	cmd.Flags().StringVar(&configFilepath, "config", "c", "path to configuration")
-	cmd.Flags().BoolVar(&verbose, "verbose", false, "print log data as the binary executes")
+	cmd.Flags().StringVar(&opts.configFilepath, "config", "c", "path to configuration")
+	cmd.Flags().BoolVar(&opts.verbose, "verbose", false, "print log data as the binary executes")
+	cmd.Flags().StringVar(&opts.maxMemory, "max-memory", "", "memory limit (e.g. 2GiB) to degrade gracefully under")
+	cmd.Flags().BoolVar(&opts.resume, "resume", false, "skip requests completed by an interrupted run, see checkpoint")
+	cmd.Flags().BoolVar(&opts.preflight, "preflight", false, "check every source and storage target before the run")
+	cmd.Flags().BoolVar(&opts.tui, "tui", false, "show a live dashboard of progress, rates and errors")
+	cmd.Flags().StringVar(&opts.events, "events", "", "write structured events to stdout in place of the log: ndjson")
+	cmd.Flags().StringSliceVar(&opts.truncate, "truncate", nil, "tables to replace with the data of the run")
+	cmd.Flags().StringVar(&opts.record, "record", "", "cassette file to record the HTTP requests of the run to")
+	cmd.Flags().StringVar(&opts.replay, "replay", "", "cassette file to replay the HTTP requests of the run from")
 
 	if err := cmd.MarkFlagRequired("config"); err != nil {
 		logrus.Fatalf("error marking flag as required: %v", err)
 	}
 
+	replayCmd := &cobra.Command{
+		Use:     "replay",
+		Short:   "Re-execute the requests captured in the dead-letter file",
+		Example: "gidari replay --config config.yaml",
+
+		Run: func(_ *cobra.Command, args []string) { replay(opts, args) },
+	}
+
+	replayCmd.Flags().StringVar(&opts.configFilepath, "config", "c", "path to configuration")
+	replayCmd.Flags().BoolVar(&opts.verbose, "verbose", false, "print log data as the binary executes")
+	replayCmd.Flags().StringVar(&opts.deadLetterFile, "file", "", "dead-letter file, defaults to deadLetter.file")
+
+	if err := replayCmd.MarkFlagRequired("config"); err != nil {
+		logrus.Fatalf("error marking flag as required: %v", err)
+	}
+
+	exportCmd := &cobra.Command{
+		Use:     "export",
+		Short:   "Read an ingested table back out of storage into files",
+		Example: "gidari export --config config.yaml --table trades --time-column time --since 2022-01-01 --slice 1d",
+
+		Run: func(_ *cobra.Command, args []string) { export(opts, args) },
+	}
+
+	exportCmd.Flags().StringVar(&opts.configFilepath, "config", "c", "path to configuration")
+	exportCmd.Flags().BoolVar(&opts.verbose, "verbose", false, "print log data as the binary executes")
+	exportCmd.Flags().StringVar(&opts.export.Table, "table", "", "table to export")
+	exportCmd.Flags().StringVar(&opts.export.Format, "format", "ndjson", "file format, ndjson, csv or parquet")
+	exportCmd.Flags().StringVar(&opts.export.Dir, "out", ".", "directory to write the files to")
+	exportCmd.Flags().StringVar(&opts.export.ConnectionString, "connection", "",
+		"connection string to export from, defaults to the first of connectionStrings")
+	exportCmd.Flags().StringVar(&opts.export.TimeColumn, "time-column", "", "column that since, until and slice use")
+	exportCmd.Flags().StringVar(&opts.since, "since", "", "export records at or after this time")
+	exportCmd.Flags().StringVar(&opts.until, "until", "", "export records before this time")
+	exportCmd.Flags().StringVar(&opts.slice, "slice", "", "write one resumable file per slice of time (e.g. 1d)")
+
+	for _, flag := range []string{"config", "table"} {
+		if err := exportCmd.MarkFlagRequired(flag); err != nil {
+			logrus.Fatalf("error marking flag as required: %v", err)
+		}
+	}
+
+	docsCmd := &cobra.Command{
+		Use:     "docs",
+		Short:   "Sample the configured requests and write a data dictionary of their tables",
+		Example: "gidari docs --config config.yaml --format markdown --out DATA.md",
+
+		Run: func(_ *cobra.Command, args []string) { docs(opts, args) },
+	}
+
+	docsCmd.Flags().StringVar(&opts.configFilepath, "config", "c", "path to configuration")
+	docsCmd.Flags().BoolVar(&opts.verbose, "verbose", false, "print log data as the binary executes")
+	docsCmd.Flags().StringVar(&opts.docs.Format, "format", "markdown", "document format, markdown or json")
+	docsCmd.Flags().StringVar(&opts.docsFile, "out", "", "file to write the data dictionary to, defaults to stdout")
+	docsCmd.Flags().IntVar(&opts.docs.Samples, "samples", 100, "records of each request to infer the columns from")
+
+	if err := docsCmd.MarkFlagRequired("config"); err != nil {
+		logrus.Fatalf("error marking flag as required: %v", err)
+	}
+
+	fixturesCmd := &cobra.Command{
+		Use:     "fixtures",
+		Short:   "Capture scrubbed sample responses of the configured requests for offline tests",
+		Example: "gidari fixtures --config config.yaml --request candles --out testdata/",
+
+		Run: func(_ *cobra.Command, args []string) { fixtures(opts, args) },
+	}
+
+	fixturesCmd.Flags().StringVar(&opts.configFilepath, "config", "c", "path to configuration")
+	fixturesCmd.Flags().BoolVar(&opts.verbose, "verbose", false, "print log data as the binary executes")
+	fixturesCmd.Flags().StringSliceVar(&opts.fixtures.Requests, "request", nil,
+		"tables or endpoints of the requests to capture, defaults to every request")
+	fixturesCmd.Flags().StringVar(&opts.fixtures.Dir, "out", "testdata", "directory to write the fixtures to")
+
+	if err := fixturesCmd.MarkFlagRequired("config"); err != nil {
+		logrus.Fatalf("error marking flag as required: %v", err)
+	}
+
+	diffRunsCmd := &cobra.Command{
+		Use:     "diff-runs <run1> <run2>",
+		Short:   "Compare the manifests of two runs, e.g. before and after an upgrade",
+		Example: "gidari diff-runs 20221014T150405Z 20221015T150405Z --dir gidari-runs",
+		Args:    cobra.ExactArgs(2),
+
+		Run: func(_ *cobra.Command, args []string) { diffRuns(opts, args) },
+	}
+
+	diffRunsCmd.Flags().StringVar(&opts.diffRuns.Dir, "dir", config.DefaultManifestDir,
+		"manifest.dir that the runs wrote their manifests to")
+
+	retryFailedCmd := &cobra.Command{
+		Use:     "retry-failed",
+		Short:   "Re-run the failed requests of a previous run with the configuration it was made with",
+		Example: "gidari retry-failed --run 20221014T150405Z",
+
+		Run: func(_ *cobra.Command, args []string) { retryFailed(opts, args) },
+	}
+
+	retryFailedCmd.Flags().StringVar(&opts.retryFailed.Run, "run", "", "ID of the run, or the path of its manifest")
+	retryFailedCmd.Flags().StringVar(&opts.retryFailed.Dir, "dir", config.DefaultManifestDir,
+		"manifest.dir that the run wrote its manifest to")
+	retryFailedCmd.Flags().StringVar(&opts.configFilepath, "config", "",
+		"path to configuration, defaults to the configuration of the run")
+	retryFailedCmd.Flags().BoolVar(&opts.verbose, "verbose", false, "print log data as the binary executes")
+
+	if err := retryFailedCmd.MarkFlagRequired("run"); err != nil {
+		logrus.Fatalf("error marking flag as required: %v", err)
+	}
+
+	configSchemaCmd := &cobra.Command{
+		Use:     "config-schema",
+		Short:   "Write the JSON Schema of the configuration file, for editors to validate and autocomplete it",
+		Example: "gidari config-schema --out gidari.schema.json",
+		Args:    cobra.NoArgs,
+
+		Run: func(_ *cobra.Command, args []string) { configSchema(opts, args) },
+	}
+
+	configSchemaCmd.Flags().StringVar(&opts.schemaFile, "out", "", "file to write the schema to, defaults to stdout")
+
+	exampleCmd := &cobra.Command{
+		Use:     "example coinbase|github|alpaca|binance",
+		Short:   "Write a runnable sample configuration of a popular web API that writes to SQLite",
+		Example: "gidari example coinbase --out coinbase.yml && gidari --config coinbase.yml",
+		Args:    cobra.ExactArgs(1),
+
+		Run: func(_ *cobra.Command, args []string) { example(opts, args) },
+	}
+
+	exampleCmd.Flags().StringVar(&opts.exampleFile, "out", "", "file to write the configuration to, defaults to stdout")
+	exampleCmd.Flags().StringVar(&opts.exampleDatabase, "database", "",
+		"SQLite database file of the configuration, defaults to <provider>.db")
+
+	cmd.AddCommand(replayCmd, exportCmd, docsCmd, fixturesCmd, diffRunsCmd, retryFailedCmd, configSchemaCmd,
+		exampleCmd)
+
 	if err := cmd.Execute(); err != nil {
 		log.Fatal(err)
 	}
 }
 
-func run(configFilepath string, verboseLogging bool, _ []string) {
-	file, err := os.Open(configFilepath)
+// loadConfig will read the configuration file and apply the command line flags to it.
+func loadConfig(opts options) *config.Config {
+	file, err := os.Open(opts.configFilepath)
 	if err != nil {
-		log.Fatalf("error opening config file  %s: %v", configFilepath, err)
+		log.Fatalf("error opening config file  %s: %v", opts.configFilepath, err)
 	}
 
 	cfg, err := config.New(context.Background(), file)
@@ -63,13 +281,374 @@ func run(configFilepath string, verboseLogging bool, _ []string) {
 		log.Fatalf("error creating new config: %v", err)
 	}
 
-	if verboseLogging {
+	if opts.maxMemory != "" {
+		cfg.MaxMemory, err = config.ParseByteSize(opts.maxMemory)
+		if err != nil {
+			log.Fatalf("error parsing max memory: %v", err)
+		}
+	}
+
+	cfg.Resume = opts.resume
+
+	if err := cfg.TruncateTables(opts.truncate...); err != nil {
+		log.Fatalf("error truncating tables: %v", err)
+	}
+
+	if opts.preflight {
+		cfg.Preflight = true
+	}
+
+	if opts.deadLetterFile != "" {
+		if cfg.DeadLetter == nil {
+			cfg.DeadLetter = new(config.DeadLetterConfig)
+		}
+
+		cfg.DeadLetter.File = opts.deadLetterFile
+	}
+
+	if opts.verbose {
 		cfg.Logger.SetOutput(os.Stdout)
 		cfg.Logger.SetLevel(logrus.InfoLevel)
 	}
 
-	err = gidari.Transport(context.Background(), cfg)
+	return cfg
+}
+
+// notifyContext returns a context that is canceled on SIGINT or SIGTERM, so that the transport can shut down
+// gracefully.
+func notifyContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+
+	// After the first signal, restore the default behavior so that a second signal aborts immediately.
+	go func() {
+		<-ctx.Done()
+		stop()
+
+		log.Printf("shutting down, finishing in-flight requests (signal again to abort)")
+	}()
+
+	return ctx, stop
+}
+
+// startDashboard will draw the progress of the run on stderr until the returned function is called, which draws the
+// final state of the run. The log is shown at the bottom of the dashboard rather than written over it, and commands
+// to pause, resume and cancel requests are read from stdin.
+func startDashboard(cfg *config.Config) func() {
+	mon := monitor.New(cfg.Clock)
+	cfg.Monitor = mon
+
+	cfg.Logger.SetOutput(mon)
+	cfg.Logger.SetLevel(logrus.InfoLevel)
+	logrus.SetOutput(mon)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		mon.Run(ctx, monitor.NewTerminal(os.Stderr), dashboardInterval)
+	}()
+
+	// Requests can be paused, resumed and canceled by typing commands into the dashboard.
+	cfg.Control = control.New(func(name string, state control.State) {
+		if state == control.Running {
+			mon.SetState(name, "")
+		} else {
+			mon.SetState(name, state.String())
+		}
+	})
+
+	mon.SetCommands(control.Usage)
+
+	go cfg.Control.Listen(ctx, os.Stdin, func(msg string, err error) {
+		if err != nil {
+			cfg.Logger.Warn(tools.LogFormatter{Msg: err.Error()}.String())
+
+			return
+		}
+
+		cfg.Logger.Info(tools.LogFormatter{Msg: msg}.String())
+	})
+
+	var once sync.Once
+
+	stopDashboard := func() {
+		once.Do(func() {
+			cancel()
+			<-done
+		})
+	}
+
+	// Fatal errors are logged to the dashboard, so it needs to be drawn once more before exiting.
+	cfg.Logger.ExitFunc = func(code int) {
+		stopDashboard()
+		os.Exit(code)
+	}
+
+	return stopDashboard
+}
+
+// startEvents will write the events of the run to stdout in place of the log, so that orchestrators can parse the
+// progress of the run. Errors that stop the command are still written to stderr.
+func startEvents(cfg *config.Config, opts options) {
+	if opts.events != eventsNDJSON {
+		log.Fatalf("error parsing --events %q, expected %s", opts.events, eventsNDJSON)
+	}
+
+	if opts.tui {
+		log.Fatalf("--events cannot be used with --tui")
+	}
+
+	cfg.Events = events.New(os.Stdout, cfg.Clock)
+
+	cfg.Logger.SetOutput(os.Stderr)
+	cfg.Logger.SetLevel(logrus.ErrorLevel)
+}
+
+// useCassette will record the HTTP interactions of the run to the "--record" cassette, or replay them from the
+// "--replay" cassette instead of the web API.
+func useCassette(cfg *config.Config, opts options) {
+	switch {
+	case opts.record != "" && opts.replay != "":
+		log.Fatalf("--record cannot be used with --replay")
+	case opts.record != "":
+		cfg.Cassette = web.NewCassette(opts.record)
+	case opts.replay != "":
+		cassette, err := web.LoadCassette(opts.replay)
+		if err != nil {
+			log.Fatalf("error loading cassette: %v", err)
+		}
+
+		cfg.Cassette = cassette
+	}
+}
+
+func run(opts options, _ []string) {
+	cfg := loadConfig(opts)
+
+	ctx, stop := notifyContext()
+	defer stop()
+
+	dumps, stopDumps := notifyDump()
+	defer stopDumps()
+
+	cfg.Dump = dumps
+
+	if opts.events != "" {
+		startEvents(cfg, opts)
+	}
+
+	useCassette(cfg, opts)
+
+	stopDashboard := func() {}
+	if opts.tui {
+		stopDashboard = startDashboard(cfg)
+	}
+
+	err := gidari.Transport(ctx, cfg)
+
+	stopDashboard()
+
+	// The interactions are recorded whether or not the run succeeds, e.g. to replay a failure.
+	if err := cfg.Cassette.Save(); err != nil {
+		log.Printf("failed to save cassette: %v", err)
+	}
+
+	if errors.Is(err, gidari.ErrInterrupted) {
+		stop()
+		log.Printf("%v", err)
+		os.Exit(exitInterrupted) //nolint:gocritic // stop has already been called
+	}
+
 	if err != nil {
 		log.Fatalf("failed to transport data: %v", err)
 	}
 }
+
+func replay(opts options, _ []string) {
+	cfg := loadConfig(opts)
+
+	ctx, stop := notifyContext()
+	defer stop()
+
+	if err := gidari.Replay(ctx, cfg); err != nil {
+		stop()
+		log.Fatalf("failed to replay requests: %v", err) //nolint:gocritic // stop has already been called
+	}
+}
+
+// parseTime will parse a time flag as an RFC 3339 date-time or a date.
+func parseTime(name, str string) time.Time {
+	if str == "" {
+		return time.Time{}
+	}
+
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, str); err == nil {
+			return t
+		}
+	}
+
+	log.Fatalf("error parsing --%s %q, expected RFC 3339 or YYYY-MM-DD", name, str)
+
+	return time.Time{}
+}
+
+func export(opts options, _ []string) {
+	cfg := loadConfig(opts)
+
+	opts.export.Since = parseTime("since", opts.since)
+	opts.export.Until = parseTime("until", opts.until)
+
+	if opts.slice != "" {
+		slice, err := config.ParseTimeseriesPeriod(opts.slice)
+		if err != nil {
+			log.Fatalf("error parsing --slice: %v", err)
+		}
+
+		opts.export.Slice = slice.Duration()
+	}
+
+	ctx, stop := notifyContext()
+	defer stop()
+
+	err := gidari.Export(ctx, cfg, opts.export)
+	if errors.Is(err, gidari.ErrInterrupted) {
+		stop()
+		log.Printf("%v", err)
+		os.Exit(exitInterrupted) //nolint:gocritic // stop has already been called
+	}
+
+	if err != nil {
+		stop()
+		log.Fatalf("failed to export data: %v", err)
+	}
+}
+
+func docs(opts options, _ []string) {
+	cfg := loadConfig(opts)
+
+	opts.docs.Output = os.Stdout
+
+	// The log is kept out of a data dictionary written to stdout.
+	if opts.verbose && opts.docsFile == "" {
+		cfg.Logger.SetOutput(os.Stderr)
+	}
+
+	if opts.docsFile != "" {
+		file, err := os.Create(opts.docsFile)
+		if err != nil {
+			log.Fatalf("error creating docs file %s: %v", opts.docsFile, err)
+		}
+
+		defer file.Close()
+
+		opts.docs.Output = file
+	}
+
+	ctx, stop := notifyContext()
+	defer stop()
+
+	if err := gidari.Docs(ctx, cfg, opts.docs); err != nil {
+		stop()
+		log.Fatalf("failed to document requests: %v", err) //nolint:gocritic // stop has already been called
+	}
+}
+
+func fixtures(opts options, _ []string) {
+	cfg := loadConfig(opts)
+
+	ctx, stop := notifyContext()
+	defer stop()
+
+	if err := gidari.Fixtures(ctx, cfg, opts.fixtures); err != nil {
+		stop()
+		log.Fatalf("failed to capture fixtures: %v", err) //nolint:gocritic // stop has already been called
+	}
+}
+
+func diffRuns(opts options, args []string) {
+	opts.diffRuns.Runs = args
+	opts.diffRuns.Output = os.Stdout
+
+	err := gidari.DiffRuns(opts.diffRuns)
+	if errors.Is(err, gidari.ErrRunsDiffer) {
+		os.Exit(1)
+	}
+
+	if err != nil {
+		log.Fatalf("failed to compare runs: %v", err)
+	}
+}
+
+func retryFailed(opts options, _ []string) {
+	if opts.configFilepath == "" {
+		path, err := gidari.RetryFailedConfig(opts.retryFailed)
+		if err != nil {
+			log.Fatalf("failed to retry requests: %v", err)
+		}
+
+		opts.configFilepath = path
+	}
+
+	cfg := loadConfig(opts)
+
+	ctx, stop := notifyContext()
+	defer stop()
+
+	err := gidari.RetryFailed(ctx, cfg, opts.retryFailed)
+	if errors.Is(err, gidari.ErrInterrupted) {
+		stop()
+		log.Printf("%v", err)
+		os.Exit(exitInterrupted) //nolint:gocritic // stop has already been called
+	}
+
+	if err != nil {
+		stop()
+		log.Fatalf("failed to retry requests: %v", err)
+	}
+}
+
+func configSchema(opts options, _ []string) {
+	schema, err := gidari.ConfigSchema()
+	if err != nil {
+		log.Fatalf("failed to generate the configuration schema: %v", err)
+	}
+
+	if opts.schemaFile == "" {
+		if _, err := os.Stdout.Write(schema); err != nil {
+			log.Fatalf("failed to write the configuration schema: %v", err)
+		}
+
+		return
+	}
+
+	if err := os.WriteFile(opts.schemaFile, schema, 0o644); err != nil {
+		log.Fatalf("failed to write the configuration schema to %s: %v", opts.schemaFile, err)
+	}
+}
+
+func example(opts options, args []string) {
+	database := opts.exampleDatabase
+	if database == "" {
+		database = args[0] + ".db"
+	}
+
+	data, err := gidari.ExampleConfig(args[0], database)
+	if err != nil {
+		log.Fatalf("failed to generate the example configuration: %v", err)
+	}
+
+	if opts.exampleFile == "" {
+		if _, err := os.Stdout.Write(data); err != nil {
+			log.Fatalf("failed to write the example configuration: %v", err)
+		}
+
+		return
+	}
+
+	if err := os.WriteFile(opts.exampleFile, data, 0o644); err != nil {
+		log.Fatalf("failed to write the example configuration to %s: %v", opts.exampleFile, err)
+	}
+}
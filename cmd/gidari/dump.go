@@ -0,0 +1,47 @@
+//go:build !windows
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// notifyDump returns a channel that receives each time the process receives SIGUSR1, so that the progress of the run
+// can be logged while it continues, e.g. with "kill -USR1 <pid>". The returned function stops the notifications.
+func notifyDump() (<-chan struct{}, func()) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGUSR1)
+
+	dumps := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-sigs:
+			}
+
+			select {
+			case <-done:
+				return
+			case dumps <- struct{}{}:
+			}
+		}
+	}()
+
+	return dumps, func() {
+		signal.Stop(sigs)
+		close(done)
+	}
+}
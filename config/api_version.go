@@ -0,0 +1,59 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// APIVersion pins the version of the web API that every request asks for, so that a new version of the web API is
+// not served until the configuration opts in to it.
+type APIVersion struct {
+	// Header is the request header that the version is sent in, e.g. "X-GitHub-Api-Version". The default is that
+	// of the provider.
+	Header string `yaml:"header"`
+
+	// Version is the version of the web API that is requested, e.g. "2022-11-28".
+	Version string `yaml:"version"`
+
+	// ServedHeader is the response header of the version that was served, which is warned about if it is not the
+	// pinned version. The default is that of the provider, or else "header".
+	ServedHeader string `yaml:"servedHeader"`
+}
+
+// validHeader returns true if a header name is a non-empty HTTP token.
+func validHeader(name string) bool {
+	return name != "" && !strings.ContainsAny(name, " \t\r\n:\"(),/;<=>?@[\\]{}")
+}
+
+func (version *APIVersion) validate() error {
+	if !validHeader(version.Header) {
+		return fmt.Errorf("%w: apiVersion.header %q must be a header name", ErrInvalidAPIVersion, version.Header)
+	}
+
+	if version.ServedHeader != "" && !validHeader(version.ServedHeader) {
+		return fmt.Errorf("%w: apiVersion.servedHeader %q must be a header name", ErrInvalidAPIVersion,
+			version.ServedHeader)
+	}
+
+	if version.Version == "" {
+		return fmt.Errorf("%w: apiVersion.version is required", ErrInvalidAPIVersion)
+	}
+
+	return nil
+}
+
+// ServedVersionHeader returns the response header of the version that was served.
+func (version *APIVersion) ServedVersionHeader() string {
+	if version.ServedHeader != "" {
+		return version.ServedHeader
+	}
+
+	return version.Header
+}
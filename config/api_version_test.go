@@ -0,0 +1,64 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestAPIVersion(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name    string
+		version APIVersion
+		err     error
+	}{
+		{name: "pinned", version: APIVersion{Header: "Stripe-Version", Version: "2022-11-15"}},
+		{name: "no header", version: APIVersion{Version: "2022-11-15"}, err: ErrInvalidAPIVersion},
+		{name: "invalid header", version: APIVersion{Header: "Api Version", Version: "2"}, err: ErrInvalidAPIVersion},
+		{
+			name:    "invalid served header",
+			version: APIVersion{Header: "X-Api-Version", Version: "2", ServedHeader: "Served:"},
+			err:     ErrInvalidAPIVersion,
+		},
+		{name: "no version", version: APIVersion{Header: "X-Api-Version"}, err: ErrInvalidAPIVersion},
+	} {
+		if err := tcase.version.validate(); !errors.Is(err, tcase.err) {
+			t.Fatalf("%s: expected error %v, got %v", tcase.name, tcase.err, err)
+		}
+	}
+
+	version := APIVersion{Header: "X-Api-Version", Version: "2"}
+	if got := version.ServedVersionHeader(); got != "X-Api-Version" {
+		t.Fatalf("expected the served version in the request header, got %q", got)
+	}
+
+	data := `
+version: 1
+provider: github
+connectionStrings:
+  - mongodb://localhost:27017/db
+apiVersion:
+  version: "2022-11-28"
+requests:
+  - endpoint: /repos/alpstable/gidari/issues
+`
+
+	cfg, err := New(context.Background(), newTestConfigFile(t, data))
+	if err != nil {
+		t.Fatalf("failed to create config: %v", err)
+	}
+
+	if got := cfg.APIVersion; got.Header != "X-GitHub-Api-Version" ||
+		got.ServedVersionHeader() != "X-GitHub-Api-Version-Selected" {
+		t.Fatalf("expected the version headers of the provider, got %+v", got)
+	}
+}
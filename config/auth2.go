@@ -0,0 +1,68 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultRefreshBefore is how long before it expires an OAuth2 bearer is refreshed when "refreshBefore" is not set.
+const DefaultRefreshBefore = 5 * time.Minute
+
+// Auth2 is a struct that contains the authentication data for a web API that uses OAuth2.
+type Auth2 struct {
+	// Bearer is a static bearer. If it is a JWT, its expiry is reported by the "credentialExpiry" metric. It is
+	// replaced by the bearers obtained from "tokenURL", if set.
+	Bearer string `yaml:"bearer"`
+
+	// TokenURL is the token endpoint that bearers are obtained from, with the client credentials grant or, if
+	// "refreshToken" is set, the refresh token grant. Bearers are refreshed before they expire.
+	TokenURL     string   `yaml:"tokenURL"`
+	ClientID     string   `yaml:"clientID"`
+	ClientSecret string   `yaml:"clientSecret"`
+	RefreshToken string   `yaml:"refreshToken"`
+	Scopes       []string `yaml:"scopes"`
+
+	// RefreshBefore is how long before it expires a bearer is refreshed, e.g. "5m". Bearers that are issued for
+	// less than twice this long are refreshed halfway through their lifetime. The default is
+	// "DefaultRefreshBefore".
+	RefreshBefore string `yaml:"refreshBefore"`
+}
+
+func (auth2 *Auth2) validate() error {
+	if auth2.TokenURL == "" {
+		return nil
+	}
+
+	if auth2.ClientID == "" && auth2.RefreshToken == "" {
+		return fmt.Errorf("%w: auth2 tokenURL requires a clientID or a refreshToken", ErrInvalidAuthentication)
+	}
+
+	if _, err := auth2.RefreshBeforeOrDefault(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RefreshBeforeOrDefault returns how long before it expires a bearer is refreshed, which defaults to
+// "DefaultRefreshBefore".
+func (auth2 *Auth2) RefreshBeforeOrDefault() (time.Duration, error) {
+	if auth2.RefreshBefore == "" {
+		return DefaultRefreshBefore, nil
+	}
+
+	refreshBefore, err := time.ParseDuration(auth2.RefreshBefore)
+	if err != nil || refreshBefore < 0 {
+		return 0, fmt.Errorf("%w: auth2 refreshBefore %q must be a positive duration", ErrInvalidAuthentication,
+			auth2.RefreshBefore)
+	}
+
+	return refreshBefore, nil
+}
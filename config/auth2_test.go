@@ -0,0 +1,66 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAuth2Validate(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name          string
+		auth2         Auth2
+		refreshBefore time.Duration
+		err           error
+	}{
+		{name: "bearer", auth2: Auth2{Bearer: "token"}, refreshBefore: DefaultRefreshBefore},
+		{
+			name:          "client credentials",
+			auth2:         Auth2{TokenURL: "https://auth.example.com/token", ClientID: "id", ClientSecret: "secret"},
+			refreshBefore: DefaultRefreshBefore,
+		},
+		{
+			name:          "refresh token",
+			auth2:         Auth2{TokenURL: "https://auth.example.com/token", RefreshToken: "refresh", RefreshBefore: "90s"},
+			refreshBefore: 90 * time.Second,
+		},
+		{name: "no client", auth2: Auth2{TokenURL: "https://auth.example.com/token"}, err: ErrInvalidAuthentication},
+		{
+			name:  "invalid refreshBefore",
+			auth2: Auth2{TokenURL: "https://auth.example.com/token", ClientID: "id", RefreshBefore: "5"},
+			err:   ErrInvalidAuthentication,
+		},
+		{
+			name:  "negative refreshBefore",
+			auth2: Auth2{TokenURL: "https://auth.example.com/token", ClientID: "id", RefreshBefore: "-1m"},
+			err:   ErrInvalidAuthentication,
+		},
+	} {
+		tcase := tcase
+
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			if err := tcase.auth2.validate(); !errors.Is(err, tcase.err) {
+				t.Fatalf("expected error %v, got %v", tcase.err, err)
+			}
+
+			if tcase.err != nil {
+				return
+			}
+
+			if refreshBefore, _ := tcase.auth2.RefreshBeforeOrDefault(); refreshBefore != tcase.refreshBefore {
+				t.Fatalf("expected refreshBefore %s, got %s", tcase.refreshBefore, refreshBefore)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"fmt"
+
+	"github.com/alpstable/gidari/internal/proto"
+)
+
+// AutoCreate is how the table of a request is created if it does not exist when it is first written to, resolved
+// from the "autoCreate", "typeMapping" and table settings of the configuration.
+type AutoCreate struct {
+	// PrimaryKeys are the columns of the primary key of the created table.
+	PrimaryKeys []string
+
+	// ColumnTypes are the column types of individual columns, keyed by column name, which take precedence over
+	// the type mapping.
+	ColumnTypes map[string]string
+
+	// TypeMapping are the column types of the kinds of values inferred for the columns, keyed by storage scheme
+	// and then by kind.
+	TypeMapping map[string]map[string]string
+}
+
+// validateTypeMapping will ensure that the type mapping only maps known kinds, and to a column type.
+func validateTypeMapping(mapping map[string]map[string]string) error {
+	for scheme, types := range mapping {
+		for kind, typ := range types {
+			if !proto.IsKind(kind) {
+				return fmt.Errorf("%w: typeMapping.%s.%s must be one of %q, %q, %q, %q, %q or %q",
+					ErrInvalidAutoCreate, scheme, kind, proto.KindBoolean, proto.KindInteger, proto.KindNumber,
+					proto.KindTimestamp, proto.KindString, proto.KindJSON)
+			}
+
+			if typ == "" {
+				return fmt.Errorf("%w: typeMapping.%s.%s must have a column type", ErrInvalidAutoCreate, scheme,
+					kind)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateColumnTypes will ensure that every column of a table is mapped to a column type.
+func validateColumnTypes(name string, types map[string]string) error {
+	for column, typ := range types {
+		if typ == "" {
+			return fmt.Errorf("%w: tables.%s.columnTypes.%s must have a column type", ErrInvalidAutoCreate, name,
+				column)
+		}
+	}
+
+	return nil
+}
+
+// newAutoCreate returns how the table of a request is created, or nil if tables are not created.
+func (cfg *Config) newAutoCreate(req *Request) *AutoCreate {
+	if !cfg.AutoCreate {
+		return nil
+	}
+
+	create := &AutoCreate{TypeMapping: cfg.TypeMapping}
+	if table, ok := cfg.Tables[req.Table]; ok {
+		create.PrimaryKeys = table.PrimaryKeys
+		create.ColumnTypes = table.ColumnTypes
+	}
+
+	// Tables without primary keys of their own are keyed by the surrogate key of their records.
+	if len(create.PrimaryKeys) == 0 && req.SurrogateKey != nil {
+		create.PrimaryKeys = []string{req.SurrogateKey.Column}
+	}
+
+	return create
+}
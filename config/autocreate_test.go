@@ -0,0 +1,104 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestNewAppliesAutoCreate(t *testing.T) {
+	t.Parallel()
+
+	data := `
+version: 1
+url: https://example.com
+connectionStrings:
+  - postgresql://localhost:5432/db
+rateLimit:
+  burst: 1
+  period: 1
+autoCreate: true
+typeMapping:
+  postgresql:
+    timestamp: TIMESTAMP
+tables:
+  trades:
+    primaryKeys: [id]
+    columnTypes:
+      price: NUMERIC(18,8)
+requests:
+  - endpoint: /trades
+  - endpoint: /candles
+`
+
+	cfg, err := New(context.Background(), newTestConfigFile(t, data))
+	if err != nil {
+		t.Fatalf("failed to create config: %v", err)
+	}
+
+	want := &AutoCreate{
+		PrimaryKeys: []string{"id"},
+		ColumnTypes: map[string]string{"price": "NUMERIC(18,8)"},
+		TypeMapping: map[string]map[string]string{"postgresql": {"timestamp": "TIMESTAMP"}},
+	}
+
+	if got := cfg.Requests[0].AutoCreate; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected the table settings to be applied, got %+v", got)
+	}
+
+	if got := cfg.Requests[1].AutoCreate; got == nil || got.PrimaryKeys != nil || got.TypeMapping == nil {
+		t.Fatalf("expected only the type mapping to apply to a request without table settings, got %+v", got)
+	}
+}
+
+func TestValidateAutoCreate(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name    string
+		mapping map[string]map[string]string
+		types   map[string]string
+		err     error
+	}{
+		{name: "empty"},
+		{
+			name:    "valid",
+			mapping: map[string]map[string]string{"postgresql": {"json": "JSON", "number": "NUMERIC"}},
+			types:   map[string]string{"price": "NUMERIC(18,8)"},
+		},
+		{
+			name:    "unknown kind",
+			mapping: map[string]map[string]string{"postgresql": {"float": "REAL"}},
+			err:     ErrInvalidAutoCreate,
+		},
+		{
+			name:    "missing mapped type",
+			mapping: map[string]map[string]string{"postgresql": {"json": ""}},
+			err:     ErrInvalidAutoCreate,
+		},
+		{name: "missing column type", types: map[string]string{"price": ""}, err: ErrInvalidAutoCreate},
+	} {
+		tcase := tcase
+
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := validateTypeMapping(tcase.mapping)
+			if err == nil {
+				err = validateColumnTypes("trades", tcase.types)
+			}
+
+			if !errors.Is(err, tcase.err) {
+				t.Fatalf("expected error %v, got %v", tcase.err, err)
+			}
+		})
+	}
+}
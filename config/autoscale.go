@@ -0,0 +1,86 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// DefaultAutoscaleMaxWorkers is the most web workers that fetch from a host at once when "autoscale.maxWorkers"
+	// is not set.
+	DefaultAutoscaleMaxWorkers = 32
+
+	// DefaultAutoscaleTargetLatency is the response latency above which fewer web workers fetch from a host when
+	// "autoscale.targetLatency" is not set.
+	DefaultAutoscaleTargetLatency = time.Second
+)
+
+// AutoscaleConfig configures how the number of web workers that fetch from each host at once grows and shrinks
+// with the latency and errors of its responses, and with the rate limit budget that is left unused.
+type AutoscaleConfig struct {
+	// MinWorkers is the fewest web workers that fetch from a host at once. The default is 1.
+	MinWorkers int `yaml:"minWorkers"`
+
+	// MaxWorkers is the most web workers that fetch from a host at once. The default is
+	// "DefaultAutoscaleMaxWorkers".
+	MaxWorkers int `yaml:"maxWorkers"`
+
+	// TargetLatency is the response latency, e.g. "500ms", above which fewer web workers fetch from a host. The
+	// default is "DefaultAutoscaleTargetLatency".
+	TargetLatency string `yaml:"targetLatency"`
+}
+
+func (autoscale *AutoscaleConfig) validate() error {
+	if autoscale.MinWorkers < 0 || autoscale.MaxWorkers < 0 {
+		return fmt.Errorf("%w: workers must not be negative", ErrInvalidAutoscale)
+	}
+
+	if autoscale.MaxWorkers > 0 && autoscale.MinWorkers > autoscale.MaxWorkers {
+		return fmt.Errorf("%w: minWorkers %d is more than maxWorkers %d", ErrInvalidAutoscale,
+			autoscale.MinWorkers, autoscale.MaxWorkers)
+	}
+
+	if autoscale.TargetLatency != "" {
+		if latency, err := time.ParseDuration(autoscale.TargetLatency); err != nil || latency <= 0 {
+			return fmt.Errorf("%w: targetLatency must be a positive duration, e.g. \"500ms\", got %q",
+				ErrInvalidAutoscale, autoscale.TargetLatency)
+		}
+	}
+
+	return nil
+}
+
+// Workers returns the fewest and the most web workers that fetch from a host at once.
+func (autoscale *AutoscaleConfig) Workers() (int, int) {
+	least, most := autoscale.MinWorkers, autoscale.MaxWorkers
+	if least == 0 {
+		least = 1
+	}
+
+	if most == 0 {
+		most = DefaultAutoscaleMaxWorkers
+	}
+
+	if least > most {
+		most = least
+	}
+
+	return least, most
+}
+
+// Latency returns the target latency of responses, defaulting to "DefaultAutoscaleTargetLatency".
+func (autoscale *AutoscaleConfig) Latency() time.Duration {
+	latency, err := time.ParseDuration(autoscale.TargetLatency)
+	if err != nil || latency <= 0 {
+		return DefaultAutoscaleTargetLatency
+	}
+
+	return latency
+}
@@ -0,0 +1,56 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAutoscaleConfig(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name    string
+		cfg     *AutoscaleConfig
+		least   int
+		most    int
+		latency time.Duration
+		err     error
+	}{
+		{name: "defaults", cfg: &AutoscaleConfig{}, least: 1, most: DefaultAutoscaleMaxWorkers,
+			latency: DefaultAutoscaleTargetLatency},
+		{
+			name:    "configured",
+			cfg:     &AutoscaleConfig{MinWorkers: 2, MaxWorkers: 4, TargetLatency: "250ms"},
+			least:   2,
+			most:    4,
+			latency: 250 * time.Millisecond,
+		},
+		{name: "negative", cfg: &AutoscaleConfig{MaxWorkers: -1}, err: ErrInvalidAutoscale},
+		{name: "min above max", cfg: &AutoscaleConfig{MinWorkers: 8, MaxWorkers: 4}, err: ErrInvalidAutoscale},
+		{name: "latency", cfg: &AutoscaleConfig{TargetLatency: "fast"}, err: ErrInvalidAutoscale},
+	} {
+		if err := tcase.cfg.validate(); !errors.Is(err, tcase.err) {
+			t.Fatalf("%s: expected error %v, got %v", tcase.name, tcase.err, err)
+		}
+
+		if tcase.err != nil {
+			continue
+		}
+
+		if least, most := tcase.cfg.Workers(); least != tcase.least || most != tcase.most {
+			t.Fatalf("%s: expected %d to %d workers, got %d to %d", tcase.name, tcase.least, tcase.most, least, most)
+		}
+
+		if latency := tcase.cfg.Latency(); latency != tcase.latency {
+			t.Fatalf("%s: expected a latency of %s, got %s", tcase.name, tcase.latency, latency)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Builder builds a configuration in Go, for embedding the transport in other services without a configuration
+// file. The settings of the request methods, e.g. "Table" and "Timeseries", apply to the request that was last
+// added with "Request". Settings that the builder has no method for are set with "Configure" and "With". Mistakes
+// in the order of the calls are returned by "Build", along with anything else that makes the configuration invalid.
+type Builder struct {
+	cfg   *Config
+	err   error
+	built bool
+
+	// ranges are the start and end of the timeseries requests, which are written to their queries with the
+	// layout of the timeseries once it is built.
+	ranges map[*Request][2]time.Time
+}
+
+// NewBuilder returns a builder of a configuration for the web API at "baseURL".
+func NewBuilder(baseURL string) *Builder {
+	return &Builder{
+		cfg:    &Config{Version: CurrentVersion, RawURL: baseURL, Logger: logrus.New()},
+		ranges: make(map[*Request][2]time.Time),
+	}
+}
+
+// ConnectionStrings will add storage that the requests are written to.
+func (builder *Builder) ConnectionStrings(dns ...string) *Builder {
+	builder.cfg.ConnectionStrings = append(builder.cfg.ConnectionStrings, dns...)
+
+	return builder
+}
+
+// RateLimit will limit the requests to "burst" requests every "period".
+func (builder *Builder) RateLimit(burst int, period time.Duration) *Builder {
+	builder.cfg.RateLimitConfig = &RateLimitConfig{Burst: &burst, Period: &period}
+
+	return builder
+}
+
+// APIKey will authenticate the requests with an API key.
+func (builder *Builder) APIKey(key, secret, passphrase string) *Builder {
+	builder.cfg.Authentication.APIKey = &APIKey{Key: key, Secret: secret, Passphrase: passphrase}
+
+	return builder
+}
+
+// Bearer will authenticate the requests with a bearer token.
+func (builder *Builder) Bearer(token string) *Builder {
+	builder.cfg.Authentication.Auth2 = &Auth2{Bearer: token}
+
+	return builder
+}
+
+// AutoCreate will create the tables that the requests are written to, if they do not exist.
+func (builder *Builder) AutoCreate() *Builder {
+	builder.cfg.AutoCreate = true
+
+	return builder
+}
+
+// Logger will set the logger of the run.
+func (builder *Builder) Logger(logger *logrus.Logger) *Builder {
+	builder.cfg.Logger = logger
+
+	return builder
+}
+
+// HTTPClient will base the requests of every worker on an embedder's own client.
+func (builder *Builder) HTTPClient(client *http.Client) *Builder {
+	builder.cfg.HTTPClient = client
+
+	return builder
+}
+
+// RoundTripper will send the authenticated requests of every worker with "base", e.g. for instrumentation, a proxy
+// or a test double.
+func (builder *Builder) RoundTripper(base http.RoundTripper) *Builder {
+	builder.cfg.RoundTripper = base
+
+	return builder
+}
+
+// Configure will call "fn" with the configuration, to set what the builder has no method for.
+func (builder *Builder) Configure(fn func(cfg *Config)) *Builder {
+	fn(builder.cfg)
+
+	return builder
+}
+
+// Request will add a GET request of "endpoint", which is written to the table named after the last part of the
+// endpoint unless "Table" is set.
+func (builder *Builder) Request(endpoint string) *Builder {
+	builder.cfg.Requests = append(builder.cfg.Requests, &Request{Endpoint: endpoint})
+
+	return builder
+}
+
+// request returns the request that was last added, or nil once the builder has failed. Calling a request method
+// before "Request" fails the builder.
+func (builder *Builder) request(method string) *Request {
+	if builder.err != nil {
+		return nil
+	}
+
+	if len(builder.cfg.Requests) == 0 {
+		builder.err = fmt.Errorf("%w: %s is called before Request", ErrInvalidBuilder, method)
+
+		return nil
+	}
+
+	return builder.cfg.Requests[len(builder.cfg.Requests)-1]
+}
+
+// Method will set the HTTP method of the request.
+func (builder *Builder) Method(method string) *Builder {
+	if req := builder.request("Method"); req != nil {
+		req.Method = method
+	}
+
+	return builder
+}
+
+// Table will set the table that the request is written to.
+func (builder *Builder) Table(table string) *Builder {
+	if req := builder.request("Table"); req != nil {
+		req.Table = table
+	}
+
+	return builder
+}
+
+// Query will add a query parameter to the request.
+func (builder *Builder) Query(key, value string) *Builder {
+	if req := builder.request("Query"); req != nil {
+		if req.Query == nil {
+			req.Query = make(map[string]string)
+		}
+
+		req.Query[key] = value
+	}
+
+	return builder
+}
+
+// Body will set the JSON body of the request.
+func (builder *Builder) Body(body map[string]interface{}) *Builder {
+	if req := builder.request("Body"); req != nil {
+		req.Body = body
+	}
+
+	return builder
+}
+
+// Timeseries will make the request a timeseries from "start" to "end", in chunks of "period". The boundaries of
+// every chunk are written to the "startName" and "endName" query parameters, in the layout of the timeseries,
+// which is RFC 3339 unless it is set with "With".
+func (builder *Builder) Timeseries(startName, endName string, start, end time.Time, period time.Duration) *Builder {
+	if req := builder.request("Timeseries"); req != nil {
+		req.Timeseries = &Timeseries{
+			StartName: startName,
+			EndName:   endName,
+			Period:    TimeseriesPeriod(period / time.Second),
+		}
+
+		builder.ranges[req] = [2]time.Time{start, end}
+	}
+
+	return builder
+}
+
+// With will call "fn" with the request, to set what the builder has no method for.
+func (builder *Builder) With(fn func(req *Request)) *Builder {
+	if req := builder.request("With"); req != nil {
+		fn(req)
+	}
+
+	return builder
+}
+
+// Build will validate the configuration and return it, with the defaults of "New" applied. A builder can only be
+// built once.
+func (builder *Builder) Build() (*Config, error) {
+	if builder.err != nil {
+		return nil, builder.err
+	}
+
+	if builder.built {
+		return nil, fmt.Errorf("%w: Build is called more than once", ErrInvalidBuilder)
+	}
+
+	builder.built = true
+	cfg := builder.cfg
+
+	for req, bounds := range builder.ranges {
+		if req.Timeseries == nil {
+			continue
+		}
+
+		if req.Query == nil {
+			req.Query = make(map[string]string)
+		}
+
+		req.Query[req.Timeseries.StartName] = req.Timeseries.FormatTime(bounds[0])
+		req.Query[req.Timeseries.EndName] = req.Timeseries.FormatTime(bounds[1])
+	}
+
+	if err := cfg.prepare(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,88 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestBuilder(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2022, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	builder := NewBuilder("https://api.exchange.com").
+		ConnectionStrings("mongodb://localhost:27017/coinbase").
+		RateLimit(5, time.Second).
+		Request("/products/BTC-USD/candles").
+		Query("granularity", "60").
+		Timeseries("start", "end", start, start.Add(48*time.Hour), 24*time.Hour).
+		With(func(req *Request) {
+			layout := "2006-01-02"
+			req.Timeseries.Layout = &layout
+		}).
+		Request("/accounts").
+		Table("accounts")
+
+	cfg, err := builder.Build()
+	if err != nil {
+		t.Fatalf("failed to build: %v", err)
+	}
+
+	if len(cfg.Requests) != 2 || cfg.URL.Host != "api.exchange.com" || cfg.Version != CurrentVersion {
+		t.Fatalf("expected two requests of api.exchange.com, got %d of %v", len(cfg.Requests), cfg.URL)
+	}
+
+	candles := cfg.Requests[0]
+	if candles.Method != http.MethodGet || candles.Table != "candles" || candles.RateLimiter == nil {
+		t.Fatalf("expected the defaults of New, got %s %s", candles.Method, candles.Table)
+	}
+
+	if candles.Query["start"] != "2022-05-01" || candles.Query["end"] != "2022-05-03" ||
+		candles.Query["granularity"] != "60" || candles.Timeseries.Period != 24*60*60 {
+		t.Fatalf("expected the timeseries in the query, got %v", candles.Query)
+	}
+
+	if cfg.Requests[1].Table != "accounts" || cfg.Requests[1].Timeseries != nil {
+		t.Fatalf("expected the settings to apply to the last request, got %+v", cfg.Requests[1])
+	}
+
+	if _, err := builder.Build(); !errors.Is(err, ErrInvalidBuilder) {
+		t.Fatalf("expected error %v building twice, got %v", ErrInvalidBuilder, err)
+	}
+
+	for _, tcase := range []struct {
+		name    string
+		builder *Builder
+		err     error
+	}{
+		{
+			name:    "before request",
+			builder: NewBuilder("https://api.exchange.com").RateLimit(5, time.Second).Table("t").Request("/t"),
+			err:     ErrInvalidBuilder,
+		},
+		{
+			name:    "no rate limit",
+			builder: NewBuilder("https://api.exchange.com").Request("/t"),
+			err:     ErrMissingConfigField,
+		},
+		{
+			name: "invalid timeseries",
+			builder: NewBuilder("https://api.exchange.com").RateLimit(5, time.Second).Request("/t").
+				Timeseries("start", "end", start, start.Add(time.Hour), time.Millisecond),
+			err: ErrInvalidTimeseriesPeriod,
+		},
+	} {
+		if _, err := tcase.builder.Build(); !errors.Is(err, tcase.err) {
+			t.Fatalf("%s: expected error %v, got %v", tcase.name, tcase.err, err)
+		}
+	}
+}
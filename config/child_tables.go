@@ -0,0 +1,95 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import "fmt"
+
+const (
+	// DefaultChildForeignKeyPrefix is prepended to the "parentKey" of a child table for the name of its foreign key
+	// column, unless "foreignKey" is set, e.g. "parent_id".
+	DefaultChildForeignKeyPrefix = "parent_"
+
+	// DefaultChildIndexColumn is the column that the position of a child record in its array is written to, unless
+	// "indexColumn" is set.
+	DefaultChildIndexColumn = "position"
+)
+
+// ChildTable writes the objects of an array column of the records to a table of their own, e.g. the line items of
+// an order, with the key of the record that they belong to. Values of the array that are not objects are written to
+// a "value" column.
+type ChildTable struct {
+	// Column is the array column of the records, once their "fields" are mapped and flattened. It is not written
+	// to the table of the records.
+	Column string `yaml:"column"`
+
+	// Table is the table that the objects of the array are written to.
+	Table string `yaml:"table"`
+
+	// ParentKey is the column of the records that identifies them, e.g. "id", whose value is written to the
+	// "foreignKey" column of their children.
+	ParentKey string `yaml:"parentKey"`
+
+	// ForeignKey is the column of the child table that the "parentKey" is written to, "parent_<parentKey>" by
+	// default.
+	ForeignKey string `yaml:"foreignKey"`
+
+	// IndexColumn is the column of the child table that the position of each object in its array is written to,
+	// "position" by default. Together with the "foreignKey" it identifies a child record.
+	IndexColumn string `yaml:"indexColumn"`
+}
+
+// ForeignKeyColumn returns the column of the child table that the key of the parent record is written to.
+func (child *ChildTable) ForeignKeyColumn() string {
+	if child.ForeignKey != "" {
+		return child.ForeignKey
+	}
+
+	return DefaultChildForeignKeyPrefix + child.ParentKey
+}
+
+// IndexColumnName returns the column of the child table that the position of a child record is written to.
+func (child *ChildTable) IndexColumnName() string {
+	if child.IndexColumn != "" {
+		return child.IndexColumn
+	}
+
+	return DefaultChildIndexColumn
+}
+
+// validateChildTables will ensure that every child table names its column, table and parent key, and that no array
+// column or table is used twice or by the table of the records, "table".
+func validateChildTables(field, table string, children []*ChildTable) error {
+	columns, tables := make(map[string]bool), make(map[string]bool)
+
+	for idx, child := range children {
+		switch {
+		case child == nil:
+			return fmt.Errorf("%w: %s[%d] must not be empty", ErrInvalidChildTables, field, idx)
+		case child.Column == "":
+			return fmt.Errorf("%w: %s[%d].column is required", ErrInvalidChildTables, field, idx)
+		case child.Table == "":
+			return fmt.Errorf("%w: %s[%d].table is required", ErrInvalidChildTables, field, idx)
+		case child.ParentKey == "":
+			return fmt.Errorf("%w: %s[%d].parentKey is required", ErrInvalidChildTables, field, idx)
+		case child.ForeignKeyColumn() == child.IndexColumnName():
+			return fmt.Errorf("%w: %s[%d].foreignKey and indexColumn must be different columns",
+				ErrInvalidChildTables, field, idx)
+		case child.Table == table:
+			return fmt.Errorf("%w: %s[%d].table must not be the table of its parent records", ErrInvalidChildTables,
+				field, idx)
+		case columns[child.Column]:
+			return fmt.Errorf("%w: %s has the column %q more than once", ErrInvalidChildTables, field, child.Column)
+		case tables[child.Table]:
+			return fmt.Errorf("%w: %s has the table %q more than once", ErrInvalidChildTables, field, child.Table)
+		}
+
+		columns[child.Column], tables[child.Table] = true, true
+	}
+
+	return nil
+}
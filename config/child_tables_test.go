@@ -0,0 +1,76 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestChildTables(t *testing.T) {
+	t.Parallel()
+
+	items := &ChildTable{Column: "line_items", Table: "order_items", ParentKey: "id"}
+
+	for _, tcase := range []struct {
+		name     string
+		children []*ChildTable
+		err      error
+	}{
+		{name: "no child tables"},
+		{name: "defaults", children: []*ChildTable{items}},
+		{
+			name: "columns",
+			children: []*ChildTable{
+				{Column: "line_items", Table: "order_items", ParentKey: "id", ForeignKey: "order_id", IndexColumn: "n"},
+			},
+		},
+		{name: "no column", children: []*ChildTable{{Table: "order_items", ParentKey: "id"}}, err: ErrInvalidChildTables},
+		{name: "no table", children: []*ChildTable{{Column: "line_items", ParentKey: "id"}}, err: ErrInvalidChildTables},
+		{
+			name:     "no parent key",
+			children: []*ChildTable{{Column: "line_items", Table: "order_items"}},
+			err:      ErrInvalidChildTables,
+		},
+		{
+			name:     "same columns",
+			children: []*ChildTable{{Column: "a", Table: "b", ParentKey: "id", IndexColumn: "parent_id"}},
+			err:      ErrInvalidChildTables,
+		},
+		{
+			name:     "parent table",
+			children: []*ChildTable{{Column: "line_items", Table: "orders", ParentKey: "id"}},
+			err:      ErrInvalidChildTables,
+		},
+		{
+			name:     "repeated table",
+			children: []*ChildTable{items, {Column: "refunds", Table: "order_items", ParentKey: "id"}},
+			err:      ErrInvalidChildTables,
+		},
+	} {
+		req := &Request{Endpoint: "/orders", Table: "orders", ChildTables: tcase.children}
+		if err := req.validate(); !errors.Is(err, tcase.err) {
+			t.Fatalf("%s: expected error %v, got %v", tcase.name, tcase.err, err)
+		}
+	}
+
+	if got := items.ForeignKeyColumn(); got != "parent_id" {
+		t.Fatalf("expected the default foreign key %q, got %q", "parent_id", got)
+	}
+
+	if got := items.IndexColumnName(); got != DefaultChildIndexColumn {
+		t.Fatalf("expected the default index column %q, got %q", DefaultChildIndexColumn, got)
+	}
+
+	table := &Table{ChildTables: []*ChildTable{items}}
+
+	req := &Request{}
+	if table.apply(req); len(req.ChildTables) != 1 {
+		t.Fatalf("expected the child tables of the table, got %+v", req.ChildTables)
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/alpstable/gidari/tools"
+)
+
+// tableWrite is how a request writes to its table. Requests that write to the same table in different ways would
+// interleave inconsistent rows, e.g. raw responses in a "clobColumn" next to decoded records.
+type tableWrite struct {
+	writeMode  string
+	clobColumn string
+}
+
+// newTableWrite returns the write of a request. Requests that truncate their table replace it, and a request that
+// opts out of the truncation of a "replace" table upserts into it.
+func newTableWrite(req *Request) tableWrite {
+	write := tableWrite{writeMode: req.WriteMode, clobColumn: req.ClobColumn}
+
+	switch {
+	case req.Truncate != nil && *req.Truncate:
+		write.writeMode = WriteModeReplace
+	case write.writeMode == "" || write.writeMode == WriteModeReplace:
+		write.writeMode = WriteModeUpsert
+	}
+
+	return write
+}
+
+// describe returns the settings of the write, limited to those that vary across the writes of its table.
+func (write tableWrite) describe(writeModeVaries, clobColumnVaries bool) string {
+	var fields []string
+
+	if writeModeVaries {
+		fields = append(fields, fmt.Sprintf("writeMode=%s", write.writeMode))
+	}
+
+	if clobColumnVaries {
+		fields = append(fields, fmt.Sprintf("clobColumn=%q", write.clobColumn))
+	}
+
+	return strings.Join(fields, ", ")
+}
+
+// tableCollision is a table that requests write to in different ways.
+type tableCollision struct {
+	table string
+	lines []string
+}
+
+func (collision tableCollision) String() string {
+	return fmt.Sprintf("requests collide on table %q:\n\t%s", collision.table, strings.Join(collision.lines, "\n\t"))
+}
+
+// sharesSinks returns true if two requests write to at least one of the same sinks. Requests without sinks write to
+// every sink.
+func sharesSinks(left, right *Request) bool {
+	if len(left.ConnectionStrings) == 0 || len(right.ConnectionStrings) == 0 {
+		return true
+	}
+
+	for _, dns := range left.ConnectionStrings {
+		for _, other := range right.ConnectionStrings {
+			if dns == other {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// findTableCollisions will return the tables that requests write to in different ways, in name order. Requests only
+// collide if they write to the same sink, and the collision lists every such request with the settings that differ
+// between them.
+func findTableCollisions(reqs []*Request) []tableCollision {
+	byTable := make(map[string][]int)
+
+	for idx, req := range reqs {
+		byTable[req.Table] = append(byTable[req.Table], idx)
+	}
+
+	var collisions []tableCollision
+
+	for table, indices := range byTable {
+		var writeModeVaries, clobColumnVaries bool
+
+		colliding := make(map[int]bool)
+
+		for pos, left := range indices {
+			for _, right := range indices[pos+1:] {
+				leftWrite, rightWrite := newTableWrite(reqs[left]), newTableWrite(reqs[right])
+				if leftWrite == rightWrite || !sharesSinks(reqs[left], reqs[right]) {
+					continue
+				}
+
+				writeModeVaries = writeModeVaries || leftWrite.writeMode != rightWrite.writeMode
+				clobColumnVaries = clobColumnVaries || leftWrite.clobColumn != rightWrite.clobColumn
+				colliding[left], colliding[right] = true, true
+			}
+		}
+
+		if len(colliding) == 0 {
+			continue
+		}
+
+		collision := tableCollision{table: table}
+
+		for _, idx := range indices {
+			if !colliding[idx] {
+				continue
+			}
+
+			collision.lines = append(collision.lines, fmt.Sprintf("requests[%d] (%s %s): %s", idx, reqs[idx].Method,
+				reqs[idx].Endpoint, newTableWrite(reqs[idx]).describe(writeModeVaries, clobColumnVaries)))
+		}
+
+		collisions = append(collisions, collision)
+	}
+
+	sort.Slice(collisions, func(i, j int) bool { return collisions[i].table < collisions[j].table })
+
+	return collisions
+}
+
+// checkTableCollisions will fail if requests write to the same table with a different write mode or "clobColumn",
+// unless the table allows collisions, in which case a warning is logged instead.
+func (cfg *Config) checkTableCollisions() error {
+	var failed []string
+
+	for _, collision := range findTableCollisions(cfg.Requests) {
+		if table, ok := cfg.Tables[collision.table]; ok && table.AllowCollisions {
+			cfg.Logger.Warn(tools.LogFormatter{Msg: collision.String()}.String())
+
+			continue
+		}
+
+		failed = append(failed, fmt.Sprintf("%s\nset tables.%s.allowCollisions to write to the table anyway",
+			collision, collision.table))
+	}
+
+	if len(failed) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s", ErrTableCollision, strings.Join(failed, "\n"))
+}
@@ -0,0 +1,105 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFindTableCollisions(t *testing.T) {
+	t.Parallel()
+
+	replace := true
+
+	for _, tcase := range []struct {
+		name string
+		reqs []*Request
+		want []tableCollision
+	}{
+		{
+			name: "same writes",
+			reqs: []*Request{{Endpoint: "/a", Table: "trades"}, {Endpoint: "/b", Table: "trades"}},
+		},
+		{
+			name: "different tables",
+			reqs: []*Request{{Endpoint: "/a", Table: "trades"}, {Endpoint: "/b", Table: "quotes", Truncate: &replace}},
+		},
+		{
+			name: "different sinks",
+			reqs: []*Request{
+				{Endpoint: "/a", Table: "trades", ConnectionStrings: []string{"mongodb://a"}},
+				{Endpoint: "/b", Table: "trades", ConnectionStrings: []string{"mongodb://b"}, ClobColumn: "raw"},
+			},
+		},
+		{
+			name: "write mode and clob column",
+			reqs: []*Request{
+				{Method: "GET", Endpoint: "/a", Table: "trades", Truncate: &replace},
+				{Method: "GET", Endpoint: "/b", Table: "quotes"},
+				{Method: "GET", Endpoint: "/c", Table: "trades", ConnectionStrings: []string{"mongodb://a"}},
+				{Method: "GET", Endpoint: "/d", Table: "trades", Truncate: &replace, ClobColumn: "raw"},
+			},
+			want: []tableCollision{{table: "trades", lines: []string{
+				`requests[0] (GET /a): writeMode=replace, clobColumn=""`,
+				`requests[2] (GET /c): writeMode=upsert, clobColumn=""`,
+				`requests[3] (GET /d): writeMode=replace, clobColumn="raw"`,
+			}}},
+		},
+	} {
+		tcase := tcase
+
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := findTableCollisions(tcase.reqs); !reflect.DeepEqual(got, tcase.want) {
+				t.Fatalf("expected collisions %+v, got %+v", tcase.want, got)
+			}
+		})
+	}
+}
+
+func TestNewTableCollisions(t *testing.T) {
+	t.Parallel()
+
+	data := `
+version: 1
+url: https://example.com
+connectionStrings:
+  - mongodb://localhost:27017/db
+rateLimit:
+  burst: 1
+  period: 1
+tables:
+  candles:
+    allowCollisions: %s
+requests:
+  - endpoint: /candles
+  - endpoint: /candles/daily
+    table: candles
+    clobColumn: raw
+`
+
+	_, err := New(context.Background(), newTestConfigFile(t, strings.Replace(data, "%s", "false", 1)))
+	if !errors.Is(err, ErrTableCollision) {
+		t.Fatalf("expected %v, got %v", ErrTableCollision, err)
+	}
+
+	for _, want := range []string{`requests[1] (GET /candles/daily): clobColumn="raw"`, "tables.candles.allowCollisions"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected the error to contain %q, got:\n%s", want, err)
+		}
+	}
+
+	if _, err := New(context.Background(), newTestConfigFile(t, strings.Replace(data, "%s", "true", 1))); err != nil {
+		t.Fatalf("expected the collision to be allowed, got %v", err)
+	}
+}
@@ -0,0 +1,42 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestValidateConditional(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name   string
+		method string
+		state  *StateConfig
+		err    error
+	}{
+		{name: "get", state: &StateConfig{File: "gidari.json"}},
+		{name: "explicit get", method: "get", state: &StateConfig{File: "gidari.json"}},
+		{name: "no state file", err: ErrInvalidConditional},
+		{name: "empty state file", state: &StateConfig{}, err: ErrInvalidConditional},
+		{name: "post", method: "POST", state: &StateConfig{File: "gidari.json"}, err: ErrInvalidConditional},
+	} {
+		cfg := &Config{
+			RateLimitConfig:   &RateLimitConfig{Burst: new(int), Period: new(time.Duration)},
+			ConnectionStrings: []string{"mongodb://localhost"},
+			Requests:          []*Request{{Endpoint: "/trades", Method: tcase.method, Conditional: true}},
+			State:             tcase.state,
+		}
+
+		if err := cfg.Validate(); !errors.Is(err, tcase.err) {
+			t.Fatalf("%s: expected error %v, got %v", tcase.name, tcase.err, err)
+		}
+	}
+}
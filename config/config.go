@@ -9,13 +9,22 @@ package config
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/alpstable/gidari/internal/control"
+	"github.com/alpstable/gidari/internal/events"
+	"github.com/alpstable/gidari/internal/handoff"
+	"github.com/alpstable/gidari/internal/monitor"
 	"github.com/alpstable/gidari/internal/proto"
+	"github.com/alpstable/gidari/internal/web"
 	"github.com/alpstable/gidari/tools"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/time/rate"
@@ -29,31 +38,220 @@ type APIKey struct {
 	Secret     string `yaml:"secret"`
 }
 
-// Auth2 is a struct that contains the authentication data for a web API that uses OAuth2.
-type Auth2 struct {
-	Bearer string `yaml:"bearer"`
-}
-
 // Authentication is the credential information to be used to construct an HTTP(s) transport for accessing the API.
 type Authentication struct {
-	APIKey *APIKey `yaml:"apiKey"`
-	Auth2  *Auth2  `yaml:"auth2"`
+	APIKey *APIKey     `yaml:"apiKey"`
+	Auth2  *Auth2      `yaml:"auth2"`
+	Custom *CustomAuth `yaml:"custom"`
 }
 
 // Config is the configuration used to query data from the web using HTTP requests and storing that data using
 // the repositories defined by the "ConnectionStrings" list.
 type Config struct {
-	RawURL            string           `yaml:"url"`
-	Authentication    Authentication   `yaml:"authentication"`
-	ConnectionStrings []string         `yaml:"connectionStrings"`
-	Requests          []*Request       `yaml:"requests"`
-	RateLimitConfig   *RateLimitConfig `yaml:"rateLimit"`
+	// Version is the version of the configuration format, see "CurrentVersion". Older versions are migrated when
+	// the configuration is loaded.
+	Version int `yaml:"version"`
+
+	// Provider selects the profile of a popular web API, e.g. "coinbase", which provides the defaults of the "url",
+	// "rateLimit" and timeseries range names, how the "apiKey" is sent, and how error responses are described and
+	// retried.
+	Provider string `yaml:"provider"`
+
+	RawURL            string         `yaml:"url"`
+	Authentication    Authentication `yaml:"authentication"`
+	ConnectionStrings []string       `yaml:"connectionStrings"`
+	Requests          []*Request     `yaml:"requests"`
+
+	// APIVersion pins the version of the web API that is requested, warning when a response is served with another
+	// version.
+	APIVersion *APIVersion `yaml:"apiVersion"`
+
+	// Tables are the settings for the tables that requests write to, keyed by table name.
+	Tables map[string]*Table `yaml:"tables"`
+
+	// AutoCreate will create the tables of SQL storage that do not exist before they are first written to, from
+	// the columns inferred from a sample of the records.
+	AutoCreate bool `yaml:"autoCreate"`
+
+	// TypeMapping overrides the column types of the tables that are created, keyed by storage scheme and then by
+	// the kind of the values of a column, e.g. "postgresql: {timestamp: TIMESTAMP}". The kinds are "boolean",
+	// "integer", "number", "timestamp", "string" and "json".
+	TypeMapping map[string]map[string]string `yaml:"typeMapping"`
+
+	// SchemaEvolution is what happens when the records of a request have fields that the table of SQL storage does
+	// not have a column for: "ignore" (the default) drops them, "evolve" adds the columns to the table before the
+	// records are written, and "strict" fails the write.
+	SchemaEvolution string `yaml:"schemaEvolution"`
+
+	// MaskKey is the secret key that the "hash" masks of the requests are computed with, as an HMAC-SHA256, so that
+	// the hashes of guessable values such as emails cannot be looked up. Without it they are plain SHA-256 hashes.
+	MaskKey string `yaml:"maskKey"`
+
+	// EncryptionKeys are the keys that the "encrypt" columns of the requests are encrypted with, keyed by the ID
+	// that is written with every encrypted value, so that keys can be rotated.
+	EncryptionKeys map[string]*EncryptionKey `yaml:"encryptionKeys"`
+
+	RateLimitConfig *RateLimitConfig `yaml:"rateLimit"`
+
+	// Maintenance are the recurring windows during which the web API is unavailable. The requests of a window are
+	// held while it is open, and made once it closes.
+	Maintenance []*MaintenanceWindow `yaml:"maintenance"`
+
+	// MaxMemory is the hard memory limit for the transport. As the process approaches this limit, the transport
+	// will degrade gracefully by reducing the number of concurrent fetches, shrinking the size of upsert batches,
+	// and spilling response bodies to disk.
+	MaxMemory ByteSize `yaml:"maxMemory"`
+
+	// StreamBatchSize is the number of records of each repository job when the top-level JSON arrays of responses
+	// are decoded as they are read, instead of being read into memory first. Responses are read whole if it is
+	// zero.
+	StreamBatchSize int `yaml:"streamBatchSize"`
+
+	// Proxy routes the requests to the web API through a proxy. Without it, the requests are made through the
+	// proxies of the "HTTP_PROXY", "HTTPS_PROXY" and "NO_PROXY" environment variables. It is not used by the
+	// "RoundTripper" or the transport of the "HTTPClient" of an embedder.
+	Proxy *ProxyConfig `yaml:"proxy"`
+
+	// TLS configures the TLS connections to the web API, e.g. with a private certificate authority or the client
+	// certificate of mutual TLS. Like "proxy", it is not used by the transport of an embedder.
+	TLS *TLSConfig `yaml:"tls"`
+
+	// AcceptEncoding are the content encodings that the requests advertise in their "Accept-Encoding" header, e.g.
+	// "br" and "gzip", for web APIs that only compress the encodings that are asked for. Without it, the requests
+	// advertise "gzip". Compressed responses are decoded from "gzip", "deflate", "br" and "zstd" either way.
+	AcceptEncoding []string `yaml:"acceptEncoding"`
+
+	// StorageRateLimit caps the rate that records are written to each storage target, independent of the rate
+	// that the web API is fetched at.
+	StorageRateLimit *StorageRateLimit `yaml:"storageRateLimit"`
+
+	// Partitions is the number of transactions that each PostgreSQL and MySQL storage target is written with in
+	// parallel, with the records of a table partitioned between them by the hash of their primary key so that no
+	// two transactions write to the same rows. Tables are written with a single transaction if it is zero or one.
+	Partitions int `yaml:"partitions"`
+
+	// Autoscale will grow and shrink the number of web workers that fetch from each host at once, instead of
+	// fetching with as many web workers as there are cores on the machine. Autoscaling is experimental, so it has to
+	// be enabled in "experimental" as well.
+	Autoscale *AutoscaleConfig `yaml:"autoscale"`
+
+	// Experimental opts in to experimental behaviors by name, e.g. "autoscale", which are not enabled otherwise.
+	Experimental Experimental `yaml:"experimental"`
+
+	// State configures the store used to persist watermarks between runs, making timeseries requests incremental.
+	State *StateConfig `yaml:"state"`
+
+	// RunHooks are the names of the run hooks, registered with "RegisterRunHook", that are run in order once a run
+	// has completed successfully. They read and write the variables of the state store, e.g. for an incremental
+	// pattern that the watermarks do not cover, so "state.file" has to be set.
+	RunHooks []string `yaml:"runHooks"`
+
+	// Checkpoint configures how the progress of a run is persisted. When set, data is committed to storage in
+	// batches and each committed batch is recorded, so that an interrupted run can be resumed.
+	Checkpoint *CheckpointConfig `yaml:"checkpoint"`
+
+	// Resume will skip the requests recorded as completed in the checkpoint file by a previous, interrupted run.
+	Resume bool `yaml:"-"`
+
+	// DeadLetter configures the capture of requests that fail, so that the rest of the run can continue and the
+	// failed requests can be replayed later.
+	DeadLetter *DeadLetterConfig `yaml:"deadLetter"`
+
+	// Fixtures configures how the sample responses captured by "gidari fixtures" are scrubbed of personal data.
+	Fixtures *FixturesConfig `yaml:"fixtures"`
+
+	// Limits is the budget for a run, guarding against configurations that would make far more requests than
+	// intended.
+	Limits *Limits `yaml:"limits"`
+
+	// MaxDuration is how long a run may take, e.g. "2h". Once it has passed, no new requests are made, the requests
+	// in-flight are abandoned for a resumed run, and the data that has been fetched is committed.
+	MaxDuration time.Duration `yaml:"maxDuration"`
+
+	// Deadline is the time by which a run has to stop like it does after its "maxDuration", e.g. before the next run
+	// of a schedule. The earlier of the two is kept.
+	Deadline *time.Time `yaml:"deadline"`
+
+	// Assertions are checked against the metrics of the run once it has completed, failing the run if any of
+	// them do not hold.
+	Assertions []*Assertion `yaml:"assertions"`
+
+	// CanaryAssertions are checked against the metrics of the "canary" requests once they have been made, before
+	// any other request of the run is made.
+	CanaryAssertions []*Assertion `yaml:"canaryAssertions"`
+
+	// Preflight will check that every source and storage target is reachable, with the configured credentials,
+	// before the run starts.
+	Preflight bool `yaml:"preflight"`
+
+	// Workspace configures where the temporary files of a run are kept and whether they are cleaned up.
+	Workspace *WorkspaceConfig `yaml:"workspace"`
+
+	// Manifest configures the manifest of the run that is written once it is over, for comparing runs with
+	// "gidari diff-runs".
+	Manifest *ManifestConfig `yaml:"manifest"`
+
+	// ResponseCache caches the HTTP responses of the run on disk, and answers the requests of later runs from the
+	// cache until the responses expire, for running a configuration again during development without hitting the
+	// rate limit of the web API.
+	ResponseCache *ResponseCacheConfig `yaml:"responseCache"`
+
+	Logger *logrus.Logger
+
+	// Clock is the source of time for the transport, which tests can replace to simulate the passage of time. The
+	// default is "tools.RealClock".
+	Clock tools.Clock `yaml:"-"`
+
+	// Monitor collects the progress of the run for the "--tui" dashboard. It is nil unless the dashboard is shown.
+	Monitor *monitor.Monitor `yaml:"-"`
+
+	// Events is the stream of structured events of the run, e.g. "chunk_committed", for orchestrators to follow its
+	// progress. It is nil unless the command is run with "--events ndjson".
+	Events *events.Stream `yaml:"-"`
+
+	// Control pauses, resumes and cancels individual requests while the run continues. It is nil unless commands
+	// are read from the "--tui" dashboard.
+	Control *control.Control `yaml:"-"`
+
+	// Handoff hands off the records of every table to the code that embeds the transport as they are written, in
+	// addition to writing them to storage. It is nil unless the transport is run with "gidari.TransportStream".
+	Handoff *handoff.Handoff `yaml:"-"`
+
+	// Dump logs the progress of the run, its queues, what each worker is doing and the state of the rate limiters
+	// each time it receives, without interrupting the run. The command sends on it on SIGUSR1.
+	Dump <-chan struct{} `yaml:"-"`
+
+	// Cassette records the HTTP interactions of the run, or replays them without the web API. It is nil unless the
+	// command is run with "--record" or "--replay".
+	Cassette *web.Cassette `yaml:"-"`
+
+	// HTTPClient is the embedder's own client that the requests of every worker are based on, keeping its timeout,
+	// cookie jar and redirect policy, and sending the authenticated requests with its transport. It is nil unless
+	// it is set in Go.
+	HTTPClient *http.Client `yaml:"-"`
+
+	// RoundTripper sends the authenticated requests of every worker instead of "http.DefaultTransport" or the
+	// transport of "HTTPClient", e.g. with instrumentation, a proxy or a test double. It is nil unless it is set in
+	// Go.
+	RoundTripper http.RoundTripper `yaml:"-"`
 
-	Logger         *logrus.Logger
 	StgConstructor proto.Constructor
-	Truncate       bool
+
+	// Truncate will truncate the table of every request that does not set "truncate" itself before it is loaded.
+	Truncate bool
 
 	URL *url.URL `yaml:"-"`
+
+	// sourcePath and sourceSum are the absolute path of the configuration file that the configuration was read
+	// from, and the SHA-256 of its contents.
+	sourcePath, sourceSum string
+}
+
+// Source returns the absolute path of the configuration file that "New" read the configuration from, and the
+// hex-encoded SHA-256 of its contents, e.g. to tell whether a later run uses the same configuration. Both are empty
+// for a configuration that was not read from a file.
+func (cfg *Config) Source() (string, string) {
+	return cfg.sourcePath, cfg.sourceSum
 }
 
 // New takes a YAML byte slice and returns a new transport configuration for upserting data to storage.
@@ -77,19 +275,63 @@ func New(_ context.Context, file *os.File) (*Config, error) {
 		return nil, fmt.Errorf("unable to read file: %w", err)
 	}
 
-	if err := yaml.Unmarshal(bytes, &cfg); err != nil {
+	// The source of the configuration is recorded in the manifest of its runs.
+	path, err := filepath.Abs(file.Name())
+	if err != nil {
+		path = file.Name()
+	}
+
+	sum := sha256.Sum256(bytes)
+	cfg.sourcePath, cfg.sourceSum = path, hex.EncodeToString(sum[:])
+
+	bytes, declared, warnings, err := migrate(bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, warning := range warnings {
+		cfg.Logger.Warn(tools.LogFormatter{Msg: warning}.String())
+	}
+
+	// Versioned configuration files are decoded strictly, so that misspelled or unknown fields are reported
+	// rather than silently ignored.
+	unmarshal := yaml.Unmarshal
+	if declared != legacyVersion {
+		unmarshal = yaml.UnmarshalStrict
+	}
+
+	if err := unmarshal(bytes, &cfg); err != nil {
 		return nil, fmt.Errorf("unable to unmarshal YAML: %w", err)
 	}
 
-	if err := cfg.Validate(); err != nil {
+	if err := cfg.prepare(); err != nil {
 		return nil, err
 	}
 
-	cfg.URL, err = url.Parse(cfg.RawURL)
+	return &cfg, nil
+}
+
+// prepare will validate a configuration that was decoded or built, and set the defaults of its requests.
+func (cfg *Config) prepare() error {
+	if err := cfg.applyProvider(); err != nil {
+		return err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	if cfg.Autoscale == nil && cfg.Experimental.Enabled(ExperimentAutoscale) {
+		cfg.Autoscale = &AutoscaleConfig{}
+	}
+
+	parsed, err := url.Parse(cfg.RawURL)
 	if err != nil {
-		return nil, fmt.Errorf("unable to parse URL: %w", err)
+		return fmt.Errorf("unable to parse URL: %w", err)
 	}
 
+	cfg.URL = parsed
+
 	// create a rate limiter to pass to all "flattenedRequest". This has to be defined outside of the scope of
 	// individual "flattenedRequest"s so that they all share the same rate limiter, even concurrent requests to
 	// different endpoints could cause a rate limit error on a web API.
@@ -97,6 +339,7 @@ func New(_ context.Context, file *os.File) (*Config, error) {
 
 	// Update default request data.
 	for _, req := range cfg.Requests {
+		req.Method = strings.ToUpper(req.Method)
 		if req.Method == "" {
 			req.Method = http.MethodGet
 		}
@@ -106,10 +349,76 @@ func New(_ context.Context, file *os.File) (*Config, error) {
 			req.Table = endpointParts[len(endpointParts)-1]
 		}
 
+		// Storage is resolved before the table settings, which only apply to requests without sinks of their own.
+		if len(req.Storage) != 0 {
+			req.ConnectionStrings = storageSinks(req.Storage, cfg.ConnectionStrings)
+		}
+
+		if table, ok := cfg.Tables[req.Table]; ok {
+			table.apply(req)
+		}
+
+		if req.Truncate == nil && (cfg.Truncate || req.WriteMode == WriteModeReplace) {
+			truncate := true
+			req.Truncate = &truncate
+		}
+
+		if len(req.AcceptEncoding) == 0 {
+			req.AcceptEncoding = cfg.AcceptEncoding
+		}
+
+		req.AutoCreate = cfg.newAutoCreate(req)
+		req.SchemaEvolution = cfg.newSchemaEvolution(req)
+		req.PartitionKeys = cfg.partitionKeys(req)
+
+		// YAML decodes nested maps with interface keys, which cannot be encoded as JSON.
+		if req.Body != nil {
+			req.Body, _ = tools.NormalizeYAML(req.Body).(map[string]interface{})
+		}
+
 		req.RateLimiter = rateLimiter
+
+		for _, window := range cfg.Maintenance {
+			if window.appliesTo(req) {
+				req.Maintenance = append(req.Maintenance, window)
+			}
+		}
 	}
 
-	return &cfg, nil
+	// Collisions are checked once the table settings have been applied to every request.
+	if err := cfg.checkTableCollisions(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// validateCanaries will ensure that the canary assertions are valid, and that there are canary requests for them
+// to check.
+func (cfg *Config) validateCanaries(metrics map[string]string) error {
+	if len(cfg.CanaryAssertions) == 0 {
+		return nil
+	}
+
+	canaries := 0
+
+	for _, req := range cfg.Requests {
+		if req.Canary {
+			canaries++
+		}
+	}
+
+	if canaries == 0 {
+		return fmt.Errorf("%w: canaryAssertions need a request with \"canary\" set", ErrInvalidCanary)
+	}
+
+	for _, assertion := range cfg.CanaryAssertions {
+		if err := assertion.validate(metrics); err != nil {
+			return fmt.Errorf("canaryAssertions: %w", err)
+		}
+	}
+
+	return nil
 }
 
 // Validate will ensure that the configuration is valid for querying the web API.
@@ -122,6 +431,195 @@ func (cfg *Config) Validate() error {
 		return ErrInvalidRateLimit
 	}
 
+	if cfg.Authentication.Auth2 != nil {
+		if err := cfg.Authentication.Auth2.validate(); err != nil {
+			return err
+		}
+	}
+
+	if custom := cfg.Authentication.Custom; custom != nil {
+		if err := custom.validate(cfg.Authentication.Auth2, cfg.Authentication.APIKey); err != nil {
+			return err
+		}
+	}
+
+	if cfg.Checkpoint != nil {
+		if err := cfg.Checkpoint.validate(); err != nil {
+			return err
+		}
+	}
+
+	if cfg.DeadLetter != nil {
+		if err := cfg.DeadLetter.validate(); err != nil {
+			return err
+		}
+	}
+
+	if cfg.APIVersion != nil {
+		if err := cfg.APIVersion.validate(); err != nil {
+			return err
+		}
+	}
+
+	if cfg.Fixtures != nil {
+		if err := cfg.Fixtures.validate(); err != nil {
+			return err
+		}
+	}
+
+	if err := cfg.validateDeadline(); err != nil {
+		return err
+	}
+
+	if cfg.Limits != nil {
+		if err := cfg.Limits.validate(); err != nil {
+			return err
+		}
+	}
+
+	if err := cfg.Experimental.validate(); err != nil {
+		return err
+	}
+
+	if cfg.Autoscale != nil {
+		if !cfg.Experimental.Enabled(ExperimentAutoscale) {
+			return fmt.Errorf("%w: autoscale is experimental, set experimental.%s to enable it", ErrInvalidAutoscale,
+				ExperimentAutoscale)
+		}
+
+		if err := cfg.Autoscale.validate(); err != nil {
+			return err
+		}
+	}
+
+	if cfg.Workspace != nil {
+		if err := cfg.Workspace.validate(); err != nil {
+			return err
+		}
+	}
+
+	if cfg.ResponseCache != nil {
+		if err := cfg.ResponseCache.validate(); err != nil {
+			return err
+		}
+	}
+
+	for _, window := range cfg.Maintenance {
+		if err := window.validate(); err != nil {
+			return err
+		}
+	}
+
+	if cfg.Partitions < 0 {
+		return fmt.Errorf("%w: %d must not be negative", ErrInvalidPartitions, cfg.Partitions)
+	}
+
+	if cfg.Proxy != nil {
+		if err := cfg.Proxy.validate(); err != nil {
+			return err
+		}
+	}
+
+	if err := validateAcceptEncoding("acceptEncoding", cfg.AcceptEncoding); err != nil {
+		return err
+	}
+
+	if cfg.TLS != nil {
+		if err := cfg.TLS.validate(); err != nil {
+			return err
+		}
+
+		if cfg.TLS.InsecureSkipVerify {
+			logWarn := tools.LogFormatter{
+				Msg: "tls.insecureSkipVerify is set, the certificates of the web API are not verified",
+			}
+			cfg.Logger.Warn(logWarn.String())
+		}
+	}
+
+	if cfg.StorageRateLimit != nil {
+		if err := cfg.StorageRateLimit.validate(cfg.ConnectionStrings); err != nil {
+			return err
+		}
+	}
+
+	if cfg.StreamBatchSize < 0 {
+		return fmt.Errorf("%w: %d must not be negative", ErrInvalidStreamBatchSize, cfg.StreamBatchSize)
+	}
+
+	if err := validateTypeMapping(cfg.TypeMapping); err != nil {
+		return err
+	}
+
+	if err := validateSchemaEvolution("schemaEvolution", cfg.SchemaEvolution); err != nil {
+		return err
+	}
+
+	if err := validateEncryptionKeys(cfg.EncryptionKeys); err != nil {
+		return err
+	}
+
+	for name, table := range cfg.Tables {
+		if err := table.validate(name, cfg.ConnectionStrings); err != nil {
+			return err
+		}
+	}
+
+	// Requests that declare the same metric must aggregate it the same way.
+	metrics := make(map[string]string)
+
+	for _, req := range cfg.Requests {
+		if err := req.validate(); err != nil {
+			return err
+		}
+
+		if err := validateSinks("requests.connectionStrings", req.ConnectionStrings, cfg.ConnectionStrings); err != nil {
+			return err
+		}
+
+		if err := req.validateStorage(cfg.ConnectionStrings); err != nil {
+			return err
+		}
+
+		if req.Conditional && (cfg.State == nil || cfg.State.File == "") {
+			return fmt.Errorf("%w: %s needs a state.file to store the validators of its responses",
+				ErrInvalidConditional, req.Endpoint)
+		}
+
+		if req.UsesState() && (cfg.State == nil || cfg.State.File == "") {
+			return fmt.Errorf("%w: the templates of %s need a state.file to store their variables",
+				ErrInvalidQueryTemplate, req.Endpoint)
+		}
+
+		if req.Encrypt != nil && cfg.EncryptionKeys[req.Encrypt.KeyID] == nil {
+			return fmt.Errorf("%w: encrypt.keyID %q of %s is not one of encryptionKeys", ErrInvalidEncryption,
+				req.Encrypt.KeyID, req.Endpoint)
+		}
+
+		for _, metric := range req.Metrics {
+			if aggregate, ok := metrics[metric.Name]; ok && aggregate != metric.AggregateOrDefault() {
+				return fmt.Errorf("%w: metric %q is aggregated with both %q and %q", ErrInvalidMetric,
+					metric.Name, aggregate, metric.AggregateOrDefault())
+			}
+
+			metrics[metric.Name] = metric.AggregateOrDefault()
+		}
+	}
+
+	if err := cfg.validateRunHooks(); err != nil {
+		return err
+	}
+
+	for _, assertion := range cfg.Assertions {
+		if err := assertion.validate(metrics); err != nil {
+			return err
+		}
+	}
+
+	if err := cfg.validateCanaries(metrics); err != nil {
+		return err
+	}
+
 	if cfg.ConnectionStrings == nil {
 		logWarn := tools.LogFormatter{
 			Msg: "no connectionStrings specified in the config file",
@@ -0,0 +1,84 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// The types that the values of CSV columns are coerced to.
+const (
+	CSVTypeString  = "string"
+	CSVTypeInteger = "integer"
+	CSVTypeNumber  = "number"
+	CSVTypeBoolean = "boolean"
+)
+
+// CSVFormat is how the rows of a CSV response body are read into records.
+type CSVFormat struct {
+	// Delimiter is the character that separates the fields of a row. The default is ",".
+	Delimiter string `yaml:"delimiter"`
+
+	// Header is false if the first row is data rather than the names of the columns. The default is true.
+	Header *bool `yaml:"header"`
+
+	// Columns are the names of the columns, which take precedence over the header. They are required if there is
+	// no header.
+	Columns []string `yaml:"columns"`
+
+	// Types are the types that the values of columns are coerced to, keyed by column: "string", "integer",
+	// "number" or "boolean". Empty values of typed columns are null.
+	Types map[string]string `yaml:"types"`
+
+	// Coerce will write the values of the columns without a type as numbers and booleans when they can be parsed
+	// as such, instead of as strings.
+	Coerce bool `yaml:"coerce"`
+}
+
+func (format *CSVFormat) validate(endpoint string) error {
+	if format.Delimiter != "" {
+		delim, size := utf8.DecodeRuneInString(format.Delimiter)
+		if size != len(format.Delimiter) || delim == '"' || delim == '\r' || delim == '\n' ||
+			delim == utf8.RuneError {
+			return fmt.Errorf("%w: delimiter of %s must be a single character other than a quote or newline, "+
+				"got %q", ErrInvalidCSV, endpoint, format.Delimiter)
+		}
+	}
+
+	if !format.HasHeader() && len(format.Columns) == 0 {
+		return fmt.Errorf("%w: %s must set the columns of a CSV response without a header", ErrInvalidCSV, endpoint)
+	}
+
+	for column, typ := range format.Types {
+		switch typ {
+		case CSVTypeString, CSVTypeInteger, CSVTypeNumber, CSVTypeBoolean:
+		default:
+			return fmt.Errorf("%w: type %q of column %s of %s must be one of %q, %q, %q or %q", ErrInvalidCSV, typ,
+				column, endpoint, CSVTypeString, CSVTypeInteger, CSVTypeNumber, CSVTypeBoolean)
+		}
+	}
+
+	return nil
+}
+
+// Comma returns the delimiter of the fields of a row, defaulting to a comma.
+func (format *CSVFormat) Comma() rune {
+	if format == nil || format.Delimiter == "" {
+		return ','
+	}
+
+	delim, _ := utf8.DecodeRuneInString(format.Delimiter)
+
+	return delim
+}
+
+// HasHeader returns true if the first row of the body is the names of the columns.
+func (format *CSVFormat) HasHeader() bool {
+	return format == nil || format.Header == nil || *format.Header
+}
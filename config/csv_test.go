@@ -0,0 +1,63 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRequestCSV(t *testing.T) {
+	t.Parallel()
+
+	noHeader := false
+
+	for _, tcase := range []struct {
+		name   string
+		format string
+		csv    *CSVFormat
+		err    error
+	}{
+		{name: "defaults", format: ResponseFormatCSV},
+		{
+			name:   "options",
+			format: ResponseFormatCSV,
+			csv: &CSVFormat{
+				Delimiter: ";",
+				Header:    &noHeader,
+				Columns:   []string{"time", "price"},
+				Types:     map[string]string{"time": CSVTypeInteger, "price": CSVTypeNumber},
+			},
+		},
+		{name: "tab", format: ResponseFormatCSV, csv: &CSVFormat{Delimiter: "\t"}},
+		{name: "not csv", format: ResponseFormatJSON, csv: &CSVFormat{}, err: ErrInvalidCSV},
+		{name: "long delimiter", format: ResponseFormatCSV, csv: &CSVFormat{Delimiter: ";;"}, err: ErrInvalidCSV},
+		{name: "quote delimiter", format: ResponseFormatCSV, csv: &CSVFormat{Delimiter: `"`}, err: ErrInvalidCSV},
+		{name: "no columns", format: ResponseFormatCSV, csv: &CSVFormat{Header: &noHeader}, err: ErrInvalidCSV},
+		{
+			name:   "unknown type",
+			format: ResponseFormatCSV,
+			csv:    &CSVFormat{Types: map[string]string{"time": "date"}},
+			err:    ErrInvalidCSV,
+		},
+	} {
+		req := &Request{Endpoint: "/report", ResponseFormat: tcase.format, CSV: tcase.csv}
+		if err := req.validate(); !errors.Is(err, tcase.err) {
+			t.Fatalf("%s: expected error %v, got %v", tcase.name, tcase.err, err)
+		}
+	}
+
+	format := (&Request{ResponseFormat: ResponseFormatCSV}).CSVFormat()
+	if format == nil || format.Comma() != ',' || !format.HasHeader() {
+		t.Fatalf("expected a CSV body to default to a comma delimiter and a header, got %+v", format)
+	}
+
+	if format := (&Request{ResponseFormat: ResponseFormatNDJSON}).CSVFormat(); format != nil {
+		t.Fatalf("expected no CSV format for an NDJSON body, got %+v", format)
+	}
+}
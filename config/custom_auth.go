@@ -0,0 +1,44 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/alpstable/gidari/internal/web/auth"
+)
+
+// CustomAuth is the authentication of a web API with a custom scheme, e.g. the request signing of a proprietary web
+// API, that was registered with "gidari.RegisterAuthenticator".
+type CustomAuth struct {
+	// Scheme is the name that the authenticator was registered with.
+	Scheme string `yaml:"scheme"`
+
+	// Settings are passed to the factory of the authenticator, e.g. its credentials.
+	Settings map[string]string `yaml:"settings"`
+}
+
+func (custom *CustomAuth) validate(auth2 *Auth2, apiKey *APIKey) error {
+	if custom.Scheme == "" {
+		return fmt.Errorf("%w: custom authentication requires a scheme", ErrInvalidAuthentication)
+	}
+
+	if auth2 != nil || apiKey != nil {
+		return fmt.Errorf("%w: custom authentication cannot be used with apiKey or auth2", ErrInvalidAuthentication)
+	}
+
+	for _, name := range auth.Authenticators() {
+		if name == custom.Scheme {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: custom authentication scheme %q is not registered, registered schemes: [%s]",
+		ErrInvalidAuthentication, custom.Scheme, strings.Join(auth.Authenticators(), ", "))
+}
@@ -0,0 +1,55 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/alpstable/gidari/internal/web/auth"
+)
+
+type testAuthenticator struct{}
+
+func (testAuthenticator) Authenticate(*http.Request) error          { return nil }
+func (testAuthenticator) Unauthorized(*http.Response) (bool, error) { return false, nil }
+
+func TestValidateCustomAuth(t *testing.T) {
+	t.Parallel()
+
+	err := auth.RegisterAuthenticator("config-test", func(map[string]string) (auth.Authenticator, error) {
+		return testAuthenticator{}, nil
+	})
+	if err != nil {
+		t.Fatalf("failed to register authenticator: %v", err)
+	}
+
+	t.Cleanup(func() { auth.UnregisterAuthenticator("config-test") })
+
+	for _, tcase := range []struct {
+		name   string
+		custom *CustomAuth
+		auth2  *Auth2
+		err    error
+	}{
+		{name: "registered", custom: &CustomAuth{Scheme: "config-test", Settings: map[string]string{"key": "k"}}},
+		{name: "no scheme", custom: &CustomAuth{}, err: ErrInvalidAuthentication},
+		{name: "unregistered", custom: &CustomAuth{Scheme: "missing"}, err: ErrInvalidAuthentication},
+		{name: "auth2", custom: &CustomAuth{Scheme: "config-test"}, auth2: &Auth2{}, err: ErrInvalidAuthentication},
+	} {
+		if err := tcase.custom.validate(tcase.auth2, nil); !errors.Is(err, tcase.err) {
+			t.Fatalf("%s: expected error %v, got %v", tcase.name, tcase.err, err)
+		}
+	}
+
+	secrets := Authentication{Custom: &CustomAuth{Scheme: "config-test", Settings: map[string]string{"key": "k"}}}
+	if got := secrets.Secrets(); len(got) != 1 || got[0] != "k" {
+		t.Fatalf("expected the settings of the scheme to be secrets, got %v", got)
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import "fmt"
+
+// DefaultDeadLetterFile is the dead-letter file used when "deadLetter.file" is not set.
+const DefaultDeadLetterFile = "gidari.deadletter.jsonl"
+
+// DeadLetterConfig configures how failed requests are captured. Without it, a failed request aborts the run.
+type DeadLetterConfig struct {
+	// File is the path of the newline-delimited JSON file that failed requests are appended to, with their fetch
+	// configuration, response status and error. The requests in the file can be re-executed with "gidari replay".
+	File string `yaml:"file"`
+
+	// Retries is the number of times a request is retried, with exponential backoff, before it is dead-lettered.
+	// Only network errors, "429 Too Many Requests" and server errors are retried.
+	Retries int `yaml:"retries"`
+}
+
+func (deadLetter *DeadLetterConfig) validate() error {
+	if deadLetter.Retries < 0 {
+		return fmt.Errorf("%w: retries must not be negative", ErrInvalidDeadLetter)
+	}
+
+	return nil
+}
+
+// Path returns the dead-letter file, defaulting to "DefaultDeadLetterFile".
+func (deadLetter *DeadLetterConfig) Path() string {
+	if deadLetter == nil || deadLetter.File == "" {
+		return DefaultDeadLetterFile
+	}
+
+	return deadLetter.File
+}
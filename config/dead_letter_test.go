@@ -0,0 +1,30 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDeadLetterConfig(t *testing.T) {
+	t.Parallel()
+
+	var unset *DeadLetterConfig
+	if path := unset.Path(); path != DefaultDeadLetterFile {
+		t.Fatalf("expected the default dead-letter file, got %q", path)
+	}
+
+	if path := (&DeadLetterConfig{File: "failed.jsonl"}).Path(); path != "failed.jsonl" {
+		t.Fatalf("expected the configured dead-letter file, got %q", path)
+	}
+
+	if err := (&DeadLetterConfig{Retries: -1}).validate(); !errors.Is(err, ErrInvalidDeadLetter) {
+		t.Fatalf("expected %v, got %v", ErrInvalidDeadLetter, err)
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+func (cfg *Config) validateDeadline() error {
+	if cfg.MaxDuration < 0 {
+		return fmt.Errorf("%w: maxDuration %s must not be negative", ErrInvalidDeadline, cfg.MaxDuration)
+	}
+
+	if cfg.Deadline != nil && cfg.Deadline.IsZero() {
+		return fmt.Errorf("%w: deadline must be a time, e.g. 2022-10-15T06:00:00Z", ErrInvalidDeadline)
+	}
+
+	return nil
+}
+
+// RunDeadline returns the time by which a run that starts at "start" has to stop: the earlier of the end of its
+// "maxDuration" and its "deadline". It returns false if the configuration sets neither.
+func (cfg *Config) RunDeadline(start time.Time) (time.Time, bool) {
+	var (
+		deadline time.Time
+		ok       bool
+	)
+
+	if cfg.MaxDuration > 0 {
+		deadline, ok = start.Add(cfg.MaxDuration), true
+	}
+
+	if cfg.Deadline != nil && (!ok || cfg.Deadline.Before(deadline)) {
+		deadline, ok = *cfg.Deadline, true
+	}
+
+	return deadline, ok
+}
@@ -0,0 +1,107 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRunDeadline(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2022, 10, 14, 15, 0, 0, 0, time.UTC)
+	early, late := start.Add(30*time.Minute), start.Add(3*time.Hour)
+
+	for _, tcase := range []struct {
+		name        string
+		maxDuration time.Duration
+		deadline    *time.Time
+		want        time.Time
+		ok          bool
+	}{
+		{name: "none"},
+		{name: "max duration", maxDuration: time.Hour, want: start.Add(time.Hour), ok: true},
+		{name: "deadline", deadline: &late, want: late, ok: true},
+		{name: "earlier deadline", maxDuration: time.Hour, deadline: &early, want: early, ok: true},
+		{name: "earlier max duration", maxDuration: time.Hour, deadline: &late, want: start.Add(time.Hour), ok: true},
+	} {
+		cfg := &Config{MaxDuration: tcase.maxDuration, Deadline: tcase.deadline}
+
+		got, ok := cfg.RunDeadline(start)
+		if ok != tcase.ok || !got.Equal(tcase.want) {
+			t.Fatalf("%s: expected deadline %s (%t), got %s (%t)", tcase.name, tcase.want, tcase.ok, got, ok)
+		}
+	}
+}
+
+func TestValidateDeadline(t *testing.T) {
+	t.Parallel()
+
+	var zero time.Time
+
+	for _, tcase := range []struct {
+		name string
+		cfg  *Config
+		err  error
+	}{
+		{name: "none", cfg: &Config{}},
+		{name: "max duration", cfg: &Config{MaxDuration: time.Hour}},
+		{name: "negative max duration", cfg: &Config{MaxDuration: -time.Hour}, err: ErrInvalidDeadline},
+		{name: "zero deadline", cfg: &Config{Deadline: &zero}, err: ErrInvalidDeadline},
+	} {
+		if err := tcase.cfg.validateDeadline(); !errors.Is(err, tcase.err) {
+			t.Fatalf("%s: expected error %v, got %v", tcase.name, tcase.err, err)
+		}
+	}
+}
+
+func TestNewAppliesTimeouts(t *testing.T) {
+	t.Parallel()
+
+	data := `
+version: 1
+url: https://example.com
+connectionStrings:
+  - mongodb://localhost:27017/db
+rateLimit:
+  burst: 1
+  period: 1
+maxDuration: 2h
+deadline: 2022-10-15T06:00:00Z
+requests:
+  - endpoint: /candles
+    timeout: 30s
+  - endpoint: /trades
+`
+
+	cfg, err := New(context.Background(), newTestConfigFile(t, data))
+	if err != nil {
+		t.Fatalf("failed to create config: %v", err)
+	}
+
+	if cfg.MaxDuration != 2*time.Hour {
+		t.Fatalf("expected a maxDuration of 2h, got %s", cfg.MaxDuration)
+	}
+
+	if want := time.Date(2022, 10, 15, 6, 0, 0, 0, time.UTC); cfg.Deadline == nil || !cfg.Deadline.Equal(want) {
+		t.Fatalf("expected the deadline %s, got %v", want, cfg.Deadline)
+	}
+
+	if cfg.Requests[0].Timeout != 30*time.Second || cfg.Requests[1].Timeout != 0 {
+		t.Fatalf("expected a timeout of 30s for /candles only, got %s and %s", cfg.Requests[0].Timeout,
+			cfg.Requests[1].Timeout)
+	}
+
+	cfg.Requests[1].Timeout = -time.Second
+	if err := cfg.Validate(); !errors.Is(err, ErrInvalidTimeout) {
+		t.Fatalf("expected error %v, got %v", ErrInvalidTimeout, err)
+	}
+}
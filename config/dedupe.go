@@ -0,0 +1,28 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import "fmt"
+
+// validateDedupeKeys will ensure that every column of the "dedupeKeys" is named, and is named once.
+func validateDedupeKeys(field string, keys []string) error {
+	seen := make(map[string]bool, len(keys))
+
+	for idx, key := range keys {
+		switch {
+		case key == "":
+			return fmt.Errorf("%w: %s[%d] must not be empty", ErrInvalidDedupeKeys, field, idx)
+		case seen[key]:
+			return fmt.Errorf("%w: %s has the column %q more than once", ErrInvalidDedupeKeys, field, key)
+		}
+
+		seen[key] = true
+	}
+
+	return nil
+}
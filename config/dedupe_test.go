@@ -0,0 +1,38 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDedupeKeys(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name string
+		keys []string
+		err  error
+	}{
+		{name: "no keys"},
+		{name: "keys", keys: []string{"id", "exchange"}},
+		{name: "empty key", keys: []string{"id", ""}, err: ErrInvalidDedupeKeys},
+		{name: "repeated key", keys: []string{"id", "id"}, err: ErrInvalidDedupeKeys},
+	} {
+		req := &Request{Endpoint: "/trades", DedupeKeys: tcase.keys}
+		if err := req.validate(); !errors.Is(err, tcase.err) {
+			t.Fatalf("%s: expected error %v, got %v", tcase.name, tcase.err, err)
+		}
+
+		table := &Table{DedupeKeys: tcase.keys}
+		if err := table.validate("trades", nil); !errors.Is(err, tcase.err) {
+			t.Fatalf("%s: expected table error %v, got %v", tcase.name, tcase.err, err)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// The policies for responses without records.
+const (
+	// OnEmptyWrite will write an empty response like any other. This is the default.
+	OnEmptyWrite = "write"
+
+	// OnEmptyMarker will write an empty response like any other, and record it in a "<table>_empty" side table so
+	// that empty chunks can be told apart from those that were never fetched.
+	OnEmptyMarker = "marker"
+
+	// OnEmptySkip will log a warning and not write an empty response, leaving its chunk to be fetched again by the
+	// next run.
+	OnEmptySkip = "skip"
+
+	// OnEmptyFail will fail the request, which is dead-lettered if "deadLetter" is set and fails the run otherwise.
+	OnEmptyFail = "fail"
+)
+
+// EmptyWindow is a period in which a request is expected to have empty responses, such as the weekends and holidays
+// of an exchange, so that the "onEmpty" policy does not apply to them.
+type EmptyWindow struct {
+	// Start is when the window begins, in RFC 3339. The default is the beginning of time.
+	Start string `yaml:"start"`
+
+	// End is when the window ends, in RFC 3339. The default is the end of time.
+	End string `yaml:"end"`
+
+	// Weekdays are the days of the week in UTC that the window is limited to, e.g. "saturday" and "sunday".
+	Weekdays []string `yaml:"weekdays"`
+}
+
+// parseWeekday returns the day of the week named by "name", e.g. "Saturday".
+func parseWeekday(name string) (time.Weekday, bool) {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.EqualFold(day.String(), name) {
+			return day, true
+		}
+	}
+
+	return 0, false
+}
+
+// bounds returns the start and end of the window, which are zero if they are not set.
+func (window *EmptyWindow) bounds() (time.Time, time.Time, error) {
+	var start, end time.Time
+
+	if window.Start != "" {
+		var err error
+		if start, err = time.Parse(time.RFC3339, window.Start); err != nil {
+			return start, end, fmt.Errorf("failed to parse start: %w", err)
+		}
+	}
+
+	if window.End != "" {
+		var err error
+		if end, err = time.Parse(time.RFC3339, window.End); err != nil {
+			return start, end, fmt.Errorf("failed to parse end: %w", err)
+		}
+	}
+
+	return start, end, nil
+}
+
+func (window *EmptyWindow) validate(endpoint string) error {
+	start, end, err := window.bounds()
+	if err != nil {
+		return fmt.Errorf("%w: expectedEmptyOk of %s: %v", ErrInvalidOnEmpty, endpoint, err)
+	}
+
+	if !start.IsZero() && !end.IsZero() && !start.Before(end) {
+		return fmt.Errorf("%w: expectedEmptyOk of %s must start before it ends", ErrInvalidOnEmpty, endpoint)
+	}
+
+	for _, name := range window.Weekdays {
+		if _, ok := parseWeekday(name); !ok {
+			return fmt.Errorf("%w: expectedEmptyOk of %s has an invalid weekday %q", ErrInvalidOnEmpty, endpoint,
+				name)
+		}
+	}
+
+	return nil
+}
+
+// Contains returns true if the period from "start" up to "end" is entirely within the window. A period that ends
+// when it starts is a single point in time.
+func (window *EmptyWindow) Contains(start, end time.Time) bool {
+	first, last, err := window.bounds()
+	if err != nil {
+		return false
+	}
+
+	if end.Before(start) {
+		end = start
+	}
+
+	if (!first.IsZero() && start.Before(first)) || (!last.IsZero() && end.After(last)) {
+		return false
+	}
+
+	if len(window.Weekdays) == 0 {
+		return true
+	}
+
+	days := make(map[time.Weekday]bool)
+
+	for _, name := range window.Weekdays {
+		day, _ := parseWeekday(name)
+		days[day] = true
+	}
+
+	// Every day of the period must be one of the weekdays.
+	for day := start.UTC(); ; day = day.Truncate(24 * time.Hour).Add(24 * time.Hour) {
+		if !days[day.Weekday()] {
+			return false
+		}
+
+		if !day.Truncate(24 * time.Hour).Add(24 * time.Hour).Before(end) {
+			return true
+		}
+	}
+}
+
+func (req *Request) validateOnEmpty() error {
+	switch req.OnEmpty {
+	case "", OnEmptyWrite, OnEmptyMarker, OnEmptySkip, OnEmptyFail:
+	default:
+		return fmt.Errorf("%w: onEmpty %q of %s must be one of %q, %q, %q or %q", ErrInvalidOnEmpty, req.OnEmpty,
+			req.Endpoint, OnEmptyWrite, OnEmptyMarker, OnEmptySkip, OnEmptyFail)
+	}
+
+	for _, window := range req.ExpectedEmptyOK {
+		if err := window.validate(req.Endpoint); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// EmptyWindows are the periods in which a request is expected to have empty responses.
+type EmptyWindows []*EmptyWindow
+
+// Contains returns true if the period from "start" up to "end" is entirely within one of the windows.
+func (windows EmptyWindows) Contains(start, end time.Time) bool {
+	for _, window := range windows {
+		if window.Contains(start, end) {
+			return true
+		}
+	}
+
+	return false
+}
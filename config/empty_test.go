@@ -0,0 +1,81 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestValidateOnEmpty(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name    string
+		onEmpty string
+		windows EmptyWindows
+		err     error
+	}{
+		{name: "default"},
+		{name: "fail", onEmpty: OnEmptyFail, windows: EmptyWindows{{Start: "2022-01-01T00:00:00Z"}}},
+		{name: "weekdays", onEmpty: OnEmptyMarker, windows: EmptyWindows{{Weekdays: []string{"Saturday", "sunday"}}}},
+		{name: "unknown policy", onEmpty: "retry", err: ErrInvalidOnEmpty},
+		{name: "invalid start", onEmpty: OnEmptySkip, windows: EmptyWindows{{Start: "2022-01-01"}}, err: ErrInvalidOnEmpty},
+		{
+			name:    "reversed",
+			onEmpty: OnEmptySkip,
+			windows: EmptyWindows{{Start: "2022-01-02T00:00:00Z", End: "2022-01-01T00:00:00Z"}},
+			err:     ErrInvalidOnEmpty,
+		},
+		{name: "invalid weekday", windows: EmptyWindows{{Weekdays: []string{"sat"}}}, err: ErrInvalidOnEmpty},
+	} {
+		req := &Request{Endpoint: "/trades", OnEmpty: tcase.onEmpty, ExpectedEmptyOK: tcase.windows}
+		if err := req.validate(); !errors.Is(err, tcase.err) {
+			t.Fatalf("%s: expected error %v, got %v", tcase.name, tcase.err, err)
+		}
+	}
+}
+
+func TestEmptyWindowsContains(t *testing.T) {
+	t.Parallel()
+
+	windows := EmptyWindows{
+		{Weekdays: []string{"saturday", "sunday"}},
+		{Start: "2022-12-26T00:00:00Z", End: "2022-12-27T00:00:00Z"},
+	}
+
+	saturday := time.Date(2022, 5, 14, 0, 0, 0, 0, time.UTC)
+
+	for _, tcase := range []struct {
+		name       string
+		start, end time.Time
+		want       bool
+	}{
+		{name: "weekend", start: saturday, end: saturday.Add(48 * time.Hour), want: true},
+		{name: "saturday afternoon", start: saturday.Add(12 * time.Hour), end: saturday.Add(13 * time.Hour), want: true},
+		{name: "into monday", start: saturday, end: saturday.Add(49 * time.Hour)},
+		{name: "friday", start: saturday.Add(-time.Hour), end: saturday.Add(time.Hour)},
+		{name: "point", start: saturday.Add(30 * time.Hour), end: saturday.Add(30 * time.Hour), want: true},
+		{
+			name:  "holiday",
+			start: time.Date(2022, 12, 26, 9, 0, 0, 0, time.UTC),
+			end:   time.Date(2022, 12, 26, 10, 0, 0, 0, time.UTC),
+			want:  true,
+		},
+		{
+			name:  "after holiday",
+			start: time.Date(2022, 12, 27, 9, 0, 0, 0, time.UTC),
+			end:   time.Date(2022, 12, 27, 10, 0, 0, 0, time.UTC),
+		},
+	} {
+		if got := windows.Contains(tcase.start, tcase.end); got != tcase.want {
+			t.Fatalf("%s: expected %t, got %t", tcase.name, tcase.want, got)
+		}
+	}
+}
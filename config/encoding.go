@@ -0,0 +1,60 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/alpstable/gidari/internal/web"
+)
+
+// EncodingIdentity is the content encoding of a response that is not compressed.
+const EncodingIdentity = "identity"
+
+// validateAcceptEncoding will check that every encoding of an "acceptEncoding" is one that responses can be decoded
+// from, with an optional quality, e.g. "gzip;q=0.5", between 0 and 1.
+func validateAcceptEncoding(field string, encodings []string) error {
+	known := append(append([]string(nil), web.Encodings...), EncodingIdentity)
+
+	for _, encoding := range encodings {
+		name, params, _ := strings.Cut(encoding, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+
+		supported := false
+		for _, enc := range known {
+			supported = supported || enc == name
+		}
+
+		if !supported {
+			return fmt.Errorf("%w: %s: encoding %q must be one of %s", ErrInvalidAcceptEncoding, field, encoding,
+				strings.Join(known, ", "))
+		}
+
+		if params == "" {
+			continue
+		}
+
+		params = strings.TrimSpace(params)
+
+		value, err := strconv.ParseFloat(strings.TrimPrefix(params, "q="), 64)
+		if !strings.HasPrefix(params, "q=") || err != nil || value < 0 || value > 1 {
+			return fmt.Errorf("%w: %s: the quality of %q must be \"q=\" followed by a number between 0 and 1",
+				ErrInvalidAcceptEncoding, field, encoding)
+		}
+	}
+
+	return nil
+}
+
+// AcceptEncodingHeader returns the "Accept-Encoding" header that the request advertises, or an empty string for the
+// transport to advertise "gzip".
+func (req *Request) AcceptEncodingHeader() string {
+	return strings.Join(req.AcceptEncoding, ", ")
+}
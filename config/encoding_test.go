@@ -0,0 +1,70 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestValidateAcceptEncoding(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name      string
+		encodings []string
+		err       error
+	}{
+		{name: "none"},
+		{name: "supported", encodings: []string{"br", "gzip", "zstd", "deflate", "identity"}},
+		{name: "case", encodings: []string{"BR", " Gzip "}},
+		{name: "quality", encodings: []string{"br", "gzip;q=0.5", "identity; q=0"}},
+		{name: "unsupported", encodings: []string{"compress"}, err: ErrInvalidAcceptEncoding},
+		{name: "quality range", encodings: []string{"gzip;q=2"}, err: ErrInvalidAcceptEncoding},
+		{name: "quality param", encodings: []string{"gzip;level=1"}, err: ErrInvalidAcceptEncoding},
+	} {
+		if err := validateAcceptEncoding("acceptEncoding", tcase.encodings); !errors.Is(err, tcase.err) {
+			t.Fatalf("%s: expected error %v, got %v", tcase.name, tcase.err, err)
+		}
+	}
+}
+
+func TestNewAppliesAcceptEncoding(t *testing.T) {
+	t.Parallel()
+
+	data := `
+version: 1
+url: https://example.com
+connectionStrings:
+  - mongodb://localhost:27017/db
+rateLimit:
+  burst: 1
+  period: 1
+acceptEncoding: [br, gzip;q=0.5]
+requests:
+  - endpoint: /trades
+  - endpoint: /candles
+    acceptEncoding: [zstd]
+`
+
+	cfg, err := New(context.Background(), newTestConfigFile(t, data))
+	if err != nil {
+		t.Fatalf("failed to create config: %v", err)
+	}
+
+	for idx, want := range []string{"br, gzip;q=0.5", "zstd"} {
+		if got := cfg.Requests[idx].AcceptEncodingHeader(); got != want {
+			t.Fatalf("%s: expected header %q, got %q", cfg.Requests[idx].Endpoint, want, got)
+		}
+	}
+
+	if got := (&Request{}).AcceptEncodingHeader(); got != "" {
+		t.Fatalf("expected no header, got %q", got)
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// EncryptionKey is an AES key that columns are encrypted with. It is read from exactly one of its sources.
+type EncryptionKey struct {
+	// Key is the base64 encoding of the 16, 24 or 32 bytes of the key.
+	Key string `yaml:"key"`
+
+	// KeyEnv is the environment variable that holds the base64 key, e.g. a data key that a KMS decrypts into the
+	// environment when gidari is deployed.
+	KeyEnv string `yaml:"keyEnv"`
+
+	// KeyFile is the file that holds the base64 key, e.g. a mounted secret.
+	KeyFile string `yaml:"keyFile"`
+}
+
+// Load returns the bytes of the key from its source.
+func (key *EncryptionKey) Load() ([]byte, error) {
+	var (
+		encoded string
+		sources int
+	)
+
+	if key.Key != "" {
+		encoded, sources = key.Key, sources+1
+	}
+
+	if key.KeyEnv != "" {
+		encoded, sources = os.Getenv(key.KeyEnv), sources+1
+		if encoded == "" {
+			return nil, fmt.Errorf("%w: environment variable %q is not set", ErrInvalidEncryption, key.KeyEnv)
+		}
+	}
+
+	if key.KeyFile != "" {
+		data, err := os.ReadFile(key.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to read keyFile: %v", ErrInvalidEncryption, err)
+		}
+
+		encoded, sources = string(data), sources+1
+	}
+
+	if sources != 1 {
+		return nil, fmt.Errorf("%w: exactly one of key, keyEnv and keyFile must be set", ErrInvalidEncryption)
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("%w: the key must be base64: %v", ErrInvalidEncryption, err)
+	}
+
+	switch len(decoded) {
+	case 16, 24, 32:
+		return decoded, nil
+	default:
+		return nil, fmt.Errorf("%w: the key must be 16, 24 or 32 bytes, not %d", ErrInvalidEncryption, len(decoded))
+	}
+}
+
+// validateEncryptionKeys will ensure that every key can be loaded, and that its ID can be written in the values that
+// it encrypts.
+func validateEncryptionKeys(keys map[string]*EncryptionKey) error {
+	for id, key := range keys {
+		if id == "" || strings.Contains(id, ":") {
+			return fmt.Errorf("%w: encryptionKeys %q must be an ID without colons", ErrInvalidEncryption, id)
+		}
+
+		if key == nil {
+			return fmt.Errorf("%w: encryptionKeys.%s must not be empty", ErrInvalidEncryption, id)
+		}
+
+		if _, err := key.Load(); err != nil {
+			return fmt.Errorf("encryptionKeys.%s: %w", id, err)
+		}
+	}
+
+	return nil
+}
+
+// Encrypt encrypts the values of columns with AES-GCM before they are written, so that they are never stored in
+// plaintext. Each value is written as "enc:v1:<keyID>:<base64 nonce and ciphertext>", whose plaintext is the JSON of
+// the value.
+type Encrypt struct {
+	// KeyID is the ID of the key of "encryptionKeys" that the columns are encrypted with.
+	KeyID string `yaml:"keyID"`
+
+	// Columns are the columns that are encrypted, once they are coerced to their types and masked.
+	Columns []string `yaml:"columns"`
+}
+
+// validate will ensure that the encryption names a key and columns, and that it does not encrypt the "plaintext"
+// columns, which could not be matched or read by storage once they are encrypted, or those that are masked.
+func (encrypt *Encrypt) validate(field string, plaintext []string, masks map[string]string) error {
+	if encrypt.KeyID == "" {
+		return fmt.Errorf("%w: %s.keyID is required", ErrInvalidEncryption, field)
+	}
+
+	if len(encrypt.Columns) == 0 {
+		return fmt.Errorf("%w: %s.columns is required", ErrInvalidEncryption, field)
+	}
+
+	readable := make(map[string]bool, len(plaintext))
+	for _, column := range plaintext {
+		readable[column] = true
+	}
+
+	seen := make(map[string]bool, len(encrypt.Columns))
+
+	for _, column := range encrypt.Columns {
+		switch {
+		case column == "":
+			return fmt.Errorf("%w: %s.columns must not be empty", ErrInvalidEncryption, field)
+		case seen[column]:
+			return fmt.Errorf("%w: %s.columns has %q more than once", ErrInvalidEncryption, field, column)
+		case readable[column]:
+			return fmt.Errorf("%w: %s.columns must not have %q, which must be written in plaintext",
+				ErrInvalidEncryption, field, column)
+		}
+
+		if _, ok := masks[column]; ok {
+			return fmt.Errorf("%w: %s.columns must not have %q, which is masked", ErrInvalidEncryption, field, column)
+		}
+
+		seen[column] = true
+	}
+
+	return nil
+}
+
+// plaintextColumns returns the columns of a request that cannot be encrypted: those that identify its records or their
+// parents, and the column that they expire after.
+func (req *Request) plaintextColumns() []string {
+	columns := append([]string{}, req.ConflictKeys...)
+	if req.OrderBy != "" {
+		columns = append(columns, req.OrderBy)
+	}
+
+	for _, child := range req.ChildTables {
+		if child != nil {
+			columns = append(columns, child.ParentKey)
+		}
+	}
+
+	if req.TTL != nil {
+		columns = append(columns, req.TTL.Column)
+	}
+
+	return columns
+}
@@ -0,0 +1,107 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testEncryptionKey is the base64 of a 32 byte key.
+const testEncryptionKey = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+
+//nolint:paralleltest // t.Setenv cannot be used by parallel tests
+func TestEncryptionKey(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(file, []byte(testEncryptionKey+"\n"), 0o600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+
+	t.Setenv("GIDARI_TEST_ENCRYPTION_KEY", testEncryptionKey)
+
+	for _, tcase := range []struct {
+		name string
+		key  *EncryptionKey
+		err  error
+	}{
+		{name: "key", key: &EncryptionKey{Key: testEncryptionKey}},
+		{name: "environment", key: &EncryptionKey{KeyEnv: "GIDARI_TEST_ENCRYPTION_KEY"}},
+		{name: "file", key: &EncryptionKey{KeyFile: file}},
+		{name: "no source", key: &EncryptionKey{}, err: ErrInvalidEncryption},
+		{name: "two sources", key: &EncryptionKey{Key: testEncryptionKey, KeyFile: file}, err: ErrInvalidEncryption},
+		{name: "unset environment", key: &EncryptionKey{KeyEnv: "GIDARI_TEST_UNSET"}, err: ErrInvalidEncryption},
+		{name: "not base64", key: &EncryptionKey{Key: "not a key"}, err: ErrInvalidEncryption},
+		{name: "wrong size", key: &EncryptionKey{Key: "MDEyMzQ1Njc="}, err: ErrInvalidEncryption},
+	} {
+		key, err := tcase.key.Load()
+		if !errors.Is(err, tcase.err) {
+			t.Fatalf("%s: expected error %v, got %v", tcase.name, tcase.err, err)
+		}
+
+		if tcase.err == nil && len(key) != 32 {
+			t.Fatalf("%s: expected a 32 byte key, got %d bytes", tcase.name, len(key))
+		}
+	}
+}
+
+func TestEncrypt(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name string
+		req  *Request
+		err  error
+	}{
+		{name: "encrypted", req: &Request{Encrypt: &Encrypt{KeyID: "2024", Columns: []string{"ssn"}}}},
+		{name: "no key", req: &Request{Encrypt: &Encrypt{Columns: []string{"ssn"}}}, err: ErrInvalidEncryption},
+		{name: "no columns", req: &Request{Encrypt: &Encrypt{KeyID: "2024"}}, err: ErrInvalidEncryption},
+		{
+			name: "conflict key",
+			req:  &Request{ConflictKeys: []string{"ssn"}, Encrypt: &Encrypt{KeyID: "2024", Columns: []string{"ssn"}}},
+			err:  ErrInvalidEncryption,
+		},
+		{
+			name: "masked",
+			req: &Request{
+				Masks:   map[string]string{"ssn": MaskNull},
+				Encrypt: &Encrypt{KeyID: "2024", Columns: []string{"ssn"}},
+			},
+			err: ErrInvalidEncryption,
+		},
+	} {
+		tcase.req.Endpoint = "/patients"
+		if err := tcase.req.validate(); !errors.Is(err, tcase.err) {
+			t.Fatalf("%s: expected error %v, got %v", tcase.name, tcase.err, err)
+		}
+	}
+
+	data := `
+version: 1
+url: https://example.com
+connectionStrings:
+  - mongodb://localhost:27017/db
+rateLimit:
+  burst: 1
+  period: 1
+encryptionKeys:
+  "2024":
+    key: ` + testEncryptionKey + `
+requests:
+  - endpoint: /patients
+    encrypt:
+      keyID: "2023"
+      columns: [ssn]
+`
+
+	if _, err := New(context.Background(), newTestConfigFile(t, data)); !errors.Is(err, ErrInvalidEncryption) {
+		t.Fatalf("expected an unknown key to be invalid, got %v", err)
+	}
+}
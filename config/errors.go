@@ -10,14 +10,76 @@ package config
 import "fmt"
 
 var (
-	ErrFetchingTimeseriesChunks = fmt.Errorf("failed to fetch timeseries chunks")
-	ErrInvalidRateLimit         = fmt.Errorf("invalid rate limit configuration")
-	ErrMissingConfigField       = fmt.Errorf("missing config field")
-	ErrMissingRateLimitField    = fmt.Errorf("missing rate limit field")
-	ErrMissingTimeseriesField   = fmt.Errorf("missing timeseries field")
-	ErrSettingTimeseriesChunks  = fmt.Errorf("failed to set timeseries chunks")
-	ErrUnableToParse            = fmt.Errorf("unable to parse")
-	ErrNoRequests               = fmt.Errorf("no requests defined")
+	ErrFetchingTimeseriesChunks  = fmt.Errorf("failed to fetch timeseries chunks")
+	ErrInvalidAPIVersion         = fmt.Errorf("invalid apiVersion configuration")
+	ErrInvalidAcceptEncoding     = fmt.Errorf("invalid acceptEncoding")
+	ErrInvalidAuthentication     = fmt.Errorf("invalid authentication")
+	ErrInvalidAutoCreate         = fmt.Errorf("invalid autoCreate configuration")
+	ErrInvalidAutoscale          = fmt.Errorf("invalid autoscale configuration")
+	ErrInvalidBodyTemplate       = fmt.Errorf("invalid bodyTemplate")
+	ErrInvalidBuilder            = fmt.Errorf("invalid config builder")
+	ErrInvalidCSV                = fmt.Errorf("invalid csv configuration")
+	ErrInvalidCanary             = fmt.Errorf("invalid canary configuration")
+	ErrInvalidCheckpoint         = fmt.Errorf("invalid checkpoint configuration")
+	ErrInvalidChildTables        = fmt.Errorf("invalid childTables configuration")
+	ErrInvalidChunkColumns       = fmt.Errorf("invalid timeseries chunk columns")
+	ErrInvalidConditional        = fmt.Errorf("invalid conditional configuration")
+	ErrInvalidDeadLetter         = fmt.Errorf("invalid dead-letter configuration")
+	ErrInvalidDeadline           = fmt.Errorf("invalid run deadline")
+	ErrInvalidDedupeKeys         = fmt.Errorf("invalid dedupeKeys")
+	ErrInvalidEncryption         = fmt.Errorf("invalid encryption configuration")
+	ErrInvalidExperimental       = fmt.Errorf("invalid experimental configuration")
+	ErrInvalidFields             = fmt.Errorf("invalid fields")
+	ErrInvalidFixtures           = fmt.Errorf("invalid fixtures configuration")
+	ErrInvalidFlatten            = fmt.Errorf("invalid flatten configuration")
+	ErrInvalidHooks              = fmt.Errorf("invalid hooks")
+	ErrInvalidLimits             = fmt.Errorf("invalid limits")
+	ErrInvalidMaintenance        = fmt.Errorf("invalid maintenance window")
+	ErrInvalidMasks              = fmt.Errorf("invalid masks")
+	ErrInvalidMethod             = fmt.Errorf("invalid method configuration")
+	ErrInvalidMetric             = fmt.Errorf("invalid metric")
+	ErrInvalidMigration          = fmt.Errorf("unable to migrate configuration")
+	ErrInvalidNumberLocale       = fmt.Errorf("invalid number locale")
+	ErrInvalidOnEmpty            = fmt.Errorf("invalid onEmpty")
+	ErrInvalidPartitions         = fmt.Errorf("invalid partitions")
+	ErrInvalidPricing            = fmt.Errorf("invalid pricing")
+	ErrInvalidProvenance         = fmt.Errorf("invalid provenance configuration")
+	ErrInvalidProvider           = fmt.Errorf("invalid provider")
+	ErrInvalidProxy              = fmt.Errorf("invalid proxy")
+	ErrInvalidQueryTemplate      = fmt.Errorf("invalid query template")
+	ErrInvalidRateLimit          = fmt.Errorf("invalid rate limit configuration")
+	ErrInvalidRecordsPath        = fmt.Errorf("invalid recordsPath")
+	ErrInvalidResponseCache      = fmt.Errorf("invalid responseCache configuration")
+	ErrInvalidResponseFormat     = fmt.Errorf("invalid response format")
+	ErrInvalidRunHooks           = fmt.Errorf("invalid runHooks")
+	ErrInvalidSchemaEvolution    = fmt.Errorf("invalid schema evolution mode")
+	ErrInvalidSink               = fmt.Errorf("invalid sink")
+	ErrInvalidStorageRateLimit   = fmt.Errorf("invalid storageRateLimit configuration")
+	ErrInvalidStreamBatchSize    = fmt.Errorf("invalid stream batch size")
+	ErrInvalidSurrogateKey       = fmt.Errorf("invalid surrogateKey configuration")
+	ErrInvalidTLS                = fmt.Errorf("invalid tls configuration")
+	ErrInvalidTTL                = fmt.Errorf("invalid ttl configuration")
+	ErrInvalidTable              = fmt.Errorf("invalid table configuration")
+	ErrInvalidTimeout            = fmt.Errorf("invalid request timeout")
+	ErrInvalidTimeseriesAlign    = fmt.Errorf("invalid timeseries alignment")
+	ErrInvalidTimeseriesPeriod   = fmt.Errorf("invalid timeseries period")
+	ErrInvalidTimeseriesPrefetch = fmt.Errorf("invalid timeseries prefetch")
+	ErrInvalidTimeseriesRange    = fmt.Errorf("invalid timeseries range")
+	ErrInvalidTimeseriesTarget   = fmt.Errorf("invalid timeseries target")
+	ErrInvalidTimeseriesTimezone = fmt.Errorf("invalid timeseries timezone")
+	ErrInvalidTransform          = fmt.Errorf("invalid transform")
+	ErrInvalidTypes              = fmt.Errorf("invalid types")
+	ErrInvalidWorkspaceRetain    = fmt.Errorf("invalid workspace retention policy")
+	ErrInvalidWriteMode          = fmt.Errorf("invalid write mode")
+	ErrInvalidXML                = fmt.Errorf("invalid xml configuration")
+	ErrMissingConfigField        = fmt.Errorf("missing config field")
+	ErrMissingRateLimitField     = fmt.Errorf("missing rate limit field")
+	ErrMissingTimeseriesField    = fmt.Errorf("missing timeseries field")
+	ErrSettingTimeseriesChunks   = fmt.Errorf("failed to set timeseries chunks")
+	ErrTableCollision            = fmt.Errorf("table collision")
+	ErrUnsupportedVersion        = fmt.Errorf("unsupported configuration version")
+	ErrUnableToParse             = fmt.Errorf("unable to parse")
+	ErrNoRequests                = fmt.Errorf("no requests defined")
 )
 
 // MissingConfigFieldError is returned when a configuration field is missing.
@@ -0,0 +1,72 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import "fmt"
+
+const (
+	// SchemaEvolutionIgnore will drop the fields of records that their table does not have a column for. This is
+	// the default.
+	SchemaEvolutionIgnore = "ignore"
+
+	// SchemaEvolutionEvolve will add a column to the table for every field of the records that it does not have,
+	// with the type inferred from the values of the field, before the records are written.
+	SchemaEvolutionEvolve = "evolve"
+
+	// SchemaEvolutionStrict will fail the write of records with fields that their table does not have a column
+	// for.
+	SchemaEvolutionStrict = "strict"
+)
+
+// SchemaEvolution is how the table of a request is changed when its records have fields that the table does not have
+// a column for, resolved from the "schemaEvolution", "typeMapping" and table settings of the configuration.
+type SchemaEvolution struct {
+	// Strict will fail the write rather than add the columns.
+	Strict bool
+
+	// ColumnTypes are the column types of individual columns, keyed by column name, which take precedence over
+	// the type mapping.
+	ColumnTypes map[string]string
+
+	// TypeMapping are the column types of the kinds of values inferred for the columns, keyed by storage scheme
+	// and then by kind.
+	TypeMapping map[string]map[string]string
+}
+
+// validateSchemaEvolution will ensure that "mode" is one of the schema evolution modes.
+func validateSchemaEvolution(field, mode string) error {
+	switch mode {
+	case "", SchemaEvolutionIgnore, SchemaEvolutionEvolve, SchemaEvolutionStrict:
+		return nil
+	default:
+		return fmt.Errorf("%w: %s %q must be one of %q, %q or %q", ErrInvalidSchemaEvolution, field, mode,
+			SchemaEvolutionIgnore, SchemaEvolutionEvolve, SchemaEvolutionStrict)
+	}
+}
+
+// newSchemaEvolution returns how the table of a request is changed for new fields, or nil if the fields that the
+// table does not have are dropped. The mode of the table settings takes precedence.
+func (cfg *Config) newSchemaEvolution(req *Request) *SchemaEvolution {
+	mode := cfg.SchemaEvolution
+
+	table, ok := cfg.Tables[req.Table]
+	if ok && table.SchemaEvolution != "" {
+		mode = table.SchemaEvolution
+	}
+
+	if mode != SchemaEvolutionEvolve && mode != SchemaEvolutionStrict {
+		return nil
+	}
+
+	evolve := &SchemaEvolution{Strict: mode == SchemaEvolutionStrict, TypeMapping: cfg.TypeMapping}
+	if ok {
+		evolve.ColumnTypes = table.ColumnTypes
+	}
+
+	return evolve
+}
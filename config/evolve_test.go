@@ -0,0 +1,81 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestNewAppliesSchemaEvolution(t *testing.T) {
+	t.Parallel()
+
+	data := `
+version: 1
+url: https://example.com
+connectionStrings:
+  - postgresql://localhost:5432/db
+rateLimit:
+  burst: 1
+  period: 1
+schemaEvolution: evolve
+typeMapping:
+  postgresql:
+    number: NUMERIC
+tables:
+  trades:
+    columnTypes:
+      price: NUMERIC(18,8)
+  candles:
+    schemaEvolution: strict
+  quotes:
+    schemaEvolution: ignore
+requests:
+  - endpoint: /trades
+  - endpoint: /candles
+  - endpoint: /quotes
+`
+
+	cfg, err := New(context.Background(), newTestConfigFile(t, data))
+	if err != nil {
+		t.Fatalf("failed to create config: %v", err)
+	}
+
+	want := &SchemaEvolution{
+		ColumnTypes: map[string]string{"price": "NUMERIC(18,8)"},
+		TypeMapping: map[string]map[string]string{"postgresql": {"number": "NUMERIC"}},
+	}
+
+	if got := cfg.Requests[0].SchemaEvolution; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected the table settings to be applied, got %+v", got)
+	}
+
+	if got := cfg.Requests[1].SchemaEvolution; got == nil || !got.Strict {
+		t.Fatalf("expected the mode of the table to take precedence, got %+v", got)
+	}
+
+	if got := cfg.Requests[2].SchemaEvolution; got != nil {
+		t.Fatalf("expected the fields of an ignored table to be dropped, got %+v", got)
+	}
+}
+
+func TestValidateSchemaEvolution(t *testing.T) {
+	t.Parallel()
+
+	for _, mode := range []string{"", SchemaEvolutionIgnore, SchemaEvolutionEvolve, SchemaEvolutionStrict} {
+		if err := validateSchemaEvolution("schemaEvolution", mode); err != nil {
+			t.Fatalf("expected %q to be valid, got %v", mode, err)
+		}
+	}
+
+	if err := validateSchemaEvolution("schemaEvolution", "add"); !errors.Is(err, ErrInvalidSchemaEvolution) {
+		t.Fatalf("expected an error for an unknown mode, got %v", err)
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alpstable/gidari/internal/provider"
+	"gopkg.in/yaml.v2"
+)
+
+// Example returns a runnable sample configuration of the web API of a provider profile, e.g. "coinbase", with the
+// rate limit of the profile, a stub of its credentials and a few of its endpoints, written to the SQLite database
+// file at "database". The timeseries requests of the sample end at the hour of "now".
+func Example(name, database string, now time.Time) ([]byte, error) {
+	profile, ok := provider.Lookup(name)
+	if !ok || len(profile.Examples) == 0 {
+		return nil, fmt.Errorf("%w: %q must be one of %s", ErrInvalidProvider, name,
+			strings.Join(provider.Names(), ", "))
+	}
+
+	end := now.UTC().Truncate(time.Hour)
+
+	requests := make([]yaml.MapSlice, 0, len(profile.Examples))
+	tables := yaml.MapSlice{}
+
+	for _, example := range profile.Examples {
+		req := yaml.MapSlice{{Key: "endpoint", Value: example.Endpoint}, {Key: "table", Value: example.Table}}
+
+		query := exampleQuery(example.Query)
+
+		if example.Period > 0 {
+			startName, endName := example.StartName, example.EndName
+			if startName == "" {
+				startName, endName = profile.Timeseries.StartName, profile.Timeseries.EndName
+			}
+
+			layout := profile.Timeseries.Layout
+			ts := &Timeseries{Layout: &layout}
+
+			query = append(query,
+				yaml.MapItem{Key: startName, Value: ts.FormatTime(end.Add(-example.Range))},
+				yaml.MapItem{Key: endName, Value: ts.FormatTime(end)})
+
+			timeseries := yaml.MapSlice{
+				{Key: "startName", Value: startName},
+				{Key: "endName", Value: endName},
+				{Key: "period", Value: examplePeriod(example.Period)},
+			}
+			if layout != "" {
+				timeseries = append(timeseries, yaml.MapItem{Key: "layout", Value: layout})
+			}
+
+			req = append(req, yaml.MapItem{Key: "timeseries", Value: timeseries})
+		}
+
+		if len(query) != 0 {
+			req = append(req, yaml.MapItem{Key: "query", Value: query})
+		}
+
+		if example.RecordsPath != "" {
+			req = append(req, yaml.MapItem{Key: "recordsPath", Value: example.RecordsPath})
+		}
+
+		if len(example.PrimaryKeys) != 0 {
+			tables = append(tables, yaml.MapItem{
+				Key:   example.Table,
+				Value: yaml.MapSlice{{Key: "primaryKeys", Value: example.PrimaryKeys}},
+			})
+		}
+
+		requests = append(requests, req)
+	}
+
+	cfg := yaml.MapSlice{
+		{Key: "version", Value: CurrentVersion},
+		{Key: "provider", Value: profile.Name},
+		{Key: "url", Value: profile.URL},
+		{Key: "rateLimit", Value: yaml.MapSlice{
+			{Key: "burst", Value: profile.Burst},
+			{Key: "period", Value: profile.Period.String()},
+		}},
+	}
+
+	head, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the example configuration: %w", err)
+	}
+
+	auth, err := yaml.Marshal(yaml.MapSlice{{Key: "authentication", Value: exampleAuthentication(profile)}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the example configuration: %w", err)
+	}
+
+	body, err := yaml.Marshal(yaml.MapSlice{
+		{Key: "connectionStrings", Value: []string{"sqlite://" + database}},
+		{Key: "autoCreate", Value: true},
+		{Key: "tables", Value: tables},
+		{Key: "requests", Value: requests},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the example configuration: %w", err)
+	}
+
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "# Sample configuration of the %s web API, generated by \"gidari example %s\".\n",
+		profile.Name, profile.Name)
+	fmt.Fprintf(&buf, "# Run it with \"gidari --config <file>\" to write its records to %s.\n", database)
+
+	buf.Write(head)
+
+	if profile.Public {
+		buf.WriteString("# The endpoints below are public. Uncomment the authentication, with your credentials, for\n")
+		buf.WriteString("# the private endpoints of the web API.\n")
+
+		for _, line := range strings.SplitAfter(strings.TrimSuffix(string(auth), "\n"), "\n") {
+			buf.WriteString("# " + line)
+		}
+
+		buf.WriteString("\n")
+	} else {
+		buf.WriteString("# Replace the credentials with your own.\n")
+		buf.Write(auth)
+	}
+
+	buf.Write(body)
+
+	return buf.Bytes(), nil
+}
+
+// exampleAuthentication returns the stub of the credentials that a provider profile authenticates with.
+func exampleAuthentication(profile *provider.Profile) yaml.MapSlice {
+	switch {
+	case profile.Auth.Signed:
+		return yaml.MapSlice{{Key: "apiKey", Value: yaml.MapSlice{
+			{Key: "key", Value: "<key>"},
+			{Key: "secret", Value: "<secret>"},
+			{Key: "passphrase", Value: "<passphrase>"},
+		}}}
+	case profile.Auth.KeyHeader != "":
+		key := yaml.MapSlice{{Key: "key", Value: "<key>"}}
+		if profile.Auth.SecretHeader != "" {
+			key = append(key, yaml.MapItem{Key: "secret", Value: "<secret>"})
+		}
+
+		return yaml.MapSlice{{Key: "apiKey", Value: key}}
+	default:
+		return yaml.MapSlice{{Key: "auth2", Value: yaml.MapSlice{{Key: "bearer", Value: "<token>"}}}}
+	}
+}
+
+// exampleQuery returns the query parameters of an example request, sorted so that the sample is stable.
+func exampleQuery(query map[string]string) yaml.MapSlice {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	items := make(yaml.MapSlice, 0, len(names))
+	for _, name := range names {
+		items = append(items, yaml.MapItem{Key: name, Value: query[name]})
+	}
+
+	return items
+}
+
+// examplePeriod returns a timeseries period in the largest unit that it is a whole number of, e.g. "1w".
+func examplePeriod(period time.Duration) string {
+	for _, unit := range []byte{'w', 'd', 'h', 'm'} {
+		size := time.Duration(periodUnits[unit]) * time.Second
+		if period%size == 0 {
+			return fmt.Sprintf("%d%c", period/size, unit)
+		}
+	}
+
+	return fmt.Sprintf("%d", int64(period/time.Second))
+}
@@ -0,0 +1,96 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alpstable/gidari/internal/provider"
+)
+
+func TestExample(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2022, 10, 14, 15, 4, 5, 0, time.UTC)
+
+	for _, name := range provider.Names() {
+		data, err := Example(name, name+".db", now)
+		if err != nil {
+			t.Fatalf("%s: failed to generate example: %v", name, err)
+		}
+
+		// Every sample has to be a valid configuration as it is written.
+		cfg, err := New(context.Background(), newTestConfigFile(t, string(data)))
+		if err != nil {
+			t.Fatalf("%s: invalid example: %v\n%s", name, err, data)
+		}
+
+		profile, _ := provider.Lookup(name)
+
+		if cfg.ConnectionStrings[0] != "sqlite://"+name+".db" || !cfg.AutoCreate {
+			t.Fatalf("%s: expected an auto-created SQLite target, got %v", name, cfg.ConnectionStrings)
+		}
+
+		if len(cfg.Requests) != len(profile.Examples) {
+			t.Fatalf("%s: expected %d requests, got %d", name, len(profile.Examples), len(cfg.Requests))
+		}
+
+		for idx, req := range cfg.Requests {
+			example := profile.Examples[idx]
+
+			if (req.Timeseries != nil) != (example.Period > 0) {
+				t.Fatalf("%s: expected %s to be a timeseries: %t", name, req.Endpoint, example.Period > 0)
+			}
+
+			if req.Timeseries == nil {
+				continue
+			}
+
+			end, err := req.Timeseries.ParseTime(req.Query[req.Timeseries.EndName])
+			if err != nil {
+				t.Fatalf("%s: failed to parse the end of %s: %v", name, req.Endpoint, err)
+			}
+
+			if want := now.Truncate(time.Hour); !end.Equal(want) {
+				t.Fatalf("%s: expected %s to end at %s, got %s", name, req.Endpoint, want, end)
+			}
+		}
+
+		// The credentials of public endpoints are commented out.
+		if public := !strings.Contains(string(data), "\nauthentication:"); public != profile.Public {
+			t.Fatalf("%s: expected public %t, got %t", name, profile.Public, public)
+		}
+	}
+
+	if _, err := Example("acme", "acme.db", now); !errors.Is(err, ErrInvalidProvider) {
+		t.Fatalf("expected error %v, got %v", ErrInvalidProvider, err)
+	}
+}
+
+func TestExamplePeriod(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		period time.Duration
+		want   string
+	}{
+		{period: 14 * 24 * time.Hour, want: "2w"},
+		{period: 3 * 24 * time.Hour, want: "3d"},
+		{period: time.Hour, want: "1h"},
+		{period: 90 * time.Minute, want: "90m"},
+		{period: 90 * time.Second, want: "90"},
+	} {
+		if got := examplePeriod(tcase.period); got != tcase.want {
+			t.Fatalf("%s: expected %q, got %q", tcase.period, tcase.want, got)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ExperimentAutoscale opts in to "autoscale", which grows and shrinks the web workers of each host. Enabling it
+// autoscales with the defaults if there is no "autoscale" configuration.
+const ExperimentAutoscale = "autoscale"
+
+// experiments are the names of the behaviors that can be enabled in "experimental".
+var experiments = map[string]bool{
+	ExperimentAutoscale: true,
+}
+
+// Experimental are the experimental behaviors that a configuration opts in to, by name, e.g. "autoscale: true".
+// Experimental behaviors may change or be removed between releases, so they only apply to the ingestions whose
+// configuration enables them.
+type Experimental map[string]bool
+
+// Enabled returns true if the experimental behavior is enabled.
+func (experimental Experimental) Enabled(name string) bool {
+	return experimental[name]
+}
+
+func (experimental Experimental) validate() error {
+	for name := range experimental {
+		if experiments[name] {
+			continue
+		}
+
+		known := make([]string, 0, len(experiments))
+		for name := range experiments {
+			known = append(known, name)
+		}
+
+		sort.Strings(known)
+
+		return fmt.Errorf("%w: unknown experiment %q, expected one of %s", ErrInvalidExperimental, name,
+			strings.Join(known, ", "))
+	}
+
+	return nil
+}
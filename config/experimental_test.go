@@ -0,0 +1,72 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestValidateExperimental(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name         string
+		experimental Experimental
+		autoscale    *AutoscaleConfig
+		err          error
+	}{
+		{name: "none"},
+		{name: "autoscale", experimental: Experimental{ExperimentAutoscale: true}, autoscale: &AutoscaleConfig{}},
+		{name: "flag only", experimental: Experimental{ExperimentAutoscale: true}},
+		{name: "disabled", experimental: Experimental{ExperimentAutoscale: false}},
+		{name: "unknown", experimental: Experimental{"hedging": true}, err: ErrInvalidExperimental},
+		{name: "not enabled", autoscale: &AutoscaleConfig{}, err: ErrInvalidAutoscale},
+		{
+			name:         "disabled autoscale",
+			experimental: Experimental{ExperimentAutoscale: false},
+			autoscale:    &AutoscaleConfig{},
+			err:          ErrInvalidAutoscale,
+		},
+	} {
+		cfg := &Config{
+			RateLimitConfig:   &RateLimitConfig{Burst: new(int), Period: new(time.Duration)},
+			ConnectionStrings: []string{"mongodb://localhost"},
+			Requests:          []*Request{{Endpoint: "/trades"}},
+			Autoscale:         tcase.autoscale,
+			Experimental:      tcase.experimental,
+		}
+
+		if err := cfg.Validate(); !errors.Is(err, tcase.err) {
+			t.Fatalf("%s: expected error %v, got %v", tcase.name, tcase.err, err)
+		}
+	}
+
+	var experimental Experimental
+	if experimental.Enabled(ExperimentAutoscale) {
+		t.Fatalf("expected experiments to be disabled by default")
+	}
+}
+
+func TestNewExperimentalAutoscale(t *testing.T) {
+	t.Parallel()
+
+	data := "url: https://example.com\nrateLimit:\n  burst: 1\n  period: 1\nconnectionStrings: [mongodb://localhost]\n" +
+		"experimental:\n  autoscale: true\nrequests:\n  - endpoint: /trades\n"
+
+	cfg, err := New(context.Background(), newTestConfigFile(t, data))
+	if err != nil {
+		t.Fatalf("failed to create config: %v", err)
+	}
+
+	if cfg.Autoscale == nil {
+		t.Fatalf("expected the experiment to autoscale with the defaults")
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// FieldPath returns the keys of the nested value that a field of "fields" is mapped from, e.g. "quote.USD.price".
+func FieldPath(field string) []string {
+	return strings.Split(field, ".")
+}
+
+// validateFields will ensure that every field is mapped from a path without empty keys to a column, and that no two
+// fields are mapped to the same column.
+func validateFields(field string, fields map[string]string) error {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+
+	// Fields are checked in order so that the same misconfiguration always reports the same field.
+	sort.Strings(names)
+
+	columns := make(map[string]string, len(fields))
+
+	for _, name := range names {
+		for _, key := range FieldPath(name) {
+			if key == "" {
+				return fmt.Errorf("%w: %s.%s must not have an empty key", ErrInvalidFields, field, name)
+			}
+		}
+
+		column := fields[name]
+		if column == "" {
+			return fmt.Errorf("%w: %s.%s must have a column", ErrInvalidFields, field, name)
+		}
+
+		if other, ok := columns[column]; ok {
+			return fmt.Errorf("%w: %s.%s and %s.%s are both mapped to %q", ErrInvalidFields, field, other, field,
+				name, column)
+		}
+
+		columns[column] = name
+	}
+
+	return nil
+}
+
+// validateFieldFilters will ensure that the columns of "includeFields" and "excludeFields" are not empty, and that at
+// most one of them is set.
+func validateFieldFilters(field string, include, exclude []string) error {
+	if len(include) != 0 && len(exclude) != 0 {
+		return fmt.Errorf("%w: %s cannot set both includeFields and excludeFields", ErrInvalidFields, field)
+	}
+
+	for name, columns := range map[string][]string{"includeFields": include, "excludeFields": exclude} {
+		for idx, column := range columns {
+			if column == "" {
+				return fmt.Errorf("%w: %s %s[%d] must not be empty", ErrInvalidFields, field, name, idx)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,80 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRequestFields(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name   string
+		fields map[string]string
+		err    error
+	}{
+		{name: "no fields"},
+		{name: "fields", fields: map[string]string{"priceUsd": "price_usd", "quote.USD.price": "price"}},
+		{name: "empty key", fields: map[string]string{"quote..price": "price"}, err: ErrInvalidFields},
+		{name: "no column", fields: map[string]string{"priceUsd": ""}, err: ErrInvalidFields},
+		{name: "same column", fields: map[string]string{"a": "price", "b": "price"}, err: ErrInvalidFields},
+	} {
+		req := &Request{Endpoint: "/prices", Fields: tcase.fields}
+		if err := req.validate(); !errors.Is(err, tcase.err) {
+			t.Fatalf("%s: expected error %v, got %v", tcase.name, tcase.err, err)
+		}
+	}
+
+	table := &Table{Fields: map[string]string{"priceUsd": "price_usd"}}
+
+	req := &Request{Fields: map[string]string{"price": "price"}}
+	if table.apply(req); req.Fields["price"] != "price" {
+		t.Fatalf("expected the fields of the request to take precedence, got %v", req.Fields)
+	}
+
+	req = &Request{}
+	if table.apply(req); req.Fields["priceUsd"] != "price_usd" {
+		t.Fatalf("expected the fields of the table, got %v", req.Fields)
+	}
+}
+
+func TestRequestFieldFilters(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name    string
+		include []string
+		exclude []string
+		err     error
+	}{
+		{name: "no filters"},
+		{name: "include", include: []string{"id", "price"}},
+		{name: "exclude", exclude: []string{"_links"}},
+		{name: "both", include: []string{"id"}, exclude: []string{"_links"}, err: ErrInvalidFields},
+		{name: "empty column", exclude: []string{"_links", ""}, err: ErrInvalidFields},
+	} {
+		req := &Request{Endpoint: "/prices", IncludeFields: tcase.include, ExcludeFields: tcase.exclude}
+		if err := req.validate(); !errors.Is(err, tcase.err) {
+			t.Fatalf("%s: expected error %v, got %v", tcase.name, tcase.err, err)
+		}
+	}
+
+	table := &Table{ExcludeFields: []string{"_links"}}
+
+	req := &Request{IncludeFields: []string{"id"}}
+	if table.apply(req); req.ExcludeFields != nil {
+		t.Fatalf("expected a request that includes fields not to exclude those of the table, got %v", req.ExcludeFields)
+	}
+
+	req = &Request{}
+	if table.apply(req); len(req.ExcludeFields) != 1 {
+		t.Fatalf("expected the excluded fields of the table, got %v", req.ExcludeFields)
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import "fmt"
+
+// FixturesConfig configures how the responses captured by "gidari fixtures" are scrubbed of personal data. The
+// credentials of the "authentication" are scrubbed from every fixture whether or not it is set.
+type FixturesConfig struct {
+	// Scrub are the keys of the response bodies whose values are replaced, at any depth, e.g. "email".
+	Scrub []string `yaml:"scrub"`
+
+	// ScrubQuery are the query parameters of the requests whose values are replaced, e.g. "apiKey".
+	ScrubQuery []string `yaml:"scrubQuery"`
+}
+
+func (fixtures *FixturesConfig) validate() error {
+	for idx, key := range fixtures.Scrub {
+		if key == "" {
+			return fmt.Errorf("%w: scrub[%d] must not be empty", ErrInvalidFixtures, idx)
+		}
+	}
+
+	for idx, param := range fixtures.ScrubQuery {
+		if param == "" {
+			return fmt.Errorf("%w: scrubQuery[%d] must not be empty", ErrInvalidFixtures, idx)
+		}
+	}
+
+	return nil
+}
+
+// Secrets returns the credentials of the authentication, which are never written to a fixture.
+func (auth Authentication) Secrets() []string {
+	var secrets []string
+
+	if auth.APIKey != nil {
+		secrets = append(secrets, auth.APIKey.Key, auth.APIKey.Secret, auth.APIKey.Passphrase)
+	}
+
+	if auth.Auth2 != nil {
+		secrets = append(secrets, auth.Auth2.Bearer, auth.Auth2.ClientID, auth.Auth2.ClientSecret,
+			auth.Auth2.RefreshToken)
+	}
+
+	// The settings of a custom scheme are not known to be credentials or not, so none of them are written.
+	if auth.Custom != nil {
+		for _, val := range auth.Custom.Settings {
+			secrets = append(secrets, val)
+		}
+	}
+
+	nonEmpty := secrets[:0]
+
+	for _, secret := range secrets {
+		if secret != "" {
+			nonEmpty = append(nonEmpty, secret)
+		}
+	}
+
+	return nonEmpty
+}
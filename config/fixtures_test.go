@@ -0,0 +1,43 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestFixturesConfig(t *testing.T) {
+	t.Parallel()
+
+	if err := (&FixturesConfig{Scrub: []string{"email"}, ScrubQuery: []string{"apiKey"}}).validate(); err != nil {
+		t.Fatalf("expected a valid fixtures configuration, got %v", err)
+	}
+
+	if err := (&FixturesConfig{Scrub: []string{"email", ""}}).validate(); !errors.Is(err, ErrInvalidFixtures) {
+		t.Fatalf("expected %v, got %v", ErrInvalidFixtures, err)
+	}
+
+	if err := (&FixturesConfig{ScrubQuery: []string{""}}).validate(); !errors.Is(err, ErrInvalidFixtures) {
+		t.Fatalf("expected %v, got %v", ErrInvalidFixtures, err)
+	}
+
+	auth := Authentication{
+		APIKey: &APIKey{Key: "key", Secret: "secret"},
+		Auth2:  &Auth2{Bearer: "bearer", ClientSecret: "client"},
+	}
+
+	if secrets := auth.Secrets(); !reflect.DeepEqual(secrets, []string{"key", "secret", "bearer", "client"}) {
+		t.Fatalf("expected the credentials that are set, got %v", secrets)
+	}
+
+	if secrets := (Authentication{}).Secrets(); len(secrets) != 0 {
+		t.Fatalf("expected no credentials, got %v", secrets)
+	}
+}
@@ -0,0 +1,33 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import "fmt"
+
+// DefaultFlattenDelimiter is the delimiter of the column names of flattened objects, unless "delimiter" is set.
+const DefaultFlattenDelimiter = "_"
+
+// Flatten is how the nested objects of the records are flattened into columns, e.g. "user.address.city" into
+// "user_address_city", for tables that do not store objects. Lists are written as they are.
+type Flatten struct {
+	// Delimiter is put between the keys of a nested value in its column name, "_" by default, e.g. "." for
+	// "user.address.city".
+	Delimiter string `yaml:"delimiter"`
+
+	// MaxDepth is the number of levels of objects that are flattened, with the objects nested deeper than that
+	// written as they are. The default is every level.
+	MaxDepth int `yaml:"maxDepth"`
+}
+
+func (flatten *Flatten) validate(field string) error {
+	if flatten.MaxDepth < 0 {
+		return fmt.Errorf("%w: %s.maxDepth must not be negative", ErrInvalidFlatten, field)
+	}
+
+	return nil
+}
@@ -0,0 +1,45 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRequestFlatten(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name    string
+		flatten *Flatten
+		err     error
+	}{
+		{name: "not flattened"},
+		{name: "default", flatten: &Flatten{}},
+		{name: "delimiter and depth", flatten: &Flatten{Delimiter: ".", MaxDepth: 2}},
+		{name: "negative depth", flatten: &Flatten{MaxDepth: -1}, err: ErrInvalidFlatten},
+	} {
+		req := &Request{Endpoint: "/users", Flatten: tcase.flatten}
+		if err := req.validate(); !errors.Is(err, tcase.err) {
+			t.Fatalf("%s: expected error %v, got %v", tcase.name, tcase.err, err)
+		}
+	}
+
+	table := &Table{Flatten: &Flatten{Delimiter: "."}}
+
+	req := &Request{Flatten: &Flatten{MaxDepth: 1}}
+	if table.apply(req); req.Flatten.Delimiter != "" {
+		t.Fatalf("expected the flatten of the request to take precedence, got %+v", req.Flatten)
+	}
+
+	req = &Request{}
+	if table.apply(req); req.Flatten == nil || req.Flatten.Delimiter != "." {
+		t.Fatalf("expected the flatten of the table, got %+v", req.Flatten)
+	}
+}
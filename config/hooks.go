@@ -0,0 +1,64 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/alpstable/gidari/internal/hooks"
+)
+
+// validateHooks will ensure that every transform hook is registered, and is only run once.
+func validateHooks(field string, names []string) error {
+	seen := make(map[string]bool, len(names))
+
+	for _, name := range names {
+		if seen[name] {
+			return fmt.Errorf("%w: %s lists %q more than once", ErrInvalidHooks, field, name)
+		}
+
+		seen[name] = true
+
+		if !hooks.Registered(name) {
+			return fmt.Errorf("%w: %s: transform hook %q is not registered, registered hooks: [%s]",
+				ErrInvalidHooks, field, name, strings.Join(hooks.Names(), ", "))
+		}
+	}
+
+	return nil
+}
+
+// validateRunHooks will ensure that every run hook is registered, is only run once, and has a state store to keep
+// its variables in.
+func (cfg *Config) validateRunHooks() error {
+	if len(cfg.RunHooks) == 0 {
+		return nil
+	}
+
+	if cfg.State == nil || cfg.State.File == "" {
+		return fmt.Errorf("%w: run hooks need a state.file to store their variables", ErrInvalidRunHooks)
+	}
+
+	seen := make(map[string]bool, len(cfg.RunHooks))
+
+	for _, name := range cfg.RunHooks {
+		if seen[name] {
+			return fmt.Errorf("%w: %q is listed more than once", ErrInvalidRunHooks, name)
+		}
+
+		seen[name] = true
+
+		if !hooks.RegisteredRun(name) {
+			return fmt.Errorf("%w: run hook %q is not registered, registered run hooks: [%s]",
+				ErrInvalidRunHooks, name, strings.Join(hooks.RunNames(), ", "))
+		}
+	}
+
+	return nil
+}
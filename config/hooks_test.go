@@ -0,0 +1,85 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alpstable/gidari/internal/hooks"
+)
+
+func TestValidateHooks(t *testing.T) {
+	t.Parallel()
+
+	err := hooks.Register("config-test", func(_ context.Context, _ string,
+		record map[string]interface{},
+	) (map[string]interface{}, error) {
+		return record, nil
+	})
+	if err != nil {
+		t.Fatalf("failed to register hook: %v", err)
+	}
+
+	t.Cleanup(func() { hooks.Unregister("config-test") })
+
+	for _, tcase := range []struct {
+		name  string
+		hooks []string
+		err   error
+	}{
+		{name: "none"},
+		{name: "registered", hooks: []string{"config-test"}},
+		{name: "unregistered", hooks: []string{"config-test", "missing"}, err: ErrInvalidHooks},
+		{name: "duplicate", hooks: []string{"config-test", "config-test"}, err: ErrInvalidHooks},
+	} {
+		if err := validateHooks("hooks", tcase.hooks); !errors.Is(err, tcase.err) {
+			t.Fatalf("%s: expected error %v, got %v", tcase.name, tcase.err, err)
+		}
+	}
+}
+
+func TestValidateRunHooks(t *testing.T) {
+	t.Parallel()
+
+	err := hooks.RegisterRun("config-test", func(_ context.Context, _ hooks.Variables) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to register run hook: %v", err)
+	}
+
+	t.Cleanup(func() { hooks.UnregisterRun("config-test") })
+
+	state := &StateConfig{File: "state.json"}
+
+	for _, tcase := range []struct {
+		name string
+		cfg  *Config
+		err  error
+	}{
+		{name: "none", cfg: &Config{}},
+		{name: "registered", cfg: &Config{State: state, RunHooks: []string{"config-test"}}},
+		{name: "no state", cfg: &Config{RunHooks: []string{"config-test"}}, err: ErrInvalidRunHooks},
+		{
+			name: "unregistered",
+			cfg:  &Config{State: state, RunHooks: []string{"config-test", "missing"}},
+			err:  ErrInvalidRunHooks,
+		},
+		{
+			name: "duplicate",
+			cfg:  &Config{State: state, RunHooks: []string{"config-test", "config-test"}},
+			err:  ErrInvalidRunHooks,
+		},
+	} {
+		if err := tcase.cfg.validateRunHooks(); !errors.Is(err, tcase.err) {
+			t.Fatalf("%s: expected error %v, got %v", tcase.name, tcase.err, err)
+		}
+	}
+}
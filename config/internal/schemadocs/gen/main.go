@@ -0,0 +1,28 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/alpstable/gidari/config/internal/schemadocs"
+)
+
+// main will write the "schema_docs.go" of the configuration package in the working directory, which "go generate"
+// runs in.
+func main() {
+	src, err := schemadocs.Generate(".")
+	if err != nil {
+		log.Fatalf("failed to generate schema docs: %v", err)
+	}
+
+	if err := os.WriteFile("schema_docs.go", src, 0o644); err != nil {
+		log.Fatalf("failed to write schema docs: %v", err)
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package schemadocs
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"sort"
+	"strings"
+)
+
+// header is the start of the generated file.
+const header = `// Code generated by "go generate"; DO NOT EDIT.
+
+package %s
+
+// schemaDocs are the doc comments of the configuration types, keyed by type name, and of their fields, keyed by
+// "Type.Field", which describe the JSON Schema of the configuration.
+var schemaDocs = map[string]string{
+`
+
+// Generate returns the Go source of the "schemaDocs" of the package in "dir", from the doc comments of its types and
+// struct fields. Test files are left out.
+func Generate(dir string) ([]byte, error) {
+	fset := token.NewFileSet()
+
+	pkgs, err := parser.ParseDir(fset, dir, func(info fs.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", dir, err)
+	}
+
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("expected one package in %s, got %d", dir, len(pkgs))
+	}
+
+	var name string
+
+	docs := make(map[string]string)
+
+	for pkgName, pkg := range pkgs {
+		name = pkgName
+
+		for _, file := range pkg.Files {
+			collect(docs, file)
+		}
+	}
+
+	keys := make([]string, 0, len(docs))
+	for key := range docs {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, header, name)
+
+	for _, key := range keys {
+		fmt.Fprintf(&buf, "\t%q: %q,\n", key, docs[key])
+	}
+
+	buf.WriteString("}\n")
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to format schema docs: %w", err)
+	}
+
+	return src, nil
+}
+
+// collect will add the doc comments of the exported types of a file, and of the exported fields of its structs, to
+// "docs".
+func collect(docs map[string]string, file *ast.File) {
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+
+		for _, spec := range gen.Specs {
+			typeSpec, ok := spec.(*ast.TypeSpec)
+			if !ok || !typeSpec.Name.IsExported() {
+				continue
+			}
+
+			doc := typeSpec.Doc
+			if doc == nil && len(gen.Specs) == 1 {
+				doc = gen.Doc
+			}
+
+			if text := docText(doc); text != "" {
+				docs[typeSpec.Name.Name] = text
+			}
+
+			structType, ok := typeSpec.Type.(*ast.StructType)
+			if !ok {
+				continue
+			}
+
+			for _, field := range structType.Fields.List {
+				text := docText(field.Doc)
+				if text == "" {
+					continue
+				}
+
+				for _, fieldName := range field.Names {
+					if fieldName.IsExported() {
+						docs[typeSpec.Name.Name+"."+fieldName.Name] = text
+					}
+				}
+			}
+		}
+	}
+}
+
+// docText returns the text of a doc comment on one line.
+func docText(doc *ast.CommentGroup) string {
+	if doc == nil {
+		return ""
+	}
+
+	return strings.Join(strings.Fields(doc.Text()), " ")
+}
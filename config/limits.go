@@ -0,0 +1,31 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import "fmt"
+
+// Limits is the budget for a single run. Once a limit is reached, no new requests are started, the data that has
+// been fetched is committed, and the run is aborted with a summary of what it used. Zero values are unlimited.
+type Limits struct {
+	// MaxRequests is the maximum number of HTTP requests made to the web API.
+	MaxRequests int `yaml:"maxRequests"`
+
+	// MaxRows is the maximum number of records received from the web API.
+	MaxRows int `yaml:"maxRows"`
+
+	// MaxCost is the maximum total "cost" of the requests made, for APIs that bill some endpoints more than others.
+	MaxCost float64 `yaml:"maxCost"`
+}
+
+func (limits *Limits) validate() error {
+	if limits.MaxRequests < 0 || limits.MaxRows < 0 || limits.MaxCost < 0 {
+		return fmt.Errorf("%w: limits must not be negative", ErrInvalidLimits)
+	}
+
+	return nil
+}
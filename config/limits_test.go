@@ -0,0 +1,55 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLimitsValidate(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name   string
+		limits Limits
+		err    error
+	}{
+		{name: "unlimited"},
+		{name: "limited", limits: Limits{MaxRequests: 50000, MaxRows: 10_000_000, MaxCost: 12.5}},
+		{name: "negative requests", limits: Limits{MaxRequests: -1}, err: ErrInvalidLimits},
+		{name: "negative rows", limits: Limits{MaxRows: -1}, err: ErrInvalidLimits},
+		{name: "negative cost", limits: Limits{MaxCost: -1}, err: ErrInvalidLimits},
+	} {
+		tcase := tcase
+
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			if err := tcase.limits.validate(); !errors.Is(err, tcase.err) {
+				t.Fatalf("expected error %v, got %v", tcase.err, err)
+			}
+		})
+	}
+}
+
+func TestRequestCost(t *testing.T) {
+	t.Parallel()
+
+	if cost := (&Request{}).RequestCost(); cost != 1 {
+		t.Fatalf("expected the default cost to be 1, got %g", cost)
+	}
+
+	if cost := (&Request{Cost: 2.5}).RequestCost(); cost != 2.5 {
+		t.Fatalf("expected a cost of 2.5, got %g", cost)
+	}
+
+	if err := (&Request{Cost: -1}).validate(); !errors.Is(err, ErrInvalidLimits) {
+		t.Fatalf("expected %v, got %v", ErrInvalidLimits, err)
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/alpstable/gidari/tools"
+)
+
+// MaintenanceWindow is a recurring period during which the web API is known to be unavailable, e.g. for nightly
+// maintenance. Requests are held while a window is open and made once it closes, rather than failing.
+type MaintenanceWindow struct {
+	// Schedule is the cron schedule of the start of the window, e.g. "0 2 * * *" for 02:00 every day.
+	Schedule string `yaml:"schedule"`
+
+	// Duration is how long the window stays open after each start, e.g. "30m".
+	Duration string `yaml:"duration"`
+
+	// Timezone is the IANA name of the timezone of the schedule, e.g. "America/New_York". The default is UTC.
+	Timezone string `yaml:"timezone"`
+
+	// Requests are the endpoints or tables of the requests that are held during the window. The default is every
+	// request.
+	Requests []string `yaml:"requests"`
+}
+
+func (window *MaintenanceWindow) parse() (*tools.Schedule, time.Duration, error) {
+	loc := time.UTC
+
+	if window.Timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(window.Timezone); err != nil {
+			return nil, 0, fmt.Errorf("%w: %v", ErrInvalidMaintenance, err)
+		}
+	}
+
+	sched, err := tools.ParseSchedule(window.Schedule, loc)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", ErrInvalidMaintenance, err)
+	}
+
+	duration, err := time.ParseDuration(window.Duration)
+	if err != nil || duration <= 0 {
+		return nil, 0, fmt.Errorf("%w: duration must be a positive duration, e.g. \"30m\", got %q",
+			ErrInvalidMaintenance, window.Duration)
+	}
+
+	return sched, duration, nil
+}
+
+func (window *MaintenanceWindow) validate() error {
+	_, _, err := window.parse()
+
+	return err
+}
+
+// appliesTo returns true if the requests of the window include a request.
+func (window *MaintenanceWindow) appliesTo(req *Request) bool {
+	if len(window.Requests) == 0 {
+		return true
+	}
+
+	for _, name := range window.Requests {
+		if name == req.Endpoint || name == req.Table {
+			return true
+		}
+	}
+
+	return false
+}
+
+// OpenUntil returns the time that the window closes if it is open at "t", and false if it is not.
+func (window *MaintenanceWindow) OpenUntil(t time.Time) (time.Time, bool) {
+	sched, duration, err := window.parse()
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	// The window is open if it started within its duration before "t".
+	start := sched.Next(t.Add(-duration))
+	if start.IsZero() || start.After(t) {
+		return time.Time{}, false
+	}
+
+	return start.Add(duration), true
+}
@@ -0,0 +1,119 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMaintenanceWindowValidate(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name   string
+		window MaintenanceWindow
+		err    error
+	}{
+		{name: "valid", window: MaintenanceWindow{Schedule: "0 2 * * *", Duration: "30m"}},
+		{
+			name:   "timezone",
+			window: MaintenanceWindow{Schedule: "@daily", Duration: "1h", Timezone: "Europe/Berlin"},
+		},
+		{name: "schedule", window: MaintenanceWindow{Schedule: "0 2 *", Duration: "30m"}, err: ErrInvalidMaintenance},
+		{name: "missing duration", window: MaintenanceWindow{Schedule: "0 2 * * *"}, err: ErrInvalidMaintenance},
+		{
+			name:   "negative duration",
+			window: MaintenanceWindow{Schedule: "0 2 * * *", Duration: "-1m"},
+			err:    ErrInvalidMaintenance,
+		},
+		{
+			name:   "unknown timezone",
+			window: MaintenanceWindow{Schedule: "0 2 * * *", Duration: "30m", Timezone: "Mars/Olympus"},
+			err:    ErrInvalidMaintenance,
+		},
+	} {
+		tcase := tcase
+
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			if err := tcase.window.validate(); !errors.Is(err, tcase.err) {
+				t.Fatalf("expected error %v, got %v", tcase.err, err)
+			}
+		})
+	}
+}
+
+func TestMaintenanceWindowOpenUntil(t *testing.T) {
+	t.Parallel()
+
+	window := &MaintenanceWindow{Schedule: "0 23 * * *", Duration: "2h"}
+
+	for _, tcase := range []struct {
+		name string
+		now  time.Time
+		want time.Time
+	}{
+		{name: "before", now: time.Date(2022, 5, 10, 22, 59, 59, 0, time.UTC)},
+		{
+			name: "start",
+			now:  time.Date(2022, 5, 10, 23, 0, 0, 0, time.UTC),
+			want: time.Date(2022, 5, 11, 1, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "after midnight",
+			now:  time.Date(2022, 5, 11, 0, 59, 30, 0, time.UTC),
+			want: time.Date(2022, 5, 11, 1, 0, 0, 0, time.UTC),
+		},
+		{name: "end", now: time.Date(2022, 5, 11, 1, 0, 0, 0, time.UTC)},
+	} {
+		until, open := window.OpenUntil(tcase.now)
+		if open != !tcase.want.IsZero() || !until.Equal(tcase.want) {
+			t.Fatalf("%s: expected the window to close at %v, got %v (open %t)", tcase.name, tcase.want, until, open)
+		}
+	}
+}
+
+func TestNewAppliesMaintenance(t *testing.T) {
+	t.Parallel()
+
+	data := `
+version: 1
+url: https://example.com
+connectionStrings:
+  - mongodb://localhost:27017/db
+rateLimit:
+  burst: 1
+  period: 1
+maintenance:
+  - schedule: "0 2 * * *"
+    duration: 30m
+  - schedule: "0 4 * * 0"
+    duration: 1h
+    requests: [/candles, fills]
+requests:
+  - endpoint: /candles
+  - endpoint: /fills/recent
+    table: fills
+  - endpoint: /trades
+`
+
+	cfg, err := New(context.Background(), newTestConfigFile(t, data))
+	if err != nil {
+		t.Fatalf("failed to create config: %v", err)
+	}
+
+	for idx, want := range []int{2, 2, 1} {
+		if got := len(cfg.Requests[idx].Maintenance); got != want {
+			t.Fatalf("expected %d maintenance windows for %s, got %d", want, cfg.Requests[idx].Endpoint, got)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+// DefaultManifestDir is the directory that run manifests are written to when "manifest.dir" is not set.
+const DefaultManifestDir = "gidari-runs"
+
+// ManifestConfig configures the manifest that every run writes once it is over: the rows, chunk coverage, columns
+// and durations of each of its tables, which "gidari diff-runs" compares between two runs.
+type ManifestConfig struct {
+	// Dir is the directory that the manifests are written to, as "<run ID>.json". The ID of a run is the UTC time
+	// that it started, e.g. "20221014T150405Z".
+	Dir string `yaml:"dir"`
+}
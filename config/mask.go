@@ -0,0 +1,82 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The masks that the values of the columns of "masks" are redacted with before they are written.
+const (
+	// MaskHash replaces a value with the hex SHA-256 hash of it, or its HMAC-SHA256 with the "maskKey" if one is
+	// set, so that the column can still be joined and counted by.
+	MaskHash = "hash"
+
+	// MaskNull replaces a value with null.
+	MaskNull = "null"
+
+	// MaskTruncate keeps the first characters of a string, e.g. "truncate(3)" for the first three.
+	MaskTruncate = "truncate"
+)
+
+// truncateMask matches the truncate mask and captures its length.
+var truncateMask = regexp.MustCompile(`^truncate\(\s*(\d+)\s*\)$`)
+
+// Mask is how the values of a column are redacted.
+type Mask struct {
+	// Name is one of the masks, e.g. "truncate".
+	Name string
+
+	// Length is the number of characters that the truncate mask keeps.
+	Length int
+}
+
+// ParseMask will parse a mask of "masks", e.g. "hash" or "truncate(3)".
+func ParseMask(str string) (*Mask, error) {
+	str = strings.ToLower(strings.TrimSpace(str))
+
+	switch str {
+	case MaskHash, MaskNull:
+		return &Mask{Name: str}, nil
+	}
+
+	match := truncateMask.FindStringSubmatch(str)
+	if match == nil {
+		return nil, fmt.Errorf("%w: %q must be one of %s, %s or %s(<length>)", ErrInvalidMasks, str, MaskHash,
+			MaskNull, MaskTruncate)
+	}
+
+	length, err := strconv.Atoi(match[1])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q must have a length that is an integer", ErrInvalidMasks, str)
+	}
+
+	return &Mask{Name: MaskTruncate, Length: length}, nil
+}
+
+// validateMasks will ensure that every column is redacted with one of the masks.
+func validateMasks(field string, masks map[string]string) error {
+	columns := make([]string, 0, len(masks))
+	for column := range masks {
+		columns = append(columns, column)
+	}
+
+	sort.Strings(columns)
+
+	for _, column := range columns {
+		if _, err := ParseMask(masks[column]); err != nil {
+			return fmt.Errorf("%s.%s: %w", field, column, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,37 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateMasks(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name  string
+		masks map[string]string
+		err   error
+	}{
+		{name: "none"},
+		{name: "valid", masks: map[string]string{"email": MaskHash, "token": MaskNull, "ip": "truncate( 7 )"}},
+		{name: "unknown", masks: map[string]string{"email": "encrypt"}, err: ErrInvalidMasks},
+		{name: "no length", masks: map[string]string{"ip": "truncate"}, err: ErrInvalidMasks},
+	} {
+		if err := validateMasks("masks", tcase.masks); !errors.Is(err, tcase.err) {
+			t.Fatalf("%s: expected error %v, got %v", tcase.name, tcase.err, err)
+		}
+	}
+
+	mask, err := ParseMask("Truncate(3)")
+	if err != nil || mask.Name != MaskTruncate || mask.Length != 3 {
+		t.Fatalf("expected to truncate to 3 characters, got %+v (%v)", mask, err)
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// byteSizeUnits are the suffixes accepted by "ParseByteSize", ordered so that longer suffixes are matched before
+// shorter ones (e.g. "GiB" before "B").
+var byteSizeUnits = []struct {
+	suffix string
+	scale  uint64
+}{
+	{"KiB", 1 << 10},
+	{"MiB", 1 << 20},
+	{"GiB", 1 << 30},
+	{"TiB", 1 << 40},
+	{"KB", 1e3},
+	{"MB", 1e6},
+	{"GB", 1e9},
+	{"TB", 1e12},
+	{"K", 1 << 10},
+	{"M", 1 << 20},
+	{"G", 1 << 30},
+	{"T", 1 << 40},
+	{"B", 1},
+}
+
+// ByteSize is a number of bytes that can be unmarshaled from a human readable string such as "512MiB" or "2GiB".
+type ByteSize uint64
+
+// ParseByteSize will parse a human readable size into a "ByteSize". Binary ("KiB", "MiB", "GiB", "TiB") and decimal
+// ("KB", "MB", "GB", "TB") suffixes are supported, as is a plain number of bytes.
+func ParseByteSize(str string) (ByteSize, error) {
+	str = strings.TrimSpace(str)
+	if str == "" {
+		return 0, nil
+	}
+
+	scale := uint64(1)
+
+	for _, unit := range byteSizeUnits {
+		if strings.HasSuffix(str, unit.suffix) {
+			scale = unit.scale
+			str = strings.TrimSpace(strings.TrimSuffix(str, unit.suffix))
+
+			break
+		}
+	}
+
+	num, err := strconv.ParseFloat(str, 64)
+	if err != nil || num < 0 {
+		return 0, fmt.Errorf("%w: %q", UnableToParseError("byte size"), str)
+	}
+
+	return ByteSize(num * float64(scale)), nil
+}
+
+// UnmarshalYAML will unmarshal a human readable size or an integer number of bytes into a "ByteSize".
+func (size *ByteSize) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var str string
+	if err := unmarshal(&str); err != nil {
+		return err
+	}
+
+	parsed, err := ParseByteSize(str)
+	if err != nil {
+		return err
+	}
+
+	*size = parsed
+
+	return nil
+}
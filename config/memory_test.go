@@ -0,0 +1,98 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestParseByteSize(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name string
+		str  string
+		want ByteSize
+		err  bool
+	}{
+		{name: "empty", str: "", want: 0},
+		{name: "bytes", str: "512", want: 512},
+		{name: "bytes suffix", str: "512B", want: 512},
+		{name: "kibibytes", str: "1KiB", want: 1 << 10},
+		{name: "gibibytes", str: "2GiB", want: 2 << 30},
+		{name: "gigabytes", str: "2GB", want: 2e9},
+		{name: "short suffix", str: "1G", want: 1 << 30},
+		{name: "fractional", str: "1.5MiB", want: 3 << 19},
+		{name: "whitespace", str: " 1 MiB ", want: 1 << 20},
+		{name: "invalid", str: "lots", err: true},
+		{name: "negative", str: "-1GiB", err: true},
+	} {
+		tcase := tcase
+
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := ParseByteSize(tcase.str)
+			if tcase.err && err == nil {
+				t.Fatalf("expected error, got nil")
+			}
+
+			if !tcase.err && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got != tcase.want {
+				t.Fatalf("expected %d, got %d", tcase.want, got)
+			}
+		})
+	}
+
+	t.Run("yaml", func(t *testing.T) {
+		t.Parallel()
+
+		var cfg struct {
+			MaxMemory ByteSize `yaml:"maxMemory"`
+		}
+
+		if err := yaml.Unmarshal([]byte("maxMemory: 2GiB"), &cfg); err != nil {
+			t.Fatalf("failed to unmarshal: %v", err)
+		}
+
+		if cfg.MaxMemory != 2<<30 {
+			t.Fatalf("expected %d, got %d", 2<<30, cfg.MaxMemory)
+		}
+	})
+}
+
+func TestValidateStreamBatchSize(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		size int
+		err  error
+	}{
+		{size: 0},
+		{size: 500},
+		{size: -1, err: ErrInvalidStreamBatchSize},
+	} {
+		cfg := &Config{
+			RateLimitConfig:   &RateLimitConfig{Burst: new(int), Period: new(time.Duration)},
+			ConnectionStrings: []string{"mongodb://localhost"},
+			Requests:          []*Request{{Endpoint: "/trades"}},
+			StreamBatchSize:   tcase.size,
+		}
+
+		if err := cfg.Validate(); !errors.Is(err, tcase.err) {
+			t.Fatalf("%d: expected error %v, got %v", tcase.size, tcase.err, err)
+		}
+	}
+}
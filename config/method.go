@@ -0,0 +1,296 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/alpstable/gidari/internal/hooks"
+)
+
+// FreshnessCheckHead makes a "HEAD" request before a conditional request, and skips the request if the validators of
+// the "HEAD" response are those of the last response.
+const FreshnessCheckHead = "head"
+
+// methods are the HTTP methods that a request can be made with.
+var methods = []string{
+	http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodHead,
+}
+
+// BodyTemplateData is the data that the "bodyTemplate" of a request is executed with.
+type BodyTemplateData struct {
+	// Start and End are the bounds of the timeseries chunk of the request, formatted as they are in its query, or
+	// empty if it is not a timeseries request.
+	Start, End string
+
+	// Query are the query parameters of the request.
+	Query map[string]string
+
+	// State are the variables of the state store, which the "state" and "setState" functions read and write, or nil
+	// if there is no store.
+	State hooks.Variables
+}
+
+// bodyTemplateFuncs are the functions of a "bodyTemplate": "json" encodes a value as JSON, e.g. to quote a string.
+// The state functions are bound to the variables of the data when a template is executed.
+var bodyTemplateFuncs = template.FuncMap{
+	"json": func(val interface{}) (string, error) {
+		data, err := json.Marshal(val)
+
+		return string(data), err
+	},
+	"state":    stateFuncs(nil)["state"],
+	"setState": stateFuncs(nil)["setState"],
+}
+
+// stateFuncs returns the functions of a template that read and write the variables of the state store: "state"
+// returns the value of a variable, or an empty string if it has not been set, and "setState" sets a variable and
+// renders nothing.
+func stateFuncs(vars hooks.Variables) template.FuncMap {
+	return template.FuncMap{
+		"state": func(name string) string {
+			if vars == nil {
+				return ""
+			}
+
+			value, _ := vars.Variable(name)
+
+			return value
+		},
+		"setState": func(name string, value interface{}) string {
+			if vars != nil {
+				vars.SetVariable(name, fmt.Sprint(value))
+			}
+
+			return ""
+		},
+	}
+}
+
+// usesState returns true if a template calls "state" or "setState".
+func usesState(tmpl *template.Template) bool {
+	for _, tree := range tmpl.Templates() {
+		if tree.Tree != nil && usesStateNode(tree.Tree.Root) {
+			return true
+		}
+	}
+
+	return false
+}
+
+//nolint:cyclop
+func usesStateNode(node parse.Node) bool {
+	switch node := node.(type) {
+	case *parse.ListNode:
+		if node == nil {
+			return false
+		}
+
+		for _, child := range node.Nodes {
+			if usesStateNode(child) {
+				return true
+			}
+		}
+	case *parse.ActionNode:
+		return usesStateNode(node.Pipe)
+	case *parse.PipeNode:
+		if node == nil {
+			return false
+		}
+
+		for _, cmd := range node.Cmds {
+			if usesStateNode(cmd) {
+				return true
+			}
+		}
+	case *parse.CommandNode:
+		for _, arg := range node.Args {
+			if usesStateNode(arg) {
+				return true
+			}
+		}
+	case *parse.IdentifierNode:
+		return node.Ident == "state" || node.Ident == "setState"
+	case *parse.BranchNode:
+		return usesStateNode(node.Pipe) || usesStateNode(node.List) || usesStateNode(node.ElseList)
+	case *parse.IfNode:
+		return usesStateNode(&node.BranchNode)
+	case *parse.RangeNode:
+		return usesStateNode(&node.BranchNode)
+	case *parse.WithNode:
+		return usesStateNode(&node.BranchNode)
+	case *parse.TemplateNode:
+		return usesStateNode(node.Pipe)
+	}
+
+	return false
+}
+
+func (req *Request) validateMethod() error {
+	method := strings.ToUpper(req.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	known := false
+
+	for _, m := range methods {
+		known = known || m == method
+	}
+
+	if !known {
+		return fmt.Errorf("%w: method %q of %s must be one of %s", ErrInvalidMethod, req.Method, req.Endpoint,
+			strings.Join(methods, ", "))
+	}
+
+	if method == http.MethodHead && (req.Body != nil || req.BodyTemplate != "") {
+		return fmt.Errorf("%w: %s is a HEAD request, which cannot have a body", ErrInvalidMethod, req.Endpoint)
+	}
+
+	if err := req.validateBodyTemplate(); err != nil {
+		return err
+	}
+
+	if err := req.validateQueryTemplates(); err != nil {
+		return err
+	}
+
+	switch req.FreshnessCheck {
+	case "":
+	case FreshnessCheckHead:
+		if !req.Conditional {
+			return fmt.Errorf("%w: freshnessCheck of %s requires it to be conditional", ErrInvalidMethod,
+				req.Endpoint)
+		}
+	default:
+		return fmt.Errorf("%w: freshnessCheck %q of %s must be %q", ErrInvalidMethod, req.FreshnessCheck,
+			req.Endpoint, FreshnessCheckHead)
+	}
+
+	return nil
+}
+
+func (req *Request) validateBodyTemplate() error {
+	if req.BodyTemplate == "" {
+		return nil
+	}
+
+	if req.Body != nil {
+		return fmt.Errorf("%w: %s sets both body and bodyTemplate", ErrInvalidBodyTemplate, req.Endpoint)
+	}
+
+	// The range of the chunks is read from the query, since a template has no fields to read it from.
+	if req.Timeseries != nil && req.Timeseries.Target == TimeseriesTargetBody {
+		return fmt.Errorf("%w: the timeseries of %s must target the query to use a bodyTemplate",
+			ErrInvalidBodyTemplate, req.Endpoint)
+	}
+
+	_, err := req.parseBodyTemplate()
+
+	return err
+}
+
+// UsesState returns true if the "bodyTemplate", or a query template, of the request reads or writes the variables
+// of the state store.
+func (req *Request) UsesState() bool {
+	if tmpl, err := req.parseBodyTemplate(); err == nil && req.BodyTemplate != "" && usesState(tmpl) {
+		return true
+	}
+
+	for key, value := range req.Query {
+		if tmpl, err := req.parseQueryTemplate(key, value); err == nil && tmpl != nil && usesState(tmpl) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseQueryTemplate returns the template of a query value, or nil if the value is not a template.
+func (req *Request) parseQueryTemplate(key, value string) (*template.Template, error) {
+	if !strings.Contains(value, "{{") {
+		return nil, nil
+	}
+
+	tmpl, err := template.New(key).Funcs(bodyTemplateFuncs).Option("missingkey=zero").Parse(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q of %s: %v", ErrInvalidQueryTemplate, key, req.Endpoint, err)
+	}
+
+	return tmpl, nil
+}
+
+func (req *Request) validateQueryTemplates() error {
+	for key, value := range req.Query {
+		if _, err := req.parseQueryTemplate(key, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RenderQuery will execute the query values of the request that are templates, e.g. "{{ state \"cursor\" }}",
+// with the variables of the state store, replacing them in its query. It is rendered once a run, before the query
+// is split into timeseries chunks.
+func (req *Request) RenderQuery(vars hooks.Variables) error {
+	for key, value := range req.Query {
+		tmpl, err := req.parseQueryTemplate(key, value)
+		if err != nil {
+			return err
+		}
+
+		if tmpl == nil {
+			continue
+		}
+
+		var buf bytes.Buffer
+		if err := tmpl.Funcs(stateFuncs(vars)).Execute(&buf, BodyTemplateData{State: vars}); err != nil {
+			return fmt.Errorf("%w: %q of %s: %v", ErrInvalidQueryTemplate, key, req.Endpoint, err)
+		}
+
+		req.Query[key] = buf.String()
+	}
+
+	return nil
+}
+
+func (req *Request) parseBodyTemplate() (*template.Template, error) {
+	tmpl, err := template.New(req.Endpoint).Funcs(bodyTemplateFuncs).Option("missingkey=zero").Parse(req.BodyTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidBodyTemplate, req.Endpoint, err)
+	}
+
+	return tmpl, nil
+}
+
+// RenderBody will execute the "bodyTemplate" of the request with the data, returning the JSON body of the request.
+func (req *Request) RenderBody(data BodyTemplateData) ([]byte, error) {
+	tmpl, err := req.parseBodyTemplate()
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Funcs(stateFuncs(data.State)).Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidBodyTemplate, req.Endpoint, err)
+	}
+
+	if !json.Valid(buf.Bytes()) {
+		return nil, fmt.Errorf("%w: the body rendered for %s is not valid JSON: %s", ErrInvalidBodyTemplate,
+			req.Endpoint, buf.Bytes())
+	}
+
+	return buf.Bytes(), nil
+}
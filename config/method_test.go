@@ -0,0 +1,142 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateMethod(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name string
+		req  *Request
+		err  error
+	}{
+		{name: "default", req: &Request{}},
+		{name: "lower case", req: &Request{Method: "patch", Body: map[string]interface{}{"id": 1}}},
+		{name: "delete", req: &Request{Method: "DELETE"}},
+		{name: "head", req: &Request{Method: "HEAD"}},
+		{name: "unknown", req: &Request{Method: "FETCH"}, err: ErrInvalidMethod},
+		{name: "head body", req: &Request{Method: "HEAD", BodyTemplate: `{}`}, err: ErrInvalidMethod},
+		{name: "template", req: &Request{Method: "PUT", BodyTemplate: `{"from": {{json .Start}}}`}},
+		{
+			name: "body and template",
+			req:  &Request{Method: "POST", Body: map[string]interface{}{}, BodyTemplate: `{}`},
+			err:  ErrInvalidBodyTemplate,
+		},
+		{name: "bad template", req: &Request{Method: "POST", BodyTemplate: `{{.Start`}, err: ErrInvalidBodyTemplate},
+		{
+			name: "template in body timeseries",
+			req: &Request{
+				Method:       "POST",
+				BodyTemplate: `{}`,
+				Timeseries:   &Timeseries{Target: TimeseriesTargetBody},
+			},
+			err: ErrInvalidBodyTemplate,
+		},
+		{name: "freshness", req: &Request{Conditional: true, FreshnessCheck: FreshnessCheckHead}},
+		{name: "freshness unconditional", req: &Request{FreshnessCheck: FreshnessCheckHead}, err: ErrInvalidMethod},
+		{name: "unknown freshness", req: &Request{Conditional: true, FreshnessCheck: "etag"}, err: ErrInvalidMethod},
+		{name: "query template", req: &Request{Query: map[string]string{"since": `{{ state "cursor" }}`}}},
+		{
+			name: "bad query template",
+			req:  &Request{Query: map[string]string{"since": `{{ state "cursor" `}},
+			err:  ErrInvalidQueryTemplate,
+		},
+	} {
+		if err := tcase.req.validateMethod(); !errors.Is(err, tcase.err) {
+			t.Fatalf("%s: expected error %v, got %v", tcase.name, tcase.err, err)
+		}
+	}
+}
+
+func TestRenderBody(t *testing.T) {
+	t.Parallel()
+
+	req := &Request{
+		Endpoint:     "/search",
+		BodyTemplate: `{"from": {{json .Start}}, "to": {{json .End}}, "symbol": {{json .Query.symbol}}}`,
+	}
+
+	body, err := req.RenderBody(BodyTemplateData{
+		Start: "2022-01-01T00:00:00Z",
+		End:   "2022-01-02T00:00:00Z",
+		Query: map[string]string{"symbol": "BTC"},
+	})
+	if err != nil {
+		t.Fatalf("failed to render body: %v", err)
+	}
+
+	if want := `{"from": "2022-01-01T00:00:00Z", "to": "2022-01-02T00:00:00Z", "symbol": "BTC"}`; string(body) != want {
+		t.Fatalf("expected %s, got %s", want, body)
+	}
+
+	req.BodyTemplate = `{"from": {{.Start}}}`
+	if _, err := req.RenderBody(BodyTemplateData{Start: "2022-01-01"}); !errors.Is(err, ErrInvalidBodyTemplate) {
+		t.Fatalf("expected %v for a body that is not JSON, got %v", ErrInvalidBodyTemplate, err)
+	}
+}
+
+// variables are the variables of a state store in memory.
+type variables map[string]string
+
+func (vars variables) Variable(name string) (string, bool) {
+	value, ok := vars[name]
+
+	return value, ok
+}
+
+func (vars variables) SetVariable(name, value string) {
+	vars[name] = value
+}
+
+func TestRenderState(t *testing.T) {
+	t.Parallel()
+
+	vars := variables{"cursor": "abc"}
+	req := &Request{
+		Endpoint:     "/events",
+		Query:        map[string]string{"since": `{{ state "cursor" }}`, "page": `{{ state "page" }}`, "limit": "10"},
+		BodyTemplate: `{"after": {{json (state "cursor")}}}{{ setState "seen" .Query.limit }}`,
+	}
+
+	if !req.UsesState() {
+		t.Fatalf("expected the templates of %s to use the state", req.Endpoint)
+	}
+
+	if err := req.RenderQuery(vars); err != nil {
+		t.Fatalf("failed to render query: %v", err)
+	}
+
+	want := map[string]string{"since": "abc", "page": "", "limit": "10"}
+	for key, value := range want {
+		if req.Query[key] != value {
+			t.Fatalf("expected query %q to be %q, got %q", key, value, req.Query[key])
+		}
+	}
+
+	body, err := req.RenderBody(BodyTemplateData{Query: req.Query, State: vars})
+	if err != nil {
+		t.Fatalf("failed to render body: %v", err)
+	}
+
+	if want := `{"after": "abc"}`; string(body) != want {
+		t.Fatalf("expected %s, got %s", want, body)
+	}
+
+	if vars["seen"] != "10" {
+		t.Fatalf("expected setState to set %q, got %q", "10", vars["seen"])
+	}
+
+	if (&Request{BodyTemplate: `{{json .Start}}`, Query: map[string]string{"a": "b"}}).UsesState() {
+		t.Fatalf("expected a template without state functions not to use the state")
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// MetricAggregateSum adds the values of a metric together. This is the default.
+	MetricAggregateSum = "sum"
+
+	// MetricAggregateMin keeps the smallest value of a metric.
+	MetricAggregateMin = "min"
+
+	// MetricAggregateMax keeps the largest value of a metric.
+	MetricAggregateMax = "max"
+
+	// RowsMetric is the built-in metric counting the records received by a run. "rows.<table>" counts the records
+	// received for a single table.
+	RowsMetric = "rows"
+
+	// CredentialExpiryMetric is the built-in metric with the least time, in seconds, that the credentials of the
+	// run had left before they expired. It is only recorded for credentials with a known expiry.
+	CredentialExpiryMetric = "credentialExpiry"
+)
+
+// Metric extracts a numeric value from the responses of a request into a named metric, which is included in the
+// summary of the run and can be checked by "assertions". Requests that declare a metric with the same name
+// contribute to the same metric.
+type Metric struct {
+	// Name identifies the metric in assertions and the summary of the run.
+	Name string `yaml:"name"`
+
+	// Path is a JSON path into each record of a response, e.g. "$.total_count". A response that is not an array
+	// is a single record. Records without a numeric value at the path are ignored.
+	Path string `yaml:"path"`
+
+	// Aggregate is how the values are combined across records and responses, either "sum", "min" or "max". The
+	// default is "sum".
+	Aggregate string `yaml:"aggregate" enum:"sum,min,max"`
+}
+
+// Assertion is a rule checked against the metrics once a run has completed. Each comparison is either a number or
+// the name of another metric, and the run fails if any comparison does not hold.
+type Assertion struct {
+	// Metric is the name of the metric being checked.
+	Metric string `yaml:"metric"`
+
+	// Equals is the value the metric must equal, within "tolerance".
+	Equals string `yaml:"equals"`
+
+	// Min is the value the metric must be greater than or equal to.
+	Min string `yaml:"min"`
+
+	// Max is the value the metric must be less than or equal to.
+	Max string `yaml:"max"`
+
+	// Tolerance is the relative difference allowed by "equals", e.g. 0.01 allows the values to differ by 1%.
+	Tolerance float64 `yaml:"tolerance"`
+}
+
+// IsBuiltinMetric returns true if the name is one of the metrics that every run records.
+func IsBuiltinMetric(name string) bool {
+	return name == RowsMetric || strings.HasPrefix(name, RowsMetric+".") || name == CredentialExpiryMetric
+}
+
+// AggregateOrDefault returns how the values of the metric are combined, which defaults to "sum".
+func (metric *Metric) AggregateOrDefault() string {
+	if metric.Aggregate == "" {
+		return MetricAggregateSum
+	}
+
+	return metric.Aggregate
+}
+
+func (metric *Metric) validate() error {
+	if metric.Name == "" {
+		return MissingConfigFieldError("metrics.name")
+	}
+
+	if IsBuiltinMetric(metric.Name) {
+		return fmt.Errorf("%w: metric name %q is reserved", ErrInvalidMetric, metric.Name)
+	}
+
+	if metric.Path == "" {
+		return MissingConfigFieldError(fmt.Sprintf("metrics.%s.path", metric.Name))
+	}
+
+	switch metric.Aggregate {
+	case "", MetricAggregateSum, MetricAggregateMin, MetricAggregateMax:
+	default:
+		return fmt.Errorf("%w: metrics.%s.aggregate %q must be %q, %q or %q", ErrInvalidMetric, metric.Name,
+			metric.Aggregate, MetricAggregateSum, MetricAggregateMin, MetricAggregateMax)
+	}
+
+	return nil
+}
+
+// validate will ensure that the assertion compares its metric to a number or a known metric.
+func (assertion *Assertion) validate(metrics map[string]string) error {
+	if assertion.Metric == "" {
+		return MissingConfigFieldError("assertions.metric")
+	}
+
+	if assertion.Equals == "" && assertion.Min == "" && assertion.Max == "" {
+		return MissingConfigFieldError(fmt.Sprintf("assertions.%s.equals, min or max", assertion.Metric))
+	}
+
+	if assertion.Tolerance < 0 {
+		return fmt.Errorf("%w: assertions.%s.tolerance must not be negative", ErrInvalidMetric, assertion.Metric)
+	}
+
+	for _, name := range []string{assertion.Metric, assertion.Equals, assertion.Min, assertion.Max} {
+		if _, ok := metrics[name]; ok || name == "" || IsBuiltinMetric(name) {
+			continue
+		}
+
+		if _, err := strconv.ParseFloat(name, 64); err == nil && name != assertion.Metric {
+			continue
+		}
+
+		return fmt.Errorf("%w: assertion on %q references unknown metric %q", ErrInvalidMetric, assertion.Metric,
+			name)
+	}
+
+	return nil
+}
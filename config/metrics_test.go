@@ -0,0 +1,139 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestMetricsValidate(t *testing.T) {
+	t.Parallel()
+
+	reported := &Metric{Name: "reported", Path: "$.total_count"}
+
+	for _, tcase := range []struct {
+		name       string
+		metrics    []*Metric
+		assertions []*Assertion
+		err        error
+	}{
+		{name: "none"},
+		{name: "metric", metrics: []*Metric{reported}},
+		{
+			name:       "assert against rows",
+			metrics:    []*Metric{reported},
+			assertions: []*Assertion{{Metric: "reported", Equals: "rows.trades", Tolerance: 0.01}},
+		},
+		{name: "assert on rows", assertions: []*Assertion{{Metric: "rows", Min: "1", Max: "1e6"}}},
+		{name: "no name", metrics: []*Metric{{Path: "$.total_count"}}, err: ErrMissingConfigField},
+		{name: "no path", metrics: []*Metric{{Name: "reported"}}, err: ErrMissingConfigField},
+		{name: "reserved name", metrics: []*Metric{{Name: "rows.trades", Path: "$.n"}}, err: ErrInvalidMetric},
+		{
+			name:    "aggregate",
+			metrics: []*Metric{{Name: "reported", Path: "$.total_count", Aggregate: "avg"}},
+			err:     ErrInvalidMetric,
+		},
+		{
+			name: "conflicting aggregates",
+			metrics: []*Metric{
+				{Name: "reported", Path: "$.total_count"},
+				{Name: "reported", Path: "$.total_count", Aggregate: MetricAggregateMax},
+			},
+			err: ErrInvalidMetric,
+		},
+		{name: "no comparison", assertions: []*Assertion{{Metric: "rows"}}, err: ErrMissingConfigField},
+		{
+			name:       "unknown metric",
+			metrics:    []*Metric{reported},
+			assertions: []*Assertion{{Metric: "reported", Equals: "loaded"}},
+			err:        ErrInvalidMetric,
+		},
+		{name: "number as metric", assertions: []*Assertion{{Metric: "1", Equals: "1"}}, err: ErrInvalidMetric},
+		{
+			name:       "negative tolerance",
+			assertions: []*Assertion{{Metric: "rows", Equals: "1", Tolerance: -1}},
+			err:        ErrInvalidMetric,
+		},
+	} {
+		tcase := tcase
+
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := &Config{
+				RateLimitConfig:   &RateLimitConfig{Burst: new(int), Period: new(time.Duration)},
+				ConnectionStrings: []string{"mongodb://localhost"},
+				Assertions:        tcase.assertions,
+			}
+
+			for _, metric := range tcase.metrics {
+				cfg.Requests = append(cfg.Requests, &Request{Endpoint: "/trades", Metrics: []*Metric{metric}})
+			}
+
+			if err := cfg.Validate(); !errors.Is(err, tcase.err) {
+				t.Fatalf("expected error %v, got %v", tcase.err, err)
+			}
+		})
+	}
+}
+
+func TestAssertionUnmarshal(t *testing.T) {
+	t.Parallel()
+
+	var assertion Assertion
+	if err := yaml.UnmarshalStrict([]byte("metric: rows\nmin: 100\nmax: 1.5e3\n"), &assertion); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+
+	if assertion.Min != "100" || assertion.Max != "1.5e3" {
+		t.Fatalf("expected numeric comparisons to decode as strings, got %+v", assertion)
+	}
+}
+
+func TestCanaryValidate(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name       string
+		canary     bool
+		assertions []*Assertion
+		err        error
+	}{
+		{name: "none"},
+		{name: "canary without assertions", canary: true},
+		{name: "assert on canary rows", canary: true, assertions: []*Assertion{{Metric: "rows", Min: "1"}}},
+		{name: "no canary", assertions: []*Assertion{{Metric: "rows", Min: "1"}}, err: ErrInvalidCanary},
+		{
+			name:       "unknown metric",
+			canary:     true,
+			assertions: []*Assertion{{Metric: "reported", Min: "1"}},
+			err:        ErrInvalidMetric,
+		},
+	} {
+		tcase := tcase
+
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := &Config{
+				RateLimitConfig:   &RateLimitConfig{Burst: new(int), Period: new(time.Duration)},
+				ConnectionStrings: []string{"mongodb://localhost"},
+				Requests:          []*Request{{Endpoint: "/trades", Canary: tcase.canary}, {Endpoint: "/candles"}},
+				CanaryAssertions:  tcase.assertions,
+			}
+
+			if err := cfg.Validate(); !errors.Is(err, tcase.err) {
+				t.Fatalf("expected error %v, got %v", tcase.err, err)
+			}
+		})
+	}
+}
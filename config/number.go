@@ -0,0 +1,85 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// NumberLocale is how the numbers of a locale are written.
+type NumberLocale struct {
+	// Decimal separates the integer part of a number from its fraction.
+	Decimal string
+
+	// Groups are the separators between the groups of digits of the integer part, any of which may be used.
+	Groups []string
+}
+
+var (
+	// dotDecimal is the format of "1,234.56".
+	dotDecimal = &NumberLocale{Decimal: ".", Groups: []string{","}}
+
+	// commaDecimal is the format of "1.234,56".
+	commaDecimal = &NumberLocale{Decimal: ",", Groups: []string{"."}}
+
+	// spaceGroups is the format of "1 234,56", where the space may also be a no-break or narrow no-break space.
+	spaceGroups = &NumberLocale{Decimal: ",", Groups: []string{" ", "\u00a0", "\u202f"}}
+
+	// apostropheGroups is the Swiss format of "1'234.56".
+	apostropheGroups = &NumberLocale{Decimal: ".", Groups: []string{"'", "\u2019"}}
+)
+
+// numberLocales are the number formats of languages, and of the regions whose format differs from their language.
+var numberLocales = map[string]*NumberLocale{
+	"en": dotDecimal, "ja": dotDecimal, "zh": dotDecimal, "ko": dotDecimal, "he": dotDecimal, "th": dotDecimal,
+	"hi": dotDecimal, "es-mx": dotDecimal, "es-us": dotDecimal,
+
+	"de": commaDecimal, "es": commaDecimal, "it": commaDecimal, "nl": commaDecimal, "pt": commaDecimal,
+	"da": commaDecimal, "id": commaDecimal, "tr": commaDecimal, "el": commaDecimal, "ro": commaDecimal,
+	"hr": commaDecimal, "sl": commaDecimal, "sr": commaDecimal, "vi": commaDecimal,
+
+	"fr": spaceGroups, "ru": spaceGroups, "pl": spaceGroups, "cs": spaceGroups, "sk": spaceGroups, "sv": spaceGroups,
+	"fi": spaceGroups, "nb": spaceGroups, "no": spaceGroups, "uk": spaceGroups, "hu": spaceGroups, "bg": spaceGroups,
+	"lt": spaceGroups, "lv": spaceGroups, "et": spaceGroups, "pt-pt": spaceGroups,
+
+	"de-ch": apostropheGroups, "it-ch": apostropheGroups, "de-li": apostropheGroups,
+}
+
+// LookupNumberLocale returns the number format of a locale, e.g. "de" or "de-CH". Locales without a format of their
+// own have that of their language.
+func LookupNumberLocale(name string) (*NumberLocale, bool) {
+	tag := strings.ToLower(strings.ReplaceAll(name, "_", "-"))
+	if locale, ok := numberLocales[tag]; ok {
+		return locale, true
+	}
+
+	locale, ok := numberLocales[strings.Split(tag, "-")[0]]
+
+	return locale, ok
+}
+
+// validateNumberLocales will ensure that every column is parsed with a known locale.
+func validateNumberLocales(field string, columns map[string]string) error {
+	names := make([]string, 0, len(columns))
+	for column := range columns {
+		names = append(names, column)
+	}
+
+	sort.Strings(names)
+
+	for _, column := range names {
+		if _, ok := LookupNumberLocale(columns[column]); !ok {
+			return fmt.Errorf("%w: %s.%s %q is not a known locale", ErrInvalidNumberLocale, field, column,
+				columns[column])
+		}
+	}
+
+	return nil
+}
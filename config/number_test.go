@@ -0,0 +1,39 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLookupNumberLocale(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name    string
+		decimal string
+		ok      bool
+	}{
+		{name: "en-US", decimal: ".", ok: true},
+		{name: "de", decimal: ",", ok: true},
+		{name: "de_CH", decimal: ".", ok: true},
+		{name: "fr-CA", decimal: ",", ok: true},
+		{name: "xx"},
+	} {
+		locale, ok := LookupNumberLocale(tcase.name)
+		if ok != tcase.ok || (ok && locale.Decimal != tcase.decimal) {
+			t.Fatalf("%s: expected decimal %q (%t), got %+v (%t)", tcase.name, tcase.decimal, tcase.ok, locale, ok)
+		}
+	}
+
+	req := &Request{Endpoint: "/prices", NumberLocales: map[string]string{"price": "Klingon"}}
+	if err := req.validate(); !errors.Is(err, ErrInvalidNumberLocale) {
+		t.Fatalf("expected an invalid number locale, got %v", err)
+	}
+}
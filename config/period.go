@@ -0,0 +1,88 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// periodUnits are the suffixes accepted by "ParseTimeseriesPeriod", in seconds.
+var periodUnits = map[byte]float64{
+	's': 1,
+	'm': 60,
+	'h': 60 * 60,
+	'd': 24 * 60 * 60,
+	'w': 7 * 24 * 60 * 60,
+}
+
+// TimeseriesPeriod is the size of a timeseries chunk in seconds. It can be unmarshaled from an integer number of
+// seconds or from a human readable duration such as "5h", "1d", "1w" or "1h30m".
+type TimeseriesPeriod int32
+
+// ParseTimeseriesPeriod will parse an integer number of seconds or a duration string made up of one or more numbers
+// followed by one of the units "s", "m", "h", "d" or "w" into a "TimeseriesPeriod".
+func ParseTimeseriesPeriod(str string) (TimeseriesPeriod, error) {
+	str = strings.TrimSpace(str)
+
+	if secs, err := strconv.ParseInt(str, 10, 32); err == nil {
+		return TimeseriesPeriod(secs), nil
+	}
+
+	if str == "" {
+		return 0, fmt.Errorf("%w: %q", UnableToParseError("timeseries period"), str)
+	}
+
+	var total float64
+
+	for rest := str; rest != ""; {
+		idx := strings.IndexAny(rest, "smhdw")
+		if idx <= 0 {
+			return 0, fmt.Errorf("%w: %q", UnableToParseError("timeseries period"), str)
+		}
+
+		num, err := strconv.ParseFloat(rest[:idx], 64)
+		if err != nil || num < 0 {
+			return 0, fmt.Errorf("%w: %q", UnableToParseError("timeseries period"), str)
+		}
+
+		total += num * periodUnits[rest[idx]]
+		rest = rest[idx+1:]
+	}
+
+	if total != math.Trunc(total) || total > math.MaxInt32 {
+		return 0, fmt.Errorf("%w: %q must be a whole number of seconds", ErrInvalidTimeseriesPeriod, str)
+	}
+
+	return TimeseriesPeriod(total), nil
+}
+
+// Duration returns the period as a "time.Duration".
+func (period TimeseriesPeriod) Duration() time.Duration {
+	return time.Duration(period) * time.Second
+}
+
+// UnmarshalYAML will unmarshal an integer number of seconds or a duration string into a "TimeseriesPeriod".
+func (period *TimeseriesPeriod) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var str string
+	if err := unmarshal(&str); err != nil {
+		return err
+	}
+
+	parsed, err := ParseTimeseriesPeriod(str)
+	if err != nil {
+		return err
+	}
+
+	*period = parsed
+
+	return nil
+}
@@ -0,0 +1,136 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestParseTimeseriesPeriod(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		str  string
+		want TimeseriesPeriod
+		err  error
+	}{
+		{str: "18000", want: 18000},
+		{str: "30s", want: 30},
+		{str: "15m", want: 15 * 60},
+		{str: "5h", want: 5 * 60 * 60},
+		{str: "1d", want: 24 * 60 * 60},
+		{str: "1w", want: 7 * 24 * 60 * 60},
+		{str: "1h30m", want: 90 * 60},
+		{str: "1.5h", want: 90 * 60},
+		{str: "", err: ErrUnableToParse},
+		{str: "5x", err: ErrUnableToParse},
+		{str: "h", err: ErrUnableToParse},
+		{str: "1h30", err: ErrUnableToParse},
+		{str: "0.5s", err: ErrInvalidTimeseriesPeriod},
+	} {
+		tcase := tcase
+
+		t.Run(tcase.str, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := ParseTimeseriesPeriod(tcase.str)
+			if !errors.Is(err, tcase.err) {
+				t.Fatalf("expected error %v, got %v", tcase.err, err)
+			}
+
+			if got != tcase.want {
+				t.Fatalf("expected %d, got %d", tcase.want, got)
+			}
+		})
+	}
+}
+
+func TestTimeseriesPeriodYAML(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		data string
+		want time.Duration
+	}{
+		{data: "period: 18000", want: 5 * time.Hour},
+		{data: "period: 5h", want: 5 * time.Hour},
+		{data: `period: "1d"`, want: 24 * time.Hour},
+	} {
+		var timeseries Timeseries
+		if err := yaml.Unmarshal([]byte(tcase.data), &timeseries); err != nil {
+			t.Fatalf("failed to unmarshal %q: %v", tcase.data, err)
+		}
+
+		if got := timeseries.Period.Duration(); got != tcase.want {
+			t.Fatalf("expected %v for %q, got %v", tcase.want, tcase.data, got)
+		}
+	}
+}
+
+func TestTimeseriesValidateRange(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2022, 5, 10, 0, 0, 0, 0, time.UTC)
+
+	for _, tcase := range []struct {
+		name   string
+		period TimeseriesPeriod
+		end    time.Time
+		err    error
+	}{
+		{name: "multiple chunks", period: 18000, end: start.Add(24 * time.Hour)},
+		{name: "single chunk", period: 86400, end: start.Add(24 * time.Hour)},
+		{name: "empty range", period: 86400, end: start},
+		{name: "reversed range", period: 86400, end: start.Add(-time.Hour), err: ErrInvalidTimeseriesRange},
+		{name: "period too long", period: 86400, end: start.Add(time.Hour), err: ErrInvalidTimeseriesPeriod},
+	} {
+		tcase := tcase
+
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			timeseries := &Timeseries{Period: tcase.period}
+			if err := timeseries.ValidateRange(start, tcase.end); !errors.Is(err, tcase.err) {
+				t.Fatalf("expected error %v, got %v", tcase.err, err)
+			}
+		})
+	}
+}
+
+func TestTimeseriesPartialChunk(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2022, 5, 10, 0, 0, 0, 0, time.UTC)
+
+	for _, tcase := range []struct {
+		name    string
+		ts      *Timeseries
+		end     time.Time
+		partial time.Duration
+	}{
+		{name: "whole periods", ts: &Timeseries{Period: 21600}, end: start.Add(24 * time.Hour)},
+		{name: "partial chunk", ts: &Timeseries{Period: 18000}, end: start.Add(24 * time.Hour), partial: 4 * time.Hour},
+		{name: "empty range", ts: &Timeseries{Period: 18000}, end: start},
+		{name: "aligned", ts: &Timeseries{Period: 18000, Align: TimeseriesAlignDay}, end: start.Add(26 * time.Hour)},
+	} {
+		tcase := tcase
+
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			partial, ok := tcase.ts.PartialChunk(start, tcase.end)
+			if partial != tcase.partial || ok != (tcase.partial > 0) {
+				t.Fatalf("expected a partial chunk of %s, got %s (%t)", tcase.partial, partial, ok)
+			}
+		})
+	}
+}
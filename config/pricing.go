@@ -0,0 +1,62 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DefaultCurrency is the currency of a pricing without one.
+const DefaultCurrency = "USD"
+
+var currencyRegex = regexp.MustCompile(`^[A-Za-z]{3}$`)
+
+// Pricing is what a web API bills for the HTTP requests made for a request, used to estimate the spend of every run,
+// e.g. 2.5 USD per 1000 requests. Retries are billed as requests.
+type Pricing struct {
+	// Price is what the web API bills for every "per" HTTP requests.
+	Price float64 `yaml:"price"`
+
+	// Per is the number of HTTP requests that "price" is billed for. The default is 1.
+	Per int `yaml:"per"`
+
+	// Currency is the ISO 4217 code of the currency of "price", "USD" by default.
+	Currency string `yaml:"currency"`
+}
+
+// Spend returns the estimated spend of making "requests" HTTP requests.
+func (pricing *Pricing) Spend(requests int) float64 {
+	if pricing.Per <= 1 {
+		return pricing.Price * float64(requests)
+	}
+
+	return pricing.Price * float64(requests) / float64(pricing.Per)
+}
+
+// CurrencyOrDefault returns the upper case currency of the pricing, or "DefaultCurrency" if it has none.
+func (pricing *Pricing) CurrencyOrDefault() string {
+	if pricing.Currency == "" {
+		return DefaultCurrency
+	}
+
+	return strings.ToUpper(pricing.Currency)
+}
+
+func (pricing *Pricing) validate() error {
+	if pricing.Price < 0 || pricing.Per < 0 {
+		return fmt.Errorf("%w: pricing must not be negative", ErrInvalidPricing)
+	}
+
+	if pricing.Currency != "" && !currencyRegex.MatchString(pricing.Currency) {
+		return fmt.Errorf("%w: currency %q is not a three letter code", ErrInvalidPricing, pricing.Currency)
+	}
+
+	return nil
+}
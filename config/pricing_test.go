@@ -0,0 +1,61 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPricing(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name     string
+		pricing  Pricing
+		requests int
+		spend    float64
+		currency string
+		err      error
+	}{
+		{name: "per request", pricing: Pricing{Price: 0.01}, requests: 300, spend: 3, currency: "USD"},
+		{
+			name:     "per thousand requests",
+			pricing:  Pricing{Price: 2.5, Per: 1000, Currency: "eur"},
+			requests: 4000,
+			spend:    10,
+			currency: "EUR",
+		},
+		{name: "free", pricing: Pricing{}, requests: 10, currency: "USD"},
+		{name: "negative price", pricing: Pricing{Price: -1}, err: ErrInvalidPricing},
+		{name: "negative per", pricing: Pricing{Price: 1, Per: -1000}, err: ErrInvalidPricing},
+		{name: "invalid currency", pricing: Pricing{Price: 1, Currency: "dollars"}, err: ErrInvalidPricing},
+	} {
+		tcase := tcase
+
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			if err := tcase.pricing.validate(); !errors.Is(err, tcase.err) {
+				t.Fatalf("expected error %v, got %v", tcase.err, err)
+			}
+
+			if tcase.err != nil {
+				return
+			}
+
+			if spend := tcase.pricing.Spend(tcase.requests); spend != tcase.spend {
+				t.Fatalf("expected a spend of %g, got %g", tcase.spend, spend)
+			}
+
+			if currency := tcase.pricing.CurrencyOrDefault(); currency != tcase.currency {
+				t.Fatalf("expected currency %q, got %q", tcase.currency, currency)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+// Provenance are the columns of a record that where and when it was fetched are written to, for debugging and
+// lineage downstream. Columns that are left empty are not written.
+type Provenance struct {
+	// IngestedAt is the column that the time the response of the record was fetched is written to, as an RFC 3339
+	// timestamp in UTC.
+	IngestedAt string `yaml:"ingestedAt"`
+
+	// Endpoint is the column that the path of the request is written to, e.g. "/candles".
+	Endpoint string `yaml:"endpoint"`
+
+	// URL is the column that the URL of the request is written to, with its query, as "recordPages" records it.
+	URL string `yaml:"url"`
+
+	// Status is the column that the HTTP status code of the response is written to.
+	Status string `yaml:"status"`
+
+	// WorkerID is the column that the ID of the web worker that fetched the response is written to.
+	WorkerID string `yaml:"workerId"`
+
+	// RunID is the column that the ID of the run is written to, the UTC time that it started, e.g.
+	// "20221014T150405Z", which is also the ID of its "manifest".
+	RunID string `yaml:"runId"`
+
+	// Headers are the columns that response headers are written to, keyed by the name of the header, e.g.
+	// "X-Request-Id: request_id", so that the identifiers of the web API can be correlated with the records. Headers
+	// that the response does not have are written as null.
+	Headers map[string]string `yaml:"headers"`
+}
+
+func (prov *Provenance) validate(field string) error {
+	settings := make(map[string]string)
+
+	for _, col := range []struct{ setting, column string }{
+		{"ingestedAt", prov.IngestedAt},
+		{"endpoint", prov.Endpoint},
+		{"url", prov.URL},
+		{"status", prov.Status},
+		{"workerId", prov.WorkerID},
+		{"runId", prov.RunID},
+	} {
+		if col.column == "" {
+			continue
+		}
+
+		if other, ok := settings[col.column]; ok {
+			return fmt.Errorf("%w: %s.%s and %s.%s must be different columns", ErrInvalidProvenance, field, other,
+				field, col.setting)
+		}
+
+		settings[col.column] = col.setting
+	}
+
+	headers := make([]string, 0, len(prov.Headers))
+	for header := range prov.Headers {
+		headers = append(headers, header)
+	}
+
+	sort.Strings(headers)
+
+	canonical := make(map[string]string, len(headers))
+
+	for _, header := range headers {
+		setting, column := "headers."+header, prov.Headers[header]
+		if header == "" || column == "" {
+			return fmt.Errorf("%w: %s.headers must map header names to columns", ErrInvalidProvenance, field)
+		}
+
+		if other, ok := canonical[http.CanonicalHeaderKey(header)]; ok {
+			return fmt.Errorf("%w: %s.headers.%s and %s.headers.%s are the same header", ErrInvalidProvenance,
+				field, other, field, header)
+		}
+
+		canonical[http.CanonicalHeaderKey(header)] = header
+
+		if other, ok := settings[column]; ok {
+			return fmt.Errorf("%w: %s.%s and %s.%s must be different columns", ErrInvalidProvenance, field, other,
+				field, setting)
+		}
+
+		settings[column] = setting
+	}
+
+	if len(settings) == 0 {
+		return fmt.Errorf("%w: %s must set at least one column", ErrInvalidProvenance, field)
+	}
+
+	return nil
+}
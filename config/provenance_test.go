@@ -0,0 +1,58 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestProvenance(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name string
+		prov *Provenance
+		err  error
+	}{
+		{name: "no provenance"},
+		{name: "every column", prov: &Provenance{
+			IngestedAt: "ingested_at",
+			Endpoint:   "source_endpoint",
+			URL:        "source_url",
+			Status:     "status",
+			WorkerID:   "worker_id",
+			RunID:      "run_id",
+		}},
+		{name: "one column", prov: &Provenance{RunID: "run_id"}},
+		{name: "headers", prov: &Provenance{Headers: map[string]string{"X-Request-Id": "request_id", "ETag": "etag"}}},
+		{
+			name: "same header",
+			prov: &Provenance{Headers: map[string]string{"X-Request-Id": "request_id", "x-request-id": "id"}},
+			err:  ErrInvalidProvenance,
+		},
+		{
+			name: "header column",
+			prov: &Provenance{RunID: "id", Headers: map[string]string{"X-Request-Id": "id"}},
+			err:  ErrInvalidProvenance,
+		},
+		{name: "no header column", prov: &Provenance{Headers: map[string]string{"ETag": ""}}, err: ErrInvalidProvenance},
+		{name: "no columns", prov: &Provenance{}, err: ErrInvalidProvenance},
+		{name: "same column", prov: &Provenance{URL: "source", Endpoint: "source"}, err: ErrInvalidProvenance},
+	} {
+		req := &Request{Endpoint: "/candles", Provenance: tcase.prov}
+		if err := req.validate(); !errors.Is(err, tcase.err) {
+			t.Fatalf("%s: expected error %v, got %v", tcase.name, tcase.err, err)
+		}
+
+		table := &Table{Provenance: tcase.prov}
+		if err := table.validate("candles", nil); !errors.Is(err, tcase.err) {
+			t.Fatalf("%s: expected table error %v, got %v", tcase.name, tcase.err, err)
+		}
+	}
+}
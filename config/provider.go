@@ -0,0 +1,76 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/alpstable/gidari/internal/provider"
+)
+
+// applyProvider will fill in the settings of the provider profile of the configuration that it does not set itself:
+// the URL and rate limit of the web API, the headers of its version, and the names and layout of the range of
+// timeseries requests.
+func (cfg *Config) applyProvider() error {
+	if cfg.Provider == "" {
+		return nil
+	}
+
+	profile, ok := provider.Lookup(cfg.Provider)
+	if !ok {
+		return fmt.Errorf("%w: %q must be one of %s", ErrInvalidProvider, cfg.Provider,
+			strings.Join(provider.Names(), ", "))
+	}
+
+	if cfg.RawURL == "" {
+		cfg.RawURL = profile.URL
+	}
+
+	if cfg.RateLimitConfig == nil {
+		cfg.RateLimitConfig = new(RateLimitConfig)
+	}
+
+	if cfg.RateLimitConfig.Burst == nil {
+		burst := profile.Burst
+		cfg.RateLimitConfig.Burst = &burst
+	}
+
+	if cfg.RateLimitConfig.Period == nil {
+		period := profile.Period
+		cfg.RateLimitConfig.Period = &period
+	}
+
+	if version := cfg.APIVersion; version != nil && version.Header == "" {
+		version.Header = profile.VersionHeader
+		if version.ServedHeader == "" {
+			version.ServedHeader = profile.ServedVersionHeader
+		}
+	}
+
+	for _, req := range cfg.Requests {
+		if req.Timeseries == nil {
+			continue
+		}
+
+		if req.Timeseries.StartName == "" {
+			req.Timeseries.StartName = profile.Timeseries.StartName
+		}
+
+		if req.Timeseries.EndName == "" {
+			req.Timeseries.EndName = profile.Timeseries.EndName
+		}
+
+		if req.Timeseries.Layout == nil && profile.Timeseries.Layout != "" {
+			layout := profile.Timeseries.Layout
+			req.Timeseries.Layout = &layout
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,72 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestNewAppliesProvider(t *testing.T) {
+	t.Parallel()
+
+	t.Run("profile", func(t *testing.T) {
+		t.Parallel()
+
+		data := `
+version: 1
+provider: binance
+connectionStrings:
+  - mongodb://localhost:27017/db
+rateLimit:
+  burst: 5
+requests:
+  - endpoint: /api/v3/klines
+    timeseries:
+      period: 3600
+  - endpoint: /api/v3/aggTrades
+    timeseries:
+      period: 3600
+      startName: fromTime
+      layout: unix
+`
+
+		cfg, err := New(context.Background(), newTestConfigFile(t, data))
+		if err != nil {
+			t.Fatalf("failed to create config: %v", err)
+		}
+
+		if cfg.URL.String() != "https://api.binance.com" {
+			t.Fatalf("expected the URL of the provider, got %q", cfg.URL)
+		}
+
+		if *cfg.RateLimitConfig.Burst != 5 || *cfg.RateLimitConfig.Period == 0 {
+			t.Fatalf("expected the burst to be kept and the period to be filled, got %+v", cfg.RateLimitConfig)
+		}
+
+		klines := cfg.Requests[0].Timeseries
+		if klines.StartName != "startTime" || klines.EndName != "endTime" || klines.layout() != "unix_ms" {
+			t.Fatalf("expected the timeseries defaults of the provider, got %+v", klines)
+		}
+
+		trades := cfg.Requests[1].Timeseries
+		if trades.StartName != "fromTime" || trades.EndName != "endTime" || trades.layout() != "unix" {
+			t.Fatalf("expected the timeseries settings to take precedence, got %+v", trades)
+		}
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		t.Parallel()
+
+		data := "version: 1\nprovider: kraken\nconnectionStrings:\n  - mongodb://localhost:27017/db\n"
+		if _, err := New(context.Background(), newTestConfigFile(t, data)); !errors.Is(err, ErrInvalidProvider) {
+			t.Fatalf("expected error %v, got %v", ErrInvalidProvider, err)
+		}
+	})
+}
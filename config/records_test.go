@@ -0,0 +1,35 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRequestRecordsPath(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name   string
+		format string
+		path   string
+		err    error
+	}{
+		{name: "no path"},
+		{name: "path", path: "$.result.items"},
+		{name: "relative path", path: "data", format: ResponseFormatJSON},
+		{name: "invalid path", path: "result.items[x]", err: ErrInvalidRecordsPath},
+		{name: "not json", path: "data", format: ResponseFormatCSV, err: ErrInvalidRecordsPath},
+	} {
+		req := &Request{Endpoint: "/items", ResponseFormat: tcase.format, RecordsPath: tcase.path}
+		if err := req.validate(); !errors.Is(err, tcase.err) {
+			t.Fatalf("%s: expected error %v, got %v", tcase.name, tcase.err, err)
+		}
+	}
+}
@@ -8,33 +8,525 @@
 package config
 
 import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/alpstable/gidari/tools"
 	"golang.org/x/time/rate"
 )
 
+const (
+	// ResponseFormatJSON is a response body that is a single JSON document: an array of records or a record.
+	ResponseFormatJSON = "json"
+
+	// ResponseFormatNDJSON is a response body that is newline-delimited JSON, with a record on each line.
+	ResponseFormatNDJSON = "ndjson"
+
+	// ResponseFormatJSONLines is another name for "ResponseFormatNDJSON".
+	ResponseFormatJSONLines = "jsonl"
+
+	// ResponseFormatCSV is a response body of comma-separated values, with a record on each row.
+	ResponseFormatCSV = "csv"
+
+	// ResponseFormatXML is an XML response body, with a record for each element at the "recordPath".
+	ResponseFormatXML = "xml"
+)
+
 // Request is the information needed to query the web API for data to transport.
 type Request struct {
-	// Method is the HTTP(s) method used to construct the http request to fetch data for storage.
+	// Method is the HTTP(s) method used to construct the http request to fetch data for storage: "GET", "POST",
+	// "PUT", "PATCH", "DELETE" or "HEAD". The responses to "HEAD" requests have no data to write.
 	Method string `yaml:"method"`
 
 	// Endpoint is the fragment of the URL that will be used to request data from the API. This value can include
 	// query parameters.
 	Endpoint string `yaml:"endpoint"`
 
+	// Proxy is the proxy URL that the request is made through, in place of "proxy.url", or "direct" to make it
+	// without a proxy.
+	Proxy string `yaml:"proxy"`
+
+	// AcceptEncoding are the content encodings that the request advertises, in place of the "acceptEncoding" of
+	// the configuration.
+	AcceptEncoding []string `yaml:"acceptEncoding"`
+
+	// Timeout is how long each attempt of the request may take, e.g. "30s", from sending it to reading the last
+	// byte of its response, before it fails like a request that could not connect and is retried. The default is no
+	// timeout.
+	Timeout time.Duration `yaml:"timeout"`
+
 	// Query represent the query params to apply to the URL generated by the request.
 	Query map[string]string
 
-	// Timeseries indicates that the underlying data should be queries as a time series. This means that the
+	// Body is the JSON body to send with the request. Timeseries requests may target fields of the body with
+	// their start and end values.
+	Body map[string]interface{} `yaml:"body"`
+
+	// BodyTemplate is a Go template of the JSON body to send with the request, in place of "body", which is executed
+	// with "BodyTemplateData" for every request, e.g. with the bounds of its timeseries chunk.
+	BodyTemplate string `yaml:"bodyTemplate"`
+
+	// Timeseries indicates that the underlying data should be queried as a time series, in chunks of "period"
+	// between the start and end of the query.
 	Timeseries *Timeseries `yaml:"timeseries"`
 
+	// StopWhen are the conditions under which the remaining chunks of a timeseries request are skipped.
+	StopWhen *StopWhen `yaml:"stopWhen"`
+
 	// Table is the name of the table/collection to insert the data fetched from the web API.
 	Table string `yaml:"table"`
 
-	// Truncate before upserting on single request
+	// Truncate will truncate the table in the same transaction as the first load of the run, e.g. for a full
+	// refresh.
 	Truncate *bool `yaml:"truncate"`
 
+	// WriteMode is how the data of the request is written to its table: "upsert" (the default), "insert",
+	// "append", or "replace", which truncates the table at the start of the run and upserts into it.
+	WriteMode string `yaml:"writeMode" enum:"upsert,replace,insert,append"`
+
+	// ConflictKeys are the columns that identify a record for the "upsert" and "append" write modes. The default is
+	// the primary key of the table.
+	ConflictKeys []string `yaml:"conflictKeys"`
+
+	// OrderBy is a timestamp column of the records. If it is set, the records of the table are held until every
+	// request of the batch has been fetched, and are then written from the oldest to the latest, so that the latest
+	// record of each key wins even if the pages of the web API are out of chronological order.
+	OrderBy string `yaml:"orderBy"`
+
+	// DedupeKeys are the columns that identify a record within a run, e.g. "id". If they are set, the records of
+	// the table are held until every request of the batch has been fetched, and only the latest copy of each key is
+	// written, so that records repeated across pages are not written more than once.
+	DedupeKeys []string `yaml:"dedupeKeys"`
+
+	// Fields are the columns that the keys of the records are renamed to, keyed by the JSON key, e.g. "priceUsd:
+	// price_usd". Keys with dots are paths of nested values, e.g. "quote.USD.price: price", whose objects are still
+	// written as they are. Fields are mapped before any other setting is applied, so the other settings of the
+	// request name the columns that they are mapped to.
+	Fields map[string]string `yaml:"fields"`
+
+	// Flatten flattens the nested objects of the records into columns once their "fields" are mapped, e.g.
+	// "user.address.city" into "user_address_city", so that relational tables do not need a "clobColumn" for them.
+	// The other settings of the request name the flattened columns.
+	Flatten *Flatten `yaml:"flatten"`
+
+	// ChildTables write the arrays of objects of the records, e.g. the line items of an order, to tables of their
+	// own with a foreign key to the record, once their fields are mapped and flattened. The arrays are not written
+	// to the table of the request.
+	ChildTables []*ChildTable `yaml:"childTables"`
+
+	// IncludeFields are the only columns of the records that are written, once their "fields" are mapped, e.g. to
+	// leave out the metadata of a web API. The columns of "chunkColumns" are always written.
+	IncludeFields []string `yaml:"includeFields"`
+
+	// ExcludeFields are the columns of the records that are not written, once their "fields" are mapped. It cannot
+	// be set with "includeFields".
+	ExcludeFields []string `yaml:"excludeFields"`
+
+	// Transforms are the unit conversions applied to the columns of the records before they are written, keyed by
+	// column, e.g. "time: epochToRFC3339".
+	Transforms map[string]string `yaml:"transforms"`
+
+	// NumberLocales are the locales of the columns whose numbers are localized strings, keyed by column, e.g.
+	// "price: de" for "1.234,56". They are parsed into numbers before the transforms are applied.
+	NumberLocales map[string]string `yaml:"numberLocales"`
+
+	// Types are the types that the values of columns are coerced to once they are transformed, keyed by column:
+	// "int", "float", "bool", "timestamp" or "decimal(<precision>,<scale>)", e.g. "price: decimal(10,2)" for an
+	// API that returns numbers as strings. Empty strings and null are written as null.
+	Types map[string]string `yaml:"types"`
+
+	// OnTypeError is what is done with a record when one of its values cannot be coerced to its type: "error" fails
+	// the write (the default), "null" writes the value as null, and "skip" leaves the record out.
+	OnTypeError string `yaml:"onTypeError" enum:"error,null,skip"`
+
+	// Hooks are the names of the transform hooks, registered with "gidari.RegisterTransformHook", that are run on
+	// every record in order once its types are coerced, and before it is masked, encrypted and written.
+	Hooks []string `yaml:"hooks"`
+
+	// Masks redact the values of columns before they are written, once they are coerced to their types, keyed by
+	// column: "hash", "null" or "truncate(<length>)", e.g. "email: hash" for data that must not be stored in the
+	// clear. Null values are left as they are.
+	Masks map[string]string `yaml:"masks"`
+
+	// Encrypt encrypts the values of columns with a key of "encryptionKeys" before they are written, once they are
+	// masked, e.g. for regulated fields that must never be stored in plaintext. The columns that identify the
+	// records cannot be encrypted, since the ciphertext of a value changes every time it is written.
+	Encrypt *Encrypt `yaml:"encrypt"`
+
+	// TTL expires the records of the table a duration after the time in one of their columns, on storage that
+	// expires records natively, e.g. MongoDB. The expiry of the storage is configured before the table is first
+	// written to in a run.
+	TTL *TTL `yaml:"ttl"`
+
+	// Conditional sends the "ETag" and "Last-Modified" validators of the last response to the request as
+	// "If-None-Match" and "If-Modified-Since", so that a web API whose data has not changed can respond with "304 Not
+	// Modified", and nothing is written. The validators are stored in the "state.file" once the data of the response
+	// has been committed. Requests whose table is truncated are never conditional.
+	Conditional bool `yaml:"conditional"`
+
+	// FreshnessCheck is how a conditional request checks whether its data has changed before it is made, for web
+	// APIs that do not answer conditional requests. With "head", a "HEAD" request is made first, and the request is
+	// skipped if the validators of its response are those of the last response.
+	FreshnessCheck string `yaml:"freshnessCheck"`
+
+	// Provenance writes where and when each record was fetched to columns of the record: the time it was ingested,
+	// the endpoint and URL of the request, the status and headers of the response, and the IDs of the web worker and
+	// the run.
+	Provenance *Provenance `yaml:"provenance"`
+
+	// SurrogateKey generates a synthetic key for every record, written to a column of the record once it is
+	// transformed: a UUID version 7, ULID or xid, or a hash of some of its fields.
+	SurrogateKey *SurrogateKey `yaml:"surrogateKey"`
+
 	ClobColumn string `yaml:"clobColumn"`
 
+	// RecordsPath is the JSON path of the records within the response body, e.g. "$.result.items" for a response
+	// that wraps its records in an envelope. The default is the entire body.
+	RecordsPath string `yaml:"recordsPath"`
+
+	// ResponseFormat is how the response body is parsed: "json" for a single JSON document, which is the default,
+	// "ndjson" (also "jsonl") for a record on each line, "csv" for a record on each row, or "xml" for a record for
+	// each element at a path.
+	ResponseFormat string `yaml:"responseFormat" enum:"json,ndjson,jsonl,csv,xml"`
+
+	// CSV is how the rows of a "csv" response body are read into records.
+	CSV *CSVFormat `yaml:"csv"`
+
+	// XML is how the elements of an "xml" response body are read into records, which is required by that format.
+	XML *XMLFormat `yaml:"xml"`
+
+	// OnEmpty is what is done with a response without records: "write" it like any other, which is the default,
+	// write it and a "marker" row to the "<table>_empty" side table, "skip" it with a warning, or "fail" the request.
+	OnEmpty string `yaml:"onEmpty" enum:"write,marker,skip,fail"`
+
+	// ExpectedEmptyOK are the periods in which empty responses are expected, and written like any other regardless
+	// of "onEmpty". The period of a timeseries chunk is its range, and that of any other request is when it is made.
+	ExpectedEmptyOK EmptyWindows `yaml:"expectedEmptyOk"`
+
+	// RecordPages will record metadata for every page fetched by the request, such as the chunk boundaries, item
+	// count and response time, in a "<table>_pages" side table.
+	RecordPages bool `yaml:"recordPages"`
+
+	// ColumnStats will compute statistics of every column of the records that the run writes to the table, such as
+	// the share of nulls, the smallest and largest values and an estimate of the distinct values, in a
+	// "<table>_stats" side table.
+	ColumnStats bool `yaml:"columnStats"`
+
+	// ConnectionStrings are the sinks that the request is written to, which must be a subset of the top-level
+	// "connectionStrings". The default is to write to every sink.
+	ConnectionStrings []string `yaml:"connectionStrings"`
+
+	// Storage selects the sinks that the request is written to by their scheme, e.g. "mongodb" or "postgresql",
+	// in place of listing their "connectionStrings". Every connection string with a selected scheme is written to.
+	Storage []string `yaml:"storage"`
+
+	// Metrics are the numeric values extracted from the responses of the request, for the summary of the run and
+	// its "assertions".
+	Metrics []*Metric `yaml:"metrics"`
+
+	// Canary requests are made before every other request of the run, which are only made if each canary request
+	// succeeds and the "canaryAssertions" hold.
+	Canary bool `yaml:"canary"`
+
+	// Cost is what each HTTP request made for the request counts towards "limits.maxCost". The default is 1.
+	Cost float64 `yaml:"cost"`
+
+	// Pricing is what the web API bills for the HTTP requests made for the request, to report the estimated
+	// spend of every run and, with a state file, across runs.
+	Pricing *Pricing `yaml:"pricing"`
+
 	// Chunks of requests should share a rate limiter, probably all of them; inheriting the rate limiter from the
 	// root configuration.
 	RateLimiter *rate.Limiter
+
+	// Maintenance are the maintenance windows of the configuration that hold the request while they are open.
+	Maintenance []*MaintenanceWindow `yaml:"-"`
+
+	// AutoCreate is how the table of the request is created if it does not exist. It is nil unless the
+	// configuration sets "autoCreate".
+	AutoCreate *AutoCreate `yaml:"-"`
+
+	// SchemaEvolution is how the table of the request is changed for the fields of its records that the table does
+	// not have a column for. It is nil unless the configuration, or the table, sets "schemaEvolution".
+	SchemaEvolution *SchemaEvolution `yaml:"-"`
+
+	// PartitionKeys are the columns that the records of the request are partitioned by when its table is written
+	// with several transactions: its "conflictKeys", or else the primary keys of its table.
+	PartitionKeys []string `yaml:"-"`
+}
+
+// NDJSON returns true if the response body of the request is newline-delimited JSON.
+func (req *Request) NDJSON() bool {
+	return req.ResponseFormat == ResponseFormatNDJSON || req.ResponseFormat == ResponseFormatJSONLines
+}
+
+// CSVFormat returns how the rows of the response body of the request are read into records, or nil if the response
+// body is not CSV.
+func (req *Request) CSVFormat() *CSVFormat {
+	if req.ResponseFormat != ResponseFormatCSV {
+		return nil
+	}
+
+	if req.CSV == nil {
+		return new(CSVFormat)
+	}
+
+	return req.CSV
+}
+
+// XMLFormat returns how the elements of the response body of the request are read into records, or nil if the
+// response body is not XML.
+func (req *Request) XMLFormat() *XMLFormat {
+	if req.ResponseFormat != ResponseFormatXML {
+		return nil
+	}
+
+	return req.XML
+}
+
+func (req *Request) validateRecordsPath() error {
+	if req.RecordsPath == "" {
+		return nil
+	}
+
+	if req.ResponseFormat != "" && req.ResponseFormat != ResponseFormatJSON {
+		return fmt.Errorf("%w: recordsPath of %s is only used by the %q responseFormat", ErrInvalidRecordsPath,
+			req.Endpoint, ResponseFormatJSON)
+	}
+
+	if err := tools.ValidateJSONPath(req.RecordsPath); err != nil {
+		return fmt.Errorf("%w: recordsPath of %s: %v", ErrInvalidRecordsPath, req.Endpoint, err)
+	}
+
+	return nil
+}
+
+func (req *Request) validateXML() error {
+	switch {
+	case req.ResponseFormat == ResponseFormatXML && req.XML == nil:
+		return fmt.Errorf("%w: %s must set the recordPath of its XML response", ErrInvalidXML, req.Endpoint)
+	case req.XML == nil:
+		return nil
+	case req.ResponseFormat != ResponseFormatXML:
+		return fmt.Errorf("%w: xml of %s is only used by the %q responseFormat", ErrInvalidXML, req.Endpoint,
+			ResponseFormatXML)
+	default:
+		return req.XML.validate(req.Endpoint)
+	}
+}
+
+// StateKey uniquely identifies the request in the state store across runs.
+func (req *Request) StateKey() string {
+	return fmt.Sprintf("%s %s %s", req.Method, req.Endpoint, req.Table)
+}
+
+// RequestCost returns the cost of a single HTTP request made for the request, which defaults to 1.
+func (req *Request) RequestCost() float64 {
+	if req.Cost == 0 {
+		return 1
+	}
+
+	return req.Cost
+}
+
+// storageSinks returns the connection strings with one of the given schemes.
+func storageSinks(schemes, connectionStrings []string) []string {
+	var sinks []string
+
+	for _, dns := range connectionStrings {
+		for _, scheme := range schemes {
+			if strings.EqualFold(strings.Split(dns, "://")[0], scheme) {
+				sinks = append(sinks, dns)
+
+				break
+			}
+		}
+	}
+
+	return sinks
+}
+
+// validateStorage will ensure that every "storage" selector matches at least one of the top-level
+// "connectionStrings", so that a misspelled scheme does not silently write the request to every sink.
+func (req *Request) validateStorage(connectionStrings []string) error {
+	if len(req.Storage) == 0 {
+		return nil
+	}
+
+	if len(req.ConnectionStrings) != 0 {
+		return fmt.Errorf("%w: request %s sets both storage and connectionStrings", ErrInvalidSink, req.Endpoint)
+	}
+
+	for _, scheme := range req.Storage {
+		if len(storageSinks([]string{scheme}, connectionStrings)) == 0 {
+			return fmt.Errorf("%w: storage %q of request %s matches none of the connectionStrings", ErrInvalidSink,
+				scheme, req.Endpoint)
+		}
+	}
+
+	return nil
+}
+
+func (req *Request) validate() error {
+	if err := validateWriteMode("writeMode of "+req.Endpoint, req.WriteMode); err != nil {
+		return err
+	}
+
+	if err := req.validateMethod(); err != nil {
+		return err
+	}
+
+	switch req.ResponseFormat {
+	case "", ResponseFormatJSON, ResponseFormatNDJSON, ResponseFormatJSONLines, ResponseFormatCSV, ResponseFormatXML:
+	default:
+		return fmt.Errorf("%w: responseFormat %q of %s must be one of %q, %q, %q, %q or %q", ErrInvalidResponseFormat,
+			req.ResponseFormat, req.Endpoint, ResponseFormatJSON, ResponseFormatNDJSON, ResponseFormatJSONLines,
+			ResponseFormatCSV, ResponseFormatXML)
+	}
+
+	if req.CSV != nil {
+		if req.ResponseFormat != ResponseFormatCSV {
+			return fmt.Errorf("%w: csv of %s is only used by the %q responseFormat", ErrInvalidCSV, req.Endpoint,
+				ResponseFormatCSV)
+		}
+
+		if err := req.CSV.validate(req.Endpoint); err != nil {
+			return err
+		}
+	}
+
+	if err := req.validateRecordsPath(); err != nil {
+		return err
+	}
+
+	if err := req.validateXML(); err != nil {
+		return err
+	}
+
+	if err := req.validateOnEmpty(); err != nil {
+		return err
+	}
+
+	if err := req.validateProxy(); err != nil {
+		return err
+	}
+
+	if req.Timeout < 0 {
+		return fmt.Errorf("%w: timeout of %s must not be negative", ErrInvalidTimeout, req.Endpoint)
+	}
+
+	if err := validateAcceptEncoding(fmt.Sprintf("acceptEncoding of %s", req.Endpoint), req.AcceptEncoding); err != nil {
+		return err
+	}
+
+	if req.WriteMode == WriteModeInsert && len(req.ConflictKeys) != 0 {
+		return fmt.Errorf("%w: conflictKeys of %s are not used by the %q write mode", ErrInvalidWriteMode,
+			req.Endpoint, WriteModeInsert)
+	}
+
+	if err := validateFields(fmt.Sprintf("fields of %s", req.Endpoint), req.Fields); err != nil {
+		return err
+	}
+
+	if req.Flatten != nil {
+		if err := req.Flatten.validate(fmt.Sprintf("flatten of %s", req.Endpoint)); err != nil {
+			return err
+		}
+	}
+
+	if err := validateDedupeKeys(fmt.Sprintf("dedupeKeys of %s", req.Endpoint), req.DedupeKeys); err != nil {
+		return err
+	}
+
+	if err := validateChildTables(fmt.Sprintf("childTables of %s", req.Endpoint), req.Table, req.ChildTables); err != nil {
+		return err
+	}
+
+	if err := validateFieldFilters(req.Endpoint, req.IncludeFields, req.ExcludeFields); err != nil {
+		return err
+	}
+
+	field := fmt.Sprintf("numberLocales of %s", req.Endpoint)
+	if err := validateNumberLocales(field, req.NumberLocales); err != nil {
+		return err
+	}
+
+	if err := validateTransforms(fmt.Sprintf("transforms of %s", req.Endpoint), req.Transforms); err != nil {
+		return err
+	}
+
+	if err := validateTypes(fmt.Sprintf("types of %s", req.Endpoint), req.Types, req.OnTypeError); err != nil {
+		return err
+	}
+
+	if err := validateMasks(fmt.Sprintf("masks of %s", req.Endpoint), req.Masks); err != nil {
+		return err
+	}
+
+	if err := validateHooks(fmt.Sprintf("hooks of %s", req.Endpoint), req.Hooks); err != nil {
+		return err
+	}
+
+	if req.TTL != nil {
+		if err := req.TTL.validate(fmt.Sprintf("ttl of %s", req.Endpoint)); err != nil {
+			return err
+		}
+	}
+
+	if req.Provenance != nil {
+		if err := req.Provenance.validate(fmt.Sprintf("provenance of %s", req.Endpoint)); err != nil {
+			return err
+		}
+	}
+
+	if req.SurrogateKey != nil {
+		if err := req.SurrogateKey.validate(fmt.Sprintf("surrogateKey of %s", req.Endpoint)); err != nil {
+			return err
+		}
+	}
+
+	// Only GET requests have validators that a web API can check.
+	if req.Conditional && req.Method != "" && !strings.EqualFold(req.Method, http.MethodGet) {
+		return fmt.Errorf("%w: %s is a %s request, only GET requests can be conditional", ErrInvalidConditional,
+			req.Endpoint, req.Method)
+	}
+
+	if req.Encrypt != nil {
+		field := fmt.Sprintf("encrypt of %s", req.Endpoint)
+		if err := req.Encrypt.validate(field, req.plaintextColumns(), req.Masks); err != nil {
+			return err
+		}
+	}
+
+	if req.Timeseries != nil {
+		if err := req.Timeseries.validate(); err != nil {
+			return err
+		}
+	}
+
+	if req.StopWhen != nil {
+		if err := req.StopWhen.validate(); err != nil {
+			return err
+		}
+	}
+
+	for _, metric := range req.Metrics {
+		if err := metric.validate(); err != nil {
+			return err
+		}
+	}
+
+	if req.Cost < 0 {
+		return fmt.Errorf("%w: cost of %s must not be negative", ErrInvalidLimits, req.Endpoint)
+	}
+
+	if req.Pricing != nil {
+		if err := req.Pricing.validate(); err != nil {
+			return fmt.Errorf("%s: %w", req.Endpoint, err)
+		}
+	}
+
+	return nil
 }
@@ -0,0 +1,51 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultResponseCacheDir is the directory that responses are cached in when "responseCache.dir" is not set.
+const DefaultResponseCacheDir = "gidari-cache"
+
+// ResponseCacheConfig configures the on-disk cache of HTTP responses, so that a configuration can be run again
+// during development or testing without fetching from the web API again.
+type ResponseCacheConfig struct {
+	// Dir is the directory that the responses are cached in. The default is "DefaultResponseCacheDir".
+	Dir string `yaml:"dir"`
+
+	// TTL is how long a cached response is used for once it has been fetched, e.g. "1h".
+	TTL string `yaml:"ttl"`
+}
+
+func (cache *ResponseCacheConfig) validate() error {
+	if ttl, err := time.ParseDuration(cache.TTL); err != nil || ttl <= 0 {
+		return fmt.Errorf("%w: ttl must be a positive duration, e.g. \"1h\", got %q", ErrInvalidResponseCache,
+			cache.TTL)
+	}
+
+	return nil
+}
+
+// Directory returns the directory that the responses are cached in, defaulting to "DefaultResponseCacheDir".
+func (cache *ResponseCacheConfig) Directory() string {
+	if cache.Dir == "" {
+		return DefaultResponseCacheDir
+	}
+
+	return cache.Dir
+}
+
+// Duration returns how long a cached response is used for.
+func (cache *ResponseCacheConfig) Duration() time.Duration {
+	ttl, _ := time.ParseDuration(cache.TTL)
+
+	return ttl
+}
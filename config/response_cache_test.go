@@ -0,0 +1,48 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestResponseCache(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name  string
+		cache *ResponseCacheConfig
+		dir   string
+		ttl   time.Duration
+		err   error
+	}{
+		{name: "defaults", cache: &ResponseCacheConfig{TTL: "1h"}, dir: DefaultResponseCacheDir, ttl: time.Hour},
+		{name: "dir", cache: &ResponseCacheConfig{Dir: "cache", TTL: "10m"}, dir: "cache", ttl: 10 * time.Minute},
+		{name: "no ttl", cache: &ResponseCacheConfig{}, err: ErrInvalidResponseCache},
+		{name: "not a duration", cache: &ResponseCacheConfig{TTL: "1d"}, err: ErrInvalidResponseCache},
+		{name: "negative", cache: &ResponseCacheConfig{TTL: "-1h"}, err: ErrInvalidResponseCache},
+	} {
+		if err := tcase.cache.validate(); !errors.Is(err, tcase.err) {
+			t.Fatalf("%s: expected error %v, got %v", tcase.name, tcase.err, err)
+		}
+
+		if tcase.err != nil {
+			continue
+		}
+
+		if dir := tcase.cache.Directory(); dir != tcase.dir {
+			t.Fatalf("%s: expected directory %q, got %q", tcase.name, tcase.dir, dir)
+		}
+
+		if ttl := tcase.cache.Duration(); ttl != tcase.ttl {
+			t.Fatalf("%s: expected a ttl of %s, got %s", tcase.name, tcase.ttl, ttl)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+//go:generate go run ./internal/schemadocs/gen
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// schemaDraft is the JSON Schema draft of the schema of the configuration, which editors support the most widely.
+const schemaDraft = "http://json-schema.org/draft-07/schema#"
+
+// schemaType is a configuration type that is not decoded from YAML as its Go type suggests, e.g. a size that is
+// written as "2GiB", and so describes its own JSON Schema.
+type schemaType interface {
+	jsonSchema() map[string]interface{}
+}
+
+func (ByteSize) jsonSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type":    []string{"string", "integer"},
+		"pattern": `^\s*\d+(\.\d+)?\s*([KMGT](i?B)?|B)?\s*$`,
+	}
+}
+
+func (TimeseriesPeriod) jsonSchema() map[string]interface{} {
+	return map[string]interface{}{"type": []string{"string", "integer"}}
+}
+
+// schemaBuilder builds the definitions of the struct types of a JSON Schema, which the properties refer to so that
+// types can be nested in themselves.
+type schemaBuilder struct {
+	pkgPath     string
+	definitions map[string]interface{}
+}
+
+// Schema returns the JSON Schema of the configuration file, generated from the configuration types, for editors to
+// validate and autocomplete configuration files. The descriptions are the doc comments of the types and their
+// fields. Only the fields that can be set in a configuration file are described, and unknown keys are invalid.
+func Schema() ([]byte, error) {
+	cfgType := reflect.TypeOf(Config{})
+	builder := &schemaBuilder{pkgPath: cfgType.PkgPath(), definitions: make(map[string]interface{})}
+
+	root := builder.object(cfgType)
+	root["$schema"] = schemaDraft
+	root["title"] = "Gidari configuration"
+	root["definitions"] = builder.definitions
+
+	data, err := json.MarshalIndent(root, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the configuration schema: %w", err)
+	}
+
+	return append(data, '\n'), nil
+}
+
+// schema returns the JSON Schema of the values of a type, or nil if the type cannot be set in a configuration file,
+// e.g. a function or a type of another package.
+func (builder *schemaBuilder) schema(typ reflect.Type) map[string]interface{} {
+	if typ.Implements(reflect.TypeOf((*schemaType)(nil)).Elem()) {
+		return reflect.Zero(typ).Interface().(schemaType).jsonSchema()
+	}
+
+	switch typ {
+	case reflect.TypeOf(time.Duration(0)):
+		return map[string]interface{}{"type": []string{"string", "integer"}}
+	case reflect.TypeOf(time.Time{}):
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	switch typ.Kind() {
+	case reflect.Ptr:
+		return builder.schema(typ.Elem())
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		// YAML decodes any scalar into a string, e.g. the "page: 5" of a query.
+		return map[string]interface{}{"type": []string{"string", "number", "boolean"}}
+	case reflect.Slice, reflect.Array:
+		items := builder.schema(typ.Elem())
+		if items == nil {
+			return nil
+		}
+
+		return map[string]interface{}{"type": "array", "items": items}
+	case reflect.Map:
+		values := builder.schema(typ.Elem())
+		if values == nil || typ.Key().Kind() != reflect.String {
+			return nil
+		}
+
+		return map[string]interface{}{"type": "object", "additionalProperties": values}
+	case reflect.Interface:
+		if typ.NumMethod() > 0 {
+			return nil
+		}
+
+		return map[string]interface{}{}
+	case reflect.Struct:
+		return builder.ref(typ)
+	default:
+		return nil
+	}
+}
+
+// ref returns a reference to the definition of a struct type of the configuration package, defining it the first
+// time it is referred to.
+func (builder *schemaBuilder) ref(typ reflect.Type) map[string]interface{} {
+	if typ.PkgPath() != builder.pkgPath || typ.Name() == "" {
+		return nil
+	}
+
+	if _, ok := builder.definitions[typ.Name()]; !ok {
+		// The definition is reserved before the fields are described, for types that are nested in themselves.
+		builder.definitions[typ.Name()] = nil
+		builder.definitions[typ.Name()] = builder.object(typ)
+	}
+
+	return map[string]interface{}{"$ref": "#/definitions/" + typ.Name()}
+}
+
+// object returns the JSON Schema of the YAML mapping of a struct type.
+func (builder *schemaBuilder) object(typ reflect.Type) map[string]interface{} {
+	properties := make(map[string]interface{})
+	builder.properties(typ, properties)
+
+	object := map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+
+	if doc, ok := schemaDocs[typ.Name()]; ok {
+		object["description"] = doc
+	}
+
+	return object
+}
+
+// properties will add the properties of the fields of a struct type, named as YAML decodes them, to "properties".
+// The fields of inlined structs are added as if they were fields of the struct.
+func (builder *schemaBuilder) properties(typ reflect.Type, properties map[string]interface{}) {
+	for idx := 0; idx < typ.NumField(); idx++ {
+		field := typ.Field(idx)
+		if !field.IsExported() {
+			continue
+		}
+
+		tag := strings.Split(field.Tag.Get("yaml"), ",")
+		if tag[0] == "-" {
+			continue
+		}
+
+		if inlined(tag) {
+			fieldType := field.Type
+			if fieldType.Kind() == reflect.Ptr {
+				fieldType = fieldType.Elem()
+			}
+
+			builder.properties(fieldType, properties)
+
+			continue
+		}
+
+		prop := builder.schema(field.Type)
+		if prop == nil {
+			continue
+		}
+
+		if doc, ok := schemaDocs[typ.Name()+"."+field.Name]; ok {
+			// A reference cannot be described in draft 7, so it is wrapped.
+			if _, isRef := prop["$ref"]; isRef {
+				prop = map[string]interface{}{"allOf": []interface{}{prop}}
+			}
+
+			prop["description"] = doc
+		}
+
+		if enum := field.Tag.Get("enum"); enum != "" {
+			prop["enum"] = strings.Split(enum, ",")
+		}
+
+		name := tag[0]
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+
+		properties[name] = prop
+	}
+}
+
+// inlined returns true if the options of a YAML tag inline the fields of a struct into the struct that has it.
+func inlined(tag []string) bool {
+	for _, opt := range tag[1:] {
+		if opt == "inline" {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,125 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/alpstable/gidari/config/internal/schemadocs"
+)
+
+func TestSchema(t *testing.T) {
+	t.Parallel()
+
+	data, err := Schema()
+	if err != nil {
+		t.Fatalf("failed to generate schema: %v", err)
+	}
+
+	var schema struct {
+		Schema      string                            `json:"$schema"`
+		Properties  map[string]map[string]interface{} `json:"properties"`
+		Definitions map[string]struct {
+			Description string                            `json:"description"`
+			Properties  map[string]map[string]interface{} `json:"properties"`
+		} `json:"definitions"`
+	}
+
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("failed to decode schema: %v", err)
+	}
+
+	if schema.Schema != schemaDraft {
+		t.Fatalf("expected draft %q, got %q", schemaDraft, schema.Schema)
+	}
+
+	for _, name := range []string{"logger", "resume", "clock", "cassette", "httpclient", "stgconstructor"} {
+		if _, ok := schema.Properties[name]; ok {
+			t.Fatalf("%s: expected no property, got %v", name, schema.Properties[name])
+		}
+	}
+
+	for _, tcase := range []struct {
+		name string
+		prop map[string]interface{}
+		key  string
+		want interface{}
+	}{
+		{
+			name: "reference",
+			prop: schema.Properties["rateLimit"],
+			key:  "$ref",
+			want: "#/definitions/RateLimitConfig",
+		},
+		{
+			name: "array",
+			prop: schema.Properties["requests"],
+			key:  "items",
+			want: map[string]interface{}{"$ref": "#/definitions/Request"},
+		},
+		{
+			name: "description",
+			prop: schema.Properties["autoCreate"],
+			key:  "description",
+			want: schemaDocs["Config.AutoCreate"],
+		},
+		{
+			name: "byte size",
+			prop: schema.Properties["maxMemory"],
+			key:  "type",
+			want: []interface{}{"string", "integer"},
+		},
+		{
+			name: "untagged",
+			prop: schema.Properties["truncate"],
+			key:  "type",
+			want: "boolean",
+		},
+		{
+			name: "enum",
+			prop: schema.Definitions["Request"].Properties["writeMode"],
+			key:  "enum",
+			want: []interface{}{"upsert", "replace", "insert", "append"},
+		},
+		{
+			name: "period",
+			prop: schema.Definitions["Timeseries"].Properties["period"],
+			key:  "type",
+			want: []interface{}{"string", "integer"},
+		},
+	} {
+		if got := tcase.prop[tcase.key]; !reflect.DeepEqual(got, tcase.want) {
+			t.Fatalf("%s: expected %s %v, got %v", tcase.name, tcase.key, tcase.want, got)
+		}
+	}
+
+	if schema.Definitions["Request"].Description != schemaDocs["Request"] {
+		t.Fatalf("expected the description of Request, got %q", schema.Definitions["Request"].Description)
+	}
+}
+
+func TestSchemaDocs(t *testing.T) {
+	t.Parallel()
+
+	want, err := schemadocs.Generate(".")
+	if err != nil {
+		t.Fatalf("failed to generate schema docs: %v", err)
+	}
+
+	got, err := os.ReadFile("schema_docs.go")
+	if err != nil {
+		t.Fatalf("failed to read schema docs: %v", err)
+	}
+
+	if string(got) != string(want) {
+		t.Fatalf("schema_docs.go is out of date with the doc comments, run \"go generate ./config\"")
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewSource(t *testing.T) {
+	t.Parallel()
+
+	data := "url: https://example.com\nrateLimit:\n  burst: 1\n  period: 1\nconnectionStrings: [mongodb://localhost]\n" +
+		"requests:\n  - endpoint: /trades\n"
+
+	file := newTestConfigFile(t, data)
+
+	cfg, err := New(context.Background(), file)
+	if err != nil {
+		t.Fatalf("failed to create config: %v", err)
+	}
+
+	path, sum := cfg.Source()
+	if !filepath.IsAbs(path) || path != file.Name() {
+		t.Fatalf("expected the absolute path %s, got %s", file.Name(), path)
+	}
+
+	if want := sha256.Sum256([]byte(data)); sum != hex.EncodeToString(want[:]) {
+		t.Fatalf("expected the SHA-256 %x, got %s", want, sum)
+	}
+
+	other, err := New(context.Background(), newTestConfigFile(t, data+"    table: trades\n"))
+	if err != nil {
+		t.Fatalf("failed to create config: %v", err)
+	}
+
+	if _, otherSum := other.Source(); otherSum == sum {
+		t.Fatalf("expected a changed configuration to have another SHA-256")
+	}
+}
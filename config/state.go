@@ -0,0 +1,44 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import "fmt"
+
+// StateConfig configures where the transport persists state between runs.
+type StateConfig struct {
+	// File is the path of the JSON file that watermarks are stored in. When set, each timeseries request records
+	// the end of the last chunk it ingested, and the next run starts from there instead of the configured start.
+	File string `yaml:"file"`
+}
+
+const (
+	// DefaultCheckpointFile is the checkpoint file used when resuming without a configured checkpoint file.
+	DefaultCheckpointFile = "gidari.checkpoint.json"
+
+	// DefaultCheckpointEvery is the default number of flattened requests committed between checkpoints.
+	DefaultCheckpointEvery = 100
+)
+
+// CheckpointConfig configures how the progress of a run is persisted so that an interrupted run can be resumed.
+type CheckpointConfig struct {
+	// File is the path of the JSON file that the completed requests of a run are recorded in. The file is removed
+	// once the run completes.
+	File string `yaml:"file"`
+
+	// Every is the number of flattened requests to commit to storage between checkpoints. Smaller values lose
+	// less work when a run is interrupted, at the cost of more frequent commits.
+	Every int `yaml:"every"`
+}
+
+func (checkpoint *CheckpointConfig) validate() error {
+	if checkpoint.Every < 0 {
+		return fmt.Errorf("%w: every must not be negative", ErrInvalidCheckpoint)
+	}
+
+	return nil
+}
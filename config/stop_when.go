@@ -0,0 +1,41 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+// StopWhen are the conditions under which the remaining chunks of a timeseries request are no longer fetched. This is
+// useful for APIs that never return an explicit "no more data" signal. Conditions are checked as each chunk is
+// received, so chunks that are already in-flight when a condition is met will still be stored.
+type StopWhen struct {
+	// Empty will stop the request once a chunk returns no records.
+	Empty bool `yaml:"empty"`
+
+	// Field is a JSON path into each record, e.g. "$.price", whose numeric value is compared to "Above" and
+	// "Below".
+	Field string `yaml:"field"`
+
+	// Above will stop the request once the "Field" value of any record is greater than this threshold.
+	Above *float64 `yaml:"above"`
+
+	// Below will stop the request once the "Field" value of any record is less than this threshold.
+	Below *float64 `yaml:"below"`
+
+	// MaxRows will stop the request once this many records have been received across all chunks.
+	MaxRows int `yaml:"maxRows"`
+}
+
+func (stop *StopWhen) validate() error {
+	if stop.Field == "" && (stop.Above != nil || stop.Below != nil) {
+		return MissingConfigFieldError("stopWhen.field")
+	}
+
+	if stop.Field != "" && stop.Above == nil && stop.Below == nil {
+		return MissingConfigFieldError("stopWhen.above or stopWhen.below")
+	}
+
+	return nil
+}
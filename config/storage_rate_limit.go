@@ -0,0 +1,106 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// StorageRateLimit caps the rate that records are written to each storage target, e.g. so that a backfill does not
+// saturate a shared production database, however fast the web API is fetched. Every target is limited on its own,
+// across the requests and transactions that write to it.
+type StorageRateLimit struct {
+	// RecordsPerSecond is the largest number of records per second that are written to each storage target that
+	// is not in "sinks". Those targets are not limited if it is zero.
+	RecordsPerSecond float64 `yaml:"recordsPerSecond"`
+
+	// Burst is the number of records that can be written at once before the rate applies. It defaults to a second
+	// of records, and larger upserts are written once the rate allows every burst of them.
+	Burst int `yaml:"burst"`
+
+	// Sinks are the records per second of single storage targets, keyed by their connection string or by their
+	// scheme, e.g. "postgresql", with connection strings taking precedence. Targets are not limited if it is zero.
+	Sinks map[string]float64 `yaml:"sinks"`
+}
+
+func (limit *StorageRateLimit) validate(connectionStrings []string) error {
+	if limit.RecordsPerSecond < 0 || math.IsInf(limit.RecordsPerSecond, 0) || math.IsNaN(limit.RecordsPerSecond) {
+		return fmt.Errorf("%w: storageRateLimit.recordsPerSecond must be a number that is not negative",
+			ErrInvalidStorageRateLimit)
+	}
+
+	if limit.Burst < 0 {
+		return fmt.Errorf("%w: storageRateLimit.burst must not be negative", ErrInvalidStorageRateLimit)
+	}
+
+	sinks := make([]string, 0, len(limit.Sinks))
+	for sink := range limit.Sinks {
+		sinks = append(sinks, sink)
+	}
+
+	sort.Strings(sinks)
+
+	for _, sink := range sinks {
+		rps := limit.Sinks[sink]
+		if rps < 0 || math.IsInf(rps, 0) || math.IsNaN(rps) {
+			return fmt.Errorf("%w: storageRateLimit.sinks.%s must be a number that is not negative",
+				ErrInvalidStorageRateLimit, sink)
+		}
+
+		if !limitsSink(sink, connectionStrings) {
+			return fmt.Errorf("%w: storageRateLimit.sinks.%s is neither one of the connectionStrings nor their "+
+				"scheme", ErrInvalidStorageRateLimit, sink)
+		}
+	}
+
+	return nil
+}
+
+// limitsSink returns true if a key of "sinks" is one of the connection strings, or the scheme of one of them.
+func limitsSink(sink string, connectionStrings []string) bool {
+	for _, dns := range connectionStrings {
+		if dns == sink {
+			return true
+		}
+	}
+
+	return len(storageSinks([]string{sink}, connectionStrings)) != 0
+}
+
+// RecordsPerSecondOf returns the records per second that are written to the storage target of a connection string,
+// or zero if it is not limited.
+func (limit *StorageRateLimit) RecordsPerSecondOf(dns string) float64 {
+	if limit == nil {
+		return 0
+	}
+
+	if rps, ok := limit.Sinks[dns]; ok {
+		return rps
+	}
+
+	for sink, rps := range limit.Sinks {
+		if strings.EqualFold(strings.Split(dns, "://")[0], sink) {
+			return rps
+		}
+	}
+
+	return limit.RecordsPerSecond
+}
+
+// BurstOf returns the number of records that can be written at once to a storage target that is limited to "rps"
+// records per second.
+func (limit *StorageRateLimit) BurstOf(rps float64) int {
+	if limit != nil && limit.Burst > 0 {
+		return limit.Burst
+	}
+
+	return int(math.Max(1, math.Ceil(rps)))
+}
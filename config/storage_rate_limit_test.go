@@ -0,0 +1,49 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestStorageRateLimit(t *testing.T) {
+	t.Parallel()
+
+	sinks := []string{"postgresql://db/prod", "mongodb://db/prod"}
+
+	for _, tcase := range []struct {
+		name  string
+		limit StorageRateLimit
+		err   error
+	}{
+		{name: "every sink", limit: StorageRateLimit{RecordsPerSecond: 500}},
+		{name: "by scheme", limit: StorageRateLimit{Sinks: map[string]float64{"PostgreSQL": 500}}},
+		{name: "by connection string", limit: StorageRateLimit{Sinks: map[string]float64{"mongodb://db/prod": 0}}},
+		{name: "negative", limit: StorageRateLimit{RecordsPerSecond: -1}, err: ErrInvalidStorageRateLimit},
+		{name: "negative burst", limit: StorageRateLimit{Burst: -1}, err: ErrInvalidStorageRateLimit},
+		{
+			name:  "unknown sink",
+			limit: StorageRateLimit{Sinks: map[string]float64{"mysql": 500}},
+			err:   ErrInvalidStorageRateLimit,
+		},
+	} {
+		if err := tcase.limit.validate(sinks); !errors.Is(err, tcase.err) {
+			t.Fatalf("%s: expected error %v, got %v", tcase.name, tcase.err, err)
+		}
+	}
+
+	limit := &StorageRateLimit{RecordsPerSecond: 0.5, Sinks: map[string]float64{"mongodb": 250}}
+	if rps := limit.RecordsPerSecondOf("postgresql://db/prod"); rps != 0.5 || limit.BurstOf(rps) != 1 {
+		t.Fatalf("expected the default rate and a burst of one record, got %v and %d", rps, limit.BurstOf(rps))
+	}
+
+	if rps := limit.RecordsPerSecondOf("mongodb://db/prod"); rps != 250 || limit.BurstOf(rps) != 250 {
+		t.Fatalf("expected the rate of the scheme and a second of records, got %v and %d", rps, limit.BurstOf(rps))
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import "fmt"
+
+const (
+	// SurrogateKeyUUIDv7 generates a UUID version 7, which is ordered by the time it was generated.
+	SurrogateKeyUUIDv7 = "uuidv7"
+
+	// SurrogateKeyULID generates a ULID, 26 characters of Crockford's base32 ordered by the time it was generated.
+	SurrogateKeyULID = "ulid"
+
+	// SurrogateKeyXID generates an xid, 20 characters of base32 ordered by the second it was generated.
+	SurrogateKeyXID = "xid"
+
+	// SurrogateKeyHash generates the hex-encoded SHA-256 of the "fields" of the record, so that the same record
+	// always has the same key.
+	SurrogateKeyHash = "hash"
+)
+
+// SurrogateKey is a column of synthetic keys, generated for every record that is written, for tables that need a
+// primary key that the web API does not supply.
+type SurrogateKey struct {
+	// Column is the column that the key is written to, replacing any value of the record.
+	Column string `yaml:"column"`
+
+	// Generator is how the key is generated: "uuidv7", "ulid", "xid" or "hash".
+	Generator string `yaml:"generator" enum:"uuidv7,ulid,xid,hash"`
+
+	// Fields are the columns of the record that the "hash" generator hashes, once they are transformed. A column
+	// that a record does not have is hashed as null.
+	Fields []string `yaml:"fields"`
+}
+
+func (key *SurrogateKey) validate(field string) error {
+	if key.Column == "" {
+		return fmt.Errorf("%w: %s must set a column", ErrInvalidSurrogateKey, field)
+	}
+
+	switch key.Generator {
+	case SurrogateKeyUUIDv7, SurrogateKeyULID, SurrogateKeyXID:
+		if len(key.Fields) != 0 {
+			return fmt.Errorf("%w: %s only hashes fields with the %q generator", ErrInvalidSurrogateKey, field,
+				SurrogateKeyHash)
+		}
+
+		return nil
+	case SurrogateKeyHash:
+	default:
+		return fmt.Errorf("%w: %s.generator must be one of %q, %q, %q or %q, got %q", ErrInvalidSurrogateKey,
+			field, SurrogateKeyUUIDv7, SurrogateKeyULID, SurrogateKeyXID, SurrogateKeyHash, key.Generator)
+	}
+
+	if len(key.Fields) == 0 {
+		return fmt.Errorf("%w: %s must set the fields to hash", ErrInvalidSurrogateKey, field)
+	}
+
+	seen := make(map[string]bool, len(key.Fields))
+
+	for _, name := range key.Fields {
+		if name == "" || name == key.Column || seen[name] {
+			return fmt.Errorf("%w: %s.fields must be distinct columns other than %q", ErrInvalidSurrogateKey,
+				field, key.Column)
+		}
+
+		seen[name] = true
+	}
+
+	return nil
+}
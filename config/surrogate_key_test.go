@@ -0,0 +1,54 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSurrogateKeyValidate(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name string
+		key  SurrogateKey
+		err  error
+	}{
+		{name: "uuidv7", key: SurrogateKey{Column: "key", Generator: SurrogateKeyUUIDv7}},
+		{name: "hash", key: SurrogateKey{Column: "key", Generator: SurrogateKeyHash, Fields: []string{"a", "b"}}},
+		{name: "no column", key: SurrogateKey{Generator: SurrogateKeyULID}, err: ErrInvalidSurrogateKey},
+		{name: "unknown generator", key: SurrogateKey{Column: "key", Generator: "uuidv4"}, err: ErrInvalidSurrogateKey},
+		{
+			name: "fields without hash",
+			key:  SurrogateKey{Column: "key", Generator: SurrogateKeyXID, Fields: []string{"a"}},
+			err:  ErrInvalidSurrogateKey,
+		},
+		{
+			name: "hash without fields",
+			key:  SurrogateKey{Column: "key", Generator: SurrogateKeyHash},
+			err:  ErrInvalidSurrogateKey,
+		},
+		{
+			name: "hash of the key",
+			key:  SurrogateKey{Column: "key", Generator: SurrogateKeyHash, Fields: []string{"a", "key"}},
+			err:  ErrInvalidSurrogateKey,
+		},
+	} {
+		if err := tcase.key.validate("surrogateKey"); !errors.Is(err, tcase.err) {
+			t.Fatalf("%s: expected error %v, got %v", tcase.name, tcase.err, err)
+		}
+	}
+
+	cfg := &Config{AutoCreate: true, Tables: map[string]*Table{"t": {}}}
+
+	create := cfg.newAutoCreate(&Request{Table: "t", SurrogateKey: &SurrogateKey{Column: "key"}})
+	if len(create.PrimaryKeys) != 1 || create.PrimaryKeys[0] != "key" {
+		t.Fatalf("expected the surrogate key to be the primary key, got %v", create.PrimaryKeys)
+	}
+}
@@ -0,0 +1,357 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import "fmt"
+
+const (
+	// WriteModeUpsert will insert new records and update existing records. This is the default.
+	WriteModeUpsert = "upsert"
+
+	// WriteModeReplace will truncate the table before upserting, replacing its contents on every run.
+	WriteModeReplace = "replace"
+
+	// WriteModeInsert will insert every record without checking whether it already exists, which is faster than an
+	// upsert.
+	WriteModeInsert = "insert"
+
+	// WriteModeAppend will insert new records and skip those that already exist, never modifying a record once it
+	// has been written.
+	WriteModeAppend = "append"
+)
+
+// validateWriteMode will ensure that "mode" is one of the write modes.
+func validateWriteMode(field, mode string) error {
+	switch mode {
+	case "", WriteModeUpsert, WriteModeReplace, WriteModeInsert, WriteModeAppend:
+		return nil
+	default:
+		return fmt.Errorf("%w: %s %q must be one of %q, %q, %q or %q", ErrInvalidWriteMode, field, mode,
+			WriteModeUpsert, WriteModeReplace, WriteModeInsert, WriteModeAppend)
+	}
+}
+
+// Table holds the settings shared by every request that writes to a table. Requests reference a table by name with
+// their "table" field, and settings on the request take precedence over those on the table.
+type Table struct {
+	// PrimaryKeys are the columns that identify a record. For SQL storage, the transport will fail before fetching
+	// any data if the existing table has different primary keys.
+	PrimaryKeys []string `yaml:"primaryKeys"`
+
+	// ColumnTypes are the column types of individual columns when the table is created by "autoCreate", or the
+	// columns are added by "schemaEvolution", keyed by column name, e.g. "price: NUMERIC(18,8)".
+	ColumnTypes map[string]string `yaml:"columnTypes"`
+
+	// SchemaEvolution is the "schemaEvolution" mode of the table, which takes precedence over that of the
+	// configuration.
+	SchemaEvolution string `yaml:"schemaEvolution"`
+
+	// WriteMode is the default "writeMode" for requests that write to the table.
+	WriteMode string `yaml:"writeMode" enum:"upsert,replace,insert,append"`
+
+	// ConflictKeys is the default "conflictKeys" for requests that write to the table.
+	ConflictKeys []string `yaml:"conflictKeys"`
+
+	// OrderBy is the default "orderBy" for requests that write to the table.
+	OrderBy string `yaml:"orderBy"`
+
+	// DedupeKeys is the default "dedupeKeys" for requests that write to the table.
+	DedupeKeys []string `yaml:"dedupeKeys"`
+
+	// Fields is the default "fields" for requests that write to the table.
+	Fields map[string]string `yaml:"fields"`
+
+	// Flatten is the default "flatten" for requests that write to the table.
+	Flatten *Flatten `yaml:"flatten"`
+
+	// ChildTables is the default "childTables" for requests that write to the table.
+	ChildTables []*ChildTable `yaml:"childTables"`
+
+	// IncludeFields is the default "includeFields" for requests that write to the table.
+	IncludeFields []string `yaml:"includeFields"`
+
+	// ExcludeFields is the default "excludeFields" for requests that write to the table.
+	ExcludeFields []string `yaml:"excludeFields"`
+
+	// Transforms is the default "transforms" for requests that write to the table.
+	Transforms map[string]string `yaml:"transforms"`
+
+	// NumberLocales is the default "numberLocales" for requests that write to the table.
+	NumberLocales map[string]string `yaml:"numberLocales"`
+
+	// Types is the default "types" for requests that write to the table.
+	Types map[string]string `yaml:"types"`
+
+	// OnTypeError is the default "onTypeError" for requests that write to the table.
+	OnTypeError string `yaml:"onTypeError" enum:"error,null,skip"`
+
+	// Hooks is the default "hooks" for requests that write to the table.
+	Hooks []string `yaml:"hooks"`
+
+	// Masks is the default "masks" for requests that write to the table.
+	Masks map[string]string `yaml:"masks"`
+
+	// Encrypt is the default "encrypt" for requests that write to the table.
+	Encrypt *Encrypt `yaml:"encrypt"`
+
+	// TTL is the default "ttl" for requests that write to the table.
+	TTL *TTL `yaml:"ttl"`
+
+	// Provenance is the default "provenance" for requests that write to the table.
+	Provenance *Provenance `yaml:"provenance"`
+
+	// SurrogateKey is the default "surrogateKey" for requests that write to the table.
+	SurrogateKey *SurrogateKey `yaml:"surrogateKey"`
+
+	// ClobColumn is the default "clobColumn" for requests that write to the table.
+	ClobColumn string `yaml:"clobColumn"`
+
+	// RecordPages will enable "recordPages" for every request that writes to the table.
+	RecordPages bool `yaml:"recordPages"`
+
+	// ColumnStats will enable "columnStats" for every request that writes to the table.
+	ColumnStats bool `yaml:"columnStats"`
+
+	// ConnectionStrings are the sinks that the table is written to, which must be a subset of the top-level
+	// "connectionStrings". The default is to write to every sink.
+	ConnectionStrings []string `yaml:"connectionStrings"`
+
+	// AllowCollisions will allow requests to write to the table with a different write mode or "clobColumn",
+	// logging a warning rather than failing when the configuration is loaded.
+	AllowCollisions bool `yaml:"allowCollisions"`
+}
+
+func (table *Table) validate(name string, connectionStrings []string) error {
+	if err := validateWriteMode(fmt.Sprintf("tables.%s.writeMode", name), table.WriteMode); err != nil {
+		return err
+	}
+
+	if err := validateColumnTypes(name, table.ColumnTypes); err != nil {
+		return err
+	}
+
+	if err := validateSchemaEvolution(fmt.Sprintf("tables.%s.schemaEvolution", name),
+		table.SchemaEvolution); err != nil {
+		return err
+	}
+
+	if err := validateFields(fmt.Sprintf("tables.%s.fields", name), table.Fields); err != nil {
+		return err
+	}
+
+	if table.Flatten != nil {
+		if err := table.Flatten.validate(fmt.Sprintf("tables.%s.flatten", name)); err != nil {
+			return err
+		}
+	}
+
+	if err := validateDedupeKeys(fmt.Sprintf("tables.%s.dedupeKeys", name), table.DedupeKeys); err != nil {
+		return err
+	}
+
+	if err := validateChildTables(fmt.Sprintf("tables.%s.childTables", name), name, table.ChildTables); err != nil {
+		return err
+	}
+
+	if err := validateFieldFilters("tables."+name, table.IncludeFields, table.ExcludeFields); err != nil {
+		return err
+	}
+
+	if err := validateNumberLocales(fmt.Sprintf("tables.%s.numberLocales", name), table.NumberLocales); err != nil {
+		return err
+	}
+
+	if err := validateTransforms(fmt.Sprintf("tables.%s.transforms", name), table.Transforms); err != nil {
+		return err
+	}
+
+	if err := validateTypes(fmt.Sprintf("tables.%s.types", name), table.Types, table.OnTypeError); err != nil {
+		return err
+	}
+
+	if err := validateMasks(fmt.Sprintf("tables.%s.masks", name), table.Masks); err != nil {
+		return err
+	}
+
+	if err := validateHooks(fmt.Sprintf("tables.%s.hooks", name), table.Hooks); err != nil {
+		return err
+	}
+
+	if table.TTL != nil {
+		if err := table.TTL.validate(fmt.Sprintf("tables.%s.ttl", name)); err != nil {
+			return err
+		}
+	}
+
+	if table.Provenance != nil {
+		if err := table.Provenance.validate(fmt.Sprintf("tables.%s.provenance", name)); err != nil {
+			return err
+		}
+	}
+
+	if table.SurrogateKey != nil {
+		if err := table.SurrogateKey.validate(fmt.Sprintf("tables.%s.surrogateKey", name)); err != nil {
+			return err
+		}
+	}
+
+	if table.Encrypt != nil {
+		if err := table.Encrypt.validate(fmt.Sprintf("tables.%s.encrypt", name), nil, table.Masks); err != nil {
+			return err
+		}
+	}
+
+	return validateSinks(fmt.Sprintf("tables.%s.connectionStrings", name), table.ConnectionStrings,
+		connectionStrings)
+}
+
+// validateSinks will ensure that every sink is one of the top-level "connectionStrings". The sinks themselves are
+// not included in the error, since connection strings often contain credentials.
+func validateSinks(field string, sinks, connectionStrings []string) error {
+	known := make(map[string]bool, len(connectionStrings))
+	for _, dns := range connectionStrings {
+		known[dns] = true
+	}
+
+	for idx, dns := range sinks {
+		if !known[dns] {
+			return fmt.Errorf("%w: %s[%d] is not in connectionStrings", ErrInvalidSink, field, idx)
+		}
+	}
+
+	return nil
+}
+
+// apply will fill in the table-level settings on a request that have not been set on the request itself.
+func (table *Table) apply(req *Request) {
+	if req.ClobColumn == "" {
+		req.ClobColumn = table.ClobColumn
+	}
+
+	if table.RecordPages {
+		req.RecordPages = true
+	}
+
+	if table.ColumnStats {
+		req.ColumnStats = true
+	}
+
+	if req.WriteMode == "" {
+		req.WriteMode = table.WriteMode
+	}
+
+	if req.ConflictKeys == nil {
+		req.ConflictKeys = table.ConflictKeys
+	}
+
+	if req.OrderBy == "" {
+		req.OrderBy = table.OrderBy
+	}
+
+	if req.DedupeKeys == nil {
+		req.DedupeKeys = table.DedupeKeys
+	}
+
+	if req.Fields == nil {
+		req.Fields = table.Fields
+	}
+
+	if req.Flatten == nil {
+		req.Flatten = table.Flatten
+	}
+
+	if req.ChildTables == nil {
+		req.ChildTables = table.ChildTables
+	}
+
+	// Requests that set either filter do not take the other from the table, since they cannot both be set.
+	if req.IncludeFields == nil && req.ExcludeFields == nil {
+		req.IncludeFields, req.ExcludeFields = table.IncludeFields, table.ExcludeFields
+	}
+
+	if req.Transforms == nil {
+		req.Transforms = table.Transforms
+	}
+
+	if req.NumberLocales == nil {
+		req.NumberLocales = table.NumberLocales
+	}
+
+	if req.Types == nil {
+		req.Types = table.Types
+	}
+
+	if req.OnTypeError == "" {
+		req.OnTypeError = table.OnTypeError
+	}
+
+	if req.Hooks == nil {
+		req.Hooks = table.Hooks
+	}
+
+	if req.Masks == nil {
+		req.Masks = table.Masks
+	}
+
+	if req.Encrypt == nil {
+		req.Encrypt = table.Encrypt
+	}
+
+	if req.TTL == nil {
+		req.TTL = table.TTL
+	}
+
+	if req.Provenance == nil {
+		req.Provenance = table.Provenance
+	}
+
+	if req.SurrogateKey == nil {
+		req.SurrogateKey = table.SurrogateKey
+	}
+
+	if req.ConnectionStrings == nil {
+		req.ConnectionStrings = table.ConnectionStrings
+	}
+}
+
+// TruncateTables will truncate the tables before they are loaded, as if every request that writes to them set
+// "truncate". It fails if no request writes to one of the tables.
+func (cfg *Config) TruncateTables(tables ...string) error {
+	truncate := true
+
+	for _, table := range tables {
+		found := false
+
+		for _, req := range cfg.Requests {
+			if req.Table == table {
+				req.Truncate = &truncate
+				found = true
+			}
+		}
+
+		if !found {
+			return fmt.Errorf("%w: no request writes to table %q", ErrInvalidTable, table)
+		}
+	}
+
+	return nil
+}
+
+// partitionKeys returns the columns that the records of a request are partitioned by: its "conflictKeys", or else the
+// primary keys of its table.
+func (cfg *Config) partitionKeys(req *Request) []string {
+	if len(req.ConflictKeys) > 0 {
+		return req.ConflictKeys
+	}
+
+	if table, ok := cfg.Tables[req.Table]; ok {
+		return table.PrimaryKeys
+	}
+
+	return nil
+}
@@ -0,0 +1,361 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestTableValidate(t *testing.T) {
+	t.Parallel()
+
+	sinks := []string{"mongodb://localhost:27017/db", "postgresql://localhost:5432/db"}
+
+	for _, tcase := range []struct {
+		name  string
+		table Table
+		err   error
+	}{
+		{name: "empty"},
+		{name: "replace", table: Table{WriteMode: WriteModeReplace}},
+		{name: "append", table: Table{WriteMode: WriteModeAppend}},
+		{name: "invalid write mode", table: Table{WriteMode: "merge"}, err: ErrInvalidWriteMode},
+		{name: "sink", table: Table{ConnectionStrings: sinks[1:]}},
+		{name: "unknown sink", table: Table{ConnectionStrings: []string{"mongodb://other"}}, err: ErrInvalidSink},
+	} {
+		tcase := tcase
+
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			if err := tcase.table.validate("candles", sinks); !errors.Is(err, tcase.err) {
+				t.Fatalf("expected error %v, got %v", tcase.err, err)
+			}
+		})
+	}
+}
+
+func TestRequestWriteMode(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name string
+		req  Request
+		err  error
+	}{
+		{name: "default"},
+		{name: "insert", req: Request{WriteMode: WriteModeInsert}},
+		{name: "append", req: Request{WriteMode: WriteModeAppend, ConflictKeys: []string{"id"}}},
+		{name: "upsert", req: Request{WriteMode: WriteModeUpsert, ConflictKeys: []string{"id"}}},
+		{name: "invalid", req: Request{WriteMode: "merge"}, err: ErrInvalidWriteMode},
+		{
+			name: "insert with conflict keys",
+			req:  Request{WriteMode: WriteModeInsert, ConflictKeys: []string{"id"}},
+			err:  ErrInvalidWriteMode,
+		},
+	} {
+		tcase := tcase
+
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			if err := tcase.req.validate(); !errors.Is(err, tcase.err) {
+				t.Fatalf("expected error %v, got %v", tcase.err, err)
+			}
+		})
+	}
+}
+
+func TestRequestResponseFormat(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		format string
+		ndjson bool
+		err    error
+	}{
+		{format: ""},
+		{format: ResponseFormatJSON},
+		{format: ResponseFormatNDJSON, ndjson: true},
+		{format: ResponseFormatJSONLines, ndjson: true},
+		{format: ResponseFormatCSV},
+		{format: "yaml", err: ErrInvalidResponseFormat},
+	} {
+		req := &Request{Endpoint: "/trades", ResponseFormat: tcase.format}
+		if err := req.validate(); !errors.Is(err, tcase.err) {
+			t.Fatalf("%q: expected error %v, got %v", tcase.format, tcase.err, err)
+		}
+
+		if req.NDJSON() != tcase.ndjson {
+			t.Fatalf("%q: expected ndjson to be %t", tcase.format, tcase.ndjson)
+		}
+	}
+}
+
+func TestNewAppliesTables(t *testing.T) {
+	t.Parallel()
+
+	data := `
+version: 1
+url: https://example.com
+connectionStrings:
+  - mongodb://localhost:27017/db
+  - postgresql://localhost:5432/db
+rateLimit:
+  burst: 1
+  period: 1
+tables:
+  candles:
+    writeMode: replace
+    conflictKeys: [time]
+    orderBy: time
+    clobColumn: data
+    recordPages: true
+    columnStats: true
+    allowCollisions: true
+    connectionStrings:
+      - postgresql://localhost:5432/db
+requests:
+  - endpoint: /candles
+  - endpoint: /candles/daily
+    table: candles
+    clobColumn: raw
+    truncate: false
+  - endpoint: /trades
+    writeMode: append
+    conflictKeys: [id]
+`
+
+	cfg, err := New(context.Background(), newTestConfigFile(t, data))
+	if err != nil {
+		t.Fatalf("failed to create config: %v", err)
+	}
+
+	first, second, other := cfg.Requests[0], cfg.Requests[1], cfg.Requests[2]
+
+	if first.ClobColumn != "data" || !first.RecordPages || !first.ColumnStats || first.Truncate == nil ||
+		!*first.Truncate {
+		t.Fatalf("expected table settings to be applied, got %+v", first)
+	}
+
+	if first.WriteMode != WriteModeReplace || !reflect.DeepEqual(first.ConflictKeys, []string{"time"}) ||
+		first.OrderBy != "time" {
+		t.Fatalf("expected the table write mode to be applied, got %+v", first)
+	}
+
+	if len(first.ConnectionStrings) != 1 {
+		t.Fatalf("expected the table sinks to be applied, got %v", first.ConnectionStrings)
+	}
+
+	if second.ClobColumn != "raw" || *second.Truncate {
+		t.Fatalf("expected request settings to take precedence, got %+v", second)
+	}
+
+	if other.ClobColumn != "" || other.RecordPages || other.ColumnStats || other.ConnectionStrings != nil ||
+		other.Truncate != nil || other.OrderBy != "" ||
+		other.WriteMode != WriteModeAppend || !reflect.DeepEqual(other.ConflictKeys, []string{"id"}) {
+		t.Fatalf("expected no table settings for another table, got %+v", other)
+	}
+}
+
+func TestNewAppliesTruncate(t *testing.T) {
+	t.Parallel()
+
+	data := `
+version: 1
+url: https://example.com
+connectionStrings:
+  - mongodb://localhost:27017/db
+rateLimit:
+  burst: 1
+  period: 1
+truncate: true
+requests:
+  - endpoint: /candles
+  - endpoint: /trades
+    truncate: false
+`
+
+	cfg, err := New(context.Background(), newTestConfigFile(t, data))
+	if err != nil {
+		t.Fatalf("failed to create config: %v", err)
+	}
+
+	if candles := cfg.Requests[0]; candles.Truncate == nil || !*candles.Truncate {
+		t.Fatalf("expected the top-level truncate to be applied, got %+v", candles)
+	}
+
+	if trades := cfg.Requests[1]; *trades.Truncate {
+		t.Fatalf("expected the request truncate to take precedence, got %+v", trades)
+	}
+}
+
+func TestTruncateTables(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name   string
+		tables []string
+		want   []bool
+		err    error
+	}{
+		{name: "none", want: []bool{false, false, false}},
+		{name: "every request of a table", tables: []string{"trades"}, want: []bool{true, true, false}},
+		{name: "tables", tables: []string{"trades", "quotes"}, want: []bool{true, true, true}},
+		{name: "no request", tables: []string{"candles"}, err: ErrInvalidTable},
+	} {
+		tcase := tcase
+
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := &Config{Requests: []*Request{
+				{Endpoint: "/trades", Table: "trades"},
+				{Endpoint: "/trades/daily", Table: "trades"},
+				{Endpoint: "/quotes", Table: "quotes"},
+			}}
+
+			if err := cfg.TruncateTables(tcase.tables...); !errors.Is(err, tcase.err) {
+				t.Fatalf("expected error %v, got %v", tcase.err, err)
+			}
+
+			for idx, want := range tcase.want {
+				if got := cfg.Requests[idx].Truncate != nil && *cfg.Requests[idx].Truncate; got != want {
+					t.Fatalf("expected request %d to truncate %t, got %t", idx, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestRequestStorage(t *testing.T) {
+	t.Parallel()
+
+	sinks := []string{
+		"mongodb://localhost:27017/db",
+		"postgresql://localhost:5432/db",
+		"postgresql://replica:5432/db",
+	}
+
+	for _, tcase := range []struct {
+		name string
+		req  Request
+		want []string
+		err  error
+	}{
+		{name: "empty"},
+		{name: "scheme", req: Request{Storage: []string{"mongodb"}}, want: sinks[:1]},
+		{name: "every sink of a scheme", req: Request{Storage: []string{"POSTGRESQL"}}, want: sinks[1:]},
+		{name: "unknown scheme", req: Request{Storage: []string{"mongodb", "mysql"}}, err: ErrInvalidSink},
+		{
+			name: "connection strings",
+			req:  Request{Storage: []string{"mongodb"}, ConnectionStrings: sinks[:1]},
+			err:  ErrInvalidSink,
+		},
+	} {
+		tcase := tcase
+
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			if err := tcase.req.validateStorage(sinks); !errors.Is(err, tcase.err) {
+				t.Fatalf("expected error %v, got %v", tcase.err, err)
+			}
+
+			if tcase.err != nil {
+				return
+			}
+
+			if got := storageSinks(tcase.req.Storage, sinks); !reflect.DeepEqual(got, tcase.want) {
+				t.Fatalf("expected sinks %v, got %v", tcase.want, got)
+			}
+		})
+	}
+}
+
+func TestNewAppliesStorage(t *testing.T) {
+	t.Parallel()
+
+	data := `
+version: 1
+url: https://example.com
+connectionStrings:
+  - mongodb://localhost:27017/db
+  - postgresql://localhost:5432/db
+rateLimit:
+  burst: 1
+  period: 1
+tables:
+  candles:
+    connectionStrings:
+      - postgresql://localhost:5432/db
+requests:
+  - endpoint: /candles/raw
+    table: candles
+    storage: [mongodb]
+  - endpoint: /candles
+`
+
+	cfg, err := New(context.Background(), newTestConfigFile(t, data))
+	if err != nil {
+		t.Fatalf("failed to create config: %v", err)
+	}
+
+	raw, candles := cfg.Requests[0], cfg.Requests[1]
+
+	if !reflect.DeepEqual(raw.ConnectionStrings, []string{"mongodb://localhost:27017/db"}) {
+		t.Fatalf("expected the storage to take precedence over the table sinks, got %v", raw.ConnectionStrings)
+	}
+
+	if !reflect.DeepEqual(candles.ConnectionStrings, []string{"postgresql://localhost:5432/db"}) {
+		t.Fatalf("expected the table sinks to be applied, got %v", candles.ConnectionStrings)
+	}
+}
+
+func TestNewAppliesPartitionKeys(t *testing.T) {
+	t.Parallel()
+
+	data := `
+version: 1
+url: https://example.com
+connectionStrings:
+  - postgresql://localhost:5432/db
+rateLimit:
+  burst: 1
+  period: 1
+partitions: 4
+tables:
+  trades:
+    primaryKeys: [id, venue]
+requests:
+  - endpoint: /trades
+  - endpoint: /trades/latest
+    table: trades
+    conflictKeys: [id]
+  - endpoint: /quotes
+`
+
+	cfg, err := New(context.Background(), newTestConfigFile(t, data))
+	if err != nil {
+		t.Fatalf("failed to create config: %v", err)
+	}
+
+	for idx, want := range [][]string{{"id", "venue"}, {"id"}, nil} {
+		if got := cfg.Requests[idx].PartitionKeys; !reflect.DeepEqual(got, want) {
+			t.Fatalf("expected the partition keys of %s to be %v, got %v", cfg.Requests[idx].Endpoint, want, got)
+		}
+	}
+
+	cfg.Partitions = -1
+	if err := cfg.Validate(); !errors.Is(err, ErrInvalidPartitions) {
+		t.Fatalf("expected error %v, got %v", ErrInvalidPartitions, err)
+	}
+}
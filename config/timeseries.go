@@ -8,23 +8,273 @@
 package config
 
 import (
+	"fmt"
+	"strconv"
 	"time"
 )
 
+const (
+	// TimeseriesTargetQuery indicates that the timeseries start and end values are query parameters.
+	TimeseriesTargetQuery = "query"
+
+	// TimeseriesTargetBody indicates that the timeseries start and end values are fields in the JSON request body,
+	// addressed by a JSON path such as "$.range.start".
+	TimeseriesTargetBody = "body"
+
+	// TimeseriesLayoutUnix is a layout shorthand for seconds since the Unix epoch.
+	TimeseriesLayoutUnix = "unix"
+
+	// TimeseriesLayoutUnixMilli is a layout shorthand for milliseconds since the Unix epoch.
+	TimeseriesLayoutUnixMilli = "unix_ms"
+
+	// TimeseriesLayoutUnixNano is a layout shorthand for nanoseconds since the Unix epoch.
+	TimeseriesLayoutUnixNano = "unix_nano"
+
+	// TimeseriesAlignHour aligns timeseries chunks to the start of each hour.
+	TimeseriesAlignHour = "hour"
+
+	// TimeseriesAlignDay aligns timeseries chunks to the start of each day.
+	TimeseriesAlignDay = "day"
+
+	// TimeseriesAlignWeek aligns timeseries chunks to the start of each ISO week, i.e. Monday.
+	TimeseriesAlignWeek = "week"
+
+	// TimeseriesAlignMonth aligns timeseries chunks to the start of each month.
+	TimeseriesAlignMonth = "month"
+
+	// MaxTimeseriesPrefetch is the largest number of chunks that a timeseries can prefetch.
+	MaxTimeseriesPrefetch = 8
+)
+
+// ChunkColumns are the columns of a record that the boundaries of its timeseries chunk are written to, as RFC 3339
+// timestamps in UTC. Either column may be left empty to not write that boundary.
+type ChunkColumns struct {
+	Start string `yaml:"start"`
+	End   string `yaml:"end"`
+}
+
 // Timeseries is a struct that contains the information needed to query a web API for Timeseries data.
 type Timeseries struct {
 	StartName string `yaml:"startName"`
 	EndName   string `yaml:"endName"`
 
-	// Period is the size of each chunk in seconds for which we can query the API. Some API will not allow us to
-	// query all data within the start and end range.
-	Period int32 `yaml:"period"`
+	// Target is where the "StartName" and "EndName" values are found on the request, either "query" for query
+	// parameters or "body" for JSON paths into the request body. The default is "query".
+	Target string `yaml:"target"`
+
+	// Period is the size of each chunk for which we can query the API. Some API will not allow us to query all
+	// data within the start and end range. This is either an integer number of seconds or a duration string such
+	// as "5h", "1d" or "1w".
+	Period TimeseriesPeriod `yaml:"period"`
 
-	// Layout is the time layout for parsing the "Start" and "End" values into "time.Time". The default is assumed
-	// to be RFC3339.
+	// Layout is the time layout for parsing the "Start" and "End" values into "time.Time", and for formatting the
+	// boundaries of each chunk. This is either a Go time layout or one of the shorthands "unix", "unix_ms", or
+	// "unix_nano" for integer offsets from the Unix epoch. The default is assumed to be RFC3339.
 	Layout *string `yaml:"layout"`
 
+	// Align will align the chunk boundaries to a calendar unit, one of "hour", "day", "week", or "month", rather
+	// than to fixed-size offsets from the start time. Each chunk covers a single calendar unit, the first chunk
+	// starting at the beginning of the unit containing the start time and the last chunk ending at the end of the
+	// unit containing the end time. "Period" is ignored when this is set.
+	Align string `yaml:"align" enum:"hour,day,week,month"`
+
+	// Timezone is the IANA name of the timezone used to align chunks, e.g. "America/New_York". The default is UTC.
+	Timezone string `yaml:"timezone"`
+
+	// ChunkColumns are the columns that the start and end of the chunk that fetched a record are written to, so
+	// that the rows of a table can be traced back to the window of the API that produced them.
+	ChunkColumns *ChunkColumns `yaml:"chunkColumns"`
+
+	// Prefetch is the number of upcoming chunks whose rate-limiter wait is started once the response of a chunk
+	// has arrived, so that the wait overlaps with the decoding and upserting of the response. Each chunk is still
+	// made with a single token of the rate limiter. The default is 0, which does not prefetch.
+	Prefetch int `yaml:"prefetch"`
+
+	// Watermark is the end of the timeseries data ingested by previous runs, loaded from the state store. If it is
+	// after the start of the range, chunking begins at the watermark instead.
+	Watermark *time.Time `yaml:"-"`
+
 	// Chunks are the time ranges for which we can query the API. These are broken up into pieces for API requests
 	// that only return a limited number of results.
 	Chunks [][2]time.Time
 }
+
+func (ts *Timeseries) validate() error {
+	switch ts.Target {
+	case "", TimeseriesTargetQuery, TimeseriesTargetBody:
+	default:
+		return fmt.Errorf("%w: %q", ErrInvalidTimeseriesTarget, ts.Target)
+	}
+
+	switch ts.Align {
+	case "", TimeseriesAlignHour, TimeseriesAlignDay, TimeseriesAlignWeek, TimeseriesAlignMonth:
+	default:
+		return fmt.Errorf("%w: %q", ErrInvalidTimeseriesAlign, ts.Align)
+	}
+
+	if _, err := ts.Location(); err != nil {
+		return err
+	}
+
+	if ts.Align == "" && ts.Period <= 0 {
+		return fmt.Errorf("%w: must be greater than zero", ErrInvalidTimeseriesPeriod)
+	}
+
+	if cols := ts.ChunkColumns; cols != nil && cols.Start == cols.End {
+		return fmt.Errorf("%w: start and end must be different columns, and at least one must be set",
+			ErrInvalidChunkColumns)
+	}
+
+	if ts.Prefetch < 0 || ts.Prefetch > MaxTimeseriesPrefetch {
+		return fmt.Errorf("%w: must be between 0 and %d", ErrInvalidTimeseriesPrefetch, MaxTimeseriesPrefetch)
+	}
+
+	return nil
+}
+
+// Location will return the timezone used to align chunks.
+func (ts *Timeseries) Location() (*time.Location, error) {
+	if ts.Timezone == "" {
+		return time.UTC, nil
+	}
+
+	loc, err := time.LoadLocation(ts.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTimeseriesTimezone, err)
+	}
+
+	return loc, nil
+}
+
+// AlignTime will return the start of the calendar unit containing "t" in the location "loc". If no alignment is
+// configured, "t" is returned unchanged.
+func (ts *Timeseries) AlignTime(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+
+	switch ts.Align {
+	case TimeseriesAlignHour:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, loc)
+	case TimeseriesAlignDay:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	case TimeseriesAlignWeek:
+		// Go weeks start on Sunday, ISO weeks start on Monday.
+		offset := (int(t.Weekday()) + 6) % 7
+
+		return time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, loc)
+	case TimeseriesAlignMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+	default:
+		return t
+	}
+}
+
+// NextAlignedTime will return the start of the calendar unit after the one that begins at "t". Calendar arithmetic
+// is used so that daylight saving transitions and months of different lengths are handled. If no alignment is
+// configured, the period is added to "t".
+func (ts *Timeseries) NextAlignedTime(t time.Time) time.Time {
+	switch ts.Align {
+	case TimeseriesAlignHour:
+		return t.Add(time.Hour)
+	case TimeseriesAlignDay:
+		return t.AddDate(0, 0, 1)
+	case TimeseriesAlignWeek:
+		return t.AddDate(0, 0, 7)
+	case TimeseriesAlignMonth:
+		return t.AddDate(0, 1, 0)
+	default:
+		return t.Add(ts.Period.Duration())
+	}
+}
+
+// ValidateRange will check that the period relates to the range between "start" and "end", i.e. that the range is
+// not reversed and that a non-empty range is at least one period long. A period that is longer than the range is
+// usually a units mistake, such as a period in milliseconds.
+func (ts *Timeseries) ValidateRange(start, end time.Time) error {
+	if end.Before(start) {
+		return fmt.Errorf("%w: start %s is after end %s", ErrInvalidTimeseriesRange, start, end)
+	}
+
+	if start.Equal(end) || ts.Align != "" {
+		return nil
+	}
+
+	if period := ts.Period.Duration(); period > end.Sub(start) {
+		return fmt.Errorf("%w: period %s is longer than the range %s", ErrInvalidTimeseriesPeriod, period,
+			end.Sub(start))
+	}
+
+	return nil
+}
+
+// PartialChunk returns the length of the last chunk of the range between "start" and "end" if it is shorter than the
+// period, i.e. if the range is not a whole number of periods long. A partial chunk is fetched like any other, but it
+// is usually a sign that the range or the period is off. Calendar-aligned chunks are never partial.
+func (ts *Timeseries) PartialChunk(start, end time.Time) (time.Duration, bool) {
+	period := ts.Period.Duration()
+	if ts.Align != "" || period <= 0 || !start.Before(end) {
+		return 0, false
+	}
+
+	rem := end.Sub(start) % period
+
+	return rem, rem != 0
+}
+
+// layout will return the configured layout, defaulting to RFC3339.
+func (ts *Timeseries) layout() string {
+	if ts.Layout == nil || *ts.Layout == "" {
+		return time.RFC3339
+	}
+
+	return *ts.Layout
+}
+
+// IsUnixLayout returns true if the layout is one of the Unix epoch shorthands.
+func (ts *Timeseries) IsUnixLayout() bool {
+	switch ts.layout() {
+	case TimeseriesLayoutUnix, TimeseriesLayoutUnixMilli, TimeseriesLayoutUnixNano:
+		return true
+	default:
+		return false
+	}
+}
+
+// ParseTime will parse a timeseries boundary using the configured layout.
+func (ts *Timeseries) ParseTime(str string) (time.Time, error) {
+	if !ts.IsUnixLayout() {
+		parsed, err := time.Parse(ts.layout(), str)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%w: %v", UnableToParseError("timeseries time"), err)
+		}
+
+		return parsed, nil
+	}
+
+	epoch, err := strconv.ParseInt(str, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %v", UnableToParseError("timeseries time"), err)
+	}
+
+	switch ts.layout() {
+	case TimeseriesLayoutUnixMilli:
+		return time.UnixMilli(epoch).UTC(), nil
+	case TimeseriesLayoutUnixNano:
+		return time.Unix(0, epoch).UTC(), nil
+	default:
+		return time.Unix(epoch, 0).UTC(), nil
+	}
+}
+
+// FormatTime will format a timeseries boundary using the configured layout.
+func (ts *Timeseries) FormatTime(t time.Time) string {
+	switch ts.layout() {
+	case TimeseriesLayoutUnix:
+		return strconv.FormatInt(t.Unix(), 10)
+	case TimeseriesLayoutUnixMilli:
+		return strconv.FormatInt(t.UnixMilli(), 10)
+	case TimeseriesLayoutUnixNano:
+		return strconv.FormatInt(t.UnixNano(), 10)
+	default:
+		return t.Format(ts.layout())
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTimeseriesLayout(t *testing.T) {
+	t.Parallel()
+
+	moment := time.Date(2022, 5, 10, 5, 0, 0, 0, time.UTC)
+
+	for _, tcase := range []struct {
+		layout string
+		str    string
+	}{
+		{layout: "", str: "2022-05-10T05:00:00Z"},
+		{layout: "2006-01-02 15:04", str: "2022-05-10 05:00"},
+		{layout: TimeseriesLayoutUnix, str: "1652158800"},
+		{layout: TimeseriesLayoutUnixMilli, str: "1652158800000"},
+		{layout: TimeseriesLayoutUnixNano, str: "1652158800000000000"},
+	} {
+		tcase := tcase
+
+		t.Run(tcase.layout, func(t *testing.T) {
+			t.Parallel()
+
+			timeseries := &Timeseries{Layout: &tcase.layout}
+
+			parsed, err := timeseries.ParseTime(tcase.str)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !parsed.Equal(moment) {
+				t.Fatalf("expected %v, got %v", moment, parsed)
+			}
+
+			if got := timeseries.FormatTime(moment); got != tcase.str {
+				t.Fatalf("expected %q, got %q", tcase.str, got)
+			}
+		})
+	}
+
+	t.Run("invalid unix", func(t *testing.T) {
+		t.Parallel()
+
+		layout := TimeseriesLayoutUnix
+		timeseries := &Timeseries{Layout: &layout}
+
+		if _, err := timeseries.ParseTime("2022-05-10"); err == nil {
+			t.Fatalf("expected error, got nil")
+		}
+	})
+}
+
+func TestTimeseriesAlignTime(t *testing.T) {
+	t.Parallel()
+
+	// Wednesday, 10:30 UTC.
+	moment := time.Date(2022, 6, 15, 10, 30, 0, 0, time.UTC)
+
+	for _, tcase := range []struct {
+		align string
+		want  time.Time
+		next  time.Time
+	}{
+		{
+			align: TimeseriesAlignHour,
+			want:  time.Date(2022, 6, 15, 10, 0, 0, 0, time.UTC),
+			next:  time.Date(2022, 6, 15, 11, 0, 0, 0, time.UTC),
+		},
+		{
+			align: TimeseriesAlignDay,
+			want:  time.Date(2022, 6, 15, 0, 0, 0, 0, time.UTC),
+			next:  time.Date(2022, 6, 16, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			align: TimeseriesAlignWeek,
+			want:  time.Date(2022, 6, 13, 0, 0, 0, 0, time.UTC),
+			next:  time.Date(2022, 6, 20, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			align: TimeseriesAlignMonth,
+			want:  time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC),
+			next:  time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC),
+		},
+	} {
+		tcase := tcase
+
+		t.Run(tcase.align, func(t *testing.T) {
+			t.Parallel()
+
+			timeseries := &Timeseries{Align: tcase.align}
+
+			aligned := timeseries.AlignTime(moment, time.UTC)
+			if !aligned.Equal(tcase.want) {
+				t.Fatalf("expected %v, got %v", tcase.want, aligned)
+			}
+
+			if next := timeseries.NextAlignedTime(aligned); !next.Equal(tcase.next) {
+				t.Fatalf("expected %v, got %v", tcase.next, next)
+			}
+		})
+	}
+
+	t.Run("timezone", func(t *testing.T) {
+		t.Parallel()
+
+		timeseries := &Timeseries{Align: TimeseriesAlignDay, Timezone: "America/New_York"}
+
+		loc, err := timeseries.Location()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// 02:00 UTC is the previous day in New York.
+		aligned := timeseries.AlignTime(time.Date(2022, 6, 15, 2, 0, 0, 0, time.UTC), loc)
+		if want := time.Date(2022, 6, 14, 4, 0, 0, 0, time.UTC); !aligned.Equal(want) {
+			t.Fatalf("expected %v, got %v", want, aligned)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		t.Parallel()
+
+		if err := (&Timeseries{Align: "year"}).validate(); !errors.Is(err, ErrInvalidTimeseriesAlign) {
+			t.Fatalf("expected invalid alignment, got %v", err)
+		}
+
+		timeseries := &Timeseries{Align: TimeseriesAlignDay, Timezone: "Mars/Olympus"}
+		if err := timeseries.validate(); !errors.Is(err, ErrInvalidTimeseriesTimezone) {
+			t.Fatalf("expected invalid timezone, got %v", err)
+		}
+
+		timeseries = &Timeseries{Align: TimeseriesAlignDay, ChunkColumns: &ChunkColumns{Start: "window", End: "window"}}
+		if err := timeseries.validate(); !errors.Is(err, ErrInvalidChunkColumns) {
+			t.Fatalf("expected invalid chunk columns, got %v", err)
+		}
+	})
+}
+
+func TestTimeseriesPrefetch(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name     string
+		prefetch int
+		err      error
+	}{
+		{name: "off"},
+		{name: "window", prefetch: 2},
+		{name: "largest", prefetch: MaxTimeseriesPrefetch},
+		{name: "negative", prefetch: -1, err: ErrInvalidTimeseriesPrefetch},
+		{name: "too large", prefetch: MaxTimeseriesPrefetch + 1, err: ErrInvalidTimeseriesPrefetch},
+	} {
+		ts := &Timeseries{Period: 60, Prefetch: tcase.prefetch}
+		if err := ts.validate(); !errors.Is(err, tcase.err) {
+			t.Fatalf("%s: expected error %v, got %v", tcase.name, tcase.err, err)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// tlsVersions are the "minVersion" of the TLS configuration.
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// TLSConfig configures the TLS connections to the web API, e.g. for internal APIs that are served with a private
+// certificate authority or require client certificates.
+type TLSConfig struct {
+	// CAFile is the path of a PEM bundle of certificate authorities that the certificates of the web API are
+	// verified with, in addition to those of the system.
+	CAFile string `yaml:"caFile"`
+
+	// CertFile and KeyFile are the paths of the PEM client certificate and its key, which are presented to the web
+	// API for mutual TLS.
+	CertFile string `yaml:"certFile"`
+	KeyFile  string `yaml:"keyFile"`
+
+	// MinVersion is the lowest TLS version that is accepted: "1.0", "1.1", "1.2" or "1.3". The default is that of
+	// Go, which is 1.2.
+	MinVersion string `yaml:"minVersion" enum:"1.0,1.1,1.2,1.3"`
+
+	// InsecureSkipVerify will accept any certificate of the web API, without verifying it, which leaves the
+	// connection open to interception. It is only meant for testing against self-signed certificates.
+	InsecureSkipVerify bool `yaml:"insecureSkipVerify"`
+}
+
+func (cfg *TLSConfig) validate() error {
+	if (cfg.CertFile == "") != (cfg.KeyFile == "") {
+		return fmt.Errorf("%w: certFile and keyFile must be set together", ErrInvalidTLS)
+	}
+
+	if _, ok := tlsVersions[cfg.MinVersion]; cfg.MinVersion != "" && !ok {
+		return fmt.Errorf("%w: minVersion must be \"1.0\", \"1.1\", \"1.2\" or \"1.3\", got %q", ErrInvalidTLS,
+			cfg.MinVersion)
+	}
+
+	return nil
+}
+
+// ClientConfig returns the TLS configuration of the connections to the web API, reading the certificate authorities
+// and client certificate from their files.
+func (cfg *TLSConfig) ClientConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tlsVersions[cfg.MinVersion],
+		InsecureSkipVerify: cfg.InsecureSkipVerify, //nolint:gosec // it is an explicit opt-in of the configuration
+	}
+
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to read caFile: %v", ErrInvalidTLS, err)
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%w: caFile %s has no PEM certificates", ErrInvalidTLS, cfg.CAFile)
+		}
+
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to load certFile and keyFile: %v", ErrInvalidTLS, err)
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
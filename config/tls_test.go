@@ -0,0 +1,131 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCertificate will write a self-signed certificate and its key to PEM files in "dir", returning their paths.
+func writeCertificate(t *testing.T, dir string) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "gidari"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to encode key: %v", err)
+	}
+
+	certFile, keyFile := filepath.Join(dir, "client.pem"), filepath.Join(dir, "client-key.pem")
+
+	for file, block := range map[string]*pem.Block{
+		certFile: {Type: "CERTIFICATE", Bytes: der},
+		keyFile:  {Type: "EC PRIVATE KEY", Bytes: keyDER},
+	} {
+		if err := os.WriteFile(file, pem.EncodeToMemory(block), 0o600); err != nil {
+			t.Fatalf("failed to write %s: %v", file, err)
+		}
+	}
+
+	return certFile, keyFile
+}
+
+func TestTLSConfig(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	certFile, keyFile := writeCertificate(t, dir)
+
+	notPEM := filepath.Join(dir, "not.pem")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatalf("failed to write %s: %v", notPEM, err)
+	}
+
+	for _, tcase := range []struct {
+		name    string
+		tls     *TLSConfig
+		version uint16
+		certs   int
+		roots   bool
+		err     error
+	}{
+		{name: "default", tls: &TLSConfig{}},
+		{name: "min version", tls: &TLSConfig{MinVersion: "1.3"}, version: tls.VersionTLS13},
+		{name: "ca", tls: &TLSConfig{CAFile: certFile}, roots: true},
+		{name: "client certificate", tls: &TLSConfig{CertFile: certFile, KeyFile: keyFile}, certs: 1},
+		{name: "insecure", tls: &TLSConfig{InsecureSkipVerify: true}},
+		{name: "invalid min version", tls: &TLSConfig{MinVersion: "1.4"}, err: ErrInvalidTLS},
+		{name: "no key", tls: &TLSConfig{CertFile: certFile}, err: ErrInvalidTLS},
+		{name: "no certificate", tls: &TLSConfig{KeyFile: keyFile}, err: ErrInvalidTLS},
+		{name: "missing ca", tls: &TLSConfig{CAFile: filepath.Join(dir, "missing.pem")}, err: ErrInvalidTLS},
+		{name: "ca not pem", tls: &TLSConfig{CAFile: notPEM}, err: ErrInvalidTLS},
+		{name: "key mismatch", tls: &TLSConfig{CertFile: certFile, KeyFile: notPEM}, err: ErrInvalidTLS},
+	} {
+		err := tcase.tls.validate()
+
+		var tlsConfig *tls.Config
+		if err == nil {
+			tlsConfig, err = tcase.tls.ClientConfig()
+		}
+
+		if !errors.Is(err, tcase.err) {
+			t.Fatalf("%s: expected error %v, got %v", tcase.name, tcase.err, err)
+		}
+
+		if tcase.err != nil {
+			continue
+		}
+
+		if tlsConfig.MinVersion != tcase.version {
+			t.Fatalf("%s: expected min version %x, got %x", tcase.name, tcase.version, tlsConfig.MinVersion)
+		}
+
+		if len(tlsConfig.Certificates) != tcase.certs {
+			t.Fatalf("%s: expected %d certificates, got %d", tcase.name, tcase.certs, len(tlsConfig.Certificates))
+		}
+
+		if (tlsConfig.RootCAs != nil) != tcase.roots {
+			t.Fatalf("%s: expected root CAs %t, got %t", tcase.name, tcase.roots, tlsConfig.RootCAs != nil)
+		}
+
+		if tlsConfig.InsecureSkipVerify != tcase.tls.InsecureSkipVerify {
+			t.Fatalf("%s: expected insecure %t, got %t", tcase.name, tcase.tls.InsecureSkipVerify,
+				tlsConfig.InsecureSkipVerify)
+		}
+	}
+}
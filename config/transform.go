@@ -0,0 +1,72 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// The unit conversions that can be applied to the columns of the records of a request before they are written.
+const (
+	// TransformEpochToRFC3339 converts unix seconds to an RFC 3339 time in UTC.
+	TransformEpochToRFC3339 = "epochToRFC3339"
+
+	// TransformEpochMillisToRFC3339 converts unix milliseconds to an RFC 3339 time in UTC.
+	TransformEpochMillisToRFC3339 = "epochMillisToRFC3339"
+
+	// TransformSatoshisToBTC converts satoshis to a decimal amount of bitcoin.
+	TransformSatoshisToBTC = "satoshisToBTC"
+
+	// TransformCentsToCurrency converts cents, or the minor unit of any currency with two decimal places, to a
+	// decimal amount of the currency.
+	TransformCentsToCurrency = "centsToCurrency"
+
+	// TransformBytesToMB converts bytes to decimal megabytes, i.e. 1,000,000 bytes.
+	TransformBytesToMB = "bytesToMB"
+)
+
+// transforms are the names of every transform.
+var transforms = []string{
+	TransformEpochToRFC3339,
+	TransformEpochMillisToRFC3339,
+	TransformSatoshisToBTC,
+	TransformCentsToCurrency,
+	TransformBytesToMB,
+}
+
+// validateTransforms will ensure that every column is transformed by one of the transforms.
+func validateTransforms(field string, columns map[string]string) error {
+	names := make([]string, 0, len(columns))
+	for column := range columns {
+		names = append(names, column)
+	}
+
+	// Columns are checked in order so that the same misconfiguration always reports the same column.
+	sort.Strings(names)
+
+	for _, column := range names {
+		if !isTransform(columns[column]) {
+			return fmt.Errorf("%w: %s.%s %q must be one of %s", ErrInvalidTransform, field, column, columns[column],
+				strings.Join(transforms, ", "))
+		}
+	}
+
+	return nil
+}
+
+func isTransform(name string) bool {
+	for _, transform := range transforms {
+		if name == transform {
+			return true
+		}
+	}
+
+	return false
+}
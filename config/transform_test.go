@@ -0,0 +1,70 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestValidateTransforms(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name    string
+		columns map[string]string
+		err     error
+	}{
+		{name: "none"},
+		{name: "valid", columns: map[string]string{"t": TransformEpochToRFC3339, "fee": TransformSatoshisToBTC}},
+		{name: "unknown", columns: map[string]string{"t": "epochToISO"}, err: ErrInvalidTransform},
+	} {
+		if err := validateTransforms("transforms", tcase.columns); !errors.Is(err, tcase.err) {
+			t.Fatalf("%s: expected error %v, got %v", tcase.name, tcase.err, err)
+		}
+	}
+}
+
+func TestNewAppliesTransforms(t *testing.T) {
+	t.Parallel()
+
+	data := `
+version: 1
+url: https://example.com
+connectionStrings:
+  - mongodb://localhost:27017/db
+rateLimit:
+  burst: 1
+  period: 1
+tables:
+  trades:
+    transforms:
+      time: epochMillisToRFC3339
+requests:
+  - endpoint: /trades
+  - endpoint: /trades/daily
+    table: trades
+    transforms:
+      price: centsToCurrency
+`
+
+	cfg, err := New(context.Background(), newTestConfigFile(t, data))
+	if err != nil {
+		t.Fatalf("failed to create config: %v", err)
+	}
+
+	if got := cfg.Requests[0].Transforms; !reflect.DeepEqual(got, map[string]string{"time": "epochMillisToRFC3339"}) {
+		t.Fatalf("expected the table transforms to be applied, got %v", got)
+	}
+
+	if got := cfg.Requests[1].Transforms; !reflect.DeepEqual(got, map[string]string{"price": "centsToCurrency"}) {
+		t.Fatalf("expected the request transforms to take precedence, got %v", got)
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// TTL expires the records of a table a duration after the time in one of their columns, on storage that expires
+// records natively, e.g. with a TTL index on MongoDB. Other storage keeps the records.
+type TTL struct {
+	// Column is the timestamp column that the records expire after, e.g. "updated_at", which holds RFC 3339 times.
+	Column string `yaml:"column"`
+
+	// After is how long after the time of its column a record expires, e.g. "720h".
+	After string `yaml:"after"`
+}
+
+func (ttl *TTL) validate(field string) error {
+	if ttl.Column == "" {
+		return fmt.Errorf("%w: %s.column is required", ErrInvalidTTL, field)
+	}
+
+	after, err := time.ParseDuration(ttl.After)
+	if err != nil {
+		return fmt.Errorf("%w: %s.after %q must be a duration, e.g. \"720h\"", ErrInvalidTTL, field, ttl.After)
+	}
+
+	if after < time.Second {
+		return fmt.Errorf("%w: %s.after must be at least a second", ErrInvalidTTL, field)
+	}
+
+	return nil
+}
+
+// Duration returns how long after the time of its column a record expires.
+func (ttl *TTL) Duration() time.Duration {
+	// The duration has been validated with the configuration.
+	after, _ := time.ParseDuration(ttl.After)
+
+	return after
+}
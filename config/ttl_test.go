@@ -0,0 +1,46 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTTL(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name    string
+		ttl     *TTL
+		encrypt *Encrypt
+		err     error
+	}{
+		{name: "no ttl"},
+		{name: "ttl", ttl: &TTL{Column: "updated_at", After: "720h"}},
+		{name: "no column", ttl: &TTL{After: "720h"}, err: ErrInvalidTTL},
+		{name: "not a duration", ttl: &TTL{Column: "updated_at", After: "30d"}, err: ErrInvalidTTL},
+		{name: "under a second", ttl: &TTL{Column: "updated_at", After: "10ms"}, err: ErrInvalidTTL},
+		{
+			name:    "encrypted column",
+			ttl:     &TTL{Column: "updated_at", After: "1h"},
+			encrypt: &Encrypt{KeyID: "2024", Columns: []string{"updated_at"}},
+			err:     ErrInvalidEncryption,
+		},
+	} {
+		req := &Request{Endpoint: "/sessions", TTL: tcase.ttl, Encrypt: tcase.encrypt}
+		if err := req.validate(); !errors.Is(err, tcase.err) {
+			t.Fatalf("%s: expected error %v, got %v", tcase.name, tcase.err, err)
+		}
+	}
+
+	if got := (&TTL{Column: "updated_at", After: "1h30m"}).Duration(); got != 90*time.Minute {
+		t.Fatalf("expected 1h30m, got %s", got)
+	}
+}
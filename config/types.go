@@ -0,0 +1,104 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The types that the columns of "types" are coerced to.
+const (
+	TypeInt       = "int"
+	TypeFloat     = "float"
+	TypeBool      = "bool"
+	TypeTimestamp = "timestamp"
+
+	// TypeDecimal is a decimal with a precision and a scale, e.g. "decimal(10,2)", whose values are rounded to the
+	// scale.
+	TypeDecimal = "decimal"
+)
+
+// What is done with a record when one of its values cannot be coerced to the type of its column.
+const (
+	// OnTypeErrorFail fails the transactions that the record is written to, like a failed upsert. This is the
+	// default.
+	OnTypeErrorFail = "error"
+
+	// OnTypeErrorNull writes the value as null.
+	OnTypeErrorNull = "null"
+
+	// OnTypeErrorSkip leaves the record out, logging a warning with the number of records left out.
+	OnTypeErrorSkip = "skip"
+)
+
+// decimalType matches the decimal type and captures its precision and scale.
+var decimalType = regexp.MustCompile(`^decimal\(\s*(\d+)\s*,\s*(\d+)\s*\)$`)
+
+// ColumnType is a type that the values of a column are coerced to.
+type ColumnType struct {
+	// Name is one of the types, e.g. "decimal".
+	Name string
+
+	// Precision and Scale are the number of digits of a decimal, and of those after the decimal point.
+	Precision, Scale int
+}
+
+// ParseColumnType will parse a type of "types", e.g. "int" or "decimal(10,2)".
+func ParseColumnType(str string) (*ColumnType, error) {
+	str = strings.ToLower(strings.TrimSpace(str))
+
+	switch str {
+	case TypeInt, TypeFloat, TypeBool, TypeTimestamp:
+		return &ColumnType{Name: str}, nil
+	}
+
+	match := decimalType.FindStringSubmatch(str)
+	if match == nil {
+		return nil, fmt.Errorf("%w: %q must be one of %s, %s, %s, %s or %s(<precision>,<scale>)", ErrInvalidTypes, str,
+			TypeInt, TypeFloat, TypeBool, TypeTimestamp, TypeDecimal)
+	}
+
+	precision, _ := strconv.Atoi(match[1])
+	scale, _ := strconv.Atoi(match[2])
+
+	if precision == 0 || scale > precision {
+		return nil, fmt.Errorf("%w: %q must have a precision of at least 1 and a scale of at most its precision",
+			ErrInvalidTypes, str)
+	}
+
+	return &ColumnType{Name: TypeDecimal, Precision: precision, Scale: scale}, nil
+}
+
+// validateTypes will ensure that every column is coerced to one of the types, and that "onTypeError" is one of the
+// policies.
+func validateTypes(field string, types map[string]string, onTypeError string) error {
+	names := make([]string, 0, len(types))
+	for column := range types {
+		names = append(names, column)
+	}
+
+	sort.Strings(names)
+
+	for _, column := range names {
+		if _, err := ParseColumnType(types[column]); err != nil {
+			return fmt.Errorf("%s.%s: %w", field, column, err)
+		}
+	}
+
+	switch onTypeError {
+	case "", OnTypeErrorFail, OnTypeErrorNull, OnTypeErrorSkip:
+		return nil
+	default:
+		return fmt.Errorf("%w: onTypeError of %s %q must be %q, %q or %q", ErrInvalidTypes, field, onTypeError,
+			OnTypeErrorFail, OnTypeErrorNull, OnTypeErrorSkip)
+	}
+}
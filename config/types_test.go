@@ -0,0 +1,63 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseColumnType(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		str  string
+		want *ColumnType
+		err  error
+	}{
+		{str: "int", want: &ColumnType{Name: TypeInt}},
+		{str: " Timestamp ", want: &ColumnType{Name: TypeTimestamp}},
+		{str: "decimal(10,2)", want: &ColumnType{Name: TypeDecimal, Precision: 10, Scale: 2}},
+		{str: "decimal( 4, 4 )", want: &ColumnType{Name: TypeDecimal, Precision: 4, Scale: 4}},
+		{str: "decimal(2,3)", err: ErrInvalidTypes},
+		{str: "decimal(0,0)", err: ErrInvalidTypes},
+		{str: "decimal", err: ErrInvalidTypes},
+		{str: "varchar", err: ErrInvalidTypes},
+	} {
+		got, err := ParseColumnType(tcase.str)
+		if !errors.Is(err, tcase.err) {
+			t.Fatalf("%q: expected error %v, got %v", tcase.str, tcase.err, err)
+		}
+
+		if !reflect.DeepEqual(got, tcase.want) {
+			t.Fatalf("%q: expected %+v, got %+v", tcase.str, tcase.want, got)
+		}
+	}
+}
+
+func TestRequestTypes(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name        string
+		types       map[string]string
+		onTypeError string
+		err         error
+	}{
+		{name: "no types"},
+		{name: "types", types: map[string]string{"price": "decimal(10,2)", "id": "int"}, onTypeError: OnTypeErrorSkip},
+		{name: "unknown type", types: map[string]string{"price": "money"}, err: ErrInvalidTypes},
+		{name: "unknown policy", types: map[string]string{"id": "int"}, onTypeError: "drop", err: ErrInvalidTypes},
+	} {
+		req := &Request{Endpoint: "/prices", Types: tcase.types, OnTypeError: tcase.onTypeError}
+		if err := req.validate(); !errors.Is(err, tcase.err) {
+			t.Fatalf("%s: expected error %v, got %v", tcase.name, tcase.err, err)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v2"
+)
+
+// CurrentVersion is the version of the configuration format understood by this version of gidari. Configuration
+// files without a "version" field are treated as the legacy, unversioned format and are migrated.
+const CurrentVersion = 1
+
+// legacyVersion is the version of configuration files that do not declare one.
+const legacyVersion = 0
+
+// yamlDoc is a decoded YAML mapping.
+type yamlDoc = map[interface{}]interface{}
+
+// migration upgrades a decoded configuration file from the version "from" to the version "from + 1", returning a
+// warning for every change that it makes.
+type migration struct {
+	from    int
+	migrate func(doc yamlDoc) ([]string, error)
+}
+
+// migrations are the registered migrations, in order. Every version below "CurrentVersion" must have a migration.
+var migrations = []migration{
+	{from: legacyVersion, migrate: migrateLegacy},
+}
+
+// migrate will upgrade the raw YAML configuration to the current version, returning the upgraded YAML, the version
+// that the file declared, and warnings for every deprecated construct that was rewritten.
+func migrate(data []byte) ([]byte, int, []string, error) {
+	doc := make(yamlDoc)
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, 0, nil, fmt.Errorf("unable to unmarshal YAML: %w", err)
+	}
+
+	declared, err := docVersion(doc)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	if declared > CurrentVersion {
+		return nil, 0, nil, fmt.Errorf("%w: version %d is newer than the latest supported version %d, "+
+			"upgrade gidari to use this configuration file", ErrUnsupportedVersion, declared, CurrentVersion)
+	}
+
+	if declared == CurrentVersion {
+		return data, declared, nil, nil
+	}
+
+	var warnings []string
+
+	if declared == legacyVersion {
+		warnings = append(warnings, fmt.Sprintf("configuration file has no version, assuming the legacy format; "+
+			"add \"version: %d\" once any deprecation warnings are resolved", CurrentVersion))
+	}
+
+	for _, mig := range migrations {
+		if mig.from < declared {
+			continue
+		}
+
+		warns, err := mig.migrate(doc)
+		if err != nil {
+			return nil, 0, nil, fmt.Errorf("unable to migrate configuration from version %d: %w", mig.from, err)
+		}
+
+		warnings = append(warnings, warns...)
+		doc["version"] = mig.from + 1
+	}
+
+	migrated, err := yaml.Marshal(doc)
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("unable to marshal migrated configuration: %w", err)
+	}
+
+	return migrated, declared, warnings, nil
+}
+
+// docVersion returns the version declared by a decoded configuration file.
+func docVersion(doc yamlDoc) (int, error) {
+	raw, ok := doc["version"]
+	if !ok {
+		return legacyVersion, nil
+	}
+
+	version, ok := raw.(int)
+	if !ok || version < 1 {
+		return 0, fmt.Errorf("%w: %v is not a positive integer", ErrUnsupportedVersion, raw)
+	}
+
+	return version, nil
+}
+
+// renameKey will move the value at "from" to "to" in the mapping, returning a deprecation warning if it did. It is an
+// error for both keys to be set.
+func renameKey(doc yamlDoc, path, from, to string) (string, error) {
+	val, ok := doc[from]
+	if !ok {
+		return "", nil
+	}
+
+	if _, exists := doc[to]; exists {
+		return "", fmt.Errorf("%w: %s%s and %s%s are both set, remove %s%s", ErrInvalidMigration, path, from, path, to,
+			path, from)
+	}
+
+	delete(doc, from)
+	doc[to] = val
+
+	return fmt.Sprintf("%s%s is deprecated, use %s%s", path, from, path, to), nil
+}
+
+// migrateLegacy will upgrade an unversioned configuration file to version 1.
+//
+// - "connectionString" is folded into the "connectionStrings" list.
+// - "requests[].timseries", a misspelling from earlier documentation, is renamed to "requests[].timeseries".
+func migrateLegacy(doc yamlDoc) ([]string, error) {
+	var warnings []string
+
+	if dns, ok := doc["connectionString"]; ok {
+		str, isStr := dns.(string)
+		if !isStr {
+			return nil, fmt.Errorf("%w: connectionString must be a string", ErrInvalidMigration)
+		}
+
+		list, _ := doc["connectionStrings"].([]interface{})
+		doc["connectionStrings"] = append(list, str)
+
+		delete(doc, "connectionString")
+
+		warnings = append(warnings, "connectionString is deprecated, use the connectionStrings list")
+	}
+
+	reqs, _ := doc["requests"].([]interface{})
+	for idx, raw := range reqs {
+		req, ok := raw.(yamlDoc)
+		if !ok {
+			continue
+		}
+
+		warn, err := renameKey(req, fmt.Sprintf("requests[%d].", idx), "timseries", "timeseries")
+		if err != nil {
+			return nil, err
+		}
+
+		if warn != "" {
+			warnings = append(warnings, warn)
+		}
+	}
+
+	return warnings, nil
+}
@@ -0,0 +1,126 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const legacyConfig = `
+url: https://earthquake.usgs.gov
+connectionString: mongodb://mongo1:27017/earthquakes
+rateLimit:
+  burst: 5
+  period: 1
+requests:
+  - endpoint: /fdsnws/event/1/query
+    query:
+      starttime: 2020-01-01T00:00:00Z
+      endtime: 2020-01-04T19:50:02Z
+    timseries:
+      startName: starttime
+      endName: endtime
+      period: 18000
+`
+
+func newTestConfigFile(t *testing.T, data string) *os.File {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open config: %v", err)
+	}
+
+	t.Cleanup(func() { file.Close() })
+
+	return file
+}
+
+func TestMigrate(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name     string
+		data     string
+		warnings int
+		err      error
+	}{
+		{name: "current", data: "version: 1\nurl: https://example.com\n"},
+		{name: "legacy", data: legacyConfig, warnings: 3},
+		{name: "unversioned", data: "url: https://example.com\n", warnings: 1},
+		{name: "newer", data: "version: 2\n", err: ErrUnsupportedVersion},
+		{name: "invalid", data: "version: latest\n", err: ErrUnsupportedVersion},
+		{
+			name: "conflicting keys",
+			data: "requests:\n  - timseries: {}\n    timeseries: {}\n",
+			err:  ErrInvalidMigration,
+		},
+	} {
+		tcase := tcase
+
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, _, warnings, err := migrate([]byte(tcase.data))
+			if !errors.Is(err, tcase.err) {
+				t.Fatalf("expected error %v, got %v", tcase.err, err)
+			}
+
+			if len(warnings) != tcase.warnings {
+				t.Fatalf("expected %d warnings, got %d: %v", tcase.warnings, len(warnings), warnings)
+			}
+		})
+	}
+}
+
+func TestNewMigratesLegacyConfig(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := New(context.Background(), newTestConfigFile(t, legacyConfig))
+	if err != nil {
+		t.Fatalf("failed to create config: %v", err)
+	}
+
+	if cfg.Version != CurrentVersion {
+		t.Fatalf("expected version %d, got %d", CurrentVersion, cfg.Version)
+	}
+
+	if len(cfg.ConnectionStrings) != 1 {
+		t.Fatalf("expected the connection string to be migrated, got %v", cfg.ConnectionStrings)
+	}
+
+	req := cfg.Requests[0]
+	if req.Timeseries == nil || req.Timeseries.Period != 18000 {
+		t.Fatalf("expected the timeseries to be migrated, got %+v", req.Timeseries)
+	}
+
+	if req.Query["starttime"] != "2020-01-01T00:00:00Z" {
+		t.Fatalf("unexpected query after migration: %v", req.Query)
+	}
+}
+
+func TestNewStrictVersionedConfig(t *testing.T) {
+	t.Parallel()
+
+	data := "version: 1\nurl: https://example.com\nrateLimit:\n  burst: 1\n  period: 1\nrequestz: []\n"
+
+	_, err := New(context.Background(), newTestConfigFile(t, data))
+	if err == nil || !strings.Contains(err.Error(), "requestz") {
+		t.Fatalf("expected an error naming the unknown field, got %v", err)
+	}
+}
@@ -0,0 +1,42 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import "fmt"
+
+const (
+	// WorkspaceRetainNever will always remove the workspace at the end of a run. This is the default.
+	WorkspaceRetainNever = "never"
+
+	// WorkspaceRetainOnFailure will keep the workspace if the run fails, for debugging.
+	WorkspaceRetainOnFailure = "onFailure"
+
+	// WorkspaceRetainAlways will always keep the workspace at the end of a run.
+	WorkspaceRetainAlways = "always"
+)
+
+// WorkspaceConfig configures the directory that holds the temporary files of a run, such as spilled response bodies.
+type WorkspaceConfig struct {
+	// Dir is the parent directory for run workspaces. Each run creates its own directory inside of it. The
+	// default is the system temporary directory.
+	Dir string `yaml:"dir"`
+
+	// Retain is the policy for keeping the run workspace once the run is over, one of "never", "onFailure" or
+	// "always". The default is "never".
+	Retain string `yaml:"retain"`
+}
+
+func (ws *WorkspaceConfig) validate() error {
+	switch ws.Retain {
+	case "", WorkspaceRetainNever, WorkspaceRetainOnFailure, WorkspaceRetainAlways:
+	default:
+		return fmt.Errorf("%w: %q", ErrInvalidWorkspaceRetain, ws.Retain)
+	}
+
+	return nil
+}
@@ -0,0 +1,36 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// XMLFormat is how the elements of an XML response body are read into records.
+type XMLFormat struct {
+	// RecordPath is the path of the elements that are records, from the root element, e.g. "rss/channel/item" for
+	// the items of an RSS feed. Elements are matched by their local name, without their namespace.
+	RecordPath string `yaml:"recordPath"`
+}
+
+func (format *XMLFormat) validate(endpoint string) error {
+	for _, name := range format.Path() {
+		if name == "" {
+			return fmt.Errorf("%w: recordPath %q of %s must be element names separated by slashes", ErrInvalidXML,
+				format.RecordPath, endpoint)
+		}
+	}
+
+	return nil
+}
+
+// Path returns the names of the elements of the record path, from the root element.
+func (format *XMLFormat) Path() []string {
+	return strings.Split(strings.Trim(format.RecordPath, "/"), "/")
+}
@@ -0,0 +1,39 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package config
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestRequestXML(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name   string
+		format string
+		xml    *XMLFormat
+		err    error
+	}{
+		{name: "record path", format: ResponseFormatXML, xml: &XMLFormat{RecordPath: "rss/channel/item"}},
+		{name: "no record path", format: ResponseFormatXML, err: ErrInvalidXML},
+		{name: "empty element", format: ResponseFormatXML, xml: &XMLFormat{RecordPath: "rss//item"}, err: ErrInvalidXML},
+		{name: "not xml", format: ResponseFormatCSV, xml: &XMLFormat{RecordPath: "rss"}, err: ErrInvalidXML},
+	} {
+		req := &Request{Endpoint: "/feed", ResponseFormat: tcase.format, XML: tcase.xml}
+		if err := req.validate(); !errors.Is(err, tcase.err) {
+			t.Fatalf("%s: expected error %v, got %v", tcase.name, tcase.err, err)
+		}
+	}
+
+	if path := (&XMLFormat{RecordPath: "/feed/entry/"}).Path(); !reflect.DeepEqual(path, []string{"feed", "entry"}) {
+		t.Fatalf("expected the record path to be trimmed of slashes, got %v", path)
+	}
+}
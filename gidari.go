@@ -12,11 +12,203 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"time"
 
 	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/internal/external"
+	"github.com/alpstable/gidari/internal/handoff"
+	"github.com/alpstable/gidari/internal/hooks"
 	"github.com/alpstable/gidari/internal/transport"
+	"github.com/alpstable/gidari/internal/web/auth"
 )
 
+// ErrInterrupted is returned by "Transport" when its context is canceled. Requests that were in-flight are stored,
+// the data is committed, and the committed requests are recorded in the checkpoint so that the run can be resumed.
+var ErrInterrupted = transport.ErrInterrupted
+
+// ErrBudgetExceeded is returned by "Transport" when the run reaches one of the configured "limits". As with
+// "ErrInterrupted", the data fetched before the limit was reached is committed and the run can be resumed.
+var ErrBudgetExceeded = transport.ErrBudgetExceeded
+
+// ErrDeadlineExceeded is returned by "Transport" when the run reaches its "maxDuration" or "deadline". As with
+// "ErrInterrupted", the data fetched before the deadline is committed and the run can be resumed.
+var ErrDeadlineExceeded = transport.ErrDeadlineExceeded
+
+// ErrInvalidExport is returned by "Export" when the export options are invalid.
+var ErrInvalidExport = transport.ErrInvalidExport
+
+// ErrPreflightFailed is returned by "Preflight", and by "Transport" if "preflight" is enabled, when a source or
+// storage target cannot be reached. The error lists every check that failed.
+var ErrPreflightFailed = transport.ErrPreflightFailed
+
+// ErrAssertionFailed is returned by "Transport" when the metrics of a completed run do not hold for one of the
+// configured "assertions". Unlike "ErrInterrupted", the data of the run has been committed in full.
+var ErrAssertionFailed = transport.ErrAssertionFailed
+
+// ErrInvalidDocs is returned by "Docs" when the docs options are invalid.
+var ErrInvalidDocs = transport.ErrInvalidDocs
+
+// ErrInvalidFixtures is returned by "Fixtures" when the fixtures options are invalid.
+var ErrInvalidFixtures = transport.ErrInvalidFixtures
+
+// ErrInvalidDiffRuns is returned by "DiffRuns" when the diff-runs options are invalid, or a manifest cannot be read.
+var ErrInvalidDiffRuns = transport.ErrInvalidDiffRuns
+
+// ErrRunsDiffer is returned by "DiffRuns" when the second run differs from the baseline.
+var ErrRunsDiffer = transport.ErrRunsDiffer
+
+// ErrInvalidRetryFailed is returned by "RetryFailed" when its options are invalid, the manifest of the run cannot be
+// read, or the configuration has changed since the run.
+var ErrInvalidRetryFailed = transport.ErrInvalidRetryFailed
+
+// ExportOptions are the settings for "Export".
+type ExportOptions = transport.ExportOptions
+
+// DocsOptions are the settings for "Docs".
+type DocsOptions = transport.DocsOptions
+
+// FixturesOptions are the settings for "Fixtures".
+type FixturesOptions = transport.FixturesOptions
+
+// DiffRunsOptions are the settings for "DiffRuns".
+type DiffRunsOptions = transport.DiffRunsOptions
+
+// RetryFailedOptions are the settings for "RetryFailed".
+type RetryFailedOptions = transport.RetryFailedOptions
+
+// Authenticator is a custom authentication scheme, e.g. the request signing of a proprietary web API. Its
+// "Authenticate" method signs or modifies every outgoing request, and "Unauthorized" is called with a "401
+// Unauthorized" response and returns true for the request to be authenticated and sent again, once. Authenticators
+// that also implement "Expiry() (time.Time, bool)" report when their credentials expire.
+type Authenticator = auth.Authenticator
+
+// AuthenticatorFactory returns the authenticator of a scheme for the "authentication.custom.settings" of a
+// configuration.
+type AuthenticatorFactory = auth.AuthenticatorFactory
+
+// ErrAuthenticatorRegistered is returned by "RegisterAuthenticator" when the name of the scheme is already
+// registered.
+var ErrAuthenticatorRegistered = auth.ErrAuthenticatorRegistered
+
+// RegisterAuthenticator will register a custom authentication scheme under "name", for the configurations with
+// "authentication.custom.scheme" set to it. Schemes are registered before the configuration is loaded, usually in
+// an "init" function, and a name can only be registered once.
+func RegisterAuthenticator(name string, factory AuthenticatorFactory) error {
+	if err := auth.RegisterAuthenticator(name, factory); err != nil {
+		return fmt.Errorf("unable to register authenticator: %w", err)
+	}
+
+	return nil
+}
+
+// Storage is a storage backend for the connection strings of a scheme, e.g. a data warehouse that is not built into
+// Gidari. Backends that write the batches of a transaction atomically also implement "StorageTransactional", and
+// those that can read a table back out implement "StorageReader".
+type Storage = external.Storage
+
+// StorageBatch is a batch of records that is written to a table of a "Storage".
+type StorageBatch = external.Batch
+
+// StorageTransactional is implemented by a "Storage" with transactions. "Begin" is called when a transaction is
+// started, and either "Commit" or "Rollback" once it is finished.
+type StorageTransactional = external.Transactional
+
+// StorageReader is implemented by a "Storage" that can read the records of a table back out.
+type StorageReader = external.Reader
+
+// StorageFactory returns the storage for a connection string with the scheme that it is registered for.
+type StorageFactory = external.Factory
+
+// ErrStorageRegistered is returned by "RegisterStorage" when storage is already registered for the scheme, or it is
+// the scheme of a built-in storage device.
+var ErrStorageRegistered = external.ErrStorageRegistered
+
+// RegisterStorage will register a storage backend for the connection strings with "scheme", e.g. "snowflake" for
+// "snowflake://...". Backends are registered before the configuration is transported, usually in an "init"
+// function, and a scheme can only be registered once.
+func RegisterStorage(scheme string, factory StorageFactory) error {
+	if err := external.Register(scheme, factory); err != nil {
+		return fmt.Errorf("unable to register storage: %w", err)
+	}
+
+	return nil
+}
+
+// TransformHook is run on every record that is written to the tables of the requests that list it in their "hooks",
+// once the record is decoded and its types are coerced, and before it is masked, encrypted and stored. Numbers are
+// "json.Number". It returns the record that is written in its place, or nil for the record to be dropped, and an
+// error fails the transactions that the records are written to.
+type TransformHook = hooks.Hook
+
+// ErrTransformHookRegistered is returned by "RegisterTransformHook" when the name of the hook is already registered.
+var ErrTransformHookRegistered = hooks.ErrHookRegistered
+
+// RegisterTransformHook will register a transform hook under "name", for the requests and tables with "hooks" that
+// list it. Hooks are registered before the configuration is loaded, usually in an "init" function, and a name can
+// only be registered once.
+func RegisterTransformHook(name string, hook TransformHook) error {
+	if err := hooks.Register(name, hook); err != nil {
+		return fmt.Errorf("unable to register transform hook: %w", err)
+	}
+
+	return nil
+}
+
+// Variables are the keyed variables of the state store, which are kept across runs. Request templates read them with
+// "{{ state "name" }}" and write them with "{{ setState "name" value }}".
+type Variables = hooks.Variables
+
+// RunHook is run once a run has completed successfully, for the configurations that list it in their "runHooks",
+// with the variables of the state store. The variables that it sets are saved for the templates of the next run.
+type RunHook = hooks.RunHook
+
+// ErrRunHookRegistered is returned by "RegisterRunHook" when the name of the hook is already registered.
+var ErrRunHookRegistered = hooks.ErrRunHookRegistered
+
+// RegisterRunHook will register a run hook under "name", for the configurations with "runHooks" that list it. Hooks
+// are registered before the configuration is loaded, usually in an "init" function, and a name can only be
+// registered once.
+func RegisterRunHook(name string, hook RunHook) error {
+	if err := hooks.RegisterRun(name, hook); err != nil {
+		return fmt.Errorf("unable to register run hook: %w", err)
+	}
+
+	return nil
+}
+
+// ConfigBuilder builds a configuration in Go, for embedding the transport in other services without a configuration
+// file. The request methods, e.g. "Table" and "Timeseries", apply to the request that was last added with
+// "Request", and "Build" validates the configuration like "config.New".
+type ConfigBuilder = config.Builder
+
+// NewConfig returns a builder of a configuration for the web API at "baseURL".
+func NewConfig(baseURL string) *ConfigBuilder {
+	return config.NewBuilder(baseURL)
+}
+
+// ConfigSchema returns the JSON Schema of the configuration file, for editors to validate and autocomplete
+// configuration files.
+func ConfigSchema() ([]byte, error) {
+	schema, err := config.Schema()
+	if err != nil {
+		return nil, fmt.Errorf("unable to generate the configuration schema: %w", err)
+	}
+
+	return schema, nil
+}
+
+// ExampleConfig returns a runnable sample configuration of the web API of a provider profile, e.g. "coinbase",
+// "github" or "alpaca": its rate limit, a stub of its credentials and a few of its endpoints, with timeseries chunks
+// and page sizes, written to the SQLite database file at "database".
+func ExampleConfig(provider, database string) ([]byte, error) {
+	data, err := config.Example(provider, database, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("unable to generate the example configuration: %w", err)
+	}
+
+	return data, nil
+}
+
 // Transport will construct the transport operation using a "transport.Config" object.
 func Transport(ctx context.Context, cfg *config.Config) error {
 	if err := transport.Upsert(ctx, cfg); err != nil {
@@ -26,6 +218,117 @@ func Transport(ctx context.Context, cfg *config.Config) error {
 	return nil
 }
 
+// HandoffTable is a table of "TransportStream": a reader of the records that the run writes to the table, as
+// newline-delimited JSON.
+type HandoffTable = handoff.Table
+
+// TransportStream will run the transport like "Transport", handing off the records of every table as they are
+// written to storage, so that they can be forwarded elsewhere while the run continues. Each table is sent on the
+// first channel when its first records are written, and the channel is closed once the run is done. The error of the
+// run, or nil, is then sent on the second channel, and is returned by every table once its records have been read.
+//
+// The run waits for each table to be received and for its records to be read, so each table should be read in a
+// goroutine of its own. Tables that are closed are no longer handed off.
+func TransportStream(ctx context.Context, cfg *config.Config) (<-chan *HandoffTable, <-chan error) {
+	hand := handoff.New(ctx)
+	cfg.Handoff = hand
+
+	errs := make(chan error, 1)
+
+	go func() {
+		err := Transport(ctx, cfg)
+
+		hand.Close(err)
+		errs <- err
+	}()
+
+	return hand.Tables(), errs
+}
+
+// Preflight will check that every source of the configured requests is reachable with the configured
+// authentication, and that every storage target can be connected to, without fetching any data.
+func Preflight(ctx context.Context, cfg *config.Config) error {
+	if err := transport.Preflight(ctx, cfg); err != nil {
+		return fmt.Errorf("unable to start the run: %w", err)
+	}
+
+	return nil
+}
+
+// Replay will re-execute the requests captured in the dead-letter file configured by "deadLetter", upserting their
+// responses to storage. Requests that fail again are kept in the dead-letter file.
+func Replay(ctx context.Context, cfg *config.Config) error {
+	if err := transport.Replay(ctx, cfg); err != nil {
+		return fmt.Errorf("unable to replay dead-lettered requests: %w", err)
+	}
+
+	return nil
+}
+
+// Export will read the records of a table that has been ingested back out of storage into files. Sliced exports
+// write one file per slice of time and skip the slices that have already been written, so they can be resumed.
+func Export(ctx context.Context, cfg *config.Config, opts ExportOptions) error {
+	if err := transport.Export(ctx, cfg, opts); err != nil {
+		return fmt.Errorf("unable to export: %w", err)
+	}
+
+	return nil
+}
+
+// Docs will sample the responses of the configured requests and write a data dictionary of the tables that they
+// are written to, for the consumers of the ingested data. Nothing is written to storage.
+func Docs(ctx context.Context, cfg *config.Config, opts DocsOptions) error {
+	if err := transport.Docs(ctx, cfg, opts); err != nil {
+		return fmt.Errorf("unable to document the config: %w", err)
+	}
+
+	return nil
+}
+
+// Fixtures will capture a sample response of the configured requests into files, scrubbed of credentials and of the
+// personal data of the "fixtures" configuration, for offline tests against a mock server. Nothing is written to
+// storage.
+func Fixtures(ctx context.Context, cfg *config.Config, opts FixturesOptions) error {
+	if err := transport.Fixtures(ctx, cfg, opts); err != nil {
+		return fmt.Errorf("unable to capture fixtures: %w", err)
+	}
+
+	return nil
+}
+
+// DiffRuns will compare the manifests that two runs wrote to the "manifest.dir", e.g. of the same configuration
+// before and after an upgrade, writing the differences in the rows, chunk coverage and columns of their tables, and
+// their durations.
+func DiffRuns(opts DiffRunsOptions) error {
+	if err := transport.DiffRuns(opts); err != nil {
+		return fmt.Errorf("unable to compare runs: %w", err)
+	}
+
+	return nil
+}
+
+// RetryFailed will make the failed requests of a previous run again: the requests that it dead-lettered, and the
+// requests of a batch that failed to commit, upserting their responses to storage. The configuration has to be the
+// configuration file that the run was made with, which "RetryFailedConfig" returns.
+func RetryFailed(ctx context.Context, cfg *config.Config, opts RetryFailedOptions) error {
+	if err := transport.RetryFailed(ctx, cfg, opts); err != nil {
+		return fmt.Errorf("unable to retry the failed requests: %w", err)
+	}
+
+	return nil
+}
+
+// RetryFailedConfig returns the path of the configuration file that the run of the options was made with, as it is
+// recorded in the manifest of the run.
+func RetryFailedConfig(opts RetryFailedOptions) (string, error) {
+	path, err := transport.RetryFailedConfig(opts)
+	if err != nil {
+		return "", fmt.Errorf("unable to find the configuration of the run: %w", err)
+	}
+
+	return path, nil
+}
+
 // TransportFile will construct the transport operation using a configuration YAML file.
 func TransportFile(ctx context.Context, file *os.File) error {
 	cfg, err := config.New(ctx, file)
@@ -3,8 +3,12 @@ module github.com/alpstable/gidari
 go 1.19
 
 require (
+	github.com/andybalholm/brotli v1.0.4
+	github.com/go-sql-driver/mysql v1.7.0
 	github.com/google/uuid v1.1.2
+	github.com/klauspost/compress v1.13.6
 	github.com/lib/pq v1.10.7
+	github.com/mattn/go-sqlite3 v1.14.16
 	github.com/sirupsen/logrus v1.9.0
 	github.com/spf13/cobra v1.6.0
 	go.mongodb.org/mongo-driver v1.10.3
@@ -17,7 +21,6 @@ require (
 require (
 	github.com/golang/snappy v0.0.1 // indirect
 	github.com/inconshreveable/mousetrap v1.0.1 // indirect
-	github.com/klauspost/compress v1.13.6 // indirect
 	github.com/kr/text v0.2.0 // indirect
 	github.com/montanaflynn/stats v0.0.0-20171201202039-1bf9dbcd8cbe // indirect
 	github.com/niemeyer/pretty v0.0.0-20200227124842-a10e7caefd8e // indirect
@@ -0,0 +1,931 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package bigquery
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alpstable/gidari/internal/proto"
+	"github.com/alpstable/gidari/internal/workspace"
+	"github.com/alpstable/gidari/tools"
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+var (
+	ErrTransactionNotFound = fmt.Errorf("transaction not found")
+	ErrInvalidTable        = fmt.Errorf("invalid table name")
+	ErrInvalidOptions      = fmt.Errorf("invalid bigquery options")
+	ErrMissingCredentials  = fmt.Errorf("missing bigquery credentials")
+	ErrClosed              = fmt.Errorf("bigquery storage is closed")
+)
+
+// bigQueryTxType is a type alias for the bigquery transaction type.
+type bigQueryTxType uint8
+
+const (
+	basicBigQueryTxID bigQueryTxType = iota
+)
+
+// The modes that the records of a table are written in.
+const (
+	// modeLoad writes the records with a load job, which is free and makes the records available once the job is
+	// done.
+	modeLoad = "load"
+
+	// modeStream writes the records with streaming inserts, which are billed and make the records available
+	// right away.
+	modeStream = "stream"
+)
+
+// The write dispositions of load jobs.
+const (
+	writeAppend   = "WRITE_APPEND"
+	writeTruncate = "WRITE_TRUNCATE"
+)
+
+const (
+	// streamBatchRows and streamBatchBytes are the most rows, and the largest body, of a streaming insert.
+	streamBatchRows  = 500
+	streamBatchBytes = 9 << 20
+
+	// readPageSize is the number of rows fetched by each page of a read.
+	readPageSize = 1000
+
+	// pollInterval is how long to wait between checks of a job that has not completed.
+	pollInterval = time.Second
+
+	// pingTimeout is how long "Ping" waits for the dataset to respond.
+	pingTimeout = 10 * time.Second
+
+	// tempDir is the directory of the workspace that the transaction files of the storage are created in.
+	tempDir = "bigquery"
+)
+
+// maxNameLength is the longest name of a dataset or table.
+const maxNameLength = 1024
+
+var (
+	nameRegex    = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+	projectRegex = regexp.MustCompile(`^[A-Za-z0-9.:-]+$`)
+)
+
+// validName returns true if a name can be the name of a dataset or table.
+func validName(name string) bool {
+	return len(name) <= maxNameLength && nameRegex.MatchString(name)
+}
+
+// BigQuery is a storage device that writes the records of every table into a table of a BigQuery dataset, with
+// load jobs or streaming inserts. Tables are created with a schema inferred from their records, and fields are
+// added to the schema as new fields appear.
+type BigQuery struct {
+	client *client
+
+	// modes and dispositions are the write mode and write disposition of each table. The empty table is the
+	// default for every table.
+	modes        map[string]string
+	dispositions map[string]string
+
+	mu     sync.Mutex
+	closed bool
+
+	// loaded are the tables that a load job has been started for. Since a storage device is opened for every run,
+	// only the first load of a table in a run has its WRITE_TRUNCATE disposition.
+	loaded map[string]bool
+
+	// schemaMu is held while a schema is updated, so that only one update of a table is made at a time, and
+	// schemas are the known schemas of the tables.
+	schemaMu sync.Mutex
+	schemas  map[string][]*field
+
+	// activeTx are the transactions that are currently active, keyed by the transaction ID that "StartTx" adds to
+	// the context of the functions sent to the transaction.
+	activeTx sync.Map
+
+	// ws is the workspace of the run that the storage is constructed for, which holds its transaction files.
+	ws *workspace.Workspace
+}
+
+// parseTableOptions will parse the options of a connection string that are set for every table, e.g. "mode", or
+// for a single table, e.g. "mode.trades".
+func parseTableOptions(query url.Values, name string) map[string]string {
+	opts := make(map[string]string)
+
+	for key, vals := range query {
+		switch {
+		case key == name:
+			opts[""] = vals[0]
+		case strings.HasPrefix(key, name+"."):
+			opts[strings.TrimPrefix(key, name+".")] = vals[0]
+		}
+	}
+
+	return opts
+}
+
+// parseConnectionString will return the storage for a "bigquery://" connection string, e.g.
+// "bigquery://project/dataset?location=EU&mode=stream". Requests are authorized with the service account key in
+// the "credentials" option or "GOOGLE_APPLICATION_CREDENTIALS", or with the access token in
+// "GOOGLE_OAUTH_ACCESS_TOKEN". Requests to an "endpoint" other than BigQuery, e.g. an emulator, may be made without
+// credentials.
+func parseConnectionString(connectionURL string, getenv func(string) string, clock tools.Clock) (*BigQuery, error) {
+	dns, err := url.Parse(connectionURL)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse connection string: %w", err)
+	}
+
+	if dns.Scheme != proto.SchemeFromStorageType(proto.BigQueryType) {
+		return nil, proto.DNSNotSupportedError(dns.Scheme)
+	}
+
+	dataset := strings.Trim(dns.Path, "/")
+	if !projectRegex.MatchString(dns.Host) || !validName(dataset) {
+		return nil, fmt.Errorf("%w: expected bigquery://project/dataset", proto.DNSNotSupportedError(dns.Scheme))
+	}
+
+	query := dns.Query()
+
+	stg := &BigQuery{
+		client: &client{
+			http:         http.DefaultClient,
+			project:      dns.Host,
+			dataset:      dataset,
+			location:     query.Get("location"),
+			pollInterval: pollInterval,
+			clock:        tools.ClockOrReal(clock),
+		},
+		modes:        map[string]string{"": modeLoad},
+		dispositions: map[string]string{"": writeAppend},
+		loaded:       make(map[string]bool),
+		schemas:      make(map[string][]*field),
+	}
+
+	rawEndpoint := query.Get("endpoint")
+	if rawEndpoint == "" {
+		rawEndpoint = defaultEndpoint
+	}
+
+	if stg.client.endpoint, err = url.Parse(rawEndpoint); err != nil || stg.client.endpoint.Host == "" ||
+		(stg.client.endpoint.Scheme != "http" && stg.client.endpoint.Scheme != "https") {
+		return nil, fmt.Errorf("%w: invalid endpoint %q", ErrInvalidOptions, rawEndpoint)
+	}
+
+	for table, mode := range parseTableOptions(query, "mode") {
+		if mode != modeLoad && mode != modeStream {
+			return nil, fmt.Errorf("%w: mode has to be load or stream, got %q", ErrInvalidOptions, mode)
+		}
+
+		stg.modes[table] = mode
+	}
+
+	for table, disposition := range parseTableOptions(query, "writeDisposition") {
+		disposition = strings.ToUpper(disposition)
+		if disposition != writeAppend && disposition != writeTruncate {
+			return nil, fmt.Errorf("%w: writeDisposition has to be WRITE_APPEND or WRITE_TRUNCATE, got %q",
+				ErrInvalidOptions, disposition)
+		}
+
+		stg.dispositions[table] = disposition
+	}
+
+	// Streaming inserts can only append to a table.
+	for table, disposition := range stg.dispositions {
+		if disposition == writeTruncate && stg.mode(table) == modeStream {
+			return nil, fmt.Errorf("%w: writeDisposition WRITE_TRUNCATE needs the load mode", ErrInvalidOptions)
+		}
+	}
+
+	switch path := firstNonEmpty(query.Get("credentials"), getenv("GOOGLE_APPLICATION_CREDENTIALS")); {
+	case path != "":
+		if stg.client.tokens, err = readServiceAccount(path, stg.client.http, clock); err != nil {
+			return nil, err
+		}
+	case getenv("GOOGLE_OAUTH_ACCESS_TOKEN") != "":
+		stg.client.tokens = staticToken(getenv("GOOGLE_OAUTH_ACCESS_TOKEN"))
+	case query.Get("endpoint") == "":
+		return nil, fmt.Errorf("%w: set GOOGLE_APPLICATION_CREDENTIALS or GOOGLE_OAUTH_ACCESS_TOKEN",
+			ErrMissingCredentials)
+	}
+
+	return stg, nil
+}
+
+func firstNonEmpty(vals ...string) string {
+	for _, val := range vals {
+		if val != "" {
+			return val
+		}
+	}
+
+	return ""
+}
+
+// New will return a new storage device for writing records into the BigQuery dataset of the connection string.
+func New(ctx context.Context, connectionURL string) (*BigQuery, error) {
+	stg, err := parseConnectionString(connectionURL, os.Getenv, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	stg.ws = workspace.FromContext(ctx)
+
+	return stg, nil
+}
+
+// mode returns the write mode of a table.
+func (stg *BigQuery) mode(table string) string {
+	if mode, ok := stg.modes[table]; ok {
+		return mode
+	}
+
+	return stg.modes[""]
+}
+
+// disposition returns the write disposition of the load jobs of a table.
+func (stg *BigQuery) disposition(table string) string {
+	if disposition, ok := stg.dispositions[table]; ok {
+		return disposition
+	}
+
+	return stg.dispositions[""]
+}
+
+// loadDisposition returns the write disposition of the next load job of a table, which is WRITE_APPEND once a table
+// has been loaded so that a WRITE_TRUNCATE table is only replaced by the first batch of a run. The table is marked as
+// loaded until "unload" is called for a load job that failed.
+func (stg *BigQuery) loadDisposition(table string) string {
+	stg.mu.Lock()
+	defer stg.mu.Unlock()
+
+	if stg.loaded[table] {
+		return writeAppend
+	}
+
+	stg.loaded[table] = true
+
+	return stg.disposition(table)
+}
+
+// unload will mark a table as not loaded, after its first load job failed.
+func (stg *BigQuery) unload(table string) {
+	stg.mu.Lock()
+	defer stg.mu.Unlock()
+
+	delete(stg.loaded, table)
+}
+
+func validateTable(table string) error {
+	if !validName(table) {
+		return fmt.Errorf("%w: %q", ErrInvalidTable, table)
+	}
+
+	return nil
+}
+
+// ensureSchema will create a table with the inferred fields of its records if it does not exist, or add the fields
+// that it does not have yet, returning the schema of the table.
+func (stg *BigQuery) ensureSchema(ctx context.Context, table string, inferred []*field) ([]*field, error) {
+	stg.schemaMu.Lock()
+	defer stg.schemaMu.Unlock()
+
+	fields, known := stg.schemas[table]
+	if !known {
+		info, err := stg.client.getTable(ctx, table)
+
+		switch {
+		case errors.Is(err, errNotFound):
+			if err := stg.client.createTable(ctx, table, inferred); err != nil {
+				return nil, fmt.Errorf("unable to create table %q: %w", table, err)
+			}
+
+			stg.schemas[table] = inferred
+
+			return inferred, nil
+		case err != nil:
+			return nil, fmt.Errorf("unable to get table %q: %w", table, err)
+		case info.Schema != nil:
+			fields = info.Schema.Fields
+		}
+	}
+
+	merged, changed := mergeFields(fields, inferred)
+	if changed {
+		if err := stg.client.patchSchema(ctx, table, merged); err != nil {
+			return nil, fmt.Errorf("unable to add fields to table %q: %w", table, err)
+		}
+	}
+
+	stg.schemas[table] = merged
+
+	return merged, nil
+}
+
+// encodeRows returns the newline-delimited JSON rows of records, and the fields inferred from them.
+func encodeRows(structs []*structpb.Struct) ([]byte, []*field, error) {
+	var (
+		body   []byte
+		fields []*field
+	)
+
+	for _, record := range structs {
+		row, _ := sanitize(record.AsMap()).(map[string]interface{})
+
+		data, err := json.Marshal(row)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", proto.ErrFailedToMarshalJSON, err)
+		}
+
+		body = append(append(body, data...), '\n')
+		fields = inferFields(fields, row)
+	}
+
+	return body, fields, nil
+}
+
+// writeOptions are the write mode and conflict keys of the records of an upsert. BigQuery tables have no primary
+// key, so records are only merged into a table when the request declares conflict keys.
+type writeOptions struct {
+	mode string
+	keys []string
+}
+
+func newWriteOptions(mode string, keys []string) writeOptions {
+	columns := make([]string, len(keys))
+	for idx, key := range keys {
+		columns[idx] = sanitizeName(key)
+	}
+
+	return writeOptions{mode: proto.WriteModeOrDefault(mode), keys: columns}
+}
+
+// merges returns true if the records are merged into the table on their conflict keys instead of appended.
+func (opts writeOptions) merges() bool {
+	return opts.mode != proto.WriteModeInsert && len(opts.keys) > 0
+}
+
+// mergeQuery returns the script that merges the rows of the staging table into a table on the conflict keys, and
+// then drops the staging table. Rows that match an existing row are updated by an upsert and skipped by an append,
+// and only one row is merged for each conflict key of the staging table.
+func (stg *BigQuery) mergeQuery(table, staging string, fields []*field, opts writeOptions) string {
+	path := func(name string) string {
+		return fmt.Sprintf("`%s.%s.%s`", stg.client.project, stg.client.dataset, name)
+	}
+
+	conditions := make([]string, len(opts.keys))
+	for idx, key := range opts.keys {
+		conditions[idx] = fmt.Sprintf("target.`%s` = source.`%s`", key, key)
+	}
+
+	var updates []string
+
+	for _, fld := range fields {
+		if !proto.IsConflictKey(opts.keys, fld.Name) {
+			updates = append(updates, fmt.Sprintf("`%s` = source.`%s`", fld.Name, fld.Name))
+		}
+	}
+
+	sql := fmt.Sprintf("MERGE %s AS target USING (SELECT * FROM %s WHERE TRUE QUALIFY ROW_NUMBER() OVER "+
+		"(PARTITION BY `%s`) = 1) AS source ON %s", path(table), path(staging), strings.Join(opts.keys, "`, `"),
+		strings.Join(conditions, " AND "))
+
+	if len(updates) > 0 && opts.mode == proto.WriteModeUpsert {
+		sql += " WHEN MATCHED THEN UPDATE SET " + strings.Join(updates, ", ")
+	}
+
+	return sql + fmt.Sprintf(" WHEN NOT MATCHED THEN INSERT ROW; DROP TABLE %s", path(staging))
+}
+
+// merge will load the rows of "body" into a staging table with the schema of the table, and merge them into the
+// table on their conflict keys.
+func (stg *BigQuery) merge(ctx context.Context, table string, fields []*field, opts writeOptions, rows int,
+	body io.Reader,
+) (int, error) {
+	suffix := strings.ReplaceAll(uuid.New().String(), "-", "")
+	staging := fmt.Sprintf("%s_gidari_staging_%s", table, suffix)
+
+	if err := stg.client.load(ctx, "gidari_load_"+suffix, staging, fields, writeTruncate, body); err != nil {
+		return 0, fmt.Errorf("unable to load staging table for %q: %w", table, err)
+	}
+
+	sql := stg.mergeQuery(table, staging, fields, opts)
+	if err := stg.client.query(ctx, "gidari_merge_"+suffix, sql); err != nil {
+		return 0, fmt.Errorf("unable to merge into table %q: %w", table, err)
+	}
+
+	return rows, nil
+}
+
+// batchTooLarge returns a "proto.BatchTooLargeError" for the error of a write that BigQuery rejected for its size,
+// with the number of rows that were written before it, and the error itself otherwise.
+func batchTooLarge(err error, written int) error {
+	if errors.Is(err, errRequestTooLarge) {
+		return &proto.BatchTooLargeError{Written: written, Err: err}
+	}
+
+	return err
+}
+
+// write will write the newline-delimited JSON rows of "body" into a table, returning the number of rows written.
+// Writes that BigQuery rejects for their size return a "proto.BatchTooLargeError".
+func (stg *BigQuery) write(ctx context.Context, table string, opts writeOptions, inferred []*field, rows int,
+	body io.Reader,
+) (int, error) {
+	fields, err := stg.ensureSchema(ctx, table, inferred)
+	if err != nil {
+		return 0, err
+	}
+
+	if opts.merges() {
+		written, err := stg.merge(ctx, table, fields, opts, rows, body)
+
+		return written, batchTooLarge(err, 0)
+	}
+
+	if stg.mode(table) == modeStream {
+		return stg.stream(ctx, table, body)
+	}
+
+	jobID := "gidari_load_" + strings.ReplaceAll(uuid.New().String(), "-", "")
+	disposition := stg.loadDisposition(table)
+
+	if err := stg.client.load(ctx, jobID, table, fields, disposition, body); err != nil {
+		if disposition == writeTruncate {
+			stg.unload(table)
+		}
+
+		return 0, batchTooLarge(fmt.Errorf("unable to load table %q: %w", table, err), 0)
+	}
+
+	return rows, nil
+}
+
+// stream will insert the newline-delimited JSON rows of "body" into a table with streaming inserts. An insert that
+// BigQuery rejects for its size is sent again in halves, so that the rows of transactions, which are inserted when
+// they are committed, are split as well.
+func (stg *BigQuery) stream(ctx context.Context, table string, body io.Reader) (int, error) {
+	var (
+		inserted int
+		batch    []json.RawMessage
+		size     int
+		insert   func(rows []json.RawMessage) error
+	)
+
+	insert = func(rows []json.RawMessage) error {
+		count, err := stg.client.insertAll(ctx, table, rows)
+		if errors.Is(err, errRequestTooLarge) && len(rows) > 1 {
+			if err := insert(rows[:len(rows)/2]); err != nil {
+				return err
+			}
+
+			return insert(rows[len(rows)/2:])
+		}
+
+		if err != nil {
+			return batchTooLarge(fmt.Errorf("unable to insert into table %q: %w", table, err), inserted)
+		}
+
+		inserted += count
+
+		return nil
+	}
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		err := insert(batch)
+		batch, size = batch[:0], 0
+
+		return err
+	}
+
+	reader := bufio.NewReader(body)
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) && len(line) == 0 {
+			break
+		}
+
+		if err != nil && !errors.Is(err, io.EOF) {
+			return inserted, fmt.Errorf("unable to read rows: %w", err)
+		}
+
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		if len(batch) == streamBatchRows || (len(batch) > 0 && size+len(line) > streamBatchBytes) {
+			if err := flush(); err != nil {
+				return inserted, err
+			}
+		}
+
+		batch = append(batch, line)
+		size += len(line)
+	}
+
+	return inserted, flush()
+}
+
+// bigQueryTx holds the rows of every table sent to a transaction in a temporary file, which is written to the
+// table when the transaction is committed.
+type bigQueryTx struct {
+	id string
+	ws *workspace.Workspace
+
+	mu     sync.Mutex
+	tables []string
+	files  map[string]*txFile
+
+	// truncated are the tables truncated in the transaction, which are truncated when it is committed before its
+	// rows are written.
+	truncated []string
+}
+
+// txFile holds the rows of a table sent to a transaction.
+type txFile struct {
+	temp   *os.File
+	opts   writeOptions
+	fields []*field
+	rows   int
+}
+
+func (btx *bigQueryTx) write(table string, opts writeOptions, fields []*field, rows int, body []byte) error {
+	btx.mu.Lock()
+	defer btx.mu.Unlock()
+
+	file := btx.files[table]
+	if file == nil {
+		temp, err := btx.ws.CreateTemp(tempDir, fmt.Sprintf("gidari-bigquery-%s-*.ndjson", btx.id))
+		if err != nil {
+			return fmt.Errorf("unable to create transaction file: %w", err)
+		}
+
+		file = &txFile{temp: temp, opts: opts}
+		btx.files[table] = file
+		btx.tables = append(btx.tables, table)
+	}
+
+	if _, err := file.temp.Write(body); err != nil {
+		return fmt.Errorf("unable to write transaction file: %w", err)
+	}
+
+	file.fields, _ = mergeFields(file.fields, fields)
+	file.rows += rows
+
+	return nil
+}
+
+// discard will remove the temporary files of a transaction.
+func (btx *bigQueryTx) discard() {
+	for _, file := range btx.files {
+		file.temp.Close()
+		os.Remove(file.temp.Name())
+	}
+}
+
+// upsert will write records into a table.
+func (stg *BigQuery) upsert(ctx context.Context, table string, structs []*structpb.Struct, opts writeOptions,
+) (int, error) {
+	if err := validateTable(table); err != nil {
+		return 0, err
+	}
+
+	stg.mu.Lock()
+	closed := stg.closed
+	stg.mu.Unlock()
+
+	if closed {
+		return 0, ErrClosed
+	}
+
+	body, fields, err := encodeRows(structs)
+	if err != nil {
+		return 0, err
+	}
+
+	if txID, ok := ctx.Value(basicBigQueryTxID).(string); ok {
+		stored, ok := stg.activeTx.Load(txID)
+		if !ok {
+			return 0, ErrTransactionNotFound
+		}
+
+		btx, ok := stored.(*bigQueryTx)
+		if !ok {
+			return 0, ErrTransactionNotFound
+		}
+
+		return len(structs), btx.write(table, opts, fields, len(structs), body)
+	}
+
+	return stg.write(ctx, table, opts, fields, len(structs), bytes.NewReader(body))
+}
+
+// Upsert will write the records on the request into the table. Rows are appended, unless the table has the
+// WRITE_TRUNCATE disposition, in which case the first load job of the run replaces the rows of the table. Requests
+// with conflict keys, and a write mode other than "insert", are merged into the table on those keys with a load job
+// and a MERGE statement instead, regardless of the mode and disposition of the table.
+func (stg *BigQuery) Upsert(ctx context.Context, req *proto.UpsertRequest) (*proto.UpsertResponse, error) {
+	records, err := proto.DecodeUpsertRequest(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode records: %w", err)
+	}
+
+	// Do nothing if there are no records.
+	if len(records) == 0 {
+		return &proto.UpsertResponse{}, nil
+	}
+
+	count, err := stg.upsert(ctx, req.GetTable(), records, newWriteOptions(req.GetWriteMode(), req.GetConflictKeys()))
+	if err != nil {
+		return nil, fmt.Errorf("unable to upsert: %w", err)
+	}
+
+	return &proto.UpsertResponse{UpsertedCount: int64(count)}, nil
+}
+
+// UpsertBinary will write "property bag"-like records, with the data encoded as a JSON string.
+func (stg *BigQuery) UpsertBinary(ctx context.Context,
+	req *proto.UpsertBinaryRequest,
+) (*proto.UpsertBinaryResponse, error) {
+	records, err := proto.DecodeUpsertBinaryRequest(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode records: %w", err)
+	}
+
+	// Do nothing if there are no records.
+	if len(records) == 0 {
+		return &proto.UpsertBinaryResponse{}, nil
+	}
+
+	opts := newWriteOptions(req.GetWriteMode(), req.GetConflictKeys())
+	if _, err := stg.upsert(ctx, req.GetTable(), records, opts); err != nil {
+		return nil, fmt.Errorf("unable to upsert: %w", err)
+	}
+
+	return &proto.UpsertBinaryResponse{}, nil
+}
+
+// Close will stop the storage from writing records.
+func (stg *BigQuery) Close() {
+	stg.mu.Lock()
+	defer stg.mu.Unlock()
+
+	stg.closed = true
+}
+
+// ListPrimaryKeys will return no primary keys for the tables of the dataset, since rows of BigQuery have no
+// enforced primary keys.
+func (stg *BigQuery) ListPrimaryKeys(ctx context.Context) (*proto.ListPrimaryKeysResponse, error) {
+	tables, err := stg.client.listTables(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list tables: %w", err)
+	}
+
+	rsp := &proto.ListPrimaryKeysResponse{PKSet: make(map[string]*proto.PrimaryKeys)}
+	for _, table := range tables {
+		rsp.PKSet[table] = &proto.PrimaryKeys{}
+	}
+
+	return rsp, nil
+}
+
+// ListTables will list the tables of the dataset. The size of a table is its number of bytes, including the
+// estimated bytes of rows that were streamed into it.
+func (stg *BigQuery) ListTables(ctx context.Context) (*proto.ListTablesResponse, error) {
+	tables, err := stg.client.listTables(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list tables: %w", err)
+	}
+
+	rsp := &proto.ListTablesResponse{TableSet: make(map[string]*proto.Table)}
+
+	for _, table := range tables {
+		info, err := stg.client.getTable(ctx, table)
+		if errors.Is(err, errNotFound) {
+			continue
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("unable to get table %q: %w", table, err)
+		}
+
+		size, _ := strconv.ParseInt(info.NumBytes, 10, 64)
+
+		if info.StreamingBuffer != nil {
+			buffered, _ := strconv.ParseInt(info.StreamingBuffer.EstimatedBytes, 10, 64)
+			size += buffered
+		}
+
+		rsp.TableSet[table] = &proto.Table{Size: size}
+	}
+
+	return rsp, nil
+}
+
+// truncate will delete every row of the tables with a "TRUNCATE TABLE" query. Tables that do not exist are ignored.
+func (stg *BigQuery) truncate(ctx context.Context, tables []string) error {
+	for _, table := range tables {
+		if _, err := stg.client.getTable(ctx, table); errors.Is(err, errNotFound) {
+			continue
+		}
+
+		jobID := "gidari_truncate_" + strings.ReplaceAll(uuid.New().String(), "-", "")
+		sql := fmt.Sprintf("TRUNCATE TABLE `%s.%s.%s`", stg.client.project, stg.client.dataset, table)
+
+		if err := stg.client.query(ctx, jobID, sql); err != nil {
+			return fmt.Errorf("unable to truncate table %q: %w", table, err)
+		}
+	}
+
+	return nil
+}
+
+// Truncate will delete every row of the tables on the request with a "TRUNCATE TABLE" query, keeping their schema.
+// Tables that do not exist are ignored. In a transaction, the tables are truncated when it is committed, before its
+// rows are written.
+func (stg *BigQuery) Truncate(ctx context.Context, req *proto.TruncateRequest) (*proto.TruncateResponse, error) {
+	for _, table := range req.GetTables() {
+		if err := validateTable(table); err != nil {
+			return nil, err
+		}
+	}
+
+	if txID, ok := ctx.Value(basicBigQueryTxID).(string); ok {
+		stored, ok := stg.activeTx.Load(txID)
+		if !ok {
+			return nil, ErrTransactionNotFound
+		}
+
+		btx, ok := stored.(*bigQueryTx)
+		if !ok {
+			return nil, ErrTransactionNotFound
+		}
+
+		btx.mu.Lock()
+		btx.truncated = append(btx.truncated, req.GetTables()...)
+		btx.mu.Unlock()
+
+		return &proto.TruncateResponse{}, nil
+	}
+
+	if err := stg.truncate(ctx, req.GetTables()); err != nil {
+		return nil, err
+	}
+
+	return &proto.TruncateResponse{}, nil
+}
+
+// Read will call "fn" with every row of the table on the request. If the request has an "OrderBy" column, the rows
+// are read into memory and sorted.
+func (stg *BigQuery) Read(ctx context.Context, req *proto.ReadRecordsRequest, fn proto.ReadFunc) error {
+	if err := validateTable(req.Table); err != nil {
+		return err
+	}
+
+	if req.OrderBy == "" {
+		return stg.client.listRows(ctx, req.Table, readPageSize, fn)
+	}
+
+	var records []map[string]interface{}
+
+	if err := stg.client.listRows(ctx, req.Table, readPageSize, func(record map[string]interface{}) error {
+		records = append(records, record)
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		return proto.LessValue(records[i][req.OrderBy], records[j][req.OrderBy])
+	})
+
+	for _, record := range records {
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Ping will return an error if the storage is closed or the dataset cannot be reached.
+func (stg *BigQuery) Ping() error {
+	stg.mu.Lock()
+	closed := stg.closed
+	stg.mu.Unlock()
+
+	if closed {
+		return ErrClosed
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+	defer cancel()
+
+	if err := stg.client.ping(ctx); err != nil {
+		return fmt.Errorf("connection lost: %w", err)
+	}
+
+	return nil
+}
+
+// IsNoSQL returns "true" since the schema of each table is inferred from its records.
+func (stg *BigQuery) IsNoSQL() bool { return true }
+
+// Type implements the storage interface.
+func (stg *BigQuery) Type() uint8 { return proto.BigQueryType }
+
+// StartTx will start a transaction. Records sent to the transaction are held until it is committed, and discarded
+// if it is rolled back. Each table is written with its own load job, so if the job of a table fails on commit, the
+// tables that were written before it are kept.
+func (stg *BigQuery) StartTx(ctx context.Context) (*proto.Txn, error) {
+	txn := &proto.Txn{
+		FunctionCh: make(chan proto.TxnChanFn),
+		DoneCh:     make(chan error, 1),
+		CommitCh:   make(chan bool, 1),
+	}
+
+	txnID := uuid.New().String()
+	btx := &bigQueryTx{id: txnID, ws: stg.ws, files: make(map[string]*txFile)}
+
+	stg.activeTx.Store(txnID, btx)
+
+	// Create a copy of the parent context with a transaction ID.
+	bigQueryCtx := context.WithValue(ctx, basicBigQueryTxID, txnID)
+
+	go func() {
+		defer stg.activeTx.Delete(txnID)
+		defer btx.discard()
+
+		var err error
+
+		for fn := range txn.FunctionCh {
+			if err != nil {
+				continue
+			}
+
+			err = fn(bigQueryCtx, stg)
+		}
+
+		if err != nil {
+			txn.DoneCh <- err
+
+			return
+		}
+
+		if !<-txn.CommitCh {
+			txn.DoneCh <- nil
+
+			return
+		}
+
+		txn.DoneCh <- stg.commit(ctx, btx)
+	}()
+
+	return txn, nil
+}
+
+// commit will write the rows of every table of a transaction.
+func (stg *BigQuery) commit(ctx context.Context, btx *bigQueryTx) error {
+	if err := stg.truncate(ctx, btx.truncated); err != nil {
+		return err
+	}
+
+	for _, table := range btx.tables {
+		file := btx.files[table]
+
+		if _, err := file.temp.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("unable to read transaction file: %w", err)
+		}
+
+		if _, err := stg.write(ctx, table, file.opts, file.fields, file.rows, file.temp); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
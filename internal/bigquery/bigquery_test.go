@@ -0,0 +1,1100 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package bigquery
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alpstable/gidari/internal/proto"
+	"github.com/alpstable/gidari/internal/workspace"
+)
+
+const (
+	testProject = "gidari-test"
+	testDataset = "market"
+	testToken   = "test-token"
+
+	// fakePageSize is the number of rows in every page of "tabledata.list" of the fake API, which is smaller than
+	// the page size of the storage so that reads span several pages.
+	fakePageSize = 2
+)
+
+type fakeTable struct {
+	fields   []*field
+	rows     []map[string]interface{}
+	streamed int
+}
+
+// fakeAPI is an in-memory BigQuery dataset that implements the requests of the REST API that the storage makes.
+type fakeAPI struct {
+	mu     sync.Mutex
+	tables map[string]*fakeTable
+	jobs   map[string]*job
+
+	// loads are the write dispositions of every load job, inserts the number of rows of every streaming insert,
+	// patches the tables whose schema was patched, and merges the MERGE scripts that were run.
+	loads   []string
+	inserts []int
+	patches []string
+	merges  []string
+}
+
+func newFakeAPI() *fakeAPI {
+	return &fakeAPI{tables: make(map[string]*fakeTable), jobs: make(map[string]*job)}
+}
+
+func writeAPIError(w http.ResponseWriter, status int, msg string) {
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `{"error":{"code":%d,"message":%q}}`, status, msg)
+}
+
+func writeJSON(w http.ResponseWriter, val interface{}) {
+	_ = json.NewEncoder(w).Encode(val)
+}
+
+func (api *fakeAPI) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	if req.Header.Get("Authorization") != "Bearer "+testToken {
+		writeAPIError(w, http.StatusUnauthorized, "Request is missing required authentication credential.")
+
+		return
+	}
+
+	upload := strings.HasPrefix(req.URL.Path, "/upload")
+	path := strings.TrimPrefix(strings.TrimPrefix(req.URL.Path, "/upload"), "/bigquery/v2/projects/"+testProject)
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+
+	switch {
+	case upload && path == "/jobs" && req.Method == http.MethodPost:
+		api.load(w, req)
+	case path == "/jobs" && req.Method == http.MethodPost:
+		api.query(w, req)
+	case len(parts) == 2 && parts[0] == "jobs":
+		stored := api.jobs[parts[1]]
+		if stored == nil {
+			writeAPIError(w, http.StatusNotFound, "Not found: Job")
+
+			return
+		}
+
+		writeJSON(w, stored)
+	case len(parts) < 2 || parts[0] != "datasets" || parts[1] != testDataset:
+		writeAPIError(w, http.StatusNotFound, "Not found: Dataset")
+	case len(parts) == 2:
+		writeJSON(w, map[string]interface{}{"datasetReference": map[string]string{"datasetId": testDataset}})
+	case len(parts) == 3 && req.Method == http.MethodGet:
+		api.listTables(w)
+	case len(parts) == 3 && req.Method == http.MethodPost:
+		var info tableInfo
+		_ = json.NewDecoder(req.Body).Decode(&info)
+
+		if api.tables[info.TableReference.TableID] != nil {
+			writeAPIError(w, http.StatusConflict, "Already Exists: Table")
+
+			return
+		}
+
+		api.tables[info.TableReference.TableID] = &fakeTable{fields: info.Schema.Fields}
+		writeJSON(w, info)
+	default:
+		table := api.tables[parts[3]]
+		if table == nil {
+			writeAPIError(w, http.StatusNotFound, "Not found: Table")
+
+			return
+		}
+
+		api.serveTable(w, req, parts[3], table, parts[4:])
+	}
+}
+
+func (api *fakeAPI) listTables(w http.ResponseWriter) {
+	names := make([]string, 0, len(api.tables))
+	for name := range api.tables {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	tables := make([]map[string]interface{}, len(names))
+	for idx, name := range names {
+		tables[idx] = map[string]interface{}{"tableReference": map[string]string{"tableId": name}}
+	}
+
+	writeJSON(w, map[string]interface{}{"tables": tables})
+}
+
+func (api *fakeAPI) serveTable(w http.ResponseWriter, req *http.Request, name string, table *fakeTable,
+	rest []string,
+) {
+	switch {
+	case len(rest) == 0 && req.Method == http.MethodGet:
+		size := 0
+
+		for _, row := range table.rows[:len(table.rows)-table.streamed] {
+			data, _ := json.Marshal(row)
+			size += len(data)
+		}
+
+		info := map[string]interface{}{
+			"tableReference": map[string]string{"tableId": name},
+			"schema":         schema{Fields: table.fields},
+			"numBytes":       strconv.Itoa(size),
+			"numRows":        strconv.Itoa(len(table.rows) - table.streamed),
+		}
+
+		if table.streamed > 0 {
+			info["streamingBuffer"] = map[string]string{"estimatedBytes": strconv.Itoa(100 * table.streamed)}
+		}
+
+		writeJSON(w, info)
+	case len(rest) == 0 && req.Method == http.MethodPatch:
+		var patch tableInfo
+		_ = json.NewDecoder(req.Body).Decode(&patch)
+
+		// Fields may only be added to a schema.
+		for idx, fld := range table.fields {
+			if idx >= len(patch.Schema.Fields) || patch.Schema.Fields[idx].Name != fld.Name {
+				writeAPIError(w, http.StatusBadRequest, "Provided Schema does not match Table")
+
+				return
+			}
+		}
+
+		table.fields = patch.Schema.Fields
+		api.patches = append(api.patches, name)
+		writeJSON(w, patch)
+	case len(rest) == 1 && rest[0] == "insertAll":
+		api.insertAll(w, req, table)
+	case len(rest) == 1 && rest[0] == "data":
+		offset, _ := strconv.Atoi(req.URL.Query().Get("pageToken"))
+
+		end := offset + fakePageSize
+		if end > len(table.rows) {
+			end = len(table.rows)
+		}
+
+		rows := make([]interface{}, 0, end-offset)
+		for _, row := range table.rows[offset:end] {
+			rows = append(rows, encodeRow(table.fields, row))
+		}
+
+		page := map[string]interface{}{"rows": rows}
+		if end < len(table.rows) {
+			page["pageToken"] = strconv.Itoa(end)
+		}
+
+		writeJSON(w, page)
+	default:
+		writeAPIError(w, http.StatusBadRequest, "unsupported request")
+	}
+}
+
+// checkRow returns an error if a row has a field that is not in the schema of its table, the way loading it would,
+// or if it is marked to fail.
+func checkRow(fields []*field, row map[string]interface{}) error {
+	if row["fail"] == true {
+		return fmt.Errorf("invalid value for field fail")
+	}
+
+	for key, val := range row {
+		var found *field
+
+		for _, fld := range fields {
+			if fld.Name == key {
+				found = fld
+			}
+		}
+
+		if found == nil {
+			return fmt.Errorf("no such field: %s", key)
+		}
+
+		if nested, ok := val.(map[string]interface{}); ok && found.Type == typeRecord {
+			if err := checkRow(found.Fields, nested); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (api *fakeAPI) finishJob(created *job, err error) {
+	created.Status = &jobStatus{State: "RUNNING"}
+
+	// The job is done the first time it is polled.
+	done := *created
+	done.Status = &jobStatus{State: "DONE"}
+
+	if err != nil {
+		done.Status.ErrorResult = &errorProto{Reason: "invalid", Message: err.Error()}
+		done.Status.Errors = []errorProto{*done.Status.ErrorResult}
+	}
+
+	api.jobs[created.JobReference.JobID] = &done
+}
+
+func (api *fakeAPI) load(w http.ResponseWriter, req *http.Request) {
+	_, params, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if err != nil || req.URL.Query().Get("uploadType") != "multipart" {
+		writeAPIError(w, http.StatusBadRequest, "expected a multipart upload")
+
+		return
+	}
+
+	reader := multipart.NewReader(req.Body, params["boundary"])
+
+	var created job
+
+	metadata, err := reader.NextPart()
+	if err == nil {
+		err = json.NewDecoder(metadata).Decode(&created)
+	}
+
+	var cfg loadConfig
+	if err == nil {
+		data, _ := json.Marshal(created.Configuration["load"])
+		err = json.Unmarshal(data, &cfg)
+	}
+
+	var rows []map[string]interface{}
+
+	data, err := reader.NextPart()
+	if err == nil {
+		scanner := bufio.NewScanner(data)
+
+		for scanner.Scan() {
+			var row map[string]interface{}
+			if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+				writeAPIError(w, http.StatusBadRequest, err.Error())
+
+				return
+			}
+
+			rows = append(rows, row)
+		}
+	}
+
+	if err != nil && !errors.Is(err, io.EOF) {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+
+		return
+	}
+
+	api.loads = append(api.loads, cfg.WriteDisposition)
+
+	var fields []*field
+	if cfg.Schema != nil {
+		fields = cfg.Schema.Fields
+	}
+
+	var loadErr error
+
+	for _, row := range rows {
+		if loadErr = checkRow(fields, row); loadErr != nil {
+			break
+		}
+	}
+
+	if loadErr == nil {
+		table := api.tables[cfg.DestinationTable.TableID]
+		if table == nil {
+			table = &fakeTable{}
+			api.tables[cfg.DestinationTable.TableID] = table
+		}
+
+		if cfg.WriteDisposition == writeTruncate {
+			table.rows, table.streamed = nil, 0
+		}
+
+		table.fields = fields
+		table.rows = append(rows, table.rows...)
+	}
+
+	api.finishJob(&created, loadErr)
+	writeJSON(w, created)
+}
+
+func (api *fakeAPI) query(w http.ResponseWriter, req *http.Request) {
+	var created job
+	_ = json.NewDecoder(req.Body).Decode(&created)
+
+	query, _ := created.Configuration["query"].(map[string]interface{})
+	sql, _ := query["query"].(string)
+
+	// Merges are recorded, and drop their staging table.
+	if strings.HasPrefix(sql, "MERGE ") {
+		api.merges = append(api.merges, sql)
+
+		drop := fmt.Sprintf("DROP TABLE `%s.%s.", testProject, testDataset)
+		delete(api.tables, strings.TrimSuffix(sql[strings.Index(sql, drop)+len(drop):], "`"))
+
+		api.finishJob(&created, nil)
+		writeJSON(w, created)
+
+		return
+	}
+
+	prefix := fmt.Sprintf("TRUNCATE TABLE `%s.%s.", testProject, testDataset)
+	if !strings.HasPrefix(sql, prefix) {
+		writeAPIError(w, http.StatusBadRequest, "unsupported query: "+sql)
+
+		return
+	}
+
+	table := api.tables[strings.TrimSuffix(strings.TrimPrefix(sql, prefix), "`")]
+	if table == nil {
+		api.finishJob(&created, fmt.Errorf("Not found: Table"))
+	} else {
+		table.rows, table.streamed = nil, 0
+		api.finishJob(&created, nil)
+	}
+
+	writeJSON(w, created)
+}
+
+func (api *fakeAPI) insertAll(w http.ResponseWriter, req *http.Request, table *fakeTable) {
+	var body struct {
+		Rows []struct {
+			JSON map[string]interface{} `json:"json"`
+		} `json:"rows"`
+	}
+
+	_ = json.NewDecoder(req.Body).Decode(&body)
+
+	api.inserts = append(api.inserts, len(body.Rows))
+
+	var insertErrors []map[string]interface{}
+
+	for idx, row := range body.Rows {
+		if err := checkRow(table.fields, row.JSON); err != nil {
+			insertErrors = append(insertErrors, map[string]interface{}{
+				"index":  idx,
+				"errors": []errorProto{{Reason: "invalid", Message: err.Error()}},
+			})
+		}
+	}
+
+	// A request with an invalid row inserts none of its rows, and the valid rows are reported as stopped.
+	if len(insertErrors) > 0 {
+		for idx := range body.Rows {
+			if idx != insertErrors[0]["index"] {
+				insertErrors = append(insertErrors, map[string]interface{}{
+					"index":  idx,
+					"errors": []errorProto{{Reason: "stopped"}},
+				})
+			}
+		}
+
+		sort.Slice(insertErrors, func(i, j int) bool {
+			return insertErrors[i]["index"].(int) < insertErrors[j]["index"].(int)
+		})
+
+		writeJSON(w, map[string]interface{}{"insertErrors": insertErrors})
+
+		return
+	}
+
+	for _, row := range body.Rows {
+		table.rows = append(table.rows, row.JSON)
+		table.streamed++
+	}
+
+	writeJSON(w, map[string]interface{}{})
+}
+
+// encodeCell returns a value as a cell of a row of "tabledata.list".
+func encodeCell(fld *field, val interface{}) interface{} {
+	if val == nil {
+		return map[string]interface{}{"v": nil}
+	}
+
+	if fld.Mode == modeRepeated {
+		elem := *fld
+		elem.Mode = modeNullable
+
+		items, _ := val.([]interface{})
+		cells := make([]interface{}, len(items))
+
+		for idx, item := range items {
+			cells[idx] = encodeCell(&elem, item)
+		}
+
+		return map[string]interface{}{"v": cells}
+	}
+
+	switch fld.Type {
+	case typeRecord:
+		nested, _ := val.(map[string]interface{})
+
+		return map[string]interface{}{"v": encodeRow(fld.Fields, nested)}
+	case typeJSON:
+		data, _ := json.Marshal(val)
+
+		return map[string]interface{}{"v": string(data)}
+	default:
+		return map[string]interface{}{"v": fmt.Sprint(val)}
+	}
+}
+
+func encodeRow(fields []*field, row map[string]interface{}) map[string]interface{} {
+	cells := make([]interface{}, len(fields))
+	for idx, fld := range fields {
+		cells[idx] = encodeCell(fld, row[fld.Name])
+	}
+
+	return map[string]interface{}{"f": cells}
+}
+
+// newTestBigQuery will return a storage for a fake dataset.
+func newTestBigQuery(t *testing.T, api http.Handler, options string) *BigQuery {
+	t.Helper()
+
+	srv := httptest.NewServer(api)
+	t.Cleanup(srv.Close)
+
+	stg, err := parseConnectionString("bigquery://"+testProject+"/"+testDataset+"?endpoint="+srv.URL+"&"+options,
+		func(key string) string {
+			if key == "GOOGLE_OAUTH_ACCESS_TOKEN" {
+				return testToken
+			}
+
+			return ""
+		}, nil)
+	if err != nil {
+		t.Fatalf("failed to parse connection string: %v", err)
+	}
+
+	stg.client.pollInterval = time.Millisecond
+
+	return stg
+}
+
+// readRecords will read every record of a table.
+func readRecords(ctx context.Context, t *testing.T, stg *BigQuery, req *proto.ReadRecordsRequest,
+) []map[string]interface{} {
+	t.Helper()
+
+	var records []map[string]interface{}
+
+	if err := stg.Read(ctx, req, func(record map[string]interface{}) error {
+		records = append(records, record)
+
+		return nil
+	}); err != nil {
+		t.Fatalf("failed to read: %v", err)
+	}
+
+	return records
+}
+
+func TestBigQuery(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	api := newFakeAPI()
+	stg := newTestBigQuery(t, api, "")
+
+	data := map[string]interface{}{"test_string": "test", "id": "1"}
+
+	// The size of a table is the size of its rows, so load the same record in another dataset to measure it.
+	sized := newTestBigQuery(t, newFakeAPI(), "")
+
+	encoded, _ := json.Marshal(data)
+	if _, err := sized.Upsert(ctx, &proto.UpsertRequest{Table: "tests1", Data: encoded}); err != nil {
+		t.Fatalf("failed to upsert: %v", err)
+	}
+
+	tables, err := sized.ListTables(ctx)
+	if err != nil {
+		t.Fatalf("failed to list tables: %v", err)
+	}
+
+	proto.RunTest(ctx, t, stg, func(runner *proto.TestRunner) {
+		runner.AddCloseDBCases(proto.TestCase{
+			Name: "close bigquery",
+			OpenFn: func() proto.Storage {
+				return newTestBigQuery(t, api, "")
+			},
+		})
+
+		runner.AddStorageTypeCases(proto.TestCase{Name: "storage type", StorageType: proto.BigQueryType})
+		runner.AddIsNoSQLCases(proto.TestCase{Name: "isNoSQL bigquery", ExpectedIsNoSQL: true})
+		runner.AddListTablesCases(proto.TestCase{Name: "single", Table: "lttests1"})
+
+		runner.AddUpsertTxnCases(
+			proto.TestCase{
+				Name:               "commit",
+				Table:              "tests1",
+				ExpectedUpsertSize: tables.GetTableSet()["tests1"].GetSize(),
+				Data:               data,
+			},
+			proto.TestCase{
+				Name:               "rollback",
+				Table:              "tests1",
+				ExpectedUpsertSize: 0,
+				Rollback:           true,
+				Data:               data,
+			},
+			proto.TestCase{
+				Name:       "rollback on error",
+				Table:      "tests1",
+				ForceError: true,
+				Data:       data,
+			},
+		)
+
+		runner.AddTruncateTxnCases(proto.TestCase{Name: "bigquery", Table: "tests1", Data: data})
+
+		runner.AddPingCases(proto.TestCase{Name: "check bigquery connection"})
+	})
+}
+
+func TestParseConnectionString(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name         string
+		dns          string
+		env          map[string]string
+		endpoint     string
+		project      string
+		dataset      string
+		location     string
+		modes        map[string]string
+		dispositions map[string]string
+		authorized   bool
+		err          error
+	}{
+		{
+			name:         "defaults",
+			dns:          "bigquery://gidari-test/market",
+			env:          map[string]string{"GOOGLE_OAUTH_ACCESS_TOKEN": "token"},
+			endpoint:     defaultEndpoint,
+			project:      "gidari-test",
+			dataset:      "market",
+			modes:        map[string]string{"": modeLoad},
+			dispositions: map[string]string{"": writeAppend},
+			authorized:   true,
+		},
+		{
+			name: "options",
+			dns: "bigquery://gidari-test/market?location=EU&mode=stream&mode.snapshots=load" +
+				"&writeDisposition.snapshots=write_truncate&endpoint=http://localhost:9050",
+			endpoint:     "http://localhost:9050",
+			project:      "gidari-test",
+			dataset:      "market",
+			location:     "EU",
+			modes:        map[string]string{"": modeStream, "snapshots": modeLoad},
+			dispositions: map[string]string{"": writeAppend, "snapshots": writeTruncate},
+		},
+		{name: "unsupported scheme", dns: "bq://gidari-test/market", err: proto.ErrDNSNotSupported},
+		{name: "missing dataset", dns: "bigquery://gidari-test", err: proto.ErrDNSNotSupported},
+		{name: "invalid dataset", dns: "bigquery://gidari-test/a/b", err: proto.ErrDNSNotSupported},
+		{name: "missing credentials", dns: "bigquery://gidari-test/market", err: ErrMissingCredentials},
+		{
+			name: "missing credentials file",
+			dns:  "bigquery://gidari-test/market?credentials=/does/not/exist.json",
+			err:  ErrInvalidCredentials,
+		},
+		{
+			name: "invalid mode",
+			dns:  "bigquery://gidari-test/market?endpoint=http://localhost&mode=batch",
+			err:  ErrInvalidOptions,
+		},
+		{
+			name: "invalid disposition",
+			dns:  "bigquery://gidari-test/market?endpoint=http://localhost&writeDisposition=WRITE_EMPTY",
+			err:  ErrInvalidOptions,
+		},
+		{
+			name: "truncating streams",
+			dns:  "bigquery://gidari-test/market?endpoint=http://localhost&mode=stream&writeDisposition.t=WRITE_TRUNCATE",
+			err:  ErrInvalidOptions,
+		},
+		{
+			name: "invalid endpoint",
+			dns:  "bigquery://gidari-test/market?endpoint=localhost:9050",
+			err:  ErrInvalidOptions,
+		},
+	} {
+		tcase := tcase
+
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			stg, err := parseConnectionString(tcase.dns, func(key string) string { return tcase.env[key] }, nil)
+			if !errors.Is(err, tcase.err) {
+				t.Fatalf("expected error %v, got %v", tcase.err, err)
+			}
+
+			if tcase.err != nil {
+				return
+			}
+
+			for _, cmp := range []struct {
+				name      string
+				got, want interface{}
+			}{
+				{"endpoint", stg.client.endpoint.String(), tcase.endpoint},
+				{"project", stg.client.project, tcase.project},
+				{"dataset", stg.client.dataset, tcase.dataset},
+				{"location", stg.client.location, tcase.location},
+				{"modes", stg.modes, tcase.modes},
+				{"dispositions", stg.dispositions, tcase.dispositions},
+				{"authorized", stg.client.tokens != nil, tcase.authorized},
+			} {
+				if !reflect.DeepEqual(cmp.got, cmp.want) {
+					t.Fatalf("expected %s %v, got %v", cmp.name, cmp.want, cmp.got)
+				}
+			}
+		})
+	}
+}
+
+func TestSchemaEvolution(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	api := newFakeAPI()
+	stg := newTestBigQuery(t, api, "")
+
+	for _, data := range []string{
+		`[{"id":1,"price":1.5},{"id":2,"price":2}]`,
+		`{"id":3,"price":3,"venue":"a","trade":{"size":1}}`,
+		`{"id":4,"trade":{"side":"buy"},"tags":["x","y"]}`,
+	} {
+		if _, err := stg.Upsert(ctx, &proto.UpsertRequest{Table: "trades", Data: []byte(data)}); err != nil {
+			t.Fatalf("failed to upsert: %v", err)
+		}
+	}
+
+	want := []*field{
+		{Name: "id", Type: typeFloat, Mode: modeNullable},
+		{Name: "price", Type: typeFloat, Mode: modeNullable},
+		{Name: "trade", Type: typeRecord, Mode: modeNullable, Fields: []*field{
+			{Name: "size", Type: typeFloat, Mode: modeNullable},
+			{Name: "side", Type: typeString, Mode: modeNullable},
+		}},
+		{Name: "venue", Type: typeString, Mode: modeNullable},
+		{Name: "tags", Type: typeString, Mode: modeRepeated},
+	}
+
+	if got := api.tables["trades"].fields; !reflect.DeepEqual(got, want) {
+		data, _ := json.Marshal(got)
+		t.Fatalf("unexpected schema: %s", data)
+	}
+
+	if want := []string{"trades", "trades"}; !reflect.DeepEqual(api.patches, want) {
+		t.Fatalf("expected the schema to be patched twice, got %q", api.patches)
+	}
+
+	got := readRecords(ctx, t, stg, &proto.ReadRecordsRequest{Table: "trades", OrderBy: "id"})
+
+	wantRecords := []map[string]interface{}{
+		{"id": 1.0, "price": 1.5},
+		{"id": 2.0, "price": 2.0},
+		{"id": 3.0, "price": 3.0, "venue": "a", "trade": map[string]interface{}{"size": 1.0}},
+		{"id": 4.0, "trade": map[string]interface{}{"side": "buy"}, "tags": []interface{}{"x", "y"}},
+	}
+
+	if !reflect.DeepEqual(got, wantRecords) {
+		t.Fatalf("expected %v, got %v", wantRecords, got)
+	}
+}
+
+func TestWriteDisposition(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	api := newFakeAPI()
+	stg := newTestBigQuery(t, api, "writeDisposition.snapshots=WRITE_TRUNCATE")
+
+	for idx := 1; idx <= 2; idx++ {
+		for _, table := range []string{"snapshots", "trades"} {
+			if _, err := stg.Upsert(ctx, &proto.UpsertRequest{
+				Table: table,
+				Data:  []byte(fmt.Sprintf(`{"run":%d}`, idx)),
+			}); err != nil {
+				t.Fatalf("failed to upsert: %v", err)
+			}
+		}
+	}
+
+	// Only the first load of a run replaces the rows of the table.
+	if want := []string{writeTruncate, writeAppend, writeAppend, writeAppend}; !reflect.DeepEqual(api.loads, want) {
+		t.Fatalf("expected load jobs with dispositions %q, got %q", want, api.loads)
+	}
+
+	for table, want := range map[string]int{"snapshots": 2, "trades": 2} {
+		if got := len(readRecords(ctx, t, stg, &proto.ReadRecordsRequest{Table: table})); got != want {
+			t.Fatalf("expected %d rows in %s, got %d", want, table, got)
+		}
+	}
+
+	// Truncating a table keeps it, without rows.
+	if _, err := stg.Truncate(ctx, &proto.TruncateRequest{Tables: []string{"trades", "missing"}}); err != nil {
+		t.Fatalf("failed to truncate: %v", err)
+	}
+
+	tables, err := stg.ListTables(ctx)
+	if err != nil {
+		t.Fatalf("failed to list tables: %v", err)
+	}
+
+	if table, ok := tables.GetTableSet()["trades"]; !ok || table.GetSize() != 0 {
+		t.Fatalf("expected an empty trades table, got %v", table)
+	}
+}
+
+func TestWriteTruncateBatches(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	api := newFakeAPI()
+
+	// Each run opens the storage, and commits a transaction for every batch.
+	for run := 1; run <= 2; run++ {
+		stg := newTestBigQuery(t, api, "writeDisposition=WRITE_TRUNCATE")
+
+		for batch := 1; batch <= 3; batch++ {
+			txn, err := stg.StartTx(ctx)
+			if err != nil {
+				t.Fatalf("failed to start transaction: %v", err)
+			}
+
+			data := []byte(fmt.Sprintf(`{"run":%d,"batch":%d}`, run, batch))
+
+			txn.Send(func(ctx context.Context, stg proto.Storage) error {
+				_, err := stg.Upsert(ctx, &proto.UpsertRequest{Table: "snapshots", Data: data})
+
+				return err
+			})
+
+			if err := txn.Commit(); err != nil {
+				t.Fatalf("failed to commit: %v", err)
+			}
+		}
+
+		if got := len(readRecords(ctx, t, stg, &proto.ReadRecordsRequest{Table: "snapshots"})); got != 3 {
+			t.Fatalf("expected the rows of every batch of run %d, got %d", run, got)
+		}
+	}
+
+	want := []string{writeTruncate, writeAppend, writeAppend, writeTruncate, writeAppend, writeAppend}
+	if !reflect.DeepEqual(api.loads, want) {
+		t.Fatalf("expected load jobs with dispositions %q, got %q", want, api.loads)
+	}
+}
+
+func TestWriteModes(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	api := newFakeAPI()
+	stg := newTestBigQuery(t, api, "mode=stream")
+
+	path := func(table string) string {
+		return fmt.Sprintf("`%s.%s.%s`", testProject, testDataset, table)
+	}
+
+	for _, tcase := range []struct {
+		name string
+		mode string
+		keys []string
+		want string
+	}{
+		{
+			name: "upsert",
+			keys: []string{"trade-id"},
+			want: " AS source ON target.`trade_id` = source.`trade_id` WHEN MATCHED THEN UPDATE SET " +
+				"`price` = source.`price` WHEN NOT MATCHED THEN INSERT ROW; DROP TABLE ",
+		},
+		{
+			name: "append",
+			mode: proto.WriteModeAppend,
+			keys: []string{"trade-id"},
+			want: " AS source ON target.`trade_id` = source.`trade_id` WHEN NOT MATCHED THEN INSERT ROW; DROP TABLE ",
+		},
+	} {
+		if _, err := stg.Upsert(ctx, &proto.UpsertRequest{
+			Table:        "trades",
+			Data:         []byte(`{"trade-id":1,"price":1.5}`),
+			WriteMode:    tcase.mode,
+			ConflictKeys: tcase.keys,
+		}); err != nil {
+			t.Fatalf("%s: failed to upsert: %v", tcase.name, err)
+		}
+
+		sql := api.merges[len(api.merges)-1]
+		staging := strings.TrimSuffix(path("trades_gidari_staging_"), "`")
+		if !strings.HasPrefix(sql, "MERGE "+path("trades")+" AS target USING (SELECT * FROM "+staging) ||
+			!strings.Contains(sql, tcase.want) {
+			t.Fatalf("%s: expected a merge containing %q, got %q", tcase.name, tcase.want, sql)
+		}
+	}
+
+	// Staging tables are loaded with the schema of the table, and dropped after the merge.
+	if want := []string{writeTruncate, writeTruncate}; !reflect.DeepEqual(api.loads, want) || len(api.inserts) != 0 {
+		t.Fatalf("expected staging load jobs with dispositions %q, got %q and inserts %v", want, api.loads, api.inserts)
+	}
+
+	if len(api.tables) != 1 {
+		t.Fatalf("expected only the trades table, got %d tables", len(api.tables))
+	}
+
+	// Inserts, and writes without conflict keys, are not merged.
+	for _, mode := range []string{proto.WriteModeInsert, proto.WriteModeUpsert} {
+		req := &proto.UpsertRequest{Table: "trades", Data: []byte(`{"trade-id":2}`), WriteMode: mode}
+		if mode == proto.WriteModeInsert {
+			req.ConflictKeys = []string{"trade-id"}
+		}
+
+		if _, err := stg.Upsert(ctx, req); err != nil {
+			t.Fatalf("failed to upsert: %v", err)
+		}
+	}
+
+	if len(api.merges) != 2 || !reflect.DeepEqual(api.inserts, []int{1, 1}) {
+		t.Fatalf("expected two streaming inserts, got %d merges and inserts %v", len(api.merges), api.inserts)
+	}
+}
+
+func TestStream(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	api := newFakeAPI()
+	stg := newTestBigQuery(t, api, "mode=stream")
+
+	records := make([]string, 1200)
+	for idx := range records {
+		records[idx] = fmt.Sprintf(`{"n":%d}`, idx)
+	}
+
+	txn, err := stg.StartTx(ctx)
+	if err != nil {
+		t.Fatalf("failed to start transaction: %v", err)
+	}
+
+	txn.Send(func(ctx context.Context, stg proto.Storage) error {
+		_, err := stg.Upsert(ctx, &proto.UpsertRequest{
+			Table: "counts",
+			Data:  []byte("[" + strings.Join(records, ",") + "]"),
+		})
+
+		return err
+	})
+
+	if err := txn.Commit(); err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+
+	if want := []int{500, 500, 200}; !reflect.DeepEqual(api.inserts, want) {
+		t.Fatalf("expected streaming inserts of %v rows, got %v", want, api.inserts)
+	}
+
+	if len(api.loads) != 0 {
+		t.Fatalf("expected no load jobs, got %d", len(api.loads))
+	}
+
+	tables, err := stg.ListTables(ctx)
+	if err != nil {
+		t.Fatalf("failed to list tables: %v", err)
+	}
+
+	if size := tables.GetTableSet()["counts"].GetSize(); size != 120000 {
+		t.Fatalf("expected the size of the streaming buffer, got %d", size)
+	}
+
+	// A request with an invalid row inserts none of its rows.
+	_, err = stg.Upsert(ctx, &proto.UpsertRequest{Table: "counts", Data: []byte(`[{"n":1},{"fail":true}]`)})
+	if !errors.Is(err, ErrInsertFailed) || !strings.Contains(err.Error(), "2 of 2: row 1: invalid: invalid value") {
+		t.Fatalf("expected an insert failure, got %v", err)
+	}
+
+	if rows := len(api.tables["counts"].rows); rows != 1200 {
+		t.Fatalf("expected 1200 rows, got %d", rows)
+	}
+}
+
+func TestLoadJobFailure(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	api := newFakeAPI()
+	stg := newTestBigQuery(t, api, "")
+
+	_, err := stg.Upsert(ctx, &proto.UpsertRequest{Table: "trades", Data: []byte(`[{"id":1},{"fail":true}]`)})
+	if !errors.Is(err, ErrJobFailed) || !strings.Contains(err.Error(), "invalid: invalid value for field fail") {
+		t.Fatalf("expected the load job to fail, got %v", err)
+	}
+
+	if rows := len(api.tables["trades"].rows); rows != 0 {
+		t.Fatalf("expected a failed load job to load no rows, got %d", rows)
+	}
+}
+
+// limitedAPI is a fake API that rejects requests with a body larger than a maximum size, the way BigQuery rejects
+// streaming inserts with a 400 status and uploads with a 413 status.
+type limitedAPI struct {
+	*fakeAPI
+	max int
+}
+
+func (api limitedAPI) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	if len(body) > api.max {
+		if strings.HasPrefix(req.URL.Path, "/upload/") {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+		} else {
+			writeAPIError(w, http.StatusBadRequest, payloadTooLarge+": 10485760 bytes")
+		}
+
+		return
+	}
+
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	api.fakeAPI.ServeHTTP(w, req)
+}
+
+func TestBatchTooLarge(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	records := make([]string, 600)
+	for idx := range records {
+		records[idx] = fmt.Sprintf(`{"n":%d}`, idx)
+	}
+
+	// The second streaming insert holds a record that is larger than BigQuery accepts, so it is split in halves
+	// until that record alone is rejected.
+	records[550] = fmt.Sprintf(`{"n":550,"pad":%q}`, strings.Repeat("x", 20000))
+	data := []byte("[" + strings.Join(records, ",") + "]")
+
+	for _, tcase := range []struct {
+		name    string
+		options string
+		written int
+		inserts []int
+	}{
+		{name: "stream", options: "mode=stream", written: 550, inserts: []int{500, 50}},
+		{name: "load", written: 0},
+	} {
+		tcase := tcase
+
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			api := newFakeAPI()
+			stg := newTestBigQuery(t, limitedAPI{fakeAPI: api, max: 20000}, tcase.options)
+
+			_, err := stg.Upsert(ctx, &proto.UpsertRequest{Table: "counts", Data: data})
+
+			var tooLarge *proto.BatchTooLargeError
+			if !errors.As(err, &tooLarge) || !errors.Is(err, ErrRequestFailed) {
+				t.Fatalf("expected a batch too large error, got %v", err)
+			}
+
+			if tooLarge.Written != tcase.written {
+				t.Fatalf("expected %d rows to be written before the batch was rejected, got %d", tcase.written,
+					tooLarge.Written)
+			}
+
+			if !reflect.DeepEqual(api.inserts, tcase.inserts) {
+				t.Fatalf("expected streaming inserts of %v rows, got %v", tcase.inserts, api.inserts)
+			}
+		})
+	}
+}
+
+func TestPing(t *testing.T) {
+	t.Parallel()
+
+	stg := newTestBigQuery(t, newFakeAPI(), "")
+	stg.client.dataset = "missing"
+
+	if err := stg.Ping(); err == nil || !strings.Contains(err.Error(), `dataset "missing" of project`) {
+		t.Fatalf("expected a missing dataset, got %v", err)
+	}
+
+	unauthorized := newTestBigQuery(t, newFakeAPI(), "")
+	unauthorized.client.tokens = staticToken("wrong")
+
+	if err := unauthorized.Ping(); !errors.Is(err, ErrRequestFailed) {
+		t.Fatalf("expected %v, got %v", ErrRequestFailed, err)
+	}
+}
+
+func TestWorkspaceFiles(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	ws, err := workspace.New(t.TempDir(), workspace.RetainNever)
+	if err != nil {
+		t.Fatalf("failed to create workspace: %v", err)
+	}
+
+	defer ws.Close(false)
+
+	stg := newTestBigQuery(t, newFakeAPI(), "")
+	stg.ws = ws
+
+	txn, err := stg.StartTx(ctx)
+	if err != nil {
+		t.Fatalf("failed to start transaction: %v", err)
+	}
+
+	var files []os.DirEntry
+
+	txn.Send(func(ctx context.Context, stg proto.Storage) error {
+		_, err := stg.Upsert(ctx, &proto.UpsertRequest{Table: "trades", Data: []byte(`[{"id":1}]`)})
+
+		return err
+	})
+
+	txn.Send(func(context.Context, proto.Storage) error {
+		files, err = os.ReadDir(filepath.Join(ws.Dir(), tempDir))
+
+		return err
+	})
+
+	if err := txn.Commit(); err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+
+	if len(files) != 1 {
+		t.Fatalf("expected the transaction file to be in the workspace, got %d files", len(files))
+	}
+}
@@ -0,0 +1,555 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package bigquery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alpstable/gidari/tools"
+)
+
+var (
+	// ErrRequestFailed is returned when BigQuery responds to a request with an error.
+	ErrRequestFailed = fmt.Errorf("bigquery request failed")
+
+	// ErrJobFailed is returned when a load or query job completes with an error.
+	ErrJobFailed = fmt.Errorf("bigquery job failed")
+
+	// ErrInsertFailed is returned when some of the rows of a streaming insert could not be inserted.
+	ErrInsertFailed = fmt.Errorf("failed to insert rows")
+
+	// ErrUnexpectedRow is returned when a row that is read does not match the schema of its table.
+	ErrUnexpectedRow = fmt.Errorf("unexpected bigquery row")
+)
+
+var (
+	// errNotFound is returned by "do" when the resource of a request does not exist, and errAlreadyExists when the
+	// resource that a request creates already exists.
+	errNotFound      = fmt.Errorf("not found")
+	errAlreadyExists = fmt.Errorf("already exists")
+)
+
+// errRequestTooLarge is returned by "do" when BigQuery rejects the body of a request for its size, e.g. a streaming
+// insert larger than its 10MB limit.
+var errRequestTooLarge = fmt.Errorf("%w: request is too large", ErrRequestFailed)
+
+// payloadTooLarge is the message that BigQuery rejects requests larger than its limits with, with a 400 status.
+const payloadTooLarge = "Request payload size exceeds the limit"
+
+// defaultEndpoint is the endpoint of the REST API.
+const defaultEndpoint = "https://bigquery.googleapis.com"
+
+// multipartBoundary separates the configuration of a load job from its data in a multipart upload.
+const multipartBoundary = "gidari-bigquery-load"
+
+// tokenSource returns the access token that requests are authorized with.
+type tokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// client makes requests to the REST API of BigQuery for the tables of a dataset.
+type client struct {
+	http     *http.Client
+	endpoint *url.URL
+	project  string
+	dataset  string
+	location string
+
+	// tokens authorize requests, which are not authorized if it is nil, e.g. for an emulator.
+	tokens tokenSource
+
+	// pollInterval is how long to wait between checks of a job that has not completed.
+	pollInterval time.Duration
+	clock        tools.Clock
+}
+
+// apiError is the body of an error response.
+type apiError struct {
+	Error struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Status  string `json:"status"`
+	} `json:"error"`
+}
+
+// errorProto is the error of a job, or of a row of a streaming insert.
+type errorProto struct {
+	Reason   string `json:"reason"`
+	Location string `json:"location,omitempty"`
+	Message  string `json:"message"`
+}
+
+func (proto errorProto) String() string {
+	if proto.Location != "" {
+		return fmt.Sprintf("%s: %s: %s", proto.Reason, proto.Location, proto.Message)
+	}
+
+	return fmt.Sprintf("%s: %s", proto.Reason, proto.Message)
+}
+
+// url returns the URL of an unescaped path of the API, e.g. "/bigquery/v2/projects/p/jobs". Projects, datasets,
+// tables and jobs are validated, so they need no escaping.
+func (c *client) url(path string, query url.Values) string {
+	dst := *c.endpoint
+	dst.Path = strings.TrimSuffix(dst.Path, "/") + path
+	dst.RawQuery = query.Encode()
+
+	return dst.String()
+}
+
+// datasetPath returns the path of the dataset, to which the paths of its tables are relative.
+func (c *client) datasetPath() string {
+	return "/bigquery/v2/projects/" + c.project + "/datasets/" + c.dataset
+}
+
+func (c *client) tablePath(table string) string {
+	return c.datasetPath() + "/tables/" + table
+}
+
+func (c *client) jobsPath(upload bool) string {
+	path := "/bigquery/v2/projects/" + c.project + "/jobs"
+	if upload {
+		return "/upload" + path
+	}
+
+	return path
+}
+
+// do will make a request and return the response if it succeeded. The caller must close the body of the response.
+// Requests for a resource that does not exist return "errNotFound", and for one that already exists
+// "errAlreadyExists".
+func (c *client) do(ctx context.Context, method, path string, query url.Values, contentType string,
+	body io.Reader,
+) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.url(path, query), body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create request: %w", err)
+	}
+
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	if c.tokens != nil {
+		token, err := c.tokens.Token(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	rsp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrRequestFailed, err)
+	}
+
+	if rsp.StatusCode >= http.StatusOK && rsp.StatusCode < http.StatusMultipleChoices {
+		return rsp, nil
+	}
+
+	defer rsp.Body.Close()
+
+	switch rsp.StatusCode {
+	case http.StatusNotFound:
+		return nil, errNotFound
+	case http.StatusConflict:
+		return nil, errAlreadyExists
+	}
+
+	data, _ := io.ReadAll(io.LimitReader(rsp.Body, 64*1024))
+
+	reqErr := ErrRequestFailed
+
+	var apierr apiError
+	if err := json.Unmarshal(data, &apierr); err != nil || apierr.Error.Message == "" {
+		if rsp.StatusCode == http.StatusRequestEntityTooLarge {
+			reqErr = errRequestTooLarge
+		}
+
+		return nil, fmt.Errorf("%w: %s %s: %s", reqErr, method, path, rsp.Status)
+	}
+
+	if rsp.StatusCode == http.StatusRequestEntityTooLarge || strings.Contains(apierr.Error.Message, payloadTooLarge) {
+		reqErr = errRequestTooLarge
+	}
+
+	return nil, fmt.Errorf("%w: %s %s: %s: %s", reqErr, method, path, rsp.Status, apierr.Error.Message)
+}
+
+// call will make a request with a JSON body, if it is not nil, and decode the JSON body of the response into
+// "dst", if it is not nil.
+func (c *client) call(ctx context.Context, method, path string, query url.Values, body, dst interface{}) error {
+	var (
+		reader      io.Reader
+		contentType string
+	)
+
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("unable to encode request: %w", err)
+		}
+
+		reader = bytes.NewReader(data)
+		contentType = "application/json"
+	}
+
+	rsp, err := c.do(ctx, method, path, query, contentType, reader)
+	if err != nil {
+		return err
+	}
+
+	defer rsp.Body.Close()
+
+	if dst == nil {
+		_, err = io.Copy(io.Discard, rsp.Body)
+	} else {
+		err = json.NewDecoder(rsp.Body).Decode(dst)
+	}
+
+	if err != nil {
+		return fmt.Errorf("%w: unable to decode response: %v", ErrRequestFailed, err)
+	}
+
+	return nil
+}
+
+type tableReference struct {
+	ProjectID string `json:"projectId"`
+	DatasetID string `json:"datasetId"`
+	TableID   string `json:"tableId"`
+}
+
+// tableInfo is the resource of a table, where the counts are strings since they are 64-bit integers.
+type tableInfo struct {
+	TableReference tableReference `json:"tableReference"`
+	Schema         *schema        `json:"schema,omitempty"`
+	NumBytes       string         `json:"numBytes,omitempty"`
+	NumRows        string         `json:"numRows,omitempty"`
+
+	// StreamingBuffer is the estimate of the rows that have been streamed into the table, but not yet been
+	// written to its storage.
+	StreamingBuffer *struct {
+		EstimatedBytes string `json:"estimatedBytes"`
+	} `json:"streamingBuffer,omitempty"`
+}
+
+func (c *client) reference(table string) tableReference {
+	return tableReference{ProjectID: c.project, DatasetID: c.dataset, TableID: table}
+}
+
+// getTable returns the resource of a table, and "errNotFound" if the table does not exist.
+func (c *client) getTable(ctx context.Context, table string) (*tableInfo, error) {
+	var info tableInfo
+	if err := c.call(ctx, http.MethodGet, c.tablePath(table), nil, nil, &info); err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+// createTable will create a table with a schema, and do nothing if the table already exists.
+func (c *client) createTable(ctx context.Context, table string, fields []*field) error {
+	info := tableInfo{TableReference: c.reference(table), Schema: &schema{Fields: fields}}
+
+	err := c.call(ctx, http.MethodPost, c.datasetPath()+"/tables", nil, info, nil)
+	if errors.Is(err, errAlreadyExists) {
+		return nil
+	}
+
+	return err
+}
+
+// patchSchema will replace the schema of a table, which may only add fields to it.
+func (c *client) patchSchema(ctx context.Context, table string, fields []*field) error {
+	return c.call(ctx, http.MethodPatch, c.tablePath(table), nil, map[string]interface{}{
+		"schema": schema{Fields: fields},
+	}, nil)
+}
+
+// listTables returns the tables of the dataset.
+func (c *client) listTables(ctx context.Context) ([]string, error) {
+	var (
+		tables    []string
+		pageToken string
+	)
+
+	for {
+		query := url.Values{"maxResults": {"1000"}}
+		if pageToken != "" {
+			query.Set("pageToken", pageToken)
+		}
+
+		var page struct {
+			Tables []struct {
+				TableReference tableReference `json:"tableReference"`
+			} `json:"tables"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+
+		if err := c.call(ctx, http.MethodGet, c.datasetPath()+"/tables", query, nil, &page); err != nil {
+			return nil, err
+		}
+
+		for _, table := range page.Tables {
+			tables = append(tables, table.TableReference.TableID)
+		}
+
+		if pageToken = page.NextPageToken; pageToken == "" {
+			return tables, nil
+		}
+	}
+}
+
+type jobReference struct {
+	ProjectID string `json:"projectId"`
+	JobID     string `json:"jobId"`
+	Location  string `json:"location,omitempty"`
+}
+
+type jobStatus struct {
+	State       string       `json:"state"`
+	ErrorResult *errorProto  `json:"errorResult,omitempty"`
+	Errors      []errorProto `json:"errors,omitempty"`
+}
+
+// job is the resource of a load or query job.
+type job struct {
+	JobReference  jobReference           `json:"jobReference"`
+	Configuration map[string]interface{} `json:"configuration"`
+	Status        *jobStatus             `json:"status,omitempty"`
+}
+
+// loadConfig is the configuration of a load job of newline-delimited JSON.
+type loadConfig struct {
+	DestinationTable  tableReference `json:"destinationTable"`
+	SourceFormat      string         `json:"sourceFormat"`
+	Schema            *schema        `json:"schema,omitempty"`
+	WriteDisposition  string         `json:"writeDisposition"`
+	CreateDisposition string         `json:"createDisposition"`
+}
+
+// load will run a load job of the newline-delimited JSON rows of "data" into a table, and wait for it to complete.
+// The job ID is chosen by the caller so that the job is not run twice if the upload is retried.
+func (c *client) load(ctx context.Context, jobID, table string, fields []*field, disposition string,
+	data io.Reader,
+) error {
+	var loadSchema *schema
+	if len(fields) > 0 {
+		loadSchema = &schema{Fields: fields}
+	}
+
+	metadata, err := json.Marshal(job{
+		JobReference: jobReference{ProjectID: c.project, JobID: jobID, Location: c.location},
+		Configuration: map[string]interface{}{"load": loadConfig{
+			DestinationTable:  c.reference(table),
+			SourceFormat:      "NEWLINE_DELIMITED_JSON",
+			Schema:            loadSchema,
+			WriteDisposition:  disposition,
+			CreateDisposition: "CREATE_IF_NEEDED",
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("unable to encode load job: %w", err)
+	}
+
+	// The data is streamed from the reader, so that large transactions are not held in memory.
+	body := io.MultiReader(
+		strings.NewReader("--"+multipartBoundary+"\r\nContent-Type: application/json; charset=UTF-8\r\n\r\n"),
+		bytes.NewReader(metadata),
+		strings.NewReader("\r\n--"+multipartBoundary+"\r\nContent-Type: application/octet-stream\r\n\r\n"),
+		data,
+		strings.NewReader("\r\n--"+multipartBoundary+"--\r\n"),
+	)
+
+	rsp, err := c.do(ctx, http.MethodPost, c.jobsPath(true), url.Values{"uploadType": {"multipart"}},
+		"multipart/related; boundary="+multipartBoundary, body)
+	if err != nil {
+		return err
+	}
+
+	defer rsp.Body.Close()
+
+	var created job
+	if err := json.NewDecoder(rsp.Body).Decode(&created); err != nil {
+		return fmt.Errorf("%w: unable to decode response: %v", ErrRequestFailed, err)
+	}
+
+	return c.wait(ctx, &created)
+}
+
+// query will run a query job, e.g. "TRUNCATE TABLE", and wait for it to complete.
+func (c *client) query(ctx context.Context, jobID, sql string) error {
+	var created job
+
+	if err := c.call(ctx, http.MethodPost, c.jobsPath(false), nil, job{
+		JobReference: jobReference{ProjectID: c.project, JobID: jobID, Location: c.location},
+		Configuration: map[string]interface{}{"query": map[string]interface{}{
+			"query":        sql,
+			"useLegacySql": false,
+		}},
+	}, &created); err != nil {
+		return err
+	}
+
+	return c.wait(ctx, &created)
+}
+
+// wait will poll a job until it is done, returning "ErrJobFailed" with the errors of the job if it failed.
+func (c *client) wait(ctx context.Context, current *job) error {
+	for current.Status == nil || current.Status.State != "DONE" {
+		if err := tools.Sleep(ctx, c.clock, c.pollInterval); err != nil {
+			return fmt.Errorf("unable to wait for job %q: %w", current.JobReference.JobID, err)
+		}
+
+		query := url.Values{}
+		if current.JobReference.Location != "" {
+			query.Set("location", current.JobReference.Location)
+		}
+
+		var next job
+		if err := c.call(ctx, http.MethodGet, c.jobsPath(false)+"/"+current.JobReference.JobID,
+			query, nil, &next); err != nil {
+			return err
+		}
+
+		current = &next
+	}
+
+	if current.Status.ErrorResult == nil {
+		return nil
+	}
+
+	msgs := make([]string, 0, len(current.Status.Errors)+1)
+	msgs = append(msgs, current.Status.ErrorResult.String())
+
+	for _, jobErr := range current.Status.Errors {
+		if jobErr != *current.Status.ErrorResult {
+			msgs = append(msgs, jobErr.String())
+		}
+	}
+
+	return fmt.Errorf("%w: %s: %s", ErrJobFailed, current.JobReference.JobID, strings.Join(msgs, "; "))
+}
+
+// insertRow is a row of a streaming insert.
+type insertRow struct {
+	JSON json.RawMessage `json:"json"`
+}
+
+// insertAll will stream rows into a table, returning the number of rows that were inserted.
+func (c *client) insertAll(ctx context.Context, table string, rows []json.RawMessage) (int, error) {
+	body := struct {
+		Rows []insertRow `json:"rows"`
+	}{Rows: make([]insertRow, len(rows))}
+
+	for idx, row := range rows {
+		body.Rows[idx] = insertRow{JSON: row}
+	}
+
+	var rsp struct {
+		InsertErrors []struct {
+			Index  int          `json:"index"`
+			Errors []errorProto `json:"errors"`
+		} `json:"insertErrors"`
+	}
+
+	if err := c.call(ctx, http.MethodPost, c.tablePath(table)+"/insertAll", nil, body, &rsp); err != nil {
+		return 0, err
+	}
+
+	if len(rsp.InsertErrors) == 0 {
+		return len(rows), nil
+	}
+
+	// Rows of a request either all fail or are all inserted, but only the invalid rows have a reason other than
+	// "stopped".
+	first := rsp.InsertErrors[0]
+	for _, insertErr := range rsp.InsertErrors {
+		if len(insertErr.Errors) > 0 && insertErr.Errors[0].Reason != "stopped" {
+			first = insertErr
+			break
+		}
+	}
+
+	desc := "unknown error"
+	if len(first.Errors) > 0 {
+		desc = first.Errors[0].String()
+	}
+
+	return 0, fmt.Errorf("%w: %d of %d: row %d: %s", ErrInsertFailed, len(rsp.InsertErrors), len(rows), first.Index,
+		desc)
+}
+
+// listRows will call "fn" with every row of a table, decoded with the schema of the table.
+func (c *client) listRows(ctx context.Context, table string, pageSize int, fn func(map[string]interface{}) error,
+) error {
+	info, err := c.getTable(ctx, table)
+	if err != nil {
+		return err
+	}
+
+	var fields []*field
+	if info.Schema != nil {
+		fields = info.Schema.Fields
+	}
+
+	pageToken := ""
+
+	for {
+		query := url.Values{"maxResults": {strconv.Itoa(pageSize)}}
+		if pageToken != "" {
+			query.Set("pageToken", pageToken)
+		}
+
+		var page struct {
+			Rows      []map[string]interface{} `json:"rows"`
+			PageToken string                   `json:"pageToken"`
+		}
+
+		if err := c.call(ctx, http.MethodGet, c.tablePath(table)+"/data", query, nil, &page); err != nil {
+			return err
+		}
+
+		for _, row := range page.Rows {
+			record, err := decodeRow(fields, row)
+			if err != nil {
+				return err
+			}
+
+			if err := fn(record); err != nil {
+				return err
+			}
+		}
+
+		if pageToken = page.PageToken; pageToken == "" || len(page.Rows) == 0 {
+			return nil
+		}
+	}
+}
+
+// ping will get the dataset, to check that it can be reached.
+func (c *client) ping(ctx context.Context) error {
+	err := c.call(ctx, http.MethodGet, c.datasetPath(), nil, nil, nil)
+	if errors.Is(err, errNotFound) {
+		return fmt.Errorf("dataset %q of project %q not found", c.dataset, c.project)
+	}
+
+	return err
+}
@@ -0,0 +1,217 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package bigquery
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alpstable/gidari/tools"
+)
+
+// ErrInvalidCredentials is returned when the credentials file cannot be used to authenticate requests.
+var ErrInvalidCredentials = fmt.Errorf("invalid bigquery credentials")
+
+const (
+	// bigQueryScope is the OAuth2 scope of the access tokens of a service account.
+	bigQueryScope = "https://www.googleapis.com/auth/bigquery"
+
+	// defaultTokenURI is where the access tokens of a service account are requested when its key has no
+	// "token_uri".
+	defaultTokenURI = "https://oauth2.googleapis.com/token"
+
+	// tokenLifetime is how long the access tokens of a service account are requested for, and tokenMargin how long
+	// before they expire that they are replaced.
+	tokenLifetime = time.Hour
+	tokenMargin   = 5 * time.Minute
+)
+
+// serviceAccountKey is the JSON key of a service account, from the "GOOGLE_APPLICATION_CREDENTIALS" file.
+type serviceAccountKey struct {
+	Type         string `json:"type"`
+	ClientEmail  string `json:"client_email"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	TokenURI     string `json:"token_uri"`
+}
+
+// serviceAccount requests access tokens for a service account with a signed JWT, and reuses each token until it is
+// about to expire.
+type serviceAccount struct {
+	email    string
+	keyID    string
+	key      *rsa.PrivateKey
+	tokenURI string
+	http     *http.Client
+	clock    tools.Clock
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+// readServiceAccount will read the JSON key of a service account.
+func readServiceAccount(path string, client *http.Client, clock tools.Clock) (*serviceAccount, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+
+	var key serviceAccountKey
+	if err := json.Unmarshal(data, &key); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidCredentials, path, err)
+	}
+
+	if key.Type != "service_account" || key.ClientEmail == "" {
+		return nil, fmt.Errorf("%w: %s is not the key of a service account", ErrInvalidCredentials, path)
+	}
+
+	block, _ := pem.Decode([]byte(key.PrivateKey))
+	if block == nil {
+		return nil, fmt.Errorf("%w: %s has no private key", ErrInvalidCredentials, path)
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		if parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidCredentials, path, err)
+		}
+	}
+
+	rsaKey, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s does not have an RSA private key", ErrInvalidCredentials, path)
+	}
+
+	if key.TokenURI == "" {
+		key.TokenURI = defaultTokenURI
+	}
+
+	return &serviceAccount{
+		email:    key.ClientEmail,
+		keyID:    key.PrivateKeyID,
+		key:      rsaKey,
+		tokenURI: key.TokenURI,
+		http:     client,
+		clock:    tools.ClockOrReal(clock),
+	}, nil
+}
+
+// assertion returns the signed JWT that an access token is requested with.
+func (account *serviceAccount) assertion(now time.Time) (string, error) {
+	encode := func(val interface{}) (string, error) {
+		data, err := json.Marshal(val)
+		if err != nil {
+			return "", fmt.Errorf("unable to encode assertion: %w", err)
+		}
+
+		return base64.RawURLEncoding.EncodeToString(data), nil
+	}
+
+	header, err := encode(map[string]string{"alg": "RS256", "typ": "JWT", "kid": account.keyID})
+	if err != nil {
+		return "", err
+	}
+
+	claims, err := encode(map[string]interface{}{
+		"iss":   account.email,
+		"scope": bigQueryScope,
+		"aud":   account.tokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(tokenLifetime).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	digest := sha256.Sum256([]byte(header + "." + claims))
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, account.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("unable to sign assertion: %w", err)
+	}
+
+	return header + "." + claims + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// Token returns an access token for the service account, requesting a new one if there is none or it is about to
+// expire.
+func (account *serviceAccount) Token(ctx context.Context) (string, error) {
+	account.mu.Lock()
+	defer account.mu.Unlock()
+
+	now := account.clock.Now()
+	if account.token != "" && now.Add(tokenMargin).Before(account.expiry) {
+		return account.token, nil
+	}
+
+	assertion, err := account.assertion(now)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, account.tokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("unable to create token request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rsp, err := account.http.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("%w: unable to request token: %v", ErrInvalidCredentials, err)
+	}
+
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(io.LimitReader(rsp.Body, 4096))
+
+		return "", fmt.Errorf("%w: token request failed: %s: %s", ErrInvalidCredentials, rsp.Status,
+			strings.TrimSpace(string(data)))
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+
+	if err := json.NewDecoder(rsp.Body).Decode(&token); err != nil || token.AccessToken == "" {
+		return "", fmt.Errorf("%w: token response has no access token", ErrInvalidCredentials)
+	}
+
+	account.token = token.AccessToken
+	account.expiry = now.Add(time.Duration(token.ExpiresIn) * time.Second)
+
+	return account.token, nil
+}
+
+// staticToken is an access token that is used as it is, e.g. from "gcloud auth print-access-token".
+type staticToken string
+
+func (token staticToken) Token(context.Context) (string, error) { return string(token), nil }
@@ -0,0 +1,161 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package bigquery
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alpstable/gidari/tools"
+)
+
+// writeServiceAccount will write the JSON key of a service account whose tokens are requested from "tokenURI".
+func writeServiceAccount(t *testing.T, key *rsa.PrivateKey, tokenURI string) string {
+	t.Helper()
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+
+	data, err := json.Marshal(serviceAccountKey{
+		Type:         "service_account",
+		ClientEmail:  "gidari@test.iam.gserviceaccount.com",
+		PrivateKeyID: "key-1",
+		PrivateKey:   string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		TokenURI:     tokenURI,
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal service account: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("failed to write service account: %v", err)
+	}
+
+	return path
+}
+
+func TestServiceAccount(t *testing.T) {
+	t.Parallel()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	var grants int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(rsp http.ResponseWriter, req *http.Request) {
+		if err := req.ParseForm(); err != nil || req.Form.Get("grant_type") !=
+			"urn:ietf:params:oauth:grant-type:jwt-bearer" {
+			http.Error(rsp, "unsupported grant", http.StatusBadRequest)
+
+			return
+		}
+
+		parts := strings.Split(req.Form.Get("assertion"), ".")
+		if len(parts) != 3 {
+			http.Error(rsp, "malformed assertion", http.StatusBadRequest)
+
+			return
+		}
+
+		signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+		digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+
+		if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+			http.Error(rsp, "invalid signature", http.StatusUnauthorized)
+
+			return
+		}
+
+		grant := atomic.AddInt32(&grants, 1)
+		fmt.Fprintf(rsp, `{"access_token":"token-%d","expires_in":3600}`, grant)
+	}))
+
+	t.Cleanup(srv.Close)
+
+	clock := tools.NewFakeClock(time.Date(2022, 5, 10, 0, 0, 0, 0, time.UTC))
+
+	account, err := readServiceAccount(writeServiceAccount(t, key, srv.URL), srv.Client(), clock)
+	if err != nil {
+		t.Fatalf("failed to read service account: %v", err)
+	}
+
+	for _, tcase := range []struct {
+		name    string
+		advance time.Duration
+		want    string
+	}{
+		{name: "first token", want: "token-1"},
+		{name: "reused before expiry", advance: 50 * time.Minute, want: "token-1"},
+		{name: "replaced within margin", advance: 6 * time.Minute, want: "token-2"},
+	} {
+		clock.Advance(tcase.advance)
+
+		got, err := account.Token(context.Background())
+		if err != nil {
+			t.Fatalf("%s: failed to get token: %v", tcase.name, err)
+		}
+
+		if got != tcase.want {
+			t.Fatalf("%s: expected %q, got %q", tcase.name, tcase.want, got)
+		}
+	}
+}
+
+func TestReadServiceAccount(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	for _, tcase := range []struct {
+		name string
+		data string
+	}{
+		{name: "not json", data: "{"},
+		{name: "not a service account", data: `{"type":"authorized_user","client_email":"a@b"}`},
+		{name: "no private key", data: `{"type":"service_account","client_email":"a@b","private_key":"x"}`},
+	} {
+		tcase := tcase
+
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(dir, strings.ReplaceAll(tcase.name, " ", "_")+".json")
+			if err := os.WriteFile(path, []byte(tcase.data), 0o600); err != nil {
+				t.Fatalf("failed to write credentials: %v", err)
+			}
+
+			if _, err := readServiceAccount(path, http.DefaultClient, nil); !errors.Is(err, ErrInvalidCredentials) {
+				t.Fatalf("expected %v, got %v", ErrInvalidCredentials, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,301 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package bigquery
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The types of the fields of a schema.
+const (
+	typeString  = "STRING"
+	typeFloat   = "FLOAT"
+	typeInteger = "INTEGER"
+	typeNumeric = "NUMERIC"
+	typeBoolean = "BOOLEAN"
+	typeRecord  = "RECORD"
+	typeJSON    = "JSON"
+)
+
+// The modes of the fields of a schema.
+const (
+	modeNullable = "NULLABLE"
+	modeRepeated = "REPEATED"
+)
+
+// field is a field of the schema of a table.
+type field struct {
+	Name   string   `json:"name"`
+	Type   string   `json:"type"`
+	Mode   string   `json:"mode,omitempty"`
+	Fields []*field `json:"fields,omitempty"`
+}
+
+type schema struct {
+	Fields []*field `json:"fields"`
+}
+
+// sanitizeName returns the name of the column of a key of a record. Column names may only contain letters, numbers
+// and underscores, and may not start with a number.
+func sanitizeName(key string) string {
+	name := []rune(key)
+
+	for idx, char := range name {
+		if !(char == '_' || (char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z') ||
+			(char >= '0' && char <= '9')) {
+			name[idx] = '_'
+		}
+	}
+
+	if len(name) == 0 || (name[0] >= '0' && name[0] <= '9') {
+		return "_" + string(name)
+	}
+
+	return string(name)
+}
+
+// sanitize returns a copy of a value with the keys of its objects renamed to their columns, and without the null
+// elements of its arrays, which repeated fields cannot hold.
+func sanitize(val interface{}) interface{} {
+	switch val := val.(type) {
+	case map[string]interface{}:
+		record := make(map[string]interface{}, len(val))
+		for key, item := range val {
+			record[sanitizeName(key)] = sanitize(item)
+		}
+
+		return record
+	case []interface{}:
+		list := make([]interface{}, 0, len(val))
+
+		for _, item := range val {
+			if item != nil {
+				list = append(list, sanitize(item))
+			}
+		}
+
+		return list
+	default:
+		return val
+	}
+}
+
+// inferField returns the field of a value, or nil if the type of the value cannot be told, which is the case for
+// nulls and empty arrays.
+func inferField(name string, val interface{}) *field {
+	switch val := val.(type) {
+	case string:
+		return &field{Name: name, Type: typeString, Mode: modeNullable}
+	case float64:
+		return &field{Name: name, Type: typeFloat, Mode: modeNullable}
+	case bool:
+		return &field{Name: name, Type: typeBoolean, Mode: modeNullable}
+	case map[string]interface{}:
+		return &field{Name: name, Type: typeRecord, Mode: modeNullable, Fields: inferFields(nil, val)}
+	case []interface{}:
+		var elem *field
+
+		for _, item := range val {
+			itemField := inferField(name, item)
+			if itemField == nil {
+				continue
+			}
+
+			// Repeated fields cannot be nested, so arrays of arrays are stored as JSON.
+			if itemField.Mode == modeRepeated {
+				return &field{Name: name, Type: typeJSON, Mode: modeNullable}
+			}
+
+			if elem == nil {
+				elem = itemField
+			} else {
+				elem = mergeField(elem, itemField)
+			}
+		}
+
+		if elem == nil {
+			return nil
+		}
+
+		elem.Mode = modeRepeated
+
+		return elem
+	default:
+		return nil
+	}
+}
+
+// inferFields will merge the fields of a record into a list of fields. The new fields of a record are added in the
+// order of their names.
+func inferFields(fields []*field, record map[string]interface{}) []*field {
+	inferred := make([]*field, 0, len(record))
+
+	for key, val := range record {
+		if fld := inferField(key, val); fld != nil {
+			inferred = append(inferred, fld)
+		}
+	}
+
+	fields, _ = mergeFields(fields, inferred)
+
+	return fields
+}
+
+// mergeField returns a field that can hold the values of both fields. A field keeps its type when another field
+// has a different type, except that fields of records and of other types are stored as JSON.
+func mergeField(existing, other *field) *field {
+	merged := *existing
+
+	switch {
+	case existing.Type == typeRecord && other.Type == typeRecord:
+		merged.Fields, _ = mergeFields(existing.Fields, other.Fields)
+	case (existing.Type == typeRecord) != (other.Type == typeRecord) && existing.Type != typeJSON:
+		merged.Type, merged.Fields = typeJSON, nil
+	}
+
+	return &merged
+}
+
+// mergeFields will add the fields of "other" to "existing", returning the merged fields and whether any field was
+// added. New fields are added after the existing ones, since the columns of a table cannot be reordered.
+func mergeFields(existing, other []*field) ([]*field, bool) {
+	merged := make([]*field, len(existing))
+	copy(merged, existing)
+
+	indices := make(map[string]int, len(existing))
+	for idx, fld := range existing {
+		indices[strings.ToLower(fld.Name)] = idx
+	}
+
+	sorted := make([]*field, len(other))
+	copy(sorted, other)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	changed := false
+
+	for _, fld := range sorted {
+		// Column names are case-insensitive.
+		idx, ok := indices[strings.ToLower(fld.Name)]
+		if !ok {
+			indices[strings.ToLower(fld.Name)] = len(merged)
+			merged = append(merged, fld)
+			changed = true
+
+			continue
+		}
+
+		// The type of an existing column cannot be changed, but records can have fields added.
+		if merged[idx].Type == typeRecord && fld.Type == typeRecord {
+			fields, added := mergeFields(merged[idx].Fields, fld.Fields)
+			if added {
+				copied := *merged[idx]
+				copied.Fields = fields
+				merged[idx] = &copied
+				changed = true
+			}
+		}
+	}
+
+	return merged, changed
+}
+
+// decodeValue returns the value of a field from a row of "tabledata.list", where every scalar is a string.
+func decodeValue(fld *field, val interface{}) (interface{}, error) {
+	if val == nil {
+		return nil, nil
+	}
+
+	if fld.Mode == modeRepeated {
+		cells, ok := val.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%w: %s is not repeated", ErrUnexpectedRow, fld.Name)
+		}
+
+		elem := *fld
+		elem.Mode = modeNullable
+
+		list := make([]interface{}, 0, len(cells))
+
+		for _, cell := range cells {
+			cellMap, _ := cell.(map[string]interface{})
+
+			item, err := decodeValue(&elem, cellMap["v"])
+			if err != nil {
+				return nil, err
+			}
+
+			list = append(list, item)
+		}
+
+		return list, nil
+	}
+
+	if fld.Type == typeRecord {
+		row, ok := val.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%w: %s is not a record", ErrUnexpectedRow, fld.Name)
+		}
+
+		return decodeRow(fld.Fields, row)
+	}
+
+	str, ok := val.(string)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s is not a string", ErrUnexpectedRow, fld.Name)
+	}
+
+	switch fld.Type {
+	case typeFloat, typeInteger, typeNumeric, "FLOAT64", "INT64", "BIGNUMERIC":
+		num, err := strconv.ParseFloat(str, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrUnexpectedRow, fld.Name, err)
+		}
+
+		return num, nil
+	case typeBoolean, "BOOL":
+		return str == "true", nil
+	case typeJSON:
+		var decoded interface{}
+		if err := json.Unmarshal([]byte(str), &decoded); err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrUnexpectedRow, fld.Name, err)
+		}
+
+		return decoded, nil
+	default:
+		return str, nil
+	}
+}
+
+// decodeRow returns the record of a row of "tabledata.list", e.g. {"f": [{"v": "1"}]}, leaving out null fields.
+func decodeRow(fields []*field, row map[string]interface{}) (map[string]interface{}, error) {
+	cells, _ := row["f"].([]interface{})
+	if len(cells) != len(fields) {
+		return nil, fmt.Errorf("%w: expected %d fields, got %d", ErrUnexpectedRow, len(fields), len(cells))
+	}
+
+	record := make(map[string]interface{}, len(fields))
+
+	for idx, fld := range fields {
+		cell, _ := cells[idx].(map[string]interface{})
+
+		val, err := decodeValue(fld, cell["v"])
+		if err != nil {
+			return nil, err
+		}
+
+		if val != nil {
+			record[fld.Name] = val
+		}
+	}
+
+	return record, nil
+}
@@ -0,0 +1,199 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package bigquery
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSanitize(t *testing.T) {
+	t.Parallel()
+
+	got := sanitize(map[string]interface{}{
+		"trade-id": 1.0,
+		"2nd":      "b",
+		"":         true,
+		"nested":   map[string]interface{}{"a.b": []interface{}{"x", nil, "y"}},
+	})
+
+	want := map[string]interface{}{
+		"trade_id": 1.0,
+		"_2nd":     "b",
+		"_":        true,
+		"nested":   map[string]interface{}{"a_b": []interface{}{"x", "y"}},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestInferFields(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name    string
+		records []string
+		want    []*field
+	}{
+		{
+			name:    "scalars",
+			records: []string{`{"s":"a","f":1.5,"b":true,"n":null,"e":[]}`},
+			want: []*field{
+				{Name: "b", Type: typeBoolean, Mode: modeNullable},
+				{Name: "f", Type: typeFloat, Mode: modeNullable},
+				{Name: "s", Type: typeString, Mode: modeNullable},
+			},
+		},
+		{
+			name:    "late fields",
+			records: []string{`{"a":1,"n":null}`, `{"a":2,"n":"x"}`},
+			want: []*field{
+				{Name: "a", Type: typeFloat, Mode: modeNullable},
+				{Name: "n", Type: typeString, Mode: modeNullable},
+			},
+		},
+		{
+			name:    "first type wins",
+			records: []string{`{"a":1}`, `{"a":"1"}`},
+			want:    []*field{{Name: "a", Type: typeFloat, Mode: modeNullable}},
+		},
+		{
+			name:    "records",
+			records: []string{`{"r":{"a":1}}`, `{"r":{"b":"x"}}`},
+			want: []*field{{Name: "r", Type: typeRecord, Mode: modeNullable, Fields: []*field{
+				{Name: "a", Type: typeFloat, Mode: modeNullable},
+				{Name: "b", Type: typeString, Mode: modeNullable},
+			}}},
+		},
+		{
+			name:    "repeated",
+			records: []string{`{"tags":["a"],"items":[{"id":1},{"name":"x"}]}`},
+			want: []*field{
+				{Name: "items", Type: typeRecord, Mode: modeRepeated, Fields: []*field{
+					{Name: "id", Type: typeFloat, Mode: modeNullable},
+					{Name: "name", Type: typeString, Mode: modeNullable},
+				}},
+				{Name: "tags", Type: typeString, Mode: modeRepeated},
+			},
+		},
+		{
+			name:    "json",
+			records: []string{`{"matrix":[[1,2],[3]],"mixed":[{"a":1},2]}`},
+			want: []*field{
+				{Name: "matrix", Type: typeJSON, Mode: modeNullable},
+				{Name: "mixed", Type: typeJSON, Mode: modeRepeated},
+			},
+		},
+	} {
+		tcase := tcase
+
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			var fields []*field
+
+			for _, raw := range tcase.records {
+				var record map[string]interface{}
+				if err := json.Unmarshal([]byte(raw), &record); err != nil {
+					t.Fatalf("failed to decode record: %v", err)
+				}
+
+				fields = inferFields(fields, record)
+			}
+
+			if !reflect.DeepEqual(fields, tcase.want) {
+				got, _ := json.Marshal(fields)
+				want, _ := json.Marshal(tcase.want)
+				t.Fatalf("expected %s, got %s", want, got)
+			}
+		})
+	}
+}
+
+func TestMergeFields(t *testing.T) {
+	t.Parallel()
+
+	existing := []*field{
+		{Name: "id", Type: typeInteger, Mode: "REQUIRED"},
+		{Name: "Trade", Type: typeRecord, Mode: modeNullable, Fields: []*field{{Name: "size", Type: typeFloat}}},
+	}
+
+	if merged, changed := mergeFields(existing, []*field{{Name: "ID", Type: typeFloat}}); changed ||
+		!reflect.DeepEqual(merged, existing) {
+		t.Fatalf("expected existing columns to be kept, got %v", merged)
+	}
+
+	merged, changed := mergeFields(existing, []*field{
+		{Name: "venue", Type: typeString},
+		{Name: "trade", Type: typeRecord, Fields: []*field{{Name: "side", Type: typeString}}},
+	})
+	if !changed {
+		t.Fatalf("expected fields to be added")
+	}
+
+	if len(merged) != 3 || merged[2].Name != "venue" || len(merged[1].Fields) != 2 ||
+		merged[1].Fields[1].Name != "side" {
+		got, _ := json.Marshal(merged)
+		t.Fatalf("expected new fields to be added after the existing ones, got %s", got)
+	}
+
+	// The existing schema is not changed.
+	if len(existing[1].Fields) != 1 {
+		t.Fatalf("expected the existing fields to be left as they are")
+	}
+}
+
+func TestDecodeRow(t *testing.T) {
+	t.Parallel()
+
+	fields := []*field{
+		{Name: "id", Type: typeInteger},
+		{Name: "price", Type: typeFloat},
+		{Name: "ok", Type: typeBoolean},
+		{Name: "venue", Type: typeString},
+		{Name: "missing", Type: typeString},
+		{Name: "tags", Type: typeString, Mode: modeRepeated},
+		{Name: "trade", Type: typeRecord, Fields: []*field{{Name: "side", Type: typeString}}},
+		{Name: "raw", Type: typeJSON},
+	}
+
+	var row map[string]interface{}
+	if err := json.Unmarshal([]byte(`{"f":[{"v":"1"},{"v":"1.5"},{"v":"true"},{"v":"a"},{"v":null},`+
+		`{"v":[{"v":"x"},{"v":"y"}]},{"v":{"f":[{"v":"buy"}]}},{"v":"{\"a\":[1]}"}]}`), &row); err != nil {
+		t.Fatalf("failed to decode row: %v", err)
+	}
+
+	got, err := decodeRow(fields, row)
+	if err != nil {
+		t.Fatalf("failed to decode row: %v", err)
+	}
+
+	want := map[string]interface{}{
+		"id":    1.0,
+		"price": 1.5,
+		"ok":    true,
+		"venue": "a",
+		"tags":  []interface{}{"x", "y"},
+		"trade": map[string]interface{}{"side": "buy"},
+		"raw":   map[string]interface{}{"a": []interface{}{1.0}},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+
+	if _, err := decodeRow(fields[:1], row); !errors.Is(err, ErrUnexpectedRow) {
+		t.Fatalf("expected %v, got %v", ErrUnexpectedRow, err)
+	}
+}
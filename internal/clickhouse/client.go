@@ -0,0 +1,238 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package clickhouse
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrRequestFailed is returned when ClickHouse responds to a query with an error.
+var ErrRequestFailed = fmt.Errorf("clickhouse request failed")
+
+// maxRowSize is the largest row that can be read from the result of a query.
+const maxRowSize = 64 << 20
+
+// client runs queries on a database over the HTTP interface of ClickHouse.
+type client struct {
+	http     *http.Client
+	endpoint *url.URL
+	database string
+	username string
+	password string
+}
+
+// quoteIdentifier returns a database, table or column name quoted for a query.
+func quoteIdentifier(name string) string {
+	return "`" + strings.NewReplacer(`\`, `\\`, "`", "\\`").Replace(name) + "`"
+}
+
+// tableName returns the quoted name of a table of the database.
+func (c *client) tableName(table string) string {
+	return quoteIdentifier(c.database) + "." + quoteIdentifier(table)
+}
+
+// do will run a query and return the response if it succeeded. The caller must close the body of the response.
+// Values of the query are bound with "params", e.g. "{table:String}" for the param "table", and the body is sent as
+// the data of an "INSERT".
+func (c *client) do(ctx context.Context, query string, params map[string]string,
+	body io.Reader,
+) (*http.Response, error) {
+	values := url.Values{
+		"query":    {query},
+		"database": {c.database},
+
+		// Integers are read as numbers rather than strings, the same as floats, and times are written in any of
+		// the common formats, e.g. RFC 3339.
+		"output_format_json_quote_64bit_integers": {"0"},
+		"date_time_input_format":                  {"best_effort"},
+	}
+
+	for key, val := range params {
+		values.Set("param_"+key, val)
+	}
+
+	dst := *c.endpoint
+	dst.RawQuery = values.Encode()
+
+	if body == nil {
+		body = http.NoBody
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, dst.String(), body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create request: %w", err)
+	}
+
+	if c.username != "" {
+		req.Header.Set("X-ClickHouse-User", c.username)
+		req.Header.Set("X-ClickHouse-Key", c.password)
+	}
+
+	rsp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrRequestFailed, err)
+	}
+
+	if rsp.StatusCode != http.StatusOK {
+		defer rsp.Body.Close()
+
+		data, _ := io.ReadAll(io.LimitReader(rsp.Body, 4096))
+
+		return nil, fmt.Errorf("%w: %s: %s", ErrRequestFailed, rsp.Status, strings.TrimSpace(string(data)))
+	}
+
+	return rsp, nil
+}
+
+// exec will run a query that returns no rows.
+func (c *client) exec(ctx context.Context, query string, params map[string]string) error {
+	rsp, err := c.do(ctx, query, params, nil)
+	if err != nil {
+		return err
+	}
+
+	defer rsp.Body.Close()
+
+	_, err = io.Copy(io.Discard, rsp.Body)
+
+	return err
+}
+
+// insert will insert newline-delimited JSON rows into a table.
+func (c *client) insert(ctx context.Context, table string, rows []byte) error {
+	query := fmt.Sprintf("INSERT INTO %s FORMAT JSONEachRow", c.tableName(table))
+
+	rsp, err := c.do(ctx, query, nil, bytes.NewReader(rows))
+	if err != nil {
+		return err
+	}
+
+	defer rsp.Body.Close()
+
+	_, err = io.Copy(io.Discard, rsp.Body)
+
+	return err
+}
+
+// query will call "fn" with the JSON of every row of a query that ends with "FORMAT JSONEachRow".
+func (c *client) query(ctx context.Context, query string, params map[string]string, fn func(row []byte) error) error {
+	rsp, err := c.do(ctx, query, params, nil)
+	if err != nil {
+		return err
+	}
+
+	defer rsp.Body.Close()
+
+	scanner := bufio.NewScanner(rsp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxRowSize)
+
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		if err := fn(scanner.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("unable to read rows: %w", err)
+	}
+
+	return nil
+}
+
+// tableInfo is a table of the database from "system.tables".
+type tableInfo struct {
+	Name       string  `json:"name"`
+	Engine     string  `json:"engine"`
+	TotalRows  *uint64 `json:"total_rows"`
+	TotalBytes *uint64 `json:"total_bytes"`
+	PrimaryKey string  `json:"primary_key"`
+}
+
+// listTables returns the tables of the database.
+func (c *client) listTables(ctx context.Context) ([]tableInfo, error) {
+	var tables []tableInfo
+
+	err := c.query(ctx, "SELECT name, engine, total_rows, total_bytes, primary_key FROM system.tables "+
+		"WHERE database = {database:String} AND NOT is_temporary FORMAT JSONEachRow",
+		map[string]string{"database": c.database}, func(row []byte) error {
+			var info tableInfo
+			if err := json.Unmarshal(row, &info); err != nil {
+				return fmt.Errorf("%w: unable to decode table: %v", ErrRequestFailed, err)
+			}
+
+			tables = append(tables, info)
+
+			return nil
+		})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list tables: %w", err)
+	}
+
+	return tables, nil
+}
+
+// listColumns returns the types of the columns of a table, keyed by name. A table that does not exist has no
+// columns.
+func (c *client) listColumns(ctx context.Context, table string) (map[string]string, error) {
+	columns := make(map[string]string)
+
+	err := c.query(ctx, "SELECT name, type FROM system.columns "+
+		"WHERE database = {database:String} AND table = {table:String} FORMAT JSONEachRow",
+		map[string]string{"database": c.database, "table": table}, func(row []byte) error {
+			var column struct {
+				Name string `json:"name"`
+				Type string `json:"type"`
+			}
+
+			if err := json.Unmarshal(row, &column); err != nil {
+				return fmt.Errorf("%w: unable to decode column: %v", ErrRequestFailed, err)
+			}
+
+			columns[column.Name] = column.Type
+
+			return nil
+		})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list columns of %q: %w", table, err)
+	}
+
+	return columns, nil
+}
+
+// ping will return an error if the database cannot be reached or does not exist.
+func (c *client) ping(ctx context.Context) error {
+	rsp, err := c.do(ctx, "EXISTS DATABASE "+quoteIdentifier(c.database), nil, nil)
+	if err != nil {
+		return err
+	}
+
+	defer rsp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(rsp.Body, 64))
+	if err != nil {
+		return fmt.Errorf("unable to read response: %w", err)
+	}
+
+	if strings.TrimSpace(string(data)) != "1" {
+		return fmt.Errorf("%w: database %q does not exist", ErrRequestFailed, c.database)
+	}
+
+	return nil
+}
@@ -0,0 +1,263 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package control
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrUnknownRequest  = fmt.Errorf("unknown request")
+	ErrUnknownCommand  = fmt.Errorf("unknown command")
+	ErrRequestCanceled = fmt.Errorf("request is canceled")
+)
+
+// Usage describes the commands that "Exec" accepts.
+const Usage = "pause|resume|cancel <request>"
+
+// State is the state that a request has been put in while the run continues.
+type State uint8
+
+const (
+	// Running requests are made as usual.
+	Running State = iota
+
+	// Paused requests are held until they are resumed or canceled. Requests that are in-flight when a request is
+	// paused are still finished.
+	Paused
+
+	// Canceled requests are not made for the rest of the run, and are left in the checkpoint for a resumed run.
+	Canceled
+)
+
+func (state State) String() string {
+	switch state {
+	case Paused:
+		return "paused"
+	case Canceled:
+		return "canceled"
+	default:
+		return "running"
+	}
+}
+
+// Control pauses, resumes and cancels the requests of a run while it runs. A nil "Control" has every request
+// running, so the transport can consult it unconditionally.
+type Control struct {
+	mu       sync.Mutex
+	requests []string
+	states   map[string]State
+
+	// changed is closed and replaced every time the state of a request changes, to wake up the requests that are
+	// waiting for it.
+	changed chan struct{}
+
+	// onChange is called with every change of state, e.g. to show it on the dashboard.
+	onChange func(name string, state State)
+}
+
+// New will return a control that calls "onChange", if it is not nil, every time the state of a request changes.
+func New(onChange func(name string, state State)) *Control {
+	return &Control{states: make(map[string]State), changed: make(chan struct{}), onChange: onChange}
+}
+
+// Register will add a request that can be controlled, e.g. "GET /candles candles".
+func (ctl *Control) Register(name string) {
+	if ctl == nil {
+		return
+	}
+
+	ctl.mu.Lock()
+	defer ctl.mu.Unlock()
+
+	if _, ok := ctl.states[name]; !ok {
+		ctl.requests = append(ctl.requests, name)
+		ctl.states[name] = Running
+	}
+}
+
+// resolve returns the registered request for a name, which is either the whole name of the request or, if only
+// one request has it, its endpoint or table. The lock must be held.
+func (ctl *Control) resolve(name string) (string, error) {
+	if _, ok := ctl.states[name]; ok {
+		return name, nil
+	}
+
+	var matches []string
+
+	for _, request := range ctl.requests {
+		// The first field of the name is the method, which is shared by too many requests to identify one.
+		for _, field := range strings.Fields(request)[1:] {
+			if field == name {
+				matches = append(matches, request)
+
+				break
+			}
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("%w: %q", ErrUnknownRequest, name)
+	case 1:
+		return matches[0], nil
+	default:
+		return "", fmt.Errorf("%w: %q matches %q", ErrUnknownRequest, name, strings.Join(matches, `", "`))
+	}
+}
+
+// set will change the state of a request, returning the whole name of the request. Canceled requests cannot be
+// paused or resumed.
+func (ctl *Control) set(name string, state State) (string, error) {
+	if ctl == nil {
+		return "", fmt.Errorf("%w: %q", ErrUnknownRequest, name)
+	}
+
+	ctl.mu.Lock()
+
+	request, err := ctl.resolve(name)
+	if err != nil {
+		ctl.mu.Unlock()
+
+		return "", err
+	}
+
+	if ctl.states[request] == Canceled && state != Canceled {
+		ctl.mu.Unlock()
+
+		return "", fmt.Errorf("%w: %q", ErrRequestCanceled, request)
+	}
+
+	ctl.states[request] = state
+
+	close(ctl.changed)
+	ctl.changed = make(chan struct{})
+
+	ctl.mu.Unlock()
+
+	if ctl.onChange != nil {
+		ctl.onChange(request, state)
+	}
+
+	return request, nil
+}
+
+// Pause will hold the requests of "name" until they are resumed or canceled.
+func (ctl *Control) Pause(name string) (string, error) { return ctl.set(name, Paused) }
+
+// Resume will continue the requests of "name" that were paused.
+func (ctl *Control) Resume(name string) (string, error) { return ctl.set(name, Running) }
+
+// Cancel will stop the requests of "name" from being made for the rest of the run.
+func (ctl *Control) Cancel(name string) (string, error) { return ctl.set(name, Canceled) }
+
+// State returns the state of a request.
+func (ctl *Control) State(name string) State {
+	if ctl == nil {
+		return Running
+	}
+
+	ctl.mu.Lock()
+	defer ctl.mu.Unlock()
+
+	return ctl.states[name]
+}
+
+// Wait will block while a request is paused, and return its state once it is resumed or canceled, or the context
+// is done.
+func (ctl *Control) Wait(ctx context.Context, name string) State {
+	if ctl == nil {
+		return Running
+	}
+
+	for {
+		ctl.mu.Lock()
+		state, changed := ctl.states[name], ctl.changed
+		ctl.mu.Unlock()
+
+		if state != Paused {
+			return state
+		}
+
+		select {
+		case <-ctx.Done():
+			return state
+		case <-changed:
+		}
+	}
+}
+
+// Exec will run a command, e.g. "pause GET /candles candles" or "cancel candles", and return a description of
+// what it did.
+func (ctl *Control) Exec(command string) (string, error) {
+	verb, name, _ := strings.Cut(strings.TrimSpace(command), " ")
+	name = strings.TrimSpace(name)
+
+	var (
+		request string
+		err     error
+	)
+
+	switch verb {
+	case "pause":
+		request, err = ctl.Pause(name)
+	case "resume":
+		request, err = ctl.Resume(name)
+	case "cancel":
+		request, err = ctl.Cancel(name)
+	default:
+		return "", fmt.Errorf("%w: %q, expected %s", ErrUnknownCommand, verb, Usage)
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("request %q is %s", request, ctl.State(request)), nil
+}
+
+// Listen will run the commands read from "r", one per line, until it is closed or the context is done. The result
+// of every command is passed to "report".
+func (ctl *Control) Listen(ctx context.Context, r io.Reader, report func(msg string, err error)) {
+	lines := make(chan string)
+
+	go func() {
+		defer close(lines)
+
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case line, ok := <-lines:
+			if !ok {
+				return
+			}
+
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+
+			report(ctl.Exec(line))
+		}
+	}
+}
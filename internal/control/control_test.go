@@ -0,0 +1,234 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package control
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestControl(onChange func(name string, state State)) *Control {
+	ctl := New(onChange)
+
+	ctl.Register("GET /candles candles")
+	ctl.Register("GET /trades trades")
+	ctl.Register("GET /trades/history trades")
+	ctl.Register("GET /trades trades")
+
+	return ctl
+}
+
+func TestControl(t *testing.T) {
+	t.Parallel()
+
+	var ctl *Control
+
+	// A nil control has every request running.
+	ctl.Register("GET /candles candles")
+
+	if state := ctl.State("GET /candles candles"); state != Running {
+		t.Fatalf("expected a nil control to be running, got %s", state)
+	}
+
+	if _, err := ctl.Pause("candles"); !errors.Is(err, ErrUnknownRequest) {
+		t.Fatalf("expected %v, got %v", ErrUnknownRequest, err)
+	}
+
+	for _, tcase := range []struct {
+		name    string
+		command string
+		request string
+		state   State
+		err     error
+	}{
+		{name: "whole name", command: "pause GET /trades trades", request: "GET /trades trades", state: Paused},
+		{name: "table", command: "pause candles", request: "GET /candles candles", state: Paused},
+		{name: "endpoint", command: "cancel /trades/history", request: "GET /trades/history trades", state: Canceled},
+		{name: "resume", command: "  resume   candles ", request: "GET /candles candles", state: Running},
+		{name: "ambiguous", command: "pause trades", err: ErrUnknownRequest},
+		{name: "method", command: "pause GET", err: ErrUnknownRequest},
+		{name: "unknown request", command: "pause quotes", err: ErrUnknownRequest},
+		{name: "unknown command", command: "stop candles", err: ErrUnknownCommand},
+		{name: "empty", command: "", err: ErrUnknownCommand},
+	} {
+		tcase := tcase
+
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			var changes []string
+
+			ctl := newTestControl(func(name string, state State) {
+				changes = append(changes, name+" "+state.String())
+			})
+
+			if tcase.name == "resume" {
+				if _, err := ctl.Pause("candles"); err != nil {
+					t.Fatalf("failed to pause: %v", err)
+				}
+
+				changes = nil
+			}
+
+			msg, err := ctl.Exec(tcase.command)
+			if !errors.Is(err, tcase.err) {
+				t.Fatalf("expected error %v, got %v", tcase.err, err)
+			}
+
+			if tcase.err != nil {
+				if len(changes) != 0 {
+					t.Fatalf("expected no changes, got %q", changes)
+				}
+
+				return
+			}
+
+			want := "request \"" + tcase.request + "\" is " + tcase.state.String()
+			if msg != want {
+				t.Fatalf("expected %q, got %q", want, msg)
+			}
+
+			if state := ctl.State(tcase.request); state != tcase.state {
+				t.Fatalf("expected %s, got %s", tcase.state, state)
+			}
+
+			if want := []string{tcase.request + " " + tcase.state.String()}; !reflect.DeepEqual(changes, want) {
+				t.Fatalf("expected changes %q, got %q", want, changes)
+			}
+		})
+	}
+}
+
+func TestControlCancelIsFinal(t *testing.T) {
+	t.Parallel()
+
+	ctl := newTestControl(nil)
+
+	if _, err := ctl.Cancel("candles"); err != nil {
+		t.Fatalf("failed to cancel: %v", err)
+	}
+
+	for _, set := range []func(string) (string, error){ctl.Pause, ctl.Resume} {
+		if _, err := set("candles"); !errors.Is(err, ErrRequestCanceled) {
+			t.Fatalf("expected %v, got %v", ErrRequestCanceled, err)
+		}
+	}
+
+	if state := ctl.State("GET /candles candles"); state != Canceled {
+		t.Fatalf("expected the request to stay canceled, got %s", state)
+	}
+}
+
+func TestControlWait(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name  string
+		set   func(ctl *Control) (string, error)
+		state State
+	}{
+		{name: "resume", set: func(ctl *Control) (string, error) { return ctl.Resume("candles") }, state: Running},
+		{name: "cancel", set: func(ctl *Control) (string, error) { return ctl.Cancel("candles") }, state: Canceled},
+	} {
+		tcase := tcase
+
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctl := newTestControl(nil)
+
+			if state := ctl.Wait(context.Background(), "GET /candles candles"); state != Running {
+				t.Fatalf("expected a running request not to wait, got %s", state)
+			}
+
+			if _, err := ctl.Pause("candles"); err != nil {
+				t.Fatalf("failed to pause: %v", err)
+			}
+
+			states := make(chan State)
+
+			go func() { states <- ctl.Wait(context.Background(), "GET /candles candles") }()
+
+			// Changing another request does not wake up the waiting request.
+			if _, err := ctl.Pause("/trades/history"); err != nil {
+				t.Fatalf("failed to pause: %v", err)
+			}
+
+			select {
+			case state := <-states:
+				t.Fatalf("expected the request to wait, got %s", state)
+			case <-time.After(10 * time.Millisecond):
+			}
+
+			if _, err := tcase.set(ctl); err != nil {
+				t.Fatalf("failed to set the state: %v", err)
+			}
+
+			if state := <-states; state != tcase.state {
+				t.Fatalf("expected %s, got %s", tcase.state, state)
+			}
+		})
+	}
+
+	t.Run("context", func(t *testing.T) {
+		t.Parallel()
+
+		ctl := newTestControl(nil)
+
+		if _, err := ctl.Pause("candles"); err != nil {
+			t.Fatalf("failed to pause: %v", err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		if state := ctl.Wait(ctx, "GET /candles candles"); state != Paused {
+			t.Fatalf("expected %s, got %s", Paused, state)
+		}
+	})
+}
+
+func TestControlListen(t *testing.T) {
+	t.Parallel()
+
+	ctl := newTestControl(nil)
+
+	var (
+		mu      sync.Mutex
+		reports []string
+	)
+
+	input := "pause candles\n\ncancel /trades/history\nstop trades\n"
+
+	ctl.Listen(context.Background(), strings.NewReader(input), func(msg string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if err != nil {
+			msg = "error"
+		}
+
+		reports = append(reports, msg)
+	})
+
+	want := []string{
+		`request "GET /candles candles" is paused`,
+		`request "GET /trades/history trades" is canceled`,
+		"error",
+	}
+	if !reflect.DeepEqual(reports, want) {
+		t.Fatalf("expected reports %q, got %q", want, reports)
+	}
+}
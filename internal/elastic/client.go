@@ -0,0 +1,363 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package elastic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrRequestFailed is returned when the cluster responds to a request with an error.
+	ErrRequestFailed = fmt.Errorf("elasticsearch request failed")
+
+	// ErrBulkFailed is returned when some of the records of a bulk request could not be indexed.
+	ErrBulkFailed = fmt.Errorf("failed to index records")
+)
+
+// errIndexNotFound is returned by "do" when the index of a request does not exist.
+var errIndexNotFound = fmt.Errorf("index not found")
+
+// errRequestTooLarge is returned by "do" when the cluster rejects the body of a request for its size, i.e. for being
+// larger than "http.max_content_length".
+var errRequestTooLarge = fmt.Errorf("%w: request is too large", ErrRequestFailed)
+
+// scrollTimeout is how long the cluster keeps the context of a scroll between the pages of a read.
+const scrollTimeout = "1m"
+
+// client makes requests to the REST API of Elasticsearch, which OpenSearch also implements.
+type client struct {
+	http     *http.Client
+	endpoint *url.URL
+
+	// username and password authenticate requests with basic authentication, and apiKey with an API key.
+	username string
+	password string
+	apiKey   string
+}
+
+// esError is the body of an error response, where "error" is either an object or, for some errors, a string.
+type esError struct {
+	Error  json.RawMessage `json:"error"`
+	Status int             `json:"status"`
+}
+
+// errorCause is the cause of a failed request, or of a record that failed to be indexed.
+type errorCause struct {
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+}
+
+func (cause errorCause) String() string {
+	return fmt.Sprintf("%s: %s", cause.Type, cause.Reason)
+}
+
+// describeError returns a description of the error in the body of a response.
+func describeError(data []byte) (string, string) {
+	var eserr esError
+	if err := json.Unmarshal(data, &eserr); err != nil || len(eserr.Error) == 0 {
+		return "", ""
+	}
+
+	var cause errorCause
+	if err := json.Unmarshal(eserr.Error, &cause); err == nil && cause.Type != "" {
+		return cause.Type, cause.String()
+	}
+
+	var msg string
+	if err := json.Unmarshal(eserr.Error, &msg); err == nil {
+		return "", msg
+	}
+
+	return "", ""
+}
+
+// url returns the URL of an unescaped path on the cluster. Index names are validated, so they need no escaping.
+func (c *client) url(path string, query url.Values) string {
+	dst := *c.endpoint
+	dst.Path = strings.TrimSuffix(dst.Path, "/") + path
+	dst.RawQuery = query.Encode()
+
+	return dst.String()
+}
+
+// do will make a request to the cluster and return the response if it succeeded. The caller must close the body of
+// the response. Requests for an index that does not exist return "errIndexNotFound".
+func (c *client) do(ctx context.Context, method, path string, query url.Values, contentType string,
+	body io.Reader,
+) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.url(path, query), body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create request: %w", err)
+	}
+
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	req.Header.Set("Accept", "application/json")
+
+	switch {
+	case c.apiKey != "":
+		req.Header.Set("Authorization", "ApiKey "+c.apiKey)
+	case c.username != "":
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	rsp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrRequestFailed, err)
+	}
+
+	if rsp.StatusCode >= http.StatusOK && rsp.StatusCode < http.StatusMultipleChoices {
+		return rsp, nil
+	}
+
+	defer rsp.Body.Close()
+
+	data, _ := io.ReadAll(io.LimitReader(rsp.Body, 64*1024))
+
+	errType, desc := describeError(data)
+	if errType == "index_not_found_exception" || (rsp.StatusCode == http.StatusNotFound && method == http.MethodHead) {
+		return nil, errIndexNotFound
+	}
+
+	if rsp.StatusCode == http.StatusRequestEntityTooLarge {
+		return nil, fmt.Errorf("%w: %s %s: %s", errRequestTooLarge, method, path, rsp.Status)
+	}
+
+	if desc == "" {
+		return nil, fmt.Errorf("%w: %s %s: %s", ErrRequestFailed, method, path, rsp.Status)
+	}
+
+	return nil, fmt.Errorf("%w: %s %s: %s: %s", ErrRequestFailed, method, path, rsp.Status, desc)
+}
+
+// call will make a request with a JSON body, if it is not nil, and decode the JSON body of the response into
+// "dst", if it is not nil.
+func (c *client) call(ctx context.Context, method, path string, query url.Values, body, dst interface{}) error {
+	var (
+		reader      io.Reader
+		contentType string
+	)
+
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("unable to encode request: %w", err)
+		}
+
+		reader = bytes.NewReader(data)
+		contentType = "application/json"
+	}
+
+	rsp, err := c.do(ctx, method, path, query, contentType, reader)
+	if err != nil {
+		return err
+	}
+
+	defer rsp.Body.Close()
+
+	if dst == nil {
+		_, err = io.Copy(io.Discard, rsp.Body)
+	} else {
+		err = json.NewDecoder(rsp.Body).Decode(dst)
+	}
+
+	if err != nil {
+		return fmt.Errorf("%w: unable to decode response: %v", ErrRequestFailed, err)
+	}
+
+	return nil
+}
+
+// createIndex will create an index with the mappings, and do nothing if the index already exists.
+func (c *client) createIndex(ctx context.Context, index string, mappings map[string]interface{}) error {
+	err := c.call(ctx, http.MethodPut, "/"+index, nil,
+		map[string]interface{}{"mappings": mappings}, nil)
+	if err != nil && strings.Contains(err.Error(), "resource_already_exists_exception") {
+		return nil
+	}
+
+	return err
+}
+
+// bulkResponse is the response of a bulk request.
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int         `json:"status"`
+		Error  *errorCause `json:"error"`
+	} `json:"items"`
+}
+
+// bulk will send a body of newline-delimited actions and documents to the bulk API, and return the number of
+// documents it indexed. Documents that were not created because their ID already exists are skipped. If any of the
+// others failed, the error describes the first failure.
+func (c *client) bulk(ctx context.Context, body []byte, refresh string) (int, error) {
+	var query url.Values
+	if refresh != "" {
+		query = url.Values{"refresh": {refresh}}
+	}
+
+	rsp, err := c.do(ctx, http.MethodPost, "/_bulk", query, "application/x-ndjson", bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	defer rsp.Body.Close()
+
+	var brsp bulkResponse
+	if err := json.NewDecoder(rsp.Body).Decode(&brsp); err != nil {
+		return 0, fmt.Errorf("%w: unable to decode response: %v", ErrRequestFailed, err)
+	}
+
+	var (
+		failed, skipped int
+		first           *errorCause
+	)
+
+	for _, item := range brsp.Items {
+		for action, result := range item {
+			if result.Error == nil && result.Status < http.StatusMultipleChoices {
+				continue
+			}
+
+			if action == "create" && result.Status == http.StatusConflict {
+				skipped++
+
+				continue
+			}
+
+			failed++
+
+			if first == nil {
+				first = result.Error
+				if first == nil {
+					first = &errorCause{Type: "status", Reason: strconv.Itoa(result.Status)}
+				}
+			}
+		}
+	}
+
+	if failed > 0 {
+		return len(brsp.Items) - failed - skipped, fmt.Errorf("%w: %d of %d: %s", ErrBulkFailed, failed,
+			len(brsp.Items), first)
+	}
+
+	return len(brsp.Items) - skipped, nil
+}
+
+// indexInfo is an index of the cluster, as listed by the cat indices API.
+type indexInfo struct {
+	Index     string `json:"index"`
+	DocsCount string `json:"docs.count"`
+	StoreSize string `json:"store.size"`
+}
+
+// listIndices returns the indices that match a pattern, e.g. "gidari-*".
+func (c *client) listIndices(ctx context.Context, pattern string) ([]indexInfo, error) {
+	var indices []indexInfo
+
+	query := url.Values{"format": {"json"}, "bytes": {"b"}, "h": {"index,docs.count,store.size"}}
+	if err := c.call(ctx, http.MethodGet, "/_cat/indices/"+pattern, query, nil,
+		&indices); err != nil {
+		if errors.Is(err, errIndexNotFound) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return indices, nil
+}
+
+// deleteAll will delete every document of an index, keeping its mappings. Indices that do not exist are ignored.
+func (c *client) deleteAll(ctx context.Context, index string) error {
+	query := url.Values{"refresh": {"true"}, "conflicts": {"proceed"}}
+	body := map[string]interface{}{"query": map[string]interface{}{"match_all": map[string]interface{}{}}}
+
+	err := c.call(ctx, http.MethodPost, "/"+index+"/_delete_by_query", query, body, nil)
+	if errors.Is(err, errIndexNotFound) {
+		return nil
+	}
+
+	return err
+}
+
+// searchResponse is a page of the documents of a scroll.
+type searchResponse struct {
+	ScrollID string `json:"_scroll_id"`
+	Hits     struct {
+		Hits []struct {
+			Source map[string]interface{} `json:"_source"`
+		} `json:"hits"`
+	} `json:"hits"`
+}
+
+// scroll will call "fn" with every document of an index, in index order. Indices that do not exist have no
+// documents.
+func (c *client) scroll(ctx context.Context, index string, pageSize int, fn func(map[string]interface{}) error) error {
+	var page searchResponse
+
+	query := url.Values{"scroll": {scrollTimeout}}
+	body := map[string]interface{}{"size": pageSize, "sort": []string{"_doc"}}
+
+	err := c.call(ctx, http.MethodPost, "/"+index+"/_search", query, body, &page)
+	if errors.Is(err, errIndexNotFound) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	scrollID := page.ScrollID
+
+	defer func() {
+		if scrollID != "" {
+			// The scroll expires on its own if it cannot be cleared.
+			_ = c.call(context.Background(), http.MethodDelete, "/_search/scroll", nil,
+				map[string]interface{}{"scroll_id": scrollID}, nil)
+		}
+	}()
+
+	for len(page.Hits.Hits) > 0 {
+		for _, hit := range page.Hits.Hits {
+			if err := fn(hit.Source); err != nil {
+				return err
+			}
+		}
+
+		page = searchResponse{}
+
+		if err := c.call(ctx, http.MethodPost, "/_search/scroll", nil,
+			map[string]interface{}{"scroll": scrollTimeout, "scroll_id": scrollID}, &page); err != nil {
+			return err
+		}
+
+		if page.ScrollID != "" {
+			scrollID = page.ScrollID
+		}
+	}
+
+	return nil
+}
+
+// ping will check that the cluster responds to requests with the credentials of the client.
+func (c *client) ping(ctx context.Context) error {
+	return c.call(ctx, http.MethodGet, "/", nil, nil, nil)
+}
@@ -0,0 +1,149 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package events
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/alpstable/gidari/tools"
+)
+
+const (
+	// ChunkStarted is emitted when the HTTP request of a chunk or page is started.
+	ChunkStarted = "chunk_started"
+
+	// ChunkCommitted is emitted once the data of a chunk or page has been committed to every storage target.
+	ChunkCommitted = "chunk_committed"
+
+	// Error is emitted when a request fails after every retry, or a storage target fails to commit.
+	Error = "error"
+
+	// RunSummary is the last event of a run, emitted once it is over.
+	RunSummary = "run_summary"
+)
+
+// Event is a line of the event stream of a run.
+type Event struct {
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+
+	// Request identifies the configured request of the event, e.g. "GET /candles candles", and Table is the table
+	// that it is written to.
+	Request string `json:"request,omitempty"`
+	Table   string `json:"table,omitempty"`
+
+	// Page is the 1-indexed page of the request, and ChunkStart and ChunkEnd the bounds of its timeseries chunk.
+	Page       int        `json:"page,omitempty"`
+	ChunkStart *time.Time `json:"chunk_start,omitempty"`
+	ChunkEnd   *time.Time `json:"chunk_end,omitempty"`
+
+	// Rows is the number of records that were committed, for the chunk or the whole run.
+	Rows int64 `json:"rows,omitempty"`
+
+	// Sink identifies the storage target of an error, e.g. "connectionStrings[0] (mongodb)".
+	Sink string `json:"sink,omitempty"`
+
+	Error string `json:"error,omitempty"`
+
+	// Status, Requests and DurationMS summarize a run: whether it "completed", was "interrupted" or "failed", the
+	// number of chunks and pages that were committed, and how long it took.
+	Status     string `json:"status,omitempty"`
+	Requests   int    `json:"requests,omitempty"`
+	DurationMS int64  `json:"duration_ms,omitempty"`
+}
+
+// Stream writes the events of a run as newline-delimited JSON, for orchestrators to follow the progress of the run.
+// A nil "Stream" emits nothing, so the transport can emit to it unconditionally.
+type Stream struct {
+	clock   tools.Clock
+	started time.Time
+
+	mu       sync.Mutex
+	emit     func(Event)
+	requests int
+	rows     int64
+}
+
+// New will return a stream of events written to "out" that are timed with the clock. The default clock is
+// "tools.RealClock".
+func New(out io.Writer, clock tools.Clock) *Stream {
+	encoder := json.NewEncoder(out)
+
+	// Events that cannot be written are dropped, since the run must not fail on its progress.
+	return NewFunc(func(event Event) { _ = encoder.Encode(event) }, clock)
+}
+
+// NewFunc will return a stream of events that are passed to "emit" as they happen, for code that embeds the
+// transport. Events are passed one at a time, so "emit" should return quickly.
+func NewFunc(emit func(Event), clock tools.Clock) *Stream {
+	clock = tools.ClockOrReal(clock)
+
+	return &Stream{clock: clock, started: clock.Now(), emit: emit}
+}
+
+// Emit will write an event to the stream, at the current time unless it has one. Committed chunks are counted for
+// the summary of the run.
+func (stream *Stream) Emit(event Event) {
+	if stream == nil {
+		return
+	}
+
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+
+	if event.Time.IsZero() {
+		event.Time = stream.clock.Now().UTC()
+	}
+
+	if event.Type == ChunkCommitted {
+		stream.requests++
+		stream.rows += event.Rows
+	}
+
+	stream.emit(event)
+}
+
+// Progress returns the number of chunks and pages, and of rows, that have been committed so far, and how long the
+// run has taken.
+func (stream *Stream) Progress() (int, int64, time.Duration) {
+	if stream == nil {
+		return 0, 0, 0
+	}
+
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+
+	return stream.requests, stream.rows, stream.clock.Now().Sub(stream.started)
+}
+
+// Summary will emit the summary of a run that is over with "status", and "err" if it did not complete: the chunks
+// and rows that were committed, and how long the run took.
+func (stream *Stream) Summary(status string, err error) {
+	if stream == nil {
+		return
+	}
+
+	stream.mu.Lock()
+	event := Event{
+		Type:       RunSummary,
+		Status:     status,
+		Requests:   stream.requests,
+		Rows:       stream.rows,
+		DurationMS: stream.clock.Now().Sub(stream.started).Milliseconds(),
+	}
+	stream.mu.Unlock()
+
+	if err != nil {
+		event.Error = err.Error()
+	}
+
+	stream.Emit(event)
+}
@@ -0,0 +1,74 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package events
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alpstable/gidari/tools"
+)
+
+func TestStream(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+
+	start := time.Date(2022, 10, 14, 15, 4, 5, 0, time.UTC)
+	clock := tools.NewFakeClock(start)
+	stream := New(&out, clock)
+
+	chunkStart, chunkEnd := start.Add(-time.Hour), start
+
+	stream.Emit(Event{Type: ChunkStarted, Request: "GET /candles candles", Table: "candles", Page: 1,
+		ChunkStart: &chunkStart, ChunkEnd: &chunkEnd})
+
+	clock.Advance(time.Second)
+	stream.Emit(Event{Type: ChunkCommitted, Request: "GET /candles candles", Table: "candles", Page: 1, Rows: 60})
+	stream.Emit(Event{Type: ChunkCommitted, Request: "GET /trades trades", Table: "trades", Page: 2, Rows: 40})
+	stream.Emit(Event{Type: Error, Sink: "connectionStrings[1] (mongodb)", Error: "connection refused"})
+
+	clock.Advance(time.Second)
+	stream.Summary("failed", fmt.Errorf("unable to commit"))
+
+	if requests, rows, elapsed := stream.Progress(); requests != 2 || rows != 100 || elapsed != 2*time.Second {
+		t.Fatalf("expected progress of 2 requests, 100 rows in 2s, got %d, %d in %s", requests, rows, elapsed)
+	}
+
+	// A nil stream emits nothing.
+	var none *Stream
+	none.Emit(Event{Type: ChunkStarted})
+	none.Summary("completed", nil)
+
+	if requests, rows, _ := none.Progress(); requests != 0 || rows != 0 {
+		t.Fatalf("expected no progress for a nil stream, got %d requests and %d rows", requests, rows)
+	}
+
+	want := []string{
+		`{"type":"chunk_started","time":"2022-10-14T15:04:05Z","request":"GET /candles candles","table":"candles",` +
+			`"page":1,"chunk_start":"2022-10-14T14:04:05Z","chunk_end":"2022-10-14T15:04:05Z"}`,
+		`{"type":"chunk_committed","time":"2022-10-14T15:04:06Z","request":"GET /candles candles",` +
+			`"table":"candles","page":1,"rows":60}`,
+		`{"type":"chunk_committed","time":"2022-10-14T15:04:06Z","request":"GET /trades trades","table":"trades",` +
+			`"page":2,"rows":40}`,
+		`{"type":"error","time":"2022-10-14T15:04:06Z","sink":"connectionStrings[1] (mongodb)",` +
+			`"error":"connection refused"}`,
+		`{"type":"run_summary","time":"2022-10-14T15:04:07Z","rows":100,"error":"unable to commit",` +
+			`"status":"failed","requests":2,"duration_ms":2000}`,
+	}
+
+	if got := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n"); strings.Join(got, "\n") !=
+		strings.Join(want, "\n") {
+		t.Fatalf("expected\n%s\ngot\n%s", strings.Join(want, "\n"), out.String())
+	}
+}
@@ -0,0 +1,371 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package external
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/alpstable/gidari/internal/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+var (
+	ErrInvalidStorage    = fmt.Errorf("invalid storage")
+	ErrReadNotSupported  = fmt.Errorf("storage does not support reads")
+	ErrStorageRegistered = fmt.Errorf("storage already registered")
+	ErrUnknownStorage    = fmt.Errorf("unknown storage")
+)
+
+// Batch is a batch of records that is written to a table.
+type Batch struct {
+	// Table is the name of the table, or collection, that the records are written to.
+	Table string
+
+	// Records are the records of the batch, decoded from JSON.
+	Records []map[string]interface{}
+
+	// WriteMode is how the records are written: "upsert", "insert" or "append". It is never empty.
+	WriteMode string
+
+	// ConflictKeys are the columns that identify a record for "upsert" and "append" writes. If they are empty, the
+	// storage decides what identifies a record, e.g. the primary key of the table.
+	ConflictKeys []string
+}
+
+// Storage is a storage backend that is registered with "Register", e.g. a data warehouse that is not built into
+// Gidari.
+type Storage interface {
+	// Upsert will write a batch of records to its table.
+	Upsert(ctx context.Context, batch *Batch) error
+
+	// Truncate will delete every record of the tables.
+	Truncate(ctx context.Context, tables []string) error
+
+	// Close will disconnect the storage.
+	Close() error
+}
+
+// Transactional is implemented by storage that writes the batches of a transaction atomically. "Begin" is called
+// when a transaction is started, and either "Commit" or "Rollback" once it is finished. The upserts and truncates
+// of storage that is not transactional are written as they are made.
+type Transactional interface {
+	Begin(ctx context.Context) error
+	Commit(ctx context.Context) error
+	Rollback(ctx context.Context) error
+}
+
+// Reader is implemented by storage that can read the records of a table back out, e.g. to export them.
+type Reader interface {
+	Read(ctx context.Context, table string, fn func(record map[string]interface{}) error) error
+}
+
+// Pinger is implemented by storage that can check its connection.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Factory returns the storage for a connection string with the scheme that it is registered for.
+type Factory func(ctx context.Context, connectionString string) (Storage, error)
+
+// registration is a factory and the byte representation of its storage.
+type registration struct {
+	factory     Factory
+	storageType uint8
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]registration)
+)
+
+// Register will register the factory of the storage for connection strings with "scheme", e.g. "snowflake" for
+// "snowflake://...". Storage is usually registered in an "init" function, and a scheme can only be registered once.
+// The schemes of the storage of this module cannot be registered.
+func Register(scheme string, factory Factory) error {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if scheme == "" || factory == nil {
+		return fmt.Errorf("%w: a scheme and factory are required", ErrInvalidStorage)
+	}
+
+	if _, ok := registry[scheme]; ok || proto.IsBuiltinScheme(scheme) {
+		return fmt.Errorf("%w: %q", ErrStorageRegistered, scheme)
+	}
+
+	storageType, err := proto.RegisterExternalType(scheme)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidStorage, err)
+	}
+
+	registry[scheme] = registration{factory: factory, storageType: storageType}
+
+	return nil
+}
+
+// Unregister will remove the storage registered for "scheme", if any, e.g. for a test to clean up after itself. The
+// storage type of the scheme is kept, for it to be reused if the scheme is registered again.
+func Unregister(scheme string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	delete(registry, scheme)
+}
+
+// Registered returns true if storage is registered for "scheme".
+func Registered(scheme string) bool {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	_, ok := registry[scheme]
+
+	return ok
+}
+
+// Schemes returns the schemes that storage is registered for, sorted.
+func Schemes() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	schemes := make([]string, 0, len(registry))
+	for scheme := range registry {
+		schemes = append(schemes, scheme)
+	}
+
+	sort.Strings(schemes)
+
+	return schemes
+}
+
+// New returns the registered storage for the scheme of a connection string.
+func New(ctx context.Context, dns string) (*External, error) {
+	scheme := proto.SchemeFromConnectionString(dns)
+
+	registryMu.RLock()
+	reg, ok := registry[scheme]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownStorage, scheme)
+	}
+
+	stg, err := reg.factory(ctx, dns)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s storage: %w", scheme, err)
+	}
+
+	if stg == nil {
+		return nil, fmt.Errorf("%w: the factory of %s returned no storage", ErrInvalidStorage, scheme)
+	}
+
+	return &External{stg: stg, storageType: reg.storageType}, nil
+}
+
+// External is the storage device of a registered storage backend.
+type External struct {
+	stg         Storage
+	storageType uint8
+}
+
+// Close will disconnect the storage.
+func (ext *External) Close() {
+	ext.stg.Close()
+}
+
+// ListPrimaryKeys will return no primary keys, since the storage decides what identifies a record.
+func (ext *External) ListPrimaryKeys(context.Context) (*proto.ListPrimaryKeysResponse, error) {
+	return &proto.ListPrimaryKeysResponse{PKSet: make(map[string]*proto.PrimaryKeys)}, nil
+}
+
+// ListTables will return no tables.
+func (ext *External) ListTables(context.Context) (*proto.ListTablesResponse, error) {
+	return &proto.ListTablesResponse{TableSet: make(map[string]*proto.Table)}, nil
+}
+
+// IsNoSQL returns true, since records are written to the storage as they are.
+func (ext *External) IsNoSQL() bool {
+	return true
+}
+
+// Read will call "fn" with every record of the table on the request, if the storage is a "Reader". If the request
+// has an "OrderBy" column, the records are read into memory and sorted.
+func (ext *External) Read(ctx context.Context, req *proto.ReadRecordsRequest, fn proto.ReadFunc) error {
+	reader, ok := ext.stg.(Reader)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrReadNotSupported, proto.SchemeFromStorageType(ext.storageType))
+	}
+
+	if req.OrderBy == "" {
+		if err := reader.Read(ctx, req.Table, fn); err != nil {
+			return fmt.Errorf("unable to read %s: %w", req.Table, err)
+		}
+
+		return nil
+	}
+
+	var records []map[string]interface{}
+
+	err := reader.Read(ctx, req.Table, func(record map[string]interface{}) error {
+		records = append(records, record)
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("unable to read %s: %w", req.Table, err)
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		return proto.LessValue(records[i][req.OrderBy], records[j][req.OrderBy])
+	})
+
+	for _, record := range records {
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// StartTx will start a transaction. The functions sent to the transaction are run in order, and a transactional
+// storage is committed if every function succeeds, or rolled back otherwise.
+func (ext *External) StartTx(ctx context.Context) (*proto.Txn, error) {
+	txn := &proto.Txn{
+		FunctionCh: make(chan proto.TxnChanFn),
+		DoneCh:     make(chan error, 1),
+		CommitCh:   make(chan bool, 1),
+	}
+
+	transactional, isTx := ext.stg.(Transactional)
+	if isTx {
+		if err := transactional.Begin(ctx); err != nil {
+			return nil, fmt.Errorf("unable to begin transaction: %w", err)
+		}
+	}
+
+	go func() {
+		var err error
+
+		for fn := range txn.FunctionCh {
+			if err != nil {
+				continue
+			}
+
+			err = fn(ctx, ext)
+		}
+
+		commit := err == nil && <-txn.CommitCh
+
+		switch {
+		case !isTx:
+		case commit:
+			if commitErr := transactional.Commit(ctx); commitErr != nil {
+				err = fmt.Errorf("unable to commit transaction: %w", commitErr)
+			}
+		default:
+			if rollbackErr := transactional.Rollback(ctx); rollbackErr != nil && err == nil {
+				err = fmt.Errorf("unable to rollback transaction: %w", rollbackErr)
+			}
+		}
+
+		txn.DoneCh <- err
+	}()
+
+	return txn, nil
+}
+
+// Truncate will delete every record of the tables on the request.
+func (ext *External) Truncate(ctx context.Context, req *proto.TruncateRequest) (*proto.TruncateResponse, error) {
+	if err := ext.stg.Truncate(ctx, req.GetTables()); err != nil {
+		return nil, fmt.Errorf("unable to truncate: %w", err)
+	}
+
+	return &proto.TruncateResponse{}, nil
+}
+
+// Type returns the byte representation that the scheme of the storage was registered with.
+func (ext *External) Type() uint8 {
+	return ext.storageType
+}
+
+// upsert will write the records of a request to the storage as a batch.
+func (ext *External) upsert(ctx context.Context, table, mode string, keys []string, structs []*structpb.Struct) error {
+	batch := &Batch{
+		Table:        table,
+		Records:      make([]map[string]interface{}, len(structs)),
+		WriteMode:    proto.WriteModeOrDefault(mode),
+		ConflictKeys: keys,
+	}
+
+	for idx, record := range structs {
+		batch.Records[idx] = record.AsMap()
+	}
+
+	if err := ext.stg.Upsert(ctx, batch); err != nil {
+		return fmt.Errorf("unable to upsert: %w", err)
+	}
+
+	return nil
+}
+
+// Upsert will write the records on the request to the storage.
+func (ext *External) Upsert(ctx context.Context, req *proto.UpsertRequest) (*proto.UpsertResponse, error) {
+	records, err := proto.DecodeUpsertRequest(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode records: %w", err)
+	}
+
+	// Do nothing if there are no records.
+	if len(records) == 0 {
+		return &proto.UpsertResponse{}, nil
+	}
+
+	if err := ext.upsert(ctx, req.GetTable(), req.GetWriteMode(), req.GetConflictKeys(), records); err != nil {
+		return nil, err
+	}
+
+	return &proto.UpsertResponse{UpsertedCount: int64(len(records))}, nil
+}
+
+// UpsertBinary will write "property bag"-like records, with the data encoded as a JSON string.
+func (ext *External) UpsertBinary(ctx context.Context,
+	req *proto.UpsertBinaryRequest,
+) (*proto.UpsertBinaryResponse, error) {
+	records, err := proto.DecodeUpsertBinaryRequest(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode records: %w", err)
+	}
+
+	// Do nothing if there are no records.
+	if len(records) == 0 {
+		return &proto.UpsertBinaryResponse{}, nil
+	}
+
+	if err := ext.upsert(ctx, req.GetTable(), "", nil, records); err != nil {
+		return nil, err
+	}
+
+	return &proto.UpsertBinaryResponse{}, nil
+}
+
+// Ping will check the connection of the storage, if it is a "Pinger".
+func (ext *External) Ping() error {
+	pinger, ok := ext.stg.(Pinger)
+	if !ok {
+		return nil
+	}
+
+	if err := pinger.Ping(context.Background()); err != nil {
+		return fmt.Errorf("unable to ping: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,214 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package external
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/alpstable/gidari/internal/proto"
+)
+
+// memoryStorage is a transactional storage that keeps the records of committed transactions in memory.
+type memoryStorage struct {
+	pending, tables map[string][]map[string]interface{}
+	calls           []string
+	fail            error
+}
+
+func newMemoryStorage() *memoryStorage {
+	return &memoryStorage{tables: make(map[string][]map[string]interface{})}
+}
+
+func (mem *memoryStorage) Upsert(_ context.Context, batch *Batch) error {
+	mem.calls = append(mem.calls, fmt.Sprintf("upsert %s %s %d", batch.Table, batch.WriteMode, len(batch.Records)))
+	if mem.fail != nil {
+		return mem.fail
+	}
+
+	mem.pending[batch.Table] = append(mem.pending[batch.Table], batch.Records...)
+
+	return nil
+}
+
+func (mem *memoryStorage) Truncate(_ context.Context, tables []string) error {
+	mem.calls = append(mem.calls, fmt.Sprintf("truncate %v", tables))
+
+	return nil
+}
+
+func (mem *memoryStorage) Close() error { return nil }
+
+func (mem *memoryStorage) Begin(context.Context) error {
+	mem.calls = append(mem.calls, "begin")
+	mem.pending = make(map[string][]map[string]interface{})
+
+	return nil
+}
+
+func (mem *memoryStorage) Commit(context.Context) error {
+	mem.calls = append(mem.calls, "commit")
+
+	for table, records := range mem.pending {
+		mem.tables[table] = append(mem.tables[table], records...)
+	}
+
+	return nil
+}
+
+func (mem *memoryStorage) Rollback(context.Context) error {
+	mem.calls = append(mem.calls, "rollback")
+
+	return nil
+}
+
+func (mem *memoryStorage) Read(_ context.Context, table string, fn func(map[string]interface{}) error) error {
+	for _, record := range mem.tables[table] {
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func TestRegister(t *testing.T) {
+	t.Parallel()
+
+	factory := func(context.Context, string) (Storage, error) { return newMemoryStorage(), nil }
+
+	if err := Register("memory-register", factory); err != nil {
+		t.Fatalf("failed to register storage: %v", err)
+	}
+
+	t.Cleanup(func() { Unregister("memory-register") })
+
+	for _, tcase := range []struct {
+		name    string
+		scheme  string
+		factory Factory
+		err     error
+	}{
+		{name: "no scheme", factory: factory, err: ErrInvalidStorage},
+		{name: "no factory", scheme: "memory-nil", err: ErrInvalidStorage},
+		{name: "registered", scheme: "memory-register", factory: factory, err: ErrStorageRegistered},
+		{name: "built-in", scheme: "postgresql", factory: factory, err: ErrStorageRegistered},
+	} {
+		if err := Register(tcase.scheme, tcase.factory); !errors.Is(err, tcase.err) {
+			t.Fatalf("%s: expected error %v, got %v", tcase.name, tcase.err, err)
+		}
+	}
+
+	if !Registered("memory-register") || Registered("memory-nil") {
+		t.Fatalf("expected only the registered scheme to be registered, got %v", Schemes())
+	}
+
+	ext, err := New(context.Background(), "memory-register://warehouse")
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+
+	if scheme := proto.SchemeFromStorageType(ext.Type()); scheme != "memory-register" {
+		t.Fatalf("expected the type of the storage to be the registered scheme, got %q", scheme)
+	}
+
+	if _, err := New(context.Background(), "memory-missing://warehouse"); !errors.Is(err, ErrUnknownStorage) {
+		t.Fatalf("expected error %v, got %v", ErrUnknownStorage, err)
+	}
+}
+
+func TestExternalTransaction(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	for _, tcase := range []struct {
+		name    string
+		commit  bool
+		fail    error
+		calls   string
+		records int
+	}{
+		{
+			name:    "commit",
+			commit:  true,
+			calls:   "[begin truncate [trades] upsert trades append 2 commit]",
+			records: 2,
+		},
+		{
+			name:  "rollback",
+			calls: "[begin truncate [trades] upsert trades append 2 rollback]",
+		},
+		{
+			name:   "failed",
+			commit: true,
+			fail:   errors.New("warehouse is down"),
+			calls:  "[begin truncate [trades] upsert trades append 2 rollback]",
+		},
+	} {
+		mem := newMemoryStorage()
+		mem.fail = tcase.fail
+
+		ext := &External{stg: mem, storageType: proto.ExternalType}
+
+		txn, err := ext.StartTx(ctx)
+		if err != nil {
+			t.Fatalf("%s: failed to start transaction: %v", tcase.name, err)
+		}
+
+		txn.Send(func(ctx context.Context, stg proto.Storage) error {
+			_, err := stg.Truncate(ctx, &proto.TruncateRequest{Tables: []string{"trades"}})
+
+			return err
+		})
+
+		txn.Send(func(ctx context.Context, stg proto.Storage) error {
+			_, err := stg.Upsert(ctx, &proto.UpsertRequest{
+				Table:     "trades",
+				Data:      []byte(`[{"id":2},{"id":1}]`),
+				WriteMode: proto.WriteModeAppend,
+			})
+
+			return err
+		})
+
+		if tcase.commit {
+			err = txn.Commit()
+		} else {
+			err = txn.Rollback()
+		}
+
+		if !errors.Is(err, tcase.fail) {
+			t.Fatalf("%s: expected error %v, got %v", tcase.name, tcase.fail, err)
+		}
+
+		if calls := fmt.Sprint(mem.calls); calls != tcase.calls {
+			t.Fatalf("%s: expected calls %s, got %s", tcase.name, tcase.calls, calls)
+		}
+
+		var ids []interface{}
+
+		err = ext.Read(ctx, &proto.ReadRecordsRequest{Table: "trades", OrderBy: "id"},
+			func(record map[string]interface{}) error {
+				ids = append(ids, record["id"])
+
+				return nil
+			})
+		if err != nil {
+			t.Fatalf("%s: failed to read: %v", tcase.name, err)
+		}
+
+		if len(ids) != tcase.records || (len(ids) == 2 && fmt.Sprint(ids) != "[1 2]") {
+			t.Fatalf("%s: expected %d records in order, got %v", tcase.name, tcase.records, ids)
+		}
+	}
+}
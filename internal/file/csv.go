@@ -0,0 +1,316 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package file
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/alpstable/gidari/internal/proto"
+	"github.com/alpstable/gidari/tools"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// defaultInferRows is the default number of records that the columns of a CSV file are inferred from.
+	defaultInferRows = 100
+
+	// ExtraColumnsLog will drop the fields of a record that are not columns of its CSV file, logging a warning the
+	// first time each field is dropped. This is the default.
+	ExtraColumnsLog = "log"
+
+	// ExtraColumnsAppend will add the fields of a record that are not columns of its CSV file as new columns. Since
+	// the header of a file cannot change, a new file is started with the wider header.
+	ExtraColumnsAppend = "append"
+)
+
+var ErrInvalidCSVOptions = fmt.Errorf("invalid csv options")
+
+// csvOptions are the settings of a "csv://" connection string.
+type csvOptions struct {
+	// inferRows is the number of records that the columns of a table are inferred from.
+	inferRows int
+
+	// extraColumns is what happens to the fields of a record that are not columns, either "log" or "append".
+	extraColumns string
+}
+
+// parseCSVOptions will parse the "inferRows" and "extraColumns" parameters of a connection string.
+func parseCSVOptions(query url.Values) (csvOptions, error) {
+	opts := csvOptions{inferRows: defaultInferRows, extraColumns: ExtraColumnsLog}
+
+	if str := query.Get("inferRows"); str != "" {
+		rows, err := strconv.Atoi(str)
+		if err != nil || rows < 1 {
+			return opts, fmt.Errorf("%w: inferRows %q must be a positive integer", ErrInvalidCSVOptions, str)
+		}
+
+		opts.inferRows = rows
+	}
+
+	switch str := query.Get("extraColumns"); str {
+	case "":
+	case ExtraColumnsLog, ExtraColumnsAppend:
+		opts.extraColumns = str
+	default:
+		return opts, fmt.Errorf("%w: extraColumns %q must be %q or %q", ErrInvalidCSVOptions, str, ExtraColumnsLog,
+			ExtraColumnsAppend)
+	}
+
+	return opts, nil
+}
+
+// flattenRecord will flatten the nested objects of a record into columns named by their path, e.g. {"a": {"b": 1}}
+// has the column "a.b".
+func flattenRecord(prefix string, record map[string]interface{}, flat map[string]interface{}) {
+	for key, val := range record {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		if obj, ok := val.(map[string]interface{}); ok {
+			flattenRecord(key, obj, flat)
+
+			continue
+		}
+
+		flat[key] = val
+	}
+}
+
+// csvValue will format a decoded JSON value as a CSV field. Null is an empty field, and lists are JSON text.
+func csvValue(val interface{}) (string, error) {
+	switch val := val.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return val, nil
+	case bool:
+		return strconv.FormatBool(val), nil
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64), nil
+	case json.Number:
+		return val.String(), nil
+	default:
+		data, err := json.Marshal(val)
+		if err != nil {
+			return "", fmt.Errorf("%w: %v", proto.ErrFailedToMarshalJSON, err)
+		}
+
+		return string(data), nil
+	}
+}
+
+// encodeCSVRow will encode the fields of a row as a line of CSV.
+func encodeCSVRow(fields []string) ([]byte, error) {
+	var buf bytes.Buffer
+
+	writer := csv.NewWriter(&buf)
+	if err := writer.Write(fields); err != nil {
+		return nil, fmt.Errorf("unable to encode csv: %w", err)
+	}
+
+	writer.Flush()
+
+	return buf.Bytes(), nil
+}
+
+// csvEncoder encodes the records of a table into the columns of its CSV files.
+type csvEncoder struct {
+	table string
+	opts  csvOptions
+
+	columns []string
+	index   map[string]int
+
+	// dropped are the extra fields that have been logged.
+	dropped map[string]bool
+}
+
+func newCSVEncoder(table string, opts csvOptions, columns []string) *csvEncoder {
+	enc := &csvEncoder{table: table, opts: opts, index: make(map[string]int), dropped: make(map[string]bool)}
+	for _, column := range columns {
+		enc.addColumn(column)
+	}
+
+	return enc
+}
+
+// inferCSVEncoder will return an encoder whose columns are the fields of the first "inferRows" records of the sample,
+// in name order.
+func inferCSVEncoder(table string, opts csvOptions, sample []map[string]interface{}) *csvEncoder {
+	if len(sample) > opts.inferRows {
+		sample = sample[:opts.inferRows]
+	}
+
+	seen := make(map[string]bool)
+
+	var columns []string
+
+	for _, record := range sample {
+		flat := make(map[string]interface{})
+		flattenRecord("", record, flat)
+
+		for column := range flat {
+			if !seen[column] {
+				seen[column] = true
+				columns = append(columns, column)
+			}
+		}
+	}
+
+	sort.Strings(columns)
+
+	return newCSVEncoder(table, opts, columns)
+}
+
+func (enc *csvEncoder) addColumn(column string) {
+	enc.index[column] = len(enc.columns)
+	enc.columns = append(enc.columns, column)
+}
+
+func (enc *csvEncoder) header() []byte {
+	// Encoding a row of strings only fails for invalid delimiters.
+	header, _ := encodeCSVRow(enc.columns)
+
+	return header
+}
+
+// encode will coerce a record into the columns of the table. Missing fields are empty, and extra fields are either
+// dropped or appended as new columns.
+func (enc *csvEncoder) encode(record map[string]interface{}) ([]byte, bool, error) {
+	flat := make(map[string]interface{}, len(record))
+	flattenRecord("", record, flat)
+
+	var extra []string
+
+	for column := range flat {
+		if _, ok := enc.index[column]; !ok {
+			extra = append(extra, column)
+		}
+	}
+
+	sort.Strings(extra)
+
+	widened := false
+
+	for _, column := range extra {
+		if enc.opts.extraColumns == ExtraColumnsAppend {
+			enc.addColumn(column)
+
+			widened = true
+
+			continue
+		}
+
+		if !enc.dropped[column] {
+			enc.dropped[column] = true
+
+			msg := fmt.Sprintf("dropping field %q of table %q, which is not a column of its csv file", column,
+				enc.table)
+			logrus.Warn(tools.LogFormatter{Msg: msg}.String())
+		}
+	}
+
+	row := make([]string, len(enc.columns))
+
+	for column, val := range flat {
+		idx, ok := enc.index[column]
+		if !ok {
+			continue
+		}
+
+		field, err := csvValue(val)
+		if err != nil {
+			return nil, false, err
+		}
+
+		row[idx] = field
+	}
+
+	line, err := encodeCSVRow(row)
+
+	return line, widened, err
+}
+
+// readCSVHeader will return the columns in the header of a CSV file.
+func readCSVHeader(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open %s: %w", path, err)
+	}
+
+	defer file.Close()
+
+	columns, err := csv.NewReader(file).Read()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the header of %s: %w", path, err)
+	}
+
+	return columns, nil
+}
+
+// readCSV will call "fn" with every row of a CSV file, keyed by the columns of its header. Empty fields are nil.
+func readCSV(ctx context.Context, path string, fn proto.ReadFunc) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("unable to open %s: %w", path, err)
+	}
+
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+
+	columns, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("unable to read %s: %w", path, err)
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("unable to read %s: %w", path, err)
+		}
+
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("unable to read %s: %w", path, err)
+		}
+
+		record := make(map[string]interface{}, len(columns))
+
+		for idx, column := range columns {
+			if idx < len(row) && row[idx] != "" {
+				record[column] = row[idx]
+			} else {
+				record[column] = nil
+			}
+		}
+
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,190 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package file
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/alpstable/gidari/internal/proto"
+)
+
+func TestCSV(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	dns := "csv://" + t.TempDir()
+
+	sink, err := New(ctx, dns)
+	if err != nil {
+		t.Fatalf("failed to open file storage: %v", err)
+	}
+
+	proto.RunTest(ctx, t, sink, func(runner *proto.TestRunner) {
+		runner.AddCloseDBCases(proto.TestCase{
+			Name: "close csv",
+			OpenFn: func() proto.Storage {
+				stg, _ := New(ctx, dns)
+
+				return stg
+			},
+		})
+
+		runner.AddStorageTypeCases(proto.TestCase{Name: "storage type", StorageType: proto.CSVType})
+		runner.AddListTablesCases(proto.TestCase{Name: "single", Table: "lttests1"})
+
+		runner.AddUpsertTxnCases(
+			proto.TestCase{
+				Name:               "commit",
+				Table:              "tests1",
+				ExpectedUpsertSize: int64(len("id,test_string\n1,test\n")),
+				Data:               map[string]interface{}{"test_string": "test", "id": "1"},
+			},
+			proto.TestCase{
+				Name:     "rollback",
+				Table:    "tests1",
+				Rollback: true,
+				Data:     map[string]interface{}{"test_string": "test", "id": "1"},
+			},
+		)
+
+		runner.AddTruncateTxnCases(proto.TestCase{
+			Name:  "csv",
+			Table: "tests1",
+			Data:  map[string]interface{}{"test_string": "test", "id": "1"},
+		})
+	})
+}
+
+func TestCSVColumns(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	for _, tcase := range []struct {
+		name  string
+		query string
+		files map[string]string
+	}{
+		{
+			name: "inferred from the first records",
+			files: map[string]string{
+				"trades.csv": "id,price,size.amount,tags\n" +
+					"1,1.5,2,\"[\"\"a\"\"]\"\n" +
+					"2,,,\n" +
+					"3,3,,\n",
+			},
+		},
+		{
+			name:  "extra columns appended",
+			query: "?inferRows=1&extraColumns=append",
+			files: map[string]string{
+				"trades.000001.csv": "id,price,size.amount,tags\n1,1.5,2,\"[\"\"a\"\"]\"\n2,,,\n",
+				"trades.csv":        "id,price,size.amount,tags,venue\n3,3,,,x\n",
+			},
+		},
+	} {
+		tcase := tcase
+
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+
+			sink, err := New(ctx, "csv://"+dir+tcase.query)
+			if err != nil {
+				t.Fatalf("failed to open file storage: %v", err)
+			}
+
+			defer sink.Close()
+
+			for _, data := range []string{
+				`[{"id":"1","price":1.5,"size":{"amount":2},"tags":["a"]},{"id":"2"}]`,
+				`{"id":"3","price":3,"venue":"x"}`,
+			} {
+				if _, err := sink.Upsert(ctx, &proto.UpsertRequest{Table: "trades", Data: []byte(data)}); err != nil {
+					t.Fatalf("failed to upsert: %v", err)
+				}
+			}
+
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatalf("failed to read directory: %v", err)
+			}
+
+			if len(entries) != len(tcase.files) {
+				t.Fatalf("expected %d files, got %d", len(tcase.files), len(entries))
+			}
+
+			for name, want := range tcase.files {
+				data, err := os.ReadFile(filepath.Join(dir, name))
+				if err != nil {
+					t.Fatalf("failed to read %s: %v", name, err)
+				}
+
+				if string(data) != want {
+					t.Fatalf("expected %s to contain:\n%s\ngot:\n%s", name, want, data)
+				}
+			}
+		})
+	}
+}
+
+func TestCSVResume(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	dns := "csv://" + t.TempDir()
+
+	upsert := func(data string) {
+		t.Helper()
+
+		sink, err := New(ctx, dns)
+		if err != nil {
+			t.Fatalf("failed to open file storage: %v", err)
+		}
+
+		defer sink.Close()
+
+		if _, err := sink.Upsert(ctx, &proto.UpsertRequest{Table: "trades", Data: []byte(data)}); err != nil {
+			t.Fatalf("failed to upsert: %v", err)
+		}
+	}
+
+	// A later run appends to the columns of the existing file, rather than inferring them again.
+	upsert(`{"id":"1","price":1.5}`)
+	upsert(`{"price":2,"id":"2","size":1}`)
+
+	sink, err := New(ctx, dns)
+	if err != nil {
+		t.Fatalf("failed to open file storage: %v", err)
+	}
+
+	defer sink.Close()
+
+	var records []map[string]interface{}
+
+	err = sink.Read(ctx, &proto.ReadRecordsRequest{Table: "trades"}, func(record map[string]interface{}) error {
+		records = append(records, record)
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to read: %v", err)
+	}
+
+	want := []map[string]interface{}{{"id": "1", "price": "1.5"}, {"id": "2", "price": "2"}}
+	if !reflect.DeepEqual(records, want) {
+		t.Fatalf("expected %v, got %v", want, records)
+	}
+}
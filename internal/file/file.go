@@ -0,0 +1,847 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package file
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/internal/proto"
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+var (
+	ErrTransactionNotFound = fmt.Errorf("transaction not found")
+	ErrInvalidTable        = fmt.Errorf("invalid table name")
+	ErrClosed              = fmt.Errorf("file storage is closed")
+)
+
+// fileTxType is a type alias for the file transaction type.
+type fileTxType uint8
+
+const (
+	basicFileTxID fileTxType = iota
+)
+
+// encoder encodes the records of a table into the lines of its files.
+type encoder interface {
+	// header returns the bytes written at the start of every file, or nil if the format has no header.
+	header() []byte
+
+	// encode returns the line for a record. If "widened" is true, the header has changed to fit the record, so
+	// the line has to be written to a new file.
+	encode(record map[string]interface{}) (line []byte, widened bool, err error)
+}
+
+// ndjsonEncoder encodes every record as a JSON object on its own line.
+type ndjsonEncoder struct{}
+
+func (ndjsonEncoder) header() []byte { return nil }
+
+func (ndjsonEncoder) encode(record map[string]interface{}) ([]byte, bool, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, false, fmt.Errorf("%w: %v", proto.ErrFailedToMarshalJSON, err)
+	}
+
+	return append(data, '\n'), false, nil
+}
+
+// tableWriter appends records to the active file of a table.
+type tableWriter struct {
+	file *os.File
+	buf  *bufio.Writer
+
+	// size is the size of the active file, including data that has not been flushed.
+	size int64
+
+	// seq is the sequence number of the last rotated file.
+	seq int
+
+	// enc encodes the records of the table. It is nil until records are first written to a new table, since the
+	// columns of a CSV file are inferred from them.
+	enc encoder
+}
+
+// File is a storage device that writes the records of every table to files in a directory, as either
+// newline-delimited JSON or CSV. Records are appended, so unlike a database, upserting a record twice writes it twice.
+type File struct {
+	dir string
+
+	// storageType is the format of the files, either "proto.NDJSONType" or "proto.CSVType", and ext is their
+	// extension.
+	storageType uint8
+	ext         string
+
+	// maxSize is the size at which the active file of a table is rotated. Zero disables rotation.
+	maxSize int64
+
+	// csv are the options for CSV files.
+	csv csvOptions
+
+	mu      sync.Mutex
+	writers map[string]*tableWriter
+	closed  bool
+
+	// activeTx are the transactions that are currently active, keyed by the transaction ID that "StartTx" adds to
+	// the context of the functions sent to the transaction.
+	activeTx sync.Map
+}
+
+// parseConnectionString will return the file storage for an "ndjson://" or "csv://" connection string, e.g.
+// "ndjson://data?maxSize=64MiB". The path is relative to the working directory, unless it has a leading slash.
+func parseConnectionString(connectionURL string) (*File, error) {
+	scheme, rest, _ := strings.Cut(connectionURL, "://")
+
+	sink := &File{writers: make(map[string]*tableWriter)}
+
+	switch scheme {
+	case proto.SchemeFromStorageType(proto.NDJSONType):
+		sink.storageType, sink.ext = proto.NDJSONType, ".ndjson"
+	case proto.SchemeFromStorageType(proto.CSVType):
+		sink.storageType, sink.ext = proto.CSVType, ".csv"
+	default:
+		return nil, proto.DNSNotSupportedError(scheme)
+	}
+
+	dir, rawQuery, _ := strings.Cut(rest, "?")
+	if dir == "" {
+		return nil, fmt.Errorf("%w: missing directory", proto.DNSNotSupportedError(scheme))
+	}
+
+	sink.dir = dir
+
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse connection string query: %w", err)
+	}
+
+	maxSize, err := config.ParseByteSize(query.Get("maxSize"))
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse maxSize: %w", err)
+	}
+
+	sink.maxSize = int64(maxSize)
+
+	if sink.storageType == proto.CSVType {
+		if sink.csv, err = parseCSVOptions(query); err != nil {
+			return nil, err
+		}
+	}
+
+	return sink, nil
+}
+
+// New will return a new file storage device for writing records to the directory of the connection string, which
+// is created if it does not exist.
+func New(_ context.Context, connectionURL string) (*File, error) {
+	sink, err := parseConnectionString(connectionURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(sink.dir, 0o755); err != nil {
+		return nil, fmt.Errorf("unable to create directory: %w", err)
+	}
+
+	return sink, nil
+}
+
+// activePath returns the path of the file that records for the table are appended to.
+func (sink *File) activePath(table string) string {
+	return filepath.Join(sink.dir, table+sink.ext)
+}
+
+// rotatedPath returns the path of a file that was rotated out, e.g. "trades.000001.ndjson".
+func (sink *File) rotatedPath(table string, seq int) string {
+	return filepath.Join(sink.dir, fmt.Sprintf("%s.%06d%s", table, seq, sink.ext))
+}
+
+// tableFiles are the files of a table in the directory.
+type tableFiles struct {
+	// rotated are the sequence numbers of the rotated files, in order.
+	rotated []int
+	active  bool
+}
+
+// paths returns the paths of the files of a table, with the rotated files in order followed by the active file.
+func (sink *File) paths(table string, files *tableFiles) []string {
+	if files == nil {
+		return nil
+	}
+
+	paths := make([]string, 0, len(files.rotated)+1)
+	for _, seq := range files.rotated {
+		paths = append(paths, sink.rotatedPath(table, seq))
+	}
+
+	if files.active {
+		paths = append(paths, sink.activePath(table))
+	}
+
+	return paths
+}
+
+// listFiles returns the files of every table in the directory.
+func (sink *File) listFiles() (map[string]*tableFiles, error) {
+	entries, err := os.ReadDir(sink.dir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read directory: %w", err)
+	}
+
+	files := make(map[string]*tableFiles)
+
+	get := func(table string) *tableFiles {
+		if files[table] == nil {
+			files[table] = new(tableFiles)
+		}
+
+		return files[table]
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, sink.ext) || strings.HasPrefix(name, ".") {
+			continue
+		}
+
+		base := strings.TrimSuffix(name, sink.ext)
+
+		if idx := strings.LastIndex(base, "."); idx > 0 {
+			if seq, err := strconv.Atoi(base[idx+1:]); err == nil {
+				get(base[:idx]).rotated = append(get(base[:idx]).rotated, seq)
+
+				continue
+			}
+		}
+
+		get(base).active = true
+	}
+
+	for _, table := range files {
+		sort.Ints(table.rotated)
+	}
+
+	return files, nil
+}
+
+// writer will return the writer for the active file of a table, opening it if necessary.
+func (sink *File) writer(table string) (*tableWriter, error) {
+	if sink.closed {
+		return nil, ErrClosed
+	}
+
+	if writer, ok := sink.writers[table]; ok {
+		return writer, nil
+	}
+
+	if table == "" || strings.ContainsAny(table, `/\`) || strings.HasPrefix(table, ".") {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidTable, table)
+	}
+
+	file, err := os.OpenFile(sink.activePath(table), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open file for table %q: %w", table, err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+
+		return nil, fmt.Errorf("unable to stat file for table %q: %w", table, err)
+	}
+
+	writer := &tableWriter{file: file, buf: bufio.NewWriter(file), size: info.Size()}
+
+	if writer.enc, err = sink.resumeEncoder(table, writer.size); err != nil {
+		file.Close()
+
+		return nil, err
+	}
+
+	// Continue the sequence of files that were rotated by a previous run.
+	files, err := sink.listFiles()
+	if err != nil {
+		file.Close()
+
+		return nil, err
+	}
+
+	if tableFiles := files[table]; tableFiles != nil && len(tableFiles.rotated) > 0 {
+		writer.seq = tableFiles.rotated[len(tableFiles.rotated)-1]
+	}
+
+	sink.writers[table] = writer
+
+	return writer, nil
+}
+
+// rotate will move the active file of a table to the next rotated file, and open a new active file.
+func (sink *File) rotate(table string, writer *tableWriter) error {
+	if err := writer.buf.Flush(); err != nil {
+		return fmt.Errorf("unable to write file for table %q: %w", table, err)
+	}
+
+	writer.file.Close()
+	delete(sink.writers, table)
+
+	writer.seq++
+
+	if err := os.Rename(sink.activePath(table), sink.rotatedPath(table, writer.seq)); err != nil {
+		return fmt.Errorf("unable to rotate file for table %q: %w", table, err)
+	}
+
+	return nil
+}
+
+// resumeEncoder returns the encoder for appending to the existing active file of a table. For CSV files, the
+// columns are read from the header of the file, and the encoder is nil if the file is empty.
+func (sink *File) resumeEncoder(table string, size int64) (encoder, error) {
+	if sink.storageType != proto.CSVType {
+		return ndjsonEncoder{}, nil
+	}
+
+	if size == 0 {
+		return nil, nil
+	}
+
+	columns, err := readCSVHeader(sink.activePath(table))
+	if err != nil {
+		return nil, err
+	}
+
+	return newCSVEncoder(table, sink.csv, columns), nil
+}
+
+// newEncoder returns the encoder for a table that has no records, given the first records written to it.
+func (sink *File) newEncoder(table string, sample []map[string]interface{}) encoder {
+	if sink.storageType != proto.CSVType {
+		return ndjsonEncoder{}
+	}
+
+	return inferCSVEncoder(table, sink.csv, sample)
+}
+
+// writeRecords will append records to the files of a table, rotating the active file once it reaches the maximum
+// size, or once the header of a CSV file has been widened to fit a record.
+func (sink *File) writeRecords(table string, records []map[string]interface{}) error {
+	writer, err := sink.writer(table)
+	if err != nil {
+		return err
+	}
+
+	if writer.enc == nil {
+		writer.enc = sink.newEncoder(table, records)
+	}
+
+	for _, record := range records {
+		line, widened, err := writer.enc.encode(record)
+		if err != nil {
+			return err
+		}
+
+		full := sink.maxSize > 0 && writer.size+int64(len(line)) > sink.maxSize
+		if writer.size > 0 && (widened || full) {
+			enc := writer.enc
+
+			if err := sink.rotate(table, writer); err != nil {
+				return err
+			}
+
+			if writer, err = sink.writer(table); err != nil {
+				return err
+			}
+
+			writer.enc = enc
+		}
+
+		// Every file starts with the header of the format, if it has one.
+		if writer.size == 0 {
+			line = append(writer.enc.header(), line...)
+		}
+
+		if _, err := writer.buf.Write(line); err != nil {
+			return fmt.Errorf("unable to write file for table %q: %w", table, err)
+		}
+
+		writer.size += int64(len(line))
+	}
+
+	if err := writer.buf.Flush(); err != nil {
+		return fmt.Errorf("unable to write file for table %q: %w", table, err)
+	}
+
+	return nil
+}
+
+// fileTx holds the records sent to a transaction in temporary files, which are appended to the files of their
+// tables when the transaction is committed.
+type fileTx struct {
+	id string
+
+	mu     sync.Mutex
+	tables []string
+	temps  map[string]*os.File
+
+	// truncated are the tables truncated in the transaction, which are emptied when it is committed before the
+	// records of the transaction are written.
+	truncated []string
+}
+
+// writeTx will append records to the temporary file of a table in a transaction, which holds them as
+// newline-delimited JSON until the transaction is committed.
+func (sink *File) writeTx(ftx *fileTx, table string, records []map[string]interface{}) error {
+	ftx.mu.Lock()
+	defer ftx.mu.Unlock()
+
+	temp, ok := ftx.temps[table]
+	if !ok {
+		if table == "" || strings.ContainsAny(table, `/\`) || strings.HasPrefix(table, ".") {
+			return fmt.Errorf("%w: %q", ErrInvalidTable, table)
+		}
+
+		var err error
+
+		temp, err = os.CreateTemp(sink.dir, fmt.Sprintf(".%s.%s.*.tmp", table, ftx.id))
+		if err != nil {
+			return fmt.Errorf("unable to create transaction file: %w", err)
+		}
+
+		ftx.tables = append(ftx.tables, table)
+		ftx.temps[table] = temp
+	}
+
+	for _, record := range records {
+		line, _, err := ndjsonEncoder{}.encode(record)
+		if err != nil {
+			return err
+		}
+
+		if _, err := temp.Write(line); err != nil {
+			return fmt.Errorf("unable to write transaction file: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// discard will remove the temporary files of a transaction.
+func (ftx *fileTx) discard() {
+	for _, temp := range ftx.temps {
+		temp.Close()
+		os.Remove(temp.Name())
+	}
+}
+
+func (sink *File) upsert(ctx context.Context, table string, structs []*structpb.Struct) error {
+	records := make([]map[string]interface{}, len(structs))
+	for idx, record := range structs {
+		records[idx] = record.AsMap()
+	}
+
+	if txID, ok := ctx.Value(basicFileTxID).(string); ok {
+		stored, ok := sink.activeTx.Load(txID)
+		if !ok {
+			return ErrTransactionNotFound
+		}
+
+		ftx, ok := stored.(*fileTx)
+		if !ok {
+			return ErrTransactionNotFound
+		}
+
+		return sink.writeTx(ftx, table, records)
+	}
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	return sink.writeRecords(table, records)
+}
+
+// Upsert will append the records on the request to the files of the table.
+func (sink *File) Upsert(ctx context.Context, req *proto.UpsertRequest) (*proto.UpsertResponse, error) {
+	records, err := proto.DecodeUpsertRequest(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode records: %w", err)
+	}
+
+	// Do nothing if there are no records.
+	if len(records) == 0 {
+		return &proto.UpsertResponse{}, nil
+	}
+
+	if err := sink.upsert(ctx, req.GetTable(), records); err != nil {
+		return nil, fmt.Errorf("unable to upsert: %w", err)
+	}
+
+	return &proto.UpsertResponse{UpsertedCount: int64(len(records))}, nil
+}
+
+// UpsertBinary will append "property bag"-like records, with the data encoded as a JSON string.
+func (sink *File) UpsertBinary(ctx context.Context,
+	req *proto.UpsertBinaryRequest,
+) (*proto.UpsertBinaryResponse, error) {
+	records, err := proto.DecodeUpsertBinaryRequest(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode records: %w", err)
+	}
+
+	// Do nothing if there are no records.
+	if len(records) == 0 {
+		return &proto.UpsertBinaryResponse{}, nil
+	}
+
+	if err := sink.upsert(ctx, req.GetTable(), records); err != nil {
+		return nil, fmt.Errorf("unable to upsert: %w", err)
+	}
+
+	return &proto.UpsertBinaryResponse{}, nil
+}
+
+// Close will flush and close the files of every table.
+func (sink *File) Close() {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	for table, writer := range sink.writers {
+		writer.buf.Flush()
+		writer.file.Close()
+
+		delete(sink.writers, table)
+	}
+
+	sink.closed = true
+}
+
+// ListPrimaryKeys will return an empty set, since records written to files do not have primary keys.
+func (sink *File) ListPrimaryKeys(_ context.Context) (*proto.ListPrimaryKeysResponse, error) {
+	return &proto.ListPrimaryKeysResponse{PKSet: make(map[string]*proto.PrimaryKeys)}, nil
+}
+
+// ListTables will list every table with a file in the directory. The size of a table is the size of its files.
+func (sink *File) ListTables(_ context.Context) (*proto.ListTablesResponse, error) {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	files, err := sink.listFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	rsp := &proto.ListTablesResponse{TableSet: make(map[string]*proto.Table)}
+
+	for table, tableFiles := range files {
+		var size int64
+
+		for _, path := range sink.paths(table, tableFiles) {
+			info, err := os.Stat(path)
+			if err != nil {
+				return nil, fmt.Errorf("unable to stat file for table %q: %w", table, err)
+			}
+
+			size += info.Size()
+		}
+
+		rsp.TableSet[table] = &proto.Table{Size: size}
+	}
+
+	return rsp, nil
+}
+
+// truncate will remove the rotated files of the tables and empty their active files. The lock must be held.
+func (sink *File) truncate(tables []string) error {
+	files, err := sink.listFiles()
+	if err != nil {
+		return err
+	}
+
+	for _, table := range tables {
+		if writer, ok := sink.writers[table]; ok {
+			writer.file.Close()
+			delete(sink.writers, table)
+		}
+
+		for _, path := range sink.paths(table, files[table]) {
+			if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("unable to truncate table %q: %w", table, err)
+			}
+		}
+
+		if err := os.WriteFile(sink.activePath(table), nil, 0o644); err != nil {
+			return fmt.Errorf("unable to truncate table %q: %w", table, err)
+		}
+	}
+
+	return nil
+}
+
+// Truncate will remove the rotated files of the tables on the request and empty their active files. The columns of
+// a truncated CSV table are inferred again from the next records written to it. In a transaction, the tables are
+// truncated when it is committed, before its records are written.
+func (sink *File) Truncate(ctx context.Context, req *proto.TruncateRequest) (*proto.TruncateResponse, error) {
+	if txID, ok := ctx.Value(basicFileTxID).(string); ok {
+		stored, ok := sink.activeTx.Load(txID)
+		if !ok {
+			return nil, ErrTransactionNotFound
+		}
+
+		ftx, ok := stored.(*fileTx)
+		if !ok {
+			return nil, ErrTransactionNotFound
+		}
+
+		ftx.mu.Lock()
+		ftx.truncated = append(ftx.truncated, req.GetTables()...)
+		ftx.mu.Unlock()
+
+		return &proto.TruncateResponse{}, nil
+	}
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	if err := sink.truncate(req.GetTables()); err != nil {
+		return nil, err
+	}
+
+	return &proto.TruncateResponse{}, nil
+}
+
+// Read will call "fn" with every record in the files of the table on the request, in the order they were written.
+// If the request has an "OrderBy" column, the records are read into memory and sorted.
+func (sink *File) Read(ctx context.Context, req *proto.ReadRecordsRequest, fn proto.ReadFunc) error {
+	sink.mu.Lock()
+	files, err := sink.listFiles()
+	sink.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	var records []map[string]interface{}
+
+	emit := fn
+	if req.OrderBy != "" {
+		emit = func(record map[string]interface{}) error {
+			records = append(records, record)
+
+			return nil
+		}
+	}
+
+	for _, path := range sink.paths(req.Table, files[req.Table]) {
+		if err := sink.readFile(ctx, path, emit); err != nil {
+			return err
+		}
+	}
+
+	if req.OrderBy == "" {
+		return nil
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		return proto.LessValue(records[i][req.OrderBy], records[j][req.OrderBy])
+	})
+
+	for _, record := range records {
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readFile will call "fn" with every record in a file.
+func (sink *File) readFile(ctx context.Context, path string, fn proto.ReadFunc) error {
+	if sink.storageType == proto.CSVType {
+		return readCSV(ctx, path, fn)
+	}
+
+	return readNDJSON(ctx, path, fn)
+}
+
+func readNDJSON(ctx context.Context, path string, fn proto.ReadFunc) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("unable to open %s: %w", path, err)
+	}
+
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("unable to read %s: %w", path, err)
+		}
+
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var record map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return fmt.Errorf("%w: %v", proto.ErrFailedToUnmarshalJSON, err)
+		}
+
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("unable to read %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// IsNoSQL returns "true" since the files have no schema that records have to match.
+func (sink *File) IsNoSQL() bool { return true }
+
+// Type implements the storage interface.
+func (sink *File) Type() uint8 { return sink.storageType }
+
+// StartTx will start a transaction. Records sent to the transaction are held until it is committed, and discarded
+// if it is rolled back.
+func (sink *File) StartTx(ctx context.Context) (*proto.Txn, error) {
+	txn := &proto.Txn{
+		FunctionCh: make(chan proto.TxnChanFn),
+		DoneCh:     make(chan error, 1),
+		CommitCh:   make(chan bool, 1),
+	}
+
+	txnID := uuid.New().String()
+	ftx := &fileTx{id: txnID, temps: make(map[string]*os.File)}
+
+	sink.activeTx.Store(txnID, ftx)
+
+	// Create a copy of the parent context with a transaction ID.
+	fileCtx := context.WithValue(ctx, basicFileTxID, txnID)
+
+	go func() {
+		defer sink.activeTx.Delete(txnID)
+		defer ftx.discard()
+
+		var err error
+
+		for fn := range txn.FunctionCh {
+			if err != nil {
+				continue
+			}
+
+			err = fn(fileCtx, sink)
+		}
+
+		if err != nil {
+			txn.DoneCh <- err
+
+			return
+		}
+
+		if !<-txn.CommitCh {
+			txn.DoneCh <- nil
+
+			return
+		}
+
+		txn.DoneCh <- sink.commit(ftx)
+	}()
+
+	return txn, nil
+}
+
+// commitBatchSize is the number of records copied from the file of a transaction at a time.
+const commitBatchSize = 1000
+
+// commit will append the records of a transaction to the files of their tables.
+func (sink *File) commit(ftx *fileTx) error {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	// The columns of a CSV file are inferred from the first batch of records written to it.
+	batchSize := commitBatchSize
+	if sink.csv.inferRows > batchSize {
+		batchSize = sink.csv.inferRows
+	}
+
+	if err := sink.truncate(ftx.truncated); err != nil {
+		return err
+	}
+
+	for _, table := range ftx.tables {
+		temp := ftx.temps[table]
+		if _, err := temp.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("unable to read transaction file: %w", err)
+		}
+
+		batch := make([]map[string]interface{}, 0, batchSize)
+
+		decoder := json.NewDecoder(bufio.NewReader(temp))
+		decoder.UseNumber()
+
+		for {
+			var record map[string]interface{}
+
+			err := decoder.Decode(&record)
+			if err != nil && !errors.Is(err, io.EOF) {
+				return fmt.Errorf("%w: %v", proto.ErrFailedToUnmarshalJSON, err)
+			}
+
+			if err == nil {
+				batch = append(batch, record)
+			}
+
+			if len(batch) == batchSize || (err != nil && len(batch) > 0) {
+				if err := sink.writeRecords(table, batch); err != nil {
+					return err
+				}
+
+				batch = batch[:0]
+			}
+
+			if err != nil {
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+// Ping will return an error if the storage is closed or the directory is no longer accessible.
+func (sink *File) Ping() error {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	if sink.closed {
+		return ErrClosed
+	}
+
+	if _, err := os.Stat(sink.dir); err != nil {
+		return fmt.Errorf("connection lost: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,251 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package file
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/alpstable/gidari/internal/proto"
+)
+
+func TestFile(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	dns := "ndjson://" + t.TempDir()
+
+	sink, err := New(ctx, dns)
+	if err != nil {
+		t.Fatalf("failed to open file storage: %v", err)
+	}
+
+	proto.RunTest(ctx, t, sink, func(runner *proto.TestRunner) {
+		runner.AddCloseDBCases(proto.TestCase{
+			Name: "close file",
+			OpenFn: func() proto.Storage {
+				stg, _ := New(ctx, dns)
+
+				return stg
+			},
+		})
+
+		runner.AddStorageTypeCases(proto.TestCase{Name: "storage type", StorageType: proto.NDJSONType})
+		runner.AddIsNoSQLCases(proto.TestCase{Name: "isNoSQL file", ExpectedIsNoSQL: true})
+		runner.AddListTablesCases(proto.TestCase{Name: "single", Table: "lttests1"})
+
+		runner.AddUpsertTxnCases(
+			proto.TestCase{
+				Name:               "commit",
+				Table:              "tests1",
+				ExpectedUpsertSize: int64(len(`{"id":"1","test_string":"test"}` + "\n")),
+				Data:               map[string]interface{}{"test_string": "test", "id": "1"},
+			},
+			proto.TestCase{
+				Name:               "rollback",
+				Table:              "tests1",
+				ExpectedUpsertSize: 0,
+				Rollback:           true,
+				Data:               map[string]interface{}{"test_string": "test", "id": "1"},
+			},
+			proto.TestCase{
+				Name:       "rollback on error",
+				Table:      "tests1",
+				ForceError: true,
+				Data:       map[string]interface{}{"test_string": "test", "id": "1"},
+			},
+		)
+
+		runner.AddTruncateTxnCases(proto.TestCase{
+			Name:  "file",
+			Table: "tests1",
+			Data:  map[string]interface{}{"test_string": "test", "id": "1"},
+		})
+
+		runner.AddPingCases(proto.TestCase{Name: "check file connection"})
+	})
+}
+
+func TestParseConnectionString(t *testing.T) {
+	t.Parallel()
+
+	ndjson := uint8(proto.NDJSONType)
+	defaultCSV := csvOptions{inferRows: defaultInferRows, extraColumns: ExtraColumnsLog}
+
+	for _, tcase := range []struct {
+		name        string
+		dns         string
+		dir         string
+		maxSize     int64
+		storageType uint8
+		csv         csvOptions
+		err         bool
+	}{
+		{name: "relative", dns: "ndjson://data", dir: "data", storageType: ndjson},
+		{name: "absolute", dns: "ndjson:///tmp/data", dir: "/tmp/data", storageType: ndjson},
+		{name: "max size", dns: "ndjson://data?maxSize=64KiB", dir: "data", maxSize: 64 << 10, storageType: ndjson},
+		{name: "no directory", dns: "ndjson://?maxSize=1MB", err: true},
+		{name: "invalid max size", dns: "ndjson://data?maxSize=big", err: true},
+		{name: "unknown scheme", dns: "parquet://data", err: true},
+		{name: "csv", dns: "csv://data", dir: "data", storageType: proto.CSVType, csv: defaultCSV},
+		{
+			name:        "csv options",
+			dns:         "csv://data?inferRows=10&extraColumns=append",
+			dir:         "data",
+			storageType: proto.CSVType,
+			csv:         csvOptions{inferRows: 10, extraColumns: ExtraColumnsAppend},
+		},
+		{name: "invalid infer rows", dns: "csv://data?inferRows=0", err: true},
+		{name: "invalid extra columns", dns: "csv://data?extraColumns=error", err: true},
+	} {
+		tcase := tcase
+
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			sink, err := parseConnectionString(tcase.dns)
+			if (err != nil) != tcase.err {
+				t.Fatalf("expected error %v, got %v", tcase.err, err)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if sink.dir != tcase.dir || sink.maxSize != tcase.maxSize || sink.storageType != tcase.storageType {
+				t.Fatalf("expected %q, %d and type %d, got %q, %d and type %d", tcase.dir, tcase.maxSize,
+					tcase.storageType, sink.dir, sink.maxSize, sink.storageType)
+			}
+
+			if sink.csv != tcase.csv {
+				t.Fatalf("expected csv options %+v, got %+v", tcase.csv, sink.csv)
+			}
+		})
+	}
+}
+
+func TestRotate(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	// Each record is 9 bytes, so every file holds two records.
+	dns := "ndjson://" + dir + "?maxSize=20B"
+
+	upsert := func(sink *File, data string) {
+		t.Helper()
+
+		if _, err := sink.Upsert(ctx, &proto.UpsertRequest{Table: "trades", Data: []byte(data)}); err != nil {
+			t.Fatalf("failed to upsert: %v", err)
+		}
+	}
+
+	sink, err := New(ctx, dns)
+	if err != nil {
+		t.Fatalf("failed to open file storage: %v", err)
+	}
+
+	upsert(sink, `[{"id":1},{"id":2},{"id":3}]`)
+	sink.Close()
+
+	// A new run continues the sequence of rotated files.
+	if sink, err = New(ctx, dns); err != nil {
+		t.Fatalf("failed to reopen file storage: %v", err)
+	}
+
+	defer sink.Close()
+
+	upsert(sink, `[{"id":4},{"id":5}]`)
+
+	for _, want := range []struct {
+		name string
+		data string
+	}{
+		{name: "trades.000001.ndjson", data: "{\"id\":1}\n{\"id\":2}\n"},
+		{name: "trades.000002.ndjson", data: "{\"id\":3}\n{\"id\":4}\n"},
+		{name: "trades.ndjson", data: "{\"id\":5}\n"},
+	} {
+		data, err := os.ReadFile(filepath.Join(dir, want.name))
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", want.name, err)
+		}
+
+		if string(data) != want.data {
+			t.Fatalf("expected %s to contain %q, got %q", want.name, want.data, data)
+		}
+	}
+
+	var ids []interface{}
+
+	err = sink.Read(ctx, &proto.ReadRecordsRequest{Table: "trades"}, func(record map[string]interface{}) error {
+		ids = append(ids, record["id"])
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to read: %v", err)
+	}
+
+	if want := []interface{}{1.0, 2.0, 3.0, 4.0, 5.0}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("expected records %v in the order they were written, got %v", want, ids)
+	}
+}
+
+func TestRead(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	sink, err := New(ctx, "ndjson://"+t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to open file storage: %v", err)
+	}
+
+	defer sink.Close()
+
+	data := []byte(`[{"id":"b","n":10},{"id":"a","n":2},{"id":"c"}]`)
+	if _, err := sink.Upsert(ctx, &proto.UpsertRequest{Table: "tests", Data: data}); err != nil {
+		t.Fatalf("failed to upsert: %v", err)
+	}
+
+	for _, tcase := range []struct {
+		orderBy string
+		want    []interface{}
+	}{
+		{orderBy: "", want: []interface{}{"b", "a", "c"}},
+		{orderBy: "id", want: []interface{}{"a", "b", "c"}},
+		{orderBy: "n", want: []interface{}{"c", "a", "b"}},
+	} {
+		var ids []interface{}
+
+		req := &proto.ReadRecordsRequest{Table: "tests", OrderBy: tcase.orderBy}
+		if err := sink.Read(ctx, req, func(record map[string]interface{}) error {
+			ids = append(ids, record["id"])
+
+			return nil
+		}); err != nil {
+			t.Fatalf("failed to read: %v", err)
+		}
+
+		if !reflect.DeepEqual(ids, tcase.want) {
+			t.Fatalf("expected records ordered by %q to be %v, got %v", tcase.orderBy, tcase.want, ids)
+		}
+	}
+
+	_, err = sink.Upsert(ctx, &proto.UpsertRequest{Table: "../tests", Data: data})
+	if !errors.Is(err, ErrInvalidTable) {
+		t.Fatalf("expected %v for a table outside the directory, got %v", ErrInvalidTable, err)
+	}
+}
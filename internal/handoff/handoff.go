@@ -0,0 +1,145 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package handoff
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Table is the output of a table while a run writes to it: a reader of the records that are written to the table,
+// as newline-delimited JSON.
+type Table struct {
+	// Name is the name of the table.
+	Name string
+
+	reader *io.PipeReader
+}
+
+// Read will read the records of the table as they are written, blocking until there are more. Once the run is done
+// it returns the error of the run, or "io.EOF" if it succeeded.
+func (table *Table) Read(p []byte) (int, error) {
+	return table.reader.Read(p) //nolint:wrapcheck // the error of the run is returned as it is
+}
+
+// Close will stop reading the table. The records that are written to it afterwards are dropped, while the run still
+// writes them to storage.
+func (table *Table) Close() error {
+	return table.reader.Close() //nolint:wrapcheck // closing a pipe reader never fails
+}
+
+// Handoff hands off the records of every table of a run to the code that embeds it, as they are written. A nil
+// "Handoff" hands off nothing, so the transport can write to it unconditionally.
+type Handoff struct {
+	ctx    context.Context
+	tables chan *Table
+
+	mu      sync.Mutex
+	writers map[string]*io.PipeWriter
+	closed  bool
+}
+
+// New will return a handoff whose tables are sent on "Tables" until "ctx" is done.
+func New(ctx context.Context) *Handoff {
+	return &Handoff{
+		ctx:     ctx,
+		tables:  make(chan *Table),
+		writers: make(map[string]*io.PipeWriter),
+	}
+}
+
+// Tables returns the channel that every table is sent on when its first records are written. It is closed once the
+// run is done.
+func (hand *Handoff) Tables() <-chan *Table {
+	return hand.tables
+}
+
+// writer returns the writer of a table, sending the table on "Tables" if it is new. It returns nil if the run is
+// done, or if the context is done before the table is received.
+func (hand *Handoff) writer(table string) *io.PipeWriter {
+	hand.mu.Lock()
+	defer hand.mu.Unlock()
+
+	if hand.closed {
+		return nil
+	}
+
+	if writer, ok := hand.writers[table]; ok {
+		return writer
+	}
+
+	reader, writer := io.Pipe()
+
+	select {
+	case hand.tables <- &Table{Name: table, reader: reader}:
+	case <-hand.ctx.Done():
+		return nil
+	}
+
+	hand.writers[table] = writer
+
+	return writer
+}
+
+// Write will hand off records that are written to a table, blocking until they are read. Records of tables that are
+// no longer read are dropped.
+func (hand *Handoff) Write(table string, records []json.RawMessage) error {
+	if hand == nil || len(records) == 0 {
+		return nil
+	}
+
+	writer := hand.writer(table)
+	if writer == nil {
+		return nil
+	}
+
+	// Every record is written on a line of its own, so records with line breaks are compacted.
+	var buf bytes.Buffer
+
+	for _, record := range records {
+		if err := json.Compact(&buf, record); err != nil {
+			return fmt.Errorf("failed to hand off record of %s: %w", table, err)
+		}
+
+		buf.WriteByte('\n')
+	}
+
+	// The lines are written at once, so that they are not interleaved with those of another repository worker.
+	if _, err := writer.Write(buf.Bytes()); err != nil && !errors.Is(err, io.ErrClosedPipe) {
+		return fmt.Errorf("failed to hand off records of %s: %w", table, err)
+	}
+
+	return nil
+}
+
+// Close will end every table with the error of the run, or "io.EOF" if it is nil, and close "Tables".
+func (hand *Handoff) Close(err error) {
+	if hand == nil {
+		return
+	}
+
+	hand.mu.Lock()
+	defer hand.mu.Unlock()
+
+	if hand.closed {
+		return
+	}
+
+	hand.closed = true
+
+	for _, writer := range hand.writers {
+		writer.CloseWithError(err)
+	}
+
+	close(hand.tables)
+}
@@ -0,0 +1,154 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package handoff
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// readTables will read every table of a handoff in a goroutine of its own, returning a function that waits for the
+// tables to be read and returns their records and errors, keyed by table.
+func readTables(hand *Handoff) func() (map[string]string, map[string]error) {
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		data = make(map[string]string)
+		errs = make(map[string]error)
+	)
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		for table := range hand.Tables() {
+			table := table
+
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+
+				records, err := io.ReadAll(table)
+
+				mu.Lock()
+				data[table.Name], errs[table.Name] = string(records), err
+				mu.Unlock()
+			}()
+		}
+
+		wg.Wait()
+	}()
+
+	return func() (map[string]string, map[string]error) {
+		<-done
+
+		return data, errs
+	}
+}
+
+func TestHandoff(t *testing.T) {
+	t.Parallel()
+
+	// A nil handoff hands off nothing.
+	var none *Handoff
+	if err := none.Write("candles", []json.RawMessage{[]byte(`{"id":1}`)}); err != nil {
+		t.Fatalf("expected a nil handoff to drop records, got %v", err)
+	}
+
+	none.Close(nil)
+
+	runErr := fmt.Errorf("run failed")
+	records := []json.RawMessage{[]byte("{\n  \"id\": 1\n}"), []byte(`{"id":2}`)}
+	lines := "{\"id\":1}\n{\"id\":2}\n"
+
+	for _, tcase := range []struct {
+		name string
+		err  error
+	}{
+		{name: "success"},
+		{name: "failure", err: runErr},
+	} {
+		hand := New(context.Background())
+		wait := readTables(hand)
+
+		for _, table := range []string{"candles", "trades", "candles"} {
+			if err := hand.Write(table, records); err != nil {
+				t.Fatalf("%s: failed to hand off records: %v", tcase.name, err)
+			}
+		}
+
+		hand.Close(tcase.err)
+
+		data, errs := wait()
+		if want := map[string]string{"candles": lines + lines, "trades": lines}; !reflect.DeepEqual(data, want) {
+			t.Fatalf("%s: expected %q, got %q", tcase.name, want, data)
+		}
+
+		for table, err := range errs {
+			if !errors.Is(err, tcase.err) {
+				t.Fatalf("%s: expected %s to end with %v, got %v", tcase.name, table, tcase.err, err)
+			}
+		}
+
+		// Nothing is handed off once the run is done.
+		if err := hand.Write("quotes", records); err != nil {
+			t.Fatalf("%s: expected records to be dropped once closed, got %v", tcase.name, err)
+		}
+	}
+}
+
+func TestHandoffClosedTable(t *testing.T) {
+	t.Parallel()
+
+	hand := New(context.Background())
+	records := []json.RawMessage{[]byte(`{"id":1}`)}
+
+	go func() {
+		for table := range hand.Tables() {
+			table.Close()
+		}
+	}()
+
+	// The records of a table that is no longer read are dropped, rather than blocking the run.
+	for idx := 0; idx < 3; idx++ {
+		if err := hand.Write("candles", records); err != nil {
+			t.Fatalf("expected the records of a closed table to be dropped, got %v", err)
+		}
+	}
+
+	hand.Close(nil)
+}
+
+func TestHandoffCanceled(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// Tables that are never received are not handed off once the context is done.
+	hand := New(ctx)
+	if err := hand.Write("candles", []json.RawMessage{[]byte(`{"id":1}`)}); err != nil {
+		t.Fatalf("expected the records to be dropped, got %v", err)
+	}
+
+	hand.Close(nil)
+
+	if _, ok := <-hand.Tables(); ok {
+		t.Fatalf("expected no tables to be handed off")
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package hooks
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+var (
+	ErrHookRegistered = fmt.Errorf("transform hook already registered")
+	ErrInvalidHook    = fmt.Errorf("invalid transform hook")
+	ErrUnknownHook    = fmt.Errorf("unknown transform hook")
+)
+
+// Hook is a transform hook that is run on every record that is written to a table, once it is decoded and before it
+// is stored. It returns the record that is written in its place, or nil for the record to be dropped. An error fails
+// the transactions that the records are written to, like a failed upsert.
+type Hook func(ctx context.Context, table string, record map[string]interface{}) (map[string]interface{}, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Hook)
+)
+
+// Register will register a transform hook under "name", for the requests and tables that list it in their "hooks".
+// Hooks are usually registered in an "init" function, and a name can only be registered once.
+func Register(name string, hook Hook) error {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if name == "" || hook == nil {
+		return fmt.Errorf("%w: a name and hook are required", ErrInvalidHook)
+	}
+
+	if _, ok := registry[name]; ok {
+		return fmt.Errorf("%w: %q", ErrHookRegistered, name)
+	}
+
+	registry[name] = hook
+
+	return nil
+}
+
+// Unregister will remove the transform hook registered under "name", if any, e.g. for a test to clean up after itself.
+func Unregister(name string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	delete(registry, name)
+}
+
+// Registered returns true if a transform hook is registered under "name".
+func Registered(name string) bool {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	_, ok := registry[name]
+
+	return ok
+}
+
+// Names returns the names of the registered transform hooks, sorted.
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// Lookup returns the transform hooks registered under "names", in order.
+func Lookup(names []string) ([]Hook, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	found := make([]Hook, len(names))
+
+	for idx, name := range names {
+		hook, ok := registry[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownHook, name)
+		}
+
+		found[idx] = hook
+	}
+
+	return found, nil
+}
@@ -0,0 +1,107 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package hooks
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+var (
+	ErrInvalidRunHook    = fmt.Errorf("invalid run hook")
+	ErrRunHookRegistered = fmt.Errorf("run hook already registered")
+	ErrUnknownRunHook    = fmt.Errorf("unknown run hook")
+)
+
+// Variables are the keyed variables of the state store, which are kept across runs, e.g. the cursor of an
+// incremental request. Templates read them with "state" and write them with "setState".
+type Variables interface {
+	// Variable returns the value of the variable "name", and false if it has not been set.
+	Variable(name string) (string, bool)
+
+	// SetVariable will set the variable "name" to "value".
+	SetVariable(name, value string)
+}
+
+// RunHook is a hook that is run once a run has completed successfully, before the state store is saved, e.g. to set
+// the variables that the templates of the next run read. An error fails the run, after its data has been committed.
+type RunHook func(ctx context.Context, vars Variables) error
+
+var runRegistry = make(map[string]RunHook)
+
+// RegisterRun will register a run hook under "name", for the configurations that list it in their "runHooks". Run
+// hooks are usually registered in an "init" function, and a name can only be registered once.
+func RegisterRun(name string, hook RunHook) error {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if name == "" || hook == nil {
+		return fmt.Errorf("%w: a name and hook are required", ErrInvalidRunHook)
+	}
+
+	if _, ok := runRegistry[name]; ok {
+		return fmt.Errorf("%w: %q", ErrRunHookRegistered, name)
+	}
+
+	runRegistry[name] = hook
+
+	return nil
+}
+
+// UnregisterRun will remove the run hook registered under "name", if any, e.g. for a test to clean up after itself.
+func UnregisterRun(name string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	delete(runRegistry, name)
+}
+
+// RegisteredRun returns true if a run hook is registered under "name".
+func RegisteredRun(name string) bool {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	_, ok := runRegistry[name]
+
+	return ok
+}
+
+// RunNames returns the names of the registered run hooks, sorted.
+func RunNames() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(runRegistry))
+	for name := range runRegistry {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// LookupRun returns the run hooks registered under "names", in order.
+func LookupRun(names []string) ([]RunHook, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	found := make([]RunHook, len(names))
+
+	for idx, name := range names {
+		hook, ok := runRegistry[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownRunHook, name)
+		}
+
+		found[idx] = hook
+	}
+
+	return found, nil
+}
@@ -0,0 +1,148 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alpstable/gidari/internal/proto"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/connstring"
+)
+
+// namespaceNotFoundCode is the code of the error of listing the indexes of a collection that does not exist.
+const namespaceNotFoundCode = 26
+
+// ttlIndex returns the expiry of the TTL index of a column, if "indexes" have one, and the name of the index of the
+// column, which is empty if it has none.
+func ttlIndex(indexes []bson.M, column string) (int64, string) {
+	for _, index := range indexes {
+		if !indexesColumn(index["key"], column) {
+			continue
+		}
+
+		name, _ := index["name"].(string)
+
+		switch after := index["expireAfterSeconds"].(type) {
+		case int32:
+			return int64(after), name
+		case int64:
+			return after, name
+		case float64:
+			return int64(after), name
+		default:
+			return -1, name
+		}
+	}
+
+	return -1, ""
+}
+
+// indexesColumn returns true if the key of an index is the column alone.
+func indexesColumn(key interface{}, column string) bool {
+	switch key := key.(type) {
+	case bson.M:
+		_, ok := key[column]
+
+		return ok && len(key) == 1
+	case bson.D:
+		return len(key) == 1 && key[0].Key == column
+	default:
+		return false
+	}
+}
+
+// ConfigureExpiry will create a TTL index on the column of the request, or change the expiry of the index that the
+// column has. The documents of the collection are then deleted by MongoDB once the date in their column is older
+// than the expiry, and the column is written as a date from then on.
+func (m *Mongo) ConfigureExpiry(_ context.Context, req *proto.ExpiryRequest) (*proto.ExpiryResponse, error) {
+	m.expiryMutex.Lock()
+	if m.expiry == nil {
+		m.expiry = make(map[string]string)
+	}
+
+	m.expiry[req.Table] = req.Column
+	m.expiryMutex.Unlock()
+
+	cs, err := connstring.ParseAndValidate(m.dns)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse connection string: %w", err)
+	}
+
+	// Indexes cannot be built in a transaction, so they are built outside of the session of the context.
+	ictx, cancel := context.WithTimeout(context.Background(), m.lifetime) //nolint:contextcheck // see above
+	defer cancel()
+
+	db := m.Client.Database(cs.Database)
+	seconds := int64(req.After / time.Second)
+
+	var indexes []bson.M
+
+	cursor, err := db.Collection(req.Table).Indexes().List(ictx)
+
+	var cmdErr mongo.CommandError
+
+	switch {
+	case errors.As(err, &cmdErr) && cmdErr.Code == namespaceNotFoundCode:
+	case err != nil:
+		return nil, fmt.Errorf("unable to list indexes of %q: %w", req.Table, err)
+	default:
+		if err := cursor.All(ictx, &indexes); err != nil {
+			return nil, fmt.Errorf("unable to list indexes of %q: %w", req.Table, err)
+		}
+	}
+
+	current, name := ttlIndex(indexes, req.Column)
+
+	switch {
+	case current == seconds:
+		return &proto.ExpiryResponse{Supported: true}, nil
+	case name != "":
+		err = db.RunCommand(ictx, bson.D{
+			{Key: "collMod", Value: req.Table},
+			{Key: "index", Value: bson.D{{Key: "name", Value: name}, {Key: "expireAfterSeconds", Value: seconds}}},
+		}).Err()
+	default:
+		_, err = db.Collection(req.Table).Indexes().CreateOne(ictx, mongo.IndexModel{
+			Keys:    bson.D{{Key: req.Column, Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(seconds)),
+		})
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to configure the TTL index of %q on %q: %w", req.Table, req.Column, err)
+	}
+
+	return &proto.ExpiryResponse{Supported: true, Changed: true}, nil
+}
+
+// expiryColumn returns the column that the documents of a collection expire after, if any.
+func (m *Mongo) expiryColumn(table string) string {
+	m.expiryMutex.Lock()
+	defer m.expiryMutex.Unlock()
+
+	return m.expiry[table]
+}
+
+// expiryDate will replace the RFC 3339 time of the expiry column of a record with a date, which is the only type
+// that TTL indexes expire documents by. Other values are left as they are.
+func expiryDate(record map[string]interface{}, column string) {
+	str, ok := record[column].(string)
+	if column == "" || !ok {
+		return
+	}
+
+	if date, err := time.Parse(time.RFC3339Nano, str); err == nil {
+		record[column] = date
+	}
+}
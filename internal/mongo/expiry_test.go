@@ -0,0 +1,64 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package mongo
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestTTLIndex(t *testing.T) {
+	t.Parallel()
+
+	indexes := []bson.M{
+		{"name": "_id_", "key": bson.M{"_id": int32(1)}},
+		{"name": "updated_at_1", "key": bson.D{{Key: "updated_at", Value: int32(1)}}, "expireAfterSeconds": int32(60)},
+		{"name": "user_1_seen_1", "key": bson.M{"user": int32(1), "seen": int32(1)}},
+		{"name": "seen_1", "key": bson.M{"seen": int32(1)}},
+	}
+
+	for _, tcase := range []struct {
+		column string
+		after  int64
+		name   string
+	}{
+		{column: "updated_at", after: 60, name: "updated_at_1"},
+		{column: "seen", after: -1, name: "seen_1"},
+		{column: "user", after: -1},
+	} {
+		after, name := ttlIndex(indexes, tcase.column)
+		if after != tcase.after || name != tcase.name {
+			t.Fatalf("%s: expected %d from %q, got %d from %q", tcase.column, tcase.after, tcase.name, after, name)
+		}
+	}
+}
+
+func TestExpiryDate(t *testing.T) {
+	t.Parallel()
+
+	record := map[string]interface{}{"updated_at": "2022-11-28T10:00:00.5Z", "created_at": "2022-11-28T10:00:00Z"}
+
+	expiryDate(record, "updated_at")
+
+	if date, ok := record["updated_at"].(time.Time); !ok || !date.Equal(time.Date(2022, 11, 28, 10, 0, 0, 5e8, time.UTC)) {
+		t.Fatalf("expected the expiry column to be a date, got %#v", record["updated_at"])
+	}
+
+	if _, ok := record["created_at"].(string); !ok {
+		t.Fatalf("expected the other columns to be left as they are, got %#v", record["created_at"])
+	}
+
+	record = map[string]interface{}{"updated_at": "yesterday"}
+	if expiryDate(record, "updated_at"); record["updated_at"] != "yesterday" {
+		t.Fatalf("expected a value that is not a time to be left as it is, got %#v", record["updated_at"])
+	}
+}
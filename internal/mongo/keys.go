@@ -0,0 +1,177 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package mongo
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+const (
+	// keysOption is the option of the connection string that sets how field names that are illegal in MongoDB are
+	// sanitized. It is not passed on to the driver.
+	keysOption = "keys"
+
+	// KeysReplace replaces the dots and the leading "$" of a field name with "_". It is the default.
+	KeysReplace = "replace"
+
+	// KeysNest splits a field name on its dots into nested documents, e.g. "size.amount" into the "amount" of a
+	// "size" document. The leading "$" of each part is replaced with "_".
+	KeysNest = "nest"
+
+	// KeysReject fails the upsert of a record with an illegal field name.
+	KeysReject = "reject"
+)
+
+var (
+	// ErrInvalidKey is returned when a field name is illegal in MongoDB and cannot be sanitized.
+	ErrInvalidKey = fmt.Errorf("invalid field name")
+
+	// ErrInvalidOptions is returned when the options of the connection string are invalid.
+	ErrInvalidOptions = fmt.Errorf("invalid mongo options")
+)
+
+// parseKeysOption returns the connection string without the "keys" option, and the strategy that it sets.
+func parseKeysOption(uri string) (string, string, error) {
+	base, rawQuery, ok := strings.Cut(uri, "?")
+	if !ok {
+		return uri, KeysReplace, nil
+	}
+
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %v", ErrInvalidOptions, err)
+	}
+
+	if !query.Has(keysOption) {
+		return uri, KeysReplace, nil
+	}
+
+	strategy := query.Get(keysOption)
+	if strategy != KeysReplace && strategy != KeysNest && strategy != KeysReject {
+		return "", "", fmt.Errorf("%w: keys has to be replace, nest or reject, got %q", ErrInvalidOptions, strategy)
+	}
+
+	query.Del(keysOption)
+
+	if len(query) == 0 {
+		return base, strategy, nil
+	}
+
+	return base + "?" + query.Encode(), strategy, nil
+}
+
+// illegalKey returns true if a field name cannot be written to MongoDB: it has a dot or begins with "$".
+func illegalKey(key string) bool {
+	return strings.HasPrefix(key, "$") || strings.Contains(key, ".")
+}
+
+// replaceKey returns a field name with its dots and its leading "$" replaced with "_".
+func replaceKey(key string) string {
+	if strings.HasPrefix(key, "$") {
+		key = "_" + key[1:]
+	}
+
+	return strings.ReplaceAll(key, ".", "_")
+}
+
+// nestValue will set the value of a field name that is split on its dots into the nested documents of "doc".
+func nestValue(doc map[string]interface{}, path []string, val interface{}) error {
+	key := path[0]
+	if len(path) == 1 {
+		if _, ok := doc[key]; ok {
+			return fmt.Errorf("%w: %q is set more than once", ErrInvalidKey, key)
+		}
+
+		doc[key] = val
+
+		return nil
+	}
+
+	if doc[key] == nil {
+		doc[key] = make(map[string]interface{})
+	}
+
+	nested, ok := doc[key].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("%w: %q is not a document", ErrInvalidKey, key)
+	}
+
+	return nestValue(nested, path[1:], val)
+}
+
+// sanitizeValue will sanitize the field names of the documents of a value, at any depth.
+func sanitizeValue(strategy string, val interface{}) (interface{}, error) {
+	switch val := val.(type) {
+	case map[string]interface{}:
+		return sanitizeKeys(strategy, val)
+	case []interface{}:
+		for idx, item := range val {
+			sanitized, err := sanitizeValue(strategy, item)
+			if err != nil {
+				return nil, err
+			}
+
+			val[idx] = sanitized
+		}
+	}
+
+	return val, nil
+}
+
+// sanitizeKeys returns a document with the field names that are illegal in MongoDB sanitized with the strategy, at
+// any depth. A sanitized field name that the document already has is an error, so that no field is overwritten.
+func sanitizeKeys(strategy string, doc map[string]interface{}) (map[string]interface{}, error) {
+	out := make(map[string]interface{}, len(doc))
+
+	var illegal []string
+
+	for key, val := range doc {
+		sanitized, err := sanitizeValue(strategy, val)
+		if err != nil {
+			return nil, err
+		}
+
+		if illegalKey(key) {
+			illegal = append(illegal, key)
+			doc[key] = sanitized
+
+			continue
+		}
+
+		out[key] = sanitized
+	}
+
+	sort.Strings(illegal)
+
+	for _, key := range illegal {
+		switch strategy {
+		case KeysReject:
+			return nil, fmt.Errorf("%w: %q", ErrInvalidKey, key)
+		case KeysNest:
+			path := strings.Split(key, ".")
+			for idx, part := range path {
+				path[idx] = replaceKey(part)
+			}
+
+			if err := nestValue(out, path, doc[key]); err != nil {
+				return nil, err
+			}
+		default:
+			if _, ok := out[replaceKey(key)]; ok {
+				return nil, fmt.Errorf("%w: %q is set more than once as %q", ErrInvalidKey, key, replaceKey(key))
+			}
+
+			out[replaceKey(key)] = doc[key]
+		}
+	}
+
+	return out, nil
+}
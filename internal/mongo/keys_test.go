@@ -0,0 +1,148 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package mongo
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParseKeysOption(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name     string
+		uri      string
+		wantURI  string
+		strategy string
+		err      error
+	}{
+		{
+			name:     "default",
+			uri:      "mongodb://localhost:27017/coinbase?retryWrites=true",
+			wantURI:  "mongodb://localhost:27017/coinbase?retryWrites=true",
+			strategy: KeysReplace,
+		},
+		{
+			name:     "stripped",
+			uri:      "mongodb://localhost:27017/coinbase?keys=nest&retryWrites=true",
+			wantURI:  "mongodb://localhost:27017/coinbase?retryWrites=true",
+			strategy: KeysNest,
+		},
+		{
+			name:     "only option",
+			uri:      "mongodb://localhost:27017/coinbase?keys=reject",
+			wantURI:  "mongodb://localhost:27017/coinbase",
+			strategy: KeysReject,
+		},
+		{
+			name: "invalid",
+			uri:  "mongodb://localhost:27017/coinbase?keys=drop",
+			err:  ErrInvalidOptions,
+		},
+	} {
+		tcase := tcase
+
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			uri, strategy, err := parseKeysOption(tcase.uri)
+			if !errors.Is(err, tcase.err) {
+				t.Fatalf("expected error %v, got %v", tcase.err, err)
+			}
+
+			if uri != tcase.wantURI || strategy != tcase.strategy {
+				t.Fatalf("expected %q with %q, got %q with %q", tcase.wantURI, tcase.strategy, uri, strategy)
+			}
+		})
+	}
+}
+
+func TestSanitizeKeys(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name     string
+		strategy string
+		record   string
+		want     string
+		err      error
+	}{
+		{
+			name:     "legal",
+			strategy: KeysReject,
+			record:   `{"price":"1.5","size":{"amount":2}}`,
+			want:     `{"price":"1.5","size":{"amount":2}}`,
+		},
+		{
+			name:     "replace",
+			strategy: KeysReplace,
+			record:   `{"$type":"trade","size.amount":2,"fills":[{"fee.usd":1}]}`,
+			want:     `{"_type":"trade","size_amount":2,"fills":[{"fee_usd":1}]}`,
+		},
+		{
+			name:     "replace conflict",
+			strategy: KeysReplace,
+			record:   `{"size.amount":2,"size_amount":3}`,
+			err:      ErrInvalidKey,
+		},
+		{
+			name:     "nest",
+			strategy: KeysNest,
+			record:   `{"size.amount":2,"size":{"unit":"btc"},"a.$b.c":true}`,
+			want:     `{"size":{"amount":2,"unit":"btc"},"a":{"_b":{"c":true}}}`,
+		},
+		{
+			name:     "nest conflict",
+			strategy: KeysNest,
+			record:   `{"size.amount":2,"size":"1"}`,
+			err:      ErrInvalidKey,
+		},
+		{
+			name:     "reject",
+			strategy: KeysReject,
+			record:   `{"price":"1.5","data":{"$ref":"x"}}`,
+			err:      ErrInvalidKey,
+		},
+	} {
+		tcase := tcase
+
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			var record map[string]interface{}
+			if err := json.Unmarshal([]byte(tcase.record), &record); err != nil {
+				t.Fatalf("failed to decode record: %v", err)
+			}
+
+			got, err := sanitizeKeys(tcase.strategy, record)
+			if !errors.Is(err, tcase.err) {
+				t.Fatalf("expected error %v, got %v", tcase.err, err)
+			}
+
+			if tcase.err != nil {
+				return
+			}
+
+			var want map[string]interface{}
+			if err := json.Unmarshal([]byte(tcase.want), &want); err != nil {
+				t.Fatalf("failed to decode want: %v", err)
+			}
+
+			gotJSON, _ := json.Marshal(got)
+			wantJSON, _ := json.Marshal(want)
+
+			if string(gotJSON) != string(wantJSON) {
+				t.Fatalf("expected %s, got %s", wantJSON, gotJSON)
+			}
+		})
+	}
+}
@@ -119,6 +119,8 @@ func TestMongo(t *testing.T) {
 			}...,
 		)
 
+		runner.AddTruncateTxnCases(proto.TestCase{Name: "mongo", Table: defaultTestTable, Data: defaultData})
+
 		runner.AddPingCases(
 			[]proto.TestCase{
 				{
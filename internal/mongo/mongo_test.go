@@ -0,0 +1,77 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package mongo
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/alpstable/gidari/internal/proto"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/x/mongo/driver"
+)
+
+func TestBulkWriteError(t *testing.T) {
+	t.Parallel()
+
+	errWrite := fmt.Errorf("write error")
+
+	for _, tcase := range []struct {
+		name     string
+		bwr      *mongo.BulkWriteResult
+		err      error
+		tooLarge bool
+		written  int
+	}{
+		{
+			name:     "document too large",
+			bwr:      &mongo.BulkWriteResult{InsertedCount: 2, MatchedCount: 1, UpsertedCount: 3},
+			err:      driver.ErrDocumentTooLarge,
+			tooLarge: true,
+			written:  6,
+		},
+		{
+			name: "bson object too large",
+			err: mongo.BulkWriteException{WriteErrors: []mongo.BulkWriteError{
+				{WriteError: mongo.WriteError{Code: bsonObjectTooLargeErrorCode}},
+			}},
+			tooLarge: true,
+		},
+		{
+			name: "write conflict",
+			err: mongo.BulkWriteException{WriteErrors: []mongo.BulkWriteError{
+				{WriteError: mongo.WriteError{Code: writeConflictErrorCode}},
+			}},
+		},
+		{name: "other", err: errWrite},
+	} {
+		tcase := tcase
+
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := bulkWriteError(tcase.bwr, tcase.err)
+			if !strings.Contains(fmt.Sprint(err), tcase.err.Error()) {
+				t.Fatalf("expected the error to describe %v, got %v", tcase.err, err)
+			}
+
+			var tooLarge *proto.BatchTooLargeError
+			if errors.As(err, &tooLarge) != tcase.tooLarge {
+				t.Fatalf("expected the batch to be too large to be %t, got %v", tcase.tooLarge, err)
+			}
+
+			if tcase.tooLarge && tooLarge.Written != tcase.written {
+				t.Fatalf("expected %d records to be written, got %d", tcase.written, tooLarge.Written)
+			}
+		})
+	}
+}
@@ -0,0 +1,273 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/alpstable/gidari/tools"
+)
+
+// logLines is the number of log lines that the dashboard keeps.
+const logLines = 8
+
+// RequestStats is the progress of a configured request.
+type RequestStats struct {
+	// Name identifies the request, e.g. "GET /candles candles".
+	Name string
+
+	// Planned is the number of HTTP requests the run will make for the request, and Done is the number that have
+	// finished, including those that failed or were skipped by a stop condition.
+	Planned, Done int
+
+	// InFlight is the number of HTTP requests that have been started but not finished.
+	InFlight int
+
+	// Errors is the number of HTTP requests that failed after every retry.
+	Errors int
+
+	// Rows is the number of records received.
+	Rows int64
+
+	// RateLimitWait is the total time that the HTTP requests waited on the rate limiter.
+	RateLimitWait time.Duration
+
+	// State is set when the request has been paused or canceled while the run continues, e.g. "paused".
+	State string
+}
+
+// SinkStats is the data written to a storage target.
+type SinkStats struct {
+	// Name identifies the sink without exposing its connection string, e.g. "connectionStrings[0] (mongodb)".
+	Name string
+
+	// Upserts is the number of upserts, and Rows the number of records they inserted or matched.
+	Upserts int
+	Rows    int64
+
+	// Bytes is the size of the data that was upserted.
+	Bytes int64
+}
+
+// Snapshot is the state of a run at a point in time.
+type Snapshot struct {
+	Time    time.Time
+	Elapsed time.Duration
+
+	Requests []RequestStats
+	Sinks    []SinkStats
+
+	// Logs are the most recent log lines, oldest first.
+	Logs []string
+
+	// Commands describes the commands that can be typed into the dashboard, if any.
+	Commands string
+}
+
+// Monitor collects the progress of a run for display on a terminal dashboard. A nil "Monitor" records nothing, so
+// the transport can report to it unconditionally.
+type Monitor struct {
+	clock   tools.Clock
+	started time.Time
+
+	mu       sync.Mutex
+	requests []*RequestStats
+	sinks    []*SinkStats
+	logs     []string
+	partial  []byte
+	commands string
+}
+
+// New will return a monitor that measures time with the clock. The default clock is "tools.RealClock".
+func New(clock tools.Clock) *Monitor {
+	clock = tools.ClockOrReal(clock)
+
+	return &Monitor{clock: clock, started: clock.Now()}
+}
+
+// request returns the stats of a request, in the order they were first reported. The lock must be held.
+func (mon *Monitor) request(name string) *RequestStats {
+	for _, stats := range mon.requests {
+		if stats.Name == name {
+			return stats
+		}
+	}
+
+	stats := &RequestStats{Name: name}
+	mon.requests = append(mon.requests, stats)
+
+	return stats
+}
+
+// sink returns the stats of a sink, in the order they were first reported. The lock must be held.
+func (mon *Monitor) sink(name string) *SinkStats {
+	for _, stats := range mon.sinks {
+		if stats.Name == name {
+			return stats
+		}
+	}
+
+	stats := &SinkStats{Name: name}
+	mon.sinks = append(mon.sinks, stats)
+
+	return stats
+}
+
+// update will apply "fn" to the stats of a request.
+func (mon *Monitor) update(name string, fn func(stats *RequestStats)) {
+	if mon == nil {
+		return
+	}
+
+	mon.mu.Lock()
+	defer mon.mu.Unlock()
+
+	fn(mon.request(name))
+}
+
+// Plan will add HTTP requests that the run will make for a request.
+func (mon *Monitor) Plan(name string, requests int) {
+	mon.update(name, func(stats *RequestStats) { stats.Planned += requests })
+}
+
+// Start will record that an HTTP request for a request has been started.
+func (mon *Monitor) Start(name string) {
+	mon.update(name, func(stats *RequestStats) { stats.InFlight++ })
+}
+
+// Finish will record that an HTTP request for a request has received its response.
+func (mon *Monitor) Finish(name string, rows int, rateLimitWait time.Duration) {
+	mon.update(name, func(stats *RequestStats) {
+		stats.InFlight--
+		stats.Done++
+		stats.Rows += int64(rows)
+		stats.RateLimitWait += rateLimitWait
+	})
+}
+
+// Fail will record that an HTTP request for a request has failed.
+func (mon *Monitor) Fail(name string) {
+	mon.update(name, func(stats *RequestStats) {
+		stats.InFlight--
+		stats.Done++
+		stats.Errors++
+	})
+}
+
+// Cancel will record that an HTTP request for a request was abandoned because the run was stopped.
+func (mon *Monitor) Cancel(name string) {
+	mon.update(name, func(stats *RequestStats) { stats.InFlight-- })
+}
+
+// Skip will record that an HTTP request for a request was not made because its stop condition was met.
+func (mon *Monitor) Skip(name string) {
+	mon.update(name, func(stats *RequestStats) { stats.Done++ })
+}
+
+// SetState will record that a request has been paused, resumed or canceled. Running requests have no state.
+func (mon *Monitor) SetState(name, state string) {
+	mon.update(name, func(stats *RequestStats) { stats.State = state })
+}
+
+// SetCommands will describe the commands that can be typed into the dashboard.
+func (mon *Monitor) SetCommands(usage string) {
+	if mon == nil {
+		return
+	}
+
+	mon.mu.Lock()
+	defer mon.mu.Unlock()
+
+	mon.commands = usage
+}
+
+// Upsert will record the data written to a sink.
+func (mon *Monitor) Upsert(name string, rows int64, size int) {
+	if mon == nil {
+		return
+	}
+
+	mon.mu.Lock()
+	defer mon.mu.Unlock()
+
+	stats := mon.sink(name)
+	stats.Upserts++
+	stats.Rows += rows
+	stats.Bytes += int64(size)
+}
+
+// Write will keep the most recent lines written, so that a logger can write to the dashboard instead of over it.
+func (mon *Monitor) Write(data []byte) (int, error) {
+	mon.mu.Lock()
+	defer mon.mu.Unlock()
+
+	mon.partial = append(mon.partial, data...)
+
+	for {
+		idx := bytes.IndexByte(mon.partial, '\n')
+		if idx < 0 {
+			break
+		}
+
+		mon.logs = append(mon.logs, string(mon.partial[:idx]))
+		mon.partial = mon.partial[idx+1:]
+	}
+
+	if len(mon.logs) > logLines {
+		mon.logs = append([]string(nil), mon.logs[len(mon.logs)-logLines:]...)
+	}
+
+	return len(data), nil
+}
+
+// Snapshot returns a copy of the current state of the run.
+func (mon *Monitor) Snapshot() Snapshot {
+	mon.mu.Lock()
+	defer mon.mu.Unlock()
+
+	now := mon.clock.Now()
+	snap := Snapshot{
+		Time:     now,
+		Elapsed:  now.Sub(mon.started),
+		Logs:     append([]string(nil), mon.logs...),
+		Commands: mon.commands,
+	}
+
+	for _, stats := range mon.requests {
+		snap.Requests = append(snap.Requests, *stats)
+	}
+
+	for _, stats := range mon.sinks {
+		snap.Sinks = append(snap.Sinks, *stats)
+	}
+
+	return snap
+}
+
+// Run will redraw the dashboard on the terminal every interval until the context is done, and then draw it once more
+// with the final state of the run.
+func (mon *Monitor) Run(ctx context.Context, term *Terminal, interval time.Duration) {
+	var prev Snapshot
+
+	for {
+		snap := mon.Snapshot()
+		term.Draw(snap, prev)
+		prev = snap
+
+		select {
+		case <-ctx.Done():
+			term.Draw(mon.Snapshot(), prev)
+
+			return
+		case <-mon.clock.After(interval):
+		}
+	}
+}
@@ -0,0 +1,134 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package monitor
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alpstable/gidari/tools"
+)
+
+func TestMonitor(t *testing.T) {
+	t.Parallel()
+
+	var mon *Monitor
+
+	// A nil monitor records nothing.
+	mon.Plan("GET /trades trades", 1)
+	mon.Upsert("connectionStrings[0] (mongodb)", 1, 1)
+
+	clock := tools.NewFakeClock(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC))
+	mon = New(clock)
+
+	mon.Plan("GET /trades trades", 4)
+	mon.Plan("GET /quotes quotes", 1)
+
+	for idx := 0; idx < 4; idx++ {
+		mon.Start("GET /trades trades")
+	}
+
+	mon.Finish("GET /trades trades", 10, 2*time.Second)
+	mon.Finish("GET /trades trades", 20, 0)
+	mon.Fail("GET /trades trades")
+	mon.Skip("GET /quotes quotes")
+	mon.Upsert("connectionStrings[0] (mongodb)", 30, 2048)
+
+	prev := mon.Snapshot()
+
+	clock.Advance(2 * time.Second)
+	mon.Finish("GET /trades trades", 10, time.Second)
+	mon.Upsert("connectionStrings[0] (mongodb)", 10, 1024)
+
+	snap := mon.Snapshot()
+
+	wantRequests := []RequestStats{
+		{Name: "GET /trades trades", Planned: 4, Done: 4, Errors: 1, Rows: 40, RateLimitWait: 3 * time.Second},
+		{Name: "GET /quotes quotes", Planned: 1, Done: 1},
+	}
+	if !reflect.DeepEqual(snap.Requests, wantRequests) {
+		t.Fatalf("expected requests %+v, got %+v", wantRequests, snap.Requests)
+	}
+
+	wantSinks := []SinkStats{{Name: "connectionStrings[0] (mongodb)", Upserts: 2, Rows: 40, Bytes: 3072}}
+	if !reflect.DeepEqual(snap.Sinks, wantSinks) {
+		t.Fatalf("expected sinks %+v, got %+v", wantSinks, snap.Sinks)
+	}
+
+	var buf bytes.Buffer
+
+	Render(&buf, snap, prev)
+
+	for _, want := range []string{
+		"running for 2s",
+		// One request and 10 rows over the 2 seconds since the previous snapshot, with 1s mean limiter wait.
+		"GET /trades trades  4/4   0          1       40    0.5    5.0     1s",
+		"5/5 requests done (100%), 1 errors",
+		"connectionStrings[0] (mongodb)  2        40    3.0KiB  5.0     512B",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("expected the dashboard to contain %q, got:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestMonitorWrite(t *testing.T) {
+	t.Parallel()
+
+	mon := New(nil)
+
+	fmt.Fprint(mon, "first\nsec")
+	fmt.Fprint(mon, "ond\n")
+
+	if want, got := []string{"first", "second"}, mon.Snapshot().Logs; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected logs %q, got %q", want, got)
+	}
+
+	for idx := 0; idx < logLines+2; idx++ {
+		fmt.Fprintf(mon, "line %d\n", idx)
+	}
+
+	logs := mon.Snapshot().Logs
+	if len(logs) != logLines || logs[0] != "line 2" || logs[logLines-1] != fmt.Sprintf("line %d", logLines+1) {
+		t.Fatalf("expected the last %d lines, got %q", logLines, logs)
+	}
+}
+
+func TestMonitorState(t *testing.T) {
+	t.Parallel()
+
+	mon := New(nil)
+
+	mon.Plan("GET /trades trades", 1)
+	mon.Plan("GET /quotes quotes", 1)
+	mon.SetState("GET /quotes quotes", "paused")
+	mon.SetCommands("pause|resume|cancel <request>")
+
+	var buf bytes.Buffer
+
+	Render(&buf, mon.Snapshot(), Snapshot{})
+
+	for _, want := range []string{
+		"GET /quotes quotes (paused)",
+		"type a command and press enter: pause|resume|cancel <request>",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("expected the dashboard to contain %q, got:\n%s", want, buf.String())
+		}
+	}
+
+	if strings.Contains(buf.String(), "GET /trades trades (") {
+		t.Fatalf("expected running requests to have no state, got:\n%s", buf.String())
+	}
+}
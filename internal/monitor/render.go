@@ -0,0 +1,151 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package monitor
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// clearScreen moves the cursor to the top left of the terminal and clears it.
+const clearScreen = "\x1b[H\x1b[2J"
+
+// Terminal draws the dashboard on a terminal.
+type Terminal struct {
+	out io.Writer
+}
+
+// NewTerminal will return a terminal that draws to "out", which should be a TTY that understands ANSI escape codes.
+func NewTerminal(out io.Writer) *Terminal {
+	return &Terminal{out: out}
+}
+
+// Draw will replace the contents of the terminal with the dashboard. Rates are measured since the previous snapshot.
+func (term *Terminal) Draw(snap, prev Snapshot) {
+	var buf bytes.Buffer
+
+	buf.WriteString(clearScreen)
+	Render(&buf, snap, prev)
+
+	// The dashboard is best-effort, there is nothing to do if the terminal has gone away.
+	_, _ = term.out.Write(buf.Bytes())
+}
+
+// rate returns the change per second between two values.
+func rate(cur, prev int64, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+
+	return float64(cur-prev) / elapsed.Seconds()
+}
+
+// formatBytes will format a size with a binary unit, e.g. "1.5MiB".
+func formatBytes(size float64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+
+	idx := 0
+	for size >= 1024 && idx < len(units)-1 {
+		size /= 1024
+		idx++
+	}
+
+	if idx == 0 {
+		return fmt.Sprintf("%.0f%s", size, units[idx])
+	}
+
+	return fmt.Sprintf("%.1f%s", size, units[idx])
+}
+
+// averageWait returns the mean time that the finished HTTP requests of a request waited on the rate limiter.
+func averageWait(stats RequestStats) time.Duration {
+	if fetched := stats.Done - stats.Errors; fetched > 0 {
+		return (stats.RateLimitWait / time.Duration(fetched)).Round(time.Millisecond)
+	}
+
+	return 0
+}
+
+// Render will write the dashboard for a snapshot. Rates are measured since the previous snapshot, or since the start
+// of the run if there is none.
+func Render(out io.Writer, snap, prev Snapshot) {
+	elapsed := snap.Elapsed
+	if !prev.Time.IsZero() {
+		elapsed = snap.Time.Sub(prev.Time)
+	}
+
+	prevRequests := make(map[string]RequestStats, len(prev.Requests))
+	for _, stats := range prev.Requests {
+		prevRequests[stats.Name] = stats
+	}
+
+	prevSinks := make(map[string]SinkStats, len(prev.Sinks))
+	for _, stats := range prev.Sinks {
+		prevSinks[stats.Name] = stats
+	}
+
+	fmt.Fprintf(out, "gidari: running for %s\n\n", snap.Elapsed.Round(time.Second))
+
+	table := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(table, "REQUEST\tDONE\tIN-FLIGHT\tERRORS\tROWS\tREQ/S\tROWS/S\tLIMITER WAIT")
+
+	var total RequestStats
+
+	for _, stats := range snap.Requests {
+		last := prevRequests[stats.Name]
+
+		name := stats.Name
+		if stats.State != "" {
+			name = fmt.Sprintf("%s (%s)", name, stats.State)
+		}
+
+		fmt.Fprintf(table, "%s\t%d/%d\t%d\t%d\t%d\t%.1f\t%.1f\t%s\n", name, stats.Done, stats.Planned,
+			stats.InFlight, stats.Errors, stats.Rows, rate(int64(stats.Done), int64(last.Done), elapsed),
+			rate(stats.Rows, last.Rows, elapsed), averageWait(stats))
+
+		total.Planned += stats.Planned
+		total.Done += stats.Done
+		total.Errors += stats.Errors
+	}
+
+	table.Flush()
+
+	if total.Planned > 0 {
+		fmt.Fprintf(out, "\n%d/%d requests done (%.0f%%), %d errors\n", total.Done, total.Planned,
+			100*float64(total.Done)/float64(total.Planned), total.Errors)
+	}
+
+	if len(snap.Sinks) > 0 {
+		fmt.Fprintln(out)
+
+		fmt.Fprintln(table, "SINK\tUPSERTS\tROWS\tBYTES\tROWS/S\tBYTES/S")
+
+		for _, stats := range snap.Sinks {
+			last := prevSinks[stats.Name]
+
+			fmt.Fprintf(table, "%s\t%d\t%d\t%s\t%.1f\t%s\n", stats.Name, stats.Upserts, stats.Rows,
+				formatBytes(float64(stats.Bytes)), rate(stats.Rows, last.Rows, elapsed),
+				formatBytes(rate(stats.Bytes, last.Bytes, elapsed)))
+		}
+
+		table.Flush()
+	}
+
+	if len(snap.Logs) > 0 {
+		fmt.Fprintf(out, "\n%s\n", strings.Join(snap.Logs, "\n"))
+	}
+
+	if snap.Commands != "" {
+		fmt.Fprintf(out, "\ntype a command and press enter: %s\n", snap.Commands)
+	}
+}
@@ -0,0 +1,16 @@
+SELECT c.TABLE_NAME AS table_name,
+       c.COLUMN_NAME AS column_name,
+       COALESCE(k.ORDINAL_POSITION, 0) AS primary_key
+FROM information_schema.COLUMNS c
+    LEFT JOIN information_schema.KEY_COLUMN_USAGE k
+           ON k.TABLE_SCHEMA = c.TABLE_SCHEMA
+              AND k.TABLE_NAME = c.TABLE_NAME
+              AND k.COLUMN_NAME = c.COLUMN_NAME
+              AND k.CONSTRAINT_NAME = 'PRIMARY'
+    INNER JOIN information_schema.TABLES t
+            ON t.TABLE_SCHEMA = c.TABLE_SCHEMA
+               AND t.TABLE_NAME = c.TABLE_NAME
+WHERE c.TABLE_SCHEMA = DATABASE()
+      AND t.TABLE_TYPE = 'BASE TABLE'
+ORDER BY c.TABLE_NAME,
+         c.ORDINAL_POSITION
@@ -0,0 +1,455 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package object
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alpstable/gidari/tools"
+)
+
+// ErrRequestFailed is returned when the object storage responds to a request with an error.
+var ErrRequestFailed = fmt.Errorf("object storage request failed")
+
+// The formats of the dates that requests are signed with.
+const (
+	amzDateFormat   = "20060102T150405Z"
+	scopeDateFormat = "20060102"
+)
+
+// emptyPayloadHash is the SHA-256 hash of an empty request body.
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// credentials are the access keys that requests are signed with. For Google Cloud Storage, these are HMAC keys.
+type credentials struct {
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+}
+
+// client makes requests to the XML API of S3, which Google Cloud Storage also implements. Requests are signed with
+// AWS Signature Version 4.
+type client struct {
+	http     *http.Client
+	endpoint *url.URL
+	region   string
+	bucket   string
+	creds    credentials
+	clock    tools.Clock
+
+	// pathStyle addresses the bucket in the path of the request, e.g. "https://host/bucket/key", rather than in the
+	// host, e.g. "https://bucket.host/key".
+	pathStyle bool
+}
+
+// objectInfo is an object in a bucket.
+type objectInfo struct {
+	key  string
+	size int64
+}
+
+// s3Error is the body of an error response.
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// uriEncode will encode a string as required by the canonical request of a signature, escaping every byte except
+// the unreserved characters. Slashes are escaped unless "path" is true.
+func uriEncode(str string, path bool) string {
+	var buf strings.Builder
+
+	for idx := 0; idx < len(str); idx++ {
+		char := str[idx]
+
+		switch {
+		case 'A' <= char && char <= 'Z', 'a' <= char && char <= 'z', '0' <= char && char <= '9',
+			char == '-', char == '_', char == '.', char == '~', path && char == '/':
+			buf.WriteByte(char)
+		default:
+			fmt.Fprintf(&buf, "%%%02X", char)
+		}
+	}
+
+	return buf.String()
+}
+
+// canonicalQuery returns the query of a request, sorted by key and encoded for the canonical request.
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+
+	for _, key := range keys {
+		vals := append([]string(nil), query[key]...)
+		sort.Strings(vals)
+
+		for _, val := range vals {
+			pairs = append(pairs, uriEncode(key, false)+"="+uriEncode(val, false))
+		}
+	}
+
+	return strings.Join(pairs, "&")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// sign will sign a request with AWS Signature Version 4. Every header on the request is signed, along with the host.
+func sign(req *http.Request, creds credentials, region, payloadHash string, now time.Time) {
+	now = now.UTC()
+
+	req.Header.Set("X-Amz-Date", now.Format(amzDateFormat))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	if creds.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.sessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, vals := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(vals, ","))
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uriEncode(req.URL.Path, true),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{now.Format(scopeDateFormat), region, "s3", "aws4_request"}, "/")
+
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		now.Format(amzDateFormat),
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+creds.secretAccessKey), now.Format(scopeDateFormat))
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.accessKeyID, scope, signedHeaders, signature))
+}
+
+// objectURL returns the URL of an object in the bucket, or of the bucket itself if the key is empty.
+func (c *client) objectURL(key string, query url.Values) *url.URL {
+	dst := *c.endpoint
+	dst.RawQuery = query.Encode()
+
+	switch {
+	case c.pathStyle && key == "":
+		dst.Path = strings.TrimSuffix(dst.Path, "/") + "/" + c.bucket
+	case c.pathStyle:
+		dst.Path = strings.TrimSuffix(dst.Path, "/") + "/" + c.bucket + "/" + key
+	default:
+		dst.Host = c.bucket + "." + dst.Host
+		dst.Path = strings.TrimSuffix(dst.Path, "/") + "/" + key
+	}
+
+	// Escape the path the same way it was signed, since the default escaping of the "url" package leaves some
+	// reserved characters as they are.
+	dst.RawPath = uriEncode(dst.Path, true)
+
+	return &dst
+}
+
+// do will make a signed request for an object in the bucket, and return the response if it succeeded. The caller
+// must close the body of the response.
+func (c *client) do(ctx context.Context, method, key string, query url.Values, body []byte,
+	header http.Header,
+) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.objectURL(key, query).String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("unable to create request: %w", err)
+	}
+
+	for name, vals := range header {
+		req.Header[name] = vals
+	}
+
+	payloadHash := emptyPayloadHash
+	if len(body) > 0 {
+		payloadHash = sha256Hex(body)
+	}
+
+	sign(req, c.creds, c.region, payloadHash, tools.ClockOrReal(c.clock).Now())
+
+	rsp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrRequestFailed, err)
+	}
+
+	if rsp.StatusCode >= http.StatusOK && rsp.StatusCode < http.StatusMultipleChoices {
+		return rsp, nil
+	}
+
+	defer rsp.Body.Close()
+
+	var s3err s3Error
+
+	data, _ := io.ReadAll(io.LimitReader(rsp.Body, 64*1024))
+	if err := xml.Unmarshal(data, &s3err); err != nil || s3err.Code == "" {
+		return nil, fmt.Errorf("%w: %s %s: %s", ErrRequestFailed, method, key, rsp.Status)
+	}
+
+	return nil, fmt.Errorf("%w: %s %s: %s: %s: %s", ErrRequestFailed, method, key, rsp.Status, s3err.Code,
+		s3err.Message)
+}
+
+// call will make a request and discard the body of the response.
+func (c *client) call(ctx context.Context, method, key string, query url.Values, body []byte,
+	header http.Header,
+) (http.Header, error) {
+	rsp, err := c.do(ctx, method, key, query, body, header)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rsp.Body.Close()
+
+	if _, err := io.Copy(io.Discard, rsp.Body); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrRequestFailed, err)
+	}
+
+	return rsp.Header, nil
+}
+
+// decode will make a request and decode the XML body of the response into "dst".
+func (c *client) decode(ctx context.Context, method, key string, query url.Values, body []byte,
+	dst interface{},
+) error {
+	rsp, err := c.do(ctx, method, key, query, body, nil)
+	if err != nil {
+		return err
+	}
+
+	defer rsp.Body.Close()
+
+	if err := xml.NewDecoder(rsp.Body).Decode(dst); err != nil {
+		return fmt.Errorf("%w: unable to decode response: %v", ErrRequestFailed, err)
+	}
+
+	return nil
+}
+
+// putObject will upload an object in a single request.
+func (c *client) putObject(ctx context.Context, key, contentType string, body []byte) error {
+	_, err := c.call(ctx, http.MethodPut, key, nil, body, http.Header{"Content-Type": {contentType}})
+
+	return err
+}
+
+// completedPart is a part of a multipart upload that has been uploaded.
+type completedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+// multipartUpload uploads an object in parts, which the object storage assembles once every part is uploaded.
+type multipartUpload struct {
+	client *client
+	key    string
+	id     string
+	parts  []completedPart
+}
+
+// createMultipartUpload will start a multipart upload of an object.
+func (c *client) createMultipartUpload(ctx context.Context, key, contentType string) (*multipartUpload, error) {
+	rsp, err := c.do(ctx, http.MethodPost, key, url.Values{"uploads": {""}}, nil,
+		http.Header{"Content-Type": {contentType}})
+	if err != nil {
+		return nil, err
+	}
+
+	defer rsp.Body.Close()
+
+	var result struct {
+		UploadID string `xml:"UploadId"`
+	}
+
+	if err := xml.NewDecoder(rsp.Body).Decode(&result); err != nil || result.UploadID == "" {
+		return nil, fmt.Errorf("%w: unable to start multipart upload of %s", ErrRequestFailed, key)
+	}
+
+	return &multipartUpload{client: c, key: key, id: result.UploadID}, nil
+}
+
+// uploadPart will upload the next part of the object.
+func (upload *multipartUpload) uploadPart(ctx context.Context, body []byte) error {
+	number := len(upload.parts) + 1
+
+	header, err := upload.client.call(ctx, http.MethodPut, upload.key, url.Values{
+		"partNumber": {strconv.Itoa(number)},
+		"uploadId":   {upload.id},
+	}, body, nil)
+	if err != nil {
+		return err
+	}
+
+	upload.parts = append(upload.parts, completedPart{PartNumber: number, ETag: header.Get("ETag")})
+
+	return nil
+}
+
+// complete will assemble the uploaded parts into the object.
+func (upload *multipartUpload) complete(ctx context.Context) error {
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+		Parts   []completedPart `xml:"Part"`
+	}{Parts: upload.parts})
+	if err != nil {
+		return fmt.Errorf("unable to encode multipart upload: %w", err)
+	}
+
+	// The object storage can respond with an error after the status line, in the body of a successful response.
+	var result struct {
+		XMLName xml.Name
+		s3Error
+	}
+
+	if err := upload.client.decode(ctx, http.MethodPost, upload.key, url.Values{"uploadId": {upload.id}}, body,
+		&result); err != nil {
+		return err
+	}
+
+	if result.XMLName.Local == "Error" {
+		return fmt.Errorf("%w: POST %s: %s: %s", ErrRequestFailed, upload.key, result.Code, result.Message)
+	}
+
+	return nil
+}
+
+// abort will discard the uploaded parts. The context of the upload may have been canceled, so the parts are
+// discarded with a new context.
+func (upload *multipartUpload) abort() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	_, err := upload.client.call(ctx, http.MethodDelete, upload.key, url.Values{"uploadId": {upload.id}}, nil, nil)
+
+	return err
+}
+
+// listObjects returns every object in the bucket with a key that starts with the prefix, in key order.
+func (c *client) listObjects(ctx context.Context, prefix string) ([]objectInfo, error) {
+	var (
+		objects []objectInfo
+		token   string
+	)
+
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		var page struct {
+			Contents []struct {
+				Key  string `xml:"Key"`
+				Size int64  `xml:"Size"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+
+		if err := c.decode(ctx, http.MethodGet, "", query, nil, &page); err != nil {
+			return nil, err
+		}
+
+		for _, content := range page.Contents {
+			objects = append(objects, objectInfo{key: content.Key, size: content.Size})
+		}
+
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return objects, nil
+		}
+
+		token = page.NextContinuationToken
+	}
+}
+
+// getObject will copy the content of an object to "dst".
+func (c *client) getObject(ctx context.Context, key string, dst io.Writer) error {
+	rsp, err := c.do(ctx, http.MethodGet, key, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	defer rsp.Body.Close()
+
+	if _, err := io.Copy(dst, rsp.Body); err != nil {
+		return fmt.Errorf("%w: unable to read %s: %v", ErrRequestFailed, key, err)
+	}
+
+	return nil
+}
+
+// deleteObject will delete an object from the bucket.
+func (c *client) deleteObject(ctx context.Context, key string) error {
+	_, err := c.call(ctx, http.MethodDelete, key, nil, nil, nil)
+
+	return err
+}
+
+// headBucket will return an error if the bucket does not exist or the credentials cannot access it.
+func (c *client) headBucket(ctx context.Context) error {
+	_, err := c.call(ctx, http.MethodHead, "", nil, nil, nil)
+
+	return err
+}
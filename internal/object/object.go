@@ -0,0 +1,1044 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package object
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/internal/parquet"
+	"github.com/alpstable/gidari/internal/proto"
+	"github.com/alpstable/gidari/internal/workspace"
+	"github.com/alpstable/gidari/tools"
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+var (
+	ErrTransactionNotFound = fmt.Errorf("transaction not found")
+	ErrInvalidTable        = fmt.Errorf("invalid table name")
+	ErrInvalidOptions      = fmt.Errorf("invalid object storage options")
+	ErrMissingCredentials  = fmt.Errorf("missing object storage credentials")
+	ErrClosed              = fmt.Errorf("object storage is closed")
+)
+
+// objectTxType is a type alias for the object storage transaction type.
+type objectTxType uint8
+
+const (
+	basicObjectTxID objectTxType = iota
+)
+
+// The formats that the records of a table are uploaded in.
+const (
+	formatNDJSON  = "ndjson"
+	formatParquet = "parquet"
+)
+
+const (
+	// defaultPartSize is the size of the parts of a multipart upload. Objects up to this size are uploaded in a
+	// single request.
+	defaultPartSize = 8 << 20
+
+	// minPartSize is the smallest part that S3 accepts for every part but the last.
+	minPartSize = 5 << 20
+
+	defaultRowGroupSize = 10000
+
+	// pingTimeout is how long "Ping" waits for the bucket to respond.
+	pingTimeout = 10 * time.Second
+
+	// tempDir is the directory of the workspace that the temporary files of the storage are created in.
+	tempDir = "object"
+)
+
+// The placeholders of a key template.
+const (
+	placeholderTable = "table"
+	placeholderDate  = "date"
+	placeholderChunk = "chunk"
+)
+
+// chunkPattern matches the chunks of a key, which are the run of the storage followed by a sequence number, so
+// that the table can be told apart from the chunk when a template has no separator between them.
+const chunkPattern = `\d{8}T\d{6}Z-[0-9a-f]{8}-\d{6}`
+
+// placeholderPattern matches the placeholders of a key template, e.g. "{table}".
+var placeholderPattern = regexp.MustCompile(`\{([a-z]+)\}`)
+
+// keyTemplate is the template of the keys that objects are uploaded to, e.g. "{table}/{date}/{chunk}.json.gz".
+type keyTemplate struct {
+	template string
+
+	// pattern matches the keys of the template, with the table in the first group.
+	pattern *regexp.Regexp
+}
+
+// parseKeyTemplate will parse the template of the keys that objects are uploaded to, below the prefix of the
+// connection string. The template has to include the table, so that the objects of a table can be found again, and
+// the chunk, so that every upload has its own key.
+func parseKeyTemplate(prefix, template string) (*keyTemplate, error) {
+	var (
+		pattern strings.Builder
+		last    int
+		found   = make(map[string]bool)
+	)
+
+	template = prefix + template
+
+	pattern.WriteString("^")
+
+	for _, loc := range placeholderPattern.FindAllStringSubmatchIndex(template, -1) {
+		pattern.WriteString(regexp.QuoteMeta(template[last:loc[0]]))
+
+		name := template[loc[2]:loc[3]]
+
+		switch name {
+		case placeholderTable:
+			if found[name] {
+				return nil, fmt.Errorf("%w: key %q has more than one {table}", ErrInvalidOptions, template)
+			}
+
+			pattern.WriteString(`([^/]+)`)
+		case placeholderDate:
+			pattern.WriteString(`\d{4}-\d{2}-\d{2}`)
+		case placeholderChunk:
+			pattern.WriteString(chunkPattern)
+		default:
+			return nil, fmt.Errorf("%w: unknown placeholder {%s} in key %q", ErrInvalidOptions, name, template)
+		}
+
+		found[name] = true
+		last = loc[1]
+	}
+
+	pattern.WriteString(regexp.QuoteMeta(template[last:]) + "$")
+
+	if !found[placeholderTable] || !found[placeholderChunk] {
+		return nil, fmt.Errorf("%w: key %q has to include {table} and {chunk}", ErrInvalidOptions, template)
+	}
+
+	return &keyTemplate{template: template, pattern: regexp.MustCompile(pattern.String())}, nil
+}
+
+// key returns the key of an object.
+func (tmpl *keyTemplate) key(table, date, chunk string) string {
+	return strings.NewReplacer(
+		"{"+placeholderTable+"}", table,
+		"{"+placeholderDate+"}", date,
+		"{"+placeholderChunk+"}", chunk,
+	).Replace(tmpl.template)
+}
+
+// table returns the table of an object, and false if the key does not match the template.
+func (tmpl *keyTemplate) table(key string) (string, bool) {
+	match := tmpl.pattern.FindStringSubmatch(key)
+	if match == nil {
+		return "", false
+	}
+
+	return match[1], true
+}
+
+// listPrefix returns the prefix of the keys of a table, or of every table if the table is empty, which is the
+// template up to its first placeholder once the table is filled in.
+func (tmpl *keyTemplate) listPrefix(table string) string {
+	template := tmpl.template
+	if table != "" {
+		template = strings.ReplaceAll(template, "{"+placeholderTable+"}", table)
+	}
+
+	prefix, _, _ := strings.Cut(template, "{")
+
+	return prefix
+}
+
+// Object is a storage device that uploads the records of every table to a bucket of S3, or of an S3 compatible
+// object storage such as Google Cloud Storage. Every upload is a new object, so records are appended rather than
+// upserted.
+type Object struct {
+	client      *client
+	storageType uint8
+
+	// format is the format of the objects, either "ndjson" or "parquet", and gzip is whether they are compressed.
+	format string
+	gzip   bool
+
+	key          *keyTemplate
+	partSize     int
+	rowGroupSize int
+
+	// run prefixes the chunks uploaded by the storage, so that they sort after the chunks of earlier runs and do
+	// not overwrite them.
+	run   string
+	clock tools.Clock
+
+	mu     sync.Mutex
+	seq    int
+	closed bool
+
+	// tables are the tables that the storage has uploaded objects to or truncated, which are listed even if they
+	// have no objects, the same way a truncated table of a database is.
+	tables map[string]bool
+
+	// activeTx are the transactions that are currently active, keyed by the transaction ID that "StartTx" adds to
+	// the context of the functions sent to the transaction.
+	activeTx sync.Map
+
+	// ws is the workspace of the run that the storage is constructed for, which holds its temporary files.
+	ws *workspace.Workspace
+}
+
+// defaultKey returns the key template for a format.
+func defaultKey(format string, gzip bool) string {
+	key := "{table}/{date}/{chunk}.json"
+	if format == formatParquet {
+		key = "{table}/{date}/{chunk}.parquet"
+	}
+
+	if gzip {
+		key += ".gz"
+	}
+
+	return key
+}
+
+// parseCredentials returns the credentials of a connection string, which are read from the environment if the
+// connection string does not have them.
+func parseCredentials(scheme string, user *url.Userinfo, getenv func(string) string) (credentials, error) {
+	var creds credentials
+
+	switch {
+	case user != nil:
+		creds.accessKeyID = user.Username()
+		creds.secretAccessKey, _ = user.Password()
+	case scheme == proto.SchemeFromStorageType(proto.GCSType):
+		creds.accessKeyID = getenv("GCS_ACCESS_KEY_ID")
+		creds.secretAccessKey = getenv("GCS_SECRET_ACCESS_KEY")
+	default:
+		creds.accessKeyID = getenv("AWS_ACCESS_KEY_ID")
+		creds.secretAccessKey = getenv("AWS_SECRET_ACCESS_KEY")
+		creds.sessionToken = getenv("AWS_SESSION_TOKEN")
+	}
+
+	if creds.accessKeyID == "" || creds.secretAccessKey == "" {
+		return creds, ErrMissingCredentials
+	}
+
+	return creds, nil
+}
+
+// parseConnectionString will return the object storage for an "s3://" or "gs://" connection string, e.g.
+// "s3://bucket/prefix?region=eu-west-1&format=parquet". Credentials are read from the environment, unless the
+// connection string has them, e.g. "s3://key:secret@bucket".
+func parseConnectionString(connectionURL string, getenv func(string) string) (*Object, error) {
+	dns, err := url.Parse(connectionURL)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse connection string: %w", err)
+	}
+
+	sink := &Object{client: &client{http: http.DefaultClient, bucket: dns.Host}, tables: make(map[string]bool)}
+
+	query := dns.Query()
+	endpoint := query.Get("endpoint")
+
+	switch dns.Scheme {
+	case proto.SchemeFromStorageType(proto.S3Type):
+		sink.storageType = proto.S3Type
+
+		sink.client.region = query.Get("region")
+		if sink.client.region == "" {
+			sink.client.region = getenv("AWS_REGION")
+		}
+
+		if sink.client.region == "" {
+			sink.client.region = "us-east-1"
+		}
+
+		// Buckets of other object storages are addressed in the path by default, since they do not all support
+		// addressing the bucket in the host.
+		sink.client.pathStyle = endpoint != ""
+
+		if endpoint == "" {
+			endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", sink.client.region)
+		}
+	case proto.SchemeFromStorageType(proto.GCSType):
+		sink.storageType = proto.GCSType
+		sink.client.region = "auto"
+		sink.client.pathStyle = true
+
+		if endpoint == "" {
+			endpoint = "https://storage.googleapis.com"
+		}
+	default:
+		return nil, proto.DNSNotSupportedError(dns.Scheme)
+	}
+
+	if sink.client.bucket == "" {
+		return nil, fmt.Errorf("%w: missing bucket", proto.DNSNotSupportedError(dns.Scheme))
+	}
+
+	if sink.client.endpoint, err = url.Parse(endpoint); err != nil || sink.client.endpoint.Host == "" {
+		return nil, fmt.Errorf("%w: invalid endpoint %q", ErrInvalidOptions, endpoint)
+	}
+
+	if raw := query.Get("pathStyle"); raw != "" {
+		if sink.client.pathStyle, err = strconv.ParseBool(raw); err != nil {
+			return nil, fmt.Errorf("%w: invalid pathStyle %q", ErrInvalidOptions, raw)
+		}
+	}
+
+	if sink.client.creds, err = parseCredentials(dns.Scheme, dns.User, getenv); err != nil {
+		return nil, err
+	}
+
+	if sink.format = query.Get("format"); sink.format == "" {
+		sink.format = formatNDJSON
+	}
+
+	if sink.format != formatNDJSON && sink.format != formatParquet {
+		return nil, fmt.Errorf("%w: unknown format %q", ErrInvalidOptions, sink.format)
+	}
+
+	// Parquet files are not compressed by default, since query engines cannot read gzipped Parquet files.
+	sink.gzip = sink.format == formatNDJSON
+	if raw := query.Get("gzip"); raw != "" {
+		if sink.gzip, err = strconv.ParseBool(raw); err != nil {
+			return nil, fmt.Errorf("%w: invalid gzip %q", ErrInvalidOptions, raw)
+		}
+	}
+
+	sink.partSize = defaultPartSize
+	if raw := query.Get("partSize"); raw != "" {
+		size, err := config.ParseByteSize(raw)
+		if err != nil || size < minPartSize {
+			return nil, fmt.Errorf("%w: partSize has to be at least 5MiB, got %q", ErrInvalidOptions, raw)
+		}
+
+		sink.partSize = int(size)
+	}
+
+	sink.rowGroupSize = defaultRowGroupSize
+	if raw := query.Get("rowGroupSize"); raw != "" {
+		if sink.rowGroupSize, err = strconv.Atoi(raw); err != nil || sink.rowGroupSize <= 0 {
+			return nil, fmt.Errorf("%w: invalid rowGroupSize %q", ErrInvalidOptions, raw)
+		}
+	}
+
+	prefix := strings.Trim(dns.Path, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	template := query.Get("key")
+	if template == "" {
+		template = defaultKey(sink.format, sink.gzip)
+	}
+
+	if sink.key, err = parseKeyTemplate(prefix, template); err != nil {
+		return nil, err
+	}
+
+	return sink, nil
+}
+
+// New will return a new object storage device for uploading records to the bucket of the connection string.
+func New(ctx context.Context, connectionURL string) (*Object, error) {
+	sink, err := parseConnectionString(connectionURL, os.Getenv)
+	if err != nil {
+		return nil, err
+	}
+
+	sink.ws = workspace.FromContext(ctx)
+
+	sink.start(tools.RealClock)
+
+	return sink, nil
+}
+
+// start will set the clock of the storage, and the run that prefixes its chunks.
+func (sink *Object) start(clock tools.Clock) {
+	sink.clock = clock
+	sink.client.clock = clock
+	sink.run = clock.Now().UTC().Format(amzDateFormat) + "-" + uuid.New().String()[:8]
+}
+
+func validTable(table string) error {
+	if table == "" || strings.ContainsAny(table, `/\{}`) || strings.HasPrefix(table, ".") {
+		return fmt.Errorf("%w: %q", ErrInvalidTable, table)
+	}
+
+	return nil
+}
+
+// contentType returns the content type of the objects.
+func (sink *Object) contentType() string {
+	switch {
+	case sink.gzip:
+		return "application/gzip"
+	case sink.format == formatParquet:
+		return "application/octet-stream"
+	default:
+		return "application/x-ndjson"
+	}
+}
+
+// nextKey returns the key of the next object uploaded for a table.
+func (sink *Object) nextKey(table string) string {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	sink.seq++
+	sink.tables[table] = true
+
+	now := sink.clock.Now().UTC()
+
+	return sink.key.key(table, now.Format("2006-01-02"), fmt.Sprintf("%s-%06d", sink.run, sink.seq))
+}
+
+// appendRecords will append records to a newline-delimited JSON file.
+func appendRecords(file *os.File, records []map[string]interface{}) error {
+	buf := bufio.NewWriter(file)
+
+	for _, record := range records {
+		data, err := json.Marshal(record)
+		if err != nil {
+			return fmt.Errorf("%w: %v", proto.ErrFailedToMarshalJSON, err)
+		}
+
+		if _, err := buf.Write(append(data, '\n')); err != nil {
+			return fmt.Errorf("unable to write records: %w", err)
+		}
+	}
+
+	if err := buf.Flush(); err != nil {
+		return fmt.Errorf("unable to write records: %w", err)
+	}
+
+	return nil
+}
+
+// ndjsonSource returns the records of a newline-delimited JSON file. With "useNumber", numbers are decoded as
+// "json.Number" to keep their precision.
+func ndjsonSource(path string, useNumber bool) parquet.RecordSource {
+	return func(fn func(record map[string]interface{}) error) error {
+		file, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("unable to open %s: %w", path, err)
+		}
+
+		defer file.Close()
+
+		decoder := json.NewDecoder(bufio.NewReader(file))
+		if useNumber {
+			decoder.UseNumber()
+		}
+
+		for {
+			var record map[string]interface{}
+			if err := decoder.Decode(&record); errors.Is(err, io.EOF) {
+				return nil
+			} else if err != nil {
+				return fmt.Errorf("%w: %v", proto.ErrFailedToUnmarshalJSON, err)
+			}
+
+			if err := fn(record); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// compress will write a gzipped copy of a file to a new temporary file, and return its path.
+func (sink *Object) compress(path string) (string, error) {
+	return sink.transcode(path, func(dst io.Writer, src io.Reader) error {
+		zw := gzip.NewWriter(dst)
+		if _, err := io.Copy(zw, src); err != nil {
+			return err
+		}
+
+		return zw.Close()
+	})
+}
+
+// decompress will write a gunzipped copy of a file to a new temporary file, and return its path.
+func (sink *Object) decompress(path string) (string, error) {
+	return sink.transcode(path, func(dst io.Writer, src io.Reader) error {
+		zr, err := gzip.NewReader(src)
+		if err != nil {
+			return err
+		}
+
+		if _, err := io.Copy(dst, zr); err != nil {
+			return err
+		}
+
+		return zr.Close()
+	})
+}
+
+// transcode will write a copy of a file to a new temporary file through "fn", and return its path.
+func (sink *Object) transcode(path string, fn func(dst io.Writer, src io.Reader) error) (string, error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("unable to open %s: %w", path, err)
+	}
+
+	defer src.Close()
+
+	dst, err := sink.ws.CreateTemp(tempDir, "gidari-object-*")
+	if err != nil {
+		return "", fmt.Errorf("unable to create temporary file: %w", err)
+	}
+
+	defer dst.Close()
+
+	if err := fn(dst, bufio.NewReader(src)); err != nil {
+		os.Remove(dst.Name())
+
+		return "", fmt.Errorf("unable to transcode %s: %w", path, err)
+	}
+
+	return dst.Name(), nil
+}
+
+// encode will write the records of a newline-delimited JSON file in the format of the objects, and return the path
+// of the file that is uploaded, which is a new temporary file unless the records are uploaded as they are. The path
+// is empty if there is nothing to upload.
+func (sink *Object) encode(path string) (string, error) {
+	encoded := path
+
+	if sink.format == formatParquet {
+		temp, err := sink.ws.CreateTemp(tempDir, "gidari-object-*.parquet")
+		if err != nil {
+			return "", fmt.Errorf("unable to create temporary file: %w", err)
+		}
+
+		temp.Close()
+
+		written, err := parquet.WriteFile(temp.Name(), sink.rowGroupSize, ndjsonSource(path, true))
+		if err != nil || !written {
+			os.Remove(temp.Name())
+
+			return "", err
+		}
+
+		encoded = temp.Name()
+	}
+
+	if !sink.gzip {
+		return encoded, nil
+	}
+
+	compressed, err := sink.compress(encoded)
+	if encoded != path {
+		os.Remove(encoded)
+	}
+
+	return compressed, err
+}
+
+// uploadFile will upload a file to a key. Files larger than the part size are uploaded in parts, and the parts are
+// discarded if any of them fails.
+func (sink *Object) uploadFile(ctx context.Context, key, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("unable to open %s: %w", path, err)
+	}
+
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("unable to stat %s: %w", path, err)
+	}
+
+	if info.Size() <= int64(sink.partSize) {
+		body, err := io.ReadAll(file)
+		if err != nil {
+			return fmt.Errorf("unable to read %s: %w", path, err)
+		}
+
+		return sink.client.putObject(ctx, key, sink.contentType(), body)
+	}
+
+	upload, err := sink.client.createMultipartUpload(ctx, key, sink.contentType())
+	if err != nil {
+		return err
+	}
+
+	part := make([]byte, sink.partSize)
+
+	for {
+		size, err := io.ReadFull(file, part)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+			upload.abort()
+
+			return fmt.Errorf("unable to read %s: %w", path, err)
+		}
+
+		if err := upload.uploadPart(ctx, part[:size]); err != nil {
+			upload.abort()
+
+			return err
+		}
+	}
+
+	if err := upload.complete(ctx); err != nil {
+		upload.abort()
+
+		return err
+	}
+
+	return nil
+}
+
+// upload will upload the records of a newline-delimited JSON file to a new object of the table.
+func (sink *Object) upload(ctx context.Context, table, path string) error {
+	encoded, err := sink.encode(path)
+	if err != nil || encoded == "" {
+		return err
+	}
+
+	if encoded != path {
+		defer os.Remove(encoded)
+	}
+
+	key := sink.nextKey(table)
+	if err := sink.uploadFile(ctx, key, encoded); err != nil {
+		return fmt.Errorf("unable to upload records for table %q: %w", table, err)
+	}
+
+	return nil
+}
+
+// objectTx holds the records sent to a transaction in temporary files, which are uploaded when the transaction is
+// committed.
+type objectTx struct {
+	id string
+	ws *workspace.Workspace
+
+	mu     sync.Mutex
+	tables []string
+	temps  map[string]*os.File
+
+	// truncated are the tables truncated in the transaction, whose objects are deleted when it is committed before
+	// the records of the transaction are uploaded.
+	truncated []string
+}
+
+func (otx *objectTx) write(table string, records []map[string]interface{}) error {
+	otx.mu.Lock()
+	defer otx.mu.Unlock()
+
+	temp, ok := otx.temps[table]
+	if !ok {
+		var err error
+
+		temp, err = otx.ws.CreateTemp(tempDir, fmt.Sprintf("gidari-%s-%s-*.ndjson", table, otx.id))
+		if err != nil {
+			return fmt.Errorf("unable to create transaction file: %w", err)
+		}
+
+		otx.tables = append(otx.tables, table)
+		otx.temps[table] = temp
+	}
+
+	return appendRecords(temp, records)
+}
+
+// discard will remove the temporary files of a transaction.
+func (otx *objectTx) discard() {
+	for _, temp := range otx.temps {
+		temp.Close()
+		os.Remove(temp.Name())
+	}
+}
+
+func (sink *Object) upsert(ctx context.Context, table string, structs []*structpb.Struct) error {
+	if err := validTable(table); err != nil {
+		return err
+	}
+
+	sink.mu.Lock()
+	closed := sink.closed
+	sink.mu.Unlock()
+
+	if closed {
+		return ErrClosed
+	}
+
+	records := make([]map[string]interface{}, len(structs))
+	for idx, record := range structs {
+		records[idx] = record.AsMap()
+	}
+
+	if txID, ok := ctx.Value(basicObjectTxID).(string); ok {
+		stored, ok := sink.activeTx.Load(txID)
+		if !ok {
+			return ErrTransactionNotFound
+		}
+
+		otx, ok := stored.(*objectTx)
+		if !ok {
+			return ErrTransactionNotFound
+		}
+
+		return otx.write(table, records)
+	}
+
+	temp, err := sink.ws.CreateTemp(tempDir, "gidari-object-*.ndjson")
+	if err != nil {
+		return fmt.Errorf("unable to create temporary file: %w", err)
+	}
+
+	defer os.Remove(temp.Name())
+	defer temp.Close()
+
+	if err := appendRecords(temp, records); err != nil {
+		return err
+	}
+
+	return sink.upload(ctx, table, temp.Name())
+}
+
+// Upsert will upload the records on the request to a new object of the table.
+func (sink *Object) Upsert(ctx context.Context, req *proto.UpsertRequest) (*proto.UpsertResponse, error) {
+	records, err := proto.DecodeUpsertRequest(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode records: %w", err)
+	}
+
+	// Do nothing if there are no records.
+	if len(records) == 0 {
+		return &proto.UpsertResponse{}, nil
+	}
+
+	if err := sink.upsert(ctx, req.GetTable(), records); err != nil {
+		return nil, fmt.Errorf("unable to upsert: %w", err)
+	}
+
+	return &proto.UpsertResponse{UpsertedCount: int64(len(records))}, nil
+}
+
+// UpsertBinary will upload "property bag"-like records, with the data encoded as a JSON string.
+func (sink *Object) UpsertBinary(ctx context.Context,
+	req *proto.UpsertBinaryRequest,
+) (*proto.UpsertBinaryResponse, error) {
+	records, err := proto.DecodeUpsertBinaryRequest(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode records: %w", err)
+	}
+
+	// Do nothing if there are no records.
+	if len(records) == 0 {
+		return &proto.UpsertBinaryResponse{}, nil
+	}
+
+	if err := sink.upsert(ctx, req.GetTable(), records); err != nil {
+		return nil, fmt.Errorf("unable to upsert: %w", err)
+	}
+
+	return &proto.UpsertBinaryResponse{}, nil
+}
+
+// Close will stop the storage from uploading records.
+func (sink *Object) Close() {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	sink.closed = true
+}
+
+// ListPrimaryKeys will return an empty set, since records written to objects do not have primary keys.
+func (sink *Object) ListPrimaryKeys(_ context.Context) (*proto.ListPrimaryKeysResponse, error) {
+	return &proto.ListPrimaryKeysResponse{PKSet: make(map[string]*proto.PrimaryKeys)}, nil
+}
+
+// listTable returns the objects of a table, in key order.
+func (sink *Object) listTable(ctx context.Context, table string) ([]objectInfo, error) {
+	objects, err := sink.client.listObjects(ctx, sink.key.listPrefix(table))
+	if err != nil {
+		return nil, err
+	}
+
+	matched := objects[:0]
+
+	for _, obj := range objects {
+		if name, ok := sink.key.table(obj.key); ok && name == table {
+			matched = append(matched, obj)
+		}
+	}
+
+	return matched, nil
+}
+
+// ListTables will list every table with an object in the bucket that matches the key template, along with the
+// tables that the storage has written to or truncated. The size of a table is the size of its objects.
+func (sink *Object) ListTables(ctx context.Context) (*proto.ListTablesResponse, error) {
+	objects, err := sink.client.listObjects(ctx, sink.key.listPrefix(""))
+	if err != nil {
+		return nil, err
+	}
+
+	rsp := &proto.ListTablesResponse{TableSet: make(map[string]*proto.Table)}
+
+	sink.mu.Lock()
+	for table := range sink.tables {
+		rsp.TableSet[table] = &proto.Table{}
+	}
+	sink.mu.Unlock()
+
+	for _, obj := range objects {
+		table, ok := sink.key.table(obj.key)
+		if !ok {
+			continue
+		}
+
+		if rsp.TableSet[table] == nil {
+			rsp.TableSet[table] = &proto.Table{}
+		}
+
+		rsp.TableSet[table].Size += obj.size
+	}
+
+	return rsp, nil
+}
+
+// truncate will delete the objects of the tables.
+func (sink *Object) truncate(ctx context.Context, tables []string) error {
+	for _, table := range tables {
+		objects, err := sink.listTable(ctx, table)
+		if err != nil {
+			return err
+		}
+
+		sink.mu.Lock()
+		sink.tables[table] = true
+		sink.mu.Unlock()
+
+		for _, obj := range objects {
+			if err := sink.client.deleteObject(ctx, obj.key); err != nil {
+				return fmt.Errorf("unable to truncate table %q: %w", table, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Truncate will delete the objects of the tables on the request. In a transaction, the objects are deleted when it
+// is committed, before its records are uploaded.
+func (sink *Object) Truncate(ctx context.Context, req *proto.TruncateRequest) (*proto.TruncateResponse, error) {
+	if txID, ok := ctx.Value(basicObjectTxID).(string); ok {
+		stored, ok := sink.activeTx.Load(txID)
+		if !ok {
+			return nil, ErrTransactionNotFound
+		}
+
+		otx, ok := stored.(*objectTx)
+		if !ok {
+			return nil, ErrTransactionNotFound
+		}
+
+		otx.mu.Lock()
+		otx.truncated = append(otx.truncated, req.GetTables()...)
+		otx.mu.Unlock()
+
+		return &proto.TruncateResponse{}, nil
+	}
+
+	if err := sink.truncate(ctx, req.GetTables()); err != nil {
+		return nil, err
+	}
+
+	return &proto.TruncateResponse{}, nil
+}
+
+// Read will call "fn" with every record in the objects of the table on the request, in key order. If the request
+// has an "OrderBy" column, the records are read into memory and sorted.
+func (sink *Object) Read(ctx context.Context, req *proto.ReadRecordsRequest, fn proto.ReadFunc) error {
+	objects, err := sink.listTable(ctx, req.Table)
+	if err != nil {
+		return err
+	}
+
+	var records []map[string]interface{}
+
+	emit := fn
+	if req.OrderBy != "" {
+		emit = func(record map[string]interface{}) error {
+			records = append(records, record)
+
+			return nil
+		}
+	}
+
+	for _, obj := range objects {
+		if err := sink.readObject(ctx, obj.key, emit); err != nil {
+			return err
+		}
+	}
+
+	if req.OrderBy == "" {
+		return nil
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		return proto.LessValue(records[i][req.OrderBy], records[j][req.OrderBy])
+	})
+
+	for _, record := range records {
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readObject will download an object to a temporary file, and call "fn" with every record in it.
+func (sink *Object) readObject(ctx context.Context, key string, fn proto.ReadFunc) error {
+	temp, err := sink.ws.CreateTemp(tempDir, "gidari-object-*")
+	if err != nil {
+		return fmt.Errorf("unable to create temporary file: %w", err)
+	}
+
+	defer os.Remove(temp.Name())
+	defer temp.Close()
+
+	if err := sink.client.getObject(ctx, key, temp); err != nil {
+		return err
+	}
+
+	path := temp.Name()
+
+	if sink.gzip {
+		if path, err = sink.decompress(path); err != nil {
+			return err
+		}
+
+		defer os.Remove(path)
+	}
+
+	if sink.format == formatParquet {
+		return parquet.ReadFile(ctx, path, fn)
+	}
+
+	return ndjsonSource(path, false)(fn)
+}
+
+// Ping will return an error if the storage is closed or the bucket cannot be accessed.
+func (sink *Object) Ping() error {
+	sink.mu.Lock()
+	closed := sink.closed
+	sink.mu.Unlock()
+
+	if closed {
+		return ErrClosed
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+	defer cancel()
+
+	if err := sink.client.headBucket(ctx); err != nil {
+		return fmt.Errorf("connection lost: %w", err)
+	}
+
+	return nil
+}
+
+// IsNoSQL returns "true" since the objects have no schema that records have to match.
+func (sink *Object) IsNoSQL() bool { return true }
+
+// Type implements the storage interface.
+func (sink *Object) Type() uint8 { return sink.storageType }
+
+// StartTx will start a transaction. Records sent to the transaction are held until it is committed, and discarded
+// if it is rolled back. Object storage has no transactions, so if the upload of a table fails on commit, the
+// objects of the tables that were uploaded before it are kept.
+func (sink *Object) StartTx(ctx context.Context) (*proto.Txn, error) {
+	txn := &proto.Txn{
+		FunctionCh: make(chan proto.TxnChanFn),
+		DoneCh:     make(chan error, 1),
+		CommitCh:   make(chan bool, 1),
+	}
+
+	txnID := uuid.New().String()
+	otx := &objectTx{id: txnID, ws: sink.ws, temps: make(map[string]*os.File)}
+
+	sink.activeTx.Store(txnID, otx)
+
+	// Create a copy of the parent context with a transaction ID.
+	objectCtx := context.WithValue(ctx, basicObjectTxID, txnID)
+
+	go func() {
+		defer sink.activeTx.Delete(txnID)
+		defer otx.discard()
+
+		var err error
+
+		for fn := range txn.FunctionCh {
+			if err != nil {
+				continue
+			}
+
+			err = fn(objectCtx, sink)
+		}
+
+		if err != nil {
+			txn.DoneCh <- err
+
+			return
+		}
+
+		if !<-txn.CommitCh {
+			txn.DoneCh <- nil
+
+			return
+		}
+
+		txn.DoneCh <- sink.commit(ctx, otx)
+	}()
+
+	return txn, nil
+}
+
+// commit will upload the records of a transaction to a new object for each of their tables.
+func (sink *Object) commit(ctx context.Context, otx *objectTx) error {
+	if err := sink.truncate(ctx, otx.truncated); err != nil {
+		return err
+	}
+
+	for _, table := range otx.tables {
+		if err := sink.upload(ctx, table, otx.temps[table].Name()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
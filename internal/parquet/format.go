@@ -0,0 +1,678 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package parquet
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+
+	"github.com/alpstable/gidari/internal/proto"
+	"github.com/alpstable/gidari/version"
+)
+
+// ErrUnsupportedFile is returned when reading a Parquet file that uses features that are not written by this
+// package, such as compression, dictionary encoding or nested columns.
+var ErrUnsupportedFile = fmt.Errorf("unsupported parquet file")
+
+// magic is at the start and the end of every Parquet file.
+const magic = "PAR1"
+
+// The page types, encodings and compression codecs of Parquet files.
+const (
+	pageData          = 0
+	encodingPlain     = 0
+	encodingRLE       = 3
+	codecUncompressed = 0
+)
+
+// fileWriter writes the row groups of a Parquet file. Every column chunk is a single uncompressed data page with
+// PLAIN encoded values.
+type fileWriter struct {
+	file *os.File
+	buf  *bufio.Writer
+	cols schema
+
+	// offset is the position in the file that the next page is written at.
+	offset int64
+
+	rowGroups []interface{}
+	rows      int64
+}
+
+// createFile will create a Parquet file with the columns of the schema.
+func createFile(path string, cols schema) (*fileWriter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create %s: %w", path, err)
+	}
+
+	writer := &fileWriter{file: file, buf: bufio.NewWriter(file), cols: cols}
+	if err := writer.write([]byte(magic)); err != nil {
+		file.Close()
+
+		return nil, err
+	}
+
+	return writer, nil
+}
+
+func (writer *fileWriter) write(data []byte) error {
+	if _, err := writer.buf.Write(data); err != nil {
+		return fmt.Errorf("unable to write %s: %w", writer.file.Name(), err)
+	}
+
+	writer.offset += int64(len(data))
+
+	return nil
+}
+
+// writeRowGroup will write a row group of flattened records.
+func (writer *fileWriter) writeRowGroup(records []map[string]interface{}) error {
+	chunks := make([]interface{}, 0, len(writer.cols))
+
+	var size int64
+
+	for _, col := range writer.cols {
+		page, err := encodePage(col, records)
+		if err != nil {
+			return err
+		}
+
+		header := appendThrift(nil, thriftStruct{
+			{id: 1, val: int32(pageData)},
+			{id: 2, val: int32(len(page))},
+			{id: 3, val: int32(len(page))},
+			{id: 5, val: thriftStruct{
+				{id: 1, val: int32(len(records))},
+				{id: 2, val: int32(encodingPlain)},
+				{id: 3, val: int32(encodingRLE)},
+				{id: 4, val: int32(encodingRLE)},
+			}},
+		})
+
+		offset := writer.offset
+		chunkSize := int64(len(header) + len(page))
+
+		if err := writer.write(header); err != nil {
+			return err
+		}
+
+		if err := writer.write(page); err != nil {
+			return err
+		}
+
+		chunks = append(chunks, thriftStruct{
+			{id: 2, val: offset},
+			{id: 3, val: thriftStruct{
+				{id: 1, val: col.physicalType()},
+				{id: 2, val: thriftList{elem: compactI32, vals: []interface{}{
+					int32(encodingPlain), int32(encodingRLE),
+				}}},
+				{id: 3, val: thriftList{elem: compactBinary, vals: []interface{}{col.name}}},
+				{id: 4, val: int32(codecUncompressed)},
+				{id: 5, val: int64(len(records))},
+				{id: 6, val: chunkSize},
+				{id: 7, val: chunkSize},
+				{id: 9, val: offset},
+			}},
+		})
+
+		size += chunkSize
+	}
+
+	writer.rowGroups = append(writer.rowGroups, thriftStruct{
+		{id: 1, val: thriftList{elem: compactStruct, vals: chunks}},
+		{id: 2, val: size},
+		{id: 3, val: int64(len(records))},
+	})
+
+	writer.rows += int64(len(records))
+
+	return nil
+}
+
+// close will write the footer of the file and close it.
+func (writer *fileWriter) close() error {
+	defer writer.file.Close()
+
+	footer := appendThrift(nil, thriftStruct{
+		{id: 1, val: int32(1)},
+		{id: 2, val: thriftList{elem: compactStruct, vals: writer.cols.elements()}},
+		{id: 3, val: writer.rows},
+		{id: 4, val: thriftList{elem: compactStruct, vals: writer.rowGroups}},
+		{id: 6, val: "gidari version " + version.Gidari},
+	})
+
+	footer = binary.LittleEndian.AppendUint32(footer, uint32(len(footer)))
+	footer = append(footer, magic...)
+
+	if err := writer.write(footer); err != nil {
+		return err
+	}
+
+	if err := writer.buf.Flush(); err != nil {
+		return fmt.Errorf("unable to write %s: %w", writer.file.Name(), err)
+	}
+
+	if err := writer.file.Close(); err != nil {
+		return fmt.Errorf("unable to close %s: %w", writer.file.Name(), err)
+	}
+
+	return nil
+}
+
+// RecordSource calls "fn" with every record of a file that is being written. It is called twice, once to infer the
+// schema of the file and once to write its row groups.
+type RecordSource func(fn func(record map[string]interface{}) error) error
+
+// WriteFile will write the records of the source to a Parquet file at "path", with "rowGroupSize" records in each
+// row group. Nested objects are flattened into columns named by their path, and the column types are inferred from
+// the records. If the records have no fields, no file is written and "false" is returned.
+func WriteFile(path string, rowGroupSize int, source RecordSource) (bool, error) {
+	flattened := func(fn func(record map[string]interface{}) error) error {
+		return source(func(record map[string]interface{}) error {
+			flat := make(map[string]interface{}, len(record))
+			flattenRecord("", record, flat)
+
+			return fn(flat)
+		})
+	}
+
+	inferrer := make(schemaInferrer)
+
+	if err := flattened(func(record map[string]interface{}) error {
+		inferrer.observe(record)
+
+		return nil
+	}); err != nil {
+		return false, err
+	}
+
+	cols := inferrer.schema()
+	if len(cols) == 0 {
+		return false, nil
+	}
+
+	writer, err := createFile(path, cols)
+	if err != nil {
+		return false, err
+	}
+
+	batch := make([]map[string]interface{}, 0, rowGroupSize)
+
+	err = flattened(func(record map[string]interface{}) error {
+		if batch = append(batch, record); len(batch) < rowGroupSize {
+			return nil
+		}
+
+		err := writer.writeRowGroup(batch)
+		batch = batch[:0]
+
+		return err
+	})
+	if err == nil && len(batch) > 0 {
+		err = writer.writeRowGroup(batch)
+	}
+
+	if err != nil {
+		writer.file.Close()
+
+		return false, err
+	}
+
+	if err := writer.close(); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// encodeLevels will encode definition levels with the RLE/bit-packed hybrid encoding, prefixed by their length. The
+// levels are a single run if they are all equal, and bit-packed otherwise.
+func encodeLevels(defined []bool) []byte {
+	var levels []byte
+
+	allEqual := true
+
+	for _, def := range defined {
+		allEqual = allEqual && def == defined[0]
+	}
+
+	if allEqual {
+		levels = binary.AppendUvarint(levels, uint64(len(defined))<<1)
+
+		if len(defined) > 0 && defined[0] {
+			levels = append(levels, 1)
+		} else {
+			levels = append(levels, 0)
+		}
+	} else {
+		groups := (len(defined) + 7) / 8
+		levels = binary.AppendUvarint(levels, uint64(groups)<<1|1)
+		levels = append(levels, packBools(defined)...)
+	}
+
+	return append(binary.LittleEndian.AppendUint32(nil, uint32(len(levels))), levels...)
+}
+
+// packBools will pack booleans into bits, least significant bit first.
+func packBools(vals []bool) []byte {
+	packed := make([]byte, (len(vals)+7)/8)
+
+	for idx, val := range vals {
+		if val {
+			packed[idx/8] |= 1 << (idx % 8)
+		}
+	}
+
+	return packed
+}
+
+// encodePage will encode the values of a column in a data page: the definition levels of the records followed by
+// their non-null values.
+func encodePage(col column, records []map[string]interface{}) ([]byte, error) {
+	defined := make([]bool, len(records))
+
+	var (
+		values []byte
+		bools  []bool
+	)
+
+	for idx, record := range records {
+		val := record[col.name]
+		if val == nil {
+			continue
+		}
+
+		defined[idx] = true
+
+		switch col.kind {
+		case kindBool:
+			b, _ := val.(bool)
+			bools = append(bools, b)
+		case kindInt:
+			values = binary.LittleEndian.AppendUint64(values, uint64(intValue(val)))
+		case kindFloat:
+			values = binary.LittleEndian.AppendUint64(values, math.Float64bits(floatValue(val)))
+		case kindNull, kindString, kindJSON:
+			encode := stringValue
+			if col.kind == kindJSON {
+				encode = jsonValue
+			}
+
+			data, err := encode(val)
+			if err != nil {
+				return nil, err
+			}
+
+			values = binary.LittleEndian.AppendUint32(values, uint32(len(data)))
+			values = append(values, data...)
+		}
+	}
+
+	if col.kind == kindBool {
+		values = packBools(bools)
+	}
+
+	return append(encodeLevels(defined), values...), nil
+}
+
+// fileColumn is a column of a Parquet file that is being read.
+type fileColumn struct {
+	name     string
+	typ      int64
+	optional bool
+	json     bool
+}
+
+// readFooter will read the metadata of a Parquet file.
+func readFooter(file *os.File) (thriftStruct, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("unable to stat %s: %w", file.Name(), err)
+	}
+
+	tail := make([]byte, 8)
+	if info.Size() < int64(len(magic)+len(tail)) {
+		return nil, fmt.Errorf("%w: %s is too small", ErrUnsupportedFile, file.Name())
+	}
+
+	if _, err := file.ReadAt(tail, info.Size()-int64(len(tail))); err != nil {
+		return nil, fmt.Errorf("unable to read %s: %w", file.Name(), err)
+	}
+
+	size := int64(binary.LittleEndian.Uint32(tail))
+	if string(tail[4:]) != magic || size > info.Size()-int64(len(tail)+len(magic)) {
+		return nil, fmt.Errorf("%w: %s has no footer", ErrUnsupportedFile, file.Name())
+	}
+
+	section := io.NewSectionReader(file, info.Size()-int64(len(tail))-size, size)
+
+	meta, err := newThriftDecoder(section).readStruct(0)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the footer of %s: %w", file.Name(), err)
+	}
+
+	return meta, nil
+}
+
+// fileColumns will return the columns of a file from its metadata. Only flat schemas are supported.
+func fileColumns(path string, meta thriftStruct) ([]fileColumn, error) {
+	elems := meta.structList(2)
+	if len(elems) == 0 {
+		return nil, fmt.Errorf("%w: %s has no schema", ErrUnsupportedFile, path)
+	}
+
+	cols := make([]fileColumn, 0, len(elems)-1)
+
+	for _, elem := range elems[1:] {
+		if children, _ := elem.int(5); children > 0 {
+			return nil, fmt.Errorf("%w: %s has nested column %q", ErrUnsupportedFile, path, elem.str(4))
+		}
+
+		typ, _ := elem.int(1)
+		repetition, _ := elem.int(3)
+		converted, hasConverted := elem.int(6)
+
+		if repetition != repetitionRequired && repetition != repetitionOptional {
+			return nil, fmt.Errorf("%w: %s has repeated column %q", ErrUnsupportedFile, path, elem.str(4))
+		}
+
+		cols = append(cols, fileColumn{
+			name:     elem.str(4),
+			typ:      typ,
+			optional: repetition == repetitionOptional,
+			json:     hasConverted && converted == convertedJSON,
+		})
+	}
+
+	return cols, nil
+}
+
+// decodeLevels will decode "count" definition levels encoded with the RLE/bit-packed hybrid encoding, with a bit
+// width of one.
+func decodeLevels(data []byte, count int) ([]bool, error) {
+	reader := bytes.NewReader(data)
+	defined := make([]bool, 0, count)
+
+	for len(defined) < count {
+		header, err := binary.ReadUvarint(reader)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid definition levels: %v", ErrUnsupportedFile, err)
+		}
+
+		if header&1 == 1 {
+			packed := make([]byte, header>>1)
+			if _, err := io.ReadFull(reader, packed); err != nil {
+				return nil, fmt.Errorf("%w: invalid definition levels: %v", ErrUnsupportedFile, err)
+			}
+
+			for idx := 0; idx < 8*len(packed); idx++ {
+				defined = append(defined, packed[idx/8]&(1<<(idx%8)) != 0)
+			}
+
+			continue
+		}
+
+		val, err := reader.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid definition levels: %v", ErrUnsupportedFile, err)
+		}
+
+		for idx := uint64(0); idx < header>>1; idx++ {
+			defined = append(defined, val != 0)
+		}
+	}
+
+	return defined[:count], nil
+}
+
+// decodeValue will decode the next PLAIN encoded value of a column, other than a boolean.
+func decodeValue(col fileColumn, reader *bytes.Reader) (interface{}, error) {
+	read := func(size int) ([]byte, error) {
+		data := make([]byte, size)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, fmt.Errorf("%w: invalid values of column %q: %v", ErrUnsupportedFile, col.name, err)
+		}
+
+		return data, nil
+	}
+
+	switch col.typ {
+	case typeInt32:
+		data, err := read(4)
+		if err != nil {
+			return nil, err
+		}
+
+		return int64(int32(binary.LittleEndian.Uint32(data))), nil
+	case typeInt64:
+		data, err := read(8)
+		if err != nil {
+			return nil, err
+		}
+
+		return int64(binary.LittleEndian.Uint64(data)), nil
+	case typeFloat:
+		data, err := read(4)
+		if err != nil {
+			return nil, err
+		}
+
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(data))), nil
+	case typeDouble:
+		data, err := read(8)
+		if err != nil {
+			return nil, err
+		}
+
+		return math.Float64frombits(binary.LittleEndian.Uint64(data)), nil
+	case typeByteArray:
+		size, err := read(4)
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := read(int(binary.LittleEndian.Uint32(size)))
+		if err != nil {
+			return nil, err
+		}
+
+		if !col.json {
+			return string(data), nil
+		}
+
+		var val interface{}
+		if err := json.Unmarshal(data, &val); err != nil {
+			return nil, fmt.Errorf("%w: %v", proto.ErrFailedToUnmarshalJSON, err)
+		}
+
+		return val, nil
+	default:
+		return nil, fmt.Errorf("%w: column %q has type %d", ErrUnsupportedFile, col.name, col.typ)
+	}
+}
+
+// decodePage will decode the values of a data page, with nil for the values that are not defined.
+func decodePage(col fileColumn, page []byte, count int) ([]interface{}, error) {
+	defined := make([]bool, count)
+	for idx := range defined {
+		defined[idx] = true
+	}
+
+	if col.optional {
+		if len(page) < 4 || int(binary.LittleEndian.Uint32(page)) > len(page)-4 {
+			return nil, fmt.Errorf("%w: invalid definition levels of column %q", ErrUnsupportedFile, col.name)
+		}
+
+		size := int(binary.LittleEndian.Uint32(page))
+
+		var err error
+		if defined, err = decodeLevels(page[4:4+size], count); err != nil {
+			return nil, err
+		}
+
+		page = page[4+size:]
+	}
+
+	vals := make([]interface{}, count)
+	reader := bytes.NewReader(page)
+	bit := 0
+
+	for idx, def := range defined {
+		if !def {
+			continue
+		}
+
+		if col.typ == typeBoolean {
+			if bit/8 >= len(page) {
+				return nil, fmt.Errorf("%w: invalid values of column %q", ErrUnsupportedFile, col.name)
+			}
+
+			vals[idx] = page[bit/8]&(1<<(bit%8)) != 0
+			bit++
+
+			continue
+		}
+
+		val, err := decodeValue(col, reader)
+		if err != nil {
+			return nil, err
+		}
+
+		vals[idx] = val
+	}
+
+	return vals, nil
+}
+
+// readColumnChunk will read the values of a column chunk from its data pages.
+func readColumnChunk(file *os.File, col fileColumn, chunk thriftStruct) ([]interface{}, error) {
+	meta := chunk.strct(3)
+	if meta == nil {
+		return nil, fmt.Errorf("%w: column %q has no metadata", ErrUnsupportedFile, col.name)
+	}
+
+	if codec, _ := meta.int(4); codec != codecUncompressed {
+		return nil, fmt.Errorf("%w: column %q is compressed", ErrUnsupportedFile, col.name)
+	}
+
+	if _, ok := meta.int(11); ok {
+		return nil, fmt.Errorf("%w: column %q is dictionary encoded", ErrUnsupportedFile, col.name)
+	}
+
+	count, _ := meta.int(5)
+	offset, _ := meta.int(9)
+	size, _ := meta.int(7)
+
+	reader := bufio.NewReader(io.NewSectionReader(file, offset, size))
+	dec := newThriftDecoder(reader)
+
+	vals := make([]interface{}, 0, count)
+
+	for int64(len(vals)) < count {
+		header, err := dec.readStruct(0)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read page of column %q: %w", col.name, err)
+		}
+
+		pageSize, _ := header.int(3)
+		dataPage := header.strct(5)
+
+		if typ, _ := header.int(1); typ != pageData || dataPage == nil {
+			return nil, fmt.Errorf("%w: column %q has a page of type %d", ErrUnsupportedFile, col.name, typ)
+		}
+
+		if encoding, _ := dataPage.int(2); encoding != encodingPlain {
+			return nil, fmt.Errorf("%w: column %q has encoding %d", ErrUnsupportedFile, col.name, encoding)
+		}
+
+		page := make([]byte, pageSize)
+		if _, err := io.ReadFull(reader, page); err != nil {
+			return nil, fmt.Errorf("unable to read page of column %q: %w", col.name, err)
+		}
+
+		pageCount, _ := dataPage.int(1)
+
+		pageVals, err := decodePage(col, page, int(pageCount))
+		if err != nil {
+			return nil, err
+		}
+
+		vals = append(vals, pageVals...)
+	}
+
+	return vals, nil
+}
+
+// ReadFile will call "fn" with every record of a Parquet file, keyed by column name.
+func ReadFile(ctx context.Context, path string, fn proto.ReadFunc) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("unable to open %s: %w", path, err)
+	}
+
+	defer file.Close()
+
+	meta, err := readFooter(file)
+	if err != nil {
+		return err
+	}
+
+	cols, err := fileColumns(path, meta)
+	if err != nil {
+		return err
+	}
+
+	for _, rowGroup := range meta.structList(4) {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("unable to read %s: %w", path, err)
+		}
+
+		rows, _ := rowGroup.int(3)
+
+		chunks := rowGroup.structList(1)
+		if len(chunks) != len(cols) {
+			return fmt.Errorf("%w: %s has %d columns but a row group with %d", ErrUnsupportedFile, path, len(cols),
+				len(chunks))
+		}
+
+		values := make([][]interface{}, len(cols))
+
+		for idx, col := range cols {
+			if values[idx], err = readColumnChunk(file, col, chunks[idx]); err != nil {
+				return fmt.Errorf("unable to read %s: %w", path, err)
+			}
+
+			if int64(len(values[idx])) != rows {
+				return fmt.Errorf("%w: column %q of %s has %d values for %d rows", ErrUnsupportedFile, col.name,
+					path, len(values[idx]), rows)
+			}
+		}
+
+		for row := int64(0); row < rows; row++ {
+			record := make(map[string]interface{}, len(cols))
+			for idx, col := range cols {
+				record[col.name] = values[idx][row]
+			}
+
+			if err := fn(record); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,747 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package parquet
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alpstable/gidari/internal/proto"
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+var (
+	ErrTransactionNotFound = fmt.Errorf("transaction not found")
+	ErrInvalidTable        = fmt.Errorf("invalid table name")
+	ErrClosed              = fmt.Errorf("parquet storage is closed")
+	ErrInvalidOptions      = fmt.Errorf("invalid parquet options")
+)
+
+// parquetTxType is a type alias for the parquet transaction type.
+type parquetTxType uint8
+
+const (
+	basicParquetTxID parquetTxType = iota
+)
+
+const (
+	// defaultRowGroupSize is the default number of records in each row group of a file.
+	defaultRowGroupSize = 10000
+
+	// defaultPartition is the partition of records whose "partitionBy" column is not a time, named as Hive names
+	// the partition of null values.
+	defaultPartition = "__HIVE_DEFAULT_PARTITION__"
+
+	partPrefix = "part-"
+	partExt    = ".parquet"
+)
+
+// Parquet is a storage device that writes the records of every table to Parquet files, in a directory per table.
+// Files are never modified once written: every commit adds new files to the table, so unlike a database, upserting
+// a record twice writes it twice.
+type Parquet struct {
+	dir string
+
+	// rowGroupSize is the number of records in each row group of a file.
+	rowGroupSize int
+
+	// partitionBy is the column that records are partitioned by. Each record is written to the directory of the
+	// date of its column, e.g. "trades/date=2022-01-01". Empty disables partitioning.
+	partitionBy string
+
+	mu     sync.Mutex
+	closed bool
+
+	// seqs are the sequence numbers of the last file written to each table.
+	seqs map[string]int
+
+	// activeTx are the transactions that are currently active, keyed by the transaction ID that "StartTx" adds to
+	// the context of the functions sent to the transaction.
+	activeTx sync.Map
+}
+
+// parseConnectionString will return the Parquet storage for a "parquet://" connection string, e.g.
+// "parquet://data?partitionBy=time&rowGroupSize=50000". The path is relative to the working directory, unless it has
+// a leading slash.
+func parseConnectionString(connectionURL string) (*Parquet, error) {
+	scheme, rest, _ := strings.Cut(connectionURL, "://")
+	if scheme != proto.SchemeFromStorageType(proto.ParquetType) {
+		return nil, proto.DNSNotSupportedError(scheme)
+	}
+
+	dir, rawQuery, _ := strings.Cut(rest, "?")
+	if dir == "" {
+		return nil, fmt.Errorf("%w: missing directory", proto.DNSNotSupportedError(scheme))
+	}
+
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse connection string query: %w", err)
+	}
+
+	sink := &Parquet{
+		dir:          dir,
+		rowGroupSize: defaultRowGroupSize,
+		partitionBy:  query.Get("partitionBy"),
+		seqs:         make(map[string]int),
+	}
+
+	if str := query.Get("rowGroupSize"); str != "" {
+		size, err := strconv.Atoi(str)
+		if err != nil || size < 1 {
+			return nil, fmt.Errorf("%w: rowGroupSize %q must be a positive integer", ErrInvalidOptions, str)
+		}
+
+		sink.rowGroupSize = size
+	}
+
+	return sink, nil
+}
+
+// New will return a new Parquet storage device for writing records to the directory of the connection string,
+// which is created if it does not exist.
+func New(_ context.Context, connectionURL string) (*Parquet, error) {
+	sink, err := parseConnectionString(connectionURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(sink.dir, 0o755); err != nil {
+		return nil, fmt.Errorf("unable to create directory: %w", err)
+	}
+
+	return sink, nil
+}
+
+func validTable(table string) error {
+	if table == "" || strings.ContainsAny(table, `/\`) || strings.HasPrefix(table, ".") {
+		return fmt.Errorf("%w: %q", ErrInvalidTable, table)
+	}
+
+	return nil
+}
+
+// partitionTime returns the time of a "partitionBy" value, which is either an RFC 3339 date-time or date, or a
+// number of seconds since the Unix epoch.
+func partitionTime(val interface{}) (time.Time, bool) {
+	switch val := val.(type) {
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02"} {
+			if t, err := time.Parse(layout, val); err == nil {
+				return t, true
+			}
+		}
+	case json.Number:
+		if secs, err := val.Float64(); err == nil {
+			return time.Unix(0, int64(secs*float64(time.Second))), true
+		}
+	case float64:
+		return time.Unix(0, int64(val*float64(time.Second))), true
+	}
+
+	return time.Time{}, false
+}
+
+// partition returns the directory of a flattened record within the directory of its table, or an empty string if
+// the storage is not partitioned.
+func (sink *Parquet) partition(record map[string]interface{}) string {
+	if sink.partitionBy == "" {
+		return ""
+	}
+
+	t, ok := partitionTime(record[sink.partitionBy])
+	if !ok {
+		return "date=" + defaultPartition
+	}
+
+	return "date=" + t.UTC().Format("2006-01-02")
+}
+
+// partFiles returns the paths of the files of a table, ordered by partition and then by sequence number.
+func (sink *Parquet) partFiles(table string) ([]string, error) {
+	var paths []string
+
+	err := filepath.WalkDir(filepath.Join(sink.dir, table), func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		name := entry.Name()
+		if !entry.IsDir() && strings.HasPrefix(name, partPrefix) && strings.HasSuffix(name, partExt) {
+			paths = append(paths, path)
+		}
+
+		return nil
+	})
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("unable to list files of table %q: %w", table, err)
+	}
+
+	sort.Strings(paths)
+
+	return paths, nil
+}
+
+// nextSeq returns the sequence number of the next file written to a table, continuing the sequence of the files
+// written by a previous run. The lock must be held.
+func (sink *Parquet) nextSeq(table string) (int, error) {
+	if _, ok := sink.seqs[table]; !ok {
+		paths, err := sink.partFiles(table)
+		if err != nil {
+			return 0, err
+		}
+
+		for _, path := range paths {
+			name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), partPrefix), partExt)
+			if seq, err := strconv.Atoi(name); err == nil && seq > sink.seqs[table] {
+				sink.seqs[table] = seq
+			}
+		}
+	}
+
+	sink.seqs[table]++
+
+	return sink.seqs[table], nil
+}
+
+// writeFile will write the records of a partition of a table to a new file, which only appears in the directory
+// of the table once it is complete. Records without any fields are not written. The lock must be held.
+func (sink *Parquet) writeFile(table, partition string, source RecordSource) error {
+	if sink.closed {
+		return ErrClosed
+	}
+
+	seq, err := sink.nextSeq(table)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Join(sink.dir, table, partition)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("unable to create directory for table %q: %w", table, err)
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("%s%06d%s", partPrefix, seq, partExt))
+	temp := filepath.Join(dir, "."+filepath.Base(path)+".tmp")
+
+	defer os.Remove(temp)
+
+	written, err := WriteFile(temp, sink.rowGroupSize, source)
+	if err != nil || !written {
+		return err
+	}
+
+	if err := os.Rename(temp, path); err != nil {
+		return fmt.Errorf("unable to write file for table %q: %w", table, err)
+	}
+
+	return nil
+}
+
+// partitionRecords will flatten records and group them by partition, in the order that the partitions first appear.
+func (sink *Parquet) partitionRecords(records []map[string]interface{}) ([]string,
+	map[string][]map[string]interface{},
+) {
+	var partitions []string
+
+	grouped := make(map[string][]map[string]interface{})
+
+	for _, record := range records {
+		flat := make(map[string]interface{}, len(record))
+		flattenRecord("", record, flat)
+
+		partition := sink.partition(flat)
+		if _, ok := grouped[partition]; !ok {
+			partitions = append(partitions, partition)
+		}
+
+		grouped[partition] = append(grouped[partition], flat)
+	}
+
+	return partitions, grouped
+}
+
+// writeRecords will write records to a new file in each partition of a table that they belong to.
+func (sink *Parquet) writeRecords(table string, records []map[string]interface{}) error {
+	if err := validTable(table); err != nil {
+		return err
+	}
+
+	partitions, grouped := sink.partitionRecords(records)
+
+	for _, partition := range partitions {
+		records := grouped[partition]
+
+		err := sink.writeFile(table, partition, func(fn func(record map[string]interface{}) error) error {
+			for _, record := range records {
+				if err := fn(record); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// partitionKey identifies the records of a transaction that are written to the same file.
+type partitionKey struct {
+	table     string
+	partition string
+}
+
+// parquetTx holds the records sent to a transaction in temporary files, one for each partition of each table,
+// which are written to Parquet files when the transaction is committed.
+type parquetTx struct {
+	id string
+
+	mu    sync.Mutex
+	keys  []partitionKey
+	temps map[partitionKey]string
+
+	// truncated are the tables truncated in the transaction, which are emptied when it is committed before the
+	// records of the transaction are written.
+	truncated []string
+}
+
+// writeTx will append records to the temporary files of their partitions in a transaction, which hold them as
+// flattened, newline-delimited JSON until the transaction is committed. The files are only open while they are
+// written to, since a backfill can have a partition for every day of several years.
+func (sink *Parquet) writeTx(ptx *parquetTx, table string, records []map[string]interface{}) error {
+	if err := validTable(table); err != nil {
+		return err
+	}
+
+	ptx.mu.Lock()
+	defer ptx.mu.Unlock()
+
+	partitions, grouped := sink.partitionRecords(records)
+
+	for _, partition := range partitions {
+		key := partitionKey{table: table, partition: partition}
+
+		path, ok := ptx.temps[key]
+		if !ok {
+			temp, err := os.CreateTemp(sink.dir, fmt.Sprintf(".%s.%s.*.tmp", table, ptx.id))
+			if err != nil {
+				return fmt.Errorf("unable to create transaction file: %w", err)
+			}
+
+			temp.Close()
+
+			path = temp.Name()
+			ptx.keys = append(ptx.keys, key)
+			ptx.temps[key] = path
+		}
+
+		if err := appendRecords(path, grouped[partition]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// appendRecords will append records to a file as newline-delimited JSON.
+func appendRecords(path string, records []map[string]interface{}) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("unable to open transaction file: %w", err)
+	}
+
+	defer file.Close()
+
+	buf := bufio.NewWriter(file)
+	encoder := json.NewEncoder(buf)
+
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return fmt.Errorf("%w: %v", proto.ErrFailedToMarshalJSON, err)
+		}
+	}
+
+	if err := buf.Flush(); err != nil {
+		return fmt.Errorf("unable to write transaction file: %w", err)
+	}
+
+	return nil
+}
+
+// readTemp returns the source of the records in the temporary file of a transaction.
+func readTemp(path string) RecordSource {
+	return func(fn func(record map[string]interface{}) error) error {
+		file, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("unable to read transaction file: %w", err)
+		}
+
+		defer file.Close()
+
+		decoder := json.NewDecoder(bufio.NewReader(file))
+		decoder.UseNumber()
+
+		for {
+			var record map[string]interface{}
+
+			err := decoder.Decode(&record)
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+
+			if err != nil {
+				return fmt.Errorf("%w: %v", proto.ErrFailedToUnmarshalJSON, err)
+			}
+
+			if err := fn(record); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// discard will remove the temporary files of a transaction.
+func (ptx *parquetTx) discard() {
+	for _, path := range ptx.temps {
+		os.Remove(path)
+	}
+}
+
+func (sink *Parquet) upsert(ctx context.Context, table string, structs []*structpb.Struct) error {
+	records := make([]map[string]interface{}, len(structs))
+	for idx, record := range structs {
+		records[idx] = record.AsMap()
+	}
+
+	if txID, ok := ctx.Value(basicParquetTxID).(string); ok {
+		stored, ok := sink.activeTx.Load(txID)
+		if !ok {
+			return ErrTransactionNotFound
+		}
+
+		ptx, ok := stored.(*parquetTx)
+		if !ok {
+			return ErrTransactionNotFound
+		}
+
+		return sink.writeTx(ptx, table, records)
+	}
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	return sink.writeRecords(table, records)
+}
+
+// Upsert will write the records on the request to new files of the table.
+func (sink *Parquet) Upsert(ctx context.Context, req *proto.UpsertRequest) (*proto.UpsertResponse, error) {
+	records, err := proto.DecodeUpsertRequest(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode records: %w", err)
+	}
+
+	// Do nothing if there are no records.
+	if len(records) == 0 {
+		return &proto.UpsertResponse{}, nil
+	}
+
+	if err := sink.upsert(ctx, req.GetTable(), records); err != nil {
+		return nil, fmt.Errorf("unable to upsert: %w", err)
+	}
+
+	return &proto.UpsertResponse{UpsertedCount: int64(len(records))}, nil
+}
+
+// UpsertBinary will write "property bag"-like records, with the data encoded as a JSON string.
+func (sink *Parquet) UpsertBinary(ctx context.Context,
+	req *proto.UpsertBinaryRequest,
+) (*proto.UpsertBinaryResponse, error) {
+	records, err := proto.DecodeUpsertBinaryRequest(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode records: %w", err)
+	}
+
+	// Do nothing if there are no records.
+	if len(records) == 0 {
+		return &proto.UpsertBinaryResponse{}, nil
+	}
+
+	if err := sink.upsert(ctx, req.GetTable(), records); err != nil {
+		return nil, fmt.Errorf("unable to upsert: %w", err)
+	}
+
+	return &proto.UpsertBinaryResponse{}, nil
+}
+
+// Close will close the storage. Files are complete once they are written, so there is nothing to flush.
+func (sink *Parquet) Close() {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	sink.closed = true
+}
+
+// ListPrimaryKeys will return an empty set, since records written to files do not have primary keys.
+func (sink *Parquet) ListPrimaryKeys(_ context.Context) (*proto.ListPrimaryKeysResponse, error) {
+	return &proto.ListPrimaryKeysResponse{PKSet: make(map[string]*proto.PrimaryKeys)}, nil
+}
+
+// ListTables will list every table with a directory in the storage. The size of a table is the size of its files.
+func (sink *Parquet) ListTables(_ context.Context) (*proto.ListTablesResponse, error) {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	entries, err := os.ReadDir(sink.dir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read directory: %w", err)
+	}
+
+	rsp := &proto.ListTablesResponse{TableSet: make(map[string]*proto.Table)}
+
+	for _, entry := range entries {
+		table := entry.Name()
+		if !entry.IsDir() || strings.HasPrefix(table, ".") {
+			continue
+		}
+
+		paths, err := sink.partFiles(table)
+		if err != nil {
+			return nil, err
+		}
+
+		var size int64
+
+		for _, path := range paths {
+			info, err := os.Stat(path)
+			if err != nil {
+				return nil, fmt.Errorf("unable to stat file for table %q: %w", table, err)
+			}
+
+			size += info.Size()
+		}
+
+		rsp.TableSet[table] = &proto.Table{Size: size}
+	}
+
+	return rsp, nil
+}
+
+// truncate will remove the files of the tables, leaving their directories empty. The lock must be held.
+func (sink *Parquet) truncate(tables []string) error {
+	for _, table := range tables {
+		if err := validTable(table); err != nil {
+			return err
+		}
+
+		dir := filepath.Join(sink.dir, table)
+		if err := os.RemoveAll(dir); err != nil {
+			return fmt.Errorf("unable to truncate table %q: %w", table, err)
+		}
+
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("unable to truncate table %q: %w", table, err)
+		}
+
+		delete(sink.seqs, table)
+	}
+
+	return nil
+}
+
+// Truncate will remove the files of the tables on the request, leaving their directories empty. In a transaction,
+// the tables are truncated when it is committed, before its records are written.
+func (sink *Parquet) Truncate(ctx context.Context, req *proto.TruncateRequest) (*proto.TruncateResponse, error) {
+	if txID, ok := ctx.Value(basicParquetTxID).(string); ok {
+		stored, ok := sink.activeTx.Load(txID)
+		if !ok {
+			return nil, ErrTransactionNotFound
+		}
+
+		ptx, ok := stored.(*parquetTx)
+		if !ok {
+			return nil, ErrTransactionNotFound
+		}
+
+		for _, table := range req.GetTables() {
+			if err := validTable(table); err != nil {
+				return nil, err
+			}
+		}
+
+		ptx.mu.Lock()
+		ptx.truncated = append(ptx.truncated, req.GetTables()...)
+		ptx.mu.Unlock()
+
+		return &proto.TruncateResponse{}, nil
+	}
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	if err := sink.truncate(req.GetTables()); err != nil {
+		return nil, err
+	}
+
+	return &proto.TruncateResponse{}, nil
+}
+
+// Read will call "fn" with every record in the files of the table on the request, by partition and then in the
+// order they were written. If the request has an "OrderBy" column, the records are read into memory and sorted.
+func (sink *Parquet) Read(ctx context.Context, req *proto.ReadRecordsRequest, fn proto.ReadFunc) error {
+	if err := validTable(req.Table); err != nil {
+		return err
+	}
+
+	sink.mu.Lock()
+	paths, err := sink.partFiles(req.Table)
+	sink.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	var records []map[string]interface{}
+
+	emit := fn
+	if req.OrderBy != "" {
+		emit = func(record map[string]interface{}) error {
+			records = append(records, record)
+
+			return nil
+		}
+	}
+
+	for _, path := range paths {
+		if err := ReadFile(ctx, path, emit); err != nil {
+			return err
+		}
+	}
+
+	if req.OrderBy == "" {
+		return nil
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		return proto.LessValue(records[i][req.OrderBy], records[j][req.OrderBy])
+	})
+
+	for _, record := range records {
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// IsNoSQL returns "true" since the schema of each file is inferred from its records.
+func (sink *Parquet) IsNoSQL() bool { return true }
+
+// Type implements the storage interface.
+func (sink *Parquet) Type() uint8 { return proto.ParquetType }
+
+// StartTx will start a transaction. Records sent to the transaction are held until it is committed, and discarded
+// if it is rolled back.
+func (sink *Parquet) StartTx(ctx context.Context) (*proto.Txn, error) {
+	txn := &proto.Txn{
+		FunctionCh: make(chan proto.TxnChanFn),
+		DoneCh:     make(chan error, 1),
+		CommitCh:   make(chan bool, 1),
+	}
+
+	txnID := uuid.New().String()
+	ptx := &parquetTx{id: txnID, temps: make(map[partitionKey]string)}
+
+	sink.activeTx.Store(txnID, ptx)
+
+	// Create a copy of the parent context with a transaction ID.
+	parquetCtx := context.WithValue(ctx, basicParquetTxID, txnID)
+
+	go func() {
+		defer sink.activeTx.Delete(txnID)
+		defer ptx.discard()
+
+		var err error
+
+		for fn := range txn.FunctionCh {
+			if err != nil {
+				continue
+			}
+
+			err = fn(parquetCtx, sink)
+		}
+
+		if err != nil {
+			txn.DoneCh <- err
+
+			return
+		}
+
+		if !<-txn.CommitCh {
+			txn.DoneCh <- nil
+
+			return
+		}
+
+		txn.DoneCh <- sink.commit(ptx)
+	}()
+
+	return txn, nil
+}
+
+// commit will write the records of a transaction to a new file in each partition of their tables.
+func (sink *Parquet) commit(ptx *parquetTx) error {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	if err := sink.truncate(ptx.truncated); err != nil {
+		return err
+	}
+
+	for _, key := range ptx.keys {
+		if err := sink.writeFile(key.table, key.partition, readTemp(ptx.temps[key])); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Ping will return an error if the storage is closed or the directory is no longer accessible.
+func (sink *Parquet) Ping() error {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	if sink.closed {
+		return ErrClosed
+	}
+
+	if _, err := os.Stat(sink.dir); err != nil {
+		return fmt.Errorf("connection lost: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,361 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package parquet
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/alpstable/gidari/internal/proto"
+)
+
+// readRecords will read every record of a table.
+func readRecords(ctx context.Context, t *testing.T, sink *Parquet,
+	req *proto.ReadRecordsRequest,
+) []map[string]interface{} {
+	t.Helper()
+
+	var records []map[string]interface{}
+
+	if err := sink.Read(ctx, req, func(record map[string]interface{}) error {
+		records = append(records, record)
+
+		return nil
+	}); err != nil {
+		t.Fatalf("failed to read: %v", err)
+	}
+
+	return records
+}
+
+func TestParquet(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	dns := "parquet://" + t.TempDir()
+
+	sink, err := New(ctx, dns)
+	if err != nil {
+		t.Fatalf("failed to open parquet storage: %v", err)
+	}
+
+	data := map[string]interface{}{"test_string": "test", "id": "1"}
+
+	// The size of a table is the size of its files, so write the same record to another directory to measure it.
+	sized, err := New(ctx, "parquet://"+t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to open parquet storage: %v", err)
+	}
+
+	encoded, _ := json.Marshal(data)
+	if _, err := sized.Upsert(ctx, &proto.UpsertRequest{Table: "tests1", Data: encoded}); err != nil {
+		t.Fatalf("failed to upsert: %v", err)
+	}
+
+	tables, err := sized.ListTables(ctx)
+	if err != nil {
+		t.Fatalf("failed to list tables: %v", err)
+	}
+
+	proto.RunTest(ctx, t, sink, func(runner *proto.TestRunner) {
+		runner.AddCloseDBCases(proto.TestCase{
+			Name: "close parquet",
+			OpenFn: func() proto.Storage {
+				stg, _ := New(ctx, dns)
+
+				return stg
+			},
+		})
+
+		runner.AddStorageTypeCases(proto.TestCase{Name: "storage type", StorageType: proto.ParquetType})
+		runner.AddIsNoSQLCases(proto.TestCase{Name: "isNoSQL parquet", ExpectedIsNoSQL: true})
+		runner.AddListTablesCases(proto.TestCase{Name: "single", Table: "lttests1"})
+
+		runner.AddUpsertTxnCases(
+			proto.TestCase{
+				Name:               "commit",
+				Table:              "tests1",
+				ExpectedUpsertSize: tables.GetTableSet()["tests1"].GetSize(),
+				Data:               data,
+			},
+			proto.TestCase{
+				Name:               "rollback",
+				Table:              "tests1",
+				ExpectedUpsertSize: 0,
+				Rollback:           true,
+				Data:               data,
+			},
+			proto.TestCase{
+				Name:       "rollback on error",
+				Table:      "tests1",
+				ForceError: true,
+				Data:       data,
+			},
+		)
+
+		runner.AddTruncateTxnCases(proto.TestCase{Name: "parquet", Table: "tests1", Data: data})
+
+		runner.AddPingCases(proto.TestCase{Name: "check parquet connection"})
+	})
+}
+
+func TestParseConnectionString(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name         string
+		dns          string
+		dir          string
+		rowGroupSize int
+		partitionBy  string
+		err          bool
+	}{
+		{name: "relative", dns: "parquet://data", dir: "data", rowGroupSize: defaultRowGroupSize},
+		{name: "absolute", dns: "parquet:///tmp/data", dir: "/tmp/data", rowGroupSize: defaultRowGroupSize},
+		{
+			name:         "options",
+			dns:          "parquet://data?rowGroupSize=500&partitionBy=time",
+			dir:          "data",
+			rowGroupSize: 500,
+			partitionBy:  "time",
+		},
+		{name: "no directory", dns: "parquet://?rowGroupSize=1", err: true},
+		{name: "invalid row group size", dns: "parquet://data?rowGroupSize=0", err: true},
+		{name: "unknown scheme", dns: "csv://data", err: true},
+	} {
+		tcase := tcase
+
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			sink, err := parseConnectionString(tcase.dns)
+			if (err != nil) != tcase.err {
+				t.Fatalf("expected error %v, got %v", tcase.err, err)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if sink.dir != tcase.dir || sink.rowGroupSize != tcase.rowGroupSize ||
+				sink.partitionBy != tcase.partitionBy {
+				t.Fatalf("expected %q, %d and %q, got %q, %d and %q", tcase.dir, tcase.rowGroupSize,
+					tcase.partitionBy, sink.dir, sink.rowGroupSize, sink.partitionBy)
+			}
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	// Row groups of two records, so that the five records are written in three row groups.
+	sink, err := New(ctx, "parquet://"+dir+"?rowGroupSize=2")
+	if err != nil {
+		t.Fatalf("failed to open parquet storage: %v", err)
+	}
+
+	defer sink.Close()
+
+	data := []byte(`[
+		{"id": 1, "price": 1.5, "ok": true, "name": "a", "tags": ["x"], "meta": {"venue": "nyse"}, "mixed": 1},
+		{"id": 2, "price": 2, "ok": false, "name": "b", "mixed": "two"},
+		{"id": 3, "price": null, "ok": true, "name": "", "tags": [], "meta": {"venue": "lse"}},
+		{"id": 1099511627776, "ok": false},
+		{"id": 5, "price": -0.25, "name": "e"}
+	]`)
+
+	txn, err := sink.StartTx(ctx)
+	if err != nil {
+		t.Fatalf("failed to start transaction: %v", err)
+	}
+
+	txn.Send(func(sctx context.Context, stg proto.Storage) error {
+		_, err := stg.Upsert(sctx, &proto.UpsertRequest{Table: "trades", Data: data})
+
+		return err
+	})
+
+	if err := txn.Commit(); err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+
+	want := []map[string]interface{}{
+		{
+			"id": int64(1), "price": 1.5, "ok": true, "name": "a", "tags": []interface{}{"x"}, "meta.venue": "nyse",
+			"mixed": "1",
+		},
+		{"id": int64(2), "price": 2.0, "ok": false, "name": "b", "tags": nil, "meta.venue": nil, "mixed": "two"},
+		{
+			"id": int64(3), "price": nil, "ok": true, "name": "", "tags": []interface{}{}, "meta.venue": "lse",
+			"mixed": nil,
+		},
+		{
+			"id": int64(1099511627776), "price": nil, "ok": false, "name": nil, "tags": nil, "meta.venue": nil,
+			"mixed": nil,
+		},
+		{"id": int64(5), "price": -0.25, "ok": nil, "name": "e", "tags": nil, "meta.venue": nil, "mixed": nil},
+	}
+
+	if got := readRecords(ctx, t, sink, &proto.ReadRecordsRequest{Table: "trades"}); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected records %v, got %v", want, got)
+	}
+
+	file, err := os.Open(filepath.Join(dir, "trades", "part-000001.parquet"))
+	if err != nil {
+		t.Fatalf("failed to open file: %v", err)
+	}
+
+	defer file.Close()
+
+	meta, err := readFooter(file)
+	if err != nil {
+		t.Fatalf("failed to read footer: %v", err)
+	}
+
+	if rows, _ := meta.int(3); rows != 5 || len(meta.structList(4)) != 3 {
+		t.Fatalf("expected 5 rows in 3 row groups, got %d rows in %d", rows, len(meta.structList(4)))
+	}
+
+	ordered := readRecords(ctx, t, sink, &proto.ReadRecordsRequest{Table: "trades", OrderBy: "price"})
+	if ids := []interface{}{ordered[0]["id"], ordered[2]["id"], ordered[4]["id"]}; !reflect.DeepEqual(ids,
+		[]interface{}{int64(3), int64(5), int64(2)}) {
+		t.Fatalf("expected the records ordered by price, got %v", ordered)
+	}
+}
+
+func TestPartitionBy(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	dns := "parquet://" + dir + "?partitionBy=time"
+
+	sink, err := New(ctx, dns)
+	if err != nil {
+		t.Fatalf("failed to open parquet storage: %v", err)
+	}
+
+	upsert := func(sink *Parquet, data string) {
+		t.Helper()
+
+		if _, err := sink.Upsert(ctx, &proto.UpsertRequest{Table: "candles", Data: []byte(data)}); err != nil {
+			t.Fatalf("failed to upsert: %v", err)
+		}
+	}
+
+	upsert(sink, `[
+		{"time": "2022-01-02T23:00:00-02:00", "n": 1},
+		{"time": "2022-01-02T12:00:00Z", "n": 2},
+		{"time": 1641081600, "n": 3}
+	]`)
+	sink.Close()
+
+	// A new run continues the sequence of files.
+	if sink, err = New(ctx, dns); err != nil {
+		t.Fatalf("failed to reopen parquet storage: %v", err)
+	}
+
+	defer sink.Close()
+
+	upsert(sink, `[{"time":"later","n":4}]`)
+
+	for _, path := range []string{
+		"candles/date=2022-01-03/part-000001.parquet",
+		"candles/date=2022-01-02/part-000002.parquet",
+		"candles/date=__HIVE_DEFAULT_PARTITION__/part-000003.parquet",
+	} {
+		if _, err := os.Stat(filepath.Join(dir, path)); err != nil {
+			t.Fatalf("expected %s to be written: %v", path, err)
+		}
+	}
+
+	var ns []interface{}
+	for _, record := range readRecords(ctx, t, sink, &proto.ReadRecordsRequest{Table: "candles"}) {
+		ns = append(ns, record["n"])
+	}
+
+	if want := []interface{}{int64(2), int64(3), int64(1), int64(4)}; !reflect.DeepEqual(ns, want) {
+		t.Fatalf("expected the records in partition order %v, got %v", want, ns)
+	}
+
+	_, err = sink.Upsert(ctx, &proto.UpsertRequest{Table: "../candles", Data: []byte(`[{"n":1}]`)})
+	if !errors.Is(err, ErrInvalidTable) {
+		t.Fatalf("expected %v for a table outside the directory, got %v", ErrInvalidTable, err)
+	}
+}
+
+func TestThrift(t *testing.T) {
+	t.Parallel()
+
+	list := make([]interface{}, 20)
+	for idx := range list {
+		list[idx] = int32(idx - 10)
+	}
+
+	in := thriftStruct{
+		{id: 1, val: int32(-1)},
+		{id: 2, val: int64(1) << 40},
+		{id: 4, val: "name"},
+		{id: 30, val: thriftStruct{{id: 1, val: "nested"}}},
+		{id: 31, val: thriftList{elem: compactI32, vals: list}},
+		{id: 32, val: thriftList{elem: compactStruct, vals: []interface{}{thriftStruct{{id: 7, val: int32(7)}}}}},
+	}
+
+	file, err := os.CreateTemp(t.TempDir(), "thrift")
+	if err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+
+	defer file.Close()
+
+	if _, err := file.Write(appendThrift(nil, in)); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+
+	if _, err := file.Seek(0, 0); err != nil {
+		t.Fatalf("failed to seek: %v", err)
+	}
+
+	out, err := newThriftDecoder(file).readStruct(0)
+	if err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+
+	if val, _ := out.int(1); val != -1 {
+		t.Fatalf("expected field 1 to be -1, got %d", val)
+	}
+
+	if val, _ := out.int(2); val != 1<<40 {
+		t.Fatalf("expected field 2 to be %d, got %d", int64(1)<<40, val)
+	}
+
+	if out.str(4) != "name" || out.strct(30).str(1) != "nested" {
+		t.Fatalf("expected the binary fields to be decoded, got %v", out)
+	}
+
+	if vals := out.list(31); len(vals) != 20 || vals[0] != int64(-10) || vals[19] != int64(9) {
+		t.Fatalf("expected a list of 20 integers, got %v", vals)
+	}
+
+	if structs := out.structList(32); len(structs) != 1 {
+		t.Fatalf("expected a list of 1 struct, got %v", structs)
+	} else if val, _ := structs[0].int(7); val != 7 {
+		t.Fatalf("expected the struct in the list to be decoded, got %v", structs[0])
+	}
+}
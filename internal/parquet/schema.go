@@ -0,0 +1,243 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package parquet
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/alpstable/gidari/internal/proto"
+)
+
+// The physical types of Parquet columns.
+const (
+	typeBoolean   = 0
+	typeInt32     = 1
+	typeInt64     = 2
+	typeFloat     = 4
+	typeDouble    = 5
+	typeByteArray = 6
+)
+
+// The converted types of Parquet columns, which annotate how the physical type is interpreted.
+const (
+	convertedUTF8 = 0
+	convertedJSON = 19
+)
+
+// The repetition types of Parquet fields.
+const (
+	repetitionRequired = 0
+	repetitionOptional = 1
+)
+
+// maxSafeInteger is the largest integer that a float can hold exactly.
+const maxSafeInteger = 1 << 53
+
+// kind is the type of a column, inferred from the values of its records.
+type kind uint8
+
+const (
+	// kindNull is a column with no values, which is stored as a string column.
+	kindNull kind = iota
+	kindBool
+	kindInt
+	kindFloat
+	kindString
+
+	// kindJSON is a column of lists, which are stored as JSON text.
+	kindJSON
+)
+
+// kindOf returns the kind of a value in a record.
+func kindOf(val interface{}) kind {
+	switch val := val.(type) {
+	case nil:
+		return kindNull
+	case bool:
+		return kindBool
+	case json.Number:
+		if _, err := val.Int64(); err == nil && !strings.ContainsAny(val.String(), ".eE") {
+			return kindInt
+		}
+
+		return kindFloat
+	case float64:
+		if val == math.Trunc(val) && math.Abs(val) < maxSafeInteger {
+			return kindInt
+		}
+
+		return kindFloat
+	case string:
+		return kindString
+	default:
+		return kindJSON
+	}
+}
+
+// merge returns the kind of a column that has values of both kinds. Integers widen to floats, and any other mix of
+// kinds is stored as strings.
+func (k kind) merge(other kind) kind {
+	switch {
+	case k == other || other == kindNull:
+		return k
+	case k == kindNull:
+		return other
+	case (k == kindInt && other == kindFloat) || (k == kindFloat && other == kindInt):
+		return kindFloat
+	default:
+		return kindString
+	}
+}
+
+// column is a column of a Parquet file.
+type column struct {
+	name string
+	kind kind
+}
+
+// physicalType returns the Parquet type that the column is stored as.
+func (col column) physicalType() int32 {
+	switch col.kind {
+	case kindBool:
+		return typeBoolean
+	case kindInt:
+		return typeInt64
+	case kindFloat:
+		return typeDouble
+	default:
+		return typeByteArray
+	}
+}
+
+// element returns the schema element of the column. Every column is optional, since records can omit any field.
+func (col column) element() thriftStruct {
+	elem := thriftStruct{
+		{id: 1, val: col.physicalType()},
+		{id: 3, val: int32(repetitionOptional)},
+		{id: 4, val: col.name},
+	}
+
+	switch col.kind {
+	case kindNull, kindString:
+		elem = append(elem, thriftField{id: 6, val: int32(convertedUTF8)})
+	case kindJSON:
+		elem = append(elem, thriftField{id: 6, val: int32(convertedJSON)})
+	case kindBool, kindInt, kindFloat:
+	}
+
+	return elem
+}
+
+// schema is the columns of a Parquet file, in name order.
+type schema []column
+
+// schemaInferrer infers the columns of a file from the fields of its records, which have been flattened.
+type schemaInferrer map[string]kind
+
+func (inferrer schemaInferrer) observe(record map[string]interface{}) {
+	for name, val := range record {
+		inferrer[name] = inferrer[name].merge(kindOf(val))
+	}
+}
+
+func (inferrer schemaInferrer) schema() schema {
+	cols := make(schema, 0, len(inferrer))
+	for name, kind := range inferrer {
+		cols = append(cols, column{name: name, kind: kind})
+	}
+
+	sort.Slice(cols, func(i, j int) bool { return cols[i].name < cols[j].name })
+
+	return cols
+}
+
+// elements returns the schema elements of a file, starting with the root of the schema.
+func (cols schema) elements() []interface{} {
+	elems := []interface{}{thriftStruct{{id: 4, val: "schema"}, {id: 5, val: int32(len(cols))}}}
+	for _, col := range cols {
+		elems = append(elems, col.element())
+	}
+
+	return elems
+}
+
+// flattenRecord will flatten the nested objects of a record into columns named by their path, e.g. {"a": {"b": 1}}
+// has the column "a.b".
+func flattenRecord(prefix string, record map[string]interface{}, flat map[string]interface{}) {
+	for key, val := range record {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		if obj, ok := val.(map[string]interface{}); ok {
+			flattenRecord(key, obj, flat)
+
+			continue
+		}
+
+		flat[key] = val
+	}
+}
+
+// stringValue will format a value for a string column.
+func stringValue(val interface{}) ([]byte, error) {
+	switch val := val.(type) {
+	case string:
+		return []byte(val), nil
+	case bool:
+		return []byte(strconv.FormatBool(val)), nil
+	case float64:
+		return []byte(strconv.FormatFloat(val, 'f', -1, 64)), nil
+	case json.Number:
+		return []byte(val.String()), nil
+	default:
+		return jsonValue(val)
+	}
+}
+
+func jsonValue(val interface{}) ([]byte, error) {
+	data, err := json.Marshal(val)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", proto.ErrFailedToMarshalJSON, err)
+	}
+
+	return data, nil
+}
+
+// intValue returns the value of an integer column.
+func intValue(val interface{}) int64 {
+	switch val := val.(type) {
+	case json.Number:
+		num, _ := val.Int64()
+
+		return num
+	case float64:
+		return int64(val)
+	default:
+		return 0
+	}
+}
+
+// floatValue returns the value of a float column.
+func floatValue(val interface{}) float64 {
+	switch val := val.(type) {
+	case json.Number:
+		num, _ := val.Float64()
+
+		return num
+	case float64:
+		return val
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,354 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package parquet
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// ErrInvalidThrift is returned when the metadata of a Parquet file cannot be decoded.
+var ErrInvalidThrift = fmt.Errorf("invalid thrift metadata")
+
+// The types of the Thrift compact protocol, which encodes the metadata of Parquet files.
+const (
+	compactBoolTrue  = 0x01
+	compactBoolFalse = 0x02
+	compactByte      = 0x03
+	compactI16       = 0x04
+	compactI32       = 0x05
+	compactI64       = 0x06
+	compactDouble    = 0x07
+	compactBinary    = 0x08
+	compactList      = 0x09
+	compactSet       = 0x0a
+	compactMap       = 0x0b
+	compactStruct    = 0x0c
+)
+
+// maxThriftDepth guards the decoder against metadata that nests structs without end.
+const maxThriftDepth = 64
+
+// thriftField is a field of a Thrift struct. The value is an "int32", "int64", "string", "thriftStruct" or
+// "thriftList". Decoded integers are always "int64", and decoded binary values are always "string".
+type thriftField struct {
+	id  int16
+	val interface{}
+}
+
+// thriftStruct is a Thrift struct, with its fields in ascending order of ID.
+type thriftStruct []thriftField
+
+// thriftList is a Thrift list of values of a single compact type.
+type thriftList struct {
+	elem byte
+	vals []interface{}
+}
+
+// field returns the value of a field, or nil if the struct does not have it.
+func (st thriftStruct) field(id int16) interface{} {
+	for _, field := range st {
+		if field.id == id {
+			return field.val
+		}
+	}
+
+	return nil
+}
+
+// int returns the value of an integer field, and false if the struct does not have it.
+func (st thriftStruct) int(id int16) (int64, bool) {
+	switch val := st.field(id).(type) {
+	case int64:
+		return val, true
+	case int32:
+		return int64(val), true
+	default:
+		return 0, false
+	}
+}
+
+// str returns the value of a binary field.
+func (st thriftStruct) str(id int16) string {
+	str, _ := st.field(id).(string)
+
+	return str
+}
+
+// strct returns the value of a struct field, or nil if the struct does not have it.
+func (st thriftStruct) strct(id int16) thriftStruct {
+	nested, _ := st.field(id).(thriftStruct)
+
+	return nested
+}
+
+// list returns the values of a list field.
+func (st thriftStruct) list(id int16) []interface{} {
+	list, _ := st.field(id).(thriftList)
+
+	return list.vals
+}
+
+// structList returns the structs of a list field.
+func (st thriftStruct) structList(id int16) []thriftStruct {
+	vals := st.list(id)
+
+	structs := make([]thriftStruct, 0, len(vals))
+	for _, val := range vals {
+		if nested, ok := val.(thriftStruct); ok {
+			structs = append(structs, nested)
+		}
+	}
+
+	return structs
+}
+
+func zigzag(val int64) uint64 { return uint64(val<<1) ^ uint64(val>>63) }
+
+func unzigzag(val uint64) int64 { return int64(val>>1) ^ -int64(val&1) }
+
+// compactType returns the compact type of a value to be encoded.
+func compactType(val interface{}) byte {
+	switch val.(type) {
+	case int32:
+		return compactI32
+	case int64:
+		return compactI64
+	case string:
+		return compactBinary
+	case thriftList:
+		return compactList
+	default:
+		return compactStruct
+	}
+}
+
+// appendThrift will append the compact encoding of a value to "buf".
+func appendThrift(buf []byte, val interface{}) []byte {
+	switch val := val.(type) {
+	case int32:
+		return binary.AppendUvarint(buf, zigzag(int64(val)))
+	case int64:
+		return binary.AppendUvarint(buf, zigzag(val))
+	case string:
+		buf = binary.AppendUvarint(buf, uint64(len(val)))
+
+		return append(buf, val...)
+	case thriftList:
+		if len(val.vals) < 15 {
+			buf = append(buf, byte(len(val.vals))<<4|val.elem)
+		} else {
+			buf = append(buf, 0xf0|val.elem)
+			buf = binary.AppendUvarint(buf, uint64(len(val.vals)))
+		}
+
+		for _, elem := range val.vals {
+			buf = appendThrift(buf, elem)
+		}
+
+		return buf
+	case thriftStruct:
+		var last int16
+
+		for _, field := range val {
+			typ := compactType(field.val)
+
+			if delta := field.id - last; delta > 0 && delta <= 15 {
+				buf = append(buf, byte(delta)<<4|typ)
+			} else {
+				buf = append(buf, typ)
+				buf = binary.AppendUvarint(buf, zigzag(int64(field.id)))
+			}
+
+			buf = appendThrift(buf, field.val)
+			last = field.id
+		}
+
+		return append(buf, 0)
+	default:
+		panic(fmt.Sprintf("unsupported thrift value %T", val))
+	}
+}
+
+// thriftDecoder decodes the Thrift compact protocol.
+type thriftDecoder struct {
+	r *bufio.Reader
+}
+
+func newThriftDecoder(r io.Reader) *thriftDecoder {
+	if br, ok := r.(*bufio.Reader); ok {
+		return &thriftDecoder{r: br}
+	}
+
+	return &thriftDecoder{r: bufio.NewReader(r)}
+}
+
+func (dec *thriftDecoder) uvarint() (uint64, error) {
+	val, err := binary.ReadUvarint(dec.r)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidThrift, err)
+	}
+
+	return val, nil
+}
+
+func (dec *thriftDecoder) byte() (byte, error) {
+	val, err := dec.r.ReadByte()
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidThrift, err)
+	}
+
+	return val, nil
+}
+
+// readStruct will decode a struct, whose fields are in the order they were encoded.
+func (dec *thriftDecoder) readStruct(depth int) (thriftStruct, error) {
+	if depth > maxThriftDepth {
+		return nil, fmt.Errorf("%w: structs are nested too deeply", ErrInvalidThrift)
+	}
+
+	var (
+		st   thriftStruct
+		last int16
+	)
+
+	for {
+		header, err := dec.byte()
+		if err != nil {
+			return nil, err
+		}
+
+		if header == 0 {
+			return st, nil
+		}
+
+		typ := header & 0x0f
+
+		id := last + int16(header>>4)
+		if header>>4 == 0 {
+			raw, err := dec.uvarint()
+			if err != nil {
+				return nil, err
+			}
+
+			id = int16(unzigzag(raw))
+		}
+
+		var val interface{}
+
+		switch typ {
+		case compactBoolTrue, compactBoolFalse:
+			// The value of a boolean field is its type.
+			val = int64(2 - typ)
+		default:
+			if val, err = dec.readValue(typ, depth); err != nil {
+				return nil, err
+			}
+		}
+
+		st = append(st, thriftField{id: id, val: val})
+		last = id
+	}
+}
+
+// readValue will decode a value of a compact type. Booleans and bytes are decoded as "int64", and doubles are
+// decoded as "float64".
+func (dec *thriftDecoder) readValue(typ byte, depth int) (interface{}, error) {
+	switch typ {
+	case compactBoolTrue, compactBoolFalse, compactByte:
+		val, err := dec.byte()
+
+		return int64(val), err
+	case compactI16, compactI32, compactI64:
+		raw, err := dec.uvarint()
+
+		return unzigzag(raw), err
+	case compactDouble:
+		var raw [8]byte
+		if _, err := io.ReadFull(dec.r, raw[:]); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidThrift, err)
+		}
+
+		return math.Float64frombits(binary.LittleEndian.Uint64(raw[:])), nil
+	case compactBinary:
+		size, err := dec.uvarint()
+		if err != nil {
+			return nil, err
+		}
+
+		data := make([]byte, size)
+		if _, err := io.ReadFull(dec.r, data); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidThrift, err)
+		}
+
+		return string(data), nil
+	case compactList, compactSet:
+		return dec.readList(depth)
+	case compactMap:
+		return dec.readMap(depth)
+	case compactStruct:
+		return dec.readStruct(depth + 1)
+	default:
+		return nil, fmt.Errorf("%w: unknown type %d", ErrInvalidThrift, typ)
+	}
+}
+
+func (dec *thriftDecoder) readList(depth int) (interface{}, error) {
+	header, err := dec.byte()
+	if err != nil {
+		return nil, err
+	}
+
+	list := thriftList{elem: header & 0x0f}
+
+	size := uint64(header >> 4)
+	if size == 15 {
+		if size, err = dec.uvarint(); err != nil {
+			return nil, err
+		}
+	}
+
+	for idx := uint64(0); idx < size; idx++ {
+		val, err := dec.readValue(list.elem, depth)
+		if err != nil {
+			return nil, err
+		}
+
+		list.vals = append(list.vals, val)
+	}
+
+	return list, nil
+}
+
+// readMap will decode a map, which is skipped since Parquet metadata has no maps that are needed to read the data.
+func (dec *thriftDecoder) readMap(depth int) (interface{}, error) {
+	size, err := dec.uvarint()
+	if err != nil || size == 0 {
+		return nil, err
+	}
+
+	types, err := dec.byte()
+	if err != nil {
+		return nil, err
+	}
+
+	for idx := uint64(0); idx < size; idx++ {
+		if _, err := dec.readValue(types>>4, depth); err != nil {
+			return nil, err
+		}
+
+		if _, err := dec.readValue(types&0x0f, depth); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, nil
+}
@@ -10,6 +10,7 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math"
@@ -35,8 +36,19 @@ var (
 	ErrUnsupportedDataType   = fmt.Errorf("unsupported data type")
 	ErrFailedToMarshalJSON   = fmt.Errorf("failed to marshal json")
 	ErrFailedToUnmarshalJSON = fmt.Errorf("failed to unmarshal json")
+	ErrUnsupportedIdentifier = fmt.Errorf("unsupported identifier")
 )
 
+// columnTypes are the column types of the kinds of values inferred for a table.
+var columnTypes = map[string]string{
+	proto.KindBoolean:   "BOOLEAN",
+	proto.KindInteger:   "BIGINT",
+	proto.KindNumber:    "DOUBLE PRECISION",
+	proto.KindTimestamp: "TIMESTAMPTZ",
+	proto.KindString:    "TEXT",
+	proto.KindJSON:      "JSONB",
+}
+
 // postgresTxType is a type alias for the postgres transaction type.
 type postgresTxType uint8
 
@@ -104,29 +116,79 @@ func formatPlaceholders(numCols int, numRows int, symbol string) string {
 	return strBldr.String()
 }
 
-// flattenPartition will take a slice of structures, extract data from their fields, and append it to a slice.
-// This will "flatten" the data to be used in conjunctino with placeholders in a SQL query.
-func flattenPartition(columns []string, partition []*structpb.Struct) []interface{} {
-	var args []interface{}
-
+// flattenPartition will take a slice of structures, extract data from their fields, and append it to "args".
+// This will "flatten" the data to be used in conjunctino with placeholders in a SQL query. Nested objects and lists
+// are encoded as JSON, e.g. for "JSONB" columns.
+func flattenPartition(args []interface{}, columns []string, partition []*structpb.Struct) ([]interface{}, error) {
 	for _, record := range partition {
-		hash := record.AsMap()
+		fields := record.GetFields()
 		for _, column := range columns {
-			args = append(args, hash[column])
+			val := fields[column].AsInterface()
+
+			switch val.(type) {
+			case map[string]interface{}, []interface{}:
+				data, err := json.Marshal(val)
+				if err != nil {
+					return nil, fmt.Errorf("%w: %v", ErrFailedToMarshalJSON, err)
+				}
+
+				val = string(data)
+			}
+
+			args = append(args, val)
 		}
 	}
 
-	return args
+	return args, nil
 }
 
-// exclusionConstraints will return a string of non-primary key columns to "exclude" if they are not changed in the
-// context of a Postgres insert. That is, if a column is not changed, it will not be updated. All columns beside primary
-// keys must be included in the "excluded" clause.
-func (meta *pgmeta) exclusionConstraints(table string) []string {
+// createTableQuery will return a statement that creates the table of the request if it does not exist. Since upserts
+// refer to tables and columns by their unquoted names, which postgres folds to lower case, names with upper case
+// letters are not supported.
+func createTableQuery(req *proto.CreateTableRequest) (string, error) {
+	names := []string{req.Table}
+	for _, column := range req.Columns {
+		names = append(names, column.Name)
+	}
+
+	for _, name := range names {
+		if name != strings.ToLower(name) {
+			return "", fmt.Errorf("%w: %q must be lower case", ErrUnsupportedIdentifier, name)
+		}
+	}
+
+	defs := make([]string, 0, len(req.Columns)+1)
+
+	for _, column := range req.Columns {
+		def := pq.QuoteIdentifier(column.Name) + " " + proto.ColumnType(column, columnTypes)
+		if proto.IsConflictKey(req.PrimaryKeys, column.Name) {
+			def += " NOT NULL"
+		}
+
+		defs = append(defs, def)
+	}
+
+	if len(req.PrimaryKeys) > 0 {
+		pks := make([]string, len(req.PrimaryKeys))
+		for idx, pk := range req.PrimaryKeys {
+			pks[idx] = pq.QuoteIdentifier(pk)
+		}
+
+		defs = append(defs, fmt.Sprintf("PRIMARY KEY(%s)", strings.Join(pks, ",")))
+	}
+
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s(%s)", pq.QuoteIdentifier(req.Table), strings.Join(defs, ",")),
+		nil
+}
+
+// exclusionConstraints will return a string of columns that are not conflict keys to "exclude" if they are not
+// changed in the context of a Postgres insert. That is, if a column is not changed, it will not be updated. All
+// columns beside the conflict keys must be included in the "excluded" clause.
+func (meta *pgmeta) exclusionConstraints(table string, keys []string) []string {
 	var constraints []string
 
 	for _, column := range meta.cols[table] {
-		if !meta.isPK(table, column) {
+		if !proto.IsConflictKey(keys, column) {
 			constraints = append(constraints, fmt.Sprintf("\"%s\" = EXCLUDED.\"%s\"", column, column))
 		}
 	}
@@ -134,15 +196,32 @@ func (meta *pgmeta) exclusionConstraints(table string) []string {
 	return constraints
 }
 
-// upsertStatement will return a postgres upsert statement for the meta object.
-func (meta *pgmeta) upsertStmt(ctx context.Context, table string, pcf sqlPrepareContextFn, vol int) (*sql.Stmt, error) {
-	query := fmt.Sprintf(`INSERT INTO %s(%s) VALUES %s ON CONFLICT (%s) DO UPDATE SET %s`, table,
-		strings.Join(meta.cols[table], ","),
-		formatPlaceholders(len(meta.cols[table]), vol, "$"),
-		strings.Join(meta.pks[table], ","),
-		strings.Join(meta.exclusionConstraints(table), ","))
+// upsertQuery will return a postgres statement that writes "vol" records with the write mode. Records that conflict
+// on the "keys", which default to the primary key of the table, are updated by an upsert and skipped by an append.
+// Inserts, and writes to tables without a primary key, do not check for conflicts.
+func (meta *pgmeta) upsertQuery(table string, vol int, mode string, keys []string) string {
+	query := fmt.Sprintf(`INSERT INTO %s(%s) VALUES %s`, table, strings.Join(meta.cols[table], ","),
+		formatPlaceholders(len(meta.cols[table]), vol, "$"))
 
-	stmt, err := pcf(ctx, query)
+	keys = proto.ConflictKeysOrDefault(keys, meta.pks[table])
+	if len(keys) == 0 || mode == proto.WriteModeInsert {
+		return query
+	}
+
+	constraints := meta.exclusionConstraints(table, keys)
+	if len(constraints) == 0 || mode == proto.WriteModeAppend {
+		return fmt.Sprintf("%s ON CONFLICT (%s) DO NOTHING", query, strings.Join(keys, ","))
+	}
+
+	return fmt.Sprintf("%s ON CONFLICT (%s) DO UPDATE SET %s", query, strings.Join(keys, ","),
+		strings.Join(constraints, ","))
+}
+
+// upsertStmt will return a prepared postgres statement that writes "vol" records with the write mode.
+func (meta *pgmeta) upsertStmt(ctx context.Context, table string, pcf sqlPrepareContextFn, vol int, mode string,
+	keys []string,
+) (*sql.Stmt, error) {
+	stmt, err := pcf(ctx, meta.upsertQuery(table, vol, mode, keys))
 	if err != nil {
 		return nil, fmt.Errorf("unable to prepare statement: %w", err)
 	}
@@ -188,7 +267,13 @@ func (pg *Postgres) loadMeta(ctx context.Context, garbageC bool) error {
 		}
 	}
 
-	stmt, err := pg.DB.PrepareContext(ctx, string(pgColumns))
+	// Tables created by the transaction of the context are only visible to the transaction.
+	prepareContextFn, err := pg.getPrepareContextFn(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to get preparer: %w", err)
+	}
+
+	stmt, err := prepareContextFn(ctx, string(pgColumns))
 	if err != nil {
 		return fmt.Errorf("unable to prepare statement: %w", err)
 	}
@@ -228,6 +313,10 @@ func (pg *Postgres) loadMeta(ctx context.Context, garbageC bool) error {
 
 // Close will close the underlying database / transaction.
 func (pg *Postgres) Close() {
+	if pg.dbStmts != nil {
+		pg.dbStmts.Close()
+	}
+
 	if pg.DB != nil {
 		pg.DB.Close()
 	}
@@ -284,7 +373,7 @@ func (pg *Postgres) ListTables(ctx context.Context) (*proto.ListTablesResponse,
 	return rsp, nil
 }
 
-// Truncate will truncate a table.
+// Truncate will truncate the tables on the request, as part of the transaction of the context if it has one.
 func (pg *Postgres) Truncate(ctx context.Context, req *proto.TruncateRequest) (*proto.TruncateResponse, error) {
 	// If the table is not specified, return an error.
 	if len(req.Tables) == 0 {
@@ -296,7 +385,12 @@ func (pg *Postgres) Truncate(ctx context.Context, req *proto.TruncateRequest) (*
 		return nil, ErrNoTables
 	}
 
-	stmt, err := pg.DB.PrepareContext(ctx, fmt.Sprintf(string(pgTruncatedTables), strings.Join(tables, ",")))
+	prepareContextFn, err := pg.getPrepareContextFn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get preparer: %w", err)
+	}
+
+	stmt, err := prepareContextFn(ctx, fmt.Sprintf(string(pgTruncatedTables), strings.Join(tables, ",")))
 	if err != nil {
 		return nil, fmt.Errorf("unable to prepare statement: %w", err)
 	}
@@ -310,6 +404,124 @@ func (pg *Postgres) Truncate(ctx context.Context, req *proto.TruncateRequest) (*
 	return &proto.TruncateResponse{}, nil
 }
 
+// CreateTable will create the table of the request if it does not exist, as part of the transaction of the context
+// if it has one.
+func (pg *Postgres) CreateTable(ctx context.Context,
+	req *proto.CreateTableRequest,
+) (*proto.CreateTableResponse, error) {
+	pg.writeMutex.Lock()
+	defer pg.writeMutex.Unlock()
+
+	if err := pg.loadMeta(ctx, false); err != nil {
+		return nil, fmt.Errorf("unable to load postgres metadata: %w", err)
+	}
+
+	if _, ok := pg.meta.cols[req.Table]; ok {
+		return &proto.CreateTableResponse{}, nil
+	}
+
+	query, err := createTableQuery(req)
+	if err != nil {
+		return nil, err
+	}
+
+	prepareContextFn, err := pg.getPrepareContextFn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get preparer: %w", err)
+	}
+
+	stmt, err := prepareContextFn(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("unable to prepare statement: %w", err)
+	}
+
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		return nil, fmt.Errorf("unable to create table %q: %w", req.Table, err)
+	}
+
+	return &proto.CreateTableResponse{Created: true}, nil
+}
+
+// addColumnQuery will return a statement that adds a column to a table. Like the tables that are created, the name
+// of the column must be lower case.
+func addColumnQuery(table string, column *proto.Column) (string, error) {
+	if column.Name != strings.ToLower(column.Name) {
+		return "", fmt.Errorf("%w: %q must be lower case", ErrUnsupportedIdentifier, column.Name)
+	}
+
+	return fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s %s", pq.QuoteIdentifier(table),
+		pq.QuoteIdentifier(column.Name), proto.ColumnType(column, columnTypes)), nil
+}
+
+// AddColumns will add the columns of the request that the table does not have, as part of the transaction of the
+// context if it has one. Strict requests fail with the columns that the table does not have instead.
+func (pg *Postgres) AddColumns(ctx context.Context, req *proto.AddColumnsRequest) (*proto.AddColumnsResponse, error) {
+	pg.writeMutex.Lock()
+	defer pg.writeMutex.Unlock()
+
+	if err := pg.loadMeta(ctx, false); err != nil {
+		return nil, fmt.Errorf("unable to load postgres metadata: %w", err)
+	}
+
+	cols, ok := pg.meta.cols[req.Table]
+	if !ok {
+		return &proto.AddColumnsResponse{}, nil
+	}
+
+	missing := proto.MissingColumns(cols, req.Columns)
+	if len(missing) == 0 {
+		return &proto.AddColumnsResponse{}, nil
+	}
+
+	if req.Strict {
+		return nil, proto.UnknownColumnsError(req.Table, missing)
+	}
+
+	prepareContextFn, err := pg.getPrepareContextFn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get preparer: %w", err)
+	}
+
+	rsp := &proto.AddColumnsResponse{}
+
+	for _, column := range missing {
+		query, err := addColumnQuery(req.Table, column)
+		if err != nil {
+			return nil, err
+		}
+
+		stmt, err := prepareContextFn(ctx, query)
+		if err != nil {
+			return nil, fmt.Errorf("unable to prepare statement: %w", err)
+		}
+
+		if _, err := stmt.ExecContext(ctx); err != nil {
+			return nil, fmt.Errorf("unable to add column %q to table %q: %w", column.Name, req.Table, err)
+		}
+
+		rsp.Added = append(rsp.Added, column.Name)
+	}
+
+	return rsp, nil
+}
+
+// Read will call "fn" with every record in the table on the request.
+func (pg *Postgres) Read(ctx context.Context, req *proto.ReadRecordsRequest, fn proto.ReadFunc) error {
+	query := "SELECT * FROM " + pq.QuoteIdentifier(req.Table)
+	if req.OrderBy != "" {
+		query += " ORDER BY " + pq.QuoteIdentifier(req.OrderBy)
+	}
+
+	rows, err := pg.DB.QueryContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("unable to read table %q: %w", req.Table, err)
+	}
+
+	defer rows.Close()
+
+	return proto.ScanRows(rows, fn)
+}
+
 // getPrepareContextFn will return a function that can prepare an upsert statement for a given table.
 func (pg *Postgres) getPrepareContextFn(ctx context.Context) (sqlPrepareContextFn, error) {
 	// First check to see if a transaction has been assigned to the context. If it has, use the transaction.
@@ -329,26 +541,49 @@ func (pg *Postgres) getPrepareContextFn(ctx context.Context) (sqlPrepareContextF
 	return pg.DB.PrepareContext, nil
 }
 
-func (pg *Postgres) upsert(ctx context.Context, table string, records []*structpb.Struct) error {
-	prepareContextFn, err := pg.getPrepareContextFn(ctx)
-	if err != nil {
-		return fmt.Errorf("unable to get preparer: %w", err)
+// getStmtCache will return the cache of the statements prepared by the transaction assigned to the context, or by
+// the database if there is none. The write lock must be held.
+func (pg *Postgres) getStmtCache(ctx context.Context) *proto.StmtCache {
+	if txID, ok := ctx.Value(basicPostgressTxID).(string); ok {
+		if stored, ok := pg.stmts.Load(txID); ok {
+			if cache, ok := stored.(*proto.StmtCache); ok {
+				return cache
+			}
+		}
+	}
+
+	if pg.dbStmts == nil {
+		pg.dbStmts = proto.NewStmtCache(pg.DB.PrepareContext)
 	}
 
+	return pg.dbStmts
+}
+
+func (pg *Postgres) upsert(ctx context.Context, table string, records []*structpb.Struct, mode string,
+	keys []string,
+) error {
+	cache := pg.getStmtCache(ctx)
+
 	if err := pg.loadMeta(ctx, false); err != nil {
 		return fmt.Errorf("unable to load postgres metadata: %w", err)
 	}
 
 	// Upsert 1000 records at a time, the maximum number of records that can be inserted in a single statement on a
-	// postgres database.
+	// postgres database. Every full partition of the table reuses the statement that the first one prepared.
 	for _, partition := range proto.PartitionStructs(defaultPartitionSize, records) {
-		stmt, err := pg.meta.upsertStmt(ctx, table, prepareContextFn, len(partition))
+		stmt, err := pg.meta.upsertStmt(ctx, table, cache.Prepare, len(partition), mode, keys)
 		if err != nil {
 			return fmt.Errorf("unable to prepare statement: %w", err)
 		}
 
 		// Execute upsert.
-		arguments := flattenPartition(pg.meta.cols[table], partition)
+		columns := pg.meta.cols[table]
+
+		arguments, err := flattenPartition(cache.Args(len(columns)*len(partition)), columns, partition)
+		if err != nil {
+			return err
+		}
+
 		if _, err := stmt.ExecContext(ctx, arguments...); err != nil {
 			return fmt.Errorf("unable to execute upsert: %w", err)
 		}
@@ -375,7 +610,7 @@ func (pg *Postgres) Upsert(ctx context.Context, req *proto.UpsertRequest) (*prot
 	}
 
 	table := req.GetTable()
-	if err := pg.upsert(ctx, table, records); err != nil {
+	if err := pg.upsert(ctx, table, records, req.GetWriteMode(), req.GetConflictKeys()); err != nil {
 		return nil, fmt.Errorf("unable to upsert: %w", err)
 	}
 
@@ -399,6 +634,11 @@ type Postgres struct {
 	// the method. The transaction ID is added to the context in the "StartTx" method. The transaction ID is
 	// removed from the context in the "CommitTx" and "RollbackTx" methods.
 	activeTx sync.Map
+
+	// stmts are the upsert statements prepared by the active transactions, keyed by transaction ID, and dbStmts
+	// are the ones prepared by the database for writes outside of a transaction.
+	stmts   sync.Map
+	dbStmts *proto.StmtCache
 }
 
 // New will return a new Postgres option for querying data through a Postgres DB.
@@ -486,14 +726,16 @@ func (pg *Postgres) StartTx(ctx context.Context) (*proto.Txn, error) {
 	}
 
 	pg.activeTx.Store(txnID, pgtx)
+	pg.stmts.Store(txnID, proto.NewStmtCache(pgtx.PrepareContext))
 
 	// Create a copy of the parent context with a transaction ID.
 	pgCtx := context.WithValue(ctx, basicPostgressTxID, txnID)
 
 	go func() {
 		defer func() {
-			// Remove the transaction from the activeTx map.
+			// Remove the transaction from the activeTx map. Its statements are closed with it.
 			pg.activeTx.Delete(txnID)
+			pg.stmts.Delete(txnID)
 		}()
 
 		for fn := range txn.FunctionCh {
@@ -539,7 +781,7 @@ func (pg *Postgres) UpsertBinary(ctx context.Context,
 	}
 
 	table := req.GetTable()
-	if err := pg.upsert(ctx, table, records); err != nil {
+	if err := pg.upsert(ctx, table, records, req.GetWriteMode(), req.GetConflictKeys()); err != nil {
 		return nil, fmt.Errorf("unable to upsert: %w", err)
 	}
 
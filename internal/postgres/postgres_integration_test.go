@@ -144,6 +144,8 @@ func TestPostgres(t *testing.T) {
 			}...,
 		)
 
+		runner.AddTruncateTxnCases(proto.TestCase{Name: "postgres", Table: defaultTestTable, Data: defaultData})
+
 		runner.AddPingCases(
 			[]proto.TestCase{
 				{
@@ -12,9 +12,12 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"sync"
 	"testing"
+
+	"github.com/alpstable/gidari/internal/proto"
 )
 
 func TestPGMeta(t *testing.T) {
@@ -145,10 +148,108 @@ func TestPGMeta(t *testing.T) {
 				return &sql.Stmt{}, nil
 			}
 
-			_, err := pdb.meta.upsertStmt(ctx, test.tableName, mockPCF, 1)
+			_, err := pdb.meta.upsertStmt(ctx, test.tableName, mockPCF, 1, "", nil)
 			if err != nil {
 				t.Fatalf("failed to create upsert statement: %v", err)
 			}
 		})
 	}
 }
+
+func TestUpsertQueryWriteModes(t *testing.T) {
+	t.Parallel()
+
+	meta := &pgmeta{
+		cols: map[string][]string{"trades": {"id", "code", "price"}, "logs": {"msg"}},
+		pks:  map[string][]string{"trades": {"id"}},
+	}
+
+	for _, tcase := range []struct {
+		name  string
+		table string
+		mode  string
+		keys  []string
+		want  string
+	}{
+		{
+			name:  "upsert",
+			table: "trades",
+			want: `INSERT INTO trades(id,code,price) VALUES ($1,$2,$3) ON CONFLICT (id) DO UPDATE SET ` +
+				`"code" = EXCLUDED."code","price" = EXCLUDED."price"`,
+		},
+		{
+			name:  "upsert on conflict keys",
+			table: "trades",
+			mode:  proto.WriteModeUpsert,
+			keys:  []string{"code"},
+			want: `INSERT INTO trades(id,code,price) VALUES ($1,$2,$3) ON CONFLICT (code) DO UPDATE SET ` +
+				`"id" = EXCLUDED."id","price" = EXCLUDED."price"`,
+		},
+		{
+			name:  "insert",
+			table: "trades",
+			mode:  proto.WriteModeInsert,
+			want:  `INSERT INTO trades(id,code,price) VALUES ($1,$2,$3)`,
+		},
+		{
+			name:  "append",
+			table: "trades",
+			mode:  proto.WriteModeAppend,
+			want:  `INSERT INTO trades(id,code,price) VALUES ($1,$2,$3) ON CONFLICT (id) DO NOTHING`,
+		},
+		{
+			name:  "no primary key",
+			table: "logs",
+			want:  `INSERT INTO logs(msg) VALUES ($1)`,
+		},
+	} {
+		tcase := tcase
+
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := meta.upsertQuery(tcase.table, 1, tcase.mode, tcase.keys); got != tcase.want {
+				t.Fatalf("expected %q, got %q", tcase.want, got)
+			}
+		})
+	}
+}
+
+func TestCreateTableQuery(t *testing.T) {
+	t.Parallel()
+
+	columns := []*proto.Column{{Name: "id", Kind: proto.KindInteger}, {Name: "meta", Kind: proto.KindJSON}}
+
+	got, err := createTableQuery(&proto.CreateTableRequest{Table: "trades", Columns: columns, PrimaryKeys: []string{"id"}})
+	if err != nil {
+		t.Fatalf("failed to build query: %v", err)
+	}
+
+	want := `CREATE TABLE IF NOT EXISTS "trades"("id" BIGINT NOT NULL,"meta" JSONB,PRIMARY KEY("id"))`
+	if got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+
+	columns = append(columns, &proto.Column{Name: "closeTime", Kind: proto.KindTimestamp})
+	if _, err := createTableQuery(&proto.CreateTableRequest{Table: "trades", Columns: columns}); !errors.Is(err,
+		ErrUnsupportedIdentifier) {
+		t.Fatalf("expected an error for an upper case column, got %v", err)
+	}
+}
+
+func TestAddColumnQuery(t *testing.T) {
+	t.Parallel()
+
+	got, err := addColumnQuery("trades", &proto.Column{Name: "fee", Kind: proto.KindNumber})
+	if err != nil {
+		t.Fatalf("failed to build query: %v", err)
+	}
+
+	if want := `ALTER TABLE "trades" ADD COLUMN IF NOT EXISTS "fee" DOUBLE PRECISION`; got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+
+	if _, err := addColumnQuery("trades", &proto.Column{Name: "tradeId"}); !errors.Is(err, ErrUnsupportedIdentifier) {
+		t.Fatalf("expected an error for an upper case column, got %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package proto
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// ReadRecordsRequest is the request to read the records of a table back out of a storage device.
+type ReadRecordsRequest struct {
+	// Table is the table, or collection, to read.
+	Table string
+
+	// OrderBy is the column to sort the records by, in ascending order. If it is empty, the records are read in
+	// whatever order the storage device returns them.
+	OrderBy string
+}
+
+// ReadFunc is called with every record that is read from a storage device. Returning an error stops the read.
+type ReadFunc func(record map[string]interface{}) error
+
+// LessValue orders the values of records that are read back out of files. Missing values are first, numbers are
+// compared numerically, and everything else is compared as a string.
+func LessValue(left, right interface{}) bool {
+	if left == nil || right == nil {
+		return left == nil && right != nil
+	}
+
+	leftNum, leftOK := numericValue(left)
+	rightNum, rightOK := numericValue(right)
+
+	if leftOK && rightOK {
+		return leftNum < rightNum
+	}
+
+	return fmt.Sprint(left) < fmt.Sprint(right)
+}
+
+// numericValue returns the value of a number read from a file as a float.
+func numericValue(val interface{}) (float64, bool) {
+	switch val := val.(type) {
+	case float64:
+		return val, true
+	case float32:
+		return float64(val), true
+	case int64:
+		return float64(val), true
+	case int32:
+		return float64(val), true
+	default:
+		return 0, false
+	}
+}
+
+// ScanRows will call "fn" with every row of a SQL result as a record keyed by column name. Text and blob values are
+// returned as strings.
+func ScanRows(rows *sql.Rows, fn ReadFunc) error {
+	columns, err := rows.Columns()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrFailedToGetColumns, err)
+	}
+
+	values := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+
+	for idx := range values {
+		pointers[idx] = &values[idx]
+	}
+
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			return fmt.Errorf("%w: %v", ErrFailedToScanRow, err)
+		}
+
+		record := make(map[string]interface{}, len(columns))
+
+		for idx, column := range columns {
+			if b, ok := values[idx].([]byte); ok {
+				record[column] = string(b)
+
+				continue
+			}
+
+			record[column] = values[idx]
+		}
+
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("%w: %v", ErrFailedToScanRow, err)
+	}
+
+	return nil
+}
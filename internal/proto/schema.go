@@ -0,0 +1,244 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package proto
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	structpb "google.golang.org/protobuf/types/known/structpb"
+)
+
+// The kinds of values that are inferred for the columns of a table. Storage devices map each kind to a column type,
+// which can be overridden by the type mapping of a configuration.
+const (
+	KindBoolean   = "boolean"
+	KindInteger   = "integer"
+	KindNumber    = "number"
+	KindTimestamp = "timestamp"
+	KindString    = "string"
+	KindJSON      = "json"
+)
+
+// IsKind returns true if "kind" is one of the kinds of values that are inferred for a column.
+func IsKind(kind string) bool {
+	switch kind {
+	case KindBoolean, KindInteger, KindNumber, KindTimestamp, KindString, KindJSON:
+		return true
+	default:
+		return false
+	}
+}
+
+// Column is a column of a table to create.
+type Column struct {
+	// Name is the name of the column.
+	Name string
+
+	// Kind is the kind of the values of the column, which is mapped to the default column type of the storage
+	// device.
+	Kind string
+
+	// Type is the column type to create the column with, which takes precedence over the kind.
+	Type string
+}
+
+// CreateTableRequest is the request to create a table that does not exist.
+type CreateTableRequest struct {
+	// Table is the name of the table to create.
+	Table string
+
+	// Columns are the columns of the table.
+	Columns []*Column
+
+	// PrimaryKeys are the columns of the primary key of the table, if any.
+	PrimaryKeys []string
+}
+
+// CreateTableResponse is the response of creating a table.
+type CreateTableResponse struct {
+	// Created is false if the table already existed.
+	Created bool
+}
+
+// TableCreator is implemented by storage devices that need a table to exist before they can write to it.
+type TableCreator interface {
+	// CreateTable will create the table of the request if it does not already exist.
+	CreateTable(context.Context, *CreateTableRequest) (*CreateTableResponse, error)
+}
+
+// ErrUnknownColumns is returned when the records of a write have columns that the table does not have, and the schema
+// of the table is not evolved to add them.
+var ErrUnknownColumns = fmt.Errorf("unknown columns")
+
+// AddColumnsRequest is the request to add the columns that a table does not have.
+type AddColumnsRequest struct {
+	// Table is the name of the table to add the columns to.
+	Table string
+
+	// Columns are the columns of the records that are written to the table, some of which it may already have.
+	Columns []*Column
+
+	// Strict will fail the request with "ErrUnknownColumns" rather than add the columns that the table does not
+	// have.
+	Strict bool
+}
+
+// AddColumnsResponse is the response of adding columns to a table.
+type AddColumnsResponse struct {
+	// Added are the names of the columns that were added to the table.
+	Added []string
+}
+
+// ColumnAdder is implemented by storage devices whose tables need a column to exist before they can write its
+// values, so that the columns of new fields can be added to a table rather than dropped.
+type ColumnAdder interface {
+	// AddColumns will add the columns of the request that the table does not have. A table that does not exist is
+	// left for the write to fail on.
+	AddColumns(context.Context, *AddColumnsRequest) (*AddColumnsResponse, error)
+}
+
+// MissingColumns returns the columns that are not one of the "existing" columns of a table.
+func MissingColumns(existing []string, columns []*Column) []*Column {
+	names := make(map[string]bool, len(existing))
+	for _, name := range existing {
+		names[name] = true
+	}
+
+	var missing []*Column
+
+	for _, column := range columns {
+		if !names[column.Name] {
+			missing = append(missing, column)
+		}
+	}
+
+	return missing
+}
+
+// UnknownColumnsError wraps "ErrUnknownColumns" with the columns that a table does not have.
+func UnknownColumnsError(table string, columns []*Column) error {
+	names := make([]string, len(columns))
+	for idx, column := range columns {
+		names[idx] = column.Name
+	}
+
+	return fmt.Errorf("%w: table %q does not have %s", ErrUnknownColumns, table, strings.Join(names, ", "))
+}
+
+// ExpiryRequest is the request to expire the records of a table a duration after the time in one of their columns.
+type ExpiryRequest struct {
+	// Table is the name of the table whose records expire.
+	Table string
+
+	// Column is the timestamp column that the records expire after.
+	Column string
+
+	// After is how long after the time of its column a record expires.
+	After time.Duration
+}
+
+// ExpiryResponse is the response of configuring the expiry of a table.
+type ExpiryResponse struct {
+	// Supported is false if the storage device does not expire records.
+	Supported bool
+
+	// Changed is false if the table already expired its records the same way.
+	Changed bool
+}
+
+// ExpiryConfigurer is implemented by storage devices that expire records natively, e.g. with TTL indexes.
+type ExpiryConfigurer interface {
+	// ConfigureExpiry will configure the table of the request to expire its records, replacing any expiry it has on
+	// the column.
+	ConfigureExpiry(context.Context, *ExpiryRequest) (*ExpiryResponse, error)
+}
+
+// ValueKind returns the kind of a record value, and false for null values which have no kind.
+func ValueKind(val *structpb.Value) (string, bool) {
+	switch kind := val.GetKind().(type) {
+	case *structpb.Value_BoolValue:
+		return KindBoolean, true
+	case *structpb.Value_NumberValue:
+		if kind.NumberValue == math.Trunc(kind.NumberValue) && math.Abs(kind.NumberValue) < 1<<53 {
+			return KindInteger, true
+		}
+
+		return KindNumber, true
+	case *structpb.Value_StringValue:
+		if _, err := time.Parse(time.RFC3339Nano, kind.StringValue); err == nil {
+			return KindTimestamp, true
+		}
+
+		return KindString, true
+	case *structpb.Value_StructValue, *structpb.Value_ListValue:
+		return KindJSON, true
+	default:
+		return "", false
+	}
+}
+
+// MergeKinds returns the kind of a column whose values are of two kinds. Integers widen to numbers, and any other
+// mix of kinds is stored as a string.
+func MergeKinds(left, right string) string {
+	switch {
+	case left == "" || left == right:
+		return right
+	case right == "":
+		return left
+	case (left == KindInteger && right == KindNumber) || (left == KindNumber && right == KindInteger):
+		return KindNumber
+	default:
+		return KindString
+	}
+}
+
+// InferColumns will infer the columns of a table from a sample of its records, sorted by name. Columns that are
+// null in every record of the sample are strings.
+func InferColumns(records []*structpb.Struct) []*Column {
+	kinds := make(map[string]string)
+
+	for _, record := range records {
+		for name, val := range record.GetFields() {
+			// Null values have no kind, but still add the column.
+			kind, _ := ValueKind(val)
+			kinds[name] = MergeKinds(kinds[name], kind)
+		}
+	}
+
+	columns := make([]*Column, 0, len(kinds))
+
+	for name, kind := range kinds {
+		if kind == "" {
+			kind = KindString
+		}
+
+		columns = append(columns, &Column{Name: name, Kind: kind})
+	}
+
+	sort.Slice(columns, func(i, j int) bool { return columns[i].Name < columns[j].Name })
+
+	return columns
+}
+
+// ColumnType returns the column type to create a column with: its explicit type, or the default type of its kind.
+func ColumnType(column *Column, defaults map[string]string) string {
+	if column.Type != "" {
+		return column.Type
+	}
+
+	if typ, ok := defaults[column.Kind]; ok {
+		return typ
+	}
+
+	return defaults[KindString]
+}
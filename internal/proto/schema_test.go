@@ -0,0 +1,82 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package proto
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestInferColumns(t *testing.T) {
+	t.Parallel()
+
+	req := &UpsertRequest{Data: []byte(`[
+		{"id": 1, "price": 10, "at": "2022-05-01T00:00:00Z", "ok": true, "tags": ["a"], "note": null, "mixed": 1},
+		{"id": 2, "price": 10.5, "at": "2022-05-02T00:00:00Z", "ok": false, "meta": {"a": 1}, "mixed": "x"}
+	]`)}
+
+	records, err := DecodeUpsertRequest(req)
+	if err != nil {
+		t.Fatalf("failed to decode records: %v", err)
+	}
+
+	want := []*Column{
+		{Name: "at", Kind: KindTimestamp},
+		{Name: "id", Kind: KindInteger},
+		{Name: "meta", Kind: KindJSON},
+		{Name: "mixed", Kind: KindString},
+		{Name: "note", Kind: KindString},
+		{Name: "ok", Kind: KindBoolean},
+		{Name: "price", Kind: KindNumber},
+		{Name: "tags", Kind: KindJSON},
+	}
+
+	if got := InferColumns(records); !reflect.DeepEqual(got, want) {
+		for _, column := range got {
+			t.Logf("%+v", column)
+		}
+
+		t.Fatal("unexpected columns")
+	}
+}
+
+func TestColumnType(t *testing.T) {
+	t.Parallel()
+
+	defaults := map[string]string{KindInteger: "BIGINT", KindString: "TEXT"}
+
+	for _, tcase := range []struct {
+		column *Column
+		want   string
+	}{
+		{column: &Column{Kind: KindInteger}, want: "BIGINT"},
+		{column: &Column{Kind: KindInteger, Type: "SMALLINT"}, want: "SMALLINT"},
+		{column: &Column{Kind: KindJSON}, want: "TEXT"},
+	} {
+		if got := ColumnType(tcase.column, defaults); got != tcase.want {
+			t.Fatalf("expected %q for %+v, got %q", tcase.want, tcase.column, got)
+		}
+	}
+}
+
+func TestMissingColumns(t *testing.T) {
+	t.Parallel()
+
+	columns := []*Column{{Name: "id", Kind: KindInteger}, {Name: "fee", Kind: KindNumber}, {Name: "side"}}
+
+	missing := MissingColumns([]string{"id", "price"}, columns)
+	if want := columns[1:]; !reflect.DeepEqual(missing, want) {
+		t.Fatalf("expected %+v, got %+v", want, missing)
+	}
+
+	err := UnknownColumnsError("trades", missing)
+	if !errors.Is(err, ErrUnknownColumns) || err.Error() != `unknown columns: table "trades" does not have fee, side` {
+		t.Fatalf("expected the unknown columns to be listed, got %v", err)
+	}
+}
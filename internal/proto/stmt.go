@@ -0,0 +1,82 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package proto
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// PrepareFunc prepares a SQL statement, e.g. "(*sql.Tx).PrepareContext".
+type PrepareFunc func(context.Context, string) (*sql.Stmt, error)
+
+// StmtCache holds the statements that a transaction, or a database, has prepared, keyed by their query. Upserts to a
+// table with the same columns, number of records and write mode have the same query, so every partition of a batch
+// reuses the statement rather than preparing it again. The cache also holds the buffer of the arguments of the
+// statements, which is reused between partitions.
+//
+// A cache is not safe for concurrent use, which the write lock of the SQL repositories prevents.
+type StmtCache struct {
+	prepare PrepareFunc
+	stmts   map[string]*sql.Stmt
+	args    []interface{}
+}
+
+// NewStmtCache returns an empty cache of the statements prepared with "prepare".
+func NewStmtCache(prepare PrepareFunc) *StmtCache {
+	return &StmtCache{prepare: prepare, stmts: make(map[string]*sql.Stmt)}
+}
+
+// Prepare will return the statement of the query, preparing it if it has not been prepared yet.
+func (cache *StmtCache) Prepare(ctx context.Context, query string) (*sql.Stmt, error) {
+	if stmt, ok := cache.stmts[query]; ok {
+		return stmt, nil
+	}
+
+	stmt, err := cache.prepare(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("unable to prepare statement: %w", err)
+	}
+
+	cache.stmts[query] = stmt
+
+	return stmt, nil
+}
+
+// Len returns the number of statements in the cache.
+func (cache *StmtCache) Len() int {
+	return len(cache.stmts)
+}
+
+// Args returns the empty buffer of the arguments of a statement, with room for "size" arguments. The buffer is
+// overwritten by the next call, so the arguments must be used before then.
+func (cache *StmtCache) Args(size int) []interface{} {
+	if cap(cache.args) < size {
+		cache.args = make([]interface{}, 0, size)
+	}
+
+	// The arguments of the last statement are cleared so that their values can be collected.
+	args := cache.args[:cap(cache.args)]
+	for idx := range args {
+		args[idx] = nil
+	}
+
+	return cache.args[:0]
+}
+
+// Close will close the statements of the cache. The statements of a transaction are closed when it is committed or
+// rolled back, so only the caches of databases need to be closed.
+func (cache *StmtCache) Close() {
+	for query, stmt := range cache.stmts {
+		stmt.Close()
+		delete(cache.stmts, query)
+	}
+
+	cache.args = nil
+}
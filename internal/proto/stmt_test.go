@@ -0,0 +1,60 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package proto
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestStmtCache(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	errPrepare := fmt.Errorf("syntax error")
+
+	var prepared []string
+
+	cache := NewStmtCache(func(_ context.Context, query string) (*sql.Stmt, error) {
+		if query == "invalid" {
+			return nil, errPrepare
+		}
+
+		prepared = append(prepared, query)
+
+		return new(sql.Stmt), nil
+	})
+
+	for _, query := range []string{"INSERT 1", "INSERT 2", "INSERT 1"} {
+		if _, err := cache.Prepare(ctx, query); err != nil {
+			t.Fatalf("failed to prepare %q: %v", query, err)
+		}
+	}
+
+	if len(prepared) != 2 || cache.Len() != 2 {
+		t.Fatalf("expected 2 statements to be prepared and cached, got %d and %d", len(prepared), cache.Len())
+	}
+
+	if _, err := cache.Prepare(ctx, "invalid"); !errors.Is(err, errPrepare) || cache.Len() != 2 {
+		t.Fatalf("expected an invalid statement to fail and not be cached, got %v", err)
+	}
+
+	args := append(cache.Args(3), 1, "a", true)
+	reused := cache.Args(2)
+
+	if cap(reused) != cap(args) || len(reused) != 0 || &reused[:1][0] != &args[0] || args[0] != nil {
+		t.Fatalf("expected the buffer of the arguments to be emptied and reused")
+	}
+
+	if grown := cache.Args(10); cap(grown) < 10 || len(grown) != 0 {
+		t.Fatalf("expected the buffer to grow to 10 arguments, got %d", cap(grown))
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync"
 )
 
 const (
@@ -19,8 +20,85 @@ const (
 
 	// MongoType is the byte representation of a mongo database.
 	MongoType = 0x02
+
+	// SQLiteType is the byte representation of a sqlite database.
+	SQLiteType = 0x03
+
+	// MySQLType is the byte representation of a mysql, or mariadb, database.
+	MySQLType = 0x04
+
+	// NDJSONType is the byte representation of a directory of newline-delimited JSON files.
+	NDJSONType = 0x05
+
+	// CSVType is the byte representation of a directory of CSV files.
+	CSVType = 0x06
+
+	// ParquetType is the byte representation of a directory of Parquet files.
+	ParquetType = 0x07
+
+	// S3Type is the byte representation of an Amazon S3 bucket, or a bucket of any S3 compatible object storage.
+	S3Type = 0x08
+
+	// GCSType is the byte representation of a Google Cloud Storage bucket.
+	GCSType = 0x09
+
+	// ElasticsearchType is the byte representation of an Elasticsearch cluster.
+	ElasticsearchType = 0x0A
+
+	// OpenSearchType is the byte representation of an OpenSearch cluster.
+	OpenSearchType = 0x0B
+
+	// BigQueryType is the byte representation of a BigQuery dataset.
+	BigQueryType = 0x0C
+
+	// ClickHouseType is the byte representation of a ClickHouse database.
+	ClickHouseType = 0x0D
+
+	// ExternalType is the byte representation of the first storage device that is registered with
+	// "RegisterExternalType", e.g. by a backend outside of this module. Later registrations follow it.
+	ExternalType = 0x80
+)
+
+// ErrStorageTypesExhausted is returned when every byte representation of an external storage device is registered.
+var ErrStorageTypesExhausted = fmt.Errorf("no storage types left to register")
+
+var (
+	externalTypesMu sync.RWMutex
+	externalTypes   []string
 )
 
+// RegisterExternalType will allocate a byte representation for the storage devices of "scheme", so that
+// "SchemeFromStorageType" returns the scheme for it. Registering a scheme again returns the same type.
+func RegisterExternalType(scheme string) (uint8, error) {
+	externalTypesMu.Lock()
+	defer externalTypesMu.Unlock()
+
+	for idx, registered := range externalTypes {
+		if registered == scheme {
+			return uint8(ExternalType + idx), nil
+		}
+	}
+
+	if ExternalType+len(externalTypes) > 0xFF {
+		return 0, fmt.Errorf("%w: %s", ErrStorageTypesExhausted, scheme)
+	}
+
+	externalTypes = append(externalTypes, scheme)
+
+	return uint8(ExternalType + len(externalTypes) - 1), nil
+}
+
+// IsBuiltinScheme returns true if "scheme" is the scheme of a storage device of this module.
+func IsBuiltinScheme(scheme string) bool {
+	for t := uint8(PostgresType); t <= ClickHouseType; t++ {
+		if SchemeFromStorageType(t) == scheme {
+			return true
+		}
+	}
+
+	return false
+}
+
 var ErrDNSNotSupported = fmt.Errorf("dns is not supported")
 
 // DNSNotSupported wraps an error with ErrDNSNotSupported.
@@ -42,6 +120,9 @@ type Storage interface {
 	// IsNoSQL will return true if the storage device is a NoSQL database.
 	IsNoSQL() bool
 
+	// Read will call "fn" with every record in the table of the request.
+	Read(ctx context.Context, req *ReadRecordsRequest, fn ReadFunc) error
+
 	// StartTx will start a transaction and return a "Tx" object that can be used to put operations on a channel,
 	// commit the result of all operations sent to the transaction, or rollback the result of all operations sent
 	// to the transaction.
@@ -76,9 +157,43 @@ func SchemeFromStorageType(t uint8) string {
 		return "mongodb"
 	case PostgresType:
 		return "postgresql"
+	case SQLiteType:
+		return "sqlite"
+	case MySQLType:
+		return "mysql"
+	case NDJSONType:
+		return "ndjson"
+	case CSVType:
+		return "csv"
+	case ParquetType:
+		return "parquet"
+	case S3Type:
+		return "s3"
+	case GCSType:
+		return "gs"
+	case ElasticsearchType:
+		return "elasticsearch"
+	case OpenSearchType:
+		return "opensearch"
+	case BigQueryType:
+		return "bigquery"
+	case ClickHouseType:
+		return "clickhouse"
 	default:
+		return externalScheme(t)
+	}
+}
+
+// externalScheme returns the scheme that the byte representation of an external storage device was registered for.
+func externalScheme(t uint8) string {
+	externalTypesMu.RLock()
+	defer externalTypesMu.RUnlock()
+
+	if t < ExternalType || int(t-ExternalType) >= len(externalTypes) {
 		return "unknown"
 	}
+
+	return externalTypes[t-ExternalType]
 }
 
 // SchemeFromConnectionString will return the scheme of a DNS.
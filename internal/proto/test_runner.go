@@ -81,6 +81,7 @@ type TestRunner struct {
 	listTablesCases      []TestCase
 	upsertTxnCases       []TestCase
 	upsertBinaryCases    []TestCase
+	truncateTxnCases     []TestCase
 	pingCases            []TestCase
 	Mutex                *sync.Mutex
 	Storage              Storage
@@ -105,6 +106,7 @@ func (runner TestRunner) Run(ctx context.Context, t *testing.T) {
 	runner.listPrimaryKeys(ctx, t)
 	runner.upsertTxn(ctx, t)
 	runner.upsertBinary(ctx, t)
+	runner.truncateTxn(ctx, t)
 	runner.ping(ctx, t)
 }
 
@@ -163,6 +165,14 @@ func (runner *TestRunner) AddUpsertBinaryCases(cases ...TestCase) {
 	runner.upsertBinaryCases = append(runner.upsertBinaryCases, cases...)
 }
 
+// AddTruncateTxnCases will add test cases to the "truncateTxn" test.
+func (runner *TestRunner) AddTruncateTxnCases(cases ...TestCase) {
+	runner.Mutex.Lock()
+	defer runner.Mutex.Unlock()
+
+	runner.truncateTxnCases = append(runner.truncateTxnCases, cases...)
+}
+
 // AddPingCases will add test cases to the "ping" test.
 func (runner *TestRunner) AddPingCases(cases ...TestCase) {
 	runner.Mutex.Lock()
@@ -435,6 +445,93 @@ func (runner TestRunner) upsertTxn(ctx context.Context, t *testing.T) {
 	}
 }
 
+// countRecords will return the number of records read from a table.
+func countRecords(ctx context.Context, t *testing.T, stg Storage, table string) int {
+	t.Helper()
+
+	count := 0
+
+	if err := stg.Read(ctx, &ReadRecordsRequest{Table: table}, func(map[string]interface{}) error {
+		count++
+
+		return nil
+	}); err != nil {
+		t.Fatalf("failed to read records: %v", err)
+	}
+
+	return count
+}
+
+// truncateTxn will test that the "Truncate" storage method is part of the transaction it is sent to: the table is
+// kept when the transaction is rolled back, and emptied before the records upserted after it when it is committed.
+func (runner TestRunner) truncateTxn(ctx context.Context, t *testing.T) {
+	t.Helper()
+
+	for _, tcase := range runner.truncateTxnCases {
+		tcase := tcase
+
+		name := fmt.Sprintf("%s truncate txn", tcase.Name)
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			runner.Mutex.Lock()
+			defer runner.Mutex.Unlock()
+
+			bytes, err := json.Marshal(tcase.Data)
+			if err != nil {
+				t.Fatalf("failed to marshal data: %v", err)
+			}
+
+			upsert := &UpsertRequest{Table: tcase.Table, Data: bytes}
+			if _, err := runner.Storage.Upsert(ctx, upsert); err != nil {
+				t.Fatalf("failed to upsert data: %v", err)
+			}
+
+			for _, step := range []struct {
+				upsert   bool
+				rollback bool
+				want     int
+			}{
+				{rollback: true, want: 1},
+				{upsert: true, want: 1},
+				{want: 0},
+			} {
+				txn, err := runner.Storage.StartTx(ctx)
+				if err != nil {
+					t.Fatalf("failed to start transaction: %v", err)
+				}
+
+				txn.Send(func(sctx context.Context, stg Storage) error {
+					if _, err := stg.Truncate(sctx, &TruncateRequest{Tables: []string{tcase.Table}}); err != nil {
+						return fmt.Errorf("failed to truncate table: %w", err)
+					}
+
+					return nil
+				})
+
+				if step.upsert {
+					txn.Send(func(sctx context.Context, stg Storage) error {
+						if _, err := stg.Upsert(sctx, upsert); err != nil {
+							return fmt.Errorf("failed to upsert data: %w", err)
+						}
+
+						return nil
+					})
+				}
+
+				resolveTxn(t, txn, step.rollback)
+
+				if count := countRecords(ctx, t, runner.Storage, tcase.Table); count != step.want {
+					t.Fatalf("expected %d records after truncating (rollback %t, upsert %t), got %d",
+						step.want, step.rollback, step.upsert, count)
+				}
+			}
+
+			truncateTables(ctx, t, runner.Storage, tcase.Table)
+		})
+	}
+}
+
 // upsertBinary will test the "UpsertBinary" storage method.
 func (runner TestRunner) upsertBinary(ctx context.Context, t *testing.T) {
 	t.Helper()
@@ -0,0 +1,80 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package proto
+
+import "fmt"
+
+// ErrBatchTooLarge is matched by the errors of upserts that storage rejects for their size, e.g. a MySQL packet
+// larger than "max_allowed_packet", a MongoDB document larger than 16MB, or a BigQuery or Elasticsearch request
+// larger than the API accepts, whose records can be upserted again in smaller batches.
+var ErrBatchTooLarge = fmt.Errorf("batch is too large")
+
+// BatchTooLargeError is returned when storage rejects an upsert for its size. It matches "ErrBatchTooLarge".
+type BatchTooLargeError struct {
+	// Written is the number of records of the upsert, from the first, that were written before it was rejected.
+	Written int
+
+	// Err is the error that the storage rejected the upsert with.
+	Err error
+}
+
+func (berr *BatchTooLargeError) Error() string {
+	return fmt.Sprintf("%v: %v", ErrBatchTooLarge, berr.Err)
+}
+
+func (berr *BatchTooLargeError) Unwrap() error {
+	return berr.Err
+}
+
+func (berr *BatchTooLargeError) Is(target error) bool {
+	return target == ErrBatchTooLarge
+}
+
+const (
+	// WriteModeUpsert will insert new records and update the records that conflict with existing ones. This is the
+	// default.
+	WriteModeUpsert = "upsert"
+
+	// WriteModeInsert will insert every record without checking for conflicts, which is faster than an upsert but
+	// fails on storage that enforces the key of a record that already exists.
+	WriteModeInsert = "insert"
+
+	// WriteModeAppend will insert new records and skip the records that conflict with existing ones, so that
+	// records are never modified once they are written.
+	WriteModeAppend = "append"
+)
+
+// WriteModeOrDefault returns the write mode of a request, which is "WriteModeUpsert" if it is not set.
+func WriteModeOrDefault(mode string) string {
+	if mode == "" {
+		return WriteModeUpsert
+	}
+
+	return mode
+}
+
+// ConflictKeysOrDefault returns the columns that identify a record for a write: the conflict keys of the request, or
+// the primary keys of the table if the request has none.
+func ConflictKeysOrDefault(keys, pks []string) []string {
+	if len(keys) == 0 {
+		return pks
+	}
+
+	return keys
+}
+
+// IsConflictKey returns true if "column" is one of the conflict keys of a write.
+func IsConflictKey(keys []string, column string) bool {
+	for _, key := range keys {
+		if key == column {
+			return true
+		}
+	}
+
+	return false
+}
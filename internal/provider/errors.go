@@ -0,0 +1,140 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alpstable/gidari/internal/web"
+)
+
+// errorBody returns the error message and code of the body of an error response, which are empty if the body does
+// not have the shape of the errors of the profile.
+func (profile *Profile) errorBody(rerr *web.ResponseError) (string, string) {
+	var body map[string]interface{}
+	if err := json.Unmarshal(rerr.Body, &body); err != nil {
+		return "", ""
+	}
+
+	field := func(name string) string {
+		if name == "" || body[name] == nil {
+			return ""
+		}
+
+		return strings.TrimSpace(fmt.Sprint(body[name]))
+	}
+
+	return field(profile.Errors.MessageField), field(profile.Errors.CodeField)
+}
+
+// Describe will add the error message and code of the body of an error response of the web API to a failed fetch.
+func (profile *Profile) Describe(err error) error {
+	if profile == nil {
+		return err
+	}
+
+	rerr, ok := responseError(err)
+	if !ok {
+		return err
+	}
+
+	msg, code := profile.errorBody(rerr)
+
+	switch {
+	case msg != "" && code != "":
+		return fmt.Errorf("%w: %s (code %s)", err, msg, code)
+	case msg != "":
+		return fmt.Errorf("%w: %s", err, msg)
+	case code != "":
+		return fmt.Errorf("%w: code %s", err, code)
+	default:
+		return err
+	}
+}
+
+// matches returns true if the error response matches the rule.
+func (rule ErrorRule) matches(status int, msg, code string) bool {
+	if rule.Status != 0 && rule.Status != status {
+		return false
+	}
+
+	if rule.Code != "" && rule.Code != code {
+		return false
+	}
+
+	return rule.Message == "" || strings.Contains(strings.ToLower(msg), strings.ToLower(rule.Message))
+}
+
+// Retryable returns true if a failed fetch is an error response that the web API documents as one that may succeed
+// when it is made again, such as an exceeded rate limit that is not reported as a 429.
+func (profile *Profile) Retryable(err error) bool {
+	if profile == nil {
+		return false
+	}
+
+	rerr, ok := responseError(err)
+	if !ok {
+		return false
+	}
+
+	msg, code := profile.errorBody(rerr)
+
+	for _, rule := range profile.Errors.Retryable {
+		if rule.matches(rerr.StatusCode, msg, code) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RetryAfter returns how long a failed fetch should wait before it is made again for the rate limit of the web API to
+// reset, from the "Retry-After" header or the reset header of the profile. It is zero if the response has neither.
+func (profile *Profile) RetryAfter(err error, now time.Time) time.Duration {
+	if profile == nil {
+		return 0
+	}
+
+	rerr, ok := responseError(err)
+	if !ok {
+		return 0
+	}
+
+	var wait time.Duration
+
+	if after := rerr.Header.Get("Retry-After"); after != "" {
+		if secs, err := strconv.Atoi(after); err == nil {
+			wait = time.Duration(secs) * time.Second
+		} else if at, err := http.ParseTime(after); err == nil {
+			wait = at.Sub(now)
+		}
+	}
+
+	if profile.ResetHeader != "" {
+		if secs, err := strconv.ParseInt(rerr.Header.Get(profile.ResetHeader), 10, 64); err == nil {
+			if reset := time.Unix(secs, 0).Sub(now); reset > wait {
+				wait = reset
+			}
+		}
+	}
+
+	if wait > maxRetryAfter {
+		return maxRetryAfter
+	}
+
+	if wait < 0 {
+		return 0
+	}
+
+	return wait
+}
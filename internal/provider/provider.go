@@ -0,0 +1,266 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package provider
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alpstable/gidari/internal/web"
+)
+
+// maxRetryAfter is the longest that a rate limited request waits for the limit of a web API to reset.
+const maxRetryAfter = time.Hour
+
+// Auth is how the credentials of the "apiKey" authentication are sent to a web API.
+type Auth struct {
+	// Signed requests are signed with the secret and passphrase of the key, as Coinbase requires.
+	Signed bool
+
+	// KeyHeader is the header that the key is sent in, if requests are not signed.
+	KeyHeader string
+
+	// SecretHeader is the header that the secret is sent in, if the web API requires it.
+	SecretHeader string
+}
+
+// Timeseries are the defaults of the timeseries requests to a web API: how the range of each chunk is sent.
+type Timeseries struct {
+	StartName string
+	EndName   string
+
+	// Layout is the time layout of the start and end of a chunk, or empty for RFC 3339.
+	Layout string
+}
+
+// ErrorRule matches an error response of a web API that is retried. Every field that is set must match.
+type ErrorRule struct {
+	// Status is the HTTP status code of the response.
+	Status int
+
+	// Code is the error code of the response body.
+	Code string
+
+	// Message is a case-insensitive substring of the error message of the response body.
+	Message string
+}
+
+// Errors is the shape of the JSON body of the error responses of a web API.
+type Errors struct {
+	// MessageField is the top-level field of the error message.
+	MessageField string
+
+	// CodeField is the top-level field of the error code, if the web API has error codes.
+	CodeField string
+
+	// Retryable are the error responses that may succeed when they are made again, in addition to 429s and 5xx
+	// responses.
+	Retryable []ErrorRule
+}
+
+// Example is a request of the sample configuration of a web API, which "gidari example" generates.
+type Example struct {
+	Endpoint string
+	Table    string
+
+	// Query are the query parameters of the request, e.g. the page size of the endpoint.
+	Query map[string]string
+
+	// RecordsPath is the JSON path of the records within the response, if they are wrapped in an envelope.
+	RecordsPath string
+
+	// PrimaryKeys are the primary keys of the table, which the records are upserted on.
+	PrimaryKeys []string
+
+	// Period is the size of the timeseries chunks of the request, and Range is how long before the configuration is
+	// generated the first chunk starts. The request is not a timeseries if Period is zero.
+	Period, Range time.Duration
+
+	// StartName and EndName are the query parameters of the range of a chunk, in place of those of the
+	// "Timeseries" of the profile.
+	StartName, EndName string
+}
+
+// Profile holds what is known about a popular web API, so that configurations for it only need their requests and
+// credentials.
+type Profile struct {
+	// Name is the name that selects the profile with "provider".
+	Name string
+
+	// URL is the default "url" of the web API.
+	URL string
+
+	// Burst and Period are the default "rateLimit" of the web API.
+	Burst  int
+	Period time.Duration
+
+	Auth       Auth
+	Timeseries Timeseries
+	Errors     Errors
+
+	// ResetHeader is the header of the unix time, in seconds, at which the rate limit of the web API resets.
+	// "Retry-After" is always honored.
+	ResetHeader string
+
+	// VersionHeader is the request header that a pinned version of the web API is sent in, and ServedVersionHeader
+	// is the response header of the version that was served.
+	VersionHeader, ServedVersionHeader string
+
+	// Examples are the requests of the sample configuration of the web API, and Public is true if they can be made
+	// without credentials.
+	Examples []Example
+	Public   bool
+}
+
+var profiles = map[string]*Profile{
+	"coinbase": {
+		Name:       "coinbase",
+		URL:        "https://api.exchange.coinbase.com",
+		Burst:      10,
+		Period:     100 * time.Millisecond,
+		Auth:       Auth{Signed: true},
+		Timeseries: Timeseries{StartName: "start", EndName: "end"},
+		Errors:     Errors{MessageField: "message"},
+		Examples: []Example{
+			{Endpoint: "/products", Table: "products", PrimaryKeys: []string{"id"}},
+			{
+				Endpoint:    "/products/BTC-USD/trades",
+				Table:       "trades",
+				Query:       map[string]string{"limit": "1000"},
+				PrimaryKeys: []string{"trade_id"},
+			},
+		},
+		Public: true,
+	},
+	"binance": {
+		Name:       "binance",
+		URL:        "https://api.binance.com",
+		Burst:      10,
+		Period:     100 * time.Millisecond,
+		Auth:       Auth{KeyHeader: "X-MBX-APIKEY"},
+		Timeseries: Timeseries{StartName: "startTime", EndName: "endTime", Layout: "unix_ms"},
+		Errors: Errors{
+			MessageField: "msg",
+			CodeField:    "code",
+			Retryable: []ErrorRule{
+				{Code: "-1003"}, // Too many requests.
+				{Code: "-1007"}, // Timeout waiting for the backend.
+				{Code: "-1021"}, // Timestamp outside of the receive window.
+			},
+		},
+		Examples: []Example{
+			{Endpoint: "/api/v3/ticker/24hr", Table: "tickers", PrimaryKeys: []string{"symbol"}},
+			{
+				// The range of aggregate trades is at most an hour.
+				Endpoint:    "/api/v3/aggTrades",
+				Table:       "agg_trades",
+				Query:       map[string]string{"symbol": "BTCUSDT", "limit": "1000"},
+				PrimaryKeys: []string{"a"},
+				Period:      time.Hour,
+				Range:       24 * time.Hour,
+			},
+		},
+		Public: true,
+	},
+	"alpaca": {
+		Name:        "alpaca",
+		URL:         "https://data.alpaca.markets",
+		Burst:       1,
+		Period:      300 * time.Millisecond,
+		Auth:        Auth{KeyHeader: "APCA-API-KEY-ID", SecretHeader: "APCA-API-SECRET-KEY"},
+		Timeseries:  Timeseries{StartName: "start", EndName: "end"},
+		Errors:      Errors{MessageField: "message", CodeField: "code"},
+		ResetHeader: "X-RateLimit-Reset",
+		Examples: []Example{
+			{
+				Endpoint:    "/v2/stocks/AAPL/bars",
+				Table:       "bars",
+				Query:       map[string]string{"timeframe": "1Hour", "limit": "10000"},
+				RecordsPath: "$.bars",
+				PrimaryKeys: []string{"t"},
+				Period:      7 * 24 * time.Hour,
+				Range:       28 * 24 * time.Hour,
+			},
+			{
+				Endpoint:    "/v2/stocks/AAPL/trades",
+				Table:       "trades",
+				Query:       map[string]string{"limit": "10000"},
+				RecordsPath: "$.trades",
+				PrimaryKeys: []string{"i"},
+				Period:      time.Hour,
+				Range:       24 * time.Hour,
+			},
+		},
+	},
+	"github": {
+		Name:   "github",
+		URL:    "https://api.github.com",
+		Burst:  10,
+		Period: 720 * time.Millisecond,
+		Errors: Errors{
+			MessageField: "message",
+			Retryable: []ErrorRule{
+				// Exceeded rate limits are reported as 403s.
+				{Status: 403, Message: "rate limit"},
+			},
+		},
+		ResetHeader:         "X-RateLimit-Reset",
+		VersionHeader:       "X-GitHub-Api-Version",
+		ServedVersionHeader: "X-GitHub-Api-Version-Selected",
+		Examples: []Example{
+			{
+				Endpoint:    "/repos/golang/go/releases",
+				Table:       "releases",
+				Query:       map[string]string{"per_page": "100"},
+				PrimaryKeys: []string{"id"},
+			},
+			{
+				Endpoint:    "/repos/golang/go/commits",
+				Table:       "commits",
+				Query:       map[string]string{"per_page": "100"},
+				PrimaryKeys: []string{"sha"},
+				Period:      24 * time.Hour,
+				Range:       7 * 24 * time.Hour,
+				StartName:   "since",
+				EndName:     "until",
+			},
+		},
+		Public: true,
+	},
+}
+
+// Lookup returns the profile with the name, and false if there is none.
+func Lookup(name string) (*Profile, bool) {
+	profile, ok := profiles[strings.ToLower(name)]
+
+	return profile, ok
+}
+
+// Names returns the names of every profile, sorted.
+func Names() []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// responseError returns the error response of a failed fetch.
+func responseError(err error) (*web.ResponseError, bool) {
+	var rerr *web.ResponseError
+	if errors.As(err, &rerr) {
+		return rerr, true
+	}
+
+	return nil, false
+}
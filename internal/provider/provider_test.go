@@ -0,0 +1,149 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alpstable/gidari/internal/web"
+)
+
+func newResponseError(status int, body string, header http.Header) error {
+	rerr := &web.ResponseError{StatusCode: status, Status: http.StatusText(status), Header: header, Body: []byte(body)}
+
+	return fmt.Errorf("error validating response: %w", rerr)
+}
+
+func TestDescribe(t *testing.T) {
+	t.Parallel()
+
+	binance, _ := Lookup("binance")
+	github, _ := Lookup("GitHub")
+
+	for _, tcase := range []struct {
+		name    string
+		profile *Profile
+		err     error
+		want    string
+	}{
+		{
+			name:    "message and code",
+			profile: binance,
+			err:     newResponseError(400, `{"code":-1121,"msg":"Invalid symbol."}`, nil),
+			want:    "error validating response: failed to get response: Bad Request: Invalid symbol. (code -1121)",
+		},
+		{
+			name:    "message",
+			profile: github,
+			err:     newResponseError(404, `{"message":"Not Found"}`, nil),
+			want:    "error validating response: failed to get response: Not Found: Not Found",
+		},
+		{
+			name:    "other shape",
+			profile: github,
+			err:     newResponseError(404, `<html></html>`, nil),
+			want:    "error validating response: failed to get response: Not Found",
+		},
+		{
+			name: "no profile",
+			err:  newResponseError(400, `{"msg":"Invalid symbol."}`, nil),
+			want: "error validating response: failed to get response: Bad Request",
+		},
+	} {
+		err := tcase.profile.Describe(tcase.err)
+		if err.Error() != tcase.want {
+			t.Fatalf("%s: expected %q, got %q", tcase.name, tcase.want, err)
+		}
+
+		if web.StatusCode(err) != web.StatusCode(tcase.err) {
+			t.Fatalf("%s: expected the status code to be kept, got %d", tcase.name, web.StatusCode(err))
+		}
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	t.Parallel()
+
+	binance, _ := Lookup("binance")
+	github, _ := Lookup("github")
+
+	for _, tcase := range []struct {
+		name    string
+		profile *Profile
+		err     error
+		want    bool
+	}{
+		{name: "code", profile: binance, err: newResponseError(429, `{"code":-1003,"msg":"Too many requests"}`, nil),
+			want: true},
+		{name: "other code", profile: binance, err: newResponseError(400, `{"code":-1121,"msg":"Invalid"}`, nil)},
+		{
+			name:    "status and message",
+			profile: github,
+			err:     newResponseError(403, `{"message":"API rate limit exceeded for 127.0.0.1."}`, nil),
+			want:    true,
+		},
+		{name: "forbidden", profile: github, err: newResponseError(403, `{"message":"Must have admin rights"}`, nil)},
+		{name: "not a response", profile: github, err: errors.New("connection reset")},
+		{name: "no profile", err: newResponseError(403, `{"message":"API rate limit exceeded"}`, nil)},
+	} {
+		if got := tcase.profile.Retryable(tcase.err); got != tcase.want {
+			t.Fatalf("%s: expected %t, got %t", tcase.name, tcase.want, got)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	github, _ := Lookup("github")
+	now := time.Date(2022, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, tcase := range []struct {
+		name   string
+		header http.Header
+		want   time.Duration
+	}{
+		{name: "none"},
+		{name: "seconds", header: http.Header{"Retry-After": {"30"}}, want: 30 * time.Second},
+		{
+			name:   "date",
+			header: http.Header{"Retry-After": {now.Add(time.Minute).Format(http.TimeFormat)}},
+			want:   time.Minute,
+		},
+		{
+			name:   "reset",
+			header: http.Header{"X-Ratelimit-Reset": {strconv.FormatInt(now.Add(5*time.Minute).Unix(), 10)}},
+			want:   5 * time.Minute,
+		},
+		{
+			name:   "later of both",
+			header: http.Header{"Retry-After": {"30"}, "X-Ratelimit-Reset": {strconv.FormatInt(now.Unix()+10, 10)}},
+			want:   30 * time.Second,
+		},
+		{
+			name:   "capped",
+			header: http.Header{"X-Ratelimit-Reset": {strconv.FormatInt(now.Add(48*time.Hour).Unix(), 10)}},
+			want:   maxRetryAfter,
+		},
+		{
+			name:   "passed",
+			header: http.Header{"X-Ratelimit-Reset": {strconv.FormatInt(now.Add(-time.Minute).Unix(), 10)}},
+		},
+	} {
+		if got := github.RetryAfter(newResponseError(403, "", tcase.header), now); got != tcase.want {
+			t.Fatalf("%s: expected %v, got %v", tcase.name, tcase.want, got)
+		}
+	}
+}
@@ -11,9 +11,18 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/alpstable/gidari/internal/bigquery"
+	"github.com/alpstable/gidari/internal/clickhouse"
+	"github.com/alpstable/gidari/internal/elastic"
+	"github.com/alpstable/gidari/internal/external"
+	"github.com/alpstable/gidari/internal/file"
 	"github.com/alpstable/gidari/internal/mongo"
+	"github.com/alpstable/gidari/internal/mysql"
+	"github.com/alpstable/gidari/internal/object"
+	"github.com/alpstable/gidari/internal/parquet"
 	"github.com/alpstable/gidari/internal/postgres"
 	"github.com/alpstable/gidari/internal/proto"
+	"github.com/alpstable/gidari/internal/sqlite"
 )
 
 // ErrFailedToCreateRepository is returned when the repository layer fails to create a new repository.
@@ -34,6 +43,17 @@ type Generic interface {
 	proto.Transactor
 
 	Transact(fn func(ctx context.Context, repo Generic) error)
+
+	// CreateTable will create a table that does not exist, on storage devices that need tables to exist before they
+	// can write to them.
+	CreateTable(ctx context.Context, req *proto.CreateTableRequest) (*proto.CreateTableResponse, error)
+
+	// AddColumns will add the columns that a table does not have, on storage devices that need columns to exist
+	// before they can write their values.
+	AddColumns(ctx context.Context, req *proto.AddColumnsRequest) (*proto.AddColumnsResponse, error)
+
+	// ConfigureExpiry will configure a table to expire its records, on storage devices that expire records natively.
+	ConfigureExpiry(ctx context.Context, req *proto.ExpiryRequest) (*proto.ExpiryResponse, error)
 }
 
 // GenericService is the implementation of the Generic service.
@@ -62,8 +82,73 @@ func NewStorage(ctx context.Context, dns string) (*proto.StorageService, error)
 		}
 
 		stg = &proto.StorageService{Storage: pdb}
+	case proto.SchemeFromStorageType(proto.SQLiteType):
+		sdb, err := sqlite.New(ctx, dns)
+		if err != nil {
+			return nil, fmt.Errorf("failed to construct sqlite storage: %w", err)
+		}
+
+		stg = &proto.StorageService{Storage: sdb}
+	case proto.SchemeFromStorageType(proto.MySQLType):
+		mdb, err := mysql.New(ctx, dns)
+		if err != nil {
+			return nil, fmt.Errorf("failed to construct mysql storage: %w", err)
+		}
+
+		stg = &proto.StorageService{Storage: mdb}
+	case proto.SchemeFromStorageType(proto.NDJSONType), proto.SchemeFromStorageType(proto.CSVType):
+		fdb, err := file.New(ctx, dns)
+		if err != nil {
+			return nil, fmt.Errorf("failed to construct file storage: %w", err)
+		}
+
+		stg = &proto.StorageService{Storage: fdb}
+	case proto.SchemeFromStorageType(proto.ParquetType):
+		pdb, err := parquet.New(ctx, dns)
+		if err != nil {
+			return nil, fmt.Errorf("failed to construct parquet storage: %w", err)
+		}
+
+		stg = &proto.StorageService{Storage: pdb}
+	case proto.SchemeFromStorageType(proto.S3Type), proto.SchemeFromStorageType(proto.GCSType):
+		odb, err := object.New(ctx, dns)
+		if err != nil {
+			return nil, fmt.Errorf("failed to construct object storage: %w", err)
+		}
+
+		stg = &proto.StorageService{Storage: odb}
+	case proto.SchemeFromStorageType(proto.ElasticsearchType), proto.SchemeFromStorageType(proto.OpenSearchType):
+		edb, err := elastic.New(ctx, dns)
+		if err != nil {
+			return nil, fmt.Errorf("failed to construct elasticsearch storage: %w", err)
+		}
+
+		stg = &proto.StorageService{Storage: edb}
+	case proto.SchemeFromStorageType(proto.BigQueryType):
+		bdb, err := bigquery.New(ctx, dns)
+		if err != nil {
+			return nil, fmt.Errorf("failed to construct bigquery storage: %w", err)
+		}
+
+		stg = &proto.StorageService{Storage: bdb}
+	case proto.SchemeFromStorageType(proto.ClickHouseType):
+		cdb, err := clickhouse.New(ctx, dns)
+		if err != nil {
+			return nil, fmt.Errorf("failed to construct clickhouse storage: %w", err)
+		}
+
+		stg = &proto.StorageService{Storage: cdb}
 	default:
-		return nil, fmt.Errorf("%w: %s", ErrUnkownScheme, scheme)
+		if !external.Registered(scheme) {
+			return nil, fmt.Errorf("%w: %s", ErrUnkownScheme, scheme)
+		}
+
+		xdb, err := external.New(ctx, dns)
+		if err != nil {
+			return nil, fmt.Errorf("failed to construct %s storage: %w", scheme, err)
+		}
+
+		stg = &proto.StorageService{Storage: xdb}
 	}
 
 	return stg, nil
@@ -117,3 +202,71 @@ func (svc *GenericService) Truncate(ctx context.Context, req *proto.TruncateRequ
 
 	return rsp, nil
 }
+
+// CreateTable creates a table if it does not exist. Storage devices that create tables, or collections, as they are
+// written to do nothing.
+func (svc *GenericService) CreateTable(ctx context.Context,
+	req *proto.CreateTableRequest,
+) (*proto.CreateTableResponse, error) {
+	stg := svc.Storage
+	if service, ok := stg.(*proto.StorageService); ok {
+		stg = service.Storage
+	}
+
+	creator, ok := stg.(proto.TableCreator)
+	if !ok {
+		return &proto.CreateTableResponse{}, nil
+	}
+
+	rsp, err := creator.CreateTable(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("error creating table: %w", err)
+	}
+
+	return rsp, nil
+}
+
+// ConfigureExpiry configures a table to expire its records. Storage devices that do not expire records natively
+// respond that it is not supported.
+func (svc *GenericService) ConfigureExpiry(ctx context.Context,
+	req *proto.ExpiryRequest,
+) (*proto.ExpiryResponse, error) {
+	stg := svc.Storage
+	if service, ok := stg.(*proto.StorageService); ok {
+		stg = service.Storage
+	}
+
+	configurer, ok := stg.(proto.ExpiryConfigurer)
+	if !ok {
+		return &proto.ExpiryResponse{}, nil
+	}
+
+	rsp, err := configurer.ConfigureExpiry(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("error configuring expiry: %w", err)
+	}
+
+	return rsp, nil
+}
+
+// AddColumns adds the columns that a table does not have. Storage devices that write any field of a record do nothing.
+func (svc *GenericService) AddColumns(ctx context.Context,
+	req *proto.AddColumnsRequest,
+) (*proto.AddColumnsResponse, error) {
+	stg := svc.Storage
+	if service, ok := stg.(*proto.StorageService); ok {
+		stg = service.Storage
+	}
+
+	adder, ok := stg.(proto.ColumnAdder)
+	if !ok {
+		return &proto.AddColumnsResponse{}, nil
+	}
+
+	rsp, err := adder.AddColumns(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("error adding columns: %w", err)
+	}
+
+	return rsp, nil
+}
@@ -0,0 +1,9 @@
+SELECT m.name AS table_name,
+       c.name AS column_name,
+       c.pk AS primary_key
+FROM sqlite_master m
+    INNER JOIN pragma_table_info(m.name) c
+WHERE m.type = 'table'
+      AND m.name NOT LIKE 'sqlite_%'
+ORDER BY m.name,
+         c.cid
@@ -0,0 +1,668 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/alpstable/gidari/internal/proto"
+	"github.com/google/uuid"
+	_ "github.com/mattn/go-sqlite3" // Register the "sqlite3" driver.
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	// defaultPartitionSize is the maximum number of records upserted in a single statement.
+	defaultPartitionSize = 1000
+
+	// maxVariables is the maximum number of placeholders in a single statement, the default
+	// "SQLITE_MAX_VARIABLE_NUMBER" since SQLite 3.32.
+	maxVariables = 32766
+
+	// defaultBusyTimeout is how long, in milliseconds, a connection waits for a lock held by another connection.
+	defaultBusyTimeout = 5000
+)
+
+var (
+	ErrTransactionNotFound = fmt.Errorf("transaction not found")
+	ErrTableNotFound       = fmt.Errorf("table not found")
+)
+
+// sqliteTxType is a type alias for the sqlite transaction type.
+type sqliteTxType uint8
+
+const (
+	basicSQLiteTxID sqliteTxType = iota
+)
+
+// sqlExecContextFn can be used to execute a statement.
+type sqlExecContextFn func(context.Context, string, ...interface{}) (sql.Result, error)
+
+// sqlQueryContextFn can be used to execute a query.
+type sqlQueryContextFn func(context.Context, string, ...interface{}) (*sql.Rows, error)
+
+// columnTypes are the column types of the kinds of values inferred for a table.
+var columnTypes = map[string]string{
+	proto.KindBoolean:   "INTEGER",
+	proto.KindInteger:   "INTEGER",
+	proto.KindNumber:    "REAL",
+	proto.KindTimestamp: "TEXT",
+	proto.KindString:    "TEXT",
+	proto.KindJSON:      "TEXT",
+}
+
+type meta struct {
+	// cols are the columns for a specific table, in the order they were declared.
+	cols map[string][]string
+
+	// pks are the primary keys for a specific table, in the order they were declared.
+	pks map[string][]string
+}
+
+// quoteIdent will quote a table or column name for use in a statement.
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func quoteIdents(names []string) []string {
+	quoted := make([]string, len(names))
+	for idx, name := range names {
+		quoted[idx] = quoteIdent(name)
+	}
+
+	return quoted
+}
+
+// formatPlaceholders will return a string of "numRows" groups of "numCols" placeholders, e.g. "(?,?),(?,?)".
+func formatPlaceholders(numCols int, numRows int) string {
+	if numCols == 0 || numRows == 0 {
+		return "()"
+	}
+
+	row := "(" + strings.TrimSuffix(strings.Repeat("?,", numCols), ",") + ")"
+
+	return strings.TrimSuffix(strings.Repeat(row+",", numRows), ",")
+}
+
+// partitionSize returns the number of records that can be upserted in a single statement for a table with "numCols"
+// columns.
+func partitionSize(numCols int) int {
+	if numCols == 0 {
+		return defaultPartitionSize
+	}
+
+	if size := maxVariables / numCols; size < defaultPartitionSize {
+		return size
+	}
+
+	return defaultPartitionSize
+}
+
+// flattenPartition will take a slice of structures, extract data from their fields, and append it to "args" to be
+// used in conjunction with placeholders in a SQL query. Nested objects and lists are stored as JSON text.
+func flattenPartition(args []interface{}, columns []string, partition []*structpb.Struct) ([]interface{}, error) {
+	for _, record := range partition {
+		fields := record.GetFields()
+		for _, column := range columns {
+			val := fields[column].AsInterface()
+
+			switch val.(type) {
+			case map[string]interface{}, []interface{}:
+				data, err := json.Marshal(val)
+				if err != nil {
+					return nil, fmt.Errorf("unable to encode column %q: %w", column, err)
+				}
+
+				val = string(data)
+			}
+
+			args = append(args, val)
+		}
+	}
+
+	return args, nil
+}
+
+// upsertQuery will return a statement that writes "vol" records with the write mode. Records that conflict on the
+// "keys", which default to the primary key of the table, are updated by an upsert and skipped by an append. Inserts,
+// and writes to tables without a primary key, do not check for conflicts.
+func (meta *meta) upsertQuery(table string, vol int, mode string, keys []string) string {
+	cols := meta.cols[table]
+
+	query := fmt.Sprintf(`INSERT INTO %s(%s) VALUES %s`, quoteIdent(table), strings.Join(quoteIdents(cols), ","),
+		formatPlaceholders(len(cols), vol))
+
+	keys = proto.ConflictKeysOrDefault(keys, meta.pks[table])
+	if len(keys) == 0 || mode == proto.WriteModeInsert {
+		return query
+	}
+
+	var updates []string
+
+	for _, column := range cols {
+		if !proto.IsConflictKey(keys, column) {
+			updates = append(updates, fmt.Sprintf("%s = excluded.%s", quoteIdent(column), quoteIdent(column)))
+		}
+	}
+
+	if len(updates) == 0 || mode == proto.WriteModeAppend {
+		return fmt.Sprintf("%s ON CONFLICT (%s) DO NOTHING", query, strings.Join(quoteIdents(keys), ","))
+	}
+
+	return fmt.Sprintf("%s ON CONFLICT (%s) DO UPDATE SET %s", query, strings.Join(quoteIdents(keys), ","),
+		strings.Join(updates, ","))
+}
+
+// SQLite is a wrapper around the sql.DB object for a SQLite database file.
+type SQLite struct {
+	*sql.DB
+
+	// meta hold metdata about the database.
+	meta *meta
+
+	metaMutex  sync.Mutex
+	writeMutex sync.Mutex
+
+	// activeTx are the transactions that are currently active on this connection, keyed by the transaction ID that
+	// "StartTx" adds to the context of the functions sent to the transaction.
+	activeTx sync.Map
+
+	// stmts are the upsert statements prepared by the active transactions, keyed by transaction ID, and dbStmts
+	// are the ones prepared by the database for writes outside of a transaction.
+	stmts   sync.Map
+	dbStmts *proto.StmtCache
+}
+
+// dataSourceName will convert a "sqlite://" connection string into a data source name for the driver. The path is
+// relative to the working directory, unless it has a leading slash, e.g. "sqlite:///var/lib/gidari.db".
+func dataSourceName(connectionURL string) string {
+	path := strings.TrimPrefix(connectionURL, proto.SchemeFromStorageType(proto.SQLiteType)+"://")
+
+	params := fmt.Sprintf("_busy_timeout=%d&_journal_mode=WAL", defaultBusyTimeout)
+	if strings.Contains(path, "?") {
+		return "file:" + path + "&" + params
+	}
+
+	return "file:" + path + "?" + params
+}
+
+// New will return a new SQLite option for storing data in a SQLite database file. The file is created if it does not
+// exist, and tables are created ahead of time or, with "autoCreate", from the records of the first write.
+func New(ctx context.Context, connectionURL string) (*SQLite, error) {
+	db, err := sql.Open("sqlite3", dataSourceName(connectionURL))
+	if err != nil {
+		return nil, fmt.Errorf("unable to open sqlite database: %w", err)
+	}
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+
+		return nil, fmt.Errorf("unable to connect to sqlite: %w", err)
+	}
+
+	return &SQLite{DB: db, meta: new(meta)}, nil
+}
+
+// loadMeta will load the columns and primary keys of every table in the database, including the tables created by
+// the transaction of the context.
+func (lite *SQLite) loadMeta(ctx context.Context) error {
+	lite.metaMutex.Lock()
+	defer lite.metaMutex.Unlock()
+
+	queryContextFn, err := lite.getQueryContextFn(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to get executor: %w", err)
+	}
+
+	rows, err := queryContextFn(ctx, string(sqliteColumns))
+	if err != nil {
+		return fmt.Errorf("unable to query: %w", err)
+	}
+	defer rows.Close()
+
+	lite.meta.cols = make(map[string][]string)
+	lite.meta.pks = make(map[string][]string)
+
+	pkPositions := make(map[string]map[string]int)
+
+	for rows.Next() {
+		var (
+			table  string
+			column string
+			pk     int
+		)
+
+		if err := rows.Scan(&table, &column, &pk); err != nil {
+			return fmt.Errorf("unable to scan row: %w", err)
+		}
+
+		lite.meta.cols[table] = append(lite.meta.cols[table], column)
+
+		if pk > 0 {
+			if pkPositions[table] == nil {
+				pkPositions[table] = make(map[string]int)
+			}
+
+			pkPositions[table][column] = pk
+			lite.meta.pks[table] = append(lite.meta.pks[table], column)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("unable to read rows: %w", err)
+	}
+
+	// Order composite primary keys by their position in the primary key, rather than in the table.
+	for table, pks := range lite.meta.pks {
+		positions := pkPositions[table]
+		sort.SliceStable(pks, func(i, j int) bool { return positions[pks[i]] < positions[pks[j]] })
+	}
+
+	return nil
+}
+
+// Close will close the underlying database.
+func (lite *SQLite) Close() {
+	if lite.dbStmts != nil {
+		lite.dbStmts.Close()
+	}
+
+	if lite.DB != nil {
+		lite.DB.Close()
+	}
+}
+
+// ListPrimaryKeys will list all primary keys for all of the tables in the database.
+func (lite *SQLite) ListPrimaryKeys(ctx context.Context) (*proto.ListPrimaryKeysResponse, error) {
+	if err := lite.loadMeta(ctx); err != nil {
+		return nil, fmt.Errorf("unable to load sqlite metadata: %w", err)
+	}
+
+	rsp := &proto.ListPrimaryKeysResponse{PKSet: make(map[string]*proto.PrimaryKeys)}
+
+	for table, pks := range lite.meta.pks {
+		rsp.PKSet[table] = &proto.PrimaryKeys{List: append([]string(nil), pks...)}
+	}
+
+	return rsp, nil
+}
+
+// ListTables will set a complete list of available tables on the response. SQLite does not track the size of
+// individual tables, so the size is the number of bytes of data stored in the table's columns.
+func (lite *SQLite) ListTables(ctx context.Context) (*proto.ListTablesResponse, error) {
+	if err := lite.loadMeta(ctx); err != nil {
+		return nil, fmt.Errorf("unable to load sqlite metadata: %w", err)
+	}
+
+	rsp := &proto.ListTablesResponse{TableSet: make(map[string]*proto.Table)}
+
+	for table, cols := range lite.meta.cols {
+		lengths := make([]string, len(cols))
+		for idx, col := range cols {
+			lengths[idx] = fmt.Sprintf("COALESCE(LENGTH(%s), 0)", quoteIdent(col))
+		}
+
+		query := fmt.Sprintf("SELECT COALESCE(SUM(%s), 0) FROM %s", strings.Join(lengths, "+"), quoteIdent(table))
+
+		var size int64
+		if err := lite.DB.QueryRowContext(ctx, query).Scan(&size); err != nil {
+			return nil, fmt.Errorf("unable to get size of table %q: %w", table, err)
+		}
+
+		rsp.TableSet[table] = &proto.Table{Size: size}
+	}
+
+	return rsp, nil
+}
+
+// Truncate will delete every record in the tables on the request, as part of the transaction of the context if it
+// has one.
+func (lite *SQLite) Truncate(ctx context.Context, req *proto.TruncateRequest) (*proto.TruncateResponse, error) {
+	if len(req.GetTables()) == 0 {
+		return &proto.TruncateResponse{}, nil
+	}
+
+	lite.writeMutex.Lock()
+	defer lite.writeMutex.Unlock()
+
+	execContextFn, err := lite.getExecContextFn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get executor: %w", err)
+	}
+
+	for _, table := range req.GetTables() {
+		if _, err := execContextFn(ctx, "DELETE FROM "+quoteIdent(table)); err != nil {
+			return nil, fmt.Errorf("unable to truncate table %q: %w", table, err)
+		}
+	}
+
+	return &proto.TruncateResponse{}, nil
+}
+
+// Read will call "fn" with every record in the table on the request.
+func (lite *SQLite) Read(ctx context.Context, req *proto.ReadRecordsRequest, fn proto.ReadFunc) error {
+	query := "SELECT * FROM " + quoteIdent(req.Table)
+	if req.OrderBy != "" {
+		query += " ORDER BY " + quoteIdent(req.OrderBy)
+	}
+
+	rows, err := lite.DB.QueryContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("unable to read table %q: %w", req.Table, err)
+	}
+
+	defer rows.Close()
+
+	return proto.ScanRows(rows, fn)
+}
+
+// getExecContextFn will return the function to execute statements with, using the transaction assigned to the
+// context if there is one.
+func (lite *SQLite) getExecContextFn(ctx context.Context) (sqlExecContextFn, error) {
+	txID, ok := ctx.Value(basicSQLiteTxID).(string)
+	if !ok {
+		return lite.DB.ExecContext, nil
+	}
+
+	stored, ok := lite.activeTx.Load(txID)
+	if !ok {
+		return lite.DB.ExecContext, nil
+	}
+
+	tx, ok := stored.(*sql.Tx)
+	if !ok {
+		return nil, ErrTransactionNotFound
+	}
+
+	return tx.ExecContext, nil
+}
+
+// getStmtCache will return the cache of the statements prepared by the transaction assigned to the context, or by
+// the database if there is none. The write lock must be held.
+func (lite *SQLite) getStmtCache(ctx context.Context) *proto.StmtCache {
+	if txID, ok := ctx.Value(basicSQLiteTxID).(string); ok {
+		if stored, ok := lite.stmts.Load(txID); ok {
+			if cache, ok := stored.(*proto.StmtCache); ok {
+				return cache
+			}
+		}
+	}
+
+	if lite.dbStmts == nil {
+		lite.dbStmts = proto.NewStmtCache(lite.DB.PrepareContext)
+	}
+
+	return lite.dbStmts
+}
+
+// getQueryContextFn will return the function to execute queries with, using the transaction assigned to the context
+// if there is one.
+func (lite *SQLite) getQueryContextFn(ctx context.Context) (sqlQueryContextFn, error) {
+	txID, ok := ctx.Value(basicSQLiteTxID).(string)
+	if !ok {
+		return lite.DB.QueryContext, nil
+	}
+
+	stored, ok := lite.activeTx.Load(txID)
+	if !ok {
+		return lite.DB.QueryContext, nil
+	}
+
+	tx, ok := stored.(*sql.Tx)
+	if !ok {
+		return nil, ErrTransactionNotFound
+	}
+
+	return tx.QueryContext, nil
+}
+
+// createTableQuery will return a statement that creates the table of the request if it does not exist.
+func createTableQuery(req *proto.CreateTableRequest) string {
+	defs := make([]string, 0, len(req.Columns)+1)
+
+	for _, column := range req.Columns {
+		def := quoteIdent(column.Name) + " " + proto.ColumnType(column, columnTypes)
+		if proto.IsConflictKey(req.PrimaryKeys, column.Name) {
+			def += " NOT NULL"
+		}
+
+		defs = append(defs, def)
+	}
+
+	if len(req.PrimaryKeys) > 0 {
+		defs = append(defs, fmt.Sprintf("PRIMARY KEY(%s)", strings.Join(quoteIdents(req.PrimaryKeys), ",")))
+	}
+
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s(%s)", quoteIdent(req.Table), strings.Join(defs, ","))
+}
+
+// CreateTable will create the table of the request if it does not exist, as part of the transaction of the context
+// if it has one.
+func (lite *SQLite) CreateTable(ctx context.Context,
+	req *proto.CreateTableRequest,
+) (*proto.CreateTableResponse, error) {
+	lite.writeMutex.Lock()
+	defer lite.writeMutex.Unlock()
+
+	if err := lite.loadMeta(ctx); err != nil {
+		return nil, fmt.Errorf("unable to load sqlite metadata: %w", err)
+	}
+
+	if _, ok := lite.meta.cols[req.Table]; ok {
+		return &proto.CreateTableResponse{}, nil
+	}
+
+	execContextFn, err := lite.getExecContextFn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get executor: %w", err)
+	}
+
+	if _, err := execContextFn(ctx, createTableQuery(req)); err != nil {
+		return nil, fmt.Errorf("unable to create table %q: %w", req.Table, err)
+	}
+
+	return &proto.CreateTableResponse{Created: true}, nil
+}
+
+// AddColumns will add the columns of the request that the table does not have, as part of the transaction of the
+// context if it has one. Strict requests fail with the columns that the table does not have instead.
+func (lite *SQLite) AddColumns(ctx context.Context, req *proto.AddColumnsRequest) (*proto.AddColumnsResponse, error) {
+	lite.writeMutex.Lock()
+	defer lite.writeMutex.Unlock()
+
+	if err := lite.loadMeta(ctx); err != nil {
+		return nil, fmt.Errorf("unable to load sqlite metadata: %w", err)
+	}
+
+	cols, ok := lite.meta.cols[req.Table]
+	if !ok {
+		return &proto.AddColumnsResponse{}, nil
+	}
+
+	missing := proto.MissingColumns(cols, req.Columns)
+	if len(missing) == 0 {
+		return &proto.AddColumnsResponse{}, nil
+	}
+
+	if req.Strict {
+		return nil, proto.UnknownColumnsError(req.Table, missing)
+	}
+
+	execContextFn, err := lite.getExecContextFn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get executor: %w", err)
+	}
+
+	rsp := &proto.AddColumnsResponse{}
+
+	for _, column := range missing {
+		query := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", quoteIdent(req.Table), quoteIdent(column.Name),
+			proto.ColumnType(column, columnTypes))
+		if _, err := execContextFn(ctx, query); err != nil {
+			return nil, fmt.Errorf("unable to add column %q to table %q: %w", column.Name, req.Table, err)
+		}
+
+		rsp.Added = append(rsp.Added, column.Name)
+	}
+
+	return rsp, nil
+}
+
+func (lite *SQLite) upsert(ctx context.Context, table string, records []*structpb.Struct, mode string,
+	keys []string,
+) error {
+	cache := lite.getStmtCache(ctx)
+
+	if err := lite.loadMeta(ctx); err != nil {
+		return fmt.Errorf("unable to load sqlite metadata: %w", err)
+	}
+
+	cols, ok := lite.meta.cols[table]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrTableNotFound, table)
+	}
+
+	for _, partition := range proto.PartitionStructs(partitionSize(len(cols)), records) {
+		stmt, err := cache.Prepare(ctx, lite.meta.upsertQuery(table, len(partition), mode, keys))
+		if err != nil {
+			return err
+		}
+
+		args, err := flattenPartition(cache.Args(len(cols)*len(partition)), cols, partition)
+		if err != nil {
+			return err
+		}
+
+		if _, err := stmt.ExecContext(ctx, args...); err != nil {
+			return fmt.Errorf("unable to execute upsert: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Upsert will insert the records on the request if they do not exist in the database. On conflict, it will use the
+// conflict keys of the request, or the PK of the table, to update the data in the database. Requests with the
+// "insert" write mode do not check for conflicts, and those with the "append" write mode skip conflicting records.
+func (lite *SQLite) Upsert(ctx context.Context, req *proto.UpsertRequest) (*proto.UpsertResponse, error) {
+	lite.writeMutex.Lock()
+	defer lite.writeMutex.Unlock()
+
+	records, err := proto.DecodeUpsertRequest(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode records: %w", err)
+	}
+
+	// Do nothing if there are no records.
+	if len(records) == 0 {
+		return &proto.UpsertResponse{}, nil
+	}
+
+	if err := lite.upsert(ctx, req.GetTable(), records, req.GetWriteMode(), req.GetConflictKeys()); err != nil {
+		return nil, fmt.Errorf("unable to upsert: %w", err)
+	}
+
+	return &proto.UpsertResponse{}, nil
+}
+
+// UpsertBinary will upsert binary data into a "property bag"-like table, storing the data in a text column.
+func (lite *SQLite) UpsertBinary(ctx context.Context,
+	req *proto.UpsertBinaryRequest,
+) (*proto.UpsertBinaryResponse, error) {
+	lite.writeMutex.Lock()
+	defer lite.writeMutex.Unlock()
+
+	records, err := proto.DecodeUpsertBinaryRequest(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode records: %w", err)
+	}
+
+	// Do nothing if there are no records.
+	if len(records) == 0 {
+		return &proto.UpsertBinaryResponse{}, nil
+	}
+
+	if err := lite.upsert(ctx, req.GetTable(), records, req.GetWriteMode(), req.GetConflictKeys()); err != nil {
+		return nil, fmt.Errorf("unable to upsert: %w", err)
+	}
+
+	return &proto.UpsertBinaryResponse{}, nil
+}
+
+// IsNoSQL returns "false" to indicate that "SQLite" is not a NoSQL database.
+func (lite *SQLite) IsNoSQL() bool { return false }
+
+// Type implements the storage interface.
+func (lite *SQLite) Type() uint8 { return proto.SQLiteType }
+
+// StartTx will start a transaction on the SQLite database. Operations sent to the transaction are executed in order,
+// and are committed or rolled back together.
+func (lite *SQLite) StartTx(ctx context.Context) (*proto.Txn, error) {
+	txn := &proto.Txn{
+		FunctionCh: make(chan proto.TxnChanFn),
+		DoneCh:     make(chan error, 1),
+		CommitCh:   make(chan bool, 1),
+	}
+
+	txnID := uuid.New().String()
+
+	litetx, err := lite.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return txn, fmt.Errorf("failed to start transaction: %w", err)
+	}
+
+	lite.activeTx.Store(txnID, litetx)
+	lite.stmts.Store(txnID, proto.NewStmtCache(litetx.PrepareContext))
+
+	// Create a copy of the parent context with a transaction ID.
+	liteCtx := context.WithValue(ctx, basicSQLiteTxID, txnID)
+
+	go func() {
+		// The statements of the transaction are closed with it.
+		defer lite.stmts.Delete(txnID)
+		defer lite.activeTx.Delete(txnID)
+
+		for fn := range txn.FunctionCh {
+			if err != nil {
+				continue
+			}
+
+			err = fn(liteCtx, lite)
+		}
+
+		if err != nil {
+			litetx.Rollback()
+			txn.DoneCh <- err
+
+			return
+		}
+
+		if <-txn.CommitCh {
+			txn.DoneCh <- litetx.Commit()
+		} else {
+			txn.DoneCh <- litetx.Rollback()
+		}
+	}()
+
+	return txn, nil
+}
+
+// Ping will return an error if the connection to the DB is lost.
+func (lite *SQLite) Ping() error {
+	if err := lite.DB.Ping(); err != nil {
+		return fmt.Errorf("connection lost: %w", err)
+	}
+
+	return nil
+}
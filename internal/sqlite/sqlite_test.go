@@ -0,0 +1,420 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/alpstable/gidari/internal/proto"
+)
+
+const createTestTables = `
+CREATE TABLE tests1 (id TEXT NOT NULL, test_string TEXT NOT NULL, PRIMARY KEY (id));
+CREATE TABLE lttests1 (id TEXT NOT NULL, test_string TEXT NOT NULL, PRIMARY KEY (id));
+CREATE TABLE pktests1 (test_string TEXT NOT NULL, test_int INT NOT NULL, PRIMARY KEY (test_string));
+CREATE TABLE property_bag_tests1 (id TEXT NOT NULL, data TEXT NOT NULL, PRIMARY KEY (id));
+CREATE TABLE composite (b TEXT NOT NULL, a TEXT NOT NULL, v TEXT, PRIMARY KEY (a, b));
+CREATE TABLE modes (id TEXT NOT NULL, code TEXT NOT NULL, v TEXT, PRIMARY KEY (id), UNIQUE (code));
+`
+
+func newTestSQLite(t *testing.T) (*SQLite, string) {
+	t.Helper()
+
+	dns := "sqlite://" + filepath.Join(t.TempDir(), "gidari.db")
+
+	lite, err := New(context.Background(), dns)
+	if err != nil {
+		t.Fatalf("failed to open sqlite: %v", err)
+	}
+
+	if _, err := lite.DB.Exec(createTestTables); err != nil {
+		t.Fatalf("failed to create tables: %v", err)
+	}
+
+	return lite, dns
+}
+
+func TestSQLite(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	lite, dns := newTestSQLite(t)
+
+	proto.RunTest(ctx, t, lite, func(runner *proto.TestRunner) {
+		runner.AddCloseDBCases(proto.TestCase{
+			Name: "close sqlite",
+			OpenFn: func() proto.Storage {
+				stg, _ := New(ctx, dns)
+
+				return stg
+			},
+		})
+
+		runner.AddStorageTypeCases(proto.TestCase{Name: "storage type", StorageType: proto.SQLiteType})
+		runner.AddIsNoSQLCases(proto.TestCase{Name: "isNoSQL sqlite", ExpectedIsNoSQL: false})
+
+		runner.AddListPrimaryKeysCases(proto.TestCase{
+			Name:                "single",
+			Table:               "pktests1",
+			ExpectedPrimaryKeys: map[string][]string{"pktests1": {"test_string"}},
+		})
+
+		runner.AddListTablesCases(proto.TestCase{Name: "single", Table: "lttests1"})
+
+		runner.AddUpsertTxnCases(
+			proto.TestCase{
+				Name:               "commit",
+				Table:              "tests1",
+				ExpectedUpsertSize: 5,
+				Data:               map[string]interface{}{"test_string": "test", "id": "1"},
+			},
+			proto.TestCase{
+				Name:               "rollback",
+				Table:              "tests1",
+				ExpectedUpsertSize: 0,
+				Rollback:           true,
+				Data:               map[string]interface{}{"test_string": "test", "id": "1"},
+			},
+			proto.TestCase{
+				Name:       "rollback on error",
+				Table:      "tests1",
+				ForceError: true,
+				Data:       map[string]interface{}{"test_string": "test", "id": "1"},
+			},
+		)
+
+		runner.AddUpsertBinaryCases(proto.TestCase{
+			Name:               "no pk map",
+			BinaryColumn:       "data",
+			Table:              "property_bag_tests1",
+			ExpectedUpsertSize: 33,
+			Data:               map[string]interface{}{"data": []byte("{ x: 1 }"), "id": "1"},
+		})
+
+		runner.AddTruncateTxnCases(proto.TestCase{
+			Name:  "sqlite",
+			Table: "tests1",
+			Data:  map[string]interface{}{"test_string": "test", "id": "1"},
+		})
+
+		runner.AddPingCases(proto.TestCase{Name: "check sqlite connection"})
+	})
+}
+
+func TestUpsertConflict(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	lite, _ := newTestSQLite(t)
+
+	defer lite.Close()
+
+	for _, data := range []string{
+		`[{"a": "1", "b": "2", "v": "first"}, {"a": "1", "b": "3", "v": {"nested": true}}]`,
+		`{"a": "1", "b": "2", "v": "second"}`,
+	} {
+		if _, err := lite.Upsert(ctx, &proto.UpsertRequest{Table: "composite", Data: []byte(data)}); err != nil {
+			t.Fatalf("failed to upsert: %v", err)
+		}
+	}
+
+	var count int
+	if err := lite.DB.QueryRow(`SELECT COUNT(*) FROM composite`).Scan(&count); err != nil || count != 2 {
+		t.Fatalf("expected 2 records, got %d: %v", count, err)
+	}
+
+	var val string
+	if err := lite.DB.QueryRow(`SELECT v FROM composite WHERE a = '1' AND b = '2'`).Scan(&val); err != nil {
+		t.Fatalf("failed to query: %v", err)
+	}
+
+	if val != "second" {
+		t.Fatalf("expected the record to be updated, got %q", val)
+	}
+
+	if err := lite.DB.QueryRow(`SELECT v FROM composite WHERE b = '3'`).Scan(&val); err != nil {
+		t.Fatalf("failed to query: %v", err)
+	}
+
+	if val != `{"nested":true}` {
+		t.Fatalf("expected nested data to be stored as JSON, got %q", val)
+	}
+
+	pks, err := lite.ListPrimaryKeys(ctx)
+	if err != nil {
+		t.Fatalf("failed to list primary keys: %v", err)
+	}
+
+	if got := pks.GetPKSet()["composite"].GetList(); len(got) != 2 || got[0] != "a" || got[1] != "b" {
+		t.Fatalf("expected primary keys in key order, got %v", got)
+	}
+
+	if _, err := lite.Upsert(ctx, &proto.UpsertRequest{Table: "missing", Data: []byte(`{"a": 1}`)}); err == nil {
+		t.Fatalf("expected an error for a missing table")
+	}
+}
+
+func TestWriteModes(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	lite, _ := newTestSQLite(t)
+
+	defer lite.Close()
+
+	write := func(mode, data string, keys ...string) error {
+		_, err := lite.Upsert(ctx, &proto.UpsertRequest{
+			Table:        "modes",
+			Data:         []byte(data),
+			WriteMode:    mode,
+			ConflictKeys: keys,
+		})
+
+		return err
+	}
+
+	value := func() string {
+		var val string
+		if err := lite.DB.QueryRow(`SELECT v FROM modes WHERE code = 'x'`).Scan(&val); err != nil {
+			t.Fatalf("failed to query: %v", err)
+		}
+
+		return val
+	}
+
+	if err := write(proto.WriteModeInsert, `{"id": "1", "code": "x", "v": "first"}`); err != nil {
+		t.Fatalf("failed to insert: %v", err)
+	}
+
+	if err := write(proto.WriteModeInsert, `{"id": "1", "code": "x", "v": "second"}`); err == nil {
+		t.Fatalf("expected inserting an existing record to fail")
+	}
+
+	err := write(proto.WriteModeAppend, `[{"id": "1", "code": "x", "v": "second"}, {"id": "2", "code": "y"}]`)
+	if err != nil {
+		t.Fatalf("failed to append: %v", err)
+	}
+
+	if val := value(); val != "first" {
+		t.Fatalf("expected appending to keep the existing record, got %q", val)
+	}
+
+	// The conflict key is the unique code rather than the primary key.
+	if err := write(proto.WriteModeUpsert, `{"id": "3", "code": "x", "v": "third"}`, "code"); err != nil {
+		t.Fatalf("failed to upsert: %v", err)
+	}
+
+	var id string
+	if err := lite.DB.QueryRow(`SELECT id FROM modes WHERE code = 'x'`).Scan(&id); err != nil {
+		t.Fatalf("failed to query: %v", err)
+	}
+
+	if val := value(); val != "third" || id != "3" {
+		t.Fatalf("expected the record with the conflicting code to be updated, got %q with id %q", val, id)
+	}
+
+	var count int
+	if err := lite.DB.QueryRow(`SELECT COUNT(*) FROM modes`).Scan(&count); err != nil || count != 2 {
+		t.Fatalf("expected 2 records, got %d: %v", count, err)
+	}
+}
+
+func TestStmtCache(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	lite, _ := newTestSQLite(t)
+
+	defer lite.Close()
+
+	txn, err := lite.StartTx(ctx)
+	if err != nil {
+		t.Fatalf("failed to start transaction: %v", err)
+	}
+
+	var cached int
+
+	txn.Send(func(ctx context.Context, stg proto.Storage) error {
+		// The first two upserts have the same query, so they share a statement.
+		for _, data := range []string{`[{"id": "1", "test_string": "a"}]`, `[{"id": "2", "test_string": "b"}]`,
+			`[{"id": "3", "test_string": "c"}, {"id": "4", "test_string": "d"}]`} {
+			if _, err := stg.Upsert(ctx, &proto.UpsertRequest{Table: "tests1", Data: []byte(data)}); err != nil {
+				return err
+			}
+		}
+
+		cached = lite.getStmtCache(ctx).Len()
+
+		return nil
+	})
+
+	if err := txn.Commit(); err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+
+	if cached != 2 {
+		t.Fatalf("expected 2 cached statements, got %d", cached)
+	}
+
+	var count int
+	if err := lite.DB.QueryRow(`SELECT COUNT(*) FROM tests1`).Scan(&count); err != nil || count != 4 {
+		t.Fatalf("expected 4 records, got %d: %v", count, err)
+	}
+
+	// Writes outside of a transaction are cached by the database.
+	req := &proto.UpsertRequest{Table: "tests1", Data: []byte(`{"id": "5", "test_string": "e"}`)}
+	if _, err := lite.Upsert(ctx, req); err != nil {
+		t.Fatalf("failed to upsert: %v", err)
+	}
+
+	if lite.dbStmts.Len() != 1 {
+		t.Fatalf("expected 1 cached statement on the database, got %d", lite.dbStmts.Len())
+	}
+}
+
+func TestRead(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	lite, _ := newTestSQLite(t)
+
+	data := []byte(`[{"a": "2", "b": "x", "v": "late"}, {"a": "1", "b": "y", "v": "early"}]`)
+	if _, err := lite.Upsert(ctx, &proto.UpsertRequest{Table: "composite", Data: data}); err != nil {
+		t.Fatalf("failed to upsert: %v", err)
+	}
+
+	var got []string
+
+	err := lite.Read(ctx, &proto.ReadRecordsRequest{Table: "composite", OrderBy: "a"},
+		func(record map[string]interface{}) error {
+			val, ok := record["v"].(string)
+			if !ok {
+				t.Fatalf("expected text to be read as a string, got %T", record["v"])
+			}
+
+			got = append(got, val)
+
+			return nil
+		})
+	if err != nil {
+		t.Fatalf("failed to read: %v", err)
+	}
+
+	if len(got) != 2 || got[0] != "early" || got[1] != "late" {
+		t.Fatalf("expected the records ordered by a, got %v", got)
+	}
+}
+
+func TestCreateTable(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	lite, _ := newTestSQLite(t)
+
+	defer lite.Close()
+
+	req := &proto.CreateTableRequest{
+		Table:       "candles",
+		Columns:     []*proto.Column{{Name: "id", Kind: proto.KindInteger}, {Name: "close", Kind: proto.KindNumber}},
+		PrimaryKeys: []string{"id"},
+	}
+
+	txn, err := lite.StartTx(ctx)
+	if err != nil {
+		t.Fatalf("failed to start transaction: %v", err)
+	}
+
+	// The table is created and written to in the same transaction.
+	txn.Send(func(ctx context.Context, stg proto.Storage) error {
+		rsp, err := lite.CreateTable(ctx, req)
+		if err != nil || !rsp.Created {
+			return fmt.Errorf("expected the table to be created, got %+v: %w", rsp, err)
+		}
+
+		data := []byte(`[{"id": 1, "close": 1.5}, {"id": 1, "close": 2.5}]`)
+		_, err = stg.Upsert(ctx, &proto.UpsertRequest{Table: "candles", Data: data})
+
+		return err
+	})
+
+	if err := txn.Commit(); err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+
+	var close float64
+	if err := lite.DB.QueryRow(`SELECT close FROM candles WHERE id = 1`).Scan(&close); err != nil || close != 2.5 {
+		t.Fatalf("expected the record to be upserted on the primary key, got %v: %v", close, err)
+	}
+
+	rsp, err := lite.CreateTable(ctx, req)
+	if err != nil || rsp.Created {
+		t.Fatalf("expected an existing table not to be created, got %+v: %v", rsp, err)
+	}
+}
+
+func TestAddColumns(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	lite, _ := newTestSQLite(t)
+
+	defer lite.Close()
+
+	req := &proto.AddColumnsRequest{
+		Table:   "tests1",
+		Columns: []*proto.Column{{Name: "id", Kind: proto.KindString}, {Name: "volume", Kind: proto.KindInteger}},
+		Strict:  true,
+	}
+
+	if _, err := lite.AddColumns(ctx, req); !errors.Is(err, proto.ErrUnknownColumns) {
+		t.Fatalf("expected a strict request to fail on the unknown column, got %v", err)
+	}
+
+	req.Strict = false
+
+	rsp, err := lite.AddColumns(ctx, req)
+	if err != nil || len(rsp.Added) != 1 || rsp.Added[0] != "volume" {
+		t.Fatalf("expected only the missing column to be added, got %+v: %v", rsp, err)
+	}
+
+	data := []byte(`[{"id": "1", "test_string": "a", "volume": 10}]`)
+	if _, err := lite.Upsert(ctx, &proto.UpsertRequest{Table: "tests1", Data: data}); err != nil {
+		t.Fatalf("failed to upsert: %v", err)
+	}
+
+	var volume int
+	if err := lite.DB.QueryRow(`SELECT volume FROM tests1 WHERE id = '1'`).Scan(&volume); err != nil || volume != 10 {
+		t.Fatalf("expected the new column to be written, got %v: %v", volume, err)
+	}
+
+	// A table that does not exist is left for the upsert to fail on.
+	rsp, err = lite.AddColumns(ctx, &proto.AddColumnsRequest{Table: "missing", Columns: req.Columns})
+	if err != nil || len(rsp.Added) != 0 {
+		t.Fatalf("expected no columns to be added to a missing table, got %+v: %v", rsp, err)
+	}
+}
+
+func TestDataSourceName(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct{ dns, want string }{
+		{dns: "sqlite://gidari.db", want: "file:gidari.db?_busy_timeout=5000&_journal_mode=WAL"},
+		{dns: "sqlite:///var/lib/gidari.db", want: "file:/var/lib/gidari.db?_busy_timeout=5000&_journal_mode=WAL"},
+		{dns: "sqlite://gidari.db?mode=rw", want: "file:gidari.db?mode=rw&_busy_timeout=5000&_journal_mode=WAL"},
+	} {
+		if got := dataSourceName(tcase.dns); got != tcase.want {
+			t.Fatalf("expected %q, got %q", tcase.want, got)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/alpstable/gidari/tools"
+)
+
+// Checkpoint is a file-backed record of the flattened requests that have been committed to storage during a run, so
+// that an interrupted run can be resumed without fetching them again. A nil "Checkpoint" records nothing.
+type Checkpoint struct {
+	path  string
+	clock tools.Clock
+
+	mu sync.Mutex
+
+	// Completed maps the key of each committed request to the time it was committed.
+	Completed map[string]time.Time `json:"completed"`
+
+	// Retries maps the key of each request that is being retried to its progress, so that a resumed run continues
+	// the backoff of the request rather than making it again straight away.
+	Retries map[string]*Retry `json:"retries,omitempty"`
+}
+
+// Retry is the progress of retrying a failed request.
+type Retry struct {
+	// Attempts is the number of attempts that have failed.
+	Attempts int `json:"attempts"`
+
+	// NotBefore is the earliest time that the next attempt may be made.
+	NotBefore time.Time `json:"notBefore"`
+}
+
+// OpenCheckpoint will load the checkpoint at "path" if "resume" is true. Otherwise, or if the file does not exist,
+// an empty checkpoint is returned that will replace any existing file on the first call to "Save".
+func OpenCheckpoint(path string, resume bool, clock tools.Clock) (*Checkpoint, error) {
+	checkpoint := &Checkpoint{
+		path:      path,
+		clock:     tools.ClockOrReal(clock),
+		Completed: make(map[string]time.Time),
+		Retries:   make(map[string]*Retry),
+	}
+
+	if !resume {
+		return checkpoint, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return checkpoint, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, checkpoint); err != nil {
+		return nil, fmt.Errorf("failed to decode checkpoint file %q: %w", path, err)
+	}
+
+	if checkpoint.Completed == nil {
+		checkpoint.Completed = make(map[string]time.Time)
+	}
+
+	if checkpoint.Retries == nil {
+		checkpoint.Retries = make(map[string]*Retry)
+	}
+
+	return checkpoint, nil
+}
+
+// Len returns the number of completed requests in the checkpoint.
+func (checkpoint *Checkpoint) Len() int {
+	if checkpoint == nil {
+		return 0
+	}
+
+	checkpoint.mu.Lock()
+	defer checkpoint.mu.Unlock()
+
+	return len(checkpoint.Completed)
+}
+
+// Done returns true if the request identified by "key" has been completed.
+func (checkpoint *Checkpoint) Done(key string) bool {
+	if checkpoint == nil {
+		return false
+	}
+
+	checkpoint.mu.Lock()
+	defer checkpoint.mu.Unlock()
+
+	_, ok := checkpoint.Completed[key]
+
+	return ok
+}
+
+// Complete will mark the requests identified by "keys" as completed.
+func (checkpoint *Checkpoint) Complete(keys ...string) {
+	if checkpoint == nil {
+		return
+	}
+
+	checkpoint.mu.Lock()
+	defer checkpoint.mu.Unlock()
+
+	now := checkpoint.clock.Now().UTC()
+	for _, key := range keys {
+		checkpoint.Completed[key] = now
+	}
+}
+
+// Retry returns the progress of retrying the request identified by "key", if it is being retried.
+func (checkpoint *Checkpoint) Retry(key string) (Retry, bool) {
+	if checkpoint == nil {
+		return Retry{}, false
+	}
+
+	checkpoint.mu.Lock()
+	defer checkpoint.mu.Unlock()
+
+	retry, ok := checkpoint.Retries[key]
+	if !ok {
+		return Retry{}, false
+	}
+
+	return *retry, true
+}
+
+// Backoff will record that "attempts" of the request identified by "key" have failed, and that the next attempt is
+// not made before "notBefore". The checkpoint is saved straight away, so that the backoff outlives the process.
+func (checkpoint *Checkpoint) Backoff(key string, attempts int, notBefore time.Time) error {
+	if checkpoint == nil {
+		return nil
+	}
+
+	checkpoint.mu.Lock()
+	defer checkpoint.mu.Unlock()
+
+	checkpoint.Retries[key] = &Retry{Attempts: attempts, NotBefore: notBefore.UTC()}
+
+	return writeJSON(checkpoint.path, checkpoint)
+}
+
+// ClearRetry will forget the retries of the request identified by "key", once it has succeeded or been given up on.
+func (checkpoint *Checkpoint) ClearRetry(key string) {
+	if checkpoint == nil {
+		return
+	}
+
+	checkpoint.mu.Lock()
+	defer checkpoint.mu.Unlock()
+
+	delete(checkpoint.Retries, key)
+}
+
+// Save will write the checkpoint to disk.
+func (checkpoint *Checkpoint) Save() error {
+	if checkpoint == nil {
+		return nil
+	}
+
+	checkpoint.mu.Lock()
+	defer checkpoint.mu.Unlock()
+
+	return writeJSON(checkpoint.path, checkpoint)
+}
+
+// Remove will delete the checkpoint file, which is done once a run has completed.
+func (checkpoint *Checkpoint) Remove() error {
+	if checkpoint == nil {
+		return nil
+	}
+
+	if err := os.Remove(checkpoint.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove checkpoint file: %w", err)
+	}
+
+	return nil
+}
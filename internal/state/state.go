@@ -0,0 +1,283 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/alpstable/gidari/tools"
+)
+
+// Watermark is the position up to which a request has been successfully ingested.
+type Watermark struct {
+	// Time is the end of the last contiguous timeseries chunk that was ingested.
+	Time time.Time `json:"time"`
+
+	// UpdatedAt is when the watermark was last advanced.
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Spend is the estimated spend of the HTTP requests made for a request across runs.
+type Spend struct {
+	// Requests is the number of HTTP requests that have been made.
+	Requests int64 `json:"requests"`
+
+	// Amount is the estimated spend of the requests, in "Currency".
+	Amount   float64 `json:"amount"`
+	Currency string  `json:"currency"`
+
+	// Since is when the spend was first recorded, and UpdatedAt when it was last added to.
+	Since     time.Time `json:"since"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Validator is what identifies the version of the last response to an HTTP request, which a conditional request
+// sends so that the web API can respond that the data has not been modified since.
+type Validator struct {
+	// ETag is the "ETag" header of the response, and LastModified its "Last-Modified" header.
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+
+	// UpdatedAt is when the validator was last recorded.
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Variable is a keyed variable that templates and run hooks read and write across runs, e.g. the cursor of an
+// incremental request that the watermarks do not cover.
+type Variable struct {
+	Value string `json:"value"`
+
+	// UpdatedAt is when the variable was last set.
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Store is a file-backed record of the watermarks of each request, so that later runs can pick up where the last
+// successful run stopped, of the spend of each request, of the validators of conditional requests, and of the keyed
+// variables of templates and run hooks. A nil "Store" records nothing.
+type Store struct {
+	path  string
+	clock tools.Clock
+
+	mu         sync.Mutex
+	Watermarks map[string]Watermark `json:"watermarks"`
+	Spend      map[string]Spend     `json:"spend,omitempty"`
+	Validators map[string]Validator `json:"validators,omitempty"`
+	Variables  map[string]Variable  `json:"variables,omitempty"`
+}
+
+// Open will load the state store at "path", using "clock" to timestamp updates. If the file does not exist, an empty
+// store is returned and the file is created on the first call to "Save".
+func Open(path string, clock tools.Clock) (*Store, error) {
+	store := &Store{
+		path:       path,
+		clock:      tools.ClockOrReal(clock),
+		Watermarks: make(map[string]Watermark),
+		Spend:      make(map[string]Spend),
+		Validators: make(map[string]Validator),
+		Variables:  make(map[string]Variable),
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, store); err != nil {
+		return nil, fmt.Errorf("failed to decode state file %q: %w", path, err)
+	}
+
+	if store.Watermarks == nil {
+		store.Watermarks = make(map[string]Watermark)
+	}
+
+	if store.Spend == nil {
+		store.Spend = make(map[string]Spend)
+	}
+
+	if store.Validators == nil {
+		store.Validators = make(map[string]Validator)
+	}
+
+	if store.Variables == nil {
+		store.Variables = make(map[string]Variable)
+	}
+
+	return store, nil
+}
+
+// Watermark will return the watermark for the request identified by "key", if one has been recorded.
+func (store *Store) Watermark(key string) (Watermark, bool) {
+	if store == nil {
+		return Watermark{}, false
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	mark, ok := store.Watermarks[key]
+
+	return mark, ok
+}
+
+// Advance will move the watermark for the request identified by "key" to "t". Watermarks only move forward, so
+// advancing to a time before the current watermark is a no-op.
+func (store *Store) Advance(key string, t time.Time) {
+	if store == nil {
+		return
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if mark, ok := store.Watermarks[key]; ok && !t.After(mark.Time) {
+		return
+	}
+
+	store.Watermarks[key] = Watermark{Time: t.UTC(), UpdatedAt: store.clock.Now().UTC()}
+}
+
+// AddSpend will add the spend of "requests" HTTP requests made for the request identified by "key", returning the
+// spend recorded across runs. Spend in another currency than the recorded spend replaces it, since the two cannot be
+// added up.
+func (store *Store) AddSpend(key string, requests int64, amount float64, currency string) Spend {
+	if store == nil {
+		return Spend{Requests: requests, Amount: amount, Currency: currency}
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	now := store.clock.Now().UTC()
+
+	spend, ok := store.Spend[key]
+	if !ok || spend.Currency != currency {
+		spend = Spend{Currency: currency, Since: now}
+	}
+
+	spend.Requests += requests
+	spend.Amount += amount
+	spend.UpdatedAt = now
+
+	store.Spend[key] = spend
+
+	return spend
+}
+
+// Validator will return the validator of the last response to the HTTP request identified by "key", if one has been
+// recorded.
+func (store *Store) Validator(key string) (Validator, bool) {
+	if store == nil {
+		return Validator{}, false
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	validator, ok := store.Validators[key]
+
+	return validator, ok
+}
+
+// SetValidator will record the validator of the last response to the HTTP request identified by "key".
+func (store *Store) SetValidator(key string, validator Validator) {
+	if store == nil {
+		return
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	validator.UpdatedAt = store.clock.Now().UTC()
+	store.Validators[key] = validator
+}
+
+// Variable will return the value of the variable "name", if it has been set.
+func (store *Store) Variable(name string) (string, bool) {
+	if store == nil {
+		return "", false
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	variable, ok := store.Variables[name]
+
+	return variable.Value, ok
+}
+
+// SetVariable will set the variable "name" to "value", which is written to disk with the rest of the store.
+func (store *Store) SetVariable(name, value string) {
+	if store == nil {
+		return
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.Variables[name] = Variable{Value: value, UpdatedAt: store.clock.Now().UTC()}
+}
+
+// Save will write the store to disk. The file is replaced atomically so that a crash while saving does not corrupt
+// the existing state.
+func (store *Store) Save() error {
+	if store == nil {
+		return nil
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	return writeJSON(store.path, store)
+}
+
+// writeJSON will encode "val" as JSON and write it to "path". The file is written to a temporary file first and then
+// renamed, so that readers never observe a partially written file.
+func writeJSON(path string, val interface{}) error {
+	data, err := json.MarshalIndent(val, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	file, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create state file: %w", err)
+	}
+
+	defer os.Remove(file.Name())
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+
+	if err := os.Rename(file.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace state file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,258 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alpstable/gidari/tools"
+)
+
+func TestStore(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "state", "gidari.json")
+
+	clock := tools.NewFakeClock(time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC))
+
+	store, err := Open(path, clock)
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+
+	if _, ok := store.Watermark("candles"); ok {
+		t.Fatalf("expected no watermark in an empty store")
+	}
+
+	mark := time.Date(2022, 5, 10, 0, 0, 0, 0, time.UTC)
+
+	store.Advance("candles", mark)
+	store.Advance("candles", mark.Add(-time.Hour))
+
+	if err := store.Save(); err != nil {
+		t.Fatalf("failed to save store: %v", err)
+	}
+
+	reopened, err := Open(path, clock)
+	if err != nil {
+		t.Fatalf("failed to reopen store: %v", err)
+	}
+
+	got, ok := reopened.Watermark("candles")
+	if !ok || !got.Time.Equal(mark) {
+		t.Fatalf("expected watermark %v, got %v", mark, got.Time)
+	}
+
+	if !got.UpdatedAt.Equal(clock.Now()) {
+		t.Fatalf("expected watermark to be updated at %v, got %v", clock.Now(), got.UpdatedAt)
+	}
+}
+
+func TestStoreSpend(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "gidari.json")
+
+	clock := tools.NewFakeClock(time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC))
+
+	store, err := Open(path, clock)
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+
+	store.AddSpend("candles", 1000, 2.5, "USD")
+
+	if err := store.Save(); err != nil {
+		t.Fatalf("failed to save store: %v", err)
+	}
+
+	reopened, err := Open(path, clock)
+	if err != nil {
+		t.Fatalf("failed to reopen store: %v", err)
+	}
+
+	clock.Advance(time.Hour)
+
+	spend := reopened.AddSpend("candles", 500, 1.25, "USD")
+	if spend.Requests != 1500 || spend.Amount != 3.75 {
+		t.Fatalf("expected a spend of 3.75 over 1500 requests, got %g over %d", spend.Amount, spend.Requests)
+	}
+
+	if !spend.Since.Equal(clock.Now().Add(-time.Hour)) || !spend.UpdatedAt.Equal(clock.Now()) {
+		t.Fatalf("expected the spend to be recorded since %v, got %v to %v", clock.Now().Add(-time.Hour),
+			spend.Since, spend.UpdatedAt)
+	}
+
+	// Spend in another currency cannot be added to the recorded spend.
+	if spend := reopened.AddSpend("candles", 10, 1, "EUR"); spend.Requests != 10 || spend.Amount != 1 {
+		t.Fatalf("expected the spend to be replaced, got %g over %d", spend.Amount, spend.Requests)
+	}
+
+	var nilStore *Store
+	if spend := nilStore.AddSpend("candles", 10, 1, "USD"); spend.Requests != 10 || spend.Amount != 1 {
+		t.Fatalf("expected a nil store to return the spend of the run, got %g over %d", spend.Amount, spend.Requests)
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "gidari.checkpoint.json")
+
+	checkpoint, err := OpenCheckpoint(path, false, nil)
+	if err != nil {
+		t.Fatalf("failed to open checkpoint: %v", err)
+	}
+
+	checkpoint.Complete("chunk-1", "chunk-2")
+
+	notBefore := time.Date(2022, 1, 1, 0, 0, 30, 0, time.UTC)
+	if err := checkpoint.Backoff("chunk-3", 2, notBefore); err != nil {
+		t.Fatalf("failed to record backoff: %v", err)
+	}
+
+	if err := checkpoint.Backoff("chunk-4", 1, notBefore); err != nil {
+		t.Fatalf("failed to record backoff: %v", err)
+	}
+
+	checkpoint.ClearRetry("chunk-4")
+
+	if err := checkpoint.Save(); err != nil {
+		t.Fatalf("failed to save checkpoint: %v", err)
+	}
+
+	resumed, err := OpenCheckpoint(path, true, nil)
+	if err != nil {
+		t.Fatalf("failed to resume checkpoint: %v", err)
+	}
+
+	if resumed.Len() != 2 || !resumed.Done("chunk-1") || resumed.Done("chunk-3") {
+		t.Fatalf("unexpected completed requests: %v", resumed.Completed)
+	}
+
+	if retry, ok := resumed.Retry("chunk-3"); !ok || retry.Attempts != 2 || !retry.NotBefore.Equal(notBefore) {
+		t.Fatalf("expected the backoff of chunk-3 to be resumed, got %+v", retry)
+	}
+
+	if _, ok := resumed.Retry("chunk-4"); ok {
+		t.Fatalf("expected the cleared retry of chunk-4 to not be resumed")
+	}
+
+	// Without resuming, a new run starts from an empty checkpoint.
+	fresh, err := OpenCheckpoint(path, false, nil)
+	if err != nil {
+		t.Fatalf("failed to open checkpoint: %v", err)
+	}
+
+	if fresh.Len() != 0 {
+		t.Fatalf("expected an empty checkpoint, got %d completed requests", fresh.Len())
+	}
+
+	if err := resumed.Remove(); err != nil {
+		t.Fatalf("failed to remove checkpoint: %v", err)
+	}
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected checkpoint file to be removed")
+	}
+}
+
+func TestStoreValidators(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "gidari.json")
+
+	clock := tools.NewFakeClock(time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC))
+
+	store, err := Open(path, clock)
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+
+	if _, ok := store.Validator("GET /candles candles"); ok {
+		t.Fatalf("expected no validator before one is recorded")
+	}
+
+	store.SetValidator("GET /candles candles", Validator{ETag: `"v1"`, LastModified: "Wed, 01 Jun 2022 00:00:00 GMT"})
+
+	if err := store.Save(); err != nil {
+		t.Fatalf("failed to save store: %v", err)
+	}
+
+	reopened, err := Open(path, clock)
+	if err != nil {
+		t.Fatalf("failed to reopen store: %v", err)
+	}
+
+	validator, ok := reopened.Validator("GET /candles candles")
+	if !ok || validator.ETag != `"v1"` || validator.LastModified != "Wed, 01 Jun 2022 00:00:00 GMT" {
+		t.Fatalf("expected the validator to be reloaded, got %+v", validator)
+	}
+
+	if !validator.UpdatedAt.Equal(clock.Now()) {
+		t.Fatalf("expected the validator to be updated at %v, got %v", clock.Now(), validator.UpdatedAt)
+	}
+
+	var nilStore *Store
+
+	nilStore.SetValidator("GET /candles candles", Validator{ETag: `"v1"`})
+
+	if _, ok := nilStore.Validator("GET /candles candles"); ok {
+		t.Fatalf("expected a nil store to have no validators")
+	}
+}
+
+func TestStoreVariables(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "gidari.json")
+
+	clock := tools.NewFakeClock(time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC))
+
+	store, err := Open(path, clock)
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+
+	if _, ok := store.Variable("last_cursor"); ok {
+		t.Fatalf("expected no variable before it is set")
+	}
+
+	store.SetVariable("last_cursor", "abc")
+	store.SetVariable("last_cursor", "def")
+
+	if err := store.Save(); err != nil {
+		t.Fatalf("failed to save store: %v", err)
+	}
+
+	reopened, err := Open(path, clock)
+	if err != nil {
+		t.Fatalf("failed to reopen store: %v", err)
+	}
+
+	if value, ok := reopened.Variable("last_cursor"); !ok || value != "def" {
+		t.Fatalf("expected the variable to be reloaded as %q, got %q (%t)", "def", value, ok)
+	}
+
+	if updated := reopened.Variables["last_cursor"].UpdatedAt; !updated.Equal(clock.Now()) {
+		t.Fatalf("expected the variable to be updated at %v, got %v", clock.Now(), updated)
+	}
+
+	var nilStore *Store
+
+	nilStore.SetVariable("last_cursor", "abc")
+
+	if _, ok := nilStore.Variable("last_cursor"); ok {
+		t.Fatalf("expected a nil store to have no variables")
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/internal/proto"
+	"github.com/alpstable/gidari/internal/repository"
+	"github.com/alpstable/gidari/tools"
+)
+
+// autoCreateSampleSize is the number of records of the first write to a table that its columns are inferred from.
+const autoCreateSampleSize = 100
+
+// createdTables are the tables of each repository that have been created, or were found to exist, during the run.
+type createdTables struct {
+	mu     sync.Mutex
+	tables map[string]bool
+}
+
+// has returns true if the table of a repository has been created.
+func (created *createdTables) has(idx int, table string) bool {
+	if created == nil {
+		return false
+	}
+
+	created.mu.Lock()
+	defer created.mu.Unlock()
+
+	return created.tables[fmt.Sprintf("%d/%s", idx, table)]
+}
+
+// add will mark the table of a repository as created.
+func (created *createdTables) add(idx int, table string) {
+	if created == nil {
+		return
+	}
+
+	created.mu.Lock()
+	defer created.mu.Unlock()
+
+	if created.tables == nil {
+		created.tables = make(map[string]bool)
+	}
+
+	created.tables[fmt.Sprintf("%d/%s", idx, table)] = true
+}
+
+// tableColumns returns the columns to create a table with: those inferred from a sample of its records and the
+// known columns that the sample does not have, with the column types of the table settings and then of the type
+// mapping of the storage scheme.
+func tableColumns(create *config.AutoCreate, known, inferred []*proto.Column, scheme string) []*proto.Column {
+	columns := make([]*proto.Column, 0, len(inferred)+len(known))
+	names := make(map[string]bool, len(inferred)+len(known))
+
+	for _, column := range append(append([]*proto.Column(nil), inferred...), known...) {
+		if names[column.Name] {
+			continue
+		}
+
+		names[column.Name] = true
+
+		// The known columns are shared, so every column is copied before its type is set.
+		column := &proto.Column{Name: column.Name, Kind: column.Kind, Type: column.Type}
+		if typ, ok := create.ColumnTypes[column.Name]; ok {
+			column.Type = typ
+		} else if typ, ok := create.TypeMapping[strings.ToLower(scheme)][column.Kind]; ok {
+			column.Type = typ
+		}
+
+		columns = append(columns, column)
+	}
+
+	return columns
+}
+
+// createTable will create the table of an upsert request on a repository if it does not exist, from the columns
+// inferred from a sample of the records of the request. Since the functions sent to the transaction of a repository
+// are run one at a time, each table is only created once per repository in a run.
+func createTable(ctx context.Context, workerID int, cfg *repoConfig, idx int, repo repository.Generic,
+	write tableWrite, req *proto.UpsertRequest,
+) error {
+	if write.autoCreate == nil || repo.IsNoSQL() || cfg.created.has(idx, req.Table) {
+		return nil
+	}
+
+	start := time.Now()
+
+	records, err := proto.DecodeUpsertRequest(req)
+	if err != nil {
+		return fmt.Errorf("unable to decode records: %w", err)
+	}
+
+	// Columns cannot be inferred without records, so the table is created by a later write.
+	if len(records) == 0 {
+		return nil
+	}
+
+	if len(records) > autoCreateSampleSize {
+		records = records[:autoCreateSampleSize]
+	}
+
+	scheme := proto.SchemeFromStorageType(repo.Type())
+
+	rsp, err := repo.CreateTable(ctx, &proto.CreateTableRequest{
+		Table:       req.Table,
+		Columns:     tableColumns(write.autoCreate, write.columns, proto.InferColumns(records), scheme),
+		PrimaryKeys: write.autoCreate.PrimaryKeys,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to create table %q: %w", req.Table, err)
+	}
+
+	cfg.created.add(idx, req.Table)
+
+	if rsp.Created {
+		logInfo := tools.LogFormatter{
+			WorkerID:   workerID,
+			WorkerName: "repository",
+			Duration:   time.Since(start),
+			Msg:        fmt.Sprintf("created table %s.%s", scheme, req.Table),
+		}
+		cfg.logger.Infof(logInfo.String())
+	}
+
+	return nil
+}
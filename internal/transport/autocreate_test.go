@@ -0,0 +1,70 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/internal/proto"
+)
+
+func TestTableColumns(t *testing.T) {
+	t.Parallel()
+
+	create := &config.AutoCreate{
+		ColumnTypes: map[string]string{"price": "NUMERIC(18,8)"},
+		TypeMapping: map[string]map[string]string{"postgresql": {proto.KindTimestamp: "TIMESTAMP"}},
+	}
+
+	known := []*proto.Column{{Name: "id", Kind: proto.KindString}, {Name: "closed_at", Kind: proto.KindTimestamp}}
+	inferred := []*proto.Column{
+		{Name: "id", Kind: proto.KindInteger},
+		{Name: "opened_at", Kind: proto.KindTimestamp},
+		{Name: "price", Kind: proto.KindNumber},
+	}
+
+	want := []*proto.Column{
+		{Name: "id", Kind: proto.KindInteger},
+		{Name: "opened_at", Kind: proto.KindTimestamp, Type: "TIMESTAMP"},
+		{Name: "price", Kind: proto.KindNumber, Type: "NUMERIC(18,8)"},
+		{Name: "closed_at", Kind: proto.KindTimestamp, Type: "TIMESTAMP"},
+	}
+
+	if got := tableColumns(create, known, inferred, "postgresql"); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+
+	if known[1].Type != "" {
+		t.Fatalf("expected the known columns not to be modified, got %+v", known[1])
+	}
+
+	// Other storage keeps its default column types.
+	if got := tableColumns(create, nil, inferred[1:2], "sqlite"); got[0].Type != "" {
+		t.Fatalf("expected the type mapping of another scheme not to apply, got %+v", got[0])
+	}
+}
+
+func TestCreatedTables(t *testing.T) {
+	t.Parallel()
+
+	created := new(createdTables)
+	created.add(0, "trades")
+
+	if !created.has(0, "trades") || created.has(1, "trades") || created.has(0, "quotes") {
+		t.Fatalf("expected tables to be created per repository, got %v", created.tables)
+	}
+
+	var none *createdTables
+	if none.has(0, "trades") {
+		t.Fatal("expected no tables to be created without a tracker")
+	}
+}
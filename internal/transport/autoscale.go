@@ -0,0 +1,186 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/internal/web"
+	"github.com/alpstable/gidari/tools"
+	"github.com/sirupsen/logrus"
+)
+
+// autoscalePollInterval is how often a web worker that is waiting to fetch from a host re-checks its limit.
+const autoscalePollInterval = 10 * time.Millisecond
+
+// hostScale is how many web workers may fetch from a host at once, and how many are.
+type hostScale struct {
+	limit    int
+	inFlight int
+
+	// successes are the responses received within the target latency since the limit last changed.
+	successes int
+}
+
+// autoscaler grows and shrinks the number of web workers that fetch from each host at once. A host's limit grows
+// by one once as many responses as the limit are received within the target latency without waiting on the rate
+// limiter, i.e. while there is rate limit budget to spare. It shrinks by one with each slow response, and is halved
+// by each response that is retried, such as "429 Too Many Requests" and server errors. A nil "autoscaler" imposes no
+// limits.
+type autoscaler struct {
+	least   int
+	most    int
+	initial int
+	latency time.Duration
+	logger  *logrus.Logger
+	clock   tools.Clock
+
+	mu    sync.Mutex
+	hosts map[string]*hostScale
+}
+
+// newAutoscaler will create an autoscaler whose hosts start out with as many web workers as "threads". If the
+// configuration is nil, this function will return nil.
+func newAutoscaler(cfg *config.AutoscaleConfig, threads int, logger *logrus.Logger, clock tools.Clock) *autoscaler {
+	if cfg == nil {
+		return nil
+	}
+
+	least, most := cfg.Workers()
+
+	initial := threads
+	if initial < least {
+		initial = least
+	}
+
+	if initial > most {
+		initial = most
+	}
+
+	return &autoscaler{
+		least:   least,
+		most:    most,
+		initial: initial,
+		latency: cfg.Latency(),
+		logger:  logger,
+		clock:   tools.ClockOrReal(clock),
+		hosts:   make(map[string]*hostScale),
+	}
+}
+
+// workers returns the number of web workers to start, which is the most that may fetch from a host at once.
+func (scaler *autoscaler) workers(threads int) int {
+	if scaler == nil {
+		return threads
+	}
+
+	return scaler.most
+}
+
+// host returns the scale of a host. The caller must hold the autoscaler's lock.
+func (scaler *autoscaler) host(host string) *hostScale {
+	scale := scaler.hosts[host]
+	if scale == nil {
+		scale = &hostScale{limit: scaler.initial}
+		scaler.hosts[host] = scale
+	}
+
+	return scale
+}
+
+// tryAcquire returns true, and counts the fetch as in-flight, if the limit of a host allows another fetch.
+func (scaler *autoscaler) tryAcquire(host string) bool {
+	scaler.mu.Lock()
+	defer scaler.mu.Unlock()
+
+	scale := scaler.host(host)
+	if scale.inFlight >= scale.limit {
+		return false
+	}
+
+	scale.inFlight++
+
+	return true
+}
+
+// acquire will block until the limit of a host allows another fetch.
+func (scaler *autoscaler) acquire(ctx context.Context, host string) error {
+	if scaler == nil {
+		return nil
+	}
+
+	for !scaler.tryAcquire(host) {
+		if err := tools.Sleep(ctx, scaler.clock, autoscalePollInterval); err != nil {
+			return fmt.Errorf("waiting for a web worker: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// release will mark a fetch from a host as no longer in-flight, and grow or shrink the limit of the host for how
+// long its response took, not counting the wait on the rate limiter, and whether it is retried.
+func (scaler *autoscaler) release(host string, elapsed time.Duration, rsp *web.FetchResponse, err error) {
+	if scaler == nil {
+		return
+	}
+
+	scaler.mu.Lock()
+	defer scaler.mu.Unlock()
+
+	scale := scaler.host(host)
+	scale.inFlight--
+
+	limit, reason := scale.limit, ""
+
+	switch {
+	case err != nil && retryable(err):
+		limit, reason = scale.limit/2, fmt.Sprintf("request failed: %v", err)
+	case err != nil:
+		// Errors that are not retried say nothing about the load on the host.
+		return
+	case elapsed-rsp.RateLimitWait > scaler.latency:
+		limit = scale.limit - 1
+		reason = fmt.Sprintf("response took %s, target is %s", elapsed-rsp.RateLimitWait, scaler.latency)
+	case rsp.RateLimitWait > 0:
+		// The rate limit is used up, so more web workers would only wait on it.
+		return
+	default:
+		if scale.successes++; scale.successes < scale.limit {
+			return
+		}
+
+		limit, reason = scale.limit+1, "responses are within the target latency"
+	}
+
+	if limit < scaler.least {
+		limit = scaler.least
+	}
+
+	if limit > scaler.most {
+		limit = scaler.most
+	}
+
+	scale.successes = 0
+
+	if limit == scale.limit {
+		return
+	}
+
+	logInfo := tools.LogFormatter{
+		WorkerName: "web",
+		Msg:        fmt.Sprintf("scaling web workers for %s from %d to %d, %s", host, scale.limit, limit, reason),
+	}
+	scaler.logger.Info(logInfo.String())
+
+	scale.limit = limit
+}
@@ -0,0 +1,127 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/internal/web"
+	"github.com/sirupsen/logrus"
+)
+
+func newTestAutoscaler(threads int) *autoscaler {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	cfg := &config.AutoscaleConfig{MinWorkers: 2, MaxWorkers: 8, TargetLatency: "500ms"}
+
+	return newAutoscaler(cfg, threads, logger, nil)
+}
+
+func TestAutoscalerRelease(t *testing.T) {
+	t.Parallel()
+
+	fast := &web.FetchResponse{}
+	limited := &web.FetchResponse{RateLimitWait: time.Second}
+	throttled := &web.ResponseError{StatusCode: http.StatusTooManyRequests}
+	notFound := &web.ResponseError{StatusCode: http.StatusNotFound}
+
+	type response struct {
+		elapsed time.Duration
+		rsp     *web.FetchResponse
+		err     error
+	}
+
+	for _, tcase := range []struct {
+		name      string
+		threads   int
+		responses []response
+		want      int
+	}{
+		{name: "initial", threads: 4, want: 4},
+		{name: "initial at least", threads: 1, want: 2},
+		{name: "initial at most", threads: 16, want: 8},
+		{
+			name:      "grows once the limit is received",
+			threads:   2,
+			responses: []response{{rsp: fast}, {rsp: fast}, {rsp: fast}},
+			want:      3,
+		},
+		{name: "grows at most", threads: 8, responses: []response{{rsp: fast}}, want: 8},
+		{
+			name:      "rate limited",
+			threads:   2,
+			responses: []response{{elapsed: time.Second, rsp: limited}, {elapsed: time.Second, rsp: limited}},
+			want:      2,
+		},
+		{name: "slow", threads: 4, responses: []response{{elapsed: time.Second, rsp: fast}}, want: 3},
+		{name: "throttled", threads: 8, responses: []response{{err: throttled}}, want: 4},
+		{name: "shrinks at least", threads: 2, responses: []response{{err: errors.New("connection reset")}}, want: 2},
+		{name: "not retried", threads: 4, responses: []response{{err: notFound}}, want: 4},
+		{
+			name:      "slow responses reset the successes",
+			threads:   3,
+			responses: []response{{rsp: fast}, {elapsed: time.Second, rsp: fast}, {rsp: fast}},
+			want:      2,
+		},
+	} {
+		scaler := newTestAutoscaler(tcase.threads)
+
+		for _, rsp := range tcase.responses {
+			if !scaler.tryAcquire("example.com") {
+				t.Fatalf("%s: expected the fetch to be allowed", tcase.name)
+			}
+
+			scaler.release("example.com", rsp.elapsed, rsp.rsp, rsp.err)
+		}
+
+		if got := scaler.host("example.com").limit; got != tcase.want {
+			t.Fatalf("%s: expected a limit of %d, got %d", tcase.name, tcase.want, got)
+		}
+	}
+}
+
+func TestAutoscalerAcquire(t *testing.T) {
+	t.Parallel()
+
+	scaler := newTestAutoscaler(2)
+
+	for idx := 0; idx < 2; idx++ {
+		if err := scaler.acquire(context.Background(), "example.com"); err != nil {
+			t.Fatalf("failed to acquire: %v", err)
+		}
+	}
+
+	if scaler.tryAcquire("example.com") {
+		t.Fatal("expected the fetch to wait for the limit of the host")
+	}
+
+	if !scaler.tryAcquire("other.com") {
+		t.Fatal("expected every host to have its own limit")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := scaler.acquire(ctx, "example.com"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected error %v, got %v", context.Canceled, err)
+	}
+
+	var unset *autoscaler
+	if err := unset.acquire(ctx, "example.com"); err != nil || unset.workers(4) != 4 {
+		t.Fatalf("expected a nil autoscaler to impose no limits, got %v", err)
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/tools"
+)
+
+// ErrBudgetExceeded is returned when a run reaches one of its configured "limits". The data fetched before the limit
+// was reached is committed and recorded in the checkpoint.
+var ErrBudgetExceeded = fmt.Errorf("budget exceeded")
+
+// budget enforces the "limits" of a run. When a limit is reached, the run is canceled so that it shuts down the same
+// way as an interrupted run. A nil budget is unlimited.
+type budget struct {
+	limits *config.Limits
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	requests int
+	rows     int
+	cost     float64
+	exceeded string
+}
+
+func newBudget(limits *config.Limits, cancel context.CancelFunc) *budget {
+	if limits == nil {
+		return nil
+	}
+
+	return &budget{limits: limits, cancel: cancel}
+}
+
+// reserve will account for an HTTP request of the given cost, returning false if making it would exceed the limits.
+func (bgt *budget) reserve(cost float64) bool {
+	if bgt == nil {
+		return true
+	}
+
+	bgt.mu.Lock()
+	defer bgt.mu.Unlock()
+
+	if bgt.exceeded != "" {
+		return false
+	}
+
+	if limit := bgt.limits.MaxRequests; limit > 0 && bgt.requests+1 > limit {
+		bgt.exceed(fmt.Sprintf("maxRequests of %d reached", limit))
+
+		return false
+	}
+
+	if limit := bgt.limits.MaxCost; limit > 0 && bgt.cost+cost > limit {
+		bgt.exceed(fmt.Sprintf("maxCost of %g reached", limit))
+
+		return false
+	}
+
+	bgt.requests++
+	bgt.cost += cost
+
+	return true
+}
+
+// countsRows returns true if the records of each response have to be counted to enforce the limits.
+func (bgt *budget) countsRows() bool {
+	return bgt != nil && bgt.limits.MaxRows > 0
+}
+
+// addRows will account for the records received in a response. Reaching "maxRows" stops any new requests, but the
+// responses that have already been received are still stored.
+func (bgt *budget) addRows(rows int) {
+	if bgt == nil {
+		return
+	}
+
+	bgt.mu.Lock()
+	defer bgt.mu.Unlock()
+
+	bgt.rows += rows
+
+	if limit := bgt.limits.MaxRows; limit > 0 && bgt.rows >= limit && bgt.exceeded == "" {
+		bgt.exceed(fmt.Sprintf("maxRows of %d reached", limit))
+	}
+}
+
+// exceed will record why the budget was exceeded and cancel the run. The lock must be held.
+func (bgt *budget) exceed(reason string) {
+	bgt.exceeded = reason
+	bgt.cancel()
+}
+
+// err returns "ErrBudgetExceeded" with a summary of the usage if a limit was reached, and nil otherwise.
+func (bgt *budget) err() error {
+	if bgt == nil {
+		return nil
+	}
+
+	bgt.mu.Lock()
+	defer bgt.mu.Unlock()
+
+	if bgt.exceeded == "" {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s after %s", ErrBudgetExceeded, bgt.exceeded, bgt.summaryLocked())
+}
+
+// summary describes the usage of the budget.
+func (bgt *budget) summary() string {
+	bgt.mu.Lock()
+	defer bgt.mu.Unlock()
+
+	return bgt.summaryLocked()
+}
+
+func (bgt *budget) summaryLocked() string {
+	return fmt.Sprintf("%d requests, %d rows, cost %g", bgt.requests, bgt.rows, bgt.cost)
+}
+
+// warnBudget will log a warning if the planned requests of a run cannot all be made within its limits, which usually
+// means that a timeseries period is smaller than intended.
+func warnBudget(cfg *config.Config, fetches []*flattenedRequest) {
+	if cfg.Limits == nil {
+		return
+	}
+
+	var cost float64
+	for _, req := range fetches {
+		cost += req.cost
+	}
+
+	if limit := cfg.Limits.MaxRequests; limit > 0 && len(fetches) > limit {
+		logWarn := tools.LogFormatter{
+			Msg: fmt.Sprintf("run plans %d requests, only %d will be made within limits.maxRequests", len(fetches), limit),
+		}
+		cfg.Logger.Warn(logWarn.String())
+	}
+
+	if limit := cfg.Limits.MaxCost; limit > 0 && cost > limit {
+		logWarn := tools.LogFormatter{
+			Msg: fmt.Sprintf("run plans requests costing %g, more than limits.maxCost of %g", cost, limit),
+		}
+		cfg.Logger.Warn(logWarn.String())
+	}
+}
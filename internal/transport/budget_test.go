@@ -0,0 +1,75 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alpstable/gidari/config"
+)
+
+func TestBudget(t *testing.T) {
+	t.Parallel()
+
+	t.Run("unlimited", func(t *testing.T) {
+		t.Parallel()
+
+		var bgt *budget
+		if !bgt.reserve(1) || bgt.countsRows() || bgt.err() != nil {
+			t.Fatalf("expected a nil budget to be unlimited")
+		}
+
+		bgt.addRows(10)
+	})
+
+	for _, tcase := range []struct {
+		name     string
+		limits   config.Limits
+		cost     float64
+		rows     int
+		reserved int
+	}{
+		{name: "max requests", limits: config.Limits{MaxRequests: 3}, cost: 1, reserved: 3},
+		{name: "max cost", limits: config.Limits{MaxCost: 5}, cost: 2, reserved: 2},
+		{name: "max rows", limits: config.Limits{MaxRows: 25}, cost: 1, rows: 10, reserved: 3},
+	} {
+		tcase := tcase
+
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			bgt := newBudget(&tcase.limits, cancel)
+
+			reserved := 0
+			for idx := 0; idx < 10 && bgt.reserve(tcase.cost); idx++ {
+				reserved++
+
+				bgt.addRows(tcase.rows)
+			}
+
+			if reserved != tcase.reserved {
+				t.Fatalf("expected %d requests within the budget, got %d", tcase.reserved, reserved)
+			}
+
+			if ctx.Err() == nil {
+				t.Fatalf("expected the run to be canceled")
+			}
+
+			if err := bgt.err(); !errors.Is(err, ErrBudgetExceeded) {
+				t.Fatalf("expected %v, got %v", ErrBudgetExceeded, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"fmt"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/tools"
+)
+
+// ErrCanaryFailed is returned when a canary request fails, or the "canaryAssertions" do not hold, in which case
+// none of the other requests of the run are made. The data of the canary requests has been committed.
+var ErrCanaryFailed = fmt.Errorf("canary failed")
+
+// splitCanaries will separate the canary requests from the other requests, keeping the order of both.
+func splitCanaries(reqs []*flattenedRequest) (canaries, others []*flattenedRequest) {
+	for _, req := range reqs {
+		if req.canary {
+			canaries = append(canaries, req)
+		} else {
+			others = append(others, req)
+		}
+	}
+
+	return canaries, others
+}
+
+// checkCanaries will return an error if any of the canary requests was dead-lettered or canceled, or if the
+// "canaryAssertions" do not hold for the metrics recorded by the canary requests.
+func checkCanaries(cfg *config.Config, canaries []*flattenedRequest, metrics *runMetrics, others int) error {
+	failed := 0
+
+	for _, req := range canaries {
+		if req.failed || !req.done {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%w: %d of %d canary requests did not succeed, skipping %d requests", ErrCanaryFailed,
+			failed, len(canaries), others)
+	}
+
+	if err := metrics.assert(cfg.CanaryAssertions); err != nil {
+		return fmt.Errorf("%w: skipping %d requests: %v", ErrCanaryFailed, others, err)
+	}
+
+	logInfo := tools.LogFormatter{
+		Msg: fmt.Sprintf("%d canary requests succeeded, making %d requests", len(canaries), others),
+	}
+	cfg.Logger.Info(logInfo.String())
+
+	return nil
+}
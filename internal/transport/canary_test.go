@@ -0,0 +1,91 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/sirupsen/logrus"
+)
+
+func TestSplitCanaries(t *testing.T) {
+	t.Parallel()
+
+	reqs := []*flattenedRequest{
+		{table: "trades"},
+		{table: "accounts", canary: true},
+		{table: "candles"},
+		{table: "fills", canary: true},
+	}
+
+	canaries, others := splitCanaries(reqs)
+
+	if want := []*flattenedRequest{reqs[1], reqs[3]}; !reflect.DeepEqual(canaries, want) {
+		t.Fatalf("expected canaries %v, got %v", want, canaries)
+	}
+
+	if want := []*flattenedRequest{reqs[0], reqs[2]}; !reflect.DeepEqual(others, want) {
+		t.Fatalf("expected others %v, got %v", want, others)
+	}
+}
+
+func TestCheckCanaries(t *testing.T) {
+	t.Parallel()
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	for _, tcase := range []struct {
+		name       string
+		canaries   []*flattenedRequest
+		rows       int
+		assertions []*config.Assertion
+		err        error
+	}{
+		{name: "succeeded", canaries: []*flattenedRequest{{done: true}}, rows: 1},
+		{
+			name:     "dead-lettered",
+			canaries: []*flattenedRequest{{done: true}, {done: true, failed: true}},
+			err:      ErrCanaryFailed,
+		},
+		{name: "canceled", canaries: []*flattenedRequest{{done: true}, {}}, err: ErrCanaryFailed},
+		{
+			name:       "assertions hold",
+			canaries:   []*flattenedRequest{{done: true}},
+			rows:       2,
+			assertions: []*config.Assertion{{Metric: "rows.accounts", Min: "1"}},
+		},
+		{
+			name:       "assertions fail",
+			canaries:   []*flattenedRequest{{done: true}},
+			assertions: []*config.Assertion{{Metric: "rows.accounts", Min: "1"}},
+			err:        ErrCanaryFailed,
+		},
+	} {
+		tcase := tcase
+
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := &config.Config{Logger: logger, CanaryAssertions: tcase.assertions}
+
+			metrics := newRunMetrics(cfg)
+			metrics.addRows("accounts", tcase.rows)
+
+			if err := checkCanaries(cfg, tcase.canaries, metrics, 3); !errors.Is(err, tcase.err) {
+				t.Fatalf("expected error %v, got %v", tcase.err, err)
+			}
+		})
+	}
+}
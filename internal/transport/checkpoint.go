@@ -0,0 +1,128 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"fmt"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/internal/state"
+	"github.com/alpstable/gidari/tools"
+)
+
+// checkpointKey uniquely identifies a flattened request in the checkpoint.
+func (req *flattenedRequest) checkpointKey() string {
+	return req.table + " " + req.fetchKey()
+}
+
+// openCheckpoint will open the checkpoint from the configuration, returning nil if checkpointing is disabled.
+func openCheckpoint(cfg *config.Config) (*state.Checkpoint, error) {
+	if cfg.Checkpoint == nil && !cfg.Resume {
+		return nil, nil
+	}
+
+	path := config.DefaultCheckpointFile
+	if cfg.Checkpoint != nil && cfg.Checkpoint.File != "" {
+		path = cfg.Checkpoint.File
+	}
+
+	checkpoint, err := state.OpenCheckpoint(path, cfg.Resume, cfg.Clock)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open checkpoint: %w", err)
+	}
+
+	return checkpoint, nil
+}
+
+// checkpointEvery returns the number of flattened requests to commit in each batch. Zero means that everything is
+// committed in a single batch at the end of the run.
+func checkpointEvery(cfg *config.Config) int {
+	if cfg.Checkpoint == nil && !cfg.Resume {
+		return 0
+	}
+
+	if cfg.Checkpoint != nil && cfg.Checkpoint.Every > 0 {
+		return cfg.Checkpoint.Every
+	}
+
+	return config.DefaultCheckpointEvery
+}
+
+// skipCompleted will remove the requests that were completed by a previous run from "reqs". The skipped chunks still
+// count towards the watermarks of their timeseries requests.
+func skipCompleted(cfg *config.Config, reqs []*flattenedRequest, checkpoint *state.Checkpoint) []*flattenedRequest {
+	if checkpoint.Len() == 0 {
+		return reqs
+	}
+
+	remaining := make([]*flattenedRequest, 0, len(reqs))
+
+	for _, req := range reqs {
+		if !checkpoint.Done(req.checkpointKey()) {
+			remaining = append(remaining, req)
+
+			continue
+		}
+
+		req.progress.complete(req.page)
+	}
+
+	logInfo := tools.LogFormatter{
+		Msg: fmt.Sprintf("resuming run, skipping %d completed requests", len(reqs)-len(remaining)),
+	}
+	cfg.Logger.Info(logInfo.String())
+
+	return remaining
+}
+
+// batchRequests will split the requests into batches of at most "size" requests. If "size" is not positive, all of
+// the requests are returned in a single batch.
+func batchRequests(reqs []*flattenedRequest, size int) [][]*flattenedRequest {
+	if len(reqs) == 0 {
+		return nil
+	}
+
+	if size <= 0 {
+		return [][]*flattenedRequest{reqs}
+	}
+
+	batches := make([][]*flattenedRequest, 0, (len(reqs)+size-1)/size)
+
+	for len(reqs) > size {
+		batches = append(batches, reqs[:size])
+		reqs = reqs[size:]
+	}
+
+	return append(batches, reqs)
+}
+
+// completeBatch will record every done request in the batch, including coalesced requests, in the checkpoint and
+// save it.
+func completeBatch(checkpoint *state.Checkpoint, batch []*flattenedRequest) error {
+	if checkpoint == nil {
+		return nil
+	}
+
+	keys := make([]string, 0, len(batch))
+
+	for _, req := range batch {
+		for _, target := range append([]*flattenedRequest{req}, req.coalesced...) {
+			if target.done {
+				keys = append(keys, target.checkpointKey())
+			}
+		}
+	}
+
+	checkpoint.Complete(keys...)
+
+	if err := checkpoint.Save(); err != nil {
+		return fmt.Errorf("unable to save checkpoint: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,229 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/internal/state"
+	"github.com/alpstable/gidari/internal/web"
+	"github.com/alpstable/gidari/tools"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+)
+
+func newCheckpointTestRequests(count int) []*flattenedRequest {
+	start := time.Date(2022, 5, 10, 0, 0, 0, 0, time.UTC)
+	chunks := make([][2]time.Time, count)
+	progress := newTimeseriesProgress("candles", chunks)
+	reqs := make([]*flattenedRequest, count)
+
+	for idx := range reqs {
+		chunks[idx] = [2]time.Time{start.Add(time.Duration(idx) * time.Hour), start.Add(time.Duration(idx+1) * time.Hour)}
+		rurl := &url.URL{Scheme: "https", Host: "api.example.com", RawQuery: fmt.Sprintf("page=%d", idx)}
+		reqs[idx] = &flattenedRequest{
+			fetchConfig: &web.FetchConfig{Method: "GET", URL: rurl},
+			table:       "candles",
+			page:        idx + 1,
+			progress:    progress,
+		}
+	}
+
+	return reqs
+}
+
+func TestBatchRequests(t *testing.T) {
+	t.Parallel()
+
+	reqs := newCheckpointTestRequests(5)
+
+	for _, tcase := range []struct {
+		size  int
+		sizes []int
+	}{
+		{size: 0, sizes: []int{5}},
+		{size: 2, sizes: []int{2, 2, 1}},
+		{size: 5, sizes: []int{5}},
+		{size: 10, sizes: []int{5}},
+	} {
+		batches := batchRequests(reqs, tcase.size)
+		if len(batches) != len(tcase.sizes) {
+			t.Fatalf("expected %d batches for size %d, got %d", len(tcase.sizes), tcase.size, len(batches))
+		}
+
+		for idx, batch := range batches {
+			if len(batch) != tcase.sizes[idx] {
+				t.Fatalf("expected batch %d to have %d requests, got %d", idx, tcase.sizes[idx], len(batch))
+			}
+		}
+	}
+}
+
+func TestSkipCompleted(t *testing.T) {
+	t.Parallel()
+
+	reqs := newCheckpointTestRequests(3)
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+
+	checkpoint, err := state.OpenCheckpoint(path, false, nil)
+	if err != nil {
+		t.Fatalf("failed to open checkpoint: %v", err)
+	}
+
+	reqs[0].done, reqs[1].done = true, true
+
+	if err := completeBatch(checkpoint, reqs[:2]); err != nil {
+		t.Fatalf("failed to complete batch: %v", err)
+	}
+
+	resumed, err := state.OpenCheckpoint(path, true, nil)
+	if err != nil {
+		t.Fatalf("failed to resume checkpoint: %v", err)
+	}
+
+	cfg := &config.Config{Logger: logrus.New()}
+
+	remaining := skipCompleted(cfg, reqs, resumed)
+	if len(remaining) != 1 || remaining[0] != reqs[2] {
+		t.Fatalf("expected only the last request to remain, got %d", len(remaining))
+	}
+
+	// The skipped chunks count towards the watermark.
+	if mark, ok := reqs[0].progress.watermark(); !ok || !mark.Equal(reqs[1].progress.chunks[1][1]) {
+		t.Fatalf("unexpected watermark: %v", mark)
+	}
+}
+
+func TestCheckpointRetries(t *testing.T) {
+	t.Parallel()
+
+	var fetches int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	t.Cleanup(server.Close)
+
+	rurl, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("failed to parse url: %v", err)
+	}
+
+	client, err := web.NewClient(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	clock := tools.NewFakeClock(time.Date(2022, 5, 10, 0, 0, 0, 0, time.UTC))
+	req := &flattenedRequest{
+		fetchConfig: &web.FetchConfig{
+			C:           client,
+			Method:      http.MethodGet,
+			URL:         rurl,
+			RateLimiter: rate.NewLimiter(rate.Inf, 1),
+		},
+	}
+
+	// A previous run failed two attempts and was stopped while backing off.
+	path := filepath.Join(t.TempDir(), "gidari.checkpoint.json")
+
+	previous, err := state.OpenCheckpoint(path, false, clock)
+	if err != nil {
+		t.Fatalf("failed to open checkpoint: %v", err)
+	}
+
+	if err := previous.Backoff(req.fetchKey(), 2, clock.Now().Add(30*time.Second)); err != nil {
+		t.Fatalf("failed to record backoff: %v", err)
+	}
+
+	checkpoint, err := state.OpenCheckpoint(path, true, clock)
+	if err != nil {
+		t.Fatalf("failed to resume checkpoint: %v", err)
+	}
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	job := &webJob{
+		flattenedRequest: req,
+		runResources:     &runResources{retries: 3, checkpoint: checkpoint},
+		logger:           logger,
+		clock:            clock,
+	}
+
+	type result struct {
+		attempts int
+		err      error
+	}
+
+	done := make(chan result, 1)
+
+	go func() {
+		_, attempts, err := fetch(context.Background(), job)
+		done <- result{attempts: attempts, err: err}
+	}()
+
+	waitForWaiters(t, clock)
+
+	if atomic.LoadInt32(&fetches) != 0 {
+		t.Fatalf("expected the backoff of the previous run to be waited out, got %d fetches", fetches)
+	}
+
+	clock.Advance(30 * time.Second)
+	waitForWaiters(t, clock)
+
+	// The third attempt fails, with the backoff of a third retry saved straight away.
+	resumed, err := state.OpenCheckpoint(path, true, clock)
+	if err != nil {
+		t.Fatalf("failed to resume checkpoint: %v", err)
+	}
+
+	if retry, ok := resumed.Retry(req.fetchKey()); !ok || retry.Attempts != 3 ||
+		!retry.NotBefore.Equal(clock.Now().Add(retryBackoff(3))) {
+		t.Fatalf("expected the backoff of the third attempt to be saved, got %+v", retry)
+	}
+
+	clock.Advance(retryBackoff(3))
+
+	res := <-done
+	if web.StatusCode(res.err) != http.StatusTooManyRequests || res.attempts != 2 || atomic.LoadInt32(&fetches) != 2 {
+		t.Fatalf("expected 2 attempts to fail with the remaining retry, got %d (%d fetches): %v", res.attempts,
+			fetches, res.err)
+	}
+
+	if _, ok := checkpoint.Retry(req.fetchKey()); ok {
+		t.Fatalf("expected the retries to be cleared once the request was given up on")
+	}
+}
+
+// waitForWaiters will wait until the code under test is blocked on the clock.
+func waitForWaiters(t *testing.T, clock *tools.FakeClock) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for clock.Waiters() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for the clock")
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,218 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/internal/proto"
+)
+
+// childValueColumn is the column of a child table that the values of an array that are not objects are written to.
+const childValueColumn = "value"
+
+// childTable is how the objects of an array column of the records are written to a table of their own.
+type childTable struct {
+	// column is the array column of the parent records, and table is the table that its objects are written to.
+	column, table string
+
+	// parentKey is the column of the parent records whose value is written to the foreignKey column of their
+	// children, and indexColumn is the column that the position of a child in its array is written to.
+	parentKey, foreignKey, indexColumn string
+
+	// write is how the child records are written.
+	write tableWrite
+}
+
+// newChildTables returns how the child tables of the records of a table are written, with the write mode and
+// flattening of the table. The foreign key of a child table is transformed, coerced and masked like the parent key,
+// so that it has the same values, and it is created with the type mapping of the table.
+func newChildTables(write tableWrite, children []*config.ChildTable) []childTable {
+	tables := make([]childTable, 0, len(children))
+
+	for _, child := range children {
+		foreignKey, indexColumn := child.ForeignKeyColumn(), child.IndexColumnName()
+		keys := []string{foreignKey, indexColumn}
+
+		childWrite := tableWrite{
+			mode:          write.mode,
+			flatten:       write.flatten,
+			partitionKeys: []string{foreignKey},
+			onTypeError:   write.onTypeError,
+		}
+
+		// Child records are identified by their parent and position, unless they are only inserted.
+		if write.mode != proto.WriteModeInsert {
+			childWrite.conflictKeys = keys
+		}
+
+		if typ, ok := write.types[child.ParentKey]; ok {
+			childWrite.types = map[string]string{foreignKey: typ}
+		}
+
+		if transform, ok := write.transforms[child.ParentKey]; ok {
+			childWrite.transforms = map[string]string{foreignKey: transform}
+		}
+
+		if locale, ok := write.numberLocales[child.ParentKey]; ok {
+			childWrite.numberLocales = map[string]string{foreignKey: locale}
+		}
+
+		if mask, ok := write.masks[child.ParentKey]; ok {
+			childWrite.masks = map[string]string{foreignKey: mask}
+		}
+
+		if write.autoCreate != nil {
+			childWrite.autoCreate = &config.AutoCreate{PrimaryKeys: keys, TypeMapping: write.autoCreate.TypeMapping}
+			childWrite.columns = []*proto.Column{{Name: indexColumn, Kind: proto.KindInteger}}
+		}
+
+		tables = append(tables, childTable{
+			column:      child.Column,
+			table:       child.Table,
+			parentKey:   child.ParentKey,
+			foreignKey:  foreignKey,
+			indexColumn: indexColumn,
+			write:       childWrite,
+		})
+	}
+
+	return tables
+}
+
+// extractChildRecord will remove the array columns of the child tables from a record, and append their values to the
+// records of each child table with the parent key and their position. Records that are not objects are left as they
+// are.
+func extractChildRecord(children []childTable, data json.RawMessage, childRecords [][]json.RawMessage,
+) (json.RawMessage, error) {
+	var record map[string]json.RawMessage
+	if err := json.Unmarshal(data, &record); err != nil {
+		return data, nil //nolint:nilerr // only objects have arrays to extract
+	}
+
+	extracted := false
+
+	for idx, child := range children {
+		val, ok := record[child.column]
+		if !ok {
+			continue
+		}
+
+		delete(record, child.column)
+
+		extracted = true
+
+		var items []json.RawMessage
+		if err := json.Unmarshal(val, &items); err != nil {
+			return nil, fmt.Errorf("%w %q for %s: it is not an array", ErrTransform, child.column, child.table)
+		}
+
+		if len(items) == 0 {
+			continue
+		}
+
+		key, ok := record[child.parentKey]
+		if !ok || string(key) == "null" {
+			return nil, fmt.Errorf("%w %q for %s: its record has no %q", ErrTransform, child.column, child.table,
+				child.parentKey)
+		}
+
+		for pos, item := range items {
+			var obj map[string]json.RawMessage
+			if err := json.Unmarshal(item, &obj); err != nil || obj == nil {
+				obj = map[string]json.RawMessage{childValueColumn: item}
+			}
+
+			for _, column := range []string{child.foreignKey, child.indexColumn} {
+				if _, ok := obj[column]; ok {
+					return nil, fmt.Errorf("%w %q for %s: its objects already have %q", ErrTransform, child.column,
+						child.table, column)
+				}
+			}
+
+			obj[child.foreignKey], obj[child.indexColumn] = key, json.RawMessage(fmt.Sprint(pos))
+
+			out, err := json.Marshal(obj)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode child record: %w", err)
+			}
+
+			childRecords[idx] = append(childRecords[idx], out)
+		}
+	}
+
+	if !extracted {
+		return data, nil
+	}
+
+	out, err := json.Marshal(record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode record: %w", err)
+	}
+
+	return out, nil
+}
+
+// extractChildren will remove the array columns of the child tables from the records of JSON data, an array of
+// records or a single record, and return the JSON array of the records of each child table, or nil if it has none.
+func extractChildren(children []childTable, data []byte) ([]byte, [][]byte, error) {
+	if len(children) == 0 {
+		return data, nil, nil
+	}
+
+	childRecords := make([][]json.RawMessage, len(children))
+
+	out, err := mapRecords(data, func(record json.RawMessage) (json.RawMessage, error) {
+		return extractChildRecord(children, record, childRecords)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	childData := make([][]byte, len(children))
+
+	for idx, records := range childRecords {
+		if len(records) == 0 {
+			continue
+		}
+
+		if childData[idx], err = json.Marshal(records); err != nil {
+			return nil, nil, fmt.Errorf("failed to encode child records: %w", err)
+		}
+	}
+
+	return out, childData, nil
+}
+
+// upsertChildren will put the upsert requests of the child tables of a job onto the transaction channels of the
+// repositories that the job is written to. They are sent after the upserts of the parent records, so that the
+// parents are written first.
+func upsertChildren(workerID int, cfg *repoConfig, job *repoJob, childData [][]byte) {
+	for idx, data := range childData {
+		if data == nil {
+			continue
+		}
+
+		child := job.write.children[idx]
+		childJob := &repoJob{table: child.table, sinks: job.sinks, write: child.write}
+
+		upsertRepos(workerID, cfg, childJob, childJob.upsertRequest(data))
+	}
+}
+
+// childTables returns the names of the child tables of a request.
+func (req *flattenedRequest) childTables() []string {
+	tables := make([]string, 0, len(req.write.children))
+	for _, child := range req.write.children {
+		tables = append(tables, child.table)
+	}
+
+	return tables
+}
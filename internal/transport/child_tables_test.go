@@ -0,0 +1,75 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/internal/proto"
+)
+
+func TestExtractChildren(t *testing.T) {
+	t.Parallel()
+
+	write := tableWrite{mode: proto.WriteModeUpsert, types: map[string]string{"id": config.TypeInt}}
+	children := newChildTables(write, []*config.ChildTable{
+		{Column: "items", Table: "order_items", ParentKey: "id"},
+		{Column: "tags", Table: "order_tags", ParentKey: "id", ForeignKey: "order_id", IndexColumn: "n"},
+	})
+
+	if items := children[0].write; !reflect.DeepEqual(items.conflictKeys, []string{"parent_id", "position"}) ||
+		items.types["parent_id"] != config.TypeInt {
+		t.Fatalf("expected the children to be keyed and typed by their parent, got %+v", items)
+	}
+
+	for _, tcase := range []struct {
+		name     string
+		data     string
+		want     string
+		children []string
+		err      error
+	}{
+		{name: "no arrays", data: `{"id":1}`, want: `{"id":1}`, children: []string{"", ""}},
+		{
+			name: "extracted",
+			data: `[{"id":1,"items":[{"sku":"a"},{"sku":"b"}],"tags":["new"]},{"id":2,"items":[]},3]`,
+			want: `[{"id":1},{"id":2},3]`,
+			children: []string{
+				`[{"parent_id":1,"position":0,"sku":"a"},{"parent_id":1,"position":1,"sku":"b"}]`,
+				`[{"n":0,"order_id":1,"value":"new"}]`,
+			},
+		},
+		{name: "not an array", data: `{"id":1,"items":{"sku":"a"}}`, err: ErrTransform},
+		{name: "no parent key", data: `{"items":[{"sku":"a"}]}`, err: ErrTransform},
+		{name: "foreign key collision", data: `{"id":1,"items":[{"parent_id":2}]}`, err: ErrTransform},
+	} {
+		got, childData, err := extractChildren(children, []byte(tcase.data))
+		if !errors.Is(err, tcase.err) {
+			t.Fatalf("%s: expected error %v, got %v", tcase.name, tcase.err, err)
+		}
+
+		if tcase.err != nil {
+			continue
+		}
+
+		if string(got) != tcase.want {
+			t.Fatalf("%s: expected %s, got %s", tcase.name, tcase.want, got)
+		}
+
+		for idx, want := range tcase.children {
+			if string(childData[idx]) != want {
+				t.Fatalf("%s: expected %s for %s, got %s", tcase.name, want, children[idx].table, childData[idx])
+			}
+		}
+	}
+}
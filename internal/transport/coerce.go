@@ -0,0 +1,235 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alpstable/gidari/config"
+)
+
+// coerceBools are the strings, other than those of "strconv.ParseBool", that are coerced to booleans.
+var coerceBools = map[string]bool{"yes": true, "y": true, "no": false, "n": false}
+
+// coerceRat returns the number of a JSON number or of a string holding one.
+func coerceRat(val interface{}) (*big.Rat, error) {
+	var text string
+
+	switch val := val.(type) {
+	case json.Number:
+		text = val.String()
+	case string:
+		text = val
+	default:
+		return nil, fmt.Errorf("%v is not a number", val)
+	}
+
+	num, ok := new(big.Rat).SetString(text)
+	if !ok || strings.Contains(text, "/") {
+		return nil, fmt.Errorf("%q is not a number", text)
+	}
+
+	return num, nil
+}
+
+// coerceDecimal returns a number rounded to the scale of a decimal type, with halves rounded away from zero. Numbers
+// with more integer digits than the type allows are an error.
+func coerceDecimal(typ *config.ColumnType, num *big.Rat) (string, error) {
+	str := num.FloatString(typ.Scale)
+
+	digits, _, _ := strings.Cut(strings.TrimPrefix(str, "-"), ".")
+	if digits = strings.TrimLeft(digits, "0"); len(digits) > typ.Precision-typ.Scale {
+		return "", fmt.Errorf("%s has more than %d integer digits", str, typ.Precision-typ.Scale)
+	}
+
+	return str, nil
+}
+
+// coerceTime returns the time of a string, in one of the layouts of an export or unix seconds, or of a number of
+// unix seconds.
+func coerceTime(val interface{}) (time.Time, error) {
+	switch val := val.(type) {
+	case json.Number:
+		secs, err := val.Float64()
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%s is not a time", val)
+		}
+
+		return parseExportTime(secs)
+	case string:
+		return parseExportTime(val)
+	default:
+		return time.Time{}, fmt.Errorf("%v is not a time", val)
+	}
+}
+
+// coerceJSON returns the value of a column coerced to its type. Null values and empty strings are null.
+func coerceJSON(typ *config.ColumnType, val interface{}) (interface{}, error) {
+	if str, ok := val.(string); ok {
+		if val = strings.TrimSpace(str); val == "" {
+			return nil, nil
+		}
+	}
+
+	if val == nil {
+		return nil, nil
+	}
+
+	switch typ.Name {
+	case config.TypeBool:
+		if b, ok := val.(bool); ok {
+			return b, nil
+		}
+
+		text := fmt.Sprint(val)
+		if b, ok := coerceBools[strings.ToLower(text)]; ok {
+			return b, nil
+		}
+
+		if b, err := strconv.ParseBool(text); err == nil {
+			return b, nil
+		}
+
+		return nil, fmt.Errorf("%q is not a boolean", text)
+	case config.TypeTimestamp:
+		at, err := coerceTime(val)
+		if err != nil {
+			return nil, err
+		}
+
+		return at.UTC().Format(time.RFC3339Nano), nil
+	case config.TypeFloat:
+		num, err := coerceRat(val)
+		if err != nil {
+			return nil, err
+		}
+
+		float, _ := num.Float64()
+		if math.IsInf(float, 0) {
+			return nil, fmt.Errorf("%s is out of range", num.RatString())
+		}
+
+		return json.Number(strconv.FormatFloat(float, 'g', -1, 64)), nil
+	case config.TypeInt:
+		num, err := coerceRat(val)
+		if err != nil {
+			return nil, err
+		}
+
+		if !num.IsInt() {
+			return nil, fmt.Errorf("%s is not an integer", num.FloatString(decimalPlaces(num.Denom())))
+		}
+
+		return json.Number(num.Num().String()), nil
+	default:
+		num, err := coerceRat(val)
+		if err != nil {
+			return nil, err
+		}
+
+		str, err := coerceDecimal(typ, num)
+		if err != nil {
+			return nil, err
+		}
+
+		return json.Number(str), nil
+	}
+}
+
+// coerceRecord will coerce the columns of a record to their types. It returns false if the record is left out by the
+// "skip" policy. Records that are not objects, and columns that they do not have, are left as they are.
+func coerceRecord(types map[string]*config.ColumnType, onTypeError string, data json.RawMessage,
+) (json.RawMessage, bool, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var record map[string]interface{}
+	if err := dec.Decode(&record); err != nil || record == nil {
+		return data, true, nil //nolint:nilerr // only objects have columns to coerce
+	}
+
+	for column, typ := range types {
+		val, ok := record[column]
+		if !ok {
+			continue
+		}
+
+		coerced, err := coerceJSON(typ, val)
+		if err != nil {
+			switch onTypeError {
+			case config.OnTypeErrorNull:
+				coerced = nil
+			case config.OnTypeErrorSkip:
+				return nil, false, nil
+			default:
+				return nil, false, fmt.Errorf("%w %q to %s: %v", ErrTransform, column, typ.Name, err)
+			}
+		}
+
+		record[column] = coerced
+	}
+
+	out, err := json.Marshal(record)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to encode record: %w", err)
+	}
+
+	return out, true, nil
+}
+
+// coerceData will coerce the columns of the records of JSON data, an array of records or a single record, to their
+// "types". It returns the number of records that were left out by the "skip" policy of "onTypeError".
+func coerceData(types map[string]string, onTypeError string, data []byte) ([]byte, int, error) {
+	if len(types) == 0 {
+		return data, 0, nil
+	}
+
+	// The types have been validated with the configuration.
+	parsed := make(map[string]*config.ColumnType, len(types))
+	for column, name := range types {
+		parsed[column], _ = config.ParseColumnType(name)
+	}
+
+	records, err := appendRecords(nil, data)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	kept := records[:0]
+
+	for _, record := range records {
+		coerced, ok, err := coerceRecord(parsed, onTypeError, record)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		if ok {
+			kept = append(kept, coerced)
+		}
+	}
+
+	skipped := len(records) - len(kept)
+
+	if trimmed := bytes.TrimLeft(data, " \t\r\n"); len(trimmed) > 0 && trimmed[0] != '[' && skipped == 0 {
+		return kept[0], 0, nil
+	}
+
+	out, err := json.Marshal(kept)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to encode records: %w", err)
+	}
+
+	return out, skipped, nil
+}
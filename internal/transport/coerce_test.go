@@ -0,0 +1,88 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alpstable/gidari/config"
+)
+
+func TestCoerceData(t *testing.T) {
+	t.Parallel()
+
+	types := map[string]string{
+		"id":    config.TypeInt,
+		"size":  config.TypeFloat,
+		"live":  config.TypeBool,
+		"at":    config.TypeTimestamp,
+		"price": "decimal(6,2)",
+	}
+
+	for _, tcase := range []struct {
+		name        string
+		types       map[string]string
+		onTypeError string
+		data        string
+		want        string
+		skipped     int
+		err         error
+	}{
+		{name: "no types", data: `{"id":"1"}`, want: `{"id":"1"}`},
+		{
+			name:  "strings",
+			types: types,
+			data:  `{"id":"42","size":" 1.50 ","live":"yes","at":"2022-05-01T02:00:00+02:00","price":"12.345"}`,
+			want:  `{"at":"2022-05-01T00:00:00Z","id":42,"live":true,"price":12.35,"size":1.5}`,
+		},
+		{
+			name:  "values",
+			types: types,
+			data:  `[{"id":7.0,"size":2,"live":0,"at":1651363200,"price":-0.005},{"id":"","price":null},3]`,
+			want:  `[{"at":"2022-05-01T00:00:00Z","id":7,"live":false,"price":-0.01,"size":2},{"id":null,"price":null},3]`,
+		},
+		{name: "error", types: types, data: `{"id":"1.5"}`, err: ErrTransform},
+		{name: "too many digits", types: types, data: `{"price":"12345"}`, err: ErrTransform},
+		{
+			name:        "null",
+			types:       types,
+			onTypeError: config.OnTypeErrorNull,
+			data:        `{"id":"n/a","live":"maybe"}`,
+			want:        `{"id":null,"live":null}`,
+		},
+		{
+			name:        "skip",
+			types:       types,
+			onTypeError: config.OnTypeErrorSkip,
+			data:        `[{"id":"1"},{"id":"one"},{"at":"yesterday"}]`,
+			want:        `[{"id":1}]`,
+			skipped:     2,
+		},
+		{
+			name:        "skip record",
+			types:       types,
+			onTypeError: config.OnTypeErrorSkip,
+			data:        `{"id":"one"}`,
+			want:        `[]`,
+			skipped:     1,
+		},
+	} {
+		got, skipped, err := coerceData(tcase.types, tcase.onTypeError, []byte(tcase.data))
+		if !errors.Is(err, tcase.err) {
+			t.Fatalf("%s: expected error %v, got %v", tcase.name, tcase.err, err)
+		}
+
+		if tcase.err == nil && (string(got) != tcase.want || skipped != tcase.skipped) {
+			t.Fatalf("%s: expected %s with %d skipped, got %s with %d", tcase.name, tcase.want, tcase.skipped, got,
+				skipped)
+		}
+	}
+}
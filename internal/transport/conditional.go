@@ -0,0 +1,188 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/internal/state"
+	"github.com/alpstable/gidari/internal/web"
+	"github.com/alpstable/gidari/tools"
+)
+
+// isConditional returns true if the requests flattened from a configured request are conditional. Truncated tables
+// have to be written in full, so their requests never are.
+func isConditional(req *config.Request) bool {
+	return req.Conditional && (req.Truncate == nil || !*req.Truncate)
+}
+
+// conditionalFetch returns true if the fetch of a request is conditional. Coalesced requests share the response, and
+// a response that was not modified is not written to any of their tables, so every one of them has to be
+// conditional.
+func (req *flattenedRequest) conditionalFetch() bool {
+	if !req.conditional {
+		return false
+	}
+
+	for _, other := range req.coalesced {
+		if !other.conditional {
+			return false
+		}
+	}
+
+	return true
+}
+
+// validatorKey identifies the HTTP request of a fetch in the state store. The fetch key is hashed, since the URL or
+// body of the request may hold credentials.
+func validatorKey(req *flattenedRequest) string {
+	sum := sha256.Sum256([]byte(req.fetchKey()))
+
+	return fmt.Sprintf("%s %s", req.requestKey, hex.EncodeToString(sum[:8]))
+}
+
+// applyValidators will send the validators of the last responses to the conditional fetches of a run, from the state
+// store, as "If-None-Match" and "If-Modified-Since".
+func applyValidators(fetches []*flattenedRequest, store *state.Store) {
+	for _, req := range fetches {
+		if !req.conditionalFetch() {
+			continue
+		}
+
+		validator, ok := store.Validator(validatorKey(req))
+		if !ok {
+			continue
+		}
+
+		header := make(http.Header)
+
+		if validator.ETag != "" {
+			header.Set("If-None-Match", validator.ETag)
+		}
+
+		if validator.LastModified != "" {
+			header.Set("If-Modified-Since", validator.LastModified)
+		}
+
+		req.fetchConfig.Header = header
+		req.lastValidator = &validator
+	}
+}
+
+// observeValidator will keep the validator of the response to a conditional fetch, which is stored once the data of
+// the response has been committed.
+func observeValidator(req *flattenedRequest, rsp *web.FetchResponse) {
+	if !req.conditionalFetch() {
+		return
+	}
+
+	etag, modified := rsp.Header.Get("ETag"), rsp.Header.Get("Last-Modified")
+	if etag == "" && modified == "" {
+		return
+	}
+
+	req.validator = &state.Validator{ETag: etag, LastModified: modified}
+}
+
+// recordValidators will store the validators of the committed conditional fetches of a batch in the state store,
+// which is saved with the watermarks.
+func recordValidators(batch []*flattenedRequest, store *state.Store) {
+	for _, req := range batch {
+		if req.validator == nil || !req.done || req.failed {
+			continue
+		}
+
+		store.SetValidator(validatorKey(req), *req.validator)
+	}
+}
+
+// checkFreshness will make a "HEAD" request for a conditional fetch with the "head" freshness check, returning its
+// response if its validators are those of the last response, so that the fetch can be skipped. The fetch is made if
+// the check fails.
+func (job *webJob) checkFreshness(ctx context.Context, workerID int) (*web.FetchResponse, bool) {
+	req := job.flattenedRequest
+	if req.freshness != config.FreshnessCheckHead || req.lastValidator == nil || !req.conditionalFetch() {
+		return nil, false
+	}
+
+	head := *req.fetchConfig
+	head.Method = http.MethodHead
+	head.Body = nil
+	head.Header = nil
+
+	rsp, err := web.Fetch(ctx, &head)
+	job.spend.add(job.requestKey, 1)
+
+	if err != nil {
+		logWarn := tools.LogFormatter{
+			WorkerID:   workerID,
+			WorkerName: "web",
+			Msg:        fmt.Sprintf("freshness check of %s failed, fetching it: %v", req.fetchConfig.URL, err),
+		}
+		job.logger.Warn(logWarn.String())
+
+		return nil, false
+	}
+
+	if !sameValidator(rsp.Header, req.lastValidator) {
+		rsp.Body.Close()
+
+		return nil, false
+	}
+
+	return rsp, true
+}
+
+// sameValidator returns true if the validators of the response headers are those of the last response. Validators
+// that the response does not have are not compared, but it must have one of them.
+func sameValidator(header http.Header, last *state.Validator) bool {
+	etag, modified := header.Get("ETag"), header.Get("Last-Modified")
+	if etag == "" && modified == "" {
+		return false
+	}
+
+	return (etag == "" || etag == last.ETag) && (modified == "" || modified == last.LastModified)
+}
+
+// notModified will finish a web job whose conditional request was answered with "304 Not Modified", or whose
+// freshness check found that its data has not changed. Nothing is written, but the requests are done, and their
+// timeseries chunks count towards the watermark.
+func (job *webJob) notModified(workerID int, targets []*flattenedRequest, rsp *web.FetchResponse,
+	elapsed time.Duration,
+) {
+	job.finishWithoutData(workerID, targets, rsp, elapsed, "not modified")
+}
+
+// finishWithoutData will finish a web job whose response has no data to write, for the "reason".
+func (job *webJob) finishWithoutData(workerID int, targets []*flattenedRequest, rsp *web.FetchResponse,
+	elapsed time.Duration, reason string,
+) {
+	rsp.Body.Close()
+
+	for _, target := range targets {
+		job.monitor.Finish(target.requestKey, 0, rsp.RateLimitWait)
+		target.progress.complete(target.page)
+	}
+
+	job.manifest.fetched(targets, elapsed)
+	markDone(targets)
+
+	logInfo := tools.LogFormatter{
+		WorkerID:   workerID,
+		WorkerName: "web",
+		Duration:   elapsed,
+		Msg:        fmt.Sprintf("skipping %s, %s", job.fetchConfig.URL, reason),
+	}
+	job.logger.Infof(logInfo.String())
+}
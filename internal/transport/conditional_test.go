@@ -0,0 +1,174 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/internal/state"
+	"github.com/alpstable/gidari/internal/web"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+)
+
+func TestConditional(t *testing.T) {
+	t.Parallel()
+
+	truncate := true
+
+	for _, tcase := range []struct {
+		name string
+		req  *config.Request
+		want bool
+	}{
+		{name: "unconditional", req: &config.Request{}},
+		{name: "conditional", req: &config.Request{Conditional: true}, want: true},
+		{name: "truncated", req: &config.Request{Conditional: true, Truncate: &truncate}},
+	} {
+		if got := isConditional(tcase.req); got != tcase.want {
+			t.Fatalf("%s: expected %v, got %v", tcase.name, tcase.want, got)
+		}
+	}
+
+	store, err := state.Open(filepath.Join(t.TempDir(), "gidari.json"), nil)
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+
+	newRequest := func(path string, conditional bool) *flattenedRequest {
+		return &flattenedRequest{
+			fetchConfig: &web.FetchConfig{Method: http.MethodGet, URL: &url.URL{Scheme: "https", Host: "api", Path: path}},
+			requestKey:  "GET " + path,
+			conditional: conditional,
+		}
+	}
+
+	candles, trades, orders := newRequest("/candles", true), newRequest("/trades", true), newRequest("/orders", true)
+	orders.coalesced = []*flattenedRequest{newRequest("/orders", false)}
+
+	header := make(http.Header)
+	header.Set("ETag", `"v1"`)
+	header.Set("Last-Modified", "Wed, 01 Jun 2022 00:00:00 GMT")
+
+	for _, req := range []*flattenedRequest{candles, trades, orders} {
+		observeValidator(req, &web.FetchResponse{Header: header})
+	}
+
+	if orders.validator != nil {
+		t.Fatalf("expected no validator for a request coalesced with an unconditional one")
+	}
+
+	// Only the validators of requests that were committed are stored.
+	candles.done = true
+	trades.done, trades.failed = true, true
+
+	recordValidators([]*flattenedRequest{candles, trades, orders}, store)
+
+	if _, ok := store.Validator(validatorKey(trades)); ok {
+		t.Fatalf("expected no validator for a failed request")
+	}
+
+	if key := validatorKey(candles); !strings.HasPrefix(key, "GET /candles ") || strings.Contains(key, "https") {
+		t.Fatalf("expected the validator key to hash the URL, got %q", key)
+	}
+
+	fetches := []*flattenedRequest{newRequest("/candles", true), newRequest("/candles", false)}
+	applyValidators(fetches, store)
+
+	if got := fetches[0].fetchConfig.Header.Get("If-None-Match"); got != `"v1"` {
+		t.Fatalf("expected If-None-Match %q, got %q", `"v1"`, got)
+	}
+
+	if got := fetches[0].fetchConfig.Header.Get("If-Modified-Since"); got != "Wed, 01 Jun 2022 00:00:00 GMT" {
+		t.Fatalf("expected If-Modified-Since %q, got %q", "Wed, 01 Jun 2022 00:00:00 GMT", got)
+	}
+
+	if fetches[1].fetchConfig.Header != nil {
+		t.Fatalf("expected no validators for an unconditional request, got %v", fetches[1].fetchConfig.Header)
+	}
+}
+
+func TestCheckFreshness(t *testing.T) {
+	t.Parallel()
+
+	var heads, gets int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("ETag", `"v1"`)
+
+		if req.Method == http.MethodHead {
+			atomic.AddInt32(&heads, 1)
+
+			return
+		}
+
+		atomic.AddInt32(&gets, 1)
+	}))
+	t.Cleanup(srv.Close)
+
+	client, _ := web.NewClient(context.Background(), nil)
+	rurl, _ := url.Parse(srv.URL + "/candles")
+
+	newJob := func(freshness string, last *state.Validator) *webJob {
+		return &webJob{
+			flattenedRequest: &flattenedRequest{
+				fetchConfig: &web.FetchConfig{
+					C:           client,
+					Method:      http.MethodGet,
+					URL:         rurl,
+					RateLimiter: rate.NewLimiter(rate.Inf, 1),
+				},
+				requestKey:    "GET /candles candles",
+				conditional:   true,
+				freshness:     freshness,
+				lastValidator: last,
+			},
+			runResources: &runResources{},
+			logger:       logrus.New(),
+		}
+	}
+
+	head := config.FreshnessCheckHead
+
+	for _, tcase := range []struct {
+		name      string
+		freshness string
+		last      *state.Validator
+		heads     int32
+		fresh     bool
+	}{
+		{name: "unchanged", freshness: head, last: &state.Validator{ETag: `"v1"`}, heads: 1, fresh: true},
+		{name: "changed", freshness: head, last: &state.Validator{ETag: `"v0"`}, heads: 2},
+		{name: "first run", freshness: head, heads: 2},
+		{name: "no check", last: &state.Validator{ETag: `"v1"`}, heads: 2},
+	} {
+		rsp, fresh := newJob(tcase.freshness, tcase.last).checkFreshness(context.Background(), 1)
+		if fresh != tcase.fresh || atomic.LoadInt32(&heads) != tcase.heads {
+			t.Fatalf("%s: expected fresh %v after %d HEAD requests, got %v after %d", tcase.name, tcase.fresh,
+				tcase.heads, fresh, heads)
+		}
+
+		if fresh {
+			rsp.Body.Close()
+		}
+	}
+
+	if gets != 0 {
+		t.Fatalf("expected the freshness checks not to fetch the data, got %d GET requests", gets)
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/alpstable/gidari/internal/control"
+	"github.com/alpstable/gidari/tools"
+)
+
+// activeTargets returns the request of a web job, and every request coalesced into it, that has not been canceled.
+func (job *webJob) activeTargets() []*flattenedRequest {
+	targets := make([]*flattenedRequest, 0, len(job.coalesced)+1)
+
+	for _, target := range append([]*flattenedRequest{job.flattenedRequest}, job.coalesced...) {
+		if job.control.State(target.requestKey) != control.Canceled {
+			targets = append(targets, target)
+		}
+	}
+
+	return targets
+}
+
+// pausedTarget returns the name of a paused request of a web job, and false if none of its requests are paused.
+func (job *webJob) pausedTarget() (string, bool) {
+	for _, target := range append([]*flattenedRequest{job.flattenedRequest}, job.coalesced...) {
+		if job.control.State(target.requestKey) == control.Paused {
+			return target.requestKey, true
+		}
+	}
+
+	return "", false
+}
+
+// park will hold a web job with a paused request until every request of the job is resumed or canceled, and then
+// run it. The job is held outside of the web workers, so that the other requests of the run continue. It returns
+// false if none of the requests of the job are paused.
+func (job *webJob) park(ctx context.Context, workerID int) bool {
+	name, paused := job.pausedTarget()
+	if !paused {
+		return false
+	}
+
+	logInfo := tools.LogFormatter{
+		WorkerID:   workerID,
+		WorkerName: "web",
+		Msg:        fmt.Sprintf("holding %s until request %q is resumed or canceled", job.fetchConfig.URL, name),
+	}
+	job.logger.Infof(logInfo.String())
+
+	job.parked.Add(1)
+
+	unpark := job.status.park()
+
+	go func() {
+		defer job.parked.Done()
+
+		for paused && ctx.Err() == nil {
+			job.control.Wait(ctx, name)
+
+			name, paused = job.pausedTarget()
+		}
+
+		unpark()
+
+		runWebJob(ctx, workerID, job)
+	}()
+
+	return true
+}
+
+// canceledRequests returns the number of requests in a batch, including coalesced requests, that were not made
+// because they were canceled.
+func canceledRequests(ctl *control.Control, batch []*flattenedRequest) int {
+	canceled := 0
+
+	for _, req := range batch {
+		for _, target := range append([]*flattenedRequest{req}, req.coalesced...) {
+			if !target.done && ctl.State(target.requestKey) == control.Canceled {
+				canceled++
+			}
+		}
+	}
+
+	return canceled
+}
@@ -0,0 +1,150 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/alpstable/gidari/internal/control"
+	"github.com/alpstable/gidari/internal/web"
+	"github.com/sirupsen/logrus"
+)
+
+func newTestControlJob(ctl *control.Control, keys ...string) *webJob {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	reqs := make([]*flattenedRequest, len(keys))
+	for idx, key := range keys {
+		ctl.Register(key)
+
+		reqs[idx] = &flattenedRequest{
+			fetchConfig: &web.FetchConfig{URL: &url.URL{Scheme: "https", Host: "example.com", Path: "/" + key}},
+			requestKey:  key,
+		}
+	}
+
+	reqs[0].coalesced = reqs[1:]
+
+	return &webJob{
+		flattenedRequest: reqs[0],
+		runResources:     &runResources{control: ctl, parked: new(sync.WaitGroup)},
+		logger:           logger,
+	}
+}
+
+func TestActiveTargets(t *testing.T) {
+	t.Parallel()
+
+	// Without a control every request is active.
+	job := newTestControlJob(nil, "GET /a a", "GET /b b")
+	if targets := job.activeTargets(); len(targets) != 2 {
+		t.Fatalf("expected 2 targets, got %d", len(targets))
+	}
+
+	ctl := control.New(nil)
+	job = newTestControlJob(ctl, "GET /a a", "GET /b b", "GET /c c")
+
+	if _, err := ctl.Cancel("a"); err != nil {
+		t.Fatalf("failed to cancel: %v", err)
+	}
+
+	if _, err := ctl.Pause("c"); err != nil {
+		t.Fatalf("failed to pause: %v", err)
+	}
+
+	targets := job.activeTargets()
+	if len(targets) != 2 || targets[0].requestKey != "GET /b b" || targets[1].requestKey != "GET /c c" {
+		t.Fatalf("expected the requests that were not canceled, got %+v", targets)
+	}
+
+	if name, paused := job.pausedTarget(); !paused || name != "GET /c c" {
+		t.Fatalf("expected %q to be paused, got %q", "GET /c c", name)
+	}
+
+	batch := []*flattenedRequest{job.flattenedRequest}
+	if canceled := canceledRequests(ctl, batch); canceled != 1 {
+		t.Fatalf("expected 1 canceled request, got %d", canceled)
+	}
+
+	// Requests that were made before they were canceled are not left in the checkpoint.
+	job.done = true
+
+	if canceled := canceledRequests(ctl, batch); canceled != 0 {
+		t.Fatalf("expected no canceled requests, got %d", canceled)
+	}
+}
+
+func TestPark(t *testing.T) {
+	t.Parallel()
+
+	t.Run("running", func(t *testing.T) {
+		t.Parallel()
+
+		job := newTestControlJob(control.New(nil), "GET /a a")
+		if job.park(context.Background(), 1) {
+			t.Fatal("expected a running job not to be parked")
+		}
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		t.Parallel()
+
+		ctl := control.New(nil)
+		job := newTestControlJob(ctl, "GET /a a")
+
+		if _, err := ctl.Pause("a"); err != nil {
+			t.Fatalf("failed to pause: %v", err)
+		}
+
+		if !job.park(context.Background(), 1) {
+			t.Fatal("expected a paused job to be parked")
+		}
+
+		if _, err := ctl.Cancel("a"); err != nil {
+			t.Fatalf("failed to cancel: %v", err)
+		}
+
+		// The parked job is skipped once it is canceled, without being marked as done.
+		job.parked.Wait()
+
+		if job.done {
+			t.Fatal("expected a canceled request not to be done")
+		}
+	})
+
+	t.Run("interrupted", func(t *testing.T) {
+		t.Parallel()
+
+		ctl := control.New(nil)
+		job := newTestControlJob(ctl, "GET /a a", "GET /b b")
+
+		if _, err := ctl.Pause("b"); err != nil {
+			t.Fatalf("failed to pause: %v", err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+
+		if !job.park(ctx, 1) {
+			t.Fatal("expected a job with a paused coalesced request to be parked")
+		}
+
+		cancel()
+		job.parked.Wait()
+
+		if job.done {
+			t.Fatal("expected an interrupted request not to be done")
+		}
+	})
+}
@@ -0,0 +1,69 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"fmt"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/internal/web/auth"
+	"github.com/alpstable/gidari/tools"
+)
+
+// newAuth2 will return the OAuth2 transport for the configuration. Bearers from a token endpoint are refreshed
+// before they expire, and every refresh is logged with the lifetime of the new bearer.
+func newAuth2(cfg *config.Config, auth2 *config.Auth2) *auth.Auth2 {
+	clock := tools.ClockOrReal(cfg.Clock)
+
+	// The refresh margin has been validated with the configuration.
+	refreshBefore, _ := auth2.RefreshBeforeOrDefault()
+
+	tripper := auth.NewAuth2().SetURL(cfg.RawURL).SetBearer(auth2.Bearer).SetClock(clock)
+
+	if auth2.TokenURL != "" {
+		tripper.SetTokenURL(auth2.TokenURL).
+			SetClient(auth2.ClientID, auth2.ClientSecret).
+			SetRefreshToken(auth2.RefreshToken).
+			SetScopes(auth2.Scopes).
+			SetRefreshBefore(refreshBefore).
+			SetOnRefresh(func(token auth.Token, err error) {
+				logRefresh(cfg, token, err)
+			})
+
+		return tripper
+	}
+
+	// A static bearer cannot be refreshed, so warn if it will not last.
+	if expiry, ok := tripper.Expiry(); ok && clock.Now().Add(refreshBefore).After(expiry) {
+		logWarn := tools.LogFormatter{
+			Msg: fmt.Sprintf("the auth2 bearer expires at %s, set auth2.tokenURL to refresh it",
+				expiry.UTC().Format("2006-01-02T15:04:05Z")),
+		}
+		cfg.Logger.Warn(logWarn.String())
+	}
+
+	return tripper
+}
+
+// logRefresh will log the outcome of refreshing a bearer from the token endpoint.
+func logRefresh(cfg *config.Config, token auth.Token, err error) {
+	if err != nil {
+		logWarn := tools.LogFormatter{Msg: err.Error()}
+		cfg.Logger.Warn(logWarn.String())
+
+		return
+	}
+
+	msg := "refreshed the auth2 bearer, which has no known expiry"
+	if !token.Expiry.IsZero() {
+		msg = fmt.Sprintf("refreshed the auth2 bearer, which expires in %s", token.Expiry.Sub(token.IssuedAt))
+	}
+
+	logInfo := tools.LogFormatter{Msg: msg}
+	cfg.Logger.Info(logInfo.String())
+}
@@ -0,0 +1,173 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/alpstable/gidari/config"
+)
+
+// utf8BOM is the byte order mark that spreadsheet exports often begin with.
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// isJSONNumber returns true if "value" is a number as it would be written in JSON, which excludes leading zeros so
+// that codes such as "007" stay strings.
+func isJSONNumber(value string) bool {
+	if value == "" || (value[0] != '-' && (value[0] < '0' || value[0] > '9')) {
+		return false
+	}
+
+	return strings.TrimSpace(value) == value && json.Valid([]byte(value))
+}
+
+// coerceCSV returns the value of a CSV field as the type of its column. Without a type, the value is a string unless
+// "infer" is set, in which case numbers, "true" and "false" are written as such and empty values are null.
+func coerceCSV(value, typ string, infer bool) (interface{}, error) {
+	// Empty values are null, except in columns of strings or that are not coerced.
+	if value == "" && typ != config.CSVTypeString && (typ != "" || infer) {
+		return nil, nil
+	}
+
+	switch typ {
+	case config.CSVTypeString:
+		return value, nil
+	case config.CSVTypeInteger:
+		num, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an integer", value)
+		}
+
+		return num, nil
+	case config.CSVTypeNumber:
+		if isJSONNumber(value) {
+			return json.Number(value), nil
+		}
+
+		num, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsInf(num, 0) || math.IsNaN(num) {
+			return nil, fmt.Errorf("%q is not a number", value)
+		}
+
+		return num, nil
+	case config.CSVTypeBoolean:
+		val, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a boolean", value)
+		}
+
+		return val, nil
+	}
+
+	switch {
+	case !infer:
+		return value, nil
+	case isJSONNumber(value):
+		return json.Number(value), nil
+	case strings.EqualFold(value, "true"):
+		return true, nil
+	case strings.EqualFold(value, "false"):
+		return false, nil
+	default:
+		return value, nil
+	}
+}
+
+// csvRecord returns the JSON record of a row of a CSV body.
+func csvRecord(format *config.CSVFormat, columns, row []string) (json.RawMessage, error) {
+	record := make(map[string]interface{}, len(columns))
+
+	for idx, column := range columns {
+		val, err := coerceCSV(row[idx], format.Types[column], format.Coerce)
+		if err != nil {
+			return nil, fmt.Errorf("failed to coerce column %q: %w", column, err)
+		}
+
+		record[column] = val
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal CSV record: %w", err)
+	}
+
+	return data, nil
+}
+
+// decodeCSV will decode the rows of a CSV body into records as they are read, calling "batchFn" with batches of at
+// most "size" records. The columns of the records are named by the "columns" of the format, or else by the header
+// row. Every row must have as many fields as there are columns. Like an array, a body without rows is a single empty
+// batch. It returns the number of records that were decoded.
+func decodeCSV(reader *bufio.Reader, format *config.CSVFormat, size int, batchFn streamBatchFn) (int, error) {
+	if bom, err := reader.Peek(len(utf8BOM)); err == nil && bytes.Equal(bom, utf8BOM) {
+		if _, err := reader.Discard(len(utf8BOM)); err != nil {
+			return 0, fmt.Errorf("failed to read response body: %w", err)
+		}
+	}
+
+	csvReader := csv.NewReader(reader)
+	csvReader.Comma = format.Comma()
+
+	columns := format.Columns
+
+	if format.HasHeader() {
+		header, err := csvReader.Read()
+		if err != nil && err != io.EOF {
+			return 0, fmt.Errorf("failed to decode CSV header: %w", err)
+		}
+
+		if len(columns) == 0 {
+			columns = header
+		}
+	}
+
+	csvReader.FieldsPerRecord = len(columns)
+
+	count := 0
+	batch := make([]json.RawMessage, 0, size)
+
+	for {
+		row, readErr := csvReader.Read()
+		if readErr != nil && readErr != io.EOF {
+			return count, fmt.Errorf("failed to decode response body: %w", readErr)
+		}
+
+		if readErr == nil {
+			record, err := csvRecord(format, columns, row)
+			if err != nil {
+				line, _ := csvReader.FieldPos(0)
+
+				return count, fmt.Errorf("failed to decode row on line %d: %w", line, err)
+			}
+
+			batch = append(batch, record)
+			count++
+		}
+
+		done := readErr != nil
+		if len(batch) == size || (done && (len(batch) > 0 || count == 0)) {
+			if err := batchFn(batch); err != nil {
+				return count, err
+			}
+
+			batch = make([]json.RawMessage, 0, size)
+		}
+
+		if done {
+			return count, nil
+		}
+	}
+}
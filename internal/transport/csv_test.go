@@ -0,0 +1,113 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"bufio"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alpstable/gidari/config"
+)
+
+func TestDecodeCSV(t *testing.T) {
+	t.Parallel()
+
+	noHeader := false
+
+	for _, tcase := range []struct {
+		name   string
+		format *config.CSVFormat
+		body   string
+		want   []string
+		count  int
+		err    bool
+	}{
+		{
+			name:   "header",
+			format: &config.CSVFormat{},
+			body:   "id,name\n1,a\n2,\"b, c\"\r\n3,\n",
+			want:   []string{`[{"id":"1","name":"a"},{"id":"2","name":"b, c"}]`, `[{"id":"3","name":""}]`},
+			count:  3,
+		},
+		{
+			name:   "byte order mark",
+			format: &config.CSVFormat{},
+			body:   "\xEF\xBB\xBFid\n1\n",
+			want:   []string{`[{"id":"1"}]`},
+			count:  1,
+		},
+		{
+			name:   "columns",
+			format: &config.CSVFormat{Delimiter: ";", Header: &noHeader, Columns: []string{"id", "name"}},
+			body:   "1;a\n",
+			want:   []string{`[{"id":"1","name":"a"}]`},
+			count:  1,
+		},
+		{
+			name:   "columns over header",
+			format: &config.CSVFormat{Columns: []string{"time", "price"}},
+			body:   "Time,Price\n1,2\n",
+			want:   []string{`[{"price":"2","time":"1"}]`},
+			count:  1,
+		},
+		{
+			name:   "coerce",
+			format: &config.CSVFormat{Coerce: true},
+			body:   "a,b,c,d\n1.50,TRUE,007,\n-2,false,x,1e3\n",
+			want: []string{
+				`[{"a":1.50,"b":true,"c":"007","d":null},{"a":-2,"b":false,"c":"x","d":1e3}]`,
+			},
+			count: 2,
+		},
+		{
+			name: "types",
+			format: &config.CSVFormat{Types: map[string]string{
+				"a": config.CSVTypeInteger, "b": config.CSVTypeNumber, "c": config.CSVTypeBoolean,
+				"d": config.CSVTypeString,
+			}},
+			body:  "a,b,c,d,e\n1,.5,1,007,\n,,,,\n",
+			want:  []string{`[{"a":1,"b":0.5,"c":true,"d":"007","e":""},{"a":null,"b":null,"c":null,"d":"","e":""}]`},
+			count: 2,
+		},
+		{name: "empty", format: &config.CSVFormat{}, body: "", want: []string{`[]`}},
+		{name: "header only", format: &config.CSVFormat{}, body: "id,name\n", want: []string{`[]`}},
+		{
+			name:   "invalid type",
+			format: &config.CSVFormat{Types: map[string]string{"id": config.CSVTypeInteger}},
+			body:   "id\nx\n",
+			err:    true,
+		},
+		{name: "missing field", format: &config.CSVFormat{}, body: "id,name\n1\n", err: true},
+	} {
+		var got []string
+
+		count, err := decodeCSV(bufio.NewReader(strings.NewReader(tcase.body)), tcase.format, 2,
+			func(batch []json.RawMessage) error {
+				data, err := json.Marshal(batch)
+				got = append(got, string(data))
+
+				return err
+			})
+		if (err != nil) != tcase.err {
+			t.Fatalf("%s: expected error %t, got %v", tcase.name, tcase.err, err)
+		}
+
+		if tcase.err {
+			continue
+		}
+
+		if count != tcase.count || !reflect.DeepEqual(got, tcase.want) {
+			t.Fatalf("%s: expected %d records in %v, got %d in %v", tcase.name, tcase.count, tcase.want, count, got)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/internal/web/auth"
+)
+
+// rotatingAuthenticator signs requests with a token that is rotated when a request is unauthorized.
+type rotatingAuthenticator struct {
+	token       atomic.Value
+	next        string
+	unauthorize int32
+}
+
+func (ra *rotatingAuthenticator) Authenticate(req *http.Request) error {
+	req.Header.Set("X-Signature", ra.token.Load().(string))
+
+	return nil
+}
+
+func (ra *rotatingAuthenticator) Unauthorized(*http.Response) (bool, error) {
+	atomic.AddInt32(&ra.unauthorize, 1)
+	ra.token.Store(ra.next)
+
+	return true, nil
+}
+
+func TestConnectCustomAuth(t *testing.T) {
+	t.Parallel()
+
+	var requests int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&requests, 1)
+
+		if req.Header.Get("X-Signature") != "fresh" {
+			rw.WriteHeader(http.StatusUnauthorized)
+
+			return
+		}
+
+		body, _ := io.ReadAll(req.Body)
+		_, _ = rw.Write(body)
+	}))
+	t.Cleanup(srv.Close)
+
+	authenticator := &rotatingAuthenticator{next: "fresh"}
+	authenticator.token.Store("stale")
+
+	err := auth.RegisterAuthenticator("transport-test", func(settings map[string]string) (auth.Authenticator, error) {
+		authenticator.next = settings["next"]
+
+		return authenticator, nil
+	})
+	if err != nil {
+		t.Fatalf("failed to register authenticator: %v", err)
+	}
+
+	t.Cleanup(func() { auth.UnregisterAuthenticator("transport-test") })
+
+	cfg := &config.Config{
+		RawURL: srv.URL,
+		Authentication: config.Authentication{
+			Custom: &config.CustomAuth{Scheme: "transport-test", Settings: map[string]string{"next": "fresh"}},
+		},
+	}
+
+	client, err := connectAuth(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodPost, srv.URL+"/orders",
+		strings.NewReader(`{"page":1}`))
+
+	rsp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("failed to make the request: %v", err)
+	}
+
+	defer rsp.Body.Close()
+
+	body, _ := io.ReadAll(rsp.Body)
+
+	if rsp.StatusCode != http.StatusOK || string(body) != `{"page":1}` {
+		t.Fatalf("expected the request to be sent again with its body, got %d %q", rsp.StatusCode, body)
+	}
+
+	if requests != 2 || authenticator.unauthorize != 1 {
+		t.Fatalf("expected 2 requests and 1 unauthorized response, got %d and %d", requests,
+			authenticator.unauthorize)
+	}
+
+	// A request that is still unauthorized once it was sent again is not retried again.
+	authenticator.next = "stale"
+	authenticator.token.Store("stale")
+
+	req, _ = http.NewRequestWithContext(context.Background(), http.MethodGet, srv.URL+"/orders", nil)
+
+	rsp, err = client.Do(req)
+	if err != nil {
+		t.Fatalf("failed to make the request: %v", err)
+	}
+
+	rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusUnauthorized || requests != 4 {
+		t.Fatalf("expected one retry of the unauthorized request, got %d after %d requests", rsp.StatusCode, requests)
+	}
+}
@@ -0,0 +1,454 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/internal/web"
+	"github.com/alpstable/gidari/tools"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+)
+
+// maxRetryBackoff is the longest that a failed request waits before it is retried.
+const maxRetryBackoff = time.Minute
+
+// deadLetter is a request that failed after its retries, recorded with everything needed to make it again.
+type deadLetter struct {
+	// Request is the "StateKey" of the configured request, used to look up its settings when it is replayed.
+	Request string `json:"request"`
+
+	Table  string          `json:"table"`
+	Method string          `json:"method"`
+	URL    string          `json:"url"`
+	Body   json.RawMessage `json:"body,omitempty"`
+
+	Page       int        `json:"page,omitempty"`
+	ChunkStart *time.Time `json:"chunk_start,omitempty"`
+	ChunkEnd   *time.Time `json:"chunk_end,omitempty"`
+
+	// StatusCode is the HTTP status code of the last attempt, or zero if it did not get a response.
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error"`
+	Attempts   int       `json:"attempts"`
+	FailedAt   time.Time `json:"failed_at"`
+
+	// Run is the ID of the run that the request failed in, for "gidari retry-failed".
+	Run string `json:"run,omitempty"`
+}
+
+func newDeadLetter(target *flattenedRequest, err error, attempts int, failedAt time.Time) *deadLetter {
+	letter := &deadLetter{
+		Request:    target.requestKey,
+		Table:      target.table,
+		Method:     target.fetchConfig.Method,
+		URL:        target.fetchConfig.URL.String(),
+		Page:       target.page,
+		StatusCode: web.StatusCode(err),
+		Error:      err.Error(),
+		Attempts:   attempts,
+		FailedAt:   failedAt.UTC(),
+	}
+
+	if len(target.fetchConfig.Body) > 0 {
+		letter.Body = target.fetchConfig.Body
+	}
+
+	if target.chunk != nil {
+		start, end := target.chunk[0].UTC(), target.chunk[1].UTC()
+		letter.ChunkStart, letter.ChunkEnd = &start, &end
+	}
+
+	return letter
+}
+
+// deadLetterFile appends dead letters to a newline-delimited JSON file. A nil "deadLetterFile" is disabled, and
+// requests that fail abort the run.
+type deadLetterFile struct {
+	path string
+
+	mu    sync.Mutex
+	file  *os.File
+	count int
+}
+
+// openDeadLetters will open the dead-letter file from the configuration for appending, returning nil if dead
+// letters are not configured.
+func openDeadLetters(cfg *config.Config) (*deadLetterFile, error) {
+	if cfg.DeadLetter == nil {
+		return nil, nil
+	}
+
+	return createDeadLetters(cfg.DeadLetter.Path(), os.O_APPEND)
+}
+
+func createDeadLetters(path string, flag int) (*deadLetterFile, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|flag, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open dead-letter file: %w", err)
+	}
+
+	return &deadLetterFile{path: path, file: file}, nil
+}
+
+func (letters *deadLetterFile) write(letter *deadLetter) error {
+	data, err := json.Marshal(letter)
+	if err != nil {
+		return fmt.Errorf("unable to encode dead letter: %w", err)
+	}
+
+	letters.mu.Lock()
+	defer letters.mu.Unlock()
+
+	if _, err := letters.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("unable to write dead letter: %w", err)
+	}
+
+	letters.count++
+
+	return nil
+}
+
+// close will close the dead-letter file, logging how many requests were written to it.
+func (letters *deadLetterFile) close(logger *logrus.Logger) {
+	if letters == nil {
+		return
+	}
+
+	if err := letters.file.Close(); err != nil {
+		logger.Warn(tools.LogFormatter{Msg: fmt.Sprintf("unable to close dead-letter file: %v", err)}.String())
+	}
+
+	if letters.count > 0 {
+		logWarn := tools.LogFormatter{
+			Msg: fmt.Sprintf("%d failed requests written to %s, use \"gidari replay\" to retry them", letters.count,
+				letters.path),
+		}
+		logger.Warn(logWarn.String())
+	}
+}
+
+// readDeadLetters will read every dead letter in the file at "path". A missing file has no dead letters.
+func readDeadLetters(path string) ([]*deadLetter, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to open dead-letter file: %w", err)
+	}
+
+	defer file.Close()
+
+	var letters []*deadLetter
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		letter := new(deadLetter)
+		if err := json.Unmarshal(scanner.Bytes(), letter); err != nil {
+			return nil, fmt.Errorf("unable to decode dead letter on line %d of %s: %w", line, path, err)
+		}
+
+		letters = append(letters, letter)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read dead-letter file: %w", err)
+	}
+
+	return letters, nil
+}
+
+// retryable returns true if a failed fetch may succeed when it is made again.
+func retryable(err error) bool {
+	code := web.StatusCode(err)
+
+	return code == 0 || code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
+
+// retryBackoff returns how long to wait before making the given retry, doubling with every attempt.
+func retryBackoff(retry int) time.Duration {
+	backoff := time.Second
+	for idx := 1; idx < retry && backoff < maxRetryBackoff; idx++ {
+		backoff *= 2
+	}
+
+	if backoff > maxRetryBackoff {
+		return maxRetryBackoff
+	}
+
+	return backoff
+}
+
+// fetch will make the HTTP request for a web job, retrying failures up to the configured number of retries. It
+// returns the number of attempts made. The request is not canceled with the run, so that a response that is
+// in-flight is still stored, but a canceled run does not wait to retry. Only the deadline of the run aborts it.
+//
+// With a checkpoint, the retries of the request are persisted, so that a resumed run continues counting its attempts
+// and waits out the backoff of the previous run instead of making the request again straight away.
+//
+// With a "responseCache", a response that an earlier run cached is returned without making the request, and with no
+// attempts.
+func fetch(ctx context.Context, job *webJob) (*web.FetchResponse, int, error) {
+	if rsp, ok := job.cache.get(ctx, job.fetchConfig); ok {
+		logInfo := tools.LogFormatter{Msg: fmt.Sprintf("using cached response of %s", job.fetchConfig.URL)}
+		job.logger.Debug(logInfo.String())
+
+		return rsp, 0, nil
+	}
+
+	key := job.fetchKey()
+	failed := 0
+
+	if retry, ok := job.checkpoint.Retry(key); ok {
+		failed = retry.Attempts
+
+		if wait := retry.NotBefore.Sub(job.clock.Now()); wait > 0 {
+			logInfo := tools.LogFormatter{
+				Msg: fmt.Sprintf("resuming backoff of %s for %s after %d failed attempts", job.fetchConfig.URL, wait,
+					failed),
+			}
+			job.logger.Info(logInfo.String())
+
+			if err := tools.Sleep(ctx, job.clock, wait); err != nil {
+				return nil, 0, fmt.Errorf("retry canceled: %w", err)
+			}
+		}
+	}
+
+	host := job.fetchConfig.URL.Host
+
+	for attempt := 1; ; attempt++ {
+		// Attempts that wait for a web worker until the run is stopped are not made.
+		if err := job.scaler.acquire(ctx, host); err != nil {
+			return nil, attempt - 1, err
+		}
+
+		began := job.clock.Now()
+		rsp, err := web.Fetch(job.deadline.detachFetch(ctx), job.fetchConfig)
+		job.scaler.release(host, job.clock.Now().Sub(began), rsp, err)
+
+		if err != nil {
+			err = job.provider.Describe(err)
+		}
+
+		if err == nil || failed+attempt > job.retries || !(retryable(err) || job.provider.Retryable(err)) {
+			job.checkpoint.ClearRetry(key)
+
+			if err == nil {
+				job.cache.put(job.fetchConfig, rsp)
+			}
+
+			return rsp, attempt, err
+		}
+
+		logWarn := tools.LogFormatter{
+			Msg: fmt.Sprintf("retrying %s after attempt %d failed: %v", job.fetchConfig.URL, failed+attempt, err),
+		}
+		job.logger.Warn(logWarn.String())
+
+		// The rate limit of the web API may reset later than the backoff.
+		backoff := retryBackoff(failed + attempt)
+		if wait := job.provider.RetryAfter(err, job.clock.Now()); wait > backoff {
+			backoff = wait
+		}
+
+		if err := job.checkpoint.Backoff(key, failed+attempt, job.clock.Now().Add(backoff)); err != nil {
+			logWarn := tools.LogFormatter{Msg: fmt.Sprintf("unable to save backoff of %s: %v", job.fetchConfig.URL, err)}
+			job.logger.Warn(logWarn.String())
+		}
+
+		if err := tools.Sleep(ctx, job.clock, backoff); err != nil {
+			return nil, attempt, fmt.Errorf("retry canceled: %w", err)
+		}
+
+		// Every retry is another request against the budget.
+		if !job.budget.reserve(job.cost) {
+			return nil, attempt, ErrBudgetExceeded
+		}
+	}
+}
+
+// deadLetter will record the targets of the failed web job in the dead-letter file. The requests are marked as done
+// so that a resumed run does not make them again, but the watermarks of timeseries requests are not advanced past
+// them.
+func (job *webJob) deadLetter(workerID int, targets []*flattenedRequest, err error, attempts int,
+	failedAt time.Time,
+) {
+	for _, target := range targets {
+		letter := newDeadLetter(target, err, attempts, failedAt)
+		letter.Run = job.runID
+
+		if err := job.deadLetters.write(letter); err != nil {
+			job.failure.fail("web", workerID, err)
+
+			return
+		}
+
+		job.manifest.failed(letter)
+	}
+
+	markDone(targets)
+
+	for _, target := range targets {
+		target.failed = true
+	}
+
+	logWarn := tools.LogFormatter{
+		WorkerID:   workerID,
+		WorkerName: "web",
+		Msg:        fmt.Sprintf("dead-lettered %s after %d attempts: %v", job.fetchConfig.URL, attempts, err),
+	}
+	job.logger.Warn(logWarn.String())
+}
+
+// Replay will re-execute the requests in the dead-letter file, upserting their responses to storage. Requests that
+// fail again are written back to the dead-letter file, which is removed once every request has succeeded.
+func Replay(ctx context.Context, cfg *config.Config) error {
+	start := time.Now()
+	path := cfg.DeadLetter.Path()
+
+	letters, err := readDeadLetters(path)
+	if err != nil {
+		return err
+	}
+
+	if len(letters) == 0 {
+		cfg.Logger.Info(tools.LogFormatter{Msg: fmt.Sprintf("no dead-lettered requests in %s", path)}.String())
+
+		return nil
+	}
+
+	client, err := connect(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to connect to web API: %w", err)
+	}
+
+	reqs, err := replayRequests(cfg, client, letters)
+	if err != nil {
+		return err
+	}
+
+	ws, err := newWorkspace(cfg)
+	if err != nil {
+		return err
+	}
+
+	// The dead letters have been read, so the file is rewritten with only the requests that fail again.
+	deadLetters, err := createDeadLetters(path, os.O_TRUNC)
+	if err != nil {
+		return err
+	}
+
+	res := newRunResources(cfg, ws, nil, nil, deadLetters)
+	err = upsertBatch(ctx, cfg, res, coalesceRequests(reqs))
+
+	res.sinks.report(cfg.Logger)
+
+	deadLetters.close(cfg.Logger)
+
+	if closeErr := ws.Close(err != nil); closeErr != nil {
+		cfg.Logger.Warn(tools.LogFormatter{Msg: closeErr.Error()}.String())
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if deadLetters.count == 0 {
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("unable to remove dead-letter file: %w", err)
+		}
+	}
+
+	logInfo := tools.LogFormatter{
+		Duration: time.Since(start),
+		Msg:      fmt.Sprintf("replay completed: %d of %d requests succeeded", len(letters)-deadLetters.count, len(letters)),
+	}
+	cfg.Logger.Info(logInfo.String())
+
+	return nil
+}
+
+// replayRequests will rebuild the flattened requests for the dead letters. Settings such as the "clobColumn" are
+// taken from the configured request that a dead letter was created from, if it is still in the configuration.
+func replayRequests(cfg *config.Config, client *web.Client, letters []*deadLetter) ([]*flattenedRequest, error) {
+	configured := make(map[string]*config.Request, len(cfg.Requests))
+	for _, req := range cfg.Requests {
+		configured[req.StateKey()] = req
+	}
+
+	var limiter *rate.Limiter
+	if cfg.RateLimitConfig != nil {
+		limiter = rate.NewLimiter(rate.Every(*cfg.RateLimitConfig.Period), *cfg.RateLimitConfig.Burst)
+	}
+
+	reqs := make([]*flattenedRequest, 0, len(letters))
+
+	for _, letter := range letters {
+		rurl, err := url.Parse(letter.URL)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse dead-lettered URL: %w", err)
+		}
+
+		req := &flattenedRequest{
+			fetchConfig: &web.FetchConfig{
+				C:           client,
+				Method:      letter.Method,
+				URL:         rurl,
+				RateLimiter: limiter,
+				Body:        letter.Body,
+				Clock:       cfg.Clock,
+			},
+			table:      letter.Table,
+			page:       letter.Page,
+			cost:       1,
+			requestKey: letter.Request,
+		}
+
+		if letter.ChunkStart != nil && letter.ChunkEnd != nil {
+			req.chunk = &[2]time.Time{*letter.ChunkStart, *letter.ChunkEnd}
+		}
+
+		if creq, ok := configured[letter.Request]; ok {
+			req.clobColumn = creq.ClobColumn
+			req.recordPages = creq.RecordPages
+			req.sinks = creq.ConnectionStrings
+			req.write = newTableWrite(creq)
+			req.maintenance = creq.Maintenance
+			req.cost = creq.RequestCost()
+			req.fetchConfig.Proxy = creq.Proxy
+
+			if creq.RateLimiter != nil {
+				req.fetchConfig.RateLimiter = creq.RateLimiter
+			}
+		}
+
+		reqs = append(reqs, req)
+	}
+
+	return reqs, nil
+}
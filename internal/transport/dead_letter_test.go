@@ -0,0 +1,123 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/internal/web"
+	"github.com/sirupsen/logrus"
+)
+
+func TestDeadLetterRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "dead.jsonl")
+	reqs := newCheckpointTestRequests(2)
+	reqs[0].chunk = &reqs[0].progress.chunks[0]
+	reqs[1].fetchConfig.Body = []byte(`{"page":2}`)
+	failedAt := time.Date(2022, 5, 11, 0, 0, 0, 0, time.UTC)
+
+	letters, err := createDeadLetters(path, os.O_APPEND)
+	if err != nil {
+		t.Fatalf("failed to create dead letters: %v", err)
+	}
+
+	for _, req := range reqs {
+		if err := letters.write(newDeadLetter(req, fmt.Errorf("connection refused"), 3, failedAt)); err != nil {
+			t.Fatalf("failed to write dead letter: %v", err)
+		}
+	}
+
+	letters.close(logrus.New())
+
+	read, err := readDeadLetters(path)
+	if err != nil {
+		t.Fatalf("failed to read dead letters: %v", err)
+	}
+
+	if len(read) != 2 {
+		t.Fatalf("expected 2 dead letters, got %d", len(read))
+	}
+
+	if read[1].URL != reqs[1].fetchConfig.URL.String() || string(read[1].Body) != `{"page":2}` {
+		t.Fatalf("unexpected dead letter: %+v", read[1])
+	}
+
+	if read[0].Attempts != 3 || read[0].ChunkStart == nil || !read[0].ChunkStart.Equal(reqs[0].progress.chunks[0][0]) {
+		t.Fatalf("unexpected dead letter: %+v", read[0])
+	}
+
+	missing, err := readDeadLetters(filepath.Join(t.TempDir(), "missing.jsonl"))
+	if err != nil || len(missing) != 0 {
+		t.Fatalf("expected no dead letters for a missing file, got %d: %v", len(missing), err)
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		err       error
+		retryable bool
+	}{
+		{err: fmt.Errorf("connection reset"), retryable: true},
+		{err: &web.ResponseError{StatusCode: 429}, retryable: true},
+		{err: &web.ResponseError{StatusCode: 503}, retryable: true},
+		{err: &web.ResponseError{StatusCode: 404}},
+		{err: fmt.Errorf("wrapped: %w", &web.ResponseError{StatusCode: 401})},
+	} {
+		if got := retryable(tcase.err); got != tcase.retryable {
+			t.Fatalf("expected retryable(%v) to be %v", tcase.err, tcase.retryable)
+		}
+	}
+
+	if retryBackoff(1) != time.Second || retryBackoff(3) != 4*time.Second || retryBackoff(20) != maxRetryBackoff {
+		t.Fatalf("unexpected retry backoff")
+	}
+}
+
+func TestReplayRequests(t *testing.T) {
+	t.Parallel()
+
+	burst, period := 1, time.Second
+	req := &config.Request{Method: "GET", Endpoint: "/candles", Table: "candles", ClobColumn: "raw", Cost: 2}
+	cfg := &config.Config{
+		Requests:        []*config.Request{req},
+		RateLimitConfig: &config.RateLimitConfig{Burst: &burst, Period: &period},
+	}
+
+	letters := []*deadLetter{
+		{Request: req.StateKey(), Table: "candles", Method: "GET", URL: "https://api.example.com/candles?page=1"},
+		{Request: "GET /removed removed", Table: "removed", Method: "GET", URL: "https://api.example.com/removed"},
+	}
+
+	reqs, err := replayRequests(cfg, nil, letters)
+	if err != nil {
+		t.Fatalf("failed to build replay requests: %v", err)
+	}
+
+	if reqs[0].clobColumn != "raw" || reqs[0].cost != 2 || reqs[0].fetchConfig.RateLimiter == nil {
+		t.Fatalf("expected the configured request settings, got %+v", reqs[0])
+	}
+
+	if reqs[1].clobColumn != "" || reqs[1].cost != 1 || reqs[1].fetchConfig.URL.Path != "/removed" {
+		t.Fatalf("expected default settings for a removed request, got %+v", reqs[1])
+	}
+
+	if _, err := replayRequests(cfg, nil, []*deadLetter{{URL: "://"}}); err == nil {
+		t.Fatalf("expected an error for an invalid URL")
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/tools"
+)
+
+// ErrDeadlineExceeded is returned when a run reaches its "maxDuration" or "deadline". As with "ErrInterrupted", the
+// data fetched before the deadline is committed and recorded in the checkpoint.
+var ErrDeadlineExceeded = fmt.Errorf("run deadline exceeded")
+
+// deadlineGrace is how long the data fetched before the deadline of a run has to be written and committed, after
+// which the storage transactions are abandoned as well.
+const deadlineGrace = time.Minute
+
+// runDeadline enforces the "maxDuration" and "deadline" of a run, or the deadline of its context if that is earlier.
+// At the deadline the run is canceled, so that it shuts down the same way as an interrupted run, and the requests
+// that are in-flight are aborted rather than waited for. The writes to storage are aborted once "deadlineGrace" has
+// passed as well. A nil deadline never passes.
+type runDeadline struct {
+	at     time.Time
+	cancel context.CancelFunc
+
+	// fetches is closed at the deadline, and writes once the grace period has passed.
+	fetches, writes chan struct{}
+
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+func newRunDeadline(ctx context.Context, cfg *config.Config, start time.Time, cancel context.CancelFunc) *runDeadline {
+	at, ok := cfg.RunDeadline(start)
+
+	// The requests and writes are detached from the context of the run, so they are bound to its deadline here.
+	if ctxAt, ctxOK := ctx.Deadline(); ctxOK && (!ok || ctxAt.Before(at)) {
+		at, ok = ctxAt, true
+	}
+
+	if !ok {
+		return nil
+	}
+
+	dl := &runDeadline{
+		at:      at,
+		cancel:  cancel,
+		fetches: make(chan struct{}),
+		writes:  make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+
+	go dl.watch(tools.ClockOrReal(cfg.Clock))
+
+	return dl
+}
+
+// watch will cancel the run and abort its requests at the deadline, and abort its writes after the grace period.
+func (dl *runDeadline) watch(clock tools.Clock) {
+	select {
+	case <-clock.After(dl.at.Sub(clock.Now())):
+	case <-dl.stopped:
+		return
+	}
+
+	// A deadline that was stopped by the time it passed, e.g. by a run that ended late, does not cancel the run.
+	select {
+	case <-dl.stopped:
+		return
+	default:
+	}
+
+	close(dl.fetches)
+	dl.cancel()
+
+	select {
+	case <-clock.After(deadlineGrace):
+	case <-dl.stopped:
+		return
+	}
+
+	close(dl.writes)
+}
+
+// stop will release the deadline once the run is done.
+func (dl *runDeadline) stop() {
+	if dl == nil {
+		return
+	}
+
+	dl.stopOnce.Do(func() { close(dl.stopped) })
+}
+
+// passed returns true once the deadline has passed.
+func (dl *runDeadline) passed() bool {
+	if dl == nil {
+		return false
+	}
+
+	select {
+	case <-dl.fetches:
+		return true
+	default:
+		return false
+	}
+}
+
+// err returns "ErrDeadlineExceeded" if the deadline has passed, and nil otherwise.
+func (dl *runDeadline) err() error {
+	if !dl.passed() {
+		return nil
+	}
+
+	return fmt.Errorf("%w: the run had to stop by %s", ErrDeadlineExceeded, dl.at.Format(time.RFC3339))
+}
+
+// detachFetch will detach the context of a request like "detach", but only until the deadline, so that a request
+// that is in-flight cannot hold up the run past it.
+func (dl *runDeadline) detachFetch(ctx context.Context) context.Context {
+	if dl == nil {
+		return detach(ctx)
+	}
+
+	return boundContext{detachedContext: detachedContext{parent: ctx}, at: dl.at, done: dl.fetches}
+}
+
+// detachWrite will detach the context of the storage transactions of a batch like "detach", but only until the grace
+// period after the deadline has passed.
+func (dl *runDeadline) detachWrite(ctx context.Context) context.Context {
+	if dl == nil {
+		return detach(ctx)
+	}
+
+	return boundContext{detachedContext: detachedContext{parent: ctx}, at: dl.at.Add(deadlineGrace), done: dl.writes}
+}
+
+// boundContext is a detached context that is done once its channel is closed, at the deadline of a run.
+type boundContext struct {
+	detachedContext
+	at   time.Time
+	done <-chan struct{}
+}
+
+func (ctx boundContext) Deadline() (time.Time, bool) { return ctx.at, true }
+func (ctx boundContext) Done() <-chan struct{}       { return ctx.done }
+
+func (ctx boundContext) Err() error {
+	select {
+	case <-ctx.done:
+		return context.DeadlineExceeded
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,219 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/tools"
+)
+
+func TestRunDeadline(t *testing.T) {
+	t.Parallel()
+
+	t.Run("none", func(t *testing.T) {
+		t.Parallel()
+
+		dl := newRunDeadline(context.Background(), &config.Config{}, time.Now(), func() {})
+		if dl != nil {
+			t.Fatalf("expected no deadline without maxDuration or deadline")
+		}
+
+		dl.stop()
+
+		if dl.passed() || dl.err() != nil {
+			t.Fatalf("expected a nil deadline never to pass")
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		if dl.detachFetch(ctx).Err() != nil || dl.detachWrite(ctx).Err() != nil {
+			t.Fatalf("expected the contexts of a nil deadline to be detached")
+		}
+	})
+
+	t.Run("passed", func(t *testing.T) {
+		t.Parallel()
+
+		start := time.Date(2022, 10, 14, 15, 0, 0, 0, time.UTC)
+		clock := tools.NewFakeClock(start)
+
+		ctx, cancel := context.WithCancel(context.WithValue(context.Background(), testContextKey{}, "value"))
+		defer cancel()
+
+		dl := newRunDeadline(context.Background(), &config.Config{MaxDuration: time.Hour, Clock: clock}, start, cancel)
+		defer dl.stop()
+
+		fetchCtx, writeCtx := dl.detachFetch(ctx), dl.detachWrite(ctx)
+
+		if at, ok := fetchCtx.Deadline(); !ok || !at.Equal(start.Add(time.Hour)) {
+			t.Fatalf("expected the requests to be aborted at %s, got %s", start.Add(time.Hour), at)
+		}
+
+		if fetchCtx.Value(testContextKey{}) != "value" {
+			t.Fatalf("expected the context to carry the parent values")
+		}
+
+		if dl.passed() || fetchCtx.Err() != nil {
+			t.Fatalf("expected the deadline not to have passed")
+		}
+
+		clock.Advance(time.Hour)
+		waitDone(t, fetchCtx, clock, 0)
+
+		if ctx.Err() == nil {
+			t.Fatalf("expected the run to be canceled at the deadline")
+		}
+
+		if err := dl.err(); !errors.Is(err, ErrDeadlineExceeded) {
+			t.Fatalf("expected %v, got %v", ErrDeadlineExceeded, err)
+		}
+
+		// The writes of the fetched data are only aborted once the grace period has passed.
+		if writeCtx.Err() != nil {
+			t.Fatalf("expected the writes to continue after the deadline")
+		}
+
+		waitDone(t, writeCtx, clock, deadlineGrace)
+
+		if !errors.Is(writeCtx.Err(), context.DeadlineExceeded) {
+			t.Fatalf("expected %v, got %v", context.DeadlineExceeded, writeCtx.Err())
+		}
+	})
+
+	t.Run("context", func(t *testing.T) {
+		t.Parallel()
+
+		start := time.Now()
+
+		ctx, cancel := context.WithDeadline(context.Background(), start.Add(time.Hour))
+		defer cancel()
+
+		for _, tcase := range []struct {
+			name string
+			cfg  *config.Config
+			want time.Time
+		}{
+			{name: "no maxDuration", cfg: &config.Config{}, want: start.Add(time.Hour)},
+			{name: "earlier", cfg: &config.Config{MaxDuration: 2 * time.Hour}, want: start.Add(time.Hour)},
+			{name: "later", cfg: &config.Config{MaxDuration: time.Minute}, want: start.Add(time.Minute)},
+		} {
+			dl := newRunDeadline(ctx, tcase.cfg, start, cancel)
+			if dl == nil {
+				t.Fatalf("%s: expected the deadline of the context to be a run deadline", tcase.name)
+			}
+
+			dl.stop()
+
+			if at, ok := dl.detachFetch(ctx).Deadline(); !ok || !at.Equal(tcase.want) {
+				t.Fatalf("%s: expected the requests to be aborted at %s, got %s", tcase.name, tcase.want, at)
+			}
+		}
+	})
+
+	t.Run("stopped", func(t *testing.T) {
+		t.Parallel()
+
+		start := time.Now()
+		clock := tools.NewFakeClock(start)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		cfg := &config.Config{MaxDuration: time.Minute, Clock: clock}
+
+		dl := newRunDeadline(context.Background(), cfg, start, cancel)
+		dl.stop()
+		dl.stop()
+
+		clock.Advance(time.Hour)
+		time.Sleep(10 * time.Millisecond)
+
+		if dl.passed() || ctx.Err() != nil {
+			t.Fatalf("expected a stopped deadline not to cancel the run")
+		}
+	})
+}
+
+func TestUpsertContextDeadline(t *testing.T) {
+	t.Parallel()
+
+	// The server is slower than the deadline of the run, so the request is only ever ended by the deadline.
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		select {
+		case <-req.Context().Done():
+		case <-time.After(30 * time.Second):
+			_, _ = rw.Write([]byte(`[{"id":1}]`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yml")
+
+	data := "version: 1\nurl: " + srv.URL + "\nconnectionStrings:\n- sqlite://" + filepath.Join(dir, "gidari.db") +
+		"\nautoCreate: true\nrateLimit:\n  burst: 1\n  period: 1s\nrequests:\n- endpoint: /slow\n  table: slow\n"
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open config: %v", err)
+	}
+
+	defer file.Close()
+
+	cfg, err := config.New(context.Background(), file)
+	if err != nil {
+		t.Fatalf("failed to create config: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+
+	if err := Upsert(ctx, cfg); !errors.Is(err, ErrDeadlineExceeded) {
+		t.Fatalf("expected %v, got %v", ErrDeadlineExceeded, err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Fatalf("expected the run to stop at the deadline of its context, took %s", elapsed)
+	}
+}
+
+// waitDone will advance the clock by "step" until the context is done, since the deadline waits on the clock in a
+// goroutine of its own.
+func waitDone(t *testing.T, ctx context.Context, clock *tools.FakeClock, step time.Duration) {
+	t.Helper()
+
+	timeout := time.After(5 * time.Second)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timeout:
+			t.Fatalf("expected the context to be done")
+		case <-time.After(time.Millisecond):
+			clock.Advance(step)
+		}
+	}
+}
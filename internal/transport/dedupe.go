@@ -0,0 +1,48 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// dedupeRecords will keep only the last copy of the records of each key, the values of their "keys", where it was
+// last received, and return the number of earlier copies that were dropped. Records that are not objects, or that
+// have none of the keys, are all kept.
+func dedupeRecords(records []json.RawMessage, keys []string) ([]json.RawMessage, int) {
+	if len(keys) == 0 {
+		return records, 0
+	}
+
+	unkeyed := "[" + strings.TrimSuffix(strings.Repeat("null,", len(keys)), ",") + "]"
+
+	recordKeys := make([]string, len(records))
+	last := make(map[string]int, len(records))
+
+	for idx, record := range records {
+		key, err := partitionKey(record, keys)
+		if err != nil || string(key) == unkeyed {
+			continue
+		}
+
+		recordKeys[idx], last[string(key)] = string(key), idx
+	}
+
+	deduped := make([]json.RawMessage, 0, len(last))
+
+	for idx, record := range records {
+		if key := recordKeys[idx]; key != "" && last[key] != idx {
+			continue
+		}
+
+		deduped = append(deduped, record)
+	}
+
+	return deduped, len(records) - len(deduped)
+}
@@ -0,0 +1,79 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDedupeRecords(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name    string
+		keys    []string
+		records []string
+		want    []string
+		dropped int
+	}{
+		{
+			name:    "no keys",
+			records: []string{`{"id":1}`, `{"id":1}`},
+			want:    []string{`{"id":1}`, `{"id":1}`},
+		},
+		{
+			name:    "latest copy",
+			keys:    []string{"id"},
+			records: []string{`{"id":1,"v":"a"}`, `{"id":2,"v":"b"}`, `{"id":1,"v":"c"}`},
+			want:    []string{`{"id":2,"v":"b"}`, `{"id":1,"v":"c"}`},
+			dropped: 1,
+		},
+		{
+			name:    "compound key",
+			keys:    []string{"id", "exchange"},
+			records: []string{`{"id":1,"exchange":"a"}`, `{"id":1,"exchange":"b"}`, `{"exchange":"a","id":1}`},
+			want:    []string{`{"id":1,"exchange":"b"}`, `{"exchange":"a","id":1}`},
+			dropped: 1,
+		},
+		{
+			name:    "numbers",
+			keys:    []string{"id"},
+			records: []string{`{"id":10000000000000001}`, `{"id":10000000000000002}`},
+			want:    []string{`{"id":10000000000000001}`, `{"id":10000000000000002}`},
+		},
+		{
+			name:    "unkeyed",
+			keys:    []string{"id"},
+			records: []string{`{"v":1}`, `{"v":1}`, `[1]`, `{"id":null}`},
+			want:    []string{`{"v":1}`, `{"v":1}`, `[1]`, `{"id":null}`},
+		},
+	} {
+		records := make([]json.RawMessage, len(tcase.records))
+		for idx, record := range tcase.records {
+			records[idx] = json.RawMessage(record)
+		}
+
+		got, dropped := dedupeRecords(records, tcase.keys)
+		if dropped != tcase.dropped {
+			t.Fatalf("%s: expected %d dropped, got %d", tcase.name, tcase.dropped, dropped)
+		}
+
+		if len(got) != len(tcase.want) {
+			t.Fatalf("%s: expected %s, got %s", tcase.name, tcase.want, got)
+		}
+
+		for idx, record := range got {
+			if string(record) != tcase.want[idx] {
+				t.Fatalf("%s: expected %s, got %s", tcase.name, tcase.want, got)
+			}
+		}
+	}
+}
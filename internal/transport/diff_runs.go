@@ -0,0 +1,265 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/alpstable/gidari/config"
+)
+
+// ErrInvalidDiffRuns is returned when the options to compare runs are invalid.
+var ErrInvalidDiffRuns = fmt.Errorf("invalid diff-runs")
+
+// ErrRunsDiffer is returned by "DiffRuns" when the runs differ in their status, or the rows, chunk coverage, requests
+// or columns of a table. Durations are reported but never differ, since they vary from run to run.
+var ErrRunsDiffer = fmt.Errorf("runs differ")
+
+// DiffRunsOptions are the settings for comparing the manifests of two runs.
+type DiffRunsOptions struct {
+	// Dir is the directory that the manifests are looked up in by the ID of their run. The default is the default
+	// "manifest.dir".
+	Dir string
+
+	// Runs are the IDs of the two runs, or the paths of their manifests, the baseline first.
+	Runs []string
+
+	// Output is where the differences are written to.
+	Output io.Writer
+}
+
+func (opts *DiffRunsOptions) validate() error {
+	if len(opts.Runs) != 2 {
+		return fmt.Errorf("%w: expected two runs, got %d", ErrInvalidDiffRuns, len(opts.Runs))
+	}
+
+	if opts.Output == nil {
+		return fmt.Errorf("%w: an output is required", ErrInvalidDiffRuns)
+	}
+
+	return nil
+}
+
+// loadManifest will read the manifest of a run, from the path "run" if it is a file, and from "<dir>/<run>.json"
+// otherwise. Errors wrap "invalid", the error of the command that reads the manifest.
+func loadManifest(dir, run string, invalid error) (*runManifest, error) {
+	path := run
+	if info, err := os.Stat(path); err != nil || info.IsDir() {
+		path = filepath.Join(dir, run+".json")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: no manifest for run %q: %v", invalid, run, err)
+	}
+
+	var manifest runManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("%w: manifest %s: %v", invalid, path, err)
+	}
+
+	if manifest.Tables == nil {
+		manifest.Tables = make(map[string]*tableManifest)
+	}
+
+	return &manifest, nil
+}
+
+// subtractChunks returns the spans of time covered by the merged chunks "from" but not by the merged chunks "other".
+func subtractChunks(from, other []*manifestChunk) []*manifestChunk {
+	var spans []*manifestChunk
+
+	for _, chunk := range from {
+		start := chunk.Start
+
+		for _, cut := range other {
+			if !cut.End.After(start) || !cut.Start.Before(chunk.End) {
+				continue
+			}
+
+			if cut.Start.After(start) {
+				spans = append(spans, &manifestChunk{Start: start, End: cut.Start})
+			}
+
+			start = cut.End
+		}
+
+		if chunk.End.After(start) {
+			spans = append(spans, &manifestChunk{Start: start, End: chunk.End})
+		}
+	}
+
+	return spans
+}
+
+// formatMS formats a duration in milliseconds, e.g. "1m2.5s".
+func formatMS(ms int64) string {
+	return (time.Duration(ms) * time.Millisecond).String()
+}
+
+// diffColumns returns the differences between the columns of a table in two runs.
+func diffColumns(name string, base, other *runManifest, left, right map[string]string) []string {
+	columns := make(map[string]bool, len(left)+len(right))
+	for _, cols := range []map[string]string{left, right} {
+		for column := range cols {
+			columns[column] = true
+		}
+	}
+
+	sorted := make([]string, 0, len(columns))
+	for column := range columns {
+		sorted = append(sorted, column)
+	}
+
+	sort.Strings(sorted)
+
+	var diffs []string
+
+	for _, column := range sorted {
+		leftKind, inLeft := left[column]
+		rightKind, inRight := right[column]
+
+		switch {
+		case !inRight:
+			diffs = append(diffs, fmt.Sprintf("%s: column %s (%s) only in %s", name, column, leftKind, base.ID))
+		case !inLeft:
+			diffs = append(diffs, fmt.Sprintf("%s: column %s (%s) only in %s", name, column, rightKind, other.ID))
+		case leftKind != rightKind:
+			diffs = append(diffs, fmt.Sprintf("%s: column %s is %s -> %s", name, column, leftKind, rightKind))
+		}
+	}
+
+	return diffs
+}
+
+// diffTable returns the differences between a table in two runs, which is in both of them.
+func diffTable(name string, base, other *runManifest) []string {
+	left, right := base.Tables[name], other.Tables[name]
+
+	var diffs []string
+
+	if left.Rows != right.Rows {
+		diffs = append(diffs, fmt.Sprintf("%s: rows %d -> %d (%+d)", name, left.Rows, right.Rows,
+			right.Rows-left.Rows))
+	}
+
+	if left.Requests != right.Requests {
+		diffs = append(diffs, fmt.Sprintf("%s: requests %d -> %d (%+d)", name, left.Requests, right.Requests,
+			right.Requests-left.Requests))
+	}
+
+	for _, side := range []struct {
+		run        *runManifest
+		from, rest []*manifestChunk
+	}{
+		{run: base, from: left.Chunks, rest: right.Chunks},
+		{run: other, from: right.Chunks, rest: left.Chunks},
+	} {
+		for _, span := range subtractChunks(side.from, side.rest) {
+			diffs = append(diffs, fmt.Sprintf("%s: chunks %s to %s only in %s", name, span.Start.Format(time.RFC3339),
+				span.End.Format(time.RFC3339), side.run.ID))
+		}
+	}
+
+	return append(diffs, diffColumns(name, base, other, left.Columns, right.Columns)...)
+}
+
+// diffManifests returns the differences between two runs, and how long each table took to fetch in both of them,
+// which is not a difference.
+func diffManifests(base, other *runManifest) ([]string, []string) {
+	var diffs, durations []string
+
+	if base.Status != other.Status {
+		diffs = append(diffs, fmt.Sprintf("status %s -> %s", base.Status, other.Status))
+	}
+
+	names := make(map[string]bool, len(base.Tables)+len(other.Tables))
+	for _, manifest := range []*runManifest{base, other} {
+		for name := range manifest.Tables {
+			names[name] = true
+		}
+	}
+
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+
+	sort.Strings(sorted)
+
+	for _, name := range sorted {
+		left, right := base.Tables[name], other.Tables[name]
+
+		switch {
+		case right == nil:
+			diffs = append(diffs, fmt.Sprintf("%s: table only in %s", name, base.ID))
+		case left == nil:
+			diffs = append(diffs, fmt.Sprintf("%s: table only in %s", name, other.ID))
+		default:
+			diffs = append(diffs, diffTable(name, base, other)...)
+			durations = append(durations, fmt.Sprintf("%s: fetched in %s -> %s", name, formatMS(left.FetchMS),
+				formatMS(right.FetchMS)))
+		}
+	}
+
+	return diffs, durations
+}
+
+// DiffRuns will compare the manifests of two runs, writing how the second run differs from the baseline: its
+// status, and the rows, requests, chunk coverage and columns of each table. The durations of the runs and of their
+// tables are written too. "ErrRunsDiffer" is returned if the runs differ, so that the comparison can gate an upgrade
+// or a change to the configuration.
+func DiffRuns(opts DiffRunsOptions) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+
+	if opts.Dir == "" {
+		opts.Dir = config.DefaultManifestDir
+	}
+
+	base, err := loadManifest(opts.Dir, opts.Runs[0], ErrInvalidDiffRuns)
+	if err != nil {
+		return err
+	}
+
+	other, err := loadManifest(opts.Dir, opts.Runs[1], ErrInvalidDiffRuns)
+	if err != nil {
+		return err
+	}
+
+	lines := []string{fmt.Sprintf("comparing run %s (%s in %s) with run %s (%s in %s)", base.ID, base.Status,
+		formatMS(base.DurationMS), other.ID, other.Status, formatMS(other.DurationMS))}
+
+	diffs, durations := diffManifests(base, other)
+	lines = append(append(lines, diffs...), durations...)
+
+	if len(diffs) == 0 {
+		lines = append(lines, "the runs match")
+	} else {
+		lines = append(lines, fmt.Sprintf("the runs have %d differences", len(diffs)))
+	}
+
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(opts.Output, line); err != nil {
+			return fmt.Errorf("failed to write the differences: %w", err)
+		}
+	}
+
+	if len(diffs) > 0 {
+		return ErrRunsDiffer
+	}
+
+	return nil
+}
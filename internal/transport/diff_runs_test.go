@@ -0,0 +1,120 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiffRuns(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	day := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	write := func(manifest *runManifest) {
+		data, _ := json.Marshal(manifest)
+		if err := os.WriteFile(filepath.Join(dir, manifest.ID+".json"), data, 0o600); err != nil {
+			t.Fatalf("failed to write manifest: %v", err)
+		}
+	}
+
+	write(&runManifest{
+		ID:         "base",
+		Status:     manifestCompleted,
+		DurationMS: 62000,
+		Tables: map[string]*tableManifest{
+			"candles": {
+				Requests: 4,
+				Rows:     1200,
+				FetchMS:  3200,
+				Chunks:   []*manifestChunk{{Start: day, End: day.AddDate(0, 0, 4)}},
+				Columns:  map[string]string{"id": "integer", "price": "string", "volume": "number"},
+			},
+			"trades": {Requests: 1, Rows: 10},
+		},
+	})
+
+	write(&runManifest{
+		ID:         "upgrade",
+		Status:     manifestCompleted,
+		DurationMS: 58000,
+		Tables: map[string]*tableManifest{
+			"candles": {
+				Requests: 4,
+				Rows:     1180,
+				FetchMS:  2900,
+				Chunks: []*manifestChunk{
+					{Start: day, End: day.AddDate(0, 0, 2)},
+					{Start: day.AddDate(0, 0, 3), End: day.AddDate(0, 0, 5)},
+				},
+				Columns: map[string]string{"fee": "number", "id": "integer", "price": "number", "volume": "number"},
+			},
+			"trades": {Requests: 1, Rows: 10, FetchMS: 100},
+			"orders": {Requests: 1, Rows: 5},
+		},
+	})
+
+	for _, tcase := range []struct {
+		name string
+		runs []string
+		want []string
+		err  error
+	}{
+		{
+			name: "same run",
+			runs: []string{"base", filepath.Join(dir, "base.json")},
+			want: []string{
+				"comparing run base (completed in 1m2s) with run base (completed in 1m2s)",
+				"candles: fetched in 3.2s -> 3.2s",
+				"trades: fetched in 0s -> 0s",
+				"the runs match",
+			},
+		},
+		{
+			name: "upgrade",
+			runs: []string{"base", "upgrade"},
+			want: []string{
+				"comparing run base (completed in 1m2s) with run upgrade (completed in 58s)",
+				"candles: rows 1200 -> 1180 (-20)",
+				"candles: chunks 2022-01-03T00:00:00Z to 2022-01-04T00:00:00Z only in base",
+				"candles: chunks 2022-01-05T00:00:00Z to 2022-01-06T00:00:00Z only in upgrade",
+				"candles: column fee (number) only in upgrade",
+				"candles: column price is string -> number",
+				"orders: table only in upgrade",
+				"candles: fetched in 3.2s -> 2.9s",
+				"trades: fetched in 0s -> 100ms",
+				"the runs have 6 differences",
+			},
+			err: ErrRunsDiffer,
+		},
+		{name: "one run", runs: []string{"base"}, err: ErrInvalidDiffRuns},
+		{name: "missing run", runs: []string{"base", "missing"}, err: ErrInvalidDiffRuns},
+	} {
+		var out bytes.Buffer
+
+		err := DiffRuns(DiffRunsOptions{Dir: dir, Runs: tcase.runs, Output: &out})
+		if !errors.Is(err, tcase.err) {
+			t.Fatalf("%s: expected error %v, got %v", tcase.name, tcase.err, err)
+		}
+
+		if got := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n"); tcase.want != nil &&
+			strings.Join(got, "\n") != strings.Join(tcase.want, "\n") {
+			t.Fatalf("%s: expected\n%s\ngot\n%s", tcase.name, strings.Join(tcase.want, "\n"), out.String())
+		}
+	}
+}
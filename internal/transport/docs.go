@@ -0,0 +1,464 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/internal/web"
+	"github.com/alpstable/gidari/tools"
+)
+
+const (
+	// DocsFormatMarkdown writes the data dictionary as a Markdown document, with a table of columns for every
+	// table. This is the default.
+	DocsFormatMarkdown = "markdown"
+
+	// DocsFormatJSON writes the data dictionary as a JSON document.
+	DocsFormatJSON = "json"
+
+	// defaultDocsSamples is the number of records of each request that the columns are inferred from.
+	defaultDocsSamples = 100
+
+	// maxExampleLength is the longest example value of a column, after which it is truncated.
+	maxExampleLength = 40
+)
+
+// The types of the columns of a data dictionary.
+const (
+	docsTypeString  = "string"
+	docsTypeInteger = "integer"
+	docsTypeNumber  = "number"
+	docsTypeBoolean = "boolean"
+	docsTypeArray   = "array"
+	docsTypeObject  = "object"
+)
+
+// ErrInvalidDocs is returned when the docs options are invalid.
+var ErrInvalidDocs = fmt.Errorf("invalid docs")
+
+// DocsOptions are the settings for generating a data dictionary of the tables that the configured requests are
+// written to.
+type DocsOptions struct {
+	// Format is the format of the data dictionary, either "markdown" or "json".
+	Format string
+
+	// Output is where the data dictionary is written to.
+	Output io.Writer
+
+	// Samples is the number of records of each request that the columns are inferred from. The default is 100.
+	Samples int
+}
+
+func (opts *DocsOptions) validate() error {
+	switch opts.Format {
+	case DocsFormatMarkdown, DocsFormatJSON:
+	default:
+		return fmt.Errorf("%w: format %q must be %q or %q", ErrInvalidDocs, opts.Format, DocsFormatMarkdown,
+			DocsFormatJSON)
+	}
+
+	if opts.Output == nil {
+		return fmt.Errorf("%w: an output is required", ErrInvalidDocs)
+	}
+
+	if opts.Samples < 0 {
+		return fmt.Errorf("%w: samples must not be negative", ErrInvalidDocs)
+	}
+
+	return nil
+}
+
+// docsColumn is a column of a table, inferred from the sampled records.
+type docsColumn struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Nullable bool   `json:"nullable"`
+	Example  string `json:"example,omitempty"`
+
+	// types are every type the column was seen with, and seen the number of records it was set in.
+	types map[string]bool
+	seen  int
+}
+
+// docsTable is a table of the data dictionary, with the requests that are written to it.
+type docsTable struct {
+	Name        string        `json:"name"`
+	Sources     []string      `json:"sources"`
+	PrimaryKeys []string      `json:"primaryKeys,omitempty"`
+	Records     int           `json:"sampledRecords"`
+	Columns     []*docsColumn `json:"columns"`
+
+	columns map[string]*docsColumn
+}
+
+// dataDictionary describes the tables, and their columns, of the data written by the configured requests.
+type dataDictionary struct {
+	Tables []*docsTable `json:"tables"`
+
+	tables map[string]*docsTable
+}
+
+func newDataDictionary() *dataDictionary {
+	return &dataDictionary{tables: make(map[string]*docsTable)}
+}
+
+// table returns the table with the name, adding it to the dictionary if it is new.
+func (dict *dataDictionary) table(name string) *docsTable {
+	table := dict.tables[name]
+	if table == nil {
+		table = &docsTable{Name: name, columns: make(map[string]*docsColumn)}
+		dict.tables[name] = table
+		dict.Tables = append(dict.Tables, table)
+	}
+
+	return table
+}
+
+// docsSource identifies the request that a table is written from, e.g. "GET /trades". The query of the endpoint is
+// left out, since it may contain credentials.
+func docsSource(req *config.Request) string {
+	endpoint, _, _ := strings.Cut(req.Endpoint, "?")
+
+	return fmt.Sprintf("%s %s", req.Method, endpoint)
+}
+
+// docsType returns the type of a JSON value, or an empty string for null.
+func docsType(val interface{}) string {
+	switch val := val.(type) {
+	case string:
+		return docsTypeString
+	case float64:
+		if val == float64(int64(val)) {
+			return docsTypeInteger
+		}
+
+		return docsTypeNumber
+	case bool:
+		return docsTypeBoolean
+	case []interface{}:
+		return docsTypeArray
+	case map[string]interface{}:
+		return docsTypeObject
+	default:
+		return ""
+	}
+}
+
+// docsExample returns a value as it is shown as the example of a column, truncated to "maxExampleLength".
+func docsExample(val interface{}) string {
+	example, ok := val.(string)
+	if !ok {
+		data, _ := json.Marshal(val)
+		example = string(data)
+	}
+
+	if runes := []rune(example); len(runes) > maxExampleLength {
+		example = string(runes[:maxExampleLength]) + "..."
+	}
+
+	return example
+}
+
+// observe will add a value of a record to the column at "path". Nested objects are documented as a column for
+// each of their fields, e.g. "size.amount", the same way the file sinks flatten them.
+func (table *docsTable) observe(path string, val interface{}) {
+	if obj, ok := val.(map[string]interface{}); ok && len(obj) > 0 {
+		for key, item := range obj {
+			table.observe(path+"."+key, item)
+		}
+
+		return
+	}
+
+	column := table.columns[path]
+	if column == nil {
+		column = &docsColumn{Name: path, types: make(map[string]bool)}
+		table.columns[path] = column
+	}
+
+	typ := docsType(val)
+	if typ == "" {
+		return
+	}
+
+	column.seen++
+	column.types[typ] = true
+
+	if column.Example == "" {
+		column.Example = docsExample(val)
+	}
+}
+
+// observeRecords will add the records of a response to the table.
+func (table *docsTable) observeRecords(records []interface{}) {
+	for _, record := range records {
+		table.Records++
+
+		obj, ok := record.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		for key, val := range obj {
+			table.observe(key, val)
+		}
+	}
+}
+
+// finish will resolve the type of every column and sort the columns by name. Integers that are also seen with
+// fractions are numbers, and columns seen with several other types list each of them, e.g. "number | string".
+func (table *docsTable) finish() {
+	table.Columns = make([]*docsColumn, 0, len(table.columns))
+
+	for _, column := range table.columns {
+		if column.types[docsTypeNumber] {
+			delete(column.types, docsTypeInteger)
+		}
+
+		types := make([]string, 0, len(column.types))
+		for typ := range column.types {
+			types = append(types, typ)
+		}
+
+		sort.Strings(types)
+
+		// Columns that are only ever null have no type.
+		column.Type = strings.Join(types, " | ")
+		if column.Type == "" {
+			column.Type = "null"
+		}
+
+		column.Nullable = column.seen < table.Records
+
+		table.Columns = append(table.Columns, column)
+	}
+
+	sort.Slice(table.Columns, func(i, j int) bool { return table.Columns[i].Name < table.Columns[j].Name })
+}
+
+// sampleRequest will fetch the first page of a request, or the first chunk of a timeseries request, returning its
+// records. Invalid JSON is returned as the text of the "clobColumn", if the request has one.
+func sampleRequest(ctx context.Context, req *flattenedRequest, samples int) ([]interface{}, error) {
+	rsp, err := web.Fetch(ctx, req.fetchConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rsp.Body.Close()
+
+	data, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if !json.Valid(data) {
+		if req.clobColumn == "" {
+			return nil, nil
+		}
+
+		return []interface{}{map[string]interface{}{req.clobColumn: string(data)}}, nil
+	}
+
+	records, err := decodeResponseRecords(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) > samples {
+		records = records[:samples]
+	}
+
+	return records, nil
+}
+
+// sampleDictionary will infer the data dictionary of the configured requests from a sample of their responses.
+// Requests that make the same HTTP request share a single sample.
+func sampleDictionary(ctx context.Context, cfg *config.Config, client *web.Client,
+	samples int,
+) (*dataDictionary, error) {
+	dict := newDataDictionary()
+	sampled := make(map[string][]interface{})
+
+	// The templates of the requests read the variables of the state store as a run would, but the store is never
+	// saved.
+	store, err := openState(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, req := range cfg.Requests {
+		table := dict.table(req.Table)
+		table.Sources = append(table.Sources, docsSource(req))
+
+		if tcfg, ok := cfg.Tables[req.Table]; ok && len(table.PrimaryKeys) == 0 {
+			table.PrimaryKeys = tcfg.PrimaryKeys
+		}
+
+		if err := req.RenderQuery(store); err != nil {
+			return nil, fmt.Errorf("failed to render request query: %w", err)
+		}
+
+		flatReqs, err := flattenRequestTimeseries(req, *cfg.URL, client, store)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(flatReqs) == 0 {
+			continue
+		}
+
+		flatReq := flatReqs[0]
+
+		records, ok := sampled[flatReq.fetchKey()]
+		if !ok {
+			if records, err = sampleRequest(ctx, flatReq, samples); err != nil {
+				return nil, fmt.Errorf("unable to sample %s: %w", docsSource(req), err)
+			}
+
+			sampled[flatReq.fetchKey()] = records
+		}
+
+		table.observeRecords(records)
+	}
+
+	sort.Slice(dict.Tables, func(i, j int) bool { return dict.Tables[i].Name < dict.Tables[j].Name })
+
+	for _, table := range dict.Tables {
+		table.finish()
+	}
+
+	return dict, nil
+}
+
+// markdownCell escapes the text of a cell of a Markdown table.
+func markdownCell(str string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ", "\r", " ", "`", "'").Replace(str)
+}
+
+// writeMarkdown will write the data dictionary as a Markdown document.
+func (dict *dataDictionary) writeMarkdown(w io.Writer) error {
+	var doc strings.Builder
+
+	doc.WriteString("# Data dictionary\n")
+
+	for _, table := range dict.Tables {
+		fmt.Fprintf(&doc, "\n## %s\n\n", table.Name)
+
+		sources := make([]string, len(table.Sources))
+		for idx, source := range table.Sources {
+			sources[idx] = "`" + markdownCell(source) + "`"
+		}
+
+		fmt.Fprintf(&doc, "- Sources: %s\n", strings.Join(sources, ", "))
+
+		if len(table.PrimaryKeys) > 0 {
+			fmt.Fprintf(&doc, "- Primary keys: `%s`\n", strings.Join(table.PrimaryKeys, "`, `"))
+		}
+
+		fmt.Fprintf(&doc, "- Sampled records: %d\n", table.Records)
+
+		if len(table.Columns) == 0 {
+			continue
+		}
+
+		doc.WriteString("\n| Column | Type | Nullable | Example |\n| --- | --- | --- | --- |\n")
+
+		for _, column := range table.Columns {
+			nullable := "no"
+			if column.Nullable {
+				nullable = "yes"
+			}
+
+			example := ""
+			if column.Example != "" {
+				example = "`" + markdownCell(column.Example) + "`"
+			}
+
+			fmt.Fprintf(&doc, "| `%s` | %s | %s | %s |\n", markdownCell(column.Name), markdownCell(column.Type), nullable,
+				example)
+		}
+	}
+
+	if _, err := io.WriteString(w, doc.String()); err != nil {
+		return fmt.Errorf("unable to write docs: %w", err)
+	}
+
+	return nil
+}
+
+// writeJSON will write the data dictionary as an indented JSON document.
+func (dict *dataDictionary) writeJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	if err := encoder.Encode(dict); err != nil {
+		return fmt.Errorf("unable to write docs: %w", err)
+	}
+
+	return nil
+}
+
+// Docs will sample the responses of the configured requests and write a data dictionary of the tables that they are
+// written to, with the columns, inferred types and example values of each table and the requests it is written
+// from. Nothing is written to storage.
+func Docs(ctx context.Context, cfg *config.Config, opts DocsOptions) error {
+	start := time.Now()
+
+	if opts.Format == "" {
+		opts.Format = DocsFormatMarkdown
+	}
+
+	if err := opts.validate(); err != nil {
+		return err
+	}
+
+	if opts.Samples == 0 {
+		opts.Samples = defaultDocsSamples
+	}
+
+	client, err := connect(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to connect to web API: %w", err)
+	}
+
+	dict, err := sampleDictionary(ctx, cfg, client, opts.Samples)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.Canceled) {
+			return ErrInterrupted
+		}
+
+		return err
+	}
+
+	if opts.Format == DocsFormatJSON {
+		err = dict.writeJSON(opts.Output)
+	} else {
+		err = dict.writeMarkdown(opts.Output)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	logInfo := tools.LogFormatter{
+		Duration: time.Since(start),
+		Msg:      fmt.Sprintf("documented %d tables", len(dict.Tables)),
+	}
+	cfg.Logger.Info(logInfo.String())
+
+	return nil
+}
@@ -0,0 +1,169 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+)
+
+func TestDocs(t *testing.T) {
+	t.Parallel()
+
+	var fetches int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+
+		switch r.URL.Path {
+		case "/trades":
+			io.WriteString(w, `[
+				{"id":1,"price":"10.5","size":{"amount":2,"unit":"btc"},"tags":["a"],"side":null},
+				{"id":2,"price":"11","size":{"amount":0.5,"unit":"btc"},"tags":[],"side":"buy","note":"|x|"},
+				{"id":3,"price":12,"size":{"amount":1,"unit":"btc"},"tags":[],"side":"sell"}
+			]`)
+		case "/status":
+			io.WriteString(w, "ok")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	rurl, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("failed to parse url: %v", err)
+	}
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	newRequest := func(endpoint, table string) *config.Request {
+		return &config.Request{
+			Endpoint:    endpoint,
+			Method:      http.MethodGet,
+			Table:       table,
+			Query:       map[string]string{"apikey": "secret"},
+			RateLimiter: rate.NewLimiter(rate.Inf, 1),
+		}
+	}
+
+	status := newRequest("/status", "status")
+	status.ClobColumn = "body"
+
+	cfg := &config.Config{
+		URL:    rurl,
+		Logger: logger,
+		Tables: map[string]*config.Table{"trades": {PrimaryKeys: []string{"id"}}},
+		Requests: []*config.Request{
+			newRequest("/trades", "trades"),
+			newRequest("/trades", "trades_archive"),
+			status,
+		},
+	}
+
+	var out bytes.Buffer
+
+	err = Docs(context.Background(), cfg, DocsOptions{Format: DocsFormatJSON, Output: &out, Samples: 2})
+	if err != nil {
+		t.Fatalf("failed to document: %v", err)
+	}
+
+	// Requests that make the same HTTP request share a sample.
+	if fetches != 2 {
+		t.Fatalf("expected 2 fetches, got %d", fetches)
+	}
+
+	var dict dataDictionary
+	if err := json.Unmarshal(out.Bytes(), &dict); err != nil {
+		t.Fatalf("failed to decode docs: %v", err)
+	}
+
+	if len(dict.Tables) != 3 || dict.Tables[0].Name != "status" || dict.Tables[1].Name != "trades" {
+		t.Fatalf("expected the tables status, trades and trades_archive, got %+v", dict.Tables)
+	}
+
+	trades := dict.Tables[1]
+	if trades.Records != 2 || !reflect.DeepEqual(trades.Sources, []string{"GET /trades"}) ||
+		!reflect.DeepEqual(trades.PrimaryKeys, []string{"id"}) {
+		t.Fatalf("expected 2 records of GET /trades with the primary key id, got %+v", trades)
+	}
+
+	want := []*docsColumn{
+		{Name: "id", Type: "integer", Example: "1"},
+		{Name: "note", Type: "string", Nullable: true, Example: "|x|"},
+		{Name: "price", Type: "string", Example: "10.5"},
+		{Name: "side", Type: "string", Nullable: true, Example: "buy"},
+		{Name: "size.amount", Type: "number", Example: "2"},
+		{Name: "size.unit", Type: "string", Example: "btc"},
+		{Name: "tags", Type: "array", Example: `["a"]`},
+	}
+
+	if !reflect.DeepEqual(trades.Columns, want) {
+		t.Fatalf("expected columns %s, got %s", mustJSON(t, want), mustJSON(t, trades.Columns))
+	}
+
+	columns := dict.Tables[0].Columns
+	if len(columns) != 1 || columns[0].Name != "body" || columns[0].Type != "string" {
+		t.Fatalf("expected the clob column of invalid JSON, got %s", mustJSON(t, columns))
+	}
+
+	out.Reset()
+
+	if err := Docs(context.Background(), cfg, DocsOptions{Output: &out}); err != nil {
+		t.Fatalf("failed to document: %v", err)
+	}
+
+	markdown := out.String()
+	for _, want := range []string{
+		"# Data dictionary\n\n## status\n",
+		"## trades\n\n- Sources: `GET /trades`\n- Primary keys: `id`\n- Sampled records: 3\n",
+		"| `note` | string | yes | `\\|x\\|` |\n",
+		"| `price` | integer \\| string | no | `10.5` |\n",
+	} {
+		if !strings.Contains(markdown, want) {
+			t.Fatalf("expected the docs to contain %q, got:\n%s", want, markdown)
+		}
+	}
+
+	if strings.Contains(markdown, "secret") {
+		t.Fatalf("expected the docs to not contain the query of the endpoint, got:\n%s", markdown)
+	}
+
+	err = Docs(context.Background(), cfg, DocsOptions{Format: "html", Output: &out})
+	if !errors.Is(err, ErrInvalidDocs) {
+		t.Fatalf("expected %v, got %v", ErrInvalidDocs, err)
+	}
+}
+
+func mustJSON(t *testing.T, val interface{}) string {
+	t.Helper()
+
+	data, err := json.Marshal(val)
+	if err != nil {
+		t.Fatalf("failed to encode: %v", err)
+	}
+
+	return string(data)
+}
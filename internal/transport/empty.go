@@ -0,0 +1,127 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/internal/web"
+	"github.com/alpstable/gidari/tools"
+)
+
+// ErrEmptyResponse is the error of a request that fails on a response without records.
+var ErrEmptyResponse = fmt.Errorf("empty response")
+
+// emptyTableSuffix is appended to a request's table name to get the name of the table that empty responses are
+// marked in.
+const emptyTableSuffix = "_empty"
+
+// emptyTable returns the name of the side table that the empty responses for "table" are marked in.
+func emptyTable(table string) string {
+	return table + emptyTableSuffix
+}
+
+// emptyPolicy returns what is done with an empty response to the request that was fetched at "fetchedAt". Empty
+// responses are written like any other if the chunk of the request, or else the time it was fetched, is within one
+// of its "expectedEmptyOk" windows.
+func (req *flattenedRequest) emptyPolicy(fetchedAt time.Time) string {
+	if req.onEmpty == "" {
+		return config.OnEmptyWrite
+	}
+
+	start, end := fetchedAt, fetchedAt
+	if req.chunk != nil {
+		start, end = req.chunk[0], req.chunk[1]
+	}
+
+	if req.expectedEmpty.Contains(start, end) {
+		return config.OnEmptyWrite
+	}
+
+	return req.onEmpty
+}
+
+// writesEmpty returns true if an empty response to the request that was fetched at "fetchedAt" is written.
+func (req *flattenedRequest) writesEmpty(fetchedAt time.Time) bool {
+	policy := req.emptyPolicy(fetchedAt)
+
+	return policy == config.OnEmptyWrite || policy == config.OnEmptyMarker
+}
+
+// handlesEmpty returns true if any of the targets has an "onEmpty" policy, so that their records must be counted.
+func handlesEmpty(targets []*flattenedRequest) bool {
+	for _, target := range targets {
+		if target.onEmpty != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// handleEmpty will apply the "onEmpty" policy of every target of an empty response: marking it in the side table of
+// the target, logging that it was skipped, or failing the targets, which are dead-lettered if the transport has a
+// dead-letter file. It returns the targets whose response was skipped or failed, so that their chunks are fetched
+// again by the next run.
+func (job *webJob) handleEmpty(workerID int, targets []*flattenedRequest, rsp *web.FetchResponse, attempts int,
+	elapsed time.Duration, fetchedAt time.Time,
+) map[*flattenedRequest]bool {
+	incomplete := make(map[*flattenedRequest]bool)
+
+	var failed []*flattenedRequest
+
+	for _, target := range targets {
+		switch target.emptyPolicy(fetchedAt) {
+		case config.OnEmptyMarker:
+			bytes, err := json.Marshal(newPageRecord(target, rsp, 0, elapsed, fetchedAt))
+			if err != nil {
+				job.logger.Errorf("failed to marshal empty marker: %s", err)
+
+				continue
+			}
+
+			job.send(&repoJob{
+				b:     bytes,
+				req:   *rsp.Request,
+				table: emptyTable(target.table),
+				sinks: target.sinks,
+				write: pagesWrite(target),
+			})
+		case config.OnEmptySkip:
+			incomplete[target] = true
+
+			logWarn := tools.LogFormatter{
+				WorkerID:   workerID,
+				WorkerName: "web",
+				Msg:        fmt.Sprintf("skipping empty response of %s for %s", job.fetchConfig.URL, target.table),
+			}
+			job.logger.Warn(logWarn.String())
+		case config.OnEmptyFail:
+			incomplete[target] = true
+			failed = append(failed, target)
+		}
+	}
+
+	if len(failed) == 0 {
+		return incomplete
+	}
+
+	err := fmt.Errorf("%w: %s returned no records", ErrEmptyResponse, job.fetchConfig.URL)
+	if job.deadLetters == nil {
+		job.failure.fail("web", workerID, err)
+
+		return incomplete
+	}
+
+	job.deadLetter(workerID, failed, err, attempts, fetchedAt)
+
+	return incomplete
+}
@@ -0,0 +1,118 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/internal/web"
+	"github.com/sirupsen/logrus"
+)
+
+func TestEmptyPolicy(t *testing.T) {
+	t.Parallel()
+
+	weekends := config.EmptyWindows{{Weekdays: []string{"saturday", "sunday"}}}
+	saturday := time.Date(2022, 5, 14, 0, 0, 0, 0, time.UTC)
+	monday := saturday.Add(48 * time.Hour)
+
+	for _, tcase := range []struct {
+		name      string
+		req       *flattenedRequest
+		fetchedAt time.Time
+		want      string
+	}{
+		{name: "default", req: &flattenedRequest{}, fetchedAt: saturday, want: config.OnEmptyWrite},
+		{name: "policy", req: &flattenedRequest{onEmpty: config.OnEmptySkip}, fetchedAt: monday, want: config.OnEmptySkip},
+		{
+			name: "expected chunk",
+			req: &flattenedRequest{
+				onEmpty: config.OnEmptyFail, expectedEmpty: weekends, chunk: &[2]time.Time{saturday, monday},
+			},
+			fetchedAt: monday,
+			want:      config.OnEmptyWrite,
+		},
+		{
+			name: "unexpected chunk",
+			req: &flattenedRequest{
+				onEmpty: config.OnEmptyFail, expectedEmpty: weekends, chunk: &[2]time.Time{saturday, monday.Add(time.Hour)},
+			},
+			fetchedAt: saturday,
+			want:      config.OnEmptyFail,
+		},
+		{
+			name:      "expected fetch",
+			req:       &flattenedRequest{onEmpty: config.OnEmptyMarker, expectedEmpty: weekends},
+			fetchedAt: saturday.Add(time.Hour),
+			want:      config.OnEmptyWrite,
+		},
+	} {
+		if got := tcase.req.emptyPolicy(tcase.fetchedAt); got != tcase.want {
+			t.Fatalf("%s: expected policy %q, got %q", tcase.name, tcase.want, got)
+		}
+	}
+}
+
+func TestHandleEmpty(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "dead.jsonl")
+
+	letters, err := createDeadLetters(path, os.O_APPEND)
+	if err != nil {
+		t.Fatalf("failed to create dead letters: %v", err)
+	}
+
+	jobs := make(chan *repoJob, 10)
+	job := newTestControlJob(nil, "GET /a a", "GET /b b", "GET /c c", "GET /d d")
+	job.repoJobs = jobs
+	job.deadLetters = letters
+
+	targets := job.activeTargets()
+	for idx, policy := range []string{config.OnEmptyMarker, config.OnEmptySkip, config.OnEmptyFail, ""} {
+		targets[idx].table = string(rune('a' + idx))
+		targets[idx].onEmpty = policy
+	}
+
+	rsp := &web.FetchResponse{Request: new(http.Request), StatusCode: http.StatusOK}
+	incomplete := job.handleEmpty(0, targets, rsp, 1, time.Second, time.Now())
+
+	if len(incomplete) != 2 || !incomplete[targets[1]] || !incomplete[targets[2]] {
+		t.Fatalf("expected the skipped and failed targets to be incomplete, got %v", incomplete)
+	}
+
+	if len(jobs) != 1 {
+		t.Fatalf("expected a single marker to be written, got %d jobs", len(jobs))
+	}
+
+	if rj := <-jobs; rj.table != "a_empty" {
+		t.Fatalf("expected the marker to be written to the side table, got %q", rj.table)
+	}
+
+	if !targets[2].failed || targets[1].failed {
+		t.Fatalf("expected only the failed target to be dead-lettered")
+	}
+
+	letters.close(logrus.New())
+
+	read, err := readDeadLetters(path)
+	if err != nil {
+		t.Fatalf("failed to read dead letters: %v", err)
+	}
+
+	if len(read) != 1 || read[0].URL != targets[2].fetchConfig.URL.String() {
+		t.Fatalf("expected the failed target to be dead-lettered, got %+v", read)
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/alpstable/gidari/config"
+)
+
+// encryptedPrefix is the prefix of the values of encrypted columns, which is followed by the ID of their key and
+// the base64 of their nonce and ciphertext.
+const encryptedPrefix = "enc:v1:"
+
+// newCiphers returns the AES-GCM ciphers of the encryption keys of a configuration, keyed by key ID.
+func newCiphers(keys map[string]*config.EncryptionKey) (map[string]cipher.AEAD, error) {
+	ciphers := make(map[string]cipher.AEAD, len(keys))
+
+	for id, key := range keys {
+		data, err := key.Load()
+		if err != nil {
+			return nil, fmt.Errorf("unable to load encryption key %q: %w", id, err)
+		}
+
+		block, err := aes.NewCipher(data)
+		if err != nil {
+			return nil, fmt.Errorf("unable to create cipher for encryption key %q: %w", id, err)
+		}
+
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("unable to create cipher for encryption key %q: %w", id, err)
+		}
+
+		ciphers[id] = aead
+	}
+
+	return ciphers, nil
+}
+
+// encryptValue will encrypt the JSON of a value with a random nonce. Null values are left as they are.
+func encryptValue(aead cipher.AEAD, keyID string, val json.RawMessage) (json.RawMessage, error) {
+	if bytes.Equal(bytes.TrimSpace(val), []byte("null")) {
+		return val, nil
+	}
+
+	var plaintext bytes.Buffer
+	if err := json.Compact(&plaintext, val); err != nil {
+		return nil, fmt.Errorf("failed to compact value: %w", err)
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	sealed := aead.Seal(nonce, nonce, plaintext.Bytes(), nil)
+
+	out, err := json.Marshal(encryptedPrefix + keyID + ":" + base64.StdEncoding.EncodeToString(sealed))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode encrypted value: %w", err)
+	}
+
+	return out, nil
+}
+
+// encryptRecord will encrypt the columns of a record. Records that are not objects, and columns that they do not
+// have, are left as they are.
+func encryptRecord(encrypt *config.Encrypt, aead cipher.AEAD, data json.RawMessage) (json.RawMessage, error) {
+	var record map[string]json.RawMessage
+	if err := json.Unmarshal(data, &record); err != nil {
+		return data, nil //nolint:nilerr // only objects have columns to encrypt
+	}
+
+	for _, column := range encrypt.Columns {
+		val, ok := record[column]
+		if !ok {
+			continue
+		}
+
+		encrypted, err := encryptValue(aead, encrypt.KeyID, val)
+		if err != nil {
+			return nil, fmt.Errorf("%w %q with key %s: %v", ErrTransform, column, encrypt.KeyID, err)
+		}
+
+		record[column] = encrypted
+	}
+
+	out, err := json.Marshal(record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode record: %w", err)
+	}
+
+	return out, nil
+}
+
+// encryptData will encrypt the columns of the records of JSON data, an array of records or a single record, with
+// the cipher of the key of "encrypt", unless it is nil.
+func encryptData(encrypt *config.Encrypt, ciphers map[string]cipher.AEAD, data []byte) ([]byte, error) {
+	if encrypt == nil {
+		return data, nil
+	}
+
+	aead, ok := ciphers[encrypt.KeyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: encryption key %q is not configured", ErrTransform, encrypt.KeyID)
+	}
+
+	return mapRecords(data, func(record json.RawMessage) (json.RawMessage, error) {
+		return encryptRecord(encrypt, aead, record)
+	})
+}
@@ -0,0 +1,80 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/alpstable/gidari/config"
+)
+
+func TestEncryptData(t *testing.T) {
+	t.Parallel()
+
+	ciphers, err := newCiphers(map[string]*config.EncryptionKey{
+		"2024": {Key: base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))},
+	})
+	if err != nil {
+		t.Fatalf("failed to create ciphers: %v", err)
+	}
+
+	encrypt := &config.Encrypt{KeyID: "2024", Columns: []string{"ssn", "dob", "address"}}
+	data := `[{"id":1,"ssn":"123-45-6789","dob":null,"address":{"city":"Oslo"}},3]`
+
+	got, err := encryptData(encrypt, ciphers, []byte(data))
+	if err != nil {
+		t.Fatalf("failed to encrypt: %v", err)
+	}
+
+	var records []json.RawMessage
+	if err := json.Unmarshal(got, &records); err != nil || len(records) != 2 || string(records[1]) != "3" {
+		t.Fatalf("expected the records that are not objects to be left as they are, got %s", got)
+	}
+
+	var record map[string]json.RawMessage
+	if err := json.Unmarshal(records[0], &record); err != nil {
+		t.Fatalf("failed to decode record: %v", err)
+	}
+
+	if string(record["id"]) != "1" || string(record["dob"]) != "null" {
+		t.Fatalf("expected the other columns and nulls to be left as they are, got %s", got)
+	}
+
+	for column, want := range map[string]string{"ssn": `"123-45-6789"`, "address": `{"city":"Oslo"}`} {
+		var value string
+		if err := json.Unmarshal(record[column], &value); err != nil {
+			t.Fatalf("expected %s to be encrypted to a string, got %s", column, record[column])
+		}
+
+		prefix := encryptedPrefix + "2024:"
+		if !strings.HasPrefix(value, prefix) {
+			t.Fatalf("expected %s to have the key ID, got %q", column, value)
+		}
+
+		sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, prefix))
+		if err != nil {
+			t.Fatalf("failed to decode %s: %v", column, err)
+		}
+
+		aead := ciphers["2024"]
+
+		plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+		if err != nil || string(plaintext) != want {
+			t.Fatalf("expected %s to decrypt to %s, got %s (%v)", column, want, plaintext, err)
+		}
+	}
+
+	if _, err := encryptData(&config.Encrypt{KeyID: "2023"}, ciphers, []byte(data)); err == nil {
+		t.Fatalf("expected an unknown key to fail")
+	}
+}
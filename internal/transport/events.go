@@ -0,0 +1,49 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"github.com/alpstable/gidari/internal/events"
+)
+
+// requestEvent returns an event of the type "typ" for a flattened request, with its page and timeseries chunk.
+func requestEvent(typ string, req *flattenedRequest) events.Event {
+	event := events.Event{Type: typ, Request: req.requestKey, Table: req.table, Page: req.page}
+	if event.Page == 0 {
+		event.Page = 1
+	}
+
+	if req.chunk != nil {
+		start, end := req.chunk[0].UTC(), req.chunk[1].UTC()
+		event.ChunkStart, event.ChunkEnd = &start, &end
+	}
+
+	return event
+}
+
+// emitCommitted will emit an event for every request of a committed batch that was done, including the requests
+// that were coalesced into it, with the number of records of its response.
+func emitCommitted(stream *events.Stream, batch []*flattenedRequest) {
+	if stream == nil {
+		return
+	}
+
+	for _, req := range batch {
+		for _, target := range append([]*flattenedRequest{req}, req.coalesced...) {
+			// Dead-lettered requests are done, but their data was never fetched.
+			if !target.done || target.failed {
+				continue
+			}
+
+			event := requestEvent(events.ChunkCommitted, target)
+			event.Rows = int64(target.rows)
+
+			stream.Emit(event)
+		}
+	}
+}
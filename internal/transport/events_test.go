@@ -0,0 +1,62 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alpstable/gidari/internal/events"
+	"github.com/alpstable/gidari/tools"
+)
+
+func TestEmitCommitted(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	stream := events.New(&out, tools.NewFakeClock(start))
+
+	chunk := &[2]time.Time{start, start.Add(time.Hour)}
+	coalesced := &flattenedRequest{requestKey: "GET /candles copy", table: "copy", chunk: chunk, done: true, rows: 5}
+
+	emitCommitted(stream, []*flattenedRequest{
+		{
+			requestKey: "GET /candles candles",
+			table:      "candles",
+			chunk:      chunk,
+			done:       true,
+			rows:       5,
+			coalesced:  []*flattenedRequest{coalesced},
+		},
+		{requestKey: "GET /trades trades", table: "trades", page: 2, done: true},
+		{requestKey: "GET /orders orders", table: "orders", done: true, failed: true},
+		{requestKey: "GET /fills fills", table: "fills"},
+	})
+
+	got := out.String()
+	for _, want := range []string{
+		`"request":"GET /candles candles","table":"candles","page":1,"chunk_start":"2022-01-01T00:00:00Z",` +
+			`"chunk_end":"2022-01-01T01:00:00Z","rows":5}`,
+		`"request":"GET /candles copy","table":"copy","page":1,`,
+		`"request":"GET /trades trades","table":"trades","page":2}`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("expected an event with %s, got %s", want, got)
+		}
+	}
+
+	if lines := strings.Count(got, "\n"); lines != 3 {
+		t.Fatalf("expected only the requests that were fetched to be committed, got %s", got)
+	}
+}
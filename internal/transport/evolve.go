@@ -0,0 +1,67 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/internal/proto"
+	"github.com/alpstable/gidari/internal/repository"
+	"github.com/alpstable/gidari/tools"
+)
+
+// evolveTable will add the columns of the fields of an upsert request that its table does not have, with the types
+// inferred from every record of the request and the column types of the table settings or type mapping, so that the
+// fields are written rather than dropped. In strict mode the upsert fails instead.
+func evolveTable(ctx context.Context, workerID int, cfg *repoConfig, repo repository.Generic, write tableWrite,
+	req *proto.UpsertRequest,
+) error {
+	if write.evolve == nil || repo.IsNoSQL() {
+		return nil
+	}
+
+	start := time.Now()
+
+	records, err := proto.DecodeUpsertRequest(req)
+	if err != nil {
+		return fmt.Errorf("unable to decode records: %w", err)
+	}
+
+	if len(records) == 0 {
+		return nil
+	}
+
+	scheme := proto.SchemeFromStorageType(repo.Type())
+	create := &config.AutoCreate{ColumnTypes: write.evolve.ColumnTypes, TypeMapping: write.evolve.TypeMapping}
+
+	rsp, err := repo.AddColumns(ctx, &proto.AddColumnsRequest{
+		Table:   req.Table,
+		Columns: tableColumns(create, nil, proto.InferColumns(records), scheme),
+		Strict:  write.evolve.Strict,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to evolve table %q: %w", req.Table, err)
+	}
+
+	if len(rsp.Added) != 0 {
+		logInfo := tools.LogFormatter{
+			WorkerID:   workerID,
+			WorkerName: "repository",
+			Duration:   time.Since(start),
+			Msg: fmt.Sprintf("added columns %s to table %s.%s", strings.Join(rsp.Added, ", "), scheme,
+				req.Table),
+		}
+		cfg.logger.Infof(logInfo.String())
+	}
+
+	return nil
+}
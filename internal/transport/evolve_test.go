@@ -0,0 +1,80 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/internal/proto"
+	"github.com/alpstable/gidari/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+func TestEvolveTable(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	repo, err := repository.New(ctx, newTestExportStorage(t))
+	if err != nil {
+		t.Fatalf("failed to create repository: %v", err)
+	}
+
+	defer repo.Close()
+
+	cfg := &repoConfig{logger: logrus.New()}
+	req := &proto.UpsertRequest{
+		Table: "trades",
+		Data:  []byte(`[{"id": "5", "time": "2022-01-05T00:00:00Z", "price": 5.5, "fee": 0.1, "side": "buy"}]`),
+	}
+
+	// Fields that the table does not have are dropped unless the table is evolved.
+	if err := evolveTable(ctx, 1, cfg, repo, tableWrite{}, req); err != nil {
+		t.Fatalf("expected no error without schema evolution, got %v", err)
+	}
+
+	strict := tableWrite{evolve: &config.SchemaEvolution{Strict: true}}
+	if err := evolveTable(ctx, 1, cfg, repo, strict, req); !errors.Is(err, proto.ErrUnknownColumns) {
+		t.Fatalf("expected the unknown columns to fail a strict write, got %v", err)
+	}
+
+	evolve := tableWrite{evolve: &config.SchemaEvolution{ColumnTypes: map[string]string{"fee": "NUMERIC"}}}
+	if err := evolveTable(ctx, 1, cfg, repo, evolve, req); err != nil {
+		t.Fatalf("failed to evolve table: %v", err)
+	}
+
+	if _, err := repo.Upsert(ctx, req); err != nil {
+		t.Fatalf("failed to upsert: %v", err)
+	}
+
+	var records []map[string]interface{}
+
+	read := func(record map[string]interface{}) error {
+		records = append(records, record)
+
+		return nil
+	}
+
+	if err := repo.Read(ctx, &proto.ReadRecordsRequest{Table: "trades", OrderBy: "id"}, read); err != nil {
+		t.Fatalf("failed to read table: %v", err)
+	}
+
+	if got := records[len(records)-1]; got["fee"] != 0.1 || got["side"] != "buy" {
+		t.Fatalf("expected the new fields to be written, got %v", got)
+	}
+
+	// Once the columns exist, a strict write succeeds.
+	if err := evolveTable(ctx, 1, cfg, repo, strict, req); err != nil {
+		t.Fatalf("expected no unknown columns after evolving the table, got %v", err)
+	}
+}
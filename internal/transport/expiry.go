@@ -0,0 +1,65 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/alpstable/gidari/internal/proto"
+	"github.com/alpstable/gidari/internal/repository"
+	"github.com/alpstable/gidari/tools"
+)
+
+// configureExpiry will configure the table of an upsert request on a repository to expire its records, if the write
+// has a "ttl". Each table is only configured once per repository in a run, and storage that does not expire records
+// is warned about instead.
+func configureExpiry(ctx context.Context, workerID int, cfg *repoConfig, idx int, repo repository.Generic,
+	write tableWrite, req *proto.UpsertRequest,
+) error {
+	if write.ttl == nil || cfg.expiries.has(idx, req.Table) {
+		return nil
+	}
+
+	start := time.Now()
+
+	rsp, err := repo.ConfigureExpiry(ctx, &proto.ExpiryRequest{
+		Table:  req.Table,
+		Column: write.ttl.Column,
+		After:  write.ttl.Duration(),
+	})
+	if err != nil {
+		return fmt.Errorf("unable to configure expiry of %q: %w", req.Table, err)
+	}
+
+	cfg.expiries.add(idx, req.Table)
+
+	scheme := proto.SchemeFromStorageType(repo.Type())
+
+	switch {
+	case !rsp.Supported:
+		logWarn := tools.LogFormatter{
+			WorkerID:   workerID,
+			WorkerName: "repository",
+			Msg:        fmt.Sprintf("%s does not expire records, so the ttl of %s is not enforced", scheme, req.Table),
+		}
+		cfg.logger.Warn(logWarn.String())
+	case rsp.Changed:
+		logInfo := tools.LogFormatter{
+			WorkerID:   workerID,
+			WorkerName: "repository",
+			Duration:   time.Since(start),
+			Msg: fmt.Sprintf("configured %s.%s to expire records %s after %q", scheme, req.Table,
+				write.ttl.Duration(), write.ttl.Column),
+		}
+		cfg.logger.Infof(logInfo.String())
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/internal/proto"
+	"github.com/alpstable/gidari/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// expiryStorage records the expiries that it is configured with, and expires records if "supported".
+type expiryStorage struct {
+	repository.Generic
+
+	supported  bool
+	configured []*proto.ExpiryRequest
+}
+
+func (stg *expiryStorage) ConfigureExpiry(_ context.Context, req *proto.ExpiryRequest) (*proto.ExpiryResponse, error) {
+	stg.configured = append(stg.configured, req)
+
+	return &proto.ExpiryResponse{Supported: stg.supported, Changed: stg.supported}, nil
+}
+
+func (stg *expiryStorage) Type() uint8 { return proto.MongoType }
+
+func TestConfigureExpiry(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+
+	logger := logrus.New()
+	logger.SetOutput(&out)
+
+	cfg := &repoConfig{logger: logger, expiries: new(createdTables)}
+	write := tableWrite{ttl: &config.TTL{Column: "updated_at", After: "720h"}}
+	req := &proto.UpsertRequest{Table: "sessions"}
+
+	supported, unsupported := &expiryStorage{supported: true}, &expiryStorage{}
+
+	for run := 0; run < 2; run++ {
+		for idx, repo := range []*expiryStorage{supported, unsupported} {
+			if err := configureExpiry(context.Background(), 0, cfg, idx, repo, write, req); err != nil {
+				t.Fatalf("failed to configure expiry: %v", err)
+			}
+		}
+	}
+
+	for _, repo := range []*expiryStorage{supported, unsupported} {
+		if len(repo.configured) != 1 {
+			t.Fatalf("expected the expiry to be configured once per repository, got %d", len(repo.configured))
+		}
+
+		if got := repo.configured[0]; got.Table != "sessions" || got.Column != "updated_at" || got.After != 720*time.Hour {
+			t.Fatalf("expected the ttl of the table, got %+v", got)
+		}
+	}
+
+	logs := out.String()
+	if !strings.Contains(logs, "ttl of sessions is not enforced") || !strings.Contains(logs, "to expire records") {
+		t.Fatalf("expected to log the configured and the unenforced expiry, got %s", logs)
+	}
+
+	if err := configureExpiry(context.Background(), 0, cfg, 2, supported, tableWrite{}, req); err != nil ||
+		len(supported.configured) != 1 {
+		t.Fatalf("expected tables without a ttl not to be configured, got %v", err)
+	}
+}
@@ -0,0 +1,530 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/internal/parquet"
+	"github.com/alpstable/gidari/internal/proto"
+	"github.com/alpstable/gidari/internal/repository"
+	"github.com/alpstable/gidari/tools"
+)
+
+const (
+	// ExportFormatNDJSON writes one JSON object per line. This is the default.
+	ExportFormatNDJSON = "ndjson"
+
+	// ExportFormatCSV writes a header row of the sorted column names, followed by one row per record.
+	ExportFormatCSV = "csv"
+
+	// ExportFormatParquet writes a Parquet file, with nested objects flattened into columns and the column types
+	// inferred from the records, the same way the "parquet://" storage does.
+	ExportFormatParquet = "parquet"
+)
+
+// exportRowGroupSize is the number of records in each row group of a Parquet export.
+const exportRowGroupSize = 10000
+
+// ErrInvalidExport is returned when the export options are invalid, or when the data cannot be exported with them.
+var ErrInvalidExport = fmt.Errorf("invalid export")
+
+// exportTimeLayouts are the layouts that string values of the time column are parsed with.
+var exportTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+// ExportOptions are the settings for exporting a table from storage back out into files.
+type ExportOptions struct {
+	// Table is the table, or collection, to export.
+	Table string
+
+	// Format is the file format, either "ndjson", "csv" or "parquet".
+	Format string
+
+	// Dir is the directory that the files are written to.
+	Dir string
+
+	// ConnectionString is the storage to export from. The default is the first of the "connectionStrings".
+	ConnectionString string
+
+	// TimeColumn is the column that "Since", "Until" and "Slice" apply to. Its values may be timestamps, date-time
+	// strings or Unix seconds.
+	TimeColumn string
+
+	// Since and Until bound the exported records to [Since, Until). A zero value is unbounded.
+	Since time.Time
+	Until time.Time
+
+	// Slice splits the export into one file per slice of time, starting at "Since". Slices whose file already
+	// exists are skipped, so an export that is interrupted can be resumed by running it again. If "Until" is not
+	// set, only the slices that have ended are exported.
+	Slice time.Duration
+}
+
+func (opts *ExportOptions) validate() error {
+	if opts.Table == "" {
+		return fmt.Errorf("%w: a table is required", ErrInvalidExport)
+	}
+
+	switch opts.Format {
+	case ExportFormatNDJSON, ExportFormatCSV, ExportFormatParquet:
+	default:
+		return fmt.Errorf("%w: format %q must be %q, %q or %q", ErrInvalidExport, opts.Format, ExportFormatNDJSON,
+			ExportFormatCSV, ExportFormatParquet)
+	}
+
+	bounded := !opts.Since.IsZero() || !opts.Until.IsZero() || opts.Slice != 0
+	if bounded && opts.TimeColumn == "" {
+		return fmt.Errorf("%w: since, until and slice require a time column", ErrInvalidExport)
+	}
+
+	if opts.Slice < 0 || (opts.Slice > 0 && opts.Since.IsZero()) {
+		return fmt.Errorf("%w: slice must be positive and requires since", ErrInvalidExport)
+	}
+
+	if !opts.Until.IsZero() && !opts.Until.After(opts.Since) {
+		return fmt.Errorf("%w: until must be after since", ErrInvalidExport)
+	}
+
+	return nil
+}
+
+// sliceStart returns the start of the slice that "t" falls in.
+func (opts *ExportOptions) sliceStart(t time.Time) time.Time {
+	if opts.Slice == 0 {
+		return opts.Since
+	}
+
+	return opts.Since.Add(t.Sub(opts.Since) / opts.Slice * opts.Slice)
+}
+
+// filename returns the name of the file that the slice starting at "start" is written to.
+func (opts *ExportOptions) filename(start time.Time) string {
+	name := opts.Table
+	if opts.Slice != 0 {
+		name += "-" + start.UTC().Format("20060102T150405Z")
+	}
+
+	return filepath.Join(opts.Dir, name+"."+opts.Format)
+}
+
+// parseExportTime will parse a value of the time column.
+func parseExportTime(val interface{}) (time.Time, error) {
+	switch val := val.(type) {
+	case time.Time:
+		return val, nil
+	case interface{ Time() time.Time }:
+		return val.Time(), nil
+	case int64:
+		return time.Unix(val, 0), nil
+	case int32:
+		return time.Unix(int64(val), 0), nil
+	case float64:
+		return time.Unix(0, int64(val*float64(time.Second))), nil
+	case string:
+		for _, layout := range exportTimeLayouts {
+			if t, err := time.Parse(layout, val); err == nil {
+				return t, nil
+			}
+		}
+
+		if secs, err := strconv.ParseFloat(val, 64); err == nil {
+			return parseExportTime(secs)
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("%w: unable to parse %v as a time", ErrInvalidExport, val)
+}
+
+// exportWriter encodes records into a file of one of the export formats.
+type exportWriter interface {
+	write(record map[string]interface{}) error
+	flush() error
+}
+
+type ndjsonWriter struct{ w *bufio.Writer }
+
+func (ndjson *ndjsonWriter) write(record map[string]interface{}) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("unable to encode record: %w", err)
+	}
+
+	if _, err := ndjson.w.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("unable to write record: %w", err)
+	}
+
+	return nil
+}
+
+func (ndjson *ndjsonWriter) flush() error { return ndjson.w.Flush() }
+
+// csvWriter takes its columns from the first record. Nested values are written as JSON.
+type csvWriter struct {
+	w       *csv.Writer
+	columns []string
+}
+
+func (cw *csvWriter) write(record map[string]interface{}) error {
+	if cw.columns == nil {
+		for column := range record {
+			cw.columns = append(cw.columns, column)
+		}
+
+		sort.Strings(cw.columns)
+
+		if err := cw.w.Write(cw.columns); err != nil {
+			return fmt.Errorf("unable to write header: %w", err)
+		}
+	}
+
+	for column := range record {
+		if idx := sort.SearchStrings(cw.columns, column); idx == len(cw.columns) || cw.columns[idx] != column {
+			return fmt.Errorf("%w: column %q is not in every record, export the table as %q", ErrInvalidExport,
+				column, ExportFormatNDJSON)
+		}
+	}
+
+	row := make([]string, len(cw.columns))
+
+	for idx, column := range cw.columns {
+		cell, err := csvCell(record[column])
+		if err != nil {
+			return err
+		}
+
+		row[idx] = cell
+	}
+
+	if err := cw.w.Write(row); err != nil {
+		return fmt.Errorf("unable to write record: %w", err)
+	}
+
+	return nil
+}
+
+func (cw *csvWriter) flush() error {
+	cw.w.Flush()
+
+	return cw.w.Error()
+}
+
+func csvCell(val interface{}) (string, error) {
+	switch val := val.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return val, nil
+	case time.Time:
+		return val.UTC().Format(time.RFC3339Nano), nil
+	}
+
+	data, err := json.Marshal(val)
+	if err != nil {
+		return "", fmt.Errorf("unable to encode value: %w", err)
+	}
+
+	return string(data), nil
+}
+
+// parquetWriter buffers the records of a slice in its file as newline-delimited JSON, since the schema of a Parquet
+// file is inferred from all of its records, and replaces them with the Parquet file once the slice is complete.
+type parquetWriter struct {
+	ndjsonWriter
+	file *os.File
+}
+
+func (pw *parquetWriter) flush() error {
+	if err := pw.ndjsonWriter.flush(); err != nil {
+		return err
+	}
+
+	encoded, err := os.CreateTemp(filepath.Dir(pw.file.Name()), filepath.Base(pw.file.Name())+".*.parquet")
+	if err != nil {
+		return fmt.Errorf("unable to create export file: %w", err)
+	}
+
+	encoded.Close()
+
+	defer os.Remove(encoded.Name())
+
+	written, err := parquet.WriteFile(encoded.Name(), exportRowGroupSize, exportSource(pw.file.Name()))
+	if err != nil {
+		return fmt.Errorf("unable to encode records: %w", err)
+	}
+
+	if !written {
+		return fmt.Errorf("%w: the records have no fields to write as %q", ErrInvalidExport, ExportFormatParquet)
+	}
+
+	src, err := os.Open(encoded.Name())
+	if err != nil {
+		return fmt.Errorf("unable to read export file: %w", err)
+	}
+
+	defer src.Close()
+
+	if err := pw.file.Truncate(0); err != nil {
+		return fmt.Errorf("unable to write export file: %w", err)
+	}
+
+	if _, err := pw.file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("unable to write export file: %w", err)
+	}
+
+	if _, err := io.Copy(pw.file, src); err != nil {
+		return fmt.Errorf("unable to write export file: %w", err)
+	}
+
+	return nil
+}
+
+// exportSource returns the records that a "parquetWriter" buffered in a file. Numbers are decoded as "json.Number"
+// so that integers keep their type.
+func exportSource(path string) parquet.RecordSource {
+	return func(fn func(record map[string]interface{}) error) error {
+		file, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("unable to read export file: %w", err)
+		}
+
+		defer file.Close()
+
+		decoder := json.NewDecoder(bufio.NewReader(file))
+		decoder.UseNumber()
+
+		for {
+			var record map[string]interface{}
+			if err := decoder.Decode(&record); errors.Is(err, io.EOF) {
+				return nil
+			} else if err != nil {
+				return fmt.Errorf("%w: %v", proto.ErrFailedToUnmarshalJSON, err)
+			}
+
+			if err := fn(record); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// exportSlice is the file of the slice that records are currently written to. Records are written to a temporary
+// file, which is renamed once the slice is complete so that a file that exists is always a complete slice.
+type exportSlice struct {
+	start time.Time
+	path  string
+
+	// skip is true if the file of the slice already exists.
+	skip bool
+
+	file   *os.File
+	writer exportWriter
+}
+
+// exporter writes the records read from storage into their slices. Records must be read in order of the time
+// column, so that only one slice is open at a time.
+type exporter struct {
+	opts *ExportOptions
+
+	current *exportSlice
+
+	records int
+	written []string
+	skipped int
+}
+
+func (exp *exporter) write(record map[string]interface{}) error {
+	var start time.Time
+
+	if exp.opts.TimeColumn != "" {
+		t, err := parseExportTime(record[exp.opts.TimeColumn])
+		if err != nil {
+			return fmt.Errorf("%s: %w", exp.opts.TimeColumn, err)
+		}
+
+		if t.Before(exp.opts.Since) || (!exp.opts.Until.IsZero() && !t.Before(exp.opts.Until)) {
+			return nil
+		}
+
+		start = exp.opts.sliceStart(t)
+	}
+
+	if exp.current != nil && start.Before(exp.current.start) {
+		return fmt.Errorf("%w: records are not ordered by %q", ErrInvalidExport, exp.opts.TimeColumn)
+	}
+
+	if exp.current == nil || !start.Equal(exp.current.start) {
+		if err := exp.commit(); err != nil {
+			return err
+		}
+
+		if err := exp.open(start); err != nil {
+			return err
+		}
+	}
+
+	if exp.current.skip {
+		return nil
+	}
+
+	exp.records++
+
+	return exp.current.writer.write(record)
+}
+
+func (exp *exporter) open(start time.Time) error {
+	slice := &exportSlice{start: start, path: exp.opts.filename(start)}
+	exp.current = slice
+
+	// Only sliced exports are resumed; a single file is always rewritten.
+	if _, err := os.Stat(slice.path); err == nil && exp.opts.Slice != 0 {
+		slice.skip = true
+		exp.skipped++
+
+		return nil
+	}
+
+	file, err := os.CreateTemp(exp.opts.Dir, filepath.Base(slice.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("unable to create export file: %w", err)
+	}
+
+	slice.file = file
+
+	switch exp.opts.Format {
+	case ExportFormatCSV:
+		slice.writer = &csvWriter{w: csv.NewWriter(file)}
+	case ExportFormatParquet:
+		slice.writer = &parquetWriter{ndjsonWriter: ndjsonWriter{w: bufio.NewWriter(file)}, file: file}
+	default:
+		slice.writer = &ndjsonWriter{w: bufio.NewWriter(file)}
+	}
+
+	return nil
+}
+
+// commit will complete the current slice, renaming its file into place.
+func (exp *exporter) commit() error {
+	slice := exp.current
+	if slice == nil || slice.skip {
+		return nil
+	}
+
+	exp.current = nil
+
+	if err := slice.writer.flush(); err != nil {
+		slice.file.Close()
+		os.Remove(slice.file.Name())
+
+		return fmt.Errorf("unable to write export file: %w", err)
+	}
+
+	if err := slice.file.Close(); err != nil {
+		os.Remove(slice.file.Name())
+
+		return fmt.Errorf("unable to close export file: %w", err)
+	}
+
+	if err := os.Rename(slice.file.Name(), slice.path); err != nil {
+		return fmt.Errorf("unable to rename export file: %w", err)
+	}
+
+	exp.written = append(exp.written, slice.path)
+
+	return nil
+}
+
+// abort will remove the file of the current slice, which is incomplete.
+func (exp *exporter) abort() {
+	if slice := exp.current; slice != nil && !slice.skip {
+		slice.file.Close()
+		os.Remove(slice.file.Name())
+	}
+
+	exp.current = nil
+}
+
+// Export will read the records of a table back out of storage into files. See "ExportOptions" for how the records
+// are sliced into files.
+func Export(ctx context.Context, cfg *config.Config, opts ExportOptions) error {
+	start := time.Now()
+
+	if opts.Format == "" {
+		opts.Format = ExportFormatNDJSON
+	}
+
+	if opts.ConnectionString == "" && len(cfg.ConnectionStrings) > 0 {
+		opts.ConnectionString = cfg.ConnectionStrings[0]
+	}
+
+	if opts.ConnectionString == "" {
+		return fmt.Errorf("%w: no connection string to export from", ErrInvalidExport)
+	}
+
+	// Only whole slices are exported, so that a slice is never written before all of its data has been ingested.
+	if opts.Slice != 0 && opts.Until.IsZero() && !opts.Since.IsZero() {
+		opts.Until = opts.sliceStart(tools.ClockOrReal(cfg.Clock).Now())
+	}
+
+	if err := opts.validate(); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return fmt.Errorf("unable to create export directory: %w", err)
+	}
+
+	stg, err := repository.NewStorage(ctx, opts.ConnectionString)
+	if err != nil {
+		return fmt.Errorf("failed to create repository: %w", err)
+	}
+
+	defer stg.Close()
+
+	exp := &exporter{opts: &opts}
+
+	err = stg.Read(ctx, &proto.ReadRecordsRequest{Table: opts.Table, OrderBy: opts.TimeColumn}, exp.write)
+	if err == nil {
+		err = exp.commit()
+	}
+
+	if err != nil {
+		exp.abort()
+
+		if errors.Is(ctx.Err(), context.Canceled) {
+			return fmt.Errorf("%w: %d files were exported", ErrInterrupted, len(exp.written))
+		}
+
+		return fmt.Errorf("unable to export table %q: %w", opts.Table, err)
+	}
+
+	logInfo := tools.LogFormatter{
+		Duration: time.Since(start),
+		Msg: fmt.Sprintf("exported %d records from %q into %d files, skipped %d existing files", exp.records,
+			opts.Table, len(exp.written), exp.skipped),
+	}
+	cfg.Logger.Info(logInfo.String())
+
+	return nil
+}
@@ -0,0 +1,238 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/internal/parquet"
+	"github.com/alpstable/gidari/internal/sqlite"
+	"github.com/sirupsen/logrus"
+)
+
+func newTestExportStorage(t *testing.T) string {
+	t.Helper()
+
+	dns := "sqlite://" + filepath.Join(t.TempDir(), "gidari.db")
+
+	lite, err := sqlite.New(context.Background(), dns)
+	if err != nil {
+		t.Fatalf("failed to open sqlite: %v", err)
+	}
+
+	defer lite.Close()
+
+	_, err = lite.DB.Exec(`
+CREATE TABLE trades (id TEXT NOT NULL, time TEXT NOT NULL, price REAL, PRIMARY KEY (id));
+INSERT INTO trades VALUES
+	('1', '2022-01-01T01:00:00Z', 1.5),
+	('2', '2022-01-01T23:00:00Z', 2.5),
+	('3', '2022-01-02T12:00:00Z', 3.5),
+	('4', '2022-01-04T00:00:00Z', 4.5);
+`)
+	if err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+
+	return dns
+}
+
+func TestExportOptionsValidate(t *testing.T) {
+	t.Parallel()
+
+	since := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, tcase := range []struct {
+		name string
+		opts ExportOptions
+		err  error
+	}{
+		{name: "table", opts: ExportOptions{Table: "trades", Format: ExportFormatNDJSON}},
+		{name: "no table", opts: ExportOptions{Format: ExportFormatCSV}, err: ErrInvalidExport},
+		{name: "format", opts: ExportOptions{Table: "trades", Format: "xml"}, err: ErrInvalidExport},
+		{
+			name: "since without time column",
+			opts: ExportOptions{Table: "trades", Format: ExportFormatCSV, Since: since},
+			err:  ErrInvalidExport,
+		},
+		{
+			name: "slice without since",
+			opts: ExportOptions{Table: "trades", Format: ExportFormatCSV, TimeColumn: "time", Slice: time.Hour},
+			err:  ErrInvalidExport,
+		},
+		{
+			name: "until before since",
+			opts: ExportOptions{
+				Table: "trades", Format: ExportFormatCSV, TimeColumn: "time", Since: since,
+				Until: since.Add(-time.Hour),
+			},
+			err: ErrInvalidExport,
+		},
+	} {
+		tcase := tcase
+
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			if err := tcase.opts.validate(); !errors.Is(err, tcase.err) {
+				t.Fatalf("expected error %v, got %v", tcase.err, err)
+			}
+		})
+	}
+}
+
+func TestParseExportTime(t *testing.T) {
+	t.Parallel()
+
+	want := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	for _, val := range []interface{}{
+		want, "2022-01-02T03:04:05Z", "2022-01-02 03:04:05", want.Unix(), float64(want.Unix()), "1641092645",
+	} {
+		got, err := parseExportTime(val)
+		if err != nil {
+			t.Fatalf("failed to parse %v: %v", val, err)
+		}
+
+		if !got.Equal(want) {
+			t.Fatalf("expected %v to parse as %v, got %v", val, want, got)
+		}
+	}
+
+	if _, err := parseExportTime(true); !errors.Is(err, ErrInvalidExport) {
+		t.Fatalf("expected an error for a boolean, got %v", err)
+	}
+}
+
+func TestExport(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	cfg := &config.Config{ConnectionStrings: []string{newTestExportStorage(t)}, Logger: logrus.New()}
+
+	opts := ExportOptions{
+		Table:      "trades",
+		Format:     ExportFormatNDJSON,
+		Dir:        dir,
+		TimeColumn: "time",
+		Since:      time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+		Until:      time.Date(2022, 1, 3, 0, 0, 0, 0, time.UTC),
+		Slice:      24 * time.Hour,
+	}
+
+	if err := Export(ctx, cfg, opts); err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+
+	first, err := os.ReadFile(filepath.Join(dir, "trades-20220101T000000Z.ndjson"))
+	if err != nil {
+		t.Fatalf("failed to read the first slice: %v", err)
+	}
+
+	if lines := strings.Count(string(first), "\n"); lines != 2 {
+		t.Fatalf("expected 2 records in the first slice, got %d: %s", lines, first)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to read the export directory: %v", err)
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("expected one file per slice with records before until, got %d", len(entries))
+	}
+
+	// Slices that have been exported are skipped by the next export.
+	second := filepath.Join(dir, "trades-20220102T000000Z.ndjson")
+	if err := os.WriteFile(second, []byte("kept\n"), 0o600); err != nil {
+		t.Fatalf("failed to overwrite the second slice: %v", err)
+	}
+
+	if err := Export(ctx, cfg, opts); err != nil {
+		t.Fatalf("failed to resume the export: %v", err)
+	}
+
+	if data, _ := os.ReadFile(second); string(data) != "kept\n" {
+		t.Fatalf("expected the exported slice to be skipped, got %q", data)
+	}
+}
+
+func TestExportCSV(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	cfg := &config.Config{ConnectionStrings: []string{newTestExportStorage(t)}, Logger: logrus.New()}
+
+	err := Export(context.Background(), cfg, ExportOptions{Table: "trades", Format: ExportFormatCSV, Dir: dir})
+	if err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "trades.csv"))
+	if err != nil {
+		t.Fatalf("failed to read the export: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 5 || lines[0] != "id,price,time" || lines[1] != "1,1.5,2022-01-01T01:00:00Z" {
+		t.Fatalf("unexpected csv export:\n%s", data)
+	}
+}
+
+func TestExportParquet(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	cfg := &config.Config{ConnectionStrings: []string{newTestExportStorage(t)}, Logger: logrus.New()}
+
+	err := Export(ctx, cfg, ExportOptions{Table: "trades", Format: ExportFormatParquet, Dir: dir})
+	if err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+
+	var records []map[string]interface{}
+
+	err = parquet.ReadFile(ctx, filepath.Join(dir, "trades.parquet"), func(record map[string]interface{}) error {
+		records = append(records, record)
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to read the export: %v", err)
+	}
+
+	if len(records) != 4 {
+		t.Fatalf("expected 4 records, got %d", len(records))
+	}
+
+	want := map[string]interface{}{"id": "1", "price": 1.5, "time": "2022-01-01T01:00:00Z"}
+	if !reflect.DeepEqual(records[0], want) {
+		t.Fatalf("expected the first record to be %v, got %v", want, records[0])
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to read the export directory: %v", err)
+	}
+
+	if len(entries) != 1 {
+		t.Fatalf("expected only the exported file, got %d files", len(entries))
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"context"
+	"sync"
+
+	"github.com/alpstable/gidari/tools"
+	"github.com/sirupsen/logrus"
+)
+
+// batchFailure records the first error of a batch that a worker cannot continue from, e.g. a request that fails
+// every retry without a "deadLetter" file. The batch is canceled so that no new requests are started, and it is not
+// committed, so the run returns the error rather than exiting the process.
+type batchFailure struct {
+	cancel context.CancelFunc
+	logger *logrus.Logger
+
+	mu    sync.Mutex
+	first error
+}
+
+func newBatchFailure(cancel context.CancelFunc, logger *logrus.Logger) *batchFailure {
+	return &batchFailure{cancel: cancel, logger: logger}
+}
+
+// fail will log the error of a worker and cancel the batch. Only the first error is returned by the batch.
+func (failure *batchFailure) fail(workerName string, workerID int, err error) {
+	logErr := tools.LogFormatter{WorkerID: workerID, WorkerName: workerName, Msg: err.Error()}
+	failure.logger.Error(logErr.String())
+
+	failure.mu.Lock()
+	defer failure.mu.Unlock()
+
+	if failure.first == nil {
+		failure.first = err
+	}
+
+	failure.cancel()
+}
+
+// err returns the first error of the batch, or nil if no worker failed.
+func (failure *batchFailure) err() error {
+	failure.mu.Lock()
+	defer failure.mu.Unlock()
+
+	return failure.first
+}
@@ -0,0 +1,47 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestBatchFailure(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	failure := newBatchFailure(cancel, logger)
+	if err := failure.err(); err != nil {
+		t.Fatalf("expected no error before a worker fails, got %v", err)
+	}
+
+	first := fmt.Errorf("request failed")
+	failure.fail("web", 1, first)
+	failure.fail("repository", 2, fmt.Errorf("error reading spilled data"))
+
+	if err := failure.err(); !errors.Is(err, first) {
+		t.Fatalf("expected the first error %v, got %v", first, err)
+	}
+
+	if ctx.Err() == nil {
+		t.Fatalf("expected the batch to be canceled")
+	}
+}
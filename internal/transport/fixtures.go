@@ -0,0 +1,361 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/internal/web"
+	"github.com/alpstable/gidari/tools"
+)
+
+// scrubbed is the value that secrets and personal data are replaced with in a fixture.
+const scrubbed = "REDACTED"
+
+// ErrInvalidFixtures is returned when the fixtures options are invalid.
+var ErrInvalidFixtures = fmt.Errorf("invalid fixtures")
+
+// FixturesOptions are the settings for capturing sample responses of the configured requests as test fixtures.
+type FixturesOptions struct {
+	// Requests are the tables or endpoints of the requests to capture, e.g. "candles". The default is every
+	// request.
+	Requests []string
+
+	// Dir is the directory that the fixtures are written to.
+	Dir string
+}
+
+func (opts *FixturesOptions) validate(cfg *config.Config) error {
+	if opts.Dir == "" {
+		return fmt.Errorf("%w: a directory is required", ErrInvalidFixtures)
+	}
+
+	for _, name := range opts.Requests {
+		found := false
+
+		for _, req := range cfg.Requests {
+			found = found || fixtureMatches(req, name)
+		}
+
+		if !found {
+			return fmt.Errorf("%w: no request writes to or requests %q", ErrInvalidFixtures, name)
+		}
+	}
+
+	return nil
+}
+
+// fixtureMatches returns true if a request is selected by "name": its table, or its endpoint without the query.
+func fixtureMatches(req *config.Request, name string) bool {
+	endpoint, _, _ := strings.Cut(req.Endpoint, "?")
+
+	return req.Table == name || endpoint == name
+}
+
+// fixture is a sample response of a request, with the request that it was fetched with. The body of a JSON response
+// is kept as JSON, and any other body as text.
+type fixture struct {
+	Method      string              `json:"method"`
+	Path        string              `json:"path"`
+	Query       map[string][]string `json:"query,omitempty"`
+	RequestBody json.RawMessage     `json:"requestBody,omitempty"`
+	Status      int                 `json:"status"`
+	Body        json.RawMessage     `json:"body,omitempty"`
+	Text        string              `json:"text,omitempty"`
+}
+
+// scrubber replaces the secrets and personal data of a fixture.
+type scrubber struct {
+	secrets []string
+	keys    map[string]bool
+	params  map[string]bool
+}
+
+func newScrubber(cfg *config.Config) *scrubber {
+	scrub := &scrubber{
+		secrets: cfg.Authentication.Secrets(),
+		keys:    make(map[string]bool),
+		params:  make(map[string]bool),
+	}
+
+	if cfg.Fixtures != nil {
+		for _, key := range cfg.Fixtures.Scrub {
+			scrub.keys[key] = true
+		}
+
+		for _, param := range cfg.Fixtures.ScrubQuery {
+			scrub.params[param] = true
+		}
+	}
+
+	return scrub
+}
+
+// text returns a string with every secret replaced.
+func (scrub *scrubber) text(str string) string {
+	for _, secret := range scrub.secrets {
+		str = strings.ReplaceAll(str, secret, scrubbed)
+	}
+
+	return str
+}
+
+// value returns a JSON value with the values of the scrubbed keys replaced, at any depth, and every secret replaced
+// in its strings. Null values are left as they are.
+func (scrub *scrubber) value(val interface{}) interface{} {
+	switch val := val.(type) {
+	case string:
+		return scrub.text(val)
+	case map[string]interface{}:
+		for key, item := range val {
+			if scrub.keys[key] && item != nil {
+				val[key] = scrubbed
+
+				continue
+			}
+
+			val[key] = scrub.value(item)
+		}
+	case []interface{}:
+		for idx, item := range val {
+			val[idx] = scrub.value(item)
+		}
+	}
+
+	return val
+}
+
+// json returns JSON data with its values scrubbed.
+func (scrub *scrubber) json(data []byte) (json.RawMessage, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var val interface{}
+	if err := dec.Decode(&val); err != nil {
+		return nil, fmt.Errorf("failed to decode fixture: %w", err)
+	}
+
+	var buf bytes.Buffer
+
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+
+	if err := enc.Encode(scrub.value(val)); err != nil {
+		return nil, fmt.Errorf("failed to encode fixture: %w", err)
+	}
+
+	return bytes.TrimSpace(buf.Bytes()), nil
+}
+
+// fixture returns the scrubbed fixture of a response.
+func (scrub *scrubber) fixture(fetchConfig *web.FetchConfig, status int, body []byte) (*fixture, error) {
+	fix := &fixture{
+		Method: fetchConfig.Method,
+		Path:   scrub.text(fetchConfig.URL.Path),
+		Status: status,
+	}
+
+	if query := fetchConfig.URL.Query(); len(query) > 0 {
+		fix.Query = make(map[string][]string, len(query))
+
+		for param, values := range query {
+			for idx, val := range values {
+				if scrub.params[param] {
+					values[idx] = scrubbed
+				} else {
+					values[idx] = scrub.text(val)
+				}
+			}
+
+			fix.Query[param] = values
+		}
+	}
+
+	var err error
+
+	if len(fetchConfig.Body) > 0 {
+		if fix.RequestBody, err = scrub.json(fetchConfig.Body); err != nil {
+			return nil, err
+		}
+	}
+
+	if !json.Valid(body) {
+		fix.Text = scrub.text(string(body))
+
+		return fix, nil
+	}
+
+	if fix.Body, err = scrub.json(body); err != nil {
+		return nil, err
+	}
+
+	return fix, nil
+}
+
+// fixtureFilename returns the file of the fixture of a table, numbering the fixtures of tables that are written to
+// by several requests, e.g. "candles.json" and "candles-2.json".
+func fixtureFilename(used map[string]int, table string) string {
+	used[table]++
+
+	name := strings.NewReplacer("/", "_", "\\", "_").Replace(table)
+	if used[table] > 1 {
+		name = fmt.Sprintf("%s-%d", name, used[table])
+	}
+
+	return name + ".json"
+}
+
+// writeFixture will write a fixture to a file atomically, so that an interrupted capture never leaves a partial
+// fixture. The fixture is indented so that it can be read and edited.
+func writeFixture(path string, fix *fixture) error {
+	var data bytes.Buffer
+
+	enc := json.NewEncoder(&data)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+
+	if err := enc.Encode(fix); err != nil {
+		return fmt.Errorf("failed to encode fixture: %w", err)
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create fixture: %w", err)
+	}
+
+	if _, err := file.Write(data.Bytes()); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+
+		return fmt.Errorf("failed to write fixture: %w", err)
+	}
+
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+
+		return fmt.Errorf("failed to write fixture: %w", err)
+	}
+
+	if err := os.Rename(file.Name(), path); err != nil {
+		os.Remove(file.Name())
+
+		return fmt.Errorf("failed to write fixture: %w", err)
+	}
+
+	return nil
+}
+
+// captureFixture will fetch the first page of a request, or the first chunk of a timeseries request, and return its
+// scrubbed fixture.
+func captureFixture(ctx context.Context, req *flattenedRequest, scrub *scrubber) (*fixture, error) {
+	rsp, err := web.Fetch(ctx, req.fetchConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rsp.Body.Close()
+
+	body, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	return scrub.fixture(req.fetchConfig, rsp.StatusCode, body)
+}
+
+// Fixtures will capture a sample response of each of the selected requests into a file of the directory of the
+// options, scrubbed of the credentials of the configuration and of the personal data of its "fixtures", for tests
+// that are run against a mock server. Nothing is written to storage.
+func Fixtures(ctx context.Context, cfg *config.Config, opts FixturesOptions) error {
+	start := time.Now()
+
+	if err := opts.validate(cfg); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create fixtures directory: %w", err)
+	}
+
+	client, err := connect(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to connect to web API: %w", err)
+	}
+
+	// The templates of the requests read the variables of the state store as a run would, but the store is never
+	// saved.
+	store, err := openState(cfg)
+	if err != nil {
+		return err
+	}
+
+	scrub := newScrubber(cfg)
+	used := make(map[string]int)
+	count := 0
+
+	for _, req := range cfg.Requests {
+		selected := len(opts.Requests) == 0
+		for _, name := range opts.Requests {
+			selected = selected || fixtureMatches(req, name)
+		}
+
+		if !selected {
+			continue
+		}
+
+		if err := req.RenderQuery(store); err != nil {
+			return fmt.Errorf("failed to render request query: %w", err)
+		}
+
+		flatReqs, err := flattenRequestTimeseries(req, *cfg.URL, client, store)
+		if err != nil {
+			return err
+		}
+
+		if len(flatReqs) == 0 {
+			continue
+		}
+
+		fix, err := captureFixture(ctx, flatReqs[0], scrub)
+		if err != nil {
+			if errors.Is(ctx.Err(), context.Canceled) {
+				return ErrInterrupted
+			}
+
+			return fmt.Errorf("unable to capture %s: %w", docsSource(req), err)
+		}
+
+		path := filepath.Join(opts.Dir, fixtureFilename(used, req.Table))
+		if err := writeFixture(path, fix); err != nil {
+			return err
+		}
+
+		count++
+
+		logInfo := tools.LogFormatter{Msg: fmt.Sprintf("captured %s to %s", docsSource(req), path)}
+		cfg.Logger.Info(logInfo.String())
+	}
+
+	logInfo := tools.LogFormatter{
+		Duration: time.Since(start),
+		Msg:      fmt.Sprintf("captured %d fixtures", count),
+	}
+	cfg.Logger.Info(logInfo.String())
+
+	return nil
+}
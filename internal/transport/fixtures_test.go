@@ -0,0 +1,134 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+)
+
+func TestFixtures(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/users":
+			io.WriteString(w, `[{"id":1,"email":"a@example.com","profile":{"email":"b@example.com","phone":null},`+
+				`"token":"sk_live_123","note":"key sk_live_123 & more"}]`)
+		case "/status":
+			io.WriteString(w, "ok sk_live_123")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	rurl, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("failed to parse url: %v", err)
+	}
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	newRequest := func(endpoint, table string) *config.Request {
+		return &config.Request{
+			Endpoint:    endpoint,
+			Method:      http.MethodGet,
+			Table:       table,
+			Query:       map[string]string{"apikey": "sk_live_123", "account": "42", "limit": "1"},
+			RateLimiter: rate.NewLimiter(rate.Inf, 1),
+		}
+	}
+
+	cfg := &config.Config{
+		URL:            rurl,
+		RawURL:         server.URL,
+		Logger:         logger,
+		Authentication: config.Authentication{APIKey: &config.APIKey{Key: "sk_live_123"}},
+		Fixtures:       &config.FixturesConfig{Scrub: []string{"email", "phone"}, ScrubQuery: []string{"account"}},
+		Requests: []*config.Request{
+			newRequest("/users", "users"),
+			newRequest("/users", "users"),
+			newRequest("/status", "status"),
+		},
+	}
+
+	dir := t.TempDir()
+
+	err = Fixtures(context.Background(), cfg, FixturesOptions{Dir: dir, Requests: []string{"nope"}})
+	if !errors.Is(err, ErrInvalidFixtures) {
+		t.Fatalf("expected %v for an unknown request, got %v", ErrInvalidFixtures, err)
+	}
+
+	if err := Fixtures(context.Background(), cfg, FixturesOptions{Dir: dir, Requests: []string{"users"}}); err != nil {
+		t.Fatalf("failed to capture fixtures: %v", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		t.Fatalf("failed to list fixtures: %v", err)
+	}
+
+	want := []string{filepath.Join(dir, "users-2.json"), filepath.Join(dir, "users.json")}
+	if !reflect.DeepEqual(files, want) {
+		t.Fatalf("expected the fixtures %v, got %v", want, files)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "users.json"))
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+
+	var fix fixture
+	if err := json.Unmarshal(data, &fix); err != nil {
+		t.Fatalf("failed to decode fixture: %v", err)
+	}
+
+	query := map[string][]string{"apikey": {"REDACTED"}, "account": {"REDACTED"}, "limit": {"1"}}
+	if fix.Method != http.MethodGet || fix.Path != "/users" || fix.Status != http.StatusOK ||
+		!reflect.DeepEqual(fix.Query, query) {
+		t.Fatalf("expected the scrubbed request of GET /users, got %s", data)
+	}
+
+	body := `[{"email":"REDACTED","id":1,"note":"key REDACTED & more","profile":{"email":"REDACTED","phone":null},` +
+		`"token":"REDACTED"}]`
+	var got bytes.Buffer
+	if err := json.Compact(&got, fix.Body); err != nil || got.String() != body {
+		t.Fatalf("expected the scrubbed body %s, got %s", body, fix.Body)
+	}
+
+	if err := Fixtures(context.Background(), cfg, FixturesOptions{Dir: dir, Requests: []string{"/status"}}); err != nil {
+		t.Fatalf("failed to capture fixtures: %v", err)
+	}
+
+	data, err = os.ReadFile(filepath.Join(dir, "status.json"))
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+
+	if err := json.Unmarshal(data, &fix); err != nil || fix.Text != "ok REDACTED" {
+		t.Fatalf("expected the scrubbed text of an invalid JSON body, got %s", data)
+	}
+}
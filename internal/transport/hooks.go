@@ -0,0 +1,123 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/internal/hooks"
+	"github.com/alpstable/gidari/internal/state"
+	"github.com/alpstable/gidari/tools"
+)
+
+// hookRecord will decode a record for the transform hooks, with numbers as "json.Number" so that they are not
+// rounded. It returns false if the record is not an object, which is written as it is.
+func hookRecord(raw json.RawMessage) (map[string]interface{}, bool) {
+	if trimmed := bytes.TrimLeft(raw, " \t\r\n"); len(trimmed) == 0 || trimmed[0] != '{' {
+		return nil, false
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+
+	var record map[string]interface{}
+	if decoder.Decode(&record) != nil {
+		return nil, false
+	}
+
+	return record, true
+}
+
+// hookData will run the transform hooks on the records of JSON data, an array of records or a single record, in
+// order. Records that a hook drops are not passed to the hooks after it, and are not written.
+func hookData(ctx context.Context, names []string, table string, data []byte) ([]byte, error) {
+	if len(names) == 0 {
+		return data, nil
+	}
+
+	fns, err := hooks.Lookup(names)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run transform hooks: %w", err)
+	}
+
+	records, err := appendRecords(nil, data)
+	if err != nil {
+		return nil, err
+	}
+
+	kept := make([]json.RawMessage, 0, len(records))
+
+	for _, raw := range records {
+		record, ok := hookRecord(raw)
+		if !ok {
+			kept = append(kept, raw)
+
+			continue
+		}
+
+		for idx, fn := range fns {
+			if record, err = fn(ctx, table, record); err != nil {
+				return nil, fmt.Errorf("transform hook %q failed for %s: %w", names[idx], table, err)
+			}
+
+			if record == nil {
+				break
+			}
+		}
+
+		if record == nil {
+			continue
+		}
+
+		out, err := json.Marshal(record)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode the record of transform hook for %s: %w", table, err)
+		}
+
+		kept = append(kept, out)
+	}
+
+	out, err := json.Marshal(kept)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode records: %w", err)
+	}
+
+	return out, nil
+}
+
+// runHooks will run the run hooks of a run that has completed successfully, in order, and save the variables that
+// they set in the state store. The data of the run has already been committed, so a failed hook only fails the run.
+func runHooks(ctx context.Context, cfg *config.Config, store *state.Store) error {
+	if len(cfg.RunHooks) == 0 {
+		return nil
+	}
+
+	fns, err := hooks.LookupRun(cfg.RunHooks)
+	if err != nil {
+		return fmt.Errorf("failed to run run hooks: %w", err)
+	}
+
+	for idx, fn := range fns {
+		if err := fn(ctx, store); err != nil {
+			return fmt.Errorf("run hook %q failed: %w", cfg.RunHooks[idx], err)
+		}
+
+		logInfo := tools.LogFormatter{Msg: fmt.Sprintf("ran run hook %q", cfg.RunHooks[idx])}
+		cfg.Logger.Debug(logInfo.String())
+	}
+
+	if err := store.Save(); err != nil {
+		return fmt.Errorf("failed to save the variables of the run hooks: %w", err)
+	}
+
+	return nil
+}
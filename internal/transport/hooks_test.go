@@ -0,0 +1,147 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/internal/hooks"
+	"github.com/alpstable/gidari/internal/state"
+	"github.com/sirupsen/logrus"
+)
+
+func TestHookData(t *testing.T) {
+	t.Parallel()
+
+	errHook := errors.New("enrichment is down")
+
+	for name, hook := range map[string]hooks.Hook{
+		"transport-enrich": func(_ context.Context, table string, record map[string]interface{},
+		) (map[string]interface{}, error) {
+			record["table"] = table
+
+			return record, nil
+		},
+		"transport-filter": func(_ context.Context, _ string, record map[string]interface{},
+		) (map[string]interface{}, error) {
+			if record["id"] == json.Number("2") {
+				return nil, nil
+			}
+
+			return record, nil
+		},
+		"transport-fail": func(context.Context, string, map[string]interface{}) (map[string]interface{}, error) {
+			return nil, errHook
+		},
+	} {
+		if err := hooks.Register(name, hook); err != nil {
+			t.Fatalf("failed to register hook %s: %v", name, err)
+		}
+
+		name := name
+		t.Cleanup(func() { hooks.Unregister(name) })
+	}
+
+	for _, tcase := range []struct {
+		name  string
+		hooks []string
+		data  string
+		want  string
+		err   error
+	}{
+		{name: "no hooks", data: `{"id":1}`, want: `{"id":1}`},
+		{name: "single record", hooks: []string{"transport-enrich"}, data: `{"id":1}`, want: `[{"id":1,"table":"t"}]`},
+		{
+			name:  "in order",
+			hooks: []string{"transport-filter", "transport-enrich"},
+			data:  `[{"id":1},{"id":2},{"id":12345678901234567890},3]`,
+			want:  `[{"id":1,"table":"t"},{"id":12345678901234567890,"table":"t"},3]`,
+		},
+		{name: "failed", hooks: []string{"transport-fail"}, data: `{"id":1}`, err: errHook},
+		{name: "unregistered", hooks: []string{"transport-missing"}, data: `{"id":1}`, err: hooks.ErrUnknownHook},
+	} {
+		got, err := hookData(context.Background(), tcase.hooks, "t", []byte(tcase.data))
+		if !errors.Is(err, tcase.err) {
+			t.Fatalf("%s: expected error %v, got %v", tcase.name, tcase.err, err)
+		}
+
+		if tcase.err == nil && string(got) != tcase.want {
+			t.Fatalf("%s: expected %s, got %s", tcase.name, tcase.want, got)
+		}
+	}
+}
+
+func TestRunHooks(t *testing.T) {
+	t.Parallel()
+
+	errHook := errors.New("cursor is unknown")
+
+	for name, hook := range map[string]hooks.RunHook{
+		"transport-cursor": func(_ context.Context, vars hooks.Variables) error {
+			last, _ := vars.Variable("cursor")
+			vars.SetVariable("cursor", last+"x")
+
+			return nil
+		},
+		"transport-run-fail": func(context.Context, hooks.Variables) error {
+			return errHook
+		},
+	} {
+		if err := hooks.RegisterRun(name, hook); err != nil {
+			t.Fatalf("failed to register run hook %s: %v", name, err)
+		}
+
+		name := name
+		t.Cleanup(func() { hooks.UnregisterRun(name) })
+	}
+
+	for _, tcase := range []struct {
+		name  string
+		hooks []string
+		want  string
+		err   error
+	}{
+		{name: "no hooks", want: "a"},
+		{name: "in order", hooks: []string{"transport-cursor", "transport-cursor"}, want: "axx"},
+		{name: "failed", hooks: []string{"transport-cursor", "transport-run-fail"}, want: "a", err: errHook},
+	} {
+		path := filepath.Join(t.TempDir(), "state.json")
+
+		store, err := state.Open(path, nil)
+		if err != nil {
+			t.Fatalf("%s: failed to open state: %v", tcase.name, err)
+		}
+
+		store.SetVariable("cursor", "a")
+
+		if err := store.Save(); err != nil {
+			t.Fatalf("%s: failed to save state: %v", tcase.name, err)
+		}
+
+		cfg := &config.Config{Logger: logrus.New(), RunHooks: tcase.hooks}
+		if err := runHooks(context.Background(), cfg, store); !errors.Is(err, tcase.err) {
+			t.Fatalf("%s: expected error %v, got %v", tcase.name, tcase.err, err)
+		}
+
+		saved, err := state.Open(path, nil)
+		if err != nil {
+			t.Fatalf("%s: failed to reopen state: %v", tcase.name, err)
+		}
+
+		if got, _ := saved.Variable("cursor"); got != tcase.want {
+			t.Fatalf("%s: expected the saved cursor %q, got %q", tcase.name, tcase.want, got)
+		}
+	}
+}
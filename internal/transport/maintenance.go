@@ -0,0 +1,86 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/alpstable/gidari/tools"
+)
+
+// maintenanceEnd returns the time that the last of the open maintenance windows of a web job, or of a request
+// coalesced into it, closes, and false if none of them are open at "now".
+func (job *webJob) maintenanceEnd(now time.Time) (time.Time, bool) {
+	var (
+		end  time.Time
+		open bool
+	)
+
+	for _, target := range append([]*flattenedRequest{job.flattenedRequest}, job.coalesced...) {
+		for _, window := range target.maintenance {
+			if until, ok := window.OpenUntil(now); ok && until.After(end) {
+				end, open = until, true
+			}
+		}
+	}
+
+	return end, open
+}
+
+// holdForMaintenance will hold a web job while one of its maintenance windows is open, and then run it. Like the
+// jobs of paused requests, the job is held outside of the web workers so that requests to other sources continue.
+// It returns false if none of the maintenance windows of the job are open.
+func (job *webJob) holdForMaintenance(ctx context.Context, workerID int) bool {
+	end, open := job.maintenanceEnd(job.clock.Now())
+	if !open {
+		return false
+	}
+
+	logInfo := tools.LogFormatter{
+		WorkerID:   workerID,
+		WorkerName: "web",
+		Msg: fmt.Sprintf("pausing %s for a maintenance window until %s", job.fetchConfig.URL,
+			end.Format(time.RFC3339)),
+	}
+	job.logger.Infof(logInfo.String())
+
+	job.parked.Add(1)
+
+	unpark := job.status.park()
+
+	go func() {
+		defer job.parked.Done()
+
+		// Windows that overlap, or that open again by the time the job is resumed, hold the job for longer.
+		for open {
+			if err := tools.Sleep(ctx, job.clock, end.Sub(job.clock.Now())); err != nil {
+				break
+			}
+
+			end, open = job.maintenanceEnd(job.clock.Now())
+		}
+
+		unpark()
+
+		logInfo := tools.LogFormatter{
+			WorkerID:   workerID,
+			WorkerName: "web",
+			Msg:        fmt.Sprintf("resuming %s after its maintenance window", job.fetchConfig.URL),
+		}
+		job.logger.Infof(logInfo.String())
+
+		// The request may have been paused while it was held.
+		if !job.park(ctx, workerID) {
+			runWebJob(ctx, workerID, job)
+		}
+	}()
+
+	return true
+}
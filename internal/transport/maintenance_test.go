@@ -0,0 +1,119 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/internal/control"
+	"github.com/alpstable/gidari/tools"
+)
+
+func TestMaintenanceEnd(t *testing.T) {
+	t.Parallel()
+
+	job := newTestControlJob(nil, "GET /a a", "GET /b b")
+	job.maintenance = []*config.MaintenanceWindow{{Schedule: "0 2 * * *", Duration: "30m"}}
+	job.coalesced[0].maintenance = []*config.MaintenanceWindow{{Schedule: "15 2 * * *", Duration: "30m"}}
+
+	for _, tcase := range []struct {
+		name string
+		now  time.Time
+		want time.Time
+	}{
+		{name: "before", now: time.Date(2022, 5, 10, 1, 59, 0, 0, time.UTC)},
+		{
+			name: "first window",
+			now:  time.Date(2022, 5, 10, 2, 5, 0, 0, time.UTC),
+			want: time.Date(2022, 5, 10, 2, 30, 0, 0, time.UTC),
+		},
+		{
+			name: "overlapping windows",
+			now:  time.Date(2022, 5, 10, 2, 20, 0, 0, time.UTC),
+			want: time.Date(2022, 5, 10, 2, 45, 0, 0, time.UTC),
+		},
+		{name: "after", now: time.Date(2022, 5, 10, 2, 45, 0, 0, time.UTC)},
+	} {
+		end, open := job.maintenanceEnd(tcase.now)
+		if open != !tcase.want.IsZero() || !end.Equal(tcase.want) {
+			t.Fatalf("%s: expected the windows to close at %v, got %v (open %t)", tcase.name, tcase.want, end, open)
+		}
+	}
+}
+
+func TestHoldForMaintenance(t *testing.T) {
+	t.Parallel()
+
+	newJob := func(ctl *control.Control, clock tools.Clock) *webJob {
+		job := newTestControlJob(ctl, "GET /a a")
+		job.clock = clock
+		job.maintenance = []*config.MaintenanceWindow{{Schedule: "0 2 * * *", Duration: "30m"}}
+
+		return job
+	}
+
+	t.Run("closed", func(t *testing.T) {
+		t.Parallel()
+
+		clock := tools.NewFakeClock(time.Date(2022, 5, 10, 3, 0, 0, 0, time.UTC))
+		if newJob(nil, clock).holdForMaintenance(context.Background(), 1) {
+			t.Fatal("expected a job outside of its maintenance window not to be held")
+		}
+	})
+
+	t.Run("resumed", func(t *testing.T) {
+		t.Parallel()
+
+		ctl := control.New(nil)
+		clock := tools.NewFakeClock(time.Date(2022, 5, 10, 2, 10, 0, 0, time.UTC))
+		job := newJob(ctl, clock)
+
+		if !job.holdForMaintenance(context.Background(), 1) {
+			t.Fatal("expected a job in its maintenance window to be held")
+		}
+
+		waitForWaiters(t, clock)
+
+		// The request is canceled while it is held, so that the job is skipped once the window closes.
+		if _, err := ctl.Cancel("a"); err != nil {
+			t.Fatalf("failed to cancel: %v", err)
+		}
+
+		clock.Advance(20 * time.Minute)
+		job.parked.Wait()
+
+		if job.done {
+			t.Fatal("expected a canceled request not to be done")
+		}
+	})
+
+	t.Run("interrupted", func(t *testing.T) {
+		t.Parallel()
+
+		clock := tools.NewFakeClock(time.Date(2022, 5, 10, 2, 10, 0, 0, time.UTC))
+		job := newJob(control.New(nil), clock)
+
+		ctx, cancel := context.WithCancel(context.Background())
+
+		if !job.holdForMaintenance(ctx, 1) {
+			t.Fatal("expected a job in its maintenance window to be held")
+		}
+
+		cancel()
+		job.parked.Wait()
+
+		if job.done {
+			t.Fatal("expected an interrupted request not to be done")
+		}
+	})
+}
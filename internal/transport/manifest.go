@@ -0,0 +1,346 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/internal/proto"
+	"github.com/alpstable/gidari/tools"
+)
+
+// manifestIDLayout is the layout of the ID of a run, the UTC time that it started.
+const manifestIDLayout = "20060102T150405Z"
+
+const (
+	// manifestCompleted, manifestInterrupted and manifestFailed are the statuses of a run in its manifest. Runs that
+	// are interrupted or reach a limit have committed what they fetched, and can be resumed.
+	manifestCompleted   = "completed"
+	manifestInterrupted = "interrupted"
+	manifestFailed      = "failed"
+)
+
+// manifestChunk is a span of time that the timeseries requests of a table covered.
+type manifestChunk struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// tableManifest is what a run wrote to a table.
+type tableManifest struct {
+	// Requests is the number of requests that were fetched for the table, one for each page or chunk.
+	Requests int `json:"requests"`
+
+	// Rows is the number of records that were written to the table, once they were transformed.
+	Rows int64 `json:"rows"`
+
+	// FetchMS is the time spent fetching the requests of the table, in milliseconds.
+	FetchMS int64 `json:"fetch_ms"`
+
+	// Chunks are the spans of time that the timeseries requests of the table covered, merged and in order.
+	Chunks []*manifestChunk `json:"chunks,omitempty"`
+
+	// Columns are the kinds of the columns of the records, inferred the way "autoCreate" infers them from a sample
+	// of each write.
+	Columns map[string]string `json:"columns,omitempty"`
+
+	fetched time.Duration
+}
+
+// manifestConfig identifies the configuration file of a run.
+type manifestConfig struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+}
+
+// runManifest is the record of a run that is written to the "manifest.dir" once the run is over.
+type runManifest struct {
+	ID         string                    `json:"id"`
+	Status     string                    `json:"status"`
+	Error      string                    `json:"error,omitempty"`
+	Started    time.Time                 `json:"started"`
+	Finished   time.Time                 `json:"finished"`
+	DurationMS int64                     `json:"duration_ms"`
+	Config     *manifestConfig           `json:"config,omitempty"`
+	Tables     map[string]*tableManifest `json:"tables"`
+
+	// Failed are the requests of the run that were dead-lettered, or that were not committed because their batch
+	// failed, which "gidari retry-failed" makes again.
+	Failed []*deadLetter `json:"failed,omitempty"`
+}
+
+// manifestRecorder collects the manifest of a run from its web and repository workers. A nil recorder records
+// nothing.
+type manifestRecorder struct {
+	clock tools.Clock
+	dir   string
+
+	mu       sync.Mutex
+	manifest runManifest
+}
+
+// newManifestRecorder returns the recorder of the manifest of a run, or nil if the configuration has no "manifest".
+func newManifestRecorder(cfg *config.Config) *manifestRecorder {
+	if cfg.Manifest == nil {
+		return nil
+	}
+
+	dir := config.DefaultManifestDir
+	if cfg.Manifest.Dir != "" {
+		dir = cfg.Manifest.Dir
+	}
+
+	clock := tools.ClockOrReal(cfg.Clock)
+	started := clock.Now().UTC()
+
+	rec := &manifestRecorder{
+		clock: clock,
+		dir:   dir,
+		manifest: runManifest{
+			ID:      started.Format(manifestIDLayout),
+			Started: started,
+			Tables:  make(map[string]*tableManifest),
+		},
+	}
+
+	if path, sum := cfg.Source(); path != "" {
+		rec.manifest.Config = &manifestConfig{Path: path, SHA256: sum}
+	}
+
+	return rec
+}
+
+// table returns the manifest of a table. The lock must be held.
+func (rec *manifestRecorder) table(name string) *tableManifest {
+	table := rec.manifest.Tables[name]
+	if table == nil {
+		table = &tableManifest{}
+		rec.manifest.Tables[name] = table
+	}
+
+	return table
+}
+
+// fetched will record a fetch of the target requests, which took "elapsed".
+func (rec *manifestRecorder) fetched(targets []*flattenedRequest, elapsed time.Duration) {
+	if rec == nil {
+		return
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	for _, target := range targets {
+		table := rec.table(target.table)
+		table.Requests++
+		table.fetched += elapsed
+
+		if target.chunk != nil {
+			table.Chunks = append(table.Chunks, &manifestChunk{Start: target.chunk[0].UTC(), End: target.chunk[1].UTC()})
+		}
+	}
+}
+
+// wrote will record the records of an upsert request. Data that cannot be decoded, e.g. a "clobColumn", is not
+// counted.
+func (rec *manifestRecorder) wrote(req *proto.UpsertRequest) {
+	if rec == nil {
+		return
+	}
+
+	records, err := proto.DecodeUpsertRequest(req)
+	if err != nil {
+		return
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	table := rec.table(req.Table)
+	table.Rows += int64(len(records))
+
+	if len(records) > autoCreateSampleSize {
+		records = records[:autoCreateSampleSize]
+	}
+
+	for _, record := range records {
+		if table.Columns == nil {
+			table.Columns = make(map[string]string)
+		}
+
+		// Null values have no kind, but still add the column.
+		for name, val := range record.GetFields() {
+			kind, _ := proto.ValueKind(val)
+			table.Columns[name] = proto.MergeKinds(table.Columns[name], kind)
+		}
+	}
+}
+
+// failed will record a request of the run that failed.
+func (rec *manifestRecorder) failed(letter *deadLetter) {
+	if rec == nil {
+		return
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	rec.manifest.Failed = append(rec.manifest.Failed, letter)
+}
+
+// failedBatch will record the requests of a batch that failed with "err", and so was not committed. Requests that
+// were dead-lettered have been recorded already.
+func (rec *manifestRecorder) failedBatch(batch []*flattenedRequest, err error) {
+	if rec == nil {
+		return
+	}
+
+	failedAt := rec.clock.Now()
+
+	for _, fetch := range batch {
+		for _, target := range append([]*flattenedRequest{fetch}, fetch.coalesced...) {
+			if target.failed {
+				continue
+			}
+
+			letter := newDeadLetter(target, err, 0, failedAt)
+			letter.Run = rec.manifest.ID
+
+			rec.failed(letter)
+		}
+	}
+}
+
+// mergeChunks will sort the chunks of a table and merge the ones that overlap or are adjacent.
+func mergeChunks(chunks []*manifestChunk) []*manifestChunk {
+	if len(chunks) == 0 {
+		return nil
+	}
+
+	sorted := append([]*manifestChunk(nil), chunks...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start.Before(sorted[j].Start) })
+
+	merged := []*manifestChunk{{Start: sorted[0].Start, End: sorted[0].End}}
+
+	for _, chunk := range sorted[1:] {
+		last := merged[len(merged)-1]
+		if chunk.Start.After(last.End) {
+			merged = append(merged, &manifestChunk{Start: chunk.Start, End: chunk.End})
+
+			continue
+		}
+
+		if chunk.End.After(last.End) {
+			last.End = chunk.End
+		}
+	}
+
+	return merged
+}
+
+// manifestStatus returns the status of a run that ended with "err".
+func manifestStatus(err error) string {
+	switch {
+	case err == nil:
+		return manifestCompleted
+	case errors.Is(err, ErrInterrupted), errors.Is(err, ErrBudgetExceeded), errors.Is(err, ErrDeadlineExceeded):
+		return manifestInterrupted
+	default:
+		return manifestFailed
+	}
+}
+
+// save will finish the manifest of a run that ended with "err" and write it to the manifest directory, returning
+// the path that it was written to. Runs that start in the same second are told apart by a suffix of their ID.
+func (rec *manifestRecorder) save(runErr error) (string, error) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	manifest := rec.manifest
+	manifest.Status = manifestStatus(runErr)
+	manifest.Finished = rec.clock.Now().UTC()
+	manifest.DurationMS = manifest.Finished.Sub(manifest.Started).Milliseconds()
+
+	if runErr != nil {
+		manifest.Error = runErr.Error()
+	}
+
+	for _, table := range manifest.Tables {
+		table.Chunks = mergeChunks(table.Chunks)
+		table.FetchMS = table.fetched.Milliseconds()
+
+		// Columns that are null in every record are strings, as they are for "autoCreate".
+		for name, kind := range table.Columns {
+			if kind == "" {
+				table.Columns[name] = proto.KindString
+			}
+		}
+	}
+
+	if err := os.MkdirAll(rec.dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create manifest directory: %w", err)
+	}
+
+	id := manifest.ID
+
+	for attempt := 2; ; attempt++ {
+		manifest.ID = id
+		path := filepath.Join(rec.dir, manifest.ID+".json")
+
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if errors.Is(err, os.ErrExist) {
+			id = fmt.Sprintf("%s-%d", rec.manifest.ID, attempt)
+
+			continue
+		}
+
+		if err != nil {
+			return "", fmt.Errorf("failed to create manifest: %w", err)
+		}
+
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+
+		if err := encoder.Encode(manifest); err != nil {
+			file.Close()
+
+			return "", fmt.Errorf("failed to write manifest: %w", err)
+		}
+
+		if err := file.Close(); err != nil {
+			return "", fmt.Errorf("failed to write manifest: %w", err)
+		}
+
+		return path, nil
+	}
+}
+
+// saveManifest will write the manifest of a run that ended with "err", logging where it was written. A manifest that
+// cannot be written is logged as a warning rather than failing a run whose data has been committed.
+func saveManifest(cfg *config.Config, rec *manifestRecorder, err error) {
+	if rec == nil {
+		return
+	}
+
+	path, saveErr := rec.save(err)
+	if saveErr != nil {
+		cfg.Logger.Warn(tools.LogFormatter{Msg: saveErr.Error()}.String())
+
+		return
+	}
+
+	cfg.Logger.Info(tools.LogFormatter{Msg: fmt.Sprintf("run manifest written to %s", path)}.String())
+}
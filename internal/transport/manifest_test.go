@@ -0,0 +1,99 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/internal/proto"
+	"github.com/alpstable/gidari/tools"
+)
+
+func TestManifestRecorder(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	day := time.Date(2022, 10, 14, 15, 4, 5, 0, time.UTC)
+	clock := tools.NewFakeClock(day)
+
+	rec := newManifestRecorder(&config.Config{Manifest: &config.ManifestConfig{Dir: dir}, Clock: clock})
+
+	chunk := func(from, to int) *[2]time.Time {
+		return &[2]time.Time{day.AddDate(0, 0, from), day.AddDate(0, 0, to)}
+	}
+
+	rec.fetched([]*flattenedRequest{{table: "candles", chunk: chunk(1, 2)}}, time.Second)
+	rec.fetched([]*flattenedRequest{{table: "candles", chunk: chunk(0, 1)}, {table: "trades"}}, 2*time.Second)
+	rec.fetched([]*flattenedRequest{{table: "candles", chunk: chunk(3, 4)}}, time.Second)
+
+	rec.wrote(&proto.UpsertRequest{Table: "candles", Data: []byte(`[{"id":1,"price":1},{"id":2,"price":1.5}]`)})
+	rec.wrote(&proto.UpsertRequest{Table: "candles", Data: []byte(`{"id":3,"price":null,"note":null}`)})
+	rec.wrote(&proto.UpsertRequest{Table: "trades", Data: []byte(`not json`)})
+
+	clock.Advance(time.Minute)
+
+	path, err := rec.save(ErrInterrupted)
+	if err != nil {
+		t.Fatalf("failed to save the manifest: %v", err)
+	}
+
+	if want := filepath.Join(dir, "20221014T150405Z.json"); path != want {
+		t.Fatalf("expected the manifest at %s, got %s", want, path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read the manifest: %v", err)
+	}
+
+	var manifest runManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatalf("failed to decode the manifest: %v", err)
+	}
+
+	if manifest.Status != manifestInterrupted || manifest.DurationMS != time.Minute.Milliseconds() {
+		t.Fatalf("expected an interrupted run of a minute, got %s in %dms", manifest.Status, manifest.DurationMS)
+	}
+
+	candles := manifest.Tables["candles"]
+	if candles.Requests != 3 || candles.Rows != 3 || candles.FetchMS != 4000 {
+		t.Fatalf("expected 3 requests of 3 rows fetched in 4s, got %+v", candles)
+	}
+
+	chunks := candles.Chunks
+	if len(chunks) != 2 || !chunks[0].Start.Equal(day) || !chunks[0].End.Equal(day.AddDate(0, 0, 2)) ||
+		!chunks[1].Start.Equal(day.AddDate(0, 0, 3)) {
+		t.Fatalf("expected the adjacent chunks to be merged, got %s", data)
+	}
+
+	if candles.Columns["id"] != proto.KindInteger || candles.Columns["price"] != proto.KindNumber ||
+		candles.Columns["note"] != proto.KindString {
+		t.Fatalf("expected the columns to be inferred, got %v", candles.Columns)
+	}
+
+	if trades := manifest.Tables["trades"]; trades.Requests != 1 || trades.Rows != 0 || trades.Chunks != nil {
+		t.Fatalf("expected a request of trades without rows or chunks, got %+v", trades)
+	}
+
+	// Runs that start in the same second do not overwrite each other.
+	path, err = rec.save(nil)
+	if err != nil {
+		t.Fatalf("failed to save the second manifest: %v", err)
+	}
+
+	if want := filepath.Join(dir, "20221014T150405Z-2.json"); path != want {
+		t.Fatalf("expected the manifest at %s, got %s", want, path)
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+
+	"github.com/alpstable/gidari/config"
+)
+
+// maskValue will redact a JSON value with a mask. Strings are hashed by their text and other values by their JSON,
+// and only strings can be truncated. Null values are left as they are.
+func maskValue(mask *config.Mask, key []byte, val json.RawMessage) (json.RawMessage, error) {
+	if bytes.Equal(bytes.TrimSpace(val), []byte("null")) {
+		return val, nil
+	}
+
+	var str string
+	isString := json.Unmarshal(val, &str) == nil
+
+	switch mask.Name {
+	case config.MaskNull:
+		return json.RawMessage("null"), nil
+	case config.MaskTruncate:
+		if !isString {
+			return nil, fmt.Errorf("%s is not a string", val)
+		}
+
+		if runes := []rune(str); len(runes) > mask.Length {
+			str = string(runes[:mask.Length])
+		}
+	default:
+		text := []byte(str)
+		if !isString {
+			var buf bytes.Buffer
+			if err := json.Compact(&buf, val); err != nil {
+				return nil, fmt.Errorf("failed to compact value: %w", err)
+			}
+
+			text = buf.Bytes()
+		}
+
+		var hsh hash.Hash
+		if len(key) != 0 {
+			hsh = hmac.New(sha256.New, key)
+		} else {
+			hsh = sha256.New()
+		}
+
+		hsh.Write(text)
+		str = hex.EncodeToString(hsh.Sum(nil))
+	}
+
+	out, err := json.Marshal(str)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode masked value: %w", err)
+	}
+
+	return out, nil
+}
+
+// maskRecord will redact the columns of a record with their masks. Records that are not objects, and columns that
+// they do not have, are left as they are.
+func maskRecord(masks map[string]*config.Mask, key []byte, data json.RawMessage) (json.RawMessage, error) {
+	var record map[string]json.RawMessage
+	if err := json.Unmarshal(data, &record); err != nil {
+		return data, nil //nolint:nilerr // only objects have columns to mask
+	}
+
+	for column, mask := range masks {
+		val, ok := record[column]
+		if !ok {
+			continue
+		}
+
+		masked, err := maskValue(mask, key, val)
+		if err != nil {
+			return nil, fmt.Errorf("%w %q with %s: %v", ErrTransform, column, mask.Name, err)
+		}
+
+		record[column] = masked
+	}
+
+	out, err := json.Marshal(record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode record: %w", err)
+	}
+
+	return out, nil
+}
+
+// maskData will redact the columns of the records of JSON data, an array of records or a single record, with their
+// masks, hashing with the HMAC "key" if it is not empty.
+func maskData(masks map[string]string, key []byte, data []byte) ([]byte, error) {
+	if len(masks) == 0 {
+		return data, nil
+	}
+
+	// The masks have been validated with the configuration.
+	parsed := make(map[string]*config.Mask, len(masks))
+	for column, name := range masks {
+		parsed[column], _ = config.ParseMask(name)
+	}
+
+	return mapRecords(data, func(record json.RawMessage) (json.RawMessage, error) {
+		return maskRecord(parsed, key, record)
+	})
+}
@@ -0,0 +1,53 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMaskData(t *testing.T) {
+	t.Parallel()
+
+	// The SHA-256 of "a@b.io", and its HMAC-SHA256 with the key "k".
+	const (
+		plain = "0f3306f460edf2294d6c3acba4b0e24d19173d2f0a4a545ec55166767d5e83a4"
+		keyed = "27a3f9fb3361e84fabf7a06429f073b325568df1aabf4b031477b52ec06768c1"
+	)
+
+	masks := map[string]string{"email": "hash", "token": "null", "ip": "truncate(7)"}
+
+	for _, tcase := range []struct {
+		name string
+		key  string
+		data string
+		want string
+		err  error
+	}{
+		{name: "no columns", data: `{"id":1}`, want: `{"id":1}`},
+		{
+			name: "masked",
+			data: `[{"email":"a@b.io","token":"secret","ip":"192.168.10.1"},{"email":null,"ip":"10.0"},3]`,
+			want: `[{"email":"` + plain + `","ip":"192.168","token":null},{"email":null,"ip":"10.0"},3]`,
+		},
+		{name: "keyed", key: "k", data: `{"email":"a@b.io"}`, want: `{"email":"` + keyed + `"}`},
+		{name: "truncate a number", data: `{"ip":3232238081}`, err: ErrTransform},
+	} {
+		got, err := maskData(masks, []byte(tcase.key), []byte(tcase.data))
+		if !errors.Is(err, tcase.err) {
+			t.Fatalf("%s: expected error %v, got %v", tcase.name, tcase.err, err)
+		}
+
+		if tcase.err == nil && string(got) != tcase.want {
+			t.Fatalf("%s: expected %s, got %s", tcase.name, tcase.want, got)
+		}
+	}
+}
@@ -0,0 +1,382 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"runtime/metrics"
+	"sync"
+	"time"
+
+	"github.com/alpstable/gidari/internal/workspace"
+	"github.com/alpstable/gidari/tools"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// memorySoftLimitRatio is the fraction of the hard memory limit at which the transport starts to degrade.
+	memorySoftLimitRatio = 0.8
+
+	// memoryPollInterval is how often a blocked fetch will re-check memory usage.
+	memoryPollInterval = 50 * time.Millisecond
+
+	// memorySampleInterval is the minimum duration between two reads of the runtime memory metrics.
+	memorySampleInterval = 100 * time.Millisecond
+
+	// degradedBatchSize is the maximum number of records upserted at once from a spilled response body.
+	degradedBatchSize = 100
+
+	// spillDir is the workspace directory that spilled response bodies are written to.
+	spillDir = "spill"
+)
+
+// memoryState describes how close the transport is to its memory limit.
+type memoryState uint8
+
+const (
+	memoryStateNormal memoryState = iota
+	memoryStateSoft
+	memoryStateHard
+)
+
+func (state memoryState) String() string {
+	switch state {
+	case memoryStateSoft:
+		return "soft"
+	case memoryStateHard:
+		return "hard"
+	case memoryStateNormal:
+		return "normal"
+	default:
+		return "unknown"
+	}
+}
+
+// memoryGovernor tracks memory usage of the process against a soft and hard limit. Below the soft limit, the
+// transport runs unrestricted. Between the soft and hard limit, only one fetch is allowed in-flight at a time and
+// response bodies are spilled to disk. Above the hard limit, fetches are blocked until memory is reclaimed or until
+// there is nothing else in-flight. A nil "memoryGovernor" imposes no limits.
+type memoryGovernor struct {
+	soft    uint64
+	hard    uint64
+	threads int
+	logger  *logrus.Logger
+	clock   tools.Clock
+
+	// previous is the memory limit of the process before the run, which is restored when the run ends since the
+	// limit is shared by everything else in the process that embeds the transport.
+	previous int64
+
+	mu        sync.Mutex
+	inFlight  int
+	usage     uint64
+	sampledAt time.Time
+	state     memoryState
+	samples   []metrics.Sample
+}
+
+// newMemoryGovernor will create a governor for the given hard limit in bytes. If the limit is zero, this function will
+// return nil.
+func newMemoryGovernor(limit uint64, threads int, logger *logrus.Logger, clock tools.Clock) *memoryGovernor {
+	if limit == 0 {
+		return nil
+	}
+
+	// Make the Go runtime aware of the limit so that the garbage collector works harder as we approach it.
+	previous := debug.SetMemoryLimit(int64(limit))
+
+	return &memoryGovernor{
+		soft:     uint64(float64(limit) * memorySoftLimitRatio),
+		hard:     limit,
+		threads:  threads,
+		logger:   logger,
+		clock:    tools.ClockOrReal(clock),
+		previous: previous,
+		samples: []metrics.Sample{
+			{Name: "/memory/classes/total:bytes"},
+			{Name: "/memory/classes/heap/released:bytes"},
+		},
+	}
+}
+
+// stop will restore the memory limit that the process had before the run.
+func (gov *memoryGovernor) stop() {
+	if gov == nil {
+		return
+	}
+
+	debug.SetMemoryLimit(gov.previous)
+}
+
+// sample will return the number of bytes of memory currently mapped by the Go runtime, minus memory that has been
+// released back to the OS. The caller must hold the governor's lock.
+func (gov *memoryGovernor) sample() uint64 {
+	if gov.clock.Now().Sub(gov.sampledAt) < memorySampleInterval {
+		return gov.usage
+	}
+
+	metrics.Read(gov.samples)
+
+	total := gov.samples[0].Value.Uint64()
+	released := gov.samples[1].Value.Uint64()
+
+	gov.usage = total - released
+	gov.sampledAt = gov.clock.Now()
+
+	gov.setState()
+
+	return gov.usage
+}
+
+// setState will update the state of the governor from the last sample, logging any transitions. The caller must hold
+// the governor's lock.
+func (gov *memoryGovernor) setState() {
+	state := memoryStateNormal
+
+	switch {
+	case gov.usage >= gov.hard:
+		state = memoryStateHard
+	case gov.usage >= gov.soft:
+		state = memoryStateSoft
+	}
+
+	if state == gov.state {
+		return
+	}
+
+	gov.state = state
+
+	logInfo := tools.LogFormatter{
+		Msg: fmt.Sprintf("memory usage %d bytes, entering %q memory state", gov.usage, state),
+	}
+	gov.logger.Warn(logInfo.String())
+}
+
+// allowed returns the number of fetches that may be in-flight for the current memory state. The caller must hold the
+// governor's lock.
+func (gov *memoryGovernor) allowed() int {
+	switch gov.state {
+	case memoryStateHard:
+		// Always allow a single fetch if there is nothing else in-flight, otherwise the transport could stall
+		// waiting on memory that is held by something other than response data.
+		if gov.inFlight == 0 {
+			return 1
+		}
+
+		return 0
+	case memoryStateSoft:
+		return 1
+	case memoryStateNormal:
+		return gov.threads
+	default:
+		return gov.threads
+	}
+}
+
+// acquire will block until the governor allows another fetch to be in-flight.
+func (gov *memoryGovernor) acquire(ctx context.Context) error {
+	if gov == nil {
+		return nil
+	}
+
+	for {
+		gov.mu.Lock()
+		gov.sample()
+
+		if gov.inFlight < gov.allowed() {
+			gov.inFlight++
+			gov.mu.Unlock()
+
+			return nil
+		}
+
+		hard := gov.state == memoryStateHard
+		gov.mu.Unlock()
+
+		if hard {
+			runtime.GC()
+		}
+
+		if err := tools.Sleep(ctx, gov.clock, memoryPollInterval); err != nil {
+			return fmt.Errorf("waiting for memory: %w", err)
+		}
+	}
+}
+
+// release will mark a fetch as no longer in-flight.
+func (gov *memoryGovernor) release() {
+	if gov == nil {
+		return
+	}
+
+	gov.mu.Lock()
+	defer gov.mu.Unlock()
+
+	gov.inFlight--
+}
+
+// degraded returns true if memory usage is at or above the soft limit.
+func (gov *memoryGovernor) degraded() bool {
+	if gov == nil {
+		return false
+	}
+
+	gov.mu.Lock()
+	defer gov.mu.Unlock()
+
+	gov.sample()
+
+	return gov.state != memoryStateNormal
+}
+
+// spill will write the reader to a temporary file in the workspace and return the name of the file.
+func spill(ws *workspace.Workspace, body io.Reader) (string, error) {
+	file, err := ws.CreateTemp(spillDir, "gidari-spill-*.json")
+	if err != nil {
+		return "", fmt.Errorf("failed to create spill file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(file, body); err != nil {
+		os.Remove(file.Name())
+
+		return "", fmt.Errorf("failed to write spill file: %w", err)
+	}
+
+	return file.Name(), nil
+}
+
+// copySpill will copy a spill file to a new spill file, returning the name of the copy.
+func copySpill(ws *workspace.Workspace, name string) (string, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return "", fmt.Errorf("failed to open spill file: %w", err)
+	}
+	defer file.Close()
+
+	return spill(ws, file)
+}
+
+// validSpill will check that the spilled file is valid JSON without loading it into memory.
+func validSpill(name string) (bool, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return false, fmt.Errorf("failed to open spill file: %w", err)
+	}
+	defer file.Close()
+
+	dec := json.NewDecoder(bufio.NewReader(file))
+
+	for {
+		_, err := dec.Token()
+		if err == io.EOF {
+			return true, nil
+		}
+
+		if err != nil {
+			return false, nil
+		}
+	}
+}
+
+// spillBatchFn is called with each batch of JSON data decoded from a spill file.
+type spillBatchFn func([]byte) error
+
+// readSpill will stream the spilled JSON file, calling "batchFn" with JSON arrays of at most "size" records. If the
+// spilled data is not an array, "batchFn" is called once with the entire document.
+func readSpill(name string, size int, batchFn spillBatchFn) error {
+	file, err := os.Open(name)
+	if err != nil {
+		return fmt.Errorf("failed to open spill file: %w", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+
+	// Peek at the first non-whitespace byte to determine if the data is an array.
+	first, err := firstNonSpace(reader)
+	if err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(reader)
+
+	if first != '[' {
+		var doc json.RawMessage
+		if err := dec.Decode(&doc); err != nil {
+			return fmt.Errorf("failed to decode spill file: %w", err)
+		}
+
+		return batchFn(doc)
+	}
+
+	// Consume the opening bracket.
+	if _, err := dec.Token(); err != nil {
+		return fmt.Errorf("failed to decode spill file: %w", err)
+	}
+
+	batch := make([]json.RawMessage, 0, size)
+
+	for dec.More() {
+		var record json.RawMessage
+		if err := dec.Decode(&record); err != nil {
+			return fmt.Errorf("failed to decode spill file: %w", err)
+		}
+
+		batch = append(batch, record)
+
+		if len(batch) == size {
+			if err := flushSpillBatch(batch, batchFn); err != nil {
+				return err
+			}
+
+			batch = make([]json.RawMessage, 0, size)
+		}
+	}
+
+	if len(batch) > 0 {
+		return flushSpillBatch(batch, batchFn)
+	}
+
+	return nil
+}
+
+func flushSpillBatch(batch []json.RawMessage, batchFn spillBatchFn) error {
+	data, err := json.Marshal(batch)
+	if err != nil {
+		return fmt.Errorf("failed to marshal spill batch: %w", err)
+	}
+
+	return batchFn(data)
+}
+
+// firstNonSpace will return the first byte from the reader that is not JSON whitespace, without consuming it.
+func firstNonSpace(reader *bufio.Reader) (byte, error) {
+	for {
+		peek, err := reader.Peek(1)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read spill file: %w", err)
+		}
+
+		if !bytes.ContainsAny(peek, " \t\r\n") {
+			return peek[0], nil
+		}
+
+		if _, err := reader.Discard(1); err != nil {
+			return 0, fmt.Errorf("failed to read spill file: %w", err)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"os"
+	"runtime/debug"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestMemoryGovernorRestoresLimit(t *testing.T) {
+	// The memory limit is process-wide, so this test does not run in parallel.
+	previous := debug.SetMemoryLimit(1 << 40)
+	defer debug.SetMemoryLimit(previous)
+
+	gov := newMemoryGovernor(1<<30, 1, logrus.New(), nil)
+
+	if limit := debug.SetMemoryLimit(-1); limit != 1<<30 {
+		t.Fatalf("expected the limit of the run to be set, got %d", limit)
+	}
+
+	gov.stop()
+
+	if limit := debug.SetMemoryLimit(-1); limit != 1<<40 {
+		t.Fatalf("expected the previous limit to be restored, got %d", limit)
+	}
+}
+
+func TestReadSpill(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name string
+		data string
+		size int
+		want []string
+	}{
+		{
+			name: "array",
+			data: `[{"id":1},{"id":2},{"id":3}]`,
+			size: 2,
+			want: []string{`[{"id":1},{"id":2}]`, `[{"id":3}]`},
+		},
+		{
+			name: "object",
+			data: ` {"id":1}`,
+			size: 2,
+			want: []string{`{"id":1}`},
+		},
+		{
+			name: "empty array",
+			data: "\n[]",
+			size: 2,
+		},
+	} {
+		tcase := tcase
+
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			name, err := spill(nil, strings.NewReader(tcase.data))
+			if err != nil {
+				t.Fatalf("failed to spill: %v", err)
+			}
+
+			defer os.Remove(name)
+
+			valid, err := validSpill(name)
+			if err != nil || !valid {
+				t.Fatalf("expected valid spill, got %v: %v", valid, err)
+			}
+
+			var got []string
+
+			err = readSpill(name, tcase.size, func(data []byte) error {
+				got = append(got, string(data))
+
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("failed to read spill: %v", err)
+			}
+
+			if strings.Join(got, "|") != strings.Join(tcase.want, "|") {
+				t.Fatalf("expected %v, got %v", tcase.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,273 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/tools"
+)
+
+// ErrAssertionFailed is returned when the metrics of a completed run do not hold for one of the configured
+// "assertions". The data of the run has been committed.
+var ErrAssertionFailed = fmt.Errorf("assertion failed")
+
+// runMetrics are the named values recorded over a run: the "metrics" extracted from the responses of each request,
+// and the built-in row counts. A nil "runMetrics" records nothing.
+type runMetrics struct {
+	// aggregates are how each configured metric is combined, keyed by name.
+	aggregates map[string]string
+
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+// newRunMetrics will return the metrics for a run, or nil if the configuration has no metrics or assertions.
+func newRunMetrics(cfg *config.Config) *runMetrics {
+	aggregates := make(map[string]string)
+
+	for _, req := range cfg.Requests {
+		for _, metric := range req.Metrics {
+			aggregates[metric.Name] = metric.AggregateOrDefault()
+		}
+	}
+
+	if len(aggregates) == 0 && len(cfg.Assertions) == 0 && len(cfg.CanaryAssertions) == 0 {
+		return nil
+	}
+
+	aggregates[config.CredentialExpiryMetric] = config.MetricAggregateMin
+
+	return &runMetrics{aggregates: aggregates, values: make(map[string]float64)}
+}
+
+// add will combine a value into a metric. The lock must be held.
+func (metrics *runMetrics) add(name string, val float64) {
+	cur, ok := metrics.values[name]
+	if !ok {
+		metrics.values[name] = val
+
+		return
+	}
+
+	switch metrics.aggregates[name] {
+	case config.MetricAggregateMin:
+		metrics.values[name] = math.Min(cur, val)
+	case config.MetricAggregateMax:
+		metrics.values[name] = math.Max(cur, val)
+	default:
+		metrics.values[name] = cur + val
+	}
+}
+
+// addRows will count the records received for a table.
+func (metrics *runMetrics) addRows(table string, rows int) {
+	if metrics == nil {
+		return
+	}
+
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+
+	metrics.add(config.RowsMetric, float64(rows))
+	metrics.add(config.RowsMetric+"."+table, float64(rows))
+}
+
+// addCredentialExpiry will record the time that the credentials of a request had left before they expired.
+func (metrics *runMetrics) addCredentialExpiry(remaining time.Duration) {
+	if metrics == nil {
+		return
+	}
+
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+
+	metrics.add(config.CredentialExpiryMetric, math.Floor(remaining.Seconds()))
+}
+
+// metricValue returns the numeric value of a record field, which may be a JSON number or a numeric string.
+func metricValue(val interface{}) (float64, bool) {
+	switch val := val.(type) {
+	case float64:
+		return val, true
+	case string:
+		num, err := strconv.ParseFloat(val, 64)
+
+		return num, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// observeRecords will extract the configured metrics from a batch of records.
+func (metrics *runMetrics) observeRecords(defs []*config.Metric, records []interface{}) {
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+
+	for _, def := range defs {
+		for _, record := range records {
+			val, err := tools.GetJSONPath(record, def.Path)
+			if err != nil {
+				continue
+			}
+
+			if num, ok := metricValue(val); ok {
+				metrics.add(def.Name, num)
+			}
+		}
+	}
+}
+
+// observe will extract the configured metrics of a request from its response data.
+func (metrics *runMetrics) observe(defs []*config.Metric, data []byte, spilled string) error {
+	if metrics == nil || len(defs) == 0 {
+		return nil
+	}
+
+	if spilled == "" {
+		records, err := decodeResponseRecords(data)
+		if err != nil {
+			return err
+		}
+
+		metrics.observeRecords(defs, records)
+
+		return nil
+	}
+
+	return readSpill(spilled, degradedBatchSize, func(batch []byte) error {
+		records, err := decodeResponseRecords(batch)
+		if err != nil {
+			return err
+		}
+
+		metrics.observeRecords(defs, records)
+
+		return nil
+	})
+}
+
+// summary describes the value of every metric that was recorded, in name order.
+func (metrics *runMetrics) summary() string {
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+
+	names := make([]string, 0, len(metrics.values))
+	for name := range metrics.values {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	values := make([]string, len(names))
+	for idx, name := range names {
+		values[idx] = fmt.Sprintf("%s=%s", name, formatMetric(metrics.values[name]))
+	}
+
+	return strings.Join(values, ", ")
+}
+
+func formatMetric(val float64) string {
+	return strconv.FormatFloat(val, 'f', -1, 64)
+}
+
+// operand returns the value of an assertion comparison, which is either a number or the name of a metric.
+func (metrics *runMetrics) operand(str string) (float64, error) {
+	if val, ok := metrics.values[str]; ok {
+		return val, nil
+	}
+
+	// No rows were received for a table that has no built-in row count.
+	if config.IsBuiltinMetric(str) && str != config.CredentialExpiryMetric {
+		return 0, nil
+	}
+
+	if _, ok := metrics.aggregates[str]; ok {
+		return 0, fmt.Errorf("metric %q was not recorded", str)
+	}
+
+	val, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return 0, fmt.Errorf("metric %q was not recorded", str)
+	}
+
+	return val, nil
+}
+
+// check will return a description of why the assertion does not hold, or an empty string if it does.
+func (metrics *runMetrics) check(assertion *config.Assertion) string {
+	val, err := metrics.operand(assertion.Metric)
+	if err != nil {
+		return err.Error()
+	}
+
+	for _, cmp := range []struct {
+		operand string
+		holds   func(want float64) bool
+		desc    string
+	}{
+		{
+			operand: assertion.Equals,
+			holds: func(want float64) bool {
+				return math.Abs(val-want) <= assertion.Tolerance*math.Max(math.Abs(val), math.Abs(want))
+			},
+			desc: "equal",
+		},
+		{operand: assertion.Min, holds: func(want float64) bool { return val >= want }, desc: "be at least"},
+		{operand: assertion.Max, holds: func(want float64) bool { return val <= want }, desc: "be at most"},
+	} {
+		if cmp.operand == "" {
+			continue
+		}
+
+		want, err := metrics.operand(cmp.operand)
+		if err != nil {
+			return err.Error()
+		}
+
+		if !cmp.holds(want) {
+			return fmt.Sprintf("%s is %s, expected it to %s %s (%s)", assertion.Metric, formatMetric(val), cmp.desc,
+				cmp.operand, formatMetric(want))
+		}
+	}
+
+	return ""
+}
+
+// assert will check every assertion against the metrics of the run, returning a consolidated report of the
+// assertions that do not hold.
+func (metrics *runMetrics) assert(assertions []*config.Assertion) error {
+	if metrics == nil || len(assertions) == 0 {
+		return nil
+	}
+
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+
+	var failed []string
+
+	for _, assertion := range assertions {
+		if reason := metrics.check(assertion); reason != "" {
+			failed = append(failed, reason)
+		}
+	}
+
+	if len(failed) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %d of %d assertions failed:\n\t%s", ErrAssertionFailed, len(failed), len(assertions),
+		strings.Join(failed, "\n\t"))
+}
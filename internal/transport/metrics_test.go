@@ -0,0 +1,147 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alpstable/gidari/config"
+)
+
+func TestRunMetrics(t *testing.T) {
+	t.Parallel()
+
+	if newRunMetrics(&config.Config{Requests: []*config.Request{{Table: "trades"}}}) != nil {
+		t.Fatalf("expected no metrics without metrics or assertions")
+	}
+
+	reported := &config.Metric{Name: "reported", Path: "$.total_count", Aggregate: config.MetricAggregateMax}
+	volume := &config.Metric{Name: "volume", Path: "$.size"}
+
+	cfg := &config.Config{Requests: []*config.Request{{Table: "trades", Metrics: []*config.Metric{reported, volume}}}}
+	metrics := newRunMetrics(cfg)
+
+	for _, rsp := range []string{
+		`[{"total_count":3,"size":1.5},{"total_count":3,"size":"2"}]`,
+		`{"total_count":"3","size":0.5}`,
+		`[{"id":4}]`,
+	} {
+		records, err := decodeResponseRecords([]byte(rsp))
+		if err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+
+		metrics.addRows("trades", len(records))
+
+		if err := metrics.observe(cfg.Requests[0].Metrics, []byte(rsp), ""); err != nil {
+			t.Fatalf("failed to observe response: %v", err)
+		}
+	}
+
+	if want, got := "reported=3, rows=4, rows.trades=4, volume=4", metrics.summary(); got != want {
+		t.Fatalf("expected summary %q, got %q", want, got)
+	}
+
+	for _, tcase := range []struct {
+		name       string
+		assertions []*config.Assertion
+		failed     []string
+	}{
+		{name: "none"},
+		{
+			name: "hold",
+			assertions: []*config.Assertion{
+				{Metric: "rows.trades", Equals: "reported", Tolerance: 0.5},
+				{Metric: "rows", Min: "4", Max: "volume"},
+				{Metric: "rows.quotes", Equals: "0"},
+			},
+		},
+		{
+			name: "fail",
+			assertions: []*config.Assertion{
+				{Metric: "reported", Equals: config.RowsMetric},
+				{Metric: "volume", Min: "5"},
+				{Metric: "rows", Max: "10"},
+			},
+			failed: []string{
+				"2 of 3 assertions failed",
+				"reported is 3, expected it to equal rows (4)",
+				"volume is 4, expected it to be at least 5 (5)",
+			},
+		},
+	} {
+		tcase := tcase
+
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := metrics.assert(tcase.assertions)
+			if len(tcase.failed) == 0 {
+				if err != nil {
+					t.Fatalf("expected the assertions to hold, got %v", err)
+				}
+
+				return
+			}
+
+			if !errors.Is(err, ErrAssertionFailed) {
+				t.Fatalf("expected %v, got %v", ErrAssertionFailed, err)
+			}
+
+			for _, want := range tcase.failed {
+				if !strings.Contains(err.Error(), want) {
+					t.Fatalf("expected the report to contain %q, got:\n%s", want, err)
+				}
+			}
+		})
+	}
+}
+
+func TestRunMetricsNotRecorded(t *testing.T) {
+	t.Parallel()
+
+	cfg := &config.Config{Requests: []*config.Request{{
+		Table:   "trades",
+		Metrics: []*config.Metric{{Name: "reported", Path: "$.total_count"}},
+	}}}
+
+	err := newRunMetrics(cfg).assert([]*config.Assertion{{Metric: "reported", Min: "1"}})
+	if !errors.Is(err, ErrAssertionFailed) || !strings.Contains(err.Error(), `metric "reported" was not recorded`) {
+		t.Fatalf("expected a failure for a metric that was not recorded, got %v", err)
+	}
+}
+
+func TestRunMetricsCredentialExpiry(t *testing.T) {
+	t.Parallel()
+
+	assertions := []*config.Assertion{{Metric: config.CredentialExpiryMetric, Min: "300"}}
+	metrics := newRunMetrics(&config.Config{Assertions: assertions})
+
+	// Credentials without a known expiry are not recorded.
+	if err := metrics.assert(assertions); err == nil || !strings.Contains(err.Error(), "was not recorded") {
+		t.Fatalf("expected a failure for credentials without an expiry, got %v", err)
+	}
+
+	for _, remaining := range []time.Duration{time.Hour, 290*time.Second + 500*time.Millisecond, 50 * time.Minute} {
+		metrics.addCredentialExpiry(remaining)
+	}
+
+	if want, got := "credentialExpiry=290", metrics.summary(); got != want {
+		t.Fatalf("expected summary %q, got %q", want, got)
+	}
+
+	err := metrics.assert(assertions)
+	if !errors.Is(err, ErrAssertionFailed) || !strings.Contains(err.Error(), "credentialExpiry is 290") {
+		t.Fatalf("expected the assertion to fail, got %v", err)
+	}
+}
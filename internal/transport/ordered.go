@@ -0,0 +1,282 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/tools"
+)
+
+// orderedBatchSize is the maximum number of sorted records upserted at once.
+const orderedBatchSize = 1000
+
+// orderedKey identifies the jobs whose records are sorted together: those written to the same table, sinks and
+// write settings.
+type orderedKey struct {
+	table, sinks, write string
+}
+
+// orderedJobs holds the repository jobs of the tables with an "orderBy" column or "dedupeKeys", in the order they are
+// received.
+type orderedJobs struct {
+	mu   sync.Mutex
+	keys []orderedKey
+	jobs map[orderedKey][]*repoJob
+}
+
+func (ordered *orderedJobs) add(job *repoJob) {
+	ordered.mu.Lock()
+	defer ordered.mu.Unlock()
+
+	// The tables of every request are created and evolved the same way, so how they are is not part of the key.
+	write := job.write
+	write.autoCreate, write.columns, write.evolve = nil, nil, nil
+
+	key := orderedKey{
+		table: job.table,
+		sinks: strings.Join(job.sinks, "\n"),
+		write: fmt.Sprintf("%+v", write),
+	}
+
+	if ordered.jobs == nil {
+		ordered.jobs = make(map[orderedKey][]*repoJob)
+	}
+
+	if _, ok := ordered.jobs[key]; !ok {
+		ordered.keys = append(ordered.keys, key)
+	}
+
+	ordered.jobs[key] = append(ordered.jobs[key], job)
+}
+
+// orderedRecord is a record and the time of its "orderBy" column.
+type orderedRecord struct {
+	data json.RawMessage
+	at   time.Time
+}
+
+// appendRecords will append the records of JSON data, an array of records or a single record, to "records".
+func appendRecords(records []json.RawMessage, data []byte) ([]json.RawMessage, error) {
+	if trimmed := bytes.TrimLeft(data, " \t\r\n"); len(trimmed) == 0 || trimmed[0] != '[' {
+		return append(records, data), nil
+	}
+
+	var batch []json.RawMessage
+	if err := json.Unmarshal(data, &batch); err != nil {
+		return nil, fmt.Errorf("failed to decode records: %w", err)
+	}
+
+	return append(records, batch...), nil
+}
+
+// jobRecords returns the records of a repository job, removing the spill file of the job once it has been read.
+func jobRecords(job *repoJob) ([]json.RawMessage, error) {
+	if job.spill == "" {
+		return appendRecords(nil, job.b)
+	}
+
+	defer os.Remove(job.spill)
+
+	var records []json.RawMessage
+
+	err := readSpill(job.spill, degradedBatchSize, func(data []byte) error {
+		var err error
+
+		records, err = appendRecords(records, data)
+
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading spilled data: %w", err)
+	}
+
+	return records, nil
+}
+
+// mapJobFields will map the fields of the records of a repository job to their columns.
+func mapJobFields(fields map[string]string, records []json.RawMessage) error {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	for idx, record := range records {
+		mapped, err := mapRecordFields(fields, record)
+		if err != nil {
+			return err
+		}
+
+		records[idx] = mapped
+	}
+
+	return nil
+}
+
+// flattenJobRecords will flatten the nested objects of the records of a repository job into columns.
+func flattenJobRecords(flatten config.Flatten, records []json.RawMessage) error {
+	if flatten.Delimiter == "" {
+		return nil
+	}
+
+	for idx, record := range records {
+		flat, err := flattenRecord(flatten, record)
+		if err != nil {
+			return err
+		}
+
+		records[idx] = flat
+	}
+
+	return nil
+}
+
+// recordTime returns the time of the "column" of a record. Records without a time in the column have the zero
+// time, so that they are written before every record that has one.
+func recordTime(data json.RawMessage, column string) time.Time {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var record map[string]interface{}
+	if err := dec.Decode(&record); err != nil {
+		return time.Time{}
+	}
+
+	val := record[column]
+	if num, ok := val.(json.Number); ok {
+		if val, err := num.Float64(); err == nil {
+			return time.Unix(0, int64(val*float64(time.Second)))
+		}
+	}
+
+	at, err := parseExportTime(val)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return at
+}
+
+// sortRecords will sort records from the oldest to the latest time of their "column", keeping the order that they
+// were received in for records of the same time.
+func sortRecords(records []json.RawMessage, column string) []json.RawMessage {
+	sorted := make([]orderedRecord, len(records))
+	for idx, data := range records {
+		sorted[idx] = orderedRecord{data: data, at: recordTime(data, column)}
+	}
+
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].at.Before(sorted[j].at) })
+
+	out := make([]json.RawMessage, len(sorted))
+	for idx, record := range sorted {
+		out[idx] = record.data
+	}
+
+	return out
+}
+
+// writeOrdered will write the records of the ordered jobs of a batch, from the oldest to the latest time of their
+// "orderBy" column. Since the records are sent to the transactions in order, the latest record of a key is the one
+// that is committed. Tables with "dedupeKeys" only have the latest copy of each key written.
+func writeOrdered(cfg *repoConfig) error {
+	cfg.ordered.mu.Lock()
+	defer cfg.ordered.mu.Unlock()
+
+	for _, key := range cfg.ordered.keys {
+		start := time.Now()
+		jobs := cfg.ordered.jobs[key]
+
+		var records []json.RawMessage
+
+		for _, job := range jobs {
+			data, err := jobRecords(job)
+			if err != nil {
+				return err
+			}
+
+			// The "orderBy" column is named after the fields are mapped and flattened, so they are mapped and
+			// flattened before the records are sorted.
+			if err := mapJobFields(job.write.fields, data); err != nil {
+				return err
+			}
+
+			if err := flattenJobRecords(job.write.flatten, data); err != nil {
+				return err
+			}
+
+			// The records of every job have the boundaries of their own chunk, and their own provenance.
+			if err := annotateRecords(job.write.chunkColumns, job.chunk, data); err != nil {
+				return err
+			}
+
+			if err := annotateProvenanceRecords(job.write.provenance, job.provenance, data); err != nil {
+				return err
+			}
+
+			records = append(records, data...)
+		}
+
+		job := *jobs[0]
+		job.chunk, job.provenance, job.write.fields, job.write.flatten = nil, nil, nil, config.Flatten{}
+
+		msg := fmt.Sprintf("buffered %d records of %s", len(records), job.table)
+		if job.write.orderBy != "" {
+			records = sortRecords(records, job.write.orderBy)
+			msg = fmt.Sprintf("sorted %d records of %s by %q", len(records), job.table, job.write.orderBy)
+		}
+
+		records, dropped := dedupeRecords(records, job.write.dedupeKeys)
+		if len(job.write.dedupeKeys) != 0 {
+			msg = fmt.Sprintf("%s, dropping %d earlier copies of their %s keys", msg, dropped,
+				strings.Join(job.write.dedupeKeys, ", "))
+		}
+
+		for _, chunk := range chunkRecords(records, orderedBatchSize) {
+			data, err := json.Marshal(chunk)
+			if err != nil {
+				return fmt.Errorf("failed to marshal ordered records: %w", err)
+			}
+
+			upsertRepos(0, cfg, &job, job.upsertRequest(data))
+		}
+
+		logInfo := tools.LogFormatter{
+			WorkerName: "repository",
+			Duration:   time.Since(start),
+			Msg:        msg,
+		}
+		cfg.logger.Infof(logInfo.String())
+	}
+
+	cfg.ordered.keys, cfg.ordered.jobs = nil, nil
+
+	return nil
+}
+
+// chunkRecords will split records into chunks of at most "size" records.
+func chunkRecords(records []json.RawMessage, size int) [][]json.RawMessage {
+	var chunks [][]json.RawMessage
+
+	for len(records) > size {
+		chunks = append(chunks, records[:size])
+		records = records[size:]
+	}
+
+	if len(records) > 0 {
+		chunks = append(chunks, records)
+	}
+
+	return chunks
+}
@@ -0,0 +1,154 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSortRecords(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name    string
+		records []string
+		want    []string
+	}{
+		{
+			name:    "timestamps",
+			records: []string{`{"id":1,"t":"2022-05-03T00:00:00Z"}`, `{"id":1,"t":"2022-05-01T00:00:00Z"}`},
+			want:    []string{`{"id":1,"t":"2022-05-01T00:00:00Z"}`, `{"id":1,"t":"2022-05-03T00:00:00Z"}`},
+		},
+		{
+			name:    "timezones",
+			records: []string{`{"t":"2022-05-01T02:00:00+03:00"}`, `{"t":"2022-05-01T00:00:00Z"}`},
+			want:    []string{`{"t":"2022-05-01T02:00:00+03:00"}`, `{"t":"2022-05-01T00:00:00Z"}`},
+		},
+		{
+			name:    "unix seconds",
+			records: []string{`{"t":1651363200.5}`, `{"t":1651363200}`, `{"t":"1651363100"}`},
+			want:    []string{`{"t":"1651363100"}`, `{"t":1651363200}`, `{"t":1651363200.5}`},
+		},
+		{
+			name:    "missing times are first",
+			records: []string{`{"t":"2022-05-01"}`, `{"id":2}`, `"scalar"`},
+			want:    []string{`{"id":2}`, `"scalar"`, `{"t":"2022-05-01"}`},
+		},
+		{
+			name:    "stable",
+			records: []string{`{"id":2,"t":"2022-05-01"}`, `{"id":1,"t":"2022-05-01"}`},
+			want:    []string{`{"id":2,"t":"2022-05-01"}`, `{"id":1,"t":"2022-05-01"}`},
+		},
+	} {
+		tcase := tcase
+
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			records := make([]json.RawMessage, len(tcase.records))
+			for idx, record := range tcase.records {
+				records[idx] = json.RawMessage(record)
+			}
+
+			var got []string
+			for _, record := range sortRecords(records, "t") {
+				got = append(got, string(record))
+			}
+
+			if !reflect.DeepEqual(got, tcase.want) {
+				t.Fatalf("expected %v, got %v", tcase.want, got)
+			}
+		})
+	}
+}
+
+func TestJobRecords(t *testing.T) {
+	t.Parallel()
+
+	spill := filepath.Join(t.TempDir(), "spill.json")
+	if err := os.WriteFile(spill, []byte(` [{"id":1}, {"id":2}]`), 0o600); err != nil {
+		t.Fatalf("failed to write spill file: %v", err)
+	}
+
+	for _, tcase := range []struct {
+		name string
+		job  *repoJob
+		want []string
+	}{
+		{name: "array", job: &repoJob{b: []byte(`[{"id":1},{"id":2}]`)}, want: []string{`{"id":1}`, `{"id":2}`}},
+		{name: "record", job: &repoJob{b: []byte(`{"id":1}`)}, want: []string{`{"id":1}`}},
+		{name: "spilled", job: &repoJob{spill: spill}, want: []string{`{"id":1}`, `{"id":2}`}},
+	} {
+		records, err := jobRecords(tcase.job)
+		if err != nil {
+			t.Fatalf("%s: failed to read records: %v", tcase.name, err)
+		}
+
+		var got []string
+		for _, record := range records {
+			got = append(got, string(record))
+		}
+
+		if !reflect.DeepEqual(got, tcase.want) {
+			t.Fatalf("%s: expected %v, got %v", tcase.name, tcase.want, got)
+		}
+	}
+
+	if _, err := os.Stat(spill); !os.IsNotExist(err) {
+		t.Fatalf("expected the spill file to be removed, got %v", err)
+	}
+}
+
+func TestOrderedJobs(t *testing.T) {
+	t.Parallel()
+
+	ordered := new(orderedJobs)
+	write := tableWrite{orderBy: "t"}
+
+	first := &repoJob{table: "trades", write: write}
+	other := &repoJob{table: "trades", sinks: []string{"postgresql://localhost"}, write: write}
+	second := &repoJob{table: "trades", write: write}
+
+	for _, job := range []*repoJob{first, other, second} {
+		ordered.add(job)
+	}
+
+	if len(ordered.keys) != 2 {
+		t.Fatalf("expected the jobs of each sink to be sorted separately, got %v", ordered.keys)
+	}
+
+	if got := ordered.jobs[ordered.keys[0]]; !reflect.DeepEqual(got, []*repoJob{first, second}) {
+		t.Fatalf("expected the jobs to be held in the order they were received, got %v", got)
+	}
+}
+
+func TestChunkRecords(t *testing.T) {
+	t.Parallel()
+
+	records := []json.RawMessage{json.RawMessage(`1`), json.RawMessage(`2`), json.RawMessage(`3`)}
+
+	for _, tcase := range []struct {
+		size int
+		want int
+	}{
+		{size: 1, want: 3},
+		{size: 2, want: 2},
+		{size: 3, want: 1},
+		{size: 10, want: 1},
+	} {
+		if got := chunkRecords(records, tcase.size); len(got) != tcase.want {
+			t.Fatalf("expected %d chunks of size %d, got %d", tcase.want, tcase.size, len(got))
+		}
+	}
+}
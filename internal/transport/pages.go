@@ -0,0 +1,194 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/internal/proto"
+	"github.com/alpstable/gidari/internal/web"
+)
+
+// pagesTableSuffix is appended to a request's table name to get the name of the table that page metadata is recorded
+// in.
+const pagesTableSuffix = "_pages"
+
+// pagesTable returns the name of the side table that page metadata for "table" is recorded in.
+func pagesTable(table string) string {
+	return table + pagesTableSuffix
+}
+
+// pageColumns are the columns of a "<table>_pages" side table, which are created with the table even if the records
+// that it is created from omit them.
+var pageColumns = []*proto.Column{
+	{Name: "id", Kind: proto.KindString},
+	{Name: "table", Kind: proto.KindString},
+	{Name: "method", Kind: proto.KindString},
+	{Name: "url", Kind: proto.KindString},
+	{Name: "query", Kind: proto.KindString},
+	{Name: "body", Kind: proto.KindString},
+	{Name: "page", Kind: proto.KindInteger},
+	{Name: "chunk_start", Kind: proto.KindTimestamp},
+	{Name: "chunk_end", Kind: proto.KindTimestamp},
+	{Name: "item_count", Kind: proto.KindInteger},
+	{Name: "status_code", Kind: proto.KindInteger},
+	{Name: "response_time_ms", Kind: proto.KindInteger},
+	{Name: "fetched_at", Kind: proto.KindTimestamp},
+	{Name: "encryption_key_id", Kind: proto.KindString},
+}
+
+// pagesWrite returns how the page metadata of a target request is written. The side table is keyed by the ID of
+// the page, and is created with the type mapping of the request.
+func pagesWrite(target *flattenedRequest) tableWrite {
+	if target.write.autoCreate == nil {
+		return tableWrite{}
+	}
+
+	return tableWrite{
+		autoCreate: &config.AutoCreate{PrimaryKeys: []string{"id"}, TypeMapping: target.write.autoCreate.TypeMapping},
+		columns:    pageColumns,
+	}
+}
+
+// pageRecord is the metadata recorded for every page fetched by a request with "recordPages" enabled. These records
+// make it possible to diagnose gaps in the transported data after the fact.
+type pageRecord struct {
+	// ID is a hash of the HTTP request, so that re-running a transport updates the existing page record rather
+	// than duplicating it.
+	ID string `json:"id"`
+
+	Table  string `json:"table"`
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	Query  string `json:"query,omitempty"`
+	Body   string `json:"body,omitempty"`
+
+	// Page is the 1-indexed position of the page within the request, e.g. the timeseries chunk.
+	Page int `json:"page"`
+
+	ChunkStart *time.Time `json:"chunk_start,omitempty"`
+	ChunkEnd   *time.Time `json:"chunk_end,omitempty"`
+
+	ItemCount      int       `json:"item_count"`
+	StatusCode     int       `json:"status_code"`
+	ResponseTimeMS int64     `json:"response_time_ms"`
+	FetchedAt      time.Time `json:"fetched_at"`
+
+	// EncryptionKeyID is the ID of the key that the "encrypt" columns of the records of the page were encrypted
+	// with, if any.
+	EncryptionKeyID string `json:"encryption_key_id,omitempty"`
+}
+
+// newPageRecord will build the page metadata for a target request from the response to its fetch.
+func newPageRecord(target *flattenedRequest, rsp *web.FetchResponse, items int, elapsed time.Duration,
+	fetchedAt time.Time,
+) *pageRecord {
+	sum := sha256.Sum256([]byte(target.table + " " + target.fetchKey()))
+
+	record := &pageRecord{
+		ID:             hex.EncodeToString(sum[:]),
+		Table:          target.table,
+		Method:         target.fetchConfig.Method,
+		URL:            target.fetchConfig.URL.String(),
+		Query:          target.fetchConfig.URL.RawQuery,
+		Body:           string(target.fetchConfig.Body),
+		Page:           target.page,
+		ItemCount:      items,
+		StatusCode:     rsp.StatusCode,
+		ResponseTimeMS: elapsed.Milliseconds(),
+		FetchedAt:      fetchedAt.UTC(),
+	}
+
+	if record.Page == 0 {
+		record.Page = 1
+	}
+
+	if target.chunk != nil {
+		start, end := target.chunk[0].UTC(), target.chunk[1].UTC()
+		record.ChunkStart, record.ChunkEnd = &start, &end
+	}
+
+	if target.write.encrypt != nil {
+		record.EncryptionKeyID = target.write.encrypt.KeyID
+	}
+
+	return record
+}
+
+// countResponseRecords will count the number of records in a JSON response, streaming the data from disk if it was
+// spilled.
+func countResponseRecords(data []byte, spilled string) (int, error) {
+	if spilled == "" {
+		records, err := decodeResponseRecords(data)
+		if err != nil {
+			return 0, err
+		}
+
+		return len(records), nil
+	}
+
+	count := 0
+
+	err := readSpill(spilled, degradedBatchSize, func(batch []byte) error {
+		records, err := decodeResponseRecords(batch)
+		if err != nil {
+			return err
+		}
+
+		count += len(records)
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// recordsPages returns true if any of the targets record page metadata.
+func recordsPages(targets []*flattenedRequest) bool {
+	for _, target := range targets {
+		if target.recordPages {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sendPageRecords will put the page metadata for every target with "recordPages" enabled onto the repository job
+// channel.
+func sendPageRecords(job *webJob, targets []*flattenedRequest, rsp *web.FetchResponse, items int,
+	elapsed time.Duration, fetchedAt time.Time,
+) {
+	for _, target := range targets {
+		if !target.recordPages {
+			continue
+		}
+
+		bytes, err := json.Marshal(newPageRecord(target, rsp, items, elapsed, fetchedAt))
+		if err != nil {
+			job.logger.Errorf("failed to marshal page record: %s", err)
+
+			continue
+		}
+
+		job.send(&repoJob{
+			b:     bytes,
+			req:   *rsp.Request,
+			table: pagesTable(target.table),
+			sinks: target.sinks,
+			write: pagesWrite(target),
+		})
+	}
+}
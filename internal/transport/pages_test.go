@@ -0,0 +1,110 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/alpstable/gidari/internal/web"
+)
+
+func TestNewPageRecord(t *testing.T) {
+	t.Parallel()
+
+	rurl, _ := url.Parse("https://api.example.com/candles?start=1&end=2")
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+	fetchedAt := time.Date(2022, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	for _, tcase := range []struct {
+		name   string
+		target *flattenedRequest
+		page   int
+		chunk  bool
+	}{
+		{
+			name: "request",
+			target: &flattenedRequest{
+				fetchConfig: &web.FetchConfig{Method: http.MethodGet, URL: rurl},
+				table:       "candles",
+			},
+			page: 1,
+		},
+		{
+			name: "timeseries chunk",
+			target: &flattenedRequest{
+				fetchConfig: &web.FetchConfig{Method: http.MethodGet, URL: rurl},
+				table:       "candles",
+				page:        3,
+				chunk:       &[2]time.Time{start, end},
+			},
+			page:  3,
+			chunk: true,
+		},
+	} {
+		tcase := tcase
+
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			rsp := &web.FetchResponse{StatusCode: http.StatusOK}
+			record := newPageRecord(tcase.target, rsp, 5, 1500*time.Millisecond, fetchedAt)
+
+			if record.ID == "" {
+				t.Fatalf("expected id to be set")
+			}
+
+			if record.Page != tcase.page {
+				t.Fatalf("expected page %d, got %d", tcase.page, record.Page)
+			}
+
+			if record.Query != "start=1&end=2" {
+				t.Fatalf("unexpected query: %q", record.Query)
+			}
+
+			if record.ItemCount != 5 || record.StatusCode != http.StatusOK || record.ResponseTimeMS != 1500 {
+				t.Fatalf("unexpected record: %+v", record)
+			}
+
+			if tcase.chunk != (record.ChunkStart != nil) {
+				t.Fatalf("expected chunk set to be %v", tcase.chunk)
+			}
+
+			if tcase.chunk && (!record.ChunkStart.Equal(start) || !record.ChunkEnd.Equal(end)) {
+				t.Fatalf("unexpected chunk: %v - %v", record.ChunkStart, record.ChunkEnd)
+			}
+		})
+	}
+}
+
+func TestCountResponseRecords(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		data string
+		want int
+	}{
+		{data: `[]`, want: 0},
+		{data: `[{"id":1},{"id":2}]`, want: 2},
+		{data: `{"id":1}`, want: 1},
+	} {
+		count, err := countResponseRecords([]byte(tcase.data), "")
+		if err != nil {
+			t.Fatalf("failed to count records: %v", err)
+		}
+
+		if count != tcase.want {
+			t.Fatalf("expected %d records in %s, got %d", tcase.want, tcase.data, count)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/internal/proto"
+	"github.com/alpstable/gidari/internal/repository"
+	"github.com/alpstable/gidari/tools"
+)
+
+// partitionable returns true if a repository can be written with several transactions in parallel. Only storage
+// with row locks is: SQLite locks the entire database for each transaction that writes to it.
+func partitionable(repo repository.Generic) bool {
+	switch repo.Type() {
+	case proto.PostgresType, proto.MySQLType:
+		return true
+	default:
+		return false
+	}
+}
+
+// partitionRepos will start the transactions that the PostgreSQL and MySQL repositories are written with in
+// addition to their own, keyed by the index of the repository, and return a function that closes them.
+func partitionRepos(ctx context.Context, cfg *config.Config, repos []repository.Generic,
+) (map[int][]repository.Generic, repoCloser, error) {
+	partitions := make(map[int][]repository.Generic)
+
+	closePartitions := func() {
+		for _, txs := range partitions {
+			for _, repo := range txs {
+				repo.Close()
+			}
+		}
+	}
+
+	if cfg.Partitions <= 1 {
+		return partitions, closePartitions, nil
+	}
+
+	for idx, repo := range repos {
+		if !partitionable(repo) {
+			continue
+		}
+
+		for part := 1; part < cfg.Partitions; part++ {
+			tx, err := repository.NewTx(ctx, cfg.ConnectionStrings[idx])
+			if err != nil {
+				closePartitions()
+
+				return nil, nil, fmt.Errorf("failed to create partition repository: %w", err)
+			}
+
+			partitions[idx] = append(partitions[idx], tx)
+		}
+
+		logInfo := tools.LogFormatter{
+			Msg: fmt.Sprintf("created %d partitions for %s", cfg.Partitions, sinkName(idx, repo)),
+		}
+		cfg.Logger.Info(logInfo.String())
+	}
+
+	return partitions, closePartitions, nil
+}
+
+// partitionKey returns the values of the "keys" of a record, which identify the rows that it is written to.
+func partitionKey(record json.RawMessage, keys []string) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(record))
+	dec.UseNumber()
+
+	var obj map[string]interface{}
+	if err := dec.Decode(&obj); err != nil {
+		return nil, fmt.Errorf("failed to decode record: %w", err)
+	}
+
+	values := make([]interface{}, len(keys))
+	for idx, key := range keys {
+		values[idx] = obj[key]
+	}
+
+	key, err := json.Marshal(values)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode partition key: %w", err)
+	}
+
+	return key, nil
+}
+
+// partitionData will split JSON data, an array of records or a single record, into "count" arrays of records by the
+// hash of the values of their "keys", so that the records of a row are always in the same partition. Partitions
+// without records are nil.
+func partitionData(data []byte, keys []string, count int) ([][]byte, error) {
+	records, err := appendRecords(nil, data)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := make([][]json.RawMessage, count)
+
+	for _, record := range records {
+		key, err := partitionKey(record, keys)
+		if err != nil {
+			return nil, err
+		}
+
+		hash := fnv.New32a()
+		hash.Write(key)
+
+		part := hash.Sum32() % uint32(count)
+		parts[part] = append(parts[part], record)
+	}
+
+	out := make([][]byte, count)
+
+	for idx, part := range parts {
+		if len(part) == 0 {
+			continue
+		}
+
+		if out[idx], err = json.Marshal(part); err != nil {
+			return nil, fmt.Errorf("failed to marshal partition: %w", err)
+		}
+	}
+
+	return out, nil
+}
+
+// partitionRequests returns the transactions that an upsert request of a job is written to on the repository with
+// index "idx", and the request for each of them.
+//
+// Tables are only partitioned when they are not created or truncated in the batch, since the transaction that does
+// so locks the table until it is committed, and when the columns that identify their records are known. Anything
+// else is written with the transaction of the repository itself.
+func partitionRequests(cfg *repoConfig, idx int, job *repoJob, req *proto.UpsertRequest,
+) ([]repository.Generic, []*proto.UpsertRequest) {
+	repo := cfg.repos[idx]
+
+	txs := cfg.partitions[idx]
+	if len(txs) == 0 || len(job.write.partitionKeys) == 0 || job.write.autoCreate != nil ||
+		cfg.truncating[job.table] {
+		return []repository.Generic{repo}, []*proto.UpsertRequest{req}
+	}
+
+	txs = append([]repository.Generic{repo}, txs...)
+
+	// Data that cannot be partitioned fails its upsert on the transaction of the repository itself.
+	parts, err := partitionData(req.Data, job.write.partitionKeys, len(txs))
+	if err != nil {
+		return []repository.Generic{repo}, []*proto.UpsertRequest{req}
+	}
+
+	var (
+		partTxs  []repository.Generic
+		partReqs []*proto.UpsertRequest
+	)
+
+	for part, data := range parts {
+		if data == nil {
+			continue
+		}
+
+		partTxs = append(partTxs, txs[part])
+		partReqs = append(partReqs, job.upsertRequest(data))
+	}
+
+	return partTxs, partReqs
+}
+
+// commitRepo will commit the transaction of the repository with index "idx", and those of its partitions, returning
+// the first error. Each partition is committed on its own, so the partitions that can be committed are, even once
+// another has failed.
+func commitRepo(cfg *repoConfig, sinks *sinkLedger, idx int, repo repository.Generic) error {
+	var commitErr error
+
+	for _, tx := range append([]repository.Generic{repo}, cfg.partitions[idx]...) {
+		err := tx.Commit()
+		sinks.commit(idx, sinkName(idx, repo), err)
+
+		if err != nil && commitErr == nil {
+			commitErr = err
+		}
+	}
+
+	return commitErr
+}
@@ -0,0 +1,129 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/alpstable/gidari/internal/proto"
+	"github.com/alpstable/gidari/internal/repository"
+)
+
+func TestPartitionData(t *testing.T) {
+	t.Parallel()
+
+	var records []string
+	for idx := 0; idx < 20; idx++ {
+		records = append(records, fmt.Sprintf(`{"id":%d,"venue":"a","price":%d}`, idx%10, idx))
+	}
+
+	data := []byte("[" + strings.Join(records, ",") + "]")
+
+	parts, err := partitionData(data, []string{"id", "venue"}, 3)
+	if err != nil {
+		t.Fatalf("failed to partition data: %v", err)
+	}
+
+	seen := make(map[interface{}]int)
+	total := 0
+
+	for part, data := range parts {
+		if data == nil {
+			continue
+		}
+
+		var got []map[string]interface{}
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("failed to decode partition: %v", err)
+		}
+
+		for _, record := range got {
+			if prev, ok := seen[record["id"]]; ok && prev != part {
+				t.Fatalf("expected the records of id %v to be in one partition, got %d and %d", record["id"], prev,
+					part)
+			}
+
+			seen[record["id"]] = part
+			total++
+		}
+	}
+
+	if total != len(records) || len(seen) != 10 {
+		t.Fatalf("expected every record to be partitioned, got %d of %d", total, len(records))
+	}
+
+	if _, err := partitionData([]byte(`[1,2]`), []string{"id"}, 2); err == nil {
+		t.Fatal("expected records that are not objects to fail")
+	}
+}
+
+func TestPartitionRequests(t *testing.T) {
+	t.Parallel()
+
+	repo := new(repository.GenericService)
+	partitions := []repository.Generic{new(repository.GenericService), new(repository.GenericService)}
+
+	cfg := &repoConfig{
+		repos:      []repository.Generic{repo},
+		partitions: map[int][]repository.Generic{0: partitions},
+		truncating: map[string]bool{"quotes": true},
+	}
+
+	var records []string
+	for idx := 0; idx < 30; idx++ {
+		records = append(records, fmt.Sprintf(`{"id":%d}`, idx))
+	}
+
+	data := []byte("[" + strings.Join(records, ",") + "]")
+	write := tableWrite{partitionKeys: []string{"id"}}
+
+	for _, tcase := range []struct {
+		name string
+		job  *repoJob
+		want int
+	}{
+		{name: "partitioned", job: &repoJob{table: "trades", write: write}, want: 3},
+		{name: "truncated", job: &repoJob{table: "quotes", write: write}, want: 1},
+		{name: "no keys", job: &repoJob{table: "trades"}, want: 1},
+	} {
+		txs, reqs := partitionRequests(cfg, 0, tcase.job, tcase.job.upsertRequest(data))
+		if len(txs) != tcase.want || len(reqs) != tcase.want {
+			t.Fatalf("%s: expected %d transactions, got %d", tcase.name, tcase.want, len(txs))
+		}
+
+		written := 0
+
+		for _, req := range reqs {
+			var got []json.RawMessage
+			if err := json.Unmarshal(req.Data, &got); err != nil {
+				t.Fatalf("%s: failed to decode request: %v", tcase.name, err)
+			}
+
+			if req.Table != tcase.job.table {
+				t.Fatalf("%s: expected table %q, got %q", tcase.name, tcase.job.table, req.Table)
+			}
+
+			written += len(got)
+		}
+
+		if written != len(records) {
+			t.Fatalf("%s: expected every record to be written, got %d", tcase.name, written)
+		}
+	}
+
+	// Repositories without partitions are written with their own transaction.
+	if txs, _ := partitionRequests(&repoConfig{repos: []repository.Generic{repo}}, 0, &repoJob{write: write},
+		&proto.UpsertRequest{Data: data}); len(txs) != 1 || txs[0] != repo {
+		t.Fatalf("expected the transaction of the repository, got %v", txs)
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/alpstable/gidari/internal/web"
+)
+
+// prefetchWindow fetches the upcoming chunks of a timeseries request ahead of their web jobs, so that the rate-limiter
+// wait and the request of the next chunks overlap with the decoding and upserting of the current one. Every chunk is
+// still fetched once, through the rate limiter of the request, so the rate of requests is unchanged.
+type prefetchWindow struct {
+	mu sync.Mutex
+
+	// size is the largest number of chunks that are fetched ahead of their web jobs at once.
+	size int
+
+	// chunks are the chunks of the request, in order.
+	chunks []*flattenedRequest
+
+	// queued are the chunks whose web jobs are queued in the batch and have not started, which are the only
+	// chunks that are prefetched.
+	queued map[*flattenedRequest]bool
+
+	// fetches are the chunks that are prefetched, which have not been claimed by their web jobs.
+	fetches map[*flattenedRequest]*prefetchedFetch
+}
+
+// newPrefetchWindow returns the prefetch window of the chunks of a request, or nil if "size" is not positive.
+func newPrefetchWindow(size int, chunks []*flattenedRequest) *prefetchWindow {
+	if size <= 0 || len(chunks) < 2 {
+		return nil
+	}
+
+	win := &prefetchWindow{
+		size:    size,
+		chunks:  chunks,
+		queued:  make(map[*flattenedRequest]bool),
+		fetches: make(map[*flattenedRequest]*prefetchedFetch),
+	}
+
+	for _, chunk := range chunks {
+		chunk.prefetch = win
+	}
+
+	return win
+}
+
+// queue will make the chunk of a web job that is queued in the batch eligible to be prefetched.
+func (win *prefetchWindow) queue(chunk *flattenedRequest) {
+	if win == nil {
+		return
+	}
+
+	win.mu.Lock()
+	defer win.mu.Unlock()
+
+	win.queued[chunk] = true
+}
+
+// claim is called when the web job of a chunk starts. It returns the fetch of the chunk if it was prefetched, and
+// otherwise keeps the chunk from being prefetched, since its web job fetches it.
+func (win *prefetchWindow) claim(chunk *flattenedRequest) *prefetchedFetch {
+	if win == nil {
+		return nil
+	}
+
+	win.mu.Lock()
+	defer win.mu.Unlock()
+
+	delete(win.queued, chunk)
+
+	pf, ok := win.fetches[chunk]
+	if !ok {
+		return nil
+	}
+
+	delete(win.fetches, chunk)
+
+	return pf
+}
+
+// ahead will prefetch the queued chunks that follow the chunk of a web job, whose response has arrived, until the
+// window is full. Chunks are only prefetched while the responses of the web API take longer than their wait on the
+// rate limiter, since a request that is held back by the rate limit gains nothing by waiting on it earlier.
+func (win *prefetchWindow) ahead(ctx context.Context, job *webJob, fetched *prefetchResult) {
+	if win == nil || ctx.Err() != nil || fetched.err != nil {
+		return
+	}
+
+	if wait := fetched.rsp.RateLimitWait; fetched.took-wait <= wait {
+		return
+	}
+
+	win.mu.Lock()
+	defer win.mu.Unlock()
+
+	idx := 0
+	for idx < len(win.chunks) && win.chunks[idx] != job.flattenedRequest {
+		idx++
+	}
+
+	for next := idx + 1; next < len(win.chunks) && next <= idx+win.size; next++ {
+		if len(win.fetches) >= win.size {
+			return
+		}
+
+		chunk := win.chunks[next]
+		if !win.queued[chunk] {
+			continue
+		}
+
+		// The prefetched request is reserved against the budget in place of the web job of the chunk.
+		if !job.budget.reserve(chunk.cost) {
+			return
+		}
+
+		delete(win.queued, chunk)
+
+		chunkJob := *job
+		chunkJob.flattenedRequest = chunk
+
+		pf := &prefetchedFetch{done: make(chan struct{})}
+		win.fetches[chunk] = pf
+
+		go func() {
+			defer close(pf.done)
+
+			pf.result = timedFetch(ctx, &chunkJob)
+		}()
+	}
+}
+
+// prefetchResult is the response of a fetch, and how long it took.
+type prefetchResult struct {
+	rsp      *web.FetchResponse
+	attempts int
+	err      error
+	took     time.Duration
+}
+
+// timedFetch will make the request of a web job, timing it.
+func timedFetch(ctx context.Context, job *webJob) *prefetchResult {
+	began := job.clock.Now()
+	rsp, attempts, err := fetch(ctx, job)
+
+	return &prefetchResult{rsp: rsp, attempts: attempts, err: err, took: job.clock.Now().Sub(began)}
+}
+
+// prefetchedFetch is the fetch of a chunk that was made ahead of its web job.
+type prefetchedFetch struct {
+	done   chan struct{}
+	result *prefetchResult
+	used   bool
+}
+
+// discard will close the response of a prefetched chunk whose web job did not use it, e.g. because the run was
+// interrupted, and count the requests that were made for it.
+func (pf *prefetchedFetch) discard(job *webJob) {
+	if pf == nil || pf.used {
+		return
+	}
+
+	<-pf.done
+
+	job.spend.add(job.requestKey, pf.result.attempts)
+
+	if pf.result.rsp != nil {
+		pf.result.rsp.Body.Close()
+	}
+}
+
+// fetchChunk will make the request of a web job, or wait for the response of its chunk if it was prefetched, and then
+// prefetch the chunks that follow it.
+func fetchChunk(ctx context.Context, job *webJob, pf *prefetchedFetch) (*web.FetchResponse, int, error) {
+	var fetched *prefetchResult
+
+	if pf != nil {
+		<-pf.done
+
+		pf.used = true
+		fetched = pf.result
+	} else {
+		fetched = timedFetch(ctx, job)
+	}
+
+	job.prefetch.ahead(ctx, job, fetched)
+
+	return fetched.rsp, fetched.attempts, fetched.err
+}
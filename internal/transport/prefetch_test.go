@@ -0,0 +1,136 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alpstable/gidari/internal/web"
+	"github.com/alpstable/gidari/tools"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+)
+
+func TestPrefetchWindow(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu   sync.Mutex
+		hits = make(map[string]int)
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		hits[req.URL.Path]++
+		mu.Unlock()
+
+		// Responses take longer than the wait on the rate limiter, so chunks are prefetched.
+		time.Sleep(5 * time.Millisecond)
+		fmt.Fprint(rw, "[]")
+	}))
+	t.Cleanup(srv.Close)
+
+	client, _ := web.NewClient(context.Background(), nil)
+	limiter := rate.NewLimiter(rate.Inf, 1)
+
+	chunks := make([]*flattenedRequest, 4)
+	for idx := range chunks {
+		rurl, _ := url.Parse(fmt.Sprintf("%s/%d", srv.URL, idx+1))
+		chunks[idx] = &flattenedRequest{
+			fetchConfig: &web.FetchConfig{C: client, Method: http.MethodGet, URL: rurl, RateLimiter: limiter},
+			page:        idx + 1,
+			requestKey:  "GET /candles candles",
+		}
+	}
+
+	win := newPrefetchWindow(2, chunks)
+	for _, chunk := range chunks {
+		win.queue(chunk)
+	}
+
+	ctx := context.Background()
+
+	newJob := func(chunk *flattenedRequest) *webJob {
+		return &webJob{
+			flattenedRequest: chunk,
+			runResources:     &runResources{},
+			logger:           logrus.New(),
+			clock:            tools.ClockOrReal(nil),
+		}
+	}
+
+	job := newJob(chunks[0])
+
+	rsp, _, err := fetchChunk(ctx, job, win.claim(chunks[0]))
+	if err != nil {
+		t.Fatalf("failed to fetch the first chunk: %v", err)
+	}
+
+	rsp.Body.Close()
+
+	// The next two chunks are prefetched, and the last is left for its web job.
+	for idx, prefetched := range []bool{false, true, true, false} {
+		if _, ok := win.fetches[chunks[idx]]; ok != prefetched {
+			t.Fatalf("chunk %d: expected prefetched %v, got %v", idx+1, prefetched, ok)
+		}
+	}
+
+	second := win.claim(chunks[1])
+	if second == nil {
+		t.Fatalf("expected the second chunk to be prefetched")
+	}
+
+	rsp, _, err = fetchChunk(ctx, newJob(chunks[1]), second)
+	if err != nil {
+		t.Fatalf("failed to fetch the second chunk: %v", err)
+	}
+
+	if rsp.Request.URL.Path != "/2" {
+		t.Fatalf("expected the response of the second chunk, got %s", rsp.Request.URL.Path)
+	}
+
+	rsp.Body.Close()
+
+	// The web job of the third chunk does not use it, e.g. because the run was interrupted.
+	third := win.claim(chunks[2])
+	third.discard(newJob(chunks[2]))
+
+	if pf := win.claim(chunks[3]); pf != nil {
+		pf.discard(newJob(chunks[3]))
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	for path, count := range hits {
+		if count != 1 {
+			t.Fatalf("expected %s to be requested once, got %d", path, count)
+		}
+	}
+
+	// Requests that are held back by the rate limit are not prefetched.
+	slow := newPrefetchWindow(2, []*flattenedRequest{{}, {}})
+	slow.queue(slow.chunks[1])
+	slow.ahead(ctx, newJob(slow.chunks[0]), &prefetchResult{
+		rsp:  &web.FetchResponse{RateLimitWait: time.Second},
+		took: 1500 * time.Millisecond,
+	})
+
+	if len(slow.fetches) != 0 {
+		t.Fatalf("expected no chunks to be prefetched while waiting on the rate limit, got %d", len(slow.fetches))
+	}
+}
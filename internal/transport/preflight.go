@@ -0,0 +1,186 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/internal/proto"
+	"github.com/alpstable/gidari/internal/repository"
+	"github.com/alpstable/gidari/internal/web"
+	"github.com/alpstable/gidari/tools"
+	"golang.org/x/time/rate"
+)
+
+// preflightTimeout is how long each pre-flight check waits for a source or storage target to respond.
+const preflightTimeout = 10 * time.Second
+
+// ErrPreflightFailed is returned when a source or storage target fails its pre-flight check.
+var ErrPreflightFailed = fmt.Errorf("preflight failed")
+
+// preflightCheck is a single source or storage target to check before the run starts.
+type preflightCheck struct {
+	// name identifies the target in the report. It does not include credentials, such as the query of a URL or
+	// the user info of a connection string.
+	name  string
+	check func(ctx context.Context) error
+
+	err error
+}
+
+// sourceChecks returns a check for every distinct URL that the configured requests fetch. The check makes a HEAD
+// request with the authentication of the run, so that both reachability and credentials are checked without
+// fetching any data.
+func sourceChecks(cfg *config.Config, client *web.Client) []*preflightCheck {
+	var checks []*preflightCheck
+
+	seen := make(map[string]bool)
+
+	for _, req := range cfg.Requests {
+		fetchConfig := newFetchConfig(req, *cfg.URL, client)
+		if seen[fetchConfig.URL.String()] {
+			continue
+		}
+
+		seen[fetchConfig.URL.String()] = true
+
+		// The pre-flight checks are not limited by the request's rate limiter, which would hold back the run.
+		fetchConfig.Method = http.MethodHead
+		fetchConfig.RateLimiter = rate.NewLimiter(rate.Inf, 1)
+		fetchConfig.Body = nil
+
+		name := fmt.Sprintf("source %s://%s%s", fetchConfig.URL.Scheme, fetchConfig.URL.Host, fetchConfig.URL.Path)
+
+		checks = append(checks, &preflightCheck{name: name, check: func(ctx context.Context) error {
+			return checkSource(ctx, fetchConfig)
+		}})
+	}
+
+	return checks
+}
+
+// checkSource will make the pre-flight request to a source. Rate limit responses are considered reachable, since
+// the run waits on its rate limiter.
+func checkSource(ctx context.Context, fetchConfig *web.FetchConfig) error {
+	rsp, err := web.Fetch(ctx, fetchConfig)
+
+	// The URL is left out of errors, since its query may contain credentials.
+	var urlErr *url.Error
+
+	switch code := web.StatusCode(err); {
+	case errors.As(err, &urlErr):
+		return fmt.Errorf("unable to reach source: %w", urlErr.Err)
+	case err == nil:
+		rsp.Body.Close()
+
+		return nil
+	case code == http.StatusUnauthorized || code == http.StatusForbidden:
+		return fmt.Errorf("authentication failed: %w", err)
+	case code == http.StatusTooManyRequests:
+		return nil
+	default:
+		return err
+	}
+}
+
+// storageChecks returns a check for every connection string, which connects to the storage target and pings it.
+func storageChecks(cfg *config.Config) []*preflightCheck {
+	checks := make([]*preflightCheck, len(cfg.ConnectionStrings))
+
+	for idx, dns := range cfg.ConnectionStrings {
+		dns := dns
+
+		name := fmt.Sprintf("storage connectionStrings[%d] (%s)", idx, proto.SchemeFromConnectionString(dns))
+
+		checks[idx] = &preflightCheck{name: name, check: func(ctx context.Context) error {
+			stg, err := repository.NewStorage(ctx, dns)
+			if err != nil {
+				return err
+			}
+
+			defer stg.Close()
+
+			return stg.Ping()
+		}}
+	}
+
+	return checks
+}
+
+// runPreflightChecks will run every check concurrently, returning a consolidated report of the checks that failed.
+func runPreflightChecks(ctx context.Context, checks []*preflightCheck) error {
+	var wg sync.WaitGroup
+
+	for _, check := range checks {
+		wg.Add(1)
+
+		go func(check *preflightCheck) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(ctx, preflightTimeout)
+			defer cancel()
+
+			check.err = check.check(ctx)
+		}(check)
+	}
+
+	wg.Wait()
+
+	var failed []string
+
+	for _, check := range checks {
+		if check.err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", check.name, check.err))
+		}
+	}
+
+	if len(failed) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %d of %d checks failed:\n\t%s", ErrPreflightFailed, len(failed), len(checks),
+		strings.Join(failed, "\n\t"))
+}
+
+// Preflight will check that every source of the configured requests is reachable with the configured
+// authentication, and that every storage target can be connected to, so that a bad URL or credential fails the run
+// before it starts rather than part way through.
+func Preflight(ctx context.Context, cfg *config.Config) error {
+	start := time.Now()
+
+	client, err := connect(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to connect to web API: %w", err)
+	}
+
+	checks := append(sourceChecks(cfg, client), storageChecks(cfg)...)
+
+	if err := runPreflightChecks(ctx, checks); err != nil {
+		if errors.Is(ctx.Err(), context.Canceled) {
+			return ErrInterrupted
+		}
+
+		return err
+	}
+
+	logInfo := tools.LogFormatter{
+		Duration: time.Since(start),
+		Msg:      fmt.Sprintf("preflight passed %d checks", len(checks)),
+	}
+	cfg.Logger.Info(logInfo.String())
+
+	return nil
+}
@@ -0,0 +1,111 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/internal/web"
+)
+
+// provenance is where and when the records of a repository job were fetched, which is written to the "provenance"
+// columns of the records.
+type provenance struct {
+	ingestedAt    time.Time
+	endpoint, url string
+	status        int
+	workerID      int
+	runID         string
+	header        http.Header
+}
+
+// newProvenance returns the provenance of the records of a response, fetched at "fetchedAt" by a web worker.
+func newProvenance(job *webJob, workerID int, rsp *web.FetchResponse, fetchedAt time.Time) *provenance {
+	return &provenance{
+		ingestedAt: fetchedAt.UTC(),
+		endpoint:   rsp.Request.URL.Path,
+		url:        rsp.Request.URL.String(),
+		status:     rsp.StatusCode,
+		workerID:   workerID,
+		runID:      job.runID,
+		header:     rsp.Header,
+	}
+}
+
+// provenanceValues returns the provenance of records keyed by the "columns" that it is written to.
+func provenanceValues(columns *config.Provenance, prov *provenance) (map[string]json.RawMessage, error) {
+	if columns == nil || prov == nil {
+		return nil, nil
+	}
+
+	values := make(map[string]json.RawMessage)
+
+	for column, val := range map[string]interface{}{
+		columns.IngestedAt: prov.ingestedAt.Format(time.RFC3339Nano),
+		columns.Endpoint:   prov.endpoint,
+		columns.URL:        prov.url,
+		columns.Status:     prov.status,
+		columns.WorkerID:   prov.workerID,
+		columns.RunID:      prov.runID,
+	} {
+		if column == "" {
+			continue
+		}
+
+		data, err := json.Marshal(val)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode provenance: %w", err)
+		}
+
+		values[column] = data
+	}
+
+	// Headers with more than one value are joined, as they would be in a single header.
+	for header, column := range columns.Headers {
+		var val interface{}
+		if vals := prov.header.Values(header); len(vals) > 0 {
+			val = strings.Join(vals, ", ")
+		}
+
+		data, err := json.Marshal(val)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode provenance: %w", err)
+		}
+
+		values[column] = data
+	}
+
+	return values, nil
+}
+
+// annotateProvenanceRecords will write the provenance of records to the "columns" of every record. Records that are
+// not objects are left as they are.
+func annotateProvenanceRecords(columns *config.Provenance, prov *provenance, records []json.RawMessage) error {
+	values, err := provenanceValues(columns, prov)
+	if err != nil {
+		return err
+	}
+
+	return setColumns(values, records)
+}
+
+// annotateProvenance will write the provenance of records to the "columns" of the records of JSON data, an array of
+// records or a single record.
+func annotateProvenance(columns *config.Provenance, prov *provenance, data []byte) ([]byte, error) {
+	values, err := provenanceValues(columns, prov)
+	if err != nil {
+		return nil, err
+	}
+
+	return setDataColumns(values, data)
+}
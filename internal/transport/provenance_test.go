@@ -0,0 +1,90 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/internal/web"
+)
+
+func TestAnnotateProvenance(t *testing.T) {
+	t.Parallel()
+
+	fetchedAt := time.Date(2022, 10, 14, 17, 4, 5, 0, time.FixedZone("CEST", 2*60*60))
+	reqURL, _ := url.Parse("https://api.example.com/candles?granularity=60")
+
+	job := &webJob{runResources: &runResources{runID: "20221014T150000Z"}}
+	rsp := &web.FetchResponse{
+		Request:    &http.Request{URL: reqURL},
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"X-Request-Id": {"req-1"}, "Vary": {"Accept", "Origin"}},
+	}
+	prov := newProvenance(job, 3, rsp, fetchedAt)
+
+	every := &config.Provenance{
+		IngestedAt: "ingested_at",
+		Endpoint:   "endpoint",
+		URL:        "source_url",
+		Status:     "status",
+		WorkerID:   "worker_id",
+		RunID:      "run_id",
+	}
+
+	for _, tcase := range []struct {
+		name    string
+		columns *config.Provenance
+		prov    *provenance
+		data    string
+		want    string
+	}{
+		{name: "no provenance", data: `[{"id":1}]`, want: `[{"id":1}]`},
+		{name: "not fetched", columns: every, data: `[{"id":1}]`, want: `[{"id":1}]`},
+		{
+			name:    "every column",
+			columns: every,
+			prov:    prov,
+			data:    `[{"id":1},"raw"]`,
+			want: `[{"endpoint":"/candles","id":1,"ingested_at":"2022-10-14T15:04:05Z","run_id":"20221014T150000Z",` +
+				`"source_url":"https://api.example.com/candles?granularity=60","status":200,"worker_id":3},"raw"]`,
+		},
+		{
+			name:    "single record",
+			columns: &config.Provenance{RunID: "run_id"},
+			prov:    prov,
+			data:    `{"id":1,"run_id":"replaced"}`,
+			want:    `{"id":1,"run_id":"20221014T150000Z"}`,
+		},
+		{
+			name: "headers",
+			columns: &config.Provenance{Headers: map[string]string{
+				"x-request-id": "request_id",
+				"Vary":         "vary",
+				"ETag":         "etag",
+			}},
+			prov: prov,
+			data: `[{"id":1}]`,
+			want: `[{"etag":null,"id":1,"request_id":"req-1","vary":"Accept, Origin"}]`,
+		},
+	} {
+		got, err := annotateProvenance(tcase.columns, tcase.prov, []byte(tcase.data))
+		if err != nil {
+			t.Fatalf("%s: failed to annotate provenance: %v", tcase.name, err)
+		}
+
+		if string(got) != tcase.want {
+			t.Fatalf("%s: expected %s, got %s", tcase.name, tcase.want, got)
+		}
+	}
+}
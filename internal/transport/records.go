@@ -0,0 +1,157 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/alpstable/gidari/internal/workspace"
+	"github.com/alpstable/gidari/tools"
+)
+
+// seekRecords returns a reader of the records at the "recordsPath" of a response body. A body without the path has
+// no records, which is logged as a warning, so that an envelope without records is not written as a record.
+func (job *webJob) seekRecords(body io.Reader) (*bufio.Reader, error) {
+	records, err := tools.SeekJSONPath(body, job.recordsPath)
+	if errors.Is(err, tools.ErrJSONPathNotFound) {
+		logWarn := tools.LogFormatter{
+			Msg: fmt.Sprintf("response of %s has no records at %q", job.fetchConfig.URL, job.recordsPath),
+		}
+		job.logger.Warn(logWarn.String())
+
+		return bufio.NewReader(bytes.NewReader([]byte("[]"))), nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to find records at %q: %w", job.recordsPath, err)
+	}
+
+	return bufio.NewReader(records), nil
+}
+
+// streamRecords will decode the records at the "recordsPath" of a response body as it is read, calling "batchFn"
+// with batches of them. A value at the path that is not an array is a single record.
+func (job *webJob) streamRecords(body io.Reader, batchFn streamBatchFn) (int, error) {
+	records, err := job.seekRecords(body)
+	if err != nil {
+		return 0, err
+	}
+
+	if first, err := firstNonSpace(records); err != nil || first == '[' {
+		return decodeStream(records, job.streamSize(), batchFn)
+	}
+
+	var record json.RawMessage
+	if err := json.NewDecoder(records).Decode(&record); err != nil {
+		return 0, fmt.Errorf("failed to decode records: %w", err)
+	}
+
+	return 1, batchFn([]json.RawMessage{record})
+}
+
+// spillRecords will spill the JSON value that "reader" begins with, streaming the records of an array so that they
+// are never held in memory together, returning the name of the spill file.
+func spillRecords(ws *workspace.Workspace, reader *bufio.Reader) (string, error) {
+	first, err := firstNonSpace(reader)
+	if err != nil {
+		return "", err
+	}
+
+	if first != '[' {
+		var record json.RawMessage
+		if err := json.NewDecoder(reader).Decode(&record); err != nil {
+			return "", fmt.Errorf("failed to decode records: %w", err)
+		}
+
+		return spill(ws, bytes.NewReader(record))
+	}
+
+	pipeReader, pipeWriter := io.Pipe()
+
+	go func() {
+		var buf bytes.Buffer
+
+		buf.WriteByte('[')
+
+		// Every batch is written after the opening bracket, or after the records of the last batch.
+		_, err := decodeStream(reader, degradedBatchSize, func(batch []json.RawMessage) error {
+			for idx, record := range batch {
+				if idx > 0 || buf.Len() == 0 {
+					buf.WriteByte(',')
+				}
+
+				buf.Write(record)
+			}
+
+			if _, err := buf.WriteTo(pipeWriter); err != nil {
+				return fmt.Errorf("failed to write records: %w", err)
+			}
+
+			return nil
+		})
+		if err == nil {
+			buf.WriteByte(']')
+			_, err = buf.WriteTo(pipeWriter)
+		}
+
+		pipeWriter.CloseWithError(err)
+	}()
+
+	name, err := spill(ws, pipeReader)
+	pipeReader.Close()
+
+	return name, err
+}
+
+// extractRecords returns the records at the "recordsPath" of a response body that was read whole, or spilled to the
+// file "spilled". Bodies that are not valid JSON are returned as they are, for the "clobColumn".
+func (job *webJob) extractRecords(data []byte, spilled string) ([]byte, string, error) {
+	if job.recordsPath == "" || (spilled == "" && !json.Valid(data)) {
+		return data, spilled, nil
+	}
+
+	if spilled == "" {
+		records, err := job.seekRecords(bytes.NewReader(data))
+		if err != nil {
+			return nil, "", err
+		}
+
+		var raw json.RawMessage
+		if err := json.NewDecoder(records).Decode(&raw); err != nil {
+			return nil, "", fmt.Errorf("failed to decode records: %w", err)
+		}
+
+		return raw, "", nil
+	}
+
+	defer os.Remove(spilled)
+
+	file, err := os.Open(spilled)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to open spill file: %w", err)
+	}
+	defer file.Close()
+
+	records, err := job.seekRecords(bufio.NewReader(file))
+	if err != nil {
+		return nil, "", err
+	}
+
+	name, err := spillRecords(job.ws, records)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return nil, name, nil
+}
@@ -0,0 +1,121 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExtractRecords(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name  string
+		path  string
+		data  string
+		spill bool
+		want  string
+	}{
+		{name: "no path", data: `{"result":{"items":[1]}}`, want: `{"result":{"items":[1]}}`},
+		{
+			name: "envelope",
+			path: "$.result.items",
+			data: `{"result":{"items":[{"id":1},{"id":2}]}}`,
+			want: `[{"id":1},{"id":2}]`,
+		},
+		{name: "object", path: "data", data: `{"data":{"id":1},"meta":{}}`, want: `{"id":1}`},
+		{name: "missing", path: "$.result.items", data: `{"error":"rate limited"}`, want: `[]`},
+		{name: "invalid", path: "$.result.items", data: `<html>`, want: `<html>`},
+		{
+			name:  "spilled",
+			path:  "result.items",
+			data:  `{"result": {"items": [{"id":1}, {"id":2}, {"id":3}]}, "next": "abc"}`,
+			spill: true,
+			want:  `[{"id":1},{"id":2},{"id":3}]`,
+		},
+		{name: "spilled empty", path: "result.items", data: `{"result":{"items":[]}}`, spill: true, want: `[]`},
+		{name: "spilled missing", path: "result.items", data: `{"result":{}}`, spill: true, want: `[]`},
+	} {
+		job := newTestControlJob(nil, "GET /a a")
+		job.recordsPath = tcase.path
+
+		data, spilled := []byte(tcase.data), ""
+
+		if tcase.spill {
+			name, err := spill(nil, strings.NewReader(tcase.data))
+			if err != nil {
+				t.Fatalf("%s: failed to spill data: %v", tcase.name, err)
+			}
+
+			data, spilled = nil, name
+		}
+
+		got, gotSpill, err := job.extractRecords(data, spilled)
+		if err != nil {
+			t.Fatalf("%s: failed to extract records: %v", tcase.name, err)
+		}
+
+		if gotSpill != "" {
+			defer os.Remove(gotSpill)
+
+			if got, err = os.ReadFile(gotSpill); err != nil {
+				t.Fatalf("%s: failed to read spilled records: %v", tcase.name, err)
+			}
+		}
+
+		if string(got) != tcase.want {
+			t.Fatalf("%s: expected %s, got %s", tcase.name, tcase.want, got)
+		}
+	}
+}
+
+func TestStreamRecords(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name  string
+		body  string
+		want  []string
+		count int
+	}{
+		{
+			name:  "array",
+			body:  `{"page":1,"result":{"items":[{"id":1},{"id":2},{"id":3}]}}`,
+			want:  []string{`[{"id":1},{"id":2}]`, `[{"id":3}]`},
+			count: 3,
+		},
+		{name: "record", body: `{"result":{"items":{"id":1}}}`, want: []string{`[{"id":1}]`}, count: 1},
+		{name: "missing", body: `{"result":{}}`, want: []string{`[]`}},
+	} {
+		job := newTestControlJob(nil, "GET /a a")
+		job.recordsPath = "result.items"
+		job.streamBatchSize = 2
+
+		var got []string
+
+		count, err := job.streamRecords(strings.NewReader(tcase.body), func(batch []json.RawMessage) error {
+			data, err := json.Marshal(batch)
+			got = append(got, string(data))
+
+			return err
+		})
+		if err != nil {
+			t.Fatalf("%s: failed to stream records: %v", tcase.name, err)
+		}
+
+		if count != tcase.count || !reflect.DeepEqual(got, tcase.want) {
+			t.Fatalf("%s: expected %d records in %v, got %d in %v", tcase.name, tcase.count, tcase.want, count, got)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/internal/web"
+	"github.com/alpstable/gidari/tools"
+	"github.com/sirupsen/logrus"
+)
+
+// cachedResponse is the status and headers of a cached response, which is written next to its body once the body
+// has been read in full.
+type cachedResponse struct {
+	Status   int         `json:"status"`
+	Header   http.Header `json:"header,omitempty"`
+	CachedAt time.Time   `json:"cachedAt"`
+}
+
+// responseCache answers the requests of a run with the responses that were cached on disk by earlier runs, until
+// they expire. A nil cache caches nothing.
+type responseCache struct {
+	dir    string
+	ttl    time.Duration
+	clock  tools.Clock
+	logger *logrus.Logger
+}
+
+// newResponseCache returns the response cache of a run, or nil if the configuration has no "responseCache".
+func newResponseCache(cfg *config.Config) *responseCache {
+	if cfg.ResponseCache == nil {
+		return nil
+	}
+
+	return &responseCache{
+		dir:    cfg.ResponseCache.Directory(),
+		ttl:    cfg.ResponseCache.Duration(),
+		clock:  tools.ClockOrReal(cfg.Clock),
+		logger: cfg.Logger,
+	}
+}
+
+// path returns the path of a file of the cached response to an HTTP request, which is named by the hash of its
+// method, URL and body so that credentials in the URL or body are not written to the cache directory.
+func (cache *responseCache) path(fetchConfig *web.FetchConfig, ext string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s %s %s", fetchConfig.Method, fetchConfig.URL, fetchConfig.Body)))
+
+	return filepath.Join(cache.dir, hex.EncodeToString(sum[:])+ext)
+}
+
+// get returns the cached response to an HTTP request, if one was cached within the TTL. Expired responses are
+// removed.
+func (cache *responseCache) get(ctx context.Context, fetchConfig *web.FetchConfig) (*web.FetchResponse, bool) {
+	if cache == nil {
+		return nil, false
+	}
+
+	metaPath, bodyPath := cache.path(fetchConfig, ".json"), cache.path(fetchConfig, ".body")
+
+	data, err := os.ReadFile(metaPath)
+	if err != nil {
+		return nil, false
+	}
+
+	var cached cachedResponse
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return nil, false
+	}
+
+	if cache.clock.Now().Sub(cached.CachedAt) > cache.ttl {
+		os.Remove(metaPath)
+		os.Remove(bodyPath)
+
+		return nil, false
+	}
+
+	req, err := http.NewRequestWithContext(ctx, fetchConfig.Method, fetchConfig.URL.String(), nil)
+	if err != nil {
+		return nil, false
+	}
+
+	body, err := os.Open(bodyPath)
+	if err != nil {
+		return nil, false
+	}
+
+	return &web.FetchResponse{Request: req, Body: body, StatusCode: cached.Status, Header: cached.Header}, true
+}
+
+// put will cache a successful response to an HTTP request as its body is read. The response is only cached once
+// its body has been read in full, so a body that is abandoned or fails to read is not cached.
+func (cache *responseCache) put(fetchConfig *web.FetchConfig, rsp *web.FetchResponse) {
+	if cache == nil || rsp.StatusCode < http.StatusOK || rsp.StatusCode >= http.StatusMultipleChoices {
+		return
+	}
+
+	if err := os.MkdirAll(cache.dir, 0o755); err != nil {
+		cache.warn(fmt.Errorf("failed to create response cache directory: %w", err))
+
+		return
+	}
+
+	file, err := os.CreateTemp(cache.dir, "*.tmp")
+	if err != nil {
+		cache.warn(fmt.Errorf("failed to create cached response: %w", err))
+
+		return
+	}
+
+	rsp.Body = &cachingBody{
+		ReadCloser: rsp.Body,
+		file:       file,
+		commit: func() error {
+			return cache.commit(fetchConfig, file.Name(), cachedResponse{
+				Status:   rsp.StatusCode,
+				Header:   rsp.Header,
+				CachedAt: cache.clock.Now().UTC(),
+			})
+		},
+		warn: cache.warn,
+	}
+}
+
+// commit will move the body of a response that was read in full into the cache, and then write its status and
+// headers, which make it visible to "get".
+func (cache *responseCache) commit(fetchConfig *web.FetchConfig, tmp string, cached cachedResponse) error {
+	if err := os.Rename(tmp, cache.path(fetchConfig, ".body")); err != nil {
+		os.Remove(tmp)
+
+		return fmt.Errorf("failed to cache response: %w", err)
+	}
+
+	data, err := json.Marshal(cached)
+	if err != nil {
+		return fmt.Errorf("failed to encode cached response: %w", err)
+	}
+
+	metaPath := cache.path(fetchConfig, ".json")
+	if err := os.WriteFile(metaPath+".tmp", data, 0o644); err != nil {
+		return fmt.Errorf("failed to cache response: %w", err)
+	}
+
+	if err := os.Rename(metaPath+".tmp", metaPath); err != nil {
+		return fmt.Errorf("failed to cache response: %w", err)
+	}
+
+	return nil
+}
+
+// warn will log an error of the cache, which does not fail the run.
+func (cache *responseCache) warn(err error) {
+	cache.logger.Warn(tools.LogFormatter{Msg: err.Error()}.String())
+}
+
+// cachingBody copies a response body to a temporary file as it is read, and commits it to the cache once it has
+// been read to the end.
+type cachingBody struct {
+	io.ReadCloser
+	file   *os.File
+	commit func() error
+	warn   func(error)
+
+	writeErr    error
+	eof, closed bool
+}
+
+func (body *cachingBody) Read(p []byte) (int, error) {
+	n, err := body.ReadCloser.Read(p)
+	if n > 0 && body.writeErr == nil {
+		_, body.writeErr = body.file.Write(p[:n])
+	}
+
+	if errors.Is(err, io.EOF) {
+		body.eof = true
+	}
+
+	return n, err //nolint:wrapcheck // the error of the body is returned as it is
+}
+
+func (body *cachingBody) Close() error {
+	err := body.ReadCloser.Close()
+	if body.closed {
+		return err //nolint:wrapcheck // the error of the body is returned as it is
+	}
+
+	body.closed = true
+
+	if closeErr := body.file.Close(); body.writeErr == nil {
+		body.writeErr = closeErr
+	}
+
+	switch {
+	case !body.eof:
+		os.Remove(body.file.Name())
+	case body.writeErr != nil:
+		os.Remove(body.file.Name())
+		body.warn(fmt.Errorf("failed to cache response: %w", body.writeErr))
+	default:
+		if commitErr := body.commit(); commitErr != nil {
+			body.warn(commitErr)
+		}
+	}
+
+	return err //nolint:wrapcheck // the error of the body is returned as it is
+}
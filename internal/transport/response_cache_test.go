@@ -0,0 +1,114 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/internal/web"
+	"github.com/alpstable/gidari/tools"
+	"github.com/sirupsen/logrus"
+)
+
+func TestResponseCache(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	clock := tools.NewFakeClock(time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC))
+
+	cache := newResponseCache(&config.Config{
+		ResponseCache: &config.ResponseCacheConfig{Dir: t.TempDir(), TTL: "1h"},
+		Clock:         clock,
+		Logger:        logrus.New(),
+	})
+
+	fetchConfig := &web.FetchConfig{Method: http.MethodGet, URL: &url.URL{Scheme: "https", Host: "api", Path: "/candles"}}
+
+	newResponse := func(status int) *web.FetchResponse {
+		header := make(http.Header)
+		header.Set("Content-Type", "application/json")
+
+		return &web.FetchResponse{StatusCode: status, Header: header, Body: io.NopCloser(strings.NewReader(`[{"id":1}]`))}
+	}
+
+	if _, ok := cache.get(ctx, fetchConfig); ok {
+		t.Fatalf("expected no cached response before one is fetched")
+	}
+
+	// Bodies that are not read to the end, and failed responses, are not cached.
+	abandoned := newResponse(http.StatusOK)
+	cache.put(fetchConfig, abandoned)
+	abandoned.Body.Close()
+
+	failed := newResponse(http.StatusNotModified)
+	cache.put(fetchConfig, failed)
+	io.ReadAll(failed.Body)
+	failed.Body.Close()
+
+	if _, ok := cache.get(ctx, fetchConfig); ok {
+		t.Fatalf("expected an abandoned or failed response not to be cached")
+	}
+
+	rsp := newResponse(http.StatusOK)
+	cache.put(fetchConfig, rsp)
+
+	if body, _ := io.ReadAll(rsp.Body); string(body) != `[{"id":1}]` {
+		t.Fatalf("expected the body to be read through the cache, got %s", body)
+	}
+
+	rsp.Body.Close()
+	rsp.Body.Close()
+
+	cached, ok := cache.get(ctx, fetchConfig)
+	if !ok {
+		t.Fatalf("expected the response to be cached")
+	}
+
+	body, _ := io.ReadAll(cached.Body)
+	cached.Body.Close()
+
+	if string(body) != `[{"id":1}]` || cached.StatusCode != http.StatusOK {
+		t.Fatalf("expected the cached response, got %d %s", cached.StatusCode, body)
+	}
+
+	if got := cached.Header.Get("Content-Type"); got != "application/json" {
+		t.Fatalf("expected the cached headers, got %q", got)
+	}
+
+	if cached.Request.URL.String() != fetchConfig.URL.String() {
+		t.Fatalf("expected the request of %s, got %s", fetchConfig.URL, cached.Request.URL)
+	}
+
+	other := &web.FetchConfig{Method: http.MethodGet, URL: fetchConfig.URL, Body: []byte(`{"page":2}`)}
+	if _, ok := cache.get(ctx, other); ok {
+		t.Fatalf("expected a request with another body not to be answered from the cache")
+	}
+
+	clock.Advance(time.Hour + time.Second)
+
+	if _, ok := cache.get(ctx, fetchConfig); ok {
+		t.Fatalf("expected the cached response to expire")
+	}
+
+	var nilCache *responseCache
+
+	nilCache.put(fetchConfig, newResponse(http.StatusOK))
+
+	if _, ok := nilCache.get(ctx, fetchConfig); ok {
+		t.Fatalf("expected a nil cache to cache nothing")
+	}
+}
@@ -0,0 +1,263 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/tools"
+)
+
+// ErrInvalidRetryFailed is returned when the options to retry the failed requests of a run are invalid, the manifest
+// of the run cannot be read, or the configuration has changed since the run.
+var ErrInvalidRetryFailed = fmt.Errorf("invalid retry-failed")
+
+// RetryFailedOptions are the settings for retrying the failed requests of a previous run.
+type RetryFailedOptions struct {
+	// Dir is the directory that the manifest of the run is looked up in by its ID. The default is the
+	// "manifest.dir" of the configuration.
+	Dir string
+
+	// Run is the ID of the run, or the path of its manifest.
+	Run string
+}
+
+func (opts *RetryFailedOptions) validate() error {
+	if opts.Run == "" {
+		return fmt.Errorf("%w: a run is required", ErrInvalidRetryFailed)
+	}
+
+	return nil
+}
+
+// manifestDir returns the directory that the manifest of the run is looked up in.
+func (opts *RetryFailedOptions) manifestDir(cfg *config.Config) string {
+	switch {
+	case opts.Dir != "":
+		return opts.Dir
+	case cfg != nil && cfg.Manifest != nil && cfg.Manifest.Dir != "":
+		return cfg.Manifest.Dir
+	default:
+		return config.DefaultManifestDir
+	}
+}
+
+// RetryFailedConfig returns the path of the configuration file that a run was made with, which its failed requests
+// are retried with.
+func RetryFailedConfig(opts RetryFailedOptions) (string, error) {
+	if err := opts.validate(); err != nil {
+		return "", err
+	}
+
+	manifest, err := loadManifest(opts.manifestDir(nil), opts.Run, ErrInvalidRetryFailed)
+	if err != nil {
+		return "", err
+	}
+
+	if manifest.Config == nil {
+		return "", fmt.Errorf("%w: the manifest of run %s does not record its configuration", ErrInvalidRetryFailed,
+			manifest.ID)
+	}
+
+	return manifest.Config.Path, nil
+}
+
+// failedKey identifies a failed request, which can be recorded both in the manifest and the dead-letter file.
+func failedKey(letter *deadLetter) string {
+	return fmt.Sprintf("%s %s %s %s %d", letter.Request, letter.Method, letter.URL, letter.Body, letter.Page)
+}
+
+// failedLetters returns the failed requests of a run: the requests in its manifest, and the requests that were
+// dead-lettered in the run.
+func failedLetters(cfg *config.Config, manifest *runManifest) ([]*deadLetter, error) {
+	letters := append([]*deadLetter(nil), manifest.Failed...)
+
+	if cfg.DeadLetter == nil {
+		return letters, nil
+	}
+
+	dead, err := readDeadLetters(cfg.DeadLetter.Path())
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(letters))
+	for _, letter := range letters {
+		seen[failedKey(letter)] = true
+	}
+
+	for _, letter := range dead {
+		if letter.Run != manifest.ID || seen[failedKey(letter)] {
+			continue
+		}
+
+		seen[failedKey(letter)] = true
+		letters = append(letters, letter)
+	}
+
+	return letters, nil
+}
+
+// retryDeadLetters will rewrite the dead-letter file of the configuration without the requests of the run, which
+// are retried, and open it for the requests that fail again. It returns nil if dead letters are not configured.
+func retryDeadLetters(cfg *config.Config, run string) (*deadLetterFile, error) {
+	if cfg.DeadLetter == nil {
+		return nil, nil
+	}
+
+	path := cfg.DeadLetter.Path()
+
+	letters, err := readDeadLetters(path)
+	if err != nil {
+		return nil, err
+	}
+
+	deadLetters, err := createDeadLetters(path, os.O_TRUNC)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, letter := range letters {
+		if letter.Run == run {
+			continue
+		}
+
+		if err := deadLetters.write(letter); err != nil {
+			deadLetters.close(cfg.Logger)
+
+			return nil, err
+		}
+	}
+
+	// Only the requests that fail again are counted.
+	deadLetters.count = 0
+
+	return deadLetters, nil
+}
+
+// RetryFailed will make the failed requests of a previous run again, from its manifest and the dead-letter file: the
+// requests that were dead-lettered, and the requests of a batch that failed and so was not committed. The requests
+// are made as they were in the run, with the settings of the configuration, which has to be the same configuration
+// file that the run was made with.
+//
+// Requests that fail again are dead-lettered, or fail the retry, and with a "manifest" they are recorded in the
+// manifest of the retry, so that it can be retried in turn.
+func RetryFailed(ctx context.Context, cfg *config.Config, opts RetryFailedOptions) error {
+	start := time.Now()
+
+	if err := opts.validate(); err != nil {
+		return err
+	}
+
+	manifest, err := loadManifest(opts.manifestDir(cfg), opts.Run, ErrInvalidRetryFailed)
+	if err != nil {
+		return err
+	}
+
+	if _, sum := cfg.Source(); manifest.Config != nil && sum != manifest.Config.SHA256 {
+		return fmt.Errorf("%w: the configuration has changed since run %s was made with %s", ErrInvalidRetryFailed,
+			manifest.ID, manifest.Config.Path)
+	}
+
+	letters, err := failedLetters(cfg, manifest)
+	if err != nil {
+		return err
+	}
+
+	if len(letters) == 0 {
+		cfg.Logger.Info(tools.LogFormatter{Msg: fmt.Sprintf("run %s has no failed requests", manifest.ID)}.String())
+
+		return nil
+	}
+
+	client, err := connect(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to connect to web API: %w", err)
+	}
+
+	reqs, err := replayRequests(cfg, client, letters)
+	if err != nil {
+		return err
+	}
+
+	ws, err := newWorkspace(cfg)
+	if err != nil {
+		return err
+	}
+
+	deadLetters, err := retryDeadLetters(cfg, manifest.ID)
+	if err != nil {
+		return err
+	}
+
+	rec := newManifestRecorder(cfg)
+
+	res := newRunResources(cfg, ws, nil, nil, deadLetters)
+	res.manifest = rec
+
+	if rec != nil {
+		res.runID = rec.manifest.ID
+	}
+
+	batch := coalesceRequests(reqs)
+
+	err = upsertBatch(ctx, cfg, res, batch)
+	if err != nil {
+		rec.failedBatch(batch, err)
+	} else if ctx.Err() != nil {
+		err = ErrInterrupted
+	}
+
+	// Requests that were not made before the retry was interrupted are still failed.
+	for idx, req := range reqs {
+		if req.done || ctx.Err() == nil {
+			continue
+		}
+
+		letter := *letters[idx]
+		letter.Run = res.runID
+
+		rec.failed(&letter)
+
+		if deadLetters != nil {
+			if writeErr := deadLetters.write(&letter); writeErr != nil {
+				cfg.Logger.Warn(tools.LogFormatter{Msg: writeErr.Error()}.String())
+			}
+		}
+	}
+
+	saveManifest(cfg, rec, err)
+	res.sinks.report(cfg.Logger)
+	deadLetters.close(cfg.Logger)
+
+	if closeErr := ws.Close(err != nil); closeErr != nil {
+		cfg.Logger.Warn(tools.LogFormatter{Msg: closeErr.Error()}.String())
+	}
+
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	if deadLetters != nil {
+		failed = deadLetters.count
+	}
+
+	logInfo := tools.LogFormatter{
+		Duration: time.Since(start),
+		Msg: fmt.Sprintf("retry of run %s completed: %d of %d requests succeeded", manifest.ID,
+			len(letters)-failed, len(letters)),
+	}
+	cfg.Logger.Info(logInfo.String())
+
+	return nil
+}
@@ -0,0 +1,143 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/tools"
+	"github.com/sirupsen/logrus"
+)
+
+func TestRetryFailedLetters(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "dead.jsonl")
+	cfg := &config.Config{DeadLetter: &config.DeadLetterConfig{File: path}, Logger: logrus.New()}
+
+	letters, err := createDeadLetters(path, os.O_APPEND)
+	if err != nil {
+		t.Fatalf("failed to create dead letters: %v", err)
+	}
+
+	for _, letter := range []*deadLetter{
+		{Run: "a", Method: "GET", URL: "https://api.example.com/1"},
+		{Run: "b", Method: "GET", URL: "https://api.example.com/2"},
+		{Run: "a", Method: "GET", URL: "https://api.example.com/3"},
+	} {
+		if err := letters.write(letter); err != nil {
+			t.Fatalf("failed to write dead letter: %v", err)
+		}
+	}
+
+	letters.close(cfg.Logger)
+
+	manifest := &runManifest{ID: "a", Failed: []*deadLetter{
+		{Run: "a", Method: "GET", URL: "https://api.example.com/1"},
+		{Run: "a", Method: "GET", URL: "https://api.example.com/4"},
+	}}
+
+	failed, err := failedLetters(cfg, manifest)
+	if err != nil {
+		t.Fatalf("failed to find the failed requests: %v", err)
+	}
+
+	var urls []string
+	for _, letter := range failed {
+		urls = append(urls, letter.URL)
+	}
+
+	if fmt.Sprint(urls) != "[https://api.example.com/1 https://api.example.com/4 https://api.example.com/3]" {
+		t.Fatalf("expected the failed requests of the run without duplicates, got %v", urls)
+	}
+
+	// The requests of the run are retried, so only the requests of other runs are kept.
+	retried, err := retryDeadLetters(cfg, "a")
+	if err != nil {
+		t.Fatalf("failed to rewrite the dead letters: %v", err)
+	}
+
+	if retried.count != 0 {
+		t.Fatalf("expected the dead letters of other runs not to be counted, got %d", retried.count)
+	}
+
+	retried.close(cfg.Logger)
+
+	kept, err := readDeadLetters(path)
+	if err != nil {
+		t.Fatalf("failed to read dead letters: %v", err)
+	}
+
+	if len(kept) != 1 || kept[0].Run != "b" {
+		t.Fatalf("expected only the dead letter of run b, got %d", len(kept))
+	}
+}
+
+func TestRetryFailedManifest(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	day := time.Date(2022, 10, 14, 15, 4, 5, 0, time.UTC)
+
+	rec := newManifestRecorder(&config.Config{Manifest: &config.ManifestConfig{Dir: dir}, Clock: tools.NewFakeClock(day)})
+	rec.manifest.Config = &manifestConfig{Path: "/etc/gidari/config.yaml", SHA256: "abc"}
+
+	reqs := newCheckpointTestRequests(3)
+	reqs[0].coalesced = []*flattenedRequest{reqs[1]}
+	reqs[2].failed = true
+
+	rec.failedBatch([]*flattenedRequest{reqs[0], reqs[2]}, errors.New("connection reset"))
+
+	if _, err := rec.save(errors.New("connection reset")); err != nil {
+		t.Fatalf("failed to save the manifest: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "20221014T150405Z.json"))
+	if err != nil {
+		t.Fatalf("failed to read the manifest: %v", err)
+	}
+
+	var manifest runManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatalf("failed to decode the manifest: %v", err)
+	}
+
+	if len(manifest.Failed) != 2 || manifest.Failed[1].URL != reqs[1].fetchConfig.URL.String() ||
+		manifest.Failed[0].Run != manifest.ID || manifest.Failed[0].Error != "connection reset" {
+		t.Fatalf("expected the coalesced requests of the batch without the dead letter, got %s", data)
+	}
+
+	for _, tcase := range []struct {
+		name string
+		opts RetryFailedOptions
+		path string
+		err  error
+	}{
+		{name: "run", opts: RetryFailedOptions{Dir: dir, Run: manifest.ID}, path: "/etc/gidari/config.yaml"},
+		{name: "no run", opts: RetryFailedOptions{Dir: dir}, err: ErrInvalidRetryFailed},
+		{name: "missing", opts: RetryFailedOptions{Dir: dir, Run: "missing"}, err: ErrInvalidRetryFailed},
+	} {
+		path, err := RetryFailedConfig(tcase.opts)
+		if !errors.Is(err, tcase.err) {
+			t.Fatalf("%s: expected error %v, got %v", tcase.name, tcase.err, err)
+		}
+
+		if path != tcase.path {
+			t.Fatalf("%s: expected %q, got %q", tcase.name, tcase.path, path)
+		}
+	}
+}
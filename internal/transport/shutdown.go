@@ -0,0 +1,65 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/internal/state"
+	"github.com/alpstable/gidari/tools"
+)
+
+// ErrInterrupted is returned when the context of a run is canceled. The requests fetched before the cancellation
+// are committed and recorded in the checkpoint, so that the run can be continued with "Resume".
+var ErrInterrupted = fmt.Errorf("run interrupted")
+
+// detachedContext carries the values of its parent without its cancellation. Requests that are in-flight, and the
+// storage transactions they write to, use a detached context so that canceling a run does not abort them mid-write.
+type detachedContext struct {
+	parent context.Context
+}
+
+func detach(ctx context.Context) context.Context {
+	return detachedContext{parent: ctx}
+}
+
+func (detachedContext) Deadline() (time.Time, bool)           { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}                 { return nil }
+func (detachedContext) Err() error                            { return nil }
+func (ctx detachedContext) Value(key interface{}) interface{} { return ctx.parent.Value(key) }
+
+// interruptCheckpoint will return the checkpoint to record an interrupted run in. If checkpointing is not configured,
+// a new checkpoint is created at the default path so that the run can still be resumed.
+func interruptCheckpoint(cfg *config.Config, checkpoint *state.Checkpoint) (*state.Checkpoint, error) {
+	if checkpoint != nil {
+		return checkpoint, nil
+	}
+
+	checkpoint, err := state.OpenCheckpoint(config.DefaultCheckpointFile, false, cfg.Clock)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open checkpoint: %w", err)
+	}
+
+	return checkpoint, nil
+}
+
+// interrupted will log the progress of a run that was stopped early and return "err", which describes why it was
+// stopped.
+func interrupted(cfg *config.Config, checkpoint *state.Checkpoint, start time.Time, err error) error {
+	logInfo := tools.LogFormatter{
+		Duration: time.Since(start),
+		Msg: fmt.Sprintf("upsert stopped after committing %d requests, use --resume to continue: %v",
+			checkpoint.Len(), err),
+	}
+	cfg.Logger.Warn(logInfo.String())
+
+	return err
+}
@@ -0,0 +1,56 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/alpstable/gidari/internal/state"
+)
+
+type testContextKey struct{}
+
+func TestDetach(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), testContextKey{}, "value"))
+	cancel()
+
+	detached := detach(ctx)
+	if detached.Err() != nil || detached.Done() != nil {
+		t.Fatalf("expected the detached context not to be canceled")
+	}
+
+	if detached.Value(testContextKey{}) != "value" {
+		t.Fatalf("expected the detached context to carry the parent values")
+	}
+}
+
+func TestCompleteBatchInterrupted(t *testing.T) {
+	t.Parallel()
+
+	reqs := newCheckpointTestRequests(3)
+	reqs[0].done = true
+
+	checkpoint, err := state.OpenCheckpoint(filepath.Join(t.TempDir(), "checkpoint.json"), false, nil)
+	if err != nil {
+		t.Fatalf("failed to open checkpoint: %v", err)
+	}
+
+	if err := completeBatch(checkpoint, reqs); err != nil {
+		t.Fatalf("failed to complete batch: %v", err)
+	}
+
+	if checkpoint.Len() != 1 || !checkpoint.Done(reqs[0].checkpointKey()) {
+		t.Fatalf("expected only the done request to be recorded, got %d", checkpoint.Len())
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/alpstable/gidari/internal/proto"
+	"github.com/alpstable/gidari/internal/repository"
+	"github.com/alpstable/gidari/tools"
+	"github.com/sirupsen/logrus"
+)
+
+// sinkStats are the writes made to a storage target over a run.
+type sinkStats struct {
+	name string
+
+	upserts       int
+	failedUpserts int
+	rows          int64
+	commits       int
+	failedCommits int
+
+	// err is the last error of the target, which is reported in the summary of the run.
+	err error
+}
+
+// sinkLedger accounts for the upserts and commits of every storage target of a run, so that the summary of the run
+// reports which targets were written to and which failed. A nil ledger records nothing.
+type sinkLedger struct {
+	mu    sync.Mutex
+	sinks map[int]*sinkStats
+}
+
+func newSinkLedger() *sinkLedger {
+	return &sinkLedger{sinks: make(map[int]*sinkStats)}
+}
+
+// sinkName identifies the storage target of the connection string at "idx", e.g. "connectionStrings[1] (s3)".
+func sinkName(idx int, repo repository.Generic) string {
+	return fmt.Sprintf("connectionStrings[%d] (%s)", idx, proto.SchemeFromStorageType(repo.Type()))
+}
+
+// stats returns the writes to the target of the connection string at "idx". The lock must be held.
+func (ledger *sinkLedger) stats(idx int, name string) *sinkStats {
+	stats := ledger.sinks[idx]
+	if stats == nil {
+		stats = &sinkStats{name: name}
+		ledger.sinks[idx] = stats
+	}
+
+	return stats
+}
+
+// upsert will account for an upsert to a target, which failed if "err" is set.
+func (ledger *sinkLedger) upsert(idx int, name string, rsp *proto.UpsertResponse, err error) {
+	if ledger == nil {
+		return
+	}
+
+	ledger.mu.Lock()
+	defer ledger.mu.Unlock()
+
+	stats := ledger.stats(idx, name)
+
+	if err != nil {
+		stats.failedUpserts++
+		stats.err = err
+
+		return
+	}
+
+	stats.upserts++
+	stats.rows += rsp.UpsertedCount + rsp.MatchedCount
+}
+
+// commit will account for the commit of the transaction of a target, which failed if "err" is set.
+func (ledger *sinkLedger) commit(idx int, name string, err error) {
+	if ledger == nil {
+		return
+	}
+
+	ledger.mu.Lock()
+	defer ledger.mu.Unlock()
+
+	stats := ledger.stats(idx, name)
+
+	if err != nil {
+		stats.failedCommits++
+		stats.err = err
+
+		return
+	}
+
+	stats.commits++
+}
+
+// report will log the writes of every target, as a warning for the targets that failed.
+func (ledger *sinkLedger) report(logger *logrus.Logger) {
+	if ledger == nil {
+		return
+	}
+
+	ledger.mu.Lock()
+	defer ledger.mu.Unlock()
+
+	indices := make([]int, 0, len(ledger.sinks))
+	for idx := range ledger.sinks {
+		indices = append(indices, idx)
+	}
+
+	sort.Ints(indices)
+
+	for _, idx := range indices {
+		stats := ledger.sinks[idx]
+
+		msg := fmt.Sprintf("storage target %s: %d upserts of %d records, %d commits", stats.name, stats.upserts,
+			stats.rows, stats.commits)
+
+		if stats.err == nil {
+			logger.Info(tools.LogFormatter{Msg: msg}.String())
+
+			continue
+		}
+
+		msg = fmt.Sprintf("%s, %d failed upserts, %d failed commits: %v", msg, stats.failedUpserts,
+			stats.failedCommits, stats.err)
+		logger.Warn(tools.LogFormatter{Msg: msg}.String())
+	}
+}
@@ -0,0 +1,68 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/alpstable/gidari/internal/proto"
+	"github.com/sirupsen/logrus"
+)
+
+func TestSinkLedger(t *testing.T) {
+	t.Parallel()
+
+	var ledger *sinkLedger
+
+	ledger.upsert(0, "connectionStrings[0] (sqlite)", &proto.UpsertResponse{UpsertedCount: 1}, nil)
+	ledger.commit(0, "connectionStrings[0] (sqlite)", nil)
+	ledger.report(logrus.New())
+
+	ledger = newSinkLedger()
+
+	const (
+		postgres = "connectionStrings[0] (postgresql)"
+		archive  = "connectionStrings[1] (s3)"
+	)
+
+	errUpload := errors.New("upload failed")
+
+	ledger.upsert(1, archive, &proto.UpsertResponse{UpsertedCount: 2}, nil)
+	ledger.upsert(0, postgres, &proto.UpsertResponse{UpsertedCount: 3, MatchedCount: 1}, nil)
+	ledger.upsert(0, postgres, &proto.UpsertResponse{UpsertedCount: 2}, nil)
+	ledger.upsert(1, archive, nil, errUpload)
+	ledger.commit(0, postgres, nil)
+	ledger.commit(1, archive, errUpload)
+
+	var buf bytes.Buffer
+
+	logger := logrus.New()
+	logger.SetOutput(&buf)
+
+	ledger.report(logger)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected a line for each target, got %q", lines)
+	}
+
+	for idx, want := range []string{
+		"level=info msg=\"{m:storage target connectionStrings[0] (postgresql): 2 upserts of 6 records, 1 commits",
+		"level=warning msg=\"{m:storage target connectionStrings[1] (s3): 1 upserts of 2 records, 0 commits, " +
+			"1 failed upserts, 1 failed commits: upload failed",
+	} {
+		if !strings.Contains(lines[idx], want) {
+			t.Fatalf("expected line %d to contain %q, got %q", idx, want, lines[idx])
+		}
+	}
+}
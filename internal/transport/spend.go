@@ -0,0 +1,156 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/internal/state"
+	"github.com/alpstable/gidari/tools"
+	"github.com/sirupsen/logrus"
+)
+
+// requestSpend is the spend of the HTTP requests made for a request during a run.
+type requestSpend struct {
+	pricing *config.Pricing
+
+	// requests are the HTTP requests made during the run, and recorded those of them already added to the state
+	// store.
+	requests int
+	recorded int
+
+	// total is the spend recorded in the state store across runs, which is unset without a store.
+	total *state.Spend
+}
+
+// spendLedger estimates the spend of every request with a "pricing" from the HTTP requests made for it. A nil
+// ledger records nothing, which is the case when no request has a pricing.
+type spendLedger struct {
+	mu    sync.Mutex
+	keys  []string
+	spend map[string]*requestSpend
+}
+
+func newSpendLedger(cfg *config.Config) *spendLedger {
+	ledger := &spendLedger{spend: make(map[string]*requestSpend)}
+
+	for _, req := range cfg.Requests {
+		if req.Pricing == nil {
+			continue
+		}
+
+		key := req.StateKey()
+		if _, ok := ledger.spend[key]; !ok {
+			ledger.keys = append(ledger.keys, key)
+			ledger.spend[key] = &requestSpend{pricing: req.Pricing}
+		}
+	}
+
+	if len(ledger.keys) == 0 {
+		return nil
+	}
+
+	return ledger
+}
+
+// add will account for the HTTP requests made for the request identified by "key". The requests of a fetch that
+// was coalesced from several requests are billed to the request that made it.
+func (ledger *spendLedger) add(key string, requests int) {
+	if ledger == nil {
+		return
+	}
+
+	ledger.mu.Lock()
+	defer ledger.mu.Unlock()
+
+	if spend := ledger.spend[key]; spend != nil {
+		spend.requests += requests
+	}
+}
+
+// record will add the spend since it was last recorded to the state store, which is saved with the watermarks.
+func (ledger *spendLedger) record(store *state.Store) {
+	if ledger == nil || store == nil {
+		return
+	}
+
+	ledger.mu.Lock()
+	defer ledger.mu.Unlock()
+
+	for _, key := range ledger.keys {
+		spend := ledger.spend[key]
+
+		requests := spend.requests - spend.recorded
+		if requests == 0 && spend.total != nil {
+			continue
+		}
+
+		total := store.AddSpend(key, int64(requests), spend.pricing.Spend(requests),
+			spend.pricing.CurrencyOrDefault())
+
+		spend.recorded = spend.requests
+		spend.total = &total
+	}
+}
+
+// totals returns the spend of the run in every currency, e.g. "12.5 USD".
+func (ledger *spendLedger) totals() string {
+	ledger.mu.Lock()
+	defer ledger.mu.Unlock()
+
+	amounts := make(map[string]float64)
+	for _, spend := range ledger.spend {
+		amounts[spend.pricing.CurrencyOrDefault()] += spend.pricing.Spend(spend.requests)
+	}
+
+	currencies := make([]string, 0, len(amounts))
+	for currency := range amounts {
+		currencies = append(currencies, currency)
+	}
+
+	sort.Strings(currencies)
+
+	totals := make([]string, len(currencies))
+	for idx, currency := range currencies {
+		totals[idx] = fmt.Sprintf("%g %s", amounts[currency], currency)
+	}
+
+	return strings.Join(totals, ", ")
+}
+
+// report will log the estimated spend of every request with a pricing, and of the whole run.
+func (ledger *spendLedger) report(logger *logrus.Logger) {
+	if ledger == nil {
+		return
+	}
+
+	ledger.mu.Lock()
+
+	for _, key := range ledger.keys {
+		spend := ledger.spend[key]
+		currency := spend.pricing.CurrencyOrDefault()
+
+		msg := fmt.Sprintf("estimated spend of %q: %g %s for %d requests", key, spend.pricing.Spend(spend.requests),
+			currency, spend.requests)
+
+		if spend.total != nil {
+			msg = fmt.Sprintf("%s, %g %s for %d requests since %s", msg, spend.total.Amount, spend.total.Currency,
+				spend.total.Requests, spend.total.Since.Format("2006-01-02"))
+		}
+
+		logger.Info(tools.LogFormatter{Msg: msg}.String())
+	}
+
+	ledger.mu.Unlock()
+
+	logger.Info(tools.LogFormatter{Msg: fmt.Sprintf("estimated spend of the run: %s", ledger.totals())}.String())
+}
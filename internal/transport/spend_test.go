@@ -0,0 +1,93 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/internal/state"
+	"github.com/alpstable/gidari/tools"
+	"github.com/sirupsen/logrus"
+)
+
+func TestSpendLedger(t *testing.T) {
+	t.Parallel()
+
+	if newSpendLedger(&config.Config{Requests: []*config.Request{{Table: "trades"}}}) != nil {
+		t.Fatalf("expected no ledger without a pricing")
+	}
+
+	var ledger *spendLedger
+
+	ledger.add("GET /trades trades", 1)
+	ledger.record(nil)
+	ledger.report(logrus.New())
+
+	cfg := &config.Config{Requests: []*config.Request{
+		{Method: "GET", Endpoint: "/trades", Table: "trades", Pricing: &config.Pricing{Price: 2.5, Per: 1000}},
+		{Method: "GET", Endpoint: "/quotes", Table: "quotes", Pricing: &config.Pricing{Price: 0.01, Currency: "eur"}},
+		{Method: "GET", Endpoint: "/candles", Table: "candles"},
+	}}
+
+	clock := tools.NewFakeClock(time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC))
+
+	store, err := state.Open(filepath.Join(t.TempDir(), "gidari.json"), clock)
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+
+	// The spend of a previous run is added to.
+	store.AddSpend("GET /trades trades", 2000, 5, "USD")
+
+	ledger = newSpendLedger(cfg)
+
+	ledger.add("GET /trades trades", 1500)
+	ledger.add("GET /quotes quotes", 100)
+	ledger.add("GET /candles candles", 10)
+	ledger.record(store)
+
+	ledger.add("GET /trades trades", 500)
+	ledger.record(store)
+	ledger.record(store)
+
+	if spend := store.Spend["GET /trades trades"]; spend.Requests != 4000 || spend.Amount != 10 {
+		t.Fatalf("expected a spend of 10 for 4000 requests, got %g for %d", spend.Amount, spend.Requests)
+	}
+
+	if spend := store.Spend["GET /quotes quotes"]; spend.Requests != 100 || spend.Amount != 1 || spend.Currency != "EUR" {
+		t.Fatalf("expected a spend of 1 EUR for 100 requests, got %+v", spend)
+	}
+
+	if _, ok := store.Spend["GET /candles candles"]; ok {
+		t.Fatalf("expected no spend for a request without a pricing")
+	}
+
+	var buf bytes.Buffer
+
+	logger := logrus.New()
+	logger.SetOutput(&buf)
+
+	ledger.report(logger)
+
+	for _, want := range []string{
+		`estimated spend of \"GET /trades trades\": 5 USD for 2000 requests, 10 USD for 4000 requests since 2022-06-01`,
+		`estimated spend of \"GET /quotes quotes\": 1 EUR for 100 requests, 1 EUR for 100 requests since 2022-06-01`,
+		"estimated spend of the run: 1 EUR, 5 USD",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("expected the report to contain %q, got %q", want, buf.String())
+		}
+	}
+}
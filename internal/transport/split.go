@@ -0,0 +1,106 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/alpstable/gidari/internal/proto"
+	"github.com/alpstable/gidari/internal/repository"
+	"github.com/alpstable/gidari/tools"
+)
+
+// batchSplit is what was learned about the size that storage accepts by splitting the batches that it rejected.
+type batchSplit struct {
+	// splits is the number of times that a batch was split.
+	splits int
+
+	// records and size are the number of records and bytes of the largest batch that was accepted once it was
+	// split.
+	records, size int
+}
+
+// accept will record a part of a split batch that was accepted without being split again.
+func (split *batchSplit) accept(records, size int) {
+	if size > split.size {
+		split.records, split.size = records, size
+	}
+}
+
+// upsertSplit will upsert a request, and if the storage rejects it for its size, split the records that were not
+// written into halves and upsert each of them in turn the same way, until a single record is rejected.
+func upsertSplit(ctx context.Context, repo repository.Generic, job *repoJob, req *proto.UpsertRequest,
+	split *batchSplit,
+) (*proto.UpsertResponse, error) {
+	rsp, err := repo.Upsert(ctx, req)
+
+	var tooLarge *proto.BatchTooLargeError
+	if err == nil || !errors.As(err, &tooLarge) {
+		return rsp, err //nolint:wrapcheck // the error is wrapped by the transaction
+	}
+
+	records, decodeErr := appendRecords(nil, req.Data)
+	if decodeErr != nil || tooLarge.Written >= len(records) {
+		return nil, err //nolint:wrapcheck // the error is wrapped by the transaction
+	}
+
+	// Records that were written before the request was rejected are not written again.
+	records = records[tooLarge.Written:]
+	if len(records) < 2 {
+		return nil, err //nolint:wrapcheck // a single record cannot be split
+	}
+
+	split.splits++
+
+	total := &proto.UpsertResponse{}
+	half := len(records) / 2
+
+	for _, part := range [][]json.RawMessage{records[:half], records[half:]} {
+		data, err := json.Marshal(part)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode split batch: %w", err)
+		}
+
+		splits := split.splits
+
+		partRsp, err := upsertSplit(ctx, repo, job, job.upsertRequest(data), split)
+		if err != nil {
+			return nil, err
+		}
+
+		if split.splits == splits {
+			split.accept(len(part), len(data))
+		}
+
+		total.UpsertedCount += partRsp.GetUpsertedCount()
+		total.MatchedCount += partRsp.GetMatchedCount()
+	}
+
+	return total, nil
+}
+
+// logSplit will log how often the batch of an upsert request was split for a sink, and the largest batch that the sink
+// accepted, which is the effective ceiling on the size of its batches.
+func logSplit(workerID int, cfg *repoConfig, sink string, req *proto.UpsertRequest, split *batchSplit) {
+	msg := fmt.Sprintf("%s rejected a batch of %d bytes for %s as too large, split it %d times", sink, len(req.Data),
+		req.Table, split.splits)
+	if split.size > 0 {
+		msg = fmt.Sprintf("%s; the largest batch it accepted was %d records of %d bytes", msg, split.records,
+			split.size)
+	}
+
+	logWarn := tools.LogFormatter{
+		WorkerID:   workerID,
+		WorkerName: "repository",
+		Msg:        msg,
+	}
+	cfg.logger.Warn(logWarn.String())
+}
@@ -0,0 +1,107 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/alpstable/gidari/internal/proto"
+	"github.com/alpstable/gidari/internal/repository"
+)
+
+// splitStorage rejects upserts of more than "limit" records as too large, after writing "written" of them.
+type splitStorage struct {
+	repository.Generic
+
+	limit, written int
+	upserted       []json.RawMessage
+}
+
+func (stg *splitStorage) Upsert(_ context.Context, req *proto.UpsertRequest) (*proto.UpsertResponse, error) {
+	records, err := appendRecords(nil, req.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) > stg.limit {
+		written := stg.written
+		if written > len(records) {
+			written = len(records)
+		}
+
+		stg.upserted = append(stg.upserted, records[:written]...)
+
+		return nil, &proto.BatchTooLargeError{Written: written, Err: fmt.Errorf("%d records", len(records))}
+	}
+
+	stg.upserted = append(stg.upserted, records...)
+
+	return &proto.UpsertResponse{UpsertedCount: int64(len(records))}, nil
+}
+
+func TestUpsertSplit(t *testing.T) {
+	t.Parallel()
+
+	records := make([]json.RawMessage, 10)
+	for idx := range records {
+		records[idx] = json.RawMessage(fmt.Sprintf(`{"id":%d}`, idx))
+	}
+
+	data, _ := json.Marshal(records)
+	job := &repoJob{table: "trades"}
+
+	for _, tcase := range []struct {
+		name    string
+		stg     *splitStorage
+		splits  int
+		records int
+		err     error
+	}{
+		{name: "accepted", stg: &splitStorage{limit: 10}},
+		{name: "halves", stg: &splitStorage{limit: 5}, splits: 1, records: 5},
+		{name: "repeatedly", stg: &splitStorage{limit: 3}, splits: 3, records: 3},
+		{name: "partially written", stg: &splitStorage{limit: 4, written: 2}, splits: 1, records: 4},
+		{name: "single record", stg: &splitStorage{limit: 0}, err: proto.ErrBatchTooLarge},
+	} {
+		split := new(batchSplit)
+
+		rsp, err := upsertSplit(context.Background(), tcase.stg, job, job.upsertRequest(data), split)
+		if !errors.Is(err, tcase.err) {
+			t.Fatalf("%s: expected error %v, got %v", tcase.name, tcase.err, err)
+		}
+
+		if tcase.err != nil {
+			continue
+		}
+
+		if len(tcase.stg.upserted) != len(records) {
+			t.Fatalf("%s: expected every record to be upserted once, got %s", tcase.name, tcase.stg.upserted)
+		}
+
+		for idx, record := range tcase.stg.upserted {
+			if string(record) != string(records[idx]) {
+				t.Fatalf("%s: expected the records in order, got %s", tcase.name, tcase.stg.upserted)
+			}
+		}
+
+		if split.splits != tcase.splits || split.records != tcase.records {
+			t.Fatalf("%s: expected %d splits accepting %d records, got %+v", tcase.name, tcase.splits, tcase.records,
+				split)
+		}
+
+		if tcase.splits == 0 && rsp.GetUpsertedCount() != int64(len(records)) {
+			t.Fatalf("%s: expected every record to be counted, got %d", tcase.name, rsp.GetUpsertedCount())
+		}
+	}
+}
@@ -0,0 +1,419 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/maphash"
+	"math"
+	"math/bits"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/internal/proto"
+	"github.com/alpstable/gidari/tools"
+)
+
+// statsTableSuffix is appended to a request's table name to get the name of the table that column statistics are
+// recorded in.
+const statsTableSuffix = "_stats"
+
+// statsTable returns the name of the side table that the column statistics of "table" are recorded in.
+func statsTable(table string) string {
+	return table + statsTableSuffix
+}
+
+// statsColumns are the columns of a "<table>_stats" side table, which are created with the table even if the
+// records that it is created from omit them.
+var statsColumns = []*proto.Column{
+	{Name: "id", Kind: proto.KindString},
+	{Name: "run_id", Kind: proto.KindString},
+	{Name: "table", Kind: proto.KindString},
+	{Name: "column", Kind: proto.KindString},
+	{Name: "row_count", Kind: proto.KindInteger},
+	{Name: "null_count", Kind: proto.KindInteger},
+	{Name: "null_pct", Kind: proto.KindNumber},
+	{Name: "min", Kind: proto.KindString},
+	{Name: "max", Kind: proto.KindString},
+	{Name: "distinct_estimate", Kind: proto.KindInteger},
+	{Name: "computed_at", Kind: proto.KindTimestamp},
+}
+
+// statsWrite returns how the column statistics of a table are written. The side table is keyed by the ID of the
+// statistics, and is created with the type mapping of the table.
+func statsWrite(write tableWrite) tableWrite {
+	if write.autoCreate == nil {
+		return tableWrite{}
+	}
+
+	return tableWrite{
+		autoCreate: &config.AutoCreate{PrimaryKeys: []string{"id"}, TypeMapping: write.autoCreate.TypeMapping},
+		columns:    statsColumns,
+	}
+}
+
+// hllPrecision is the number of bits of a hash that select the register of a "hyperLogLog", which has a standard
+// error of about 1.6% with 4096 registers.
+const hllPrecision = 12
+
+// hllSeed is the seed that values are hashed with, which is the same for every sketch of the run so that they can
+// be merged.
+var hllSeed = maphash.MakeSeed()
+
+// hyperLogLog estimates the number of distinct values that it has observed with a fixed amount of memory.
+type hyperLogLog struct {
+	registers [1 << hllPrecision]uint8
+}
+
+// add will observe a value.
+func (hll *hyperLogLog) add(val []byte) {
+	var hash maphash.Hash
+
+	hash.SetSeed(hllSeed)
+	hash.Write(val)
+
+	sum := hash.Sum64()
+	idx := sum >> (64 - hllPrecision)
+	rank := uint8(bits.LeadingZeros64(sum<<hllPrecision|1<<(hllPrecision-1)) + 1)
+
+	if rank > hll.registers[idx] {
+		hll.registers[idx] = rank
+	}
+}
+
+// merge will observe the values of another sketch.
+func (hll *hyperLogLog) merge(other *hyperLogLog) {
+	for idx, rank := range other.registers {
+		if rank > hll.registers[idx] {
+			hll.registers[idx] = rank
+		}
+	}
+}
+
+// estimate returns the estimated number of distinct values, using linear counting for small cardinalities.
+func (hll *hyperLogLog) estimate() int64 {
+	size := float64(len(hll.registers))
+
+	var (
+		sum   float64
+		zeros int
+	)
+
+	for _, rank := range hll.registers {
+		sum += math.Ldexp(1, -int(rank))
+
+		if rank == 0 {
+			zeros++
+		}
+	}
+
+	est := 0.7213 / (1 + 1.079/size) * size * size / sum
+	if est <= 2.5*size && zeros > 0 {
+		est = size * math.Log(size/float64(zeros))
+	}
+
+	return int64(math.Round(est))
+}
+
+// columnStat are the statistics of the values of a column.
+type columnStat struct {
+	// present is the number of records with a value that is not null.
+	present int
+
+	// min and max are the smallest and largest numbers or strings of the column, compared like "proto.LessValue".
+	min, max interface{}
+
+	distinct hyperLogLog
+}
+
+// observe will add a value that is not null to the statistics.
+func (stat *columnStat) observe(raw json.RawMessage) {
+	stat.present++
+	stat.distinct.add(raw)
+
+	var val interface{}
+
+	switch raw[0] {
+	case '"':
+		var str string
+		if json.Unmarshal(raw, &str) != nil {
+			return
+		}
+
+		val = str
+	case '{', '[', 't', 'f':
+		return
+	default:
+		num, err := strconv.ParseFloat(string(raw), 64)
+		if err != nil {
+			return
+		}
+
+		val = num
+	}
+
+	if stat.min == nil || proto.LessValue(val, stat.min) {
+		stat.min = val
+	}
+
+	if stat.max == nil || proto.LessValue(stat.max, val) {
+		stat.max = val
+	}
+}
+
+// merge will add the statistics of the same column from another set of records.
+func (stat *columnStat) merge(other *columnStat) {
+	stat.present += other.present
+	stat.distinct.merge(&other.distinct)
+
+	if other.min != nil && (stat.min == nil || proto.LessValue(other.min, stat.min)) {
+		stat.min = other.min
+	}
+
+	if other.max != nil && (stat.max == nil || proto.LessValue(stat.max, other.max)) {
+		stat.max = other.max
+	}
+}
+
+// tableStats are the statistics of the columns of the records written to a table.
+type tableStats struct {
+	rows    int
+	columns map[string]*columnStat
+
+	// sinks and write are how the statistics are written, from the job that first wrote to the table.
+	sinks []string
+	write tableWrite
+}
+
+// merge will add the statistics of the other records of the table.
+func (stats *tableStats) merge(other *tableStats) {
+	stats.rows += other.rows
+
+	for name, stat := range other.columns {
+		if _, ok := stats.columns[name]; !ok {
+			stats.columns[name] = new(columnStat)
+		}
+
+		stats.columns[name].merge(stat)
+	}
+}
+
+// columnStats collects the statistics of the columns of the tables with "columnStats", of a batch or of the run.
+type columnStats struct {
+	mu     sync.Mutex
+	tables map[string]*tableStats
+}
+
+// newColumnStats returns the column statistics of a run, or nil if no request of the configuration collects them.
+func newColumnStats(cfg *config.Config) *columnStats {
+	for _, req := range cfg.Requests {
+		if req.ColumnStats {
+			return &columnStats{tables: make(map[string]*tableStats)}
+		}
+	}
+
+	return nil
+}
+
+// batch returns the column statistics of a batch of the run, which are merged into the run once it is committed.
+func (cs *columnStats) batch() *columnStats {
+	if cs == nil {
+		return nil
+	}
+
+	return &columnStats{tables: make(map[string]*tableStats)}
+}
+
+// table returns the statistics of a table, adding them if they do not exist.
+func (cs *columnStats) table(name string, sinks []string, write tableWrite) *tableStats {
+	stats, ok := cs.tables[name]
+	if !ok {
+		stats = &tableStats{columns: make(map[string]*columnStat), sinks: sinks, write: write}
+		cs.tables[name] = stats
+	}
+
+	return stats
+}
+
+// observe will add the records of an upsert to the statistics of the table of the job. Records that are not
+// objects are not observed.
+func (cs *columnStats) observe(job *repoJob, data []byte) error {
+	if cs == nil || !job.write.columnStats {
+		return nil
+	}
+
+	records, err := appendRecords(nil, data)
+	if err != nil {
+		return err
+	}
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	stats := cs.table(job.table, job.sinks, job.write)
+
+	for _, record := range records {
+		var fields map[string]json.RawMessage
+		if json.Unmarshal(record, &fields) != nil {
+			continue
+		}
+
+		stats.rows++
+
+		for name, raw := range fields {
+			stat, ok := stats.columns[name]
+			if !ok {
+				stat = new(columnStat)
+				stats.columns[name] = stat
+			}
+
+			if len(raw) > 0 && string(raw) != "null" {
+				stat.observe(raw)
+			}
+		}
+	}
+
+	return nil
+}
+
+// merge will add the statistics of a batch that was committed to the statistics of the run.
+func (cs *columnStats) merge(batch *columnStats) {
+	if cs == nil || batch == nil {
+		return
+	}
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	for name, stats := range batch.tables {
+		cs.table(name, stats.sinks, stats.write).merge(stats)
+	}
+}
+
+// statsValue returns the string of the smallest or largest value of a column, or nil if it has none.
+func statsValue(val interface{}) *string {
+	var str string
+
+	switch val := val.(type) {
+	case nil:
+		return nil
+	case float64:
+		str = strconv.FormatFloat(val, 'g', -1, 64)
+	default:
+		str = fmt.Sprint(val)
+	}
+
+	return &str
+}
+
+// columnStatsRecord is a row of a "<table>_stats" side table: the statistics of a column of the records that a run
+// wrote to the table.
+type columnStatsRecord struct {
+	// ID is a hash of the run, table and column, so that every batch of a run updates the statistics that the
+	// earlier batches recorded rather than duplicating them.
+	ID string `json:"id"`
+
+	RunID  string `json:"run_id"`
+	Table  string `json:"table"`
+	Column string `json:"column"`
+
+	RowCount    int     `json:"row_count"`
+	NullCount   int     `json:"null_count"`
+	NullPercent float64 `json:"null_pct"`
+
+	Min *string `json:"min,omitempty"`
+	Max *string `json:"max,omitempty"`
+
+	DistinctEstimate int64     `json:"distinct_estimate"`
+	ComputedAt       time.Time `json:"computed_at"`
+}
+
+// records returns the statistics of every column of the table, ordered by column. Records without a column count
+// as a null value of it.
+func (stats *tableStats) records(runID, table string, computedAt time.Time) []*columnStatsRecord {
+	names := make([]string, 0, len(stats.columns))
+	for name := range stats.columns {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	records := make([]*columnStatsRecord, 0, len(names))
+
+	for _, name := range names {
+		stat := stats.columns[name]
+		sum := sha256.Sum256([]byte(runID + " " + table + " " + name))
+
+		record := &columnStatsRecord{
+			ID:               hex.EncodeToString(sum[:]),
+			RunID:            runID,
+			Table:            table,
+			Column:           name,
+			RowCount:         stats.rows,
+			NullCount:        stats.rows - stat.present,
+			Min:              statsValue(stat.min),
+			Max:              statsValue(stat.max),
+			DistinctEstimate: stat.distinct.estimate(),
+			ComputedAt:       computedAt.UTC(),
+		}
+
+		if stats.rows > 0 {
+			record.NullPercent = 100 * float64(record.NullCount) / float64(stats.rows)
+		}
+
+		records = append(records, record)
+	}
+
+	return records
+}
+
+// writeColumnStats will upsert the statistics of the tables that the batch wrote to, over the whole run, to their
+// "<table>_stats" side tables in the transactions of the batch.
+func writeColumnStats(cfg *repoConfig, run *columnStats, runID string, computedAt time.Time) error {
+	if run == nil || cfg.stats == nil || len(cfg.stats.tables) == 0 {
+		return nil
+	}
+
+	merged := run.batch()
+	merged.merge(run)
+	merged.merge(cfg.stats)
+
+	// Only the tables that the batch wrote to have new statistics.
+	names := make([]string, 0, len(cfg.stats.tables))
+	for name := range cfg.stats.tables {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		stats := merged.tables[name]
+
+		data, err := json.Marshal(stats.records(runID, name, computedAt))
+		if err != nil {
+			return fmt.Errorf("failed to marshal column statistics: %w", err)
+		}
+
+		job := &repoJob{table: statsTable(name), sinks: stats.sinks, write: statsWrite(stats.write)}
+		upsertRepos(0, cfg, job, job.upsertRequest(data))
+
+		logInfo := tools.LogFormatter{
+			WorkerName: "repository",
+			Msg:        fmt.Sprintf("recorded statistics of %d columns of %s", len(stats.columns), name),
+		}
+		cfg.logger.Debug(logInfo.String())
+	}
+
+	return nil
+}
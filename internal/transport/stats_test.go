@@ -0,0 +1,107 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alpstable/gidari/config"
+)
+
+func TestHyperLogLog(t *testing.T) {
+	t.Parallel()
+
+	for _, count := range []int{0, 10, 1000, 100000} {
+		var hll hyperLogLog
+
+		// Every value is observed twice, which is not counted twice.
+		for idx := 0; idx < 2*count; idx++ {
+			hll.add([]byte(strconv.Itoa(idx % count)))
+		}
+
+		est := hll.estimate()
+		if math.Abs(float64(est)-float64(count)) > 0.05*float64(count) {
+			t.Fatalf("%d: expected an estimate within 5%%, got %d", count, est)
+		}
+	}
+}
+
+func TestColumnStats(t *testing.T) {
+	t.Parallel()
+
+	cfg := &config.Config{Requests: []*config.Request{{ColumnStats: true}}}
+
+	run := newColumnStats(cfg)
+	if run == nil {
+		t.Fatalf("expected column statistics for a request with columnStats")
+	}
+
+	if newColumnStats(&config.Config{Requests: []*config.Request{{}}}) != nil {
+		t.Fatalf("expected no column statistics without columnStats")
+	}
+
+	job := &repoJob{table: "trades", write: tableWrite{columnStats: true}}
+
+	first, second := run.batch(), run.batch()
+
+	for _, tcase := range []struct {
+		batch *columnStats
+		data  string
+	}{
+		{batch: first, data: `[{"id":1,"price":"9.5","side":"buy"},{"id":2,"price":null,"side":"sell"}]`},
+		{batch: first, data: `{"id":3,"side":"buy","size":{"amount":1}}`},
+		{batch: second, data: `[{"id":10,"price":"12","side":"buy"},"not a record"]`},
+	} {
+		if err := tcase.batch.observe(job, []byte(tcase.data)); err != nil {
+			t.Fatalf("failed to observe %s: %v", tcase.data, err)
+		}
+	}
+
+	// Jobs of tables without columnStats are not observed.
+	if err := first.observe(&repoJob{table: "other"}, []byte(`[{"id":1}]`)); err != nil || len(first.tables) != 1 {
+		t.Fatalf("expected only the table with columnStats to be observed, got %d tables", len(first.tables))
+	}
+
+	run.merge(first)
+	run.merge(second)
+
+	computedAt := time.Date(2022, 10, 14, 15, 4, 5, 0, time.UTC)
+
+	got := make(map[string]string)
+	for _, record := range run.tables["trades"].records("run", "trades", computedAt) {
+		if record.RunID != "run" || record.Table != "trades" || !record.ComputedAt.Equal(computedAt) ||
+			len(record.ID) != 64 {
+			t.Fatalf("expected the record to identify the run, table and column, got %+v", record)
+		}
+
+		var min, max string
+		if record.Min != nil {
+			min, max = *record.Min, *record.Max
+		}
+
+		got[record.Column] = fmt.Sprintf("%d %d %.0f %s %s %d", record.RowCount, record.NullCount,
+			record.NullPercent, min, max, record.DistinctEstimate)
+	}
+
+	for column, expected := range map[string]string{
+		"id":    "4 0 0 1 10 4",
+		"price": "4 2 50 12 9.5 2",
+		"side":  "4 0 0 buy sell 2",
+		"size":  "4 3 75   1",
+	} {
+		if got[column] != expected {
+			t.Fatalf("%s: expected %q, got %q", column, expected, got[column])
+		}
+	}
+}
@@ -0,0 +1,276 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/tools"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+)
+
+// workerIdle is the state of a worker that is waiting for a job.
+const workerIdle = "idle"
+
+// workerStatus is what a worker is doing, and since when.
+type workerStatus struct {
+	name  string
+	id    int
+	state string
+	since time.Time
+}
+
+// runLimiter is a rate limiter of a run, with the requests that share it.
+type runLimiter struct {
+	limiter  *rate.Limiter
+	requests []string
+}
+
+// runStatus tracks the progress of a run, and what its workers are doing, so that it can be logged on demand with
+// "Config.Dump". A nil "runStatus" tracks nothing.
+type runStatus struct {
+	clock    tools.Clock
+	started  time.Time
+	limiters []*runLimiter
+
+	mu       sync.Mutex
+	planned  int
+	finished int
+	batch    int
+	batches  int
+	parked   int
+	workers  map[string]*workerStatus
+	webJobs  chan *webJob
+	repoJobs chan *repoJob
+}
+
+// newRunStatus will return the status of a run of "fetches", or nil if the configuration has no "Dump" channel.
+func newRunStatus(cfg *config.Config, fetches []*flattenedRequest, batches int) *runStatus {
+	if cfg.Dump == nil {
+		return nil
+	}
+
+	clock := tools.ClockOrReal(cfg.Clock)
+
+	status := &runStatus{
+		clock:   clock,
+		started: clock.Now(),
+		planned: len(fetches),
+		batches: batches,
+		workers: make(map[string]*workerStatus),
+	}
+
+	// Requests share the limiter of the "rateLimit" configuration unless they set their own.
+	byLimiter := make(map[*rate.Limiter]*runLimiter)
+
+	for _, req := range fetches {
+		limiter := req.fetchConfig.RateLimiter
+		if limiter == nil {
+			continue
+		}
+
+		if byLimiter[limiter] == nil {
+			byLimiter[limiter] = &runLimiter{limiter: limiter}
+			status.limiters = append(status.limiters, byLimiter[limiter])
+		}
+
+		lim := byLimiter[limiter]
+		if len(lim.requests) == 0 || lim.requests[len(lim.requests)-1] != req.requestKey {
+			lim.requests = append(lim.requests, req.requestKey)
+		}
+	}
+
+	return status
+}
+
+// startBatch will record the queues of the batch that the run is working on.
+func (status *runStatus) startBatch(webJobs chan *webJob, repoJobs chan *repoJob) {
+	if status == nil {
+		return
+	}
+
+	status.mu.Lock()
+	defer status.mu.Unlock()
+
+	status.batch++
+	status.webJobs = webJobs
+	status.repoJobs = repoJobs
+}
+
+// setWorker will record what a worker is doing.
+func (status *runStatus) setWorker(name string, id int, state string) {
+	if status == nil {
+		return
+	}
+
+	status.mu.Lock()
+	defer status.mu.Unlock()
+
+	key := fmt.Sprintf("%s %d", name, id)
+	if cur := status.workers[key]; cur != nil && cur.state == state {
+		return
+	}
+
+	status.workers[key] = &workerStatus{name: name, id: id, state: state, since: status.clock.Now()}
+}
+
+// finish will count a web job that the run has finished with, whether it was made, failed, skipped or canceled.
+func (status *runStatus) finish() {
+	if status == nil {
+		return
+	}
+
+	status.mu.Lock()
+	defer status.mu.Unlock()
+
+	status.finished++
+}
+
+// park will count a web job that is held until its paused requests are resumed or canceled, and "unpark" is
+// called when it is released.
+func (status *runStatus) park() (unpark func()) {
+	if status == nil {
+		return func() {}
+	}
+
+	status.mu.Lock()
+	defer status.mu.Unlock()
+
+	status.parked++
+
+	return func() {
+		status.mu.Lock()
+		defer status.mu.Unlock()
+
+		status.parked--
+	}
+}
+
+// limiterStatus describes a rate limiter, e.g. "5.00/s, burst 10, next request in 200ms". Since a limiter does not
+// expose its tokens, the wait is measured by reserving a token and giving it back.
+func limiterStatus(limiter *rate.Limiter, now time.Time) string {
+	limit := "unlimited"
+	if lim := limiter.Limit(); lim != rate.Inf {
+		limit = fmt.Sprintf("%.2f/s", float64(lim))
+	}
+
+	reservation := limiter.ReserveN(now, 1)
+	if !reservation.OK() {
+		return fmt.Sprintf("%s, burst %d, no requests allowed", limit, limiter.Burst())
+	}
+
+	delay := reservation.DelayFrom(now)
+	reservation.CancelAt(now)
+
+	return fmt.Sprintf("%s, burst %d, next request in %s", limit, limiter.Burst(), delay)
+}
+
+// lines returns the log lines of the status of the run.
+func (status *runStatus) lines(res *runResources) []tools.LogFormatter {
+	status.mu.Lock()
+	defer status.mu.Unlock()
+
+	now := status.clock.Now()
+
+	progress := fmt.Sprintf("status: %d of %d requests finished, batch %d of %d", status.finished, status.planned,
+		status.batch, status.batches)
+	if res.budget != nil {
+		progress = fmt.Sprintf("%s, using %s", progress, res.budget.summary())
+	}
+
+	queues := fmt.Sprintf("status: %d web jobs parked", status.parked)
+	if status.webJobs != nil {
+		queues = fmt.Sprintf("status: web queue %d of %d, repository queue %d of %d, %d web jobs parked",
+			len(status.webJobs), cap(status.webJobs), len(status.repoJobs), cap(status.repoJobs), status.parked)
+	}
+
+	if res.memory.degraded() {
+		queues += ", memory degraded"
+	}
+
+	lines := []tools.LogFormatter{
+		{Duration: now.Sub(status.started), Msg: progress},
+		{Msg: queues},
+	}
+
+	workers := make([]*workerStatus, 0, len(status.workers))
+	for _, worker := range status.workers {
+		workers = append(workers, worker)
+	}
+
+	sort.Slice(workers, func(i, j int) bool {
+		if workers[i].name != workers[j].name {
+			return workers[i].name > workers[j].name
+		}
+
+		return workers[i].id < workers[j].id
+	})
+
+	for _, worker := range workers {
+		lines = append(lines, tools.LogFormatter{
+			WorkerID:   worker.id,
+			WorkerName: worker.name,
+			Duration:   now.Sub(worker.since),
+			Msg:        "status: " + worker.state,
+		})
+	}
+
+	for _, lim := range status.limiters {
+		lines = append(lines, tools.LogFormatter{
+			Msg: fmt.Sprintf("status: rate limiter of %d requests (%s): %s", len(lim.requests), lim.requests[0],
+				limiterStatus(lim.limiter, now)),
+		})
+	}
+
+	return lines
+}
+
+// dump will log the status of the run.
+func (status *runStatus) dump(logger *logrus.Logger, res *runResources) {
+	if status == nil {
+		return
+	}
+
+	for _, line := range status.lines(res) {
+		logger.Info(line.String())
+	}
+}
+
+// listen will log the status of the run each time "dumps" receives, until the returned function is called.
+func (status *runStatus) listen(dumps <-chan struct{}, logger *logrus.Logger, res *runResources) (stop func()) {
+	if status == nil {
+		return func() {}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-dumps:
+				status.dump(logger, res)
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
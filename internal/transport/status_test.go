@@ -0,0 +1,108 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/internal/web"
+	"github.com/alpstable/gidari/tools"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+)
+
+func TestRunStatus(t *testing.T) {
+	t.Parallel()
+
+	if newRunStatus(&config.Config{}, nil, 1) != nil {
+		t.Fatalf("expected no status without a dump channel")
+	}
+
+	var status *runStatus
+
+	status.setWorker("web", 1, workerIdle)
+	status.finish()
+	status.park()()
+	status.dump(logrus.New(), &runResources{})
+	status.listen(nil, logrus.New(), &runResources{})()
+
+	clock := tools.NewFakeClock(time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC))
+	shared := rate.NewLimiter(rate.Every(time.Second), 1)
+
+	fetches := []*flattenedRequest{
+		{fetchConfig: &web.FetchConfig{RateLimiter: shared}, requestKey: "GET /trades trades", page: 1},
+		{fetchConfig: &web.FetchConfig{RateLimiter: shared}, requestKey: "GET /trades trades", page: 2},
+		{fetchConfig: &web.FetchConfig{RateLimiter: shared}, requestKey: "GET /quotes quotes"},
+		{fetchConfig: &web.FetchConfig{RateLimiter: rate.NewLimiter(rate.Inf, 1)}, requestKey: "GET /candles candles"},
+	}
+
+	dumps := make(chan struct{})
+
+	status = newRunStatus(&config.Config{Dump: dumps, Clock: clock}, fetches, 2)
+
+	// The shared limiter has no tokens left until a second has passed.
+	shared.ReserveN(clock.Now(), 1)
+
+	webJobs := make(chan *webJob, 4)
+	webJobs <- &webJob{}
+
+	status.startBatch(webJobs, make(chan *repoJob, 8))
+	status.setWorker("web", 2, workerIdle)
+	status.setWorker("web", 1, "requesting GET /trades trades, page 2")
+	status.setWorker("repository", 1, "upserting trades")
+	status.finish()
+
+	unpark := status.park()
+	status.park()
+	unpark()
+
+	clock.Advance(300 * time.Millisecond)
+
+	var out bytes.Buffer
+
+	logger := logrus.New()
+	logger.SetOutput(&out)
+
+	res := &runResources{budget: newBudget(&config.Limits{MaxRequests: 10}, nil)}
+
+	stop := status.listen(dumps, logger, res)
+	dumps <- struct{}{}
+	stop()
+
+	want := []string{
+		"status: 1 of 4 requests finished, batch 1 of 2, using 0 requests, 0 rows, cost 0",
+		"status: web queue 1 of 4, repository queue 0 of 8, 1 web jobs parked",
+		"w:1, worker:web, d:300ms, m:status: requesting GET /trades trades, page 2",
+		"w:2, worker:web, d:300ms, m:status: idle",
+		"w:1, worker:repository, d:300ms, m:status: upserting trades",
+		"status: rate limiter of 2 requests (GET /trades trades): 1.00/s, burst 1, next request in 700ms",
+		"status: rate limiter of 1 requests (GET /candles candles): unlimited, burst 1, next request in 0s",
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != len(want) {
+		t.Fatalf("expected %d lines, got %d: %s", len(want), len(lines), out.String())
+	}
+
+	for idx, line := range lines {
+		if !strings.Contains(line, want[idx]) {
+			t.Errorf("expected line %d to contain %q, got %q", idx, want[idx], line)
+		}
+	}
+
+	// Measuring the wait of a limiter does not use its tokens.
+	if got := limiterStatus(shared, clock.Now()); !strings.HasSuffix(got, "next request in 700ms") {
+		t.Fatalf("expected the wait to be unchanged, got %q", got)
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/tools"
+)
+
+// stopCondition tracks the "stopWhen" conditions for the chunks of a single timeseries request. The condition is
+// shared by every flattened request of the timeseries, and once it is met the remaining chunks are skipped. A nil
+// "stopCondition" is never met.
+type stopCondition struct {
+	cfg *config.StopWhen
+
+	mu     sync.Mutex
+	rows   int
+	reason string
+}
+
+// newStopCondition will return a stop condition for the configuration, or nil if there is no configuration.
+func newStopCondition(cfg *config.StopWhen) *stopCondition {
+	if cfg == nil {
+		return nil
+	}
+
+	return &stopCondition{cfg: cfg}
+}
+
+// met will return the reason that the condition was met, or an empty string if it has not been met.
+func (stop *stopCondition) met() string {
+	if stop == nil {
+		return ""
+	}
+
+	stop.mu.Lock()
+	defer stop.mu.Unlock()
+
+	return stop.reason
+}
+
+// observeRecords will check the field thresholds and the row limit against a batch of records from a chunk.
+func (stop *stopCondition) observeRecords(records []interface{}) {
+	if stop == nil {
+		return
+	}
+
+	stop.mu.Lock()
+	defer stop.mu.Unlock()
+
+	if stop.reason != "" {
+		return
+	}
+
+	stop.rows += len(records)
+	if stop.cfg.MaxRows > 0 && stop.rows >= stop.cfg.MaxRows {
+		stop.reason = fmt.Sprintf("received %d rows, limit is %d", stop.rows, stop.cfg.MaxRows)
+
+		return
+	}
+
+	if stop.cfg.Field == "" {
+		return
+	}
+
+	for _, record := range records {
+		val, err := tools.GetJSONPath(record, stop.cfg.Field)
+		if err != nil {
+			continue
+		}
+
+		num, ok := val.(float64)
+		if !ok {
+			continue
+		}
+
+		if above := stop.cfg.Above; above != nil && num > *above {
+			stop.reason = fmt.Sprintf("%s value %v is above %v", stop.cfg.Field, num, *above)
+
+			return
+		}
+
+		if below := stop.cfg.Below; below != nil && num < *below {
+			stop.reason = fmt.Sprintf("%s value %v is below %v", stop.cfg.Field, num, *below)
+
+			return
+		}
+	}
+}
+
+// observeChunk will check the empty result condition against the total number of records in a chunk.
+func (stop *stopCondition) observeChunk(count int) {
+	if stop == nil {
+		return
+	}
+
+	stop.mu.Lock()
+	defer stop.mu.Unlock()
+
+	if stop.reason == "" && stop.cfg.Empty && count == 0 {
+		stop.reason = "received an empty result"
+	}
+}
+
+// observe will check all of the conditions against the response data of a chunk.
+func (stop *stopCondition) observe(data []byte, spilled string) error {
+	if stop == nil {
+		return nil
+	}
+
+	if spilled == "" {
+		records, err := decodeResponseRecords(data)
+		if err != nil {
+			return err
+		}
+
+		stop.observeRecords(records)
+		stop.observeChunk(len(records))
+
+		return nil
+	}
+
+	count := 0
+
+	err := readSpill(spilled, degradedBatchSize, func(batch []byte) error {
+		records, err := decodeResponseRecords(batch)
+		if err != nil {
+			return err
+		}
+
+		count += len(records)
+		stop.observeRecords(records)
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	stop.observeChunk(count)
+
+	return nil
+}
+
+// decodeResponseRecords will decode a JSON response into a slice of records. A top-level array is a slice of records,
+// anything else is a single record.
+func decodeResponseRecords(data []byte) ([]interface{}, error) {
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	if records, ok := doc.([]interface{}); ok {
+		return records, nil
+	}
+
+	return []interface{}{doc}, nil
+}
@@ -0,0 +1,71 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"testing"
+
+	"github.com/alpstable/gidari/config"
+)
+
+func TestStopCondition(t *testing.T) {
+	t.Parallel()
+
+	above := 10.0
+
+	for _, tcase := range []struct {
+		name   string
+		cfg    *config.StopWhen
+		chunks []string
+		met    []bool
+	}{
+		{
+			name:   "nil",
+			chunks: []string{`[]`},
+			met:    []bool{false},
+		},
+		{
+			name:   "empty",
+			cfg:    &config.StopWhen{Empty: true},
+			chunks: []string{`[{"id":1}]`, `[]`, `[{"id":2}]`},
+			met:    []bool{false, true, true},
+		},
+		{
+			name:   "max rows",
+			cfg:    &config.StopWhen{MaxRows: 3},
+			chunks: []string{`[{"id":1},{"id":2}]`, `{"id":3}`},
+			met:    []bool{false, true},
+		},
+		{
+			name:   "field above",
+			cfg:    &config.StopWhen{Field: "$.price", Above: &above},
+			chunks: []string{`[{"price":5}]`, `[{"price":"11"}]`, `[{"price":11}]`},
+			met:    []bool{false, false, true},
+		},
+	} {
+		tcase := tcase
+
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			stop := newStopCondition(tcase.cfg)
+
+			for idx, chunk := range tcase.chunks {
+				if err := stop.observe([]byte(chunk), ""); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+
+				if met := stop.met() != ""; met != tcase.met[idx] {
+					t.Fatalf("chunk %d: expected met to be %v, got %v", idx, tcase.met[idx], met)
+				}
+			}
+		})
+	}
+}
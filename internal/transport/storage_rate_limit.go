@@ -0,0 +1,116 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/internal/proto"
+	"github.com/alpstable/gidari/tools"
+	"golang.org/x/time/rate"
+)
+
+// writeLimiter holds back the upserts of every storage target in turn to its "storageRateLimit", keyed by the index
+// of the repository. Targets that are not limited have no limiter.
+type writeLimiter struct {
+	clock    tools.Clock
+	limiters []*rate.Limiter
+}
+
+// newWriteLimiter returns the write limiter of the storage targets of a configuration, or nil if none of them are
+// limited.
+func newWriteLimiter(cfg *config.Config) *writeLimiter {
+	limit := cfg.StorageRateLimit
+	if limit == nil {
+		return nil
+	}
+
+	limiter := &writeLimiter{
+		clock:    tools.ClockOrReal(cfg.Clock),
+		limiters: make([]*rate.Limiter, len(cfg.ConnectionStrings)),
+	}
+	limited := false
+
+	for idx, dns := range cfg.ConnectionStrings {
+		rps := limit.RecordsPerSecondOf(dns)
+		if rps <= 0 {
+			continue
+		}
+
+		limiter.limiters[idx] = rate.NewLimiter(rate.Limit(rps), limit.BurstOf(rps))
+		limited = true
+	}
+
+	if !limited {
+		return nil
+	}
+
+	return limiter
+}
+
+// wait will block until the records of an upsert can be written to the storage target of the repository "idx",
+// returning how long it waited. Upserts with more records than the burst of the target wait for every burst of them.
+func (limiter *writeLimiter) wait(ctx context.Context, idx, records int) (time.Duration, error) {
+	if limiter == nil || idx >= len(limiter.limiters) || limiter.limiters[idx] == nil {
+		return 0, nil
+	}
+
+	lim := limiter.limiters[idx]
+	began := limiter.clock.Now()
+
+	for records > 0 {
+		size := records
+		if burst := lim.Burst(); size > burst {
+			size = burst
+		}
+
+		if err := tools.WaitReservation(ctx, limiter.clock, lim.ReserveN(limiter.clock.Now(), size)); err != nil {
+			return limiter.clock.Now().Sub(began), fmt.Errorf("failed to wait on the storage rate limit: %w", err)
+		}
+
+		records -= size
+	}
+
+	return limiter.clock.Now().Sub(began), nil
+}
+
+// waitWriteLimit will block until the records of an upsert request can be written to the storage target of the
+// repository "idx", logging how long the upsert was held back.
+func waitWriteLimit(ctx context.Context, workerID int, cfg *repoConfig, idx int, sink string,
+	req *proto.UpsertRequest,
+) error {
+	if cfg.writeLimit == nil {
+		return nil
+	}
+
+	records, err := appendRecords(nil, req.Data)
+	if err != nil {
+		return err
+	}
+
+	waited, err := cfg.writeLimit.wait(ctx, idx, len(records))
+	if err != nil {
+		return err
+	}
+
+	if waited > 0 {
+		msg := fmt.Sprintf("held %d records of %s back for the storage rate limit of %s", len(records), req.Table, sink)
+		logInfo := tools.LogFormatter{
+			WorkerID:   workerID,
+			WorkerName: "repository",
+			Duration:   waited,
+			Msg:        msg,
+		}
+		cfg.logger.Debug(logInfo.String())
+	}
+
+	return nil
+}
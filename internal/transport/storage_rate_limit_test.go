@@ -0,0 +1,72 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/tools"
+)
+
+func TestWriteLimiter(t *testing.T) {
+	t.Parallel()
+
+	clock := tools.NewFakeClock(time.Date(2022, 10, 14, 0, 0, 0, 0, time.UTC))
+
+	limiter := newWriteLimiter(&config.Config{
+		Clock:             clock,
+		ConnectionStrings: []string{"postgresql://db/prod", "mongodb://db/prod", "sqlite://local.db"},
+		StorageRateLimit: &config.StorageRateLimit{
+			RecordsPerSecond: 10,
+			Sinks:            map[string]float64{"mongodb": 100, "sqlite://local.db": 0},
+		},
+	})
+
+	if limiter == nil || limiter.limiters[0].Limit() != 10 || limiter.limiters[1].Burst() != 100 ||
+		limiter.limiters[2] != nil {
+		t.Fatalf("expected the limits of the sinks, got %+v", limiter)
+	}
+
+	ctx := context.Background()
+
+	// The first burst is written at once, and sinks that are not limited never wait.
+	if waited, err := limiter.wait(ctx, 0, 10); err != nil || waited != 0 {
+		t.Fatalf("expected the first burst to be written at once, waited %s: %v", waited, err)
+	}
+
+	if waited, err := limiter.wait(ctx, 2, 1000); err != nil || waited != 0 {
+		t.Fatalf("expected the sink without a limit to be written at once, waited %s: %v", waited, err)
+	}
+
+	done := make(chan time.Duration)
+
+	go func() {
+		waited, _ := limiter.wait(ctx, 0, 15)
+		done <- waited
+	}()
+
+	// An upsert that is larger than the burst waits for every burst of its records.
+	for _, step := range []time.Duration{time.Second, 500 * time.Millisecond} {
+		waitForWaiters(t, clock)
+		clock.Advance(step)
+	}
+
+	if waited := <-done; waited != 1500*time.Millisecond {
+		t.Fatalf("expected to wait 1.5s for 15 records at 10 per second, waited %s", waited)
+	}
+
+	if newWriteLimiter(&config.Config{ConnectionStrings: []string{"sqlite://local.db"},
+		StorageRateLimit: &config.StorageRateLimit{}}) != nil {
+		t.Fatalf("expected no write limiter without limited sinks")
+	}
+}
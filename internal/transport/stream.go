@@ -0,0 +1,240 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/alpstable/gidari/internal/web"
+	"github.com/alpstable/gidari/tools"
+)
+
+// lineBatchSize is the number of records of each repository job of a newline-delimited JSON, CSV or XML response,
+// unless "streamBatchSize" is set.
+const lineBatchSize = 1000
+
+// streamBatchFn is called with each batch of records decoded from a streamed response body.
+type streamBatchFn func([]json.RawMessage) error
+
+// decodeStream will decode the top-level JSON array that "reader" begins with as it is read, calling "batchFn" with
+// batches of at most "size" records. An empty array is a single empty batch, so that its targets are still written
+// to. It returns the number of records that were decoded.
+func decodeStream(reader io.Reader, size int, batchFn streamBatchFn) (int, error) {
+	dec := json.NewDecoder(reader)
+
+	// Consume the opening bracket.
+	if _, err := dec.Token(); err != nil {
+		return 0, fmt.Errorf("failed to decode response body: %w", err)
+	}
+
+	count := 0
+	batch := make([]json.RawMessage, 0, size)
+
+	for dec.More() {
+		var record json.RawMessage
+		if err := dec.Decode(&record); err != nil {
+			return count, fmt.Errorf("failed to decode response body: %w", err)
+		}
+
+		batch = append(batch, record)
+		count++
+
+		if len(batch) == size {
+			if err := batchFn(batch); err != nil {
+				return count, err
+			}
+
+			batch = make([]json.RawMessage, 0, size)
+		}
+	}
+
+	// Consume the closing bracket, so that a truncated body is an error.
+	if _, err := dec.Token(); err != nil {
+		return count, fmt.Errorf("failed to decode response body: %w", err)
+	}
+
+	if len(batch) > 0 || count == 0 {
+		return count, batchFn(batch)
+	}
+
+	return count, nil
+}
+
+// decodeLines will decode the newline-delimited JSON records of "reader" as they are read, calling "batchFn" with
+// batches of at most "size" records. Blank lines are skipped, as are lines that are not valid JSON, which are
+// counted. Like an array, a body without records is a single empty batch. It returns the number of records that were
+// decoded and the number of lines that were skipped.
+func decodeLines(reader *bufio.Reader, size int, batchFn streamBatchFn) (int, int, error) {
+	count, skipped := 0, 0
+	batch := make([]json.RawMessage, 0, size)
+
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return count, skipped, fmt.Errorf("failed to read response body: %w", readErr)
+		}
+
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			if !json.Valid(line) {
+				skipped++
+			} else {
+				batch = append(batch, json.RawMessage(line))
+				count++
+			}
+		}
+
+		done := readErr != nil
+		if len(batch) == size || (done && (len(batch) > 0 || count == 0)) {
+			if err := batchFn(batch); err != nil {
+				return count, skipped, err
+			}
+
+			batch = make([]json.RawMessage, 0, size)
+		}
+
+		if done {
+			return count, skipped, nil
+		}
+	}
+}
+
+// sendStreamBatch will observe a batch of streamed records for the stop conditions and metrics of a web job, and put
+// it onto the repository job channel for every target.
+func sendStreamBatch(job *webJob, targets []*flattenedRequest, rsp *web.FetchResponse, prov *provenance,
+	batch []json.RawMessage,
+) error {
+	data, err := json.Marshal(batch)
+	if err != nil {
+		return fmt.Errorf("failed to marshal streamed records: %w", err)
+	}
+
+	if job.stop != nil {
+		records, err := decodeResponseRecords(data)
+		if err != nil {
+			return err
+		}
+
+		job.stop.observeRecords(records)
+	}
+
+	for _, target := range targets {
+		// A body without records is a single empty batch, which is not written for targets that skip or fail it.
+		if len(batch) == 0 && target.onEmpty != "" && !target.writesEmpty(job.clock.Now()) {
+			continue
+		}
+
+		if err := job.metrics.observe(target.metricDefs, data, ""); err != nil {
+			return err
+		}
+
+		sendRepoJob(job, target, rsp.Request, prov, data, "", true)
+	}
+
+	return nil
+}
+
+// streamSize returns the number of records of each repository job of a streamed response. Since the body is
+// never held in memory, batches are only as large as the degraded batch size when memory is low.
+func (job *webJob) streamSize() int {
+	size := job.streamBatchSize
+	if size <= 0 {
+		size = lineBatchSize
+	}
+
+	if job.memory.degraded() && size > degradedBatchSize {
+		size = degradedBatchSize
+	}
+
+	return size
+}
+
+// streamBody will decode the response body of a web job as it is read, if it is newline-delimited JSON, CSV or XML,
+// or if it is a top-level JSON array and the web job streams its responses, sending its records to the repository
+// workers in batches.
+//
+// It returns false, and a reader of the entire body, if the body is not streamed, so that it is read whole instead.
+func streamBody(job *webJob, targets []*flattenedRequest, rsp *web.FetchResponse, prov *provenance,
+) (io.ReadCloser, bool, int, error) {
+	if job.streamBatchSize <= 0 && !job.ndjson && job.csv == nil && job.xml == nil {
+		return rsp.Body, false, 0, nil
+	}
+
+	reader := bufio.NewReader(rsp.Body)
+	body := struct {
+		io.Reader
+		io.Closer
+	}{reader, rsp.Body}
+
+	batchFn := func(batch []json.RawMessage) error {
+		return sendStreamBatch(job, targets, rsp, prov, batch)
+	}
+
+	var (
+		count int
+		err   error
+	)
+
+	switch {
+	case job.csv != nil:
+		defer rsp.Body.Close()
+
+		if count, err = decodeCSV(reader, job.csv, job.streamSize(), batchFn); err != nil {
+			return nil, true, count, err
+		}
+	case job.xml != nil:
+		defer rsp.Body.Close()
+
+		if count, err = decodeXML(reader, job.xml, job.streamSize(), batchFn); err != nil {
+			return nil, true, count, err
+		}
+	case job.ndjson:
+		defer rsp.Body.Close()
+
+		var skipped int
+		if count, skipped, err = decodeLines(reader, job.streamSize(), batchFn); err != nil {
+			return nil, true, count, err
+		}
+
+		if skipped > 0 {
+			logWarn := tools.LogFormatter{
+				Msg: fmt.Sprintf("skipped %d lines of %s that were invalid JSON", skipped, job.fetchConfig.URL),
+			}
+			job.logger.Warn(logWarn.String())
+		}
+	case job.recordsPath != "":
+		// Bodies that are not JSON documents are left for the "clobColumn".
+		if first, err := firstNonSpace(reader); err != nil || (first != '{' && first != '[') {
+			return body, false, 0, nil
+		}
+
+		defer rsp.Body.Close()
+
+		if count, err = job.streamRecords(reader, batchFn); err != nil {
+			return nil, true, count, err
+		}
+	default:
+		// Bodies that are not arrays, including invalid JSON for the "clobColumn", cannot be split into records.
+		if first, err := firstNonSpace(reader); err != nil || first != '[' {
+			return body, false, 0, nil
+		}
+
+		defer rsp.Body.Close()
+
+		if count, err = decodeStream(reader, job.streamSize(), batchFn); err != nil {
+			return nil, true, count, err
+		}
+	}
+
+	job.stop.observeChunk(count)
+
+	return nil, true, count, nil
+}
@@ -0,0 +1,210 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/internal/web"
+)
+
+func TestDecodeStream(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name  string
+		body  string
+		size  int
+		want  []string
+		count int
+		err   bool
+	}{
+		{name: "batches", body: ` [{"id":1}, {"id":2}, {"id":3}]`, size: 2, want: []string{`[{"id":1},{"id":2}]`,
+			`[{"id":3}]`}, count: 3},
+		{name: "exact", body: `[1,2]`, size: 2, want: []string{`[1,2]`}, count: 2},
+		{name: "empty", body: `[]`, size: 2, want: []string{`[]`}},
+		{name: "truncated", body: `[1,2,3`, size: 2, want: []string{`[1,2]`}, count: 3, err: true},
+	} {
+		var got []string
+
+		count, err := decodeStream(strings.NewReader(tcase.body), tcase.size, func(batch []json.RawMessage) error {
+			data, err := json.Marshal(batch)
+			got = append(got, string(data))
+
+			return err
+		})
+		if (err != nil) != tcase.err {
+			t.Fatalf("%s: expected error %t, got %v", tcase.name, tcase.err, err)
+		}
+
+		if count != tcase.count || !reflect.DeepEqual(got, tcase.want) {
+			t.Fatalf("%s: expected %d records in %v, got %d in %v", tcase.name, tcase.count, tcase.want, count, got)
+		}
+	}
+}
+
+func TestDecodeLines(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name    string
+		body    string
+		want    []string
+		count   int
+		skipped int
+	}{
+		{
+			name:  "batches",
+			body:  "{\"id\":1}\n{\"id\":2}\r\n\n{\"id\":3}",
+			want:  []string{`[{"id":1},{"id":2}]`, `[{"id":3}]`},
+			count: 3,
+		},
+		{name: "trailing newline", body: "{\"id\":1}\n{\"id\":2}\n", want: []string{`[{"id":1},{"id":2}]`}, count: 2},
+		{
+			name:    "invalid lines",
+			body:    "{\"id\":1}\n{\"id\":\n{\"id\":2}\n",
+			want:    []string{`[{"id":1},{"id":2}]`},
+			count:   2,
+			skipped: 1,
+		},
+		{name: "empty", body: "\n", want: []string{`[]`}},
+	} {
+		var got []string
+
+		count, skipped, err := decodeLines(bufio.NewReader(strings.NewReader(tcase.body)), 2,
+			func(batch []json.RawMessage) error {
+				data, err := json.Marshal(batch)
+				got = append(got, string(data))
+
+				return err
+			})
+		if err != nil {
+			t.Fatalf("%s: failed to decode lines: %v", tcase.name, err)
+		}
+
+		if count != tcase.count || skipped != tcase.skipped || !reflect.DeepEqual(got, tcase.want) {
+			t.Fatalf("%s: expected %d records (%d skipped) in %v, got %d (%d skipped) in %v", tcase.name,
+				tcase.count, tcase.skipped, tcase.want, count, skipped, got)
+		}
+	}
+}
+
+func TestStreamBody(t *testing.T) {
+	t.Parallel()
+
+	newResponse := func(body string) *web.FetchResponse {
+		return &web.FetchResponse{Request: new(http.Request), Body: io.NopCloser(strings.NewReader(body))}
+	}
+
+	t.Run("array", func(t *testing.T) {
+		t.Parallel()
+
+		jobs := make(chan *repoJob, 10)
+		job := newTestControlJob(nil, "GET /a a", "GET /b b")
+		job.repoJobs = jobs
+		job.streamBatchSize = 2
+
+		targets := job.activeTargets()
+
+		_, streamed, count, err := streamBody(job, targets, newResponse(`[{"id":1},{"id":2},{"id":3}]`), nil)
+		if err != nil || !streamed || count != 3 {
+			t.Fatalf("expected 3 records to be streamed, got %d (streamed %t): %v", count, streamed, err)
+		}
+
+		close(jobs)
+
+		var got []string
+		for rj := range jobs {
+			got = append(got, rj.table+" "+string(rj.b))
+		}
+
+		want := []string{
+			targets[0].table + ` [{"id":1},{"id":2}]`, targets[1].table + ` [{"id":1},{"id":2}]`,
+			targets[0].table + ` [{"id":3}]`, targets[1].table + ` [{"id":3}]`,
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("expected a repository job for every batch of every target, got %v", got)
+		}
+	})
+
+	t.Run("ndjson", func(t *testing.T) {
+		t.Parallel()
+
+		jobs := make(chan *repoJob, 10)
+		job := newTestControlJob(nil, "GET /a a")
+		job.repoJobs = jobs
+		job.ndjson = true
+
+		_, streamed, count, err := streamBody(job, job.activeTargets(), newResponse("{\"id\":1}\n{\"id\":2}\n"), nil)
+		if err != nil || !streamed || count != 2 {
+			t.Fatalf("expected 2 records to be streamed, got %d (streamed %t): %v", count, streamed, err)
+		}
+
+		if rj := <-jobs; string(rj.b) != `[{"id":1},{"id":2}]` {
+			t.Fatalf("expected the lines to be written as a batch of records, got %s", rj.b)
+		}
+	})
+
+	t.Run("csv", func(t *testing.T) {
+		t.Parallel()
+
+		jobs := make(chan *repoJob, 10)
+		job := newTestControlJob(nil, "GET /a a")
+		job.repoJobs = jobs
+		job.csv = &config.CSVFormat{Coerce: true}
+
+		_, streamed, count, err := streamBody(job, job.activeTargets(), newResponse("id,side\n1,buy\n2,sell\n"), nil)
+		if err != nil || !streamed || count != 2 {
+			t.Fatalf("expected 2 records to be streamed, got %d (streamed %t): %v", count, streamed, err)
+		}
+
+		if rj := <-jobs; string(rj.b) != `[{"id":1,"side":"buy"},{"id":2,"side":"sell"}]` {
+			t.Fatalf("expected the rows to be written as a batch of records, got %s", rj.b)
+		}
+	})
+
+	for _, tcase := range []struct {
+		name string
+		size int
+		body string
+	}{
+		{name: "disabled", body: `[{"id":1}]`},
+		{name: "record", size: 2, body: ` {"id":1}`},
+		{name: "invalid", size: 2, body: `id,price`},
+		{name: "empty", size: 2},
+	} {
+		tcase := tcase
+
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			job := newTestControlJob(nil, "GET /a a")
+			job.streamBatchSize = tcase.size
+
+			body, streamed, _, err := streamBody(job, job.activeTargets(), newResponse(tcase.body), nil)
+			if err != nil || streamed {
+				t.Fatalf("expected the body not to be streamed, got %t: %v", streamed, err)
+			}
+
+			// Leading whitespace may be consumed looking for the array.
+			data, err := io.ReadAll(body)
+			if want := strings.TrimLeft(tcase.body, " "); err != nil || string(data) != want {
+				t.Fatalf("expected the entire body %q to be read, got %q: %v", want, data, err)
+			}
+		})
+	}
+}
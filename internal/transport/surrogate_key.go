@@ -0,0 +1,217 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/google/uuid"
+)
+
+// crockford is the base32 alphabet of ULIDs, which leaves out "I", "L", "O" and "U".
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// xidEncoding is the lowercase base32 encoding of xids, without padding.
+var xidEncoding = base32.NewEncoding("0123456789abcdefghijklmnopqrstuv").WithPadding(base32.NoPadding)
+
+// xidMachine and xidPID identify the process that generates xids, and "xidCounter" tells apart the xids that it
+// generates in the same second, starting at a random value.
+var (
+	xidMachine = xidMachineID()
+	xidPID     = uint16(os.Getpid())
+	xidCounter = xidCounterStart()
+)
+
+// xidMachineID returns the first three bytes of the hash of the host name, or random bytes if it is unknown.
+func xidMachineID() [3]byte {
+	var machine [3]byte
+
+	host, err := os.Hostname()
+	if err != nil {
+		_, _ = rand.Read(machine[:])
+
+		return machine
+	}
+
+	sum := sha256.Sum256([]byte(host))
+	copy(machine[:], sum[:])
+
+	return machine
+}
+
+// xidCounterStart returns a random start of the xid counter.
+func xidCounterStart() *uint32 {
+	var start [4]byte
+
+	_, _ = rand.Read(start[:])
+	counter := binary.BigEndian.Uint32(start[:])
+
+	return &counter
+}
+
+// newUUIDv7 returns a UUID version 7, with the milliseconds since the Unix epoch of "now" followed by random bits.
+func newUUIDv7(now time.Time) (string, error) {
+	var id [16]byte
+
+	if _, err := rand.Read(id[6:]); err != nil {
+		return "", fmt.Errorf("failed to generate uuidv7: %w", err)
+	}
+
+	ms := uint64(now.UnixMilli())
+	for idx := 0; idx < 6; idx++ {
+		id[idx] = byte(ms >> (40 - 8*idx))
+	}
+
+	id[6] = 0x70 | id[6]&0x0f
+	id[8] = 0x80 | id[8]&0x3f
+
+	return uuid.UUID(id).String(), nil
+}
+
+// newULID returns a ULID, with the 48-bit milliseconds since the Unix epoch of "now" followed by 80 random bits, in
+// Crockford's base32.
+func newULID(now time.Time) (string, error) {
+	var id [16]byte
+
+	if _, err := rand.Read(id[6:]); err != nil {
+		return "", fmt.Errorf("failed to generate ulid: %w", err)
+	}
+
+	ms := uint64(now.UnixMilli())
+	for idx := 0; idx < 6; idx++ {
+		id[idx] = byte(ms >> (40 - 8*idx))
+	}
+
+	return encodeULID(id), nil
+}
+
+// encodeULID returns the Crockford's base32 of the 128 bits of a ULID, 5 bits at a time after two leading bits of
+// zero.
+func encodeULID(id [16]byte) string {
+	var str strings.Builder
+
+	hi, lo := binary.BigEndian.Uint64(id[:8]), binary.BigEndian.Uint64(id[8:])
+	for shift := 125; shift >= 0; shift -= 5 {
+		var bits uint64
+
+		switch {
+		case shift >= 64:
+			bits = hi >> (shift - 64)
+		case shift > 59:
+			bits = hi<<(64-shift) | lo>>shift
+		default:
+			bits = lo >> shift
+		}
+
+		str.WriteByte(crockford[bits&0x1f])
+	}
+
+	return str.String()
+}
+
+// newXID returns an xid of "now": the seconds since the Unix epoch, the machine ID and process ID, and a counter.
+func newXID(now time.Time) string {
+	var id [12]byte
+
+	binary.BigEndian.PutUint32(id[:4], uint32(now.Unix()))
+	copy(id[4:7], xidMachine[:])
+	binary.BigEndian.PutUint16(id[7:9], xidPID)
+
+	counter := atomic.AddUint32(xidCounter, 1)
+	id[9], id[10], id[11] = byte(counter>>16), byte(counter>>8), byte(counter)
+
+	return xidEncoding.EncodeToString(id[:])
+}
+
+// hashKey returns the hex-encoded SHA-256 of the values of the "fields" of a record, as a JSON array. Values are
+// decoded and encoded again, so that the key does not depend on the formatting or key order of the response.
+func hashKey(fields []string, record map[string]json.RawMessage) (string, error) {
+	values := make([]interface{}, len(fields))
+
+	for idx, name := range fields {
+		raw, ok := record[name]
+		if !ok {
+			continue
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		decoder.UseNumber()
+
+		if err := decoder.Decode(&values[idx]); err != nil {
+			return "", fmt.Errorf("failed to decode %q: %w", name, err)
+		}
+	}
+
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode the fields of the key: %w", err)
+	}
+
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// surrogateKey returns the surrogate key of a record.
+func surrogateKey(key *config.SurrogateKey, now time.Time, record map[string]json.RawMessage) (string, error) {
+	switch key.Generator {
+	case config.SurrogateKeyUUIDv7:
+		return newUUIDv7(now)
+	case config.SurrogateKeyULID:
+		return newULID(now)
+	case config.SurrogateKeyXID:
+		return newXID(now), nil
+	default:
+		return hashKey(key.Fields, record)
+	}
+}
+
+// generateKeys will write a surrogate key to the column of "key" of every record of JSON data, an array of records
+// or a single record. Records that are not objects are left as they are.
+func generateKeys(key *config.SurrogateKey, now time.Time, data []byte) ([]byte, error) {
+	if key == nil {
+		return data, nil
+	}
+
+	return mapRecords(data, func(raw json.RawMessage) (json.RawMessage, error) {
+		if trimmed := bytes.TrimLeft(raw, " \t\r\n"); len(trimmed) == 0 || trimmed[0] != '{' {
+			return raw, nil
+		}
+
+		var record map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &record); err != nil {
+			return nil, fmt.Errorf("failed to decode record: %w", err)
+		}
+
+		id, err := surrogateKey(key, now, record)
+		if err != nil {
+			return nil, fmt.Errorf("%w %q: %v", ErrTransform, key.Column, err)
+		}
+
+		record[key.Column], _ = json.Marshal(id)
+
+		out, err := json.Marshal(record)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode record: %w", err)
+		}
+
+		return out, nil
+	})
+}
@@ -0,0 +1,113 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"encoding/json"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/alpstable/gidari/config"
+)
+
+func TestGenerateKeys(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2022, 10, 14, 15, 4, 5, 0, time.UTC)
+
+	for _, tcase := range []struct {
+		name string
+		key  *config.SurrogateKey
+		want *regexp.Regexp
+
+		// prefix is the part of the key that only depends on the time it is generated.
+		prefix string
+	}{
+		{
+			name:   "uuidv7",
+			key:    &config.SurrogateKey{Column: "key", Generator: config.SurrogateKeyUUIDv7},
+			want:   regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`),
+			prefix: "0183d706-1a88",
+		},
+		{
+			name:   "ulid",
+			key:    &config.SurrogateKey{Column: "key", Generator: config.SurrogateKeyULID},
+			want:   regexp.MustCompile(`^[0-9A-HJKMNP-TV-Z]{26}$`),
+			prefix: "01GFBGC6M8",
+		},
+		{
+			name:   "xid",
+			key:    &config.SurrogateKey{Column: "key", Generator: config.SurrogateKeyXID},
+			want:   regexp.MustCompile(`^[0-9a-v]{20}$`),
+			prefix: "cd4nkp",
+		},
+	} {
+		got, err := generateKeys(tcase.key, now, []byte(`[{"id":1},{"id":2},3]`))
+		if err != nil {
+			t.Fatalf("%s: failed to generate keys: %v", tcase.name, err)
+		}
+
+		var records []json.RawMessage
+		if err := json.Unmarshal(got, &records); err != nil || len(records) != 3 || string(records[2]) != "3" {
+			t.Fatalf("%s: expected the records and the record that is not an object, got %s", tcase.name, got)
+		}
+
+		keys := make(map[string]bool)
+
+		for _, raw := range records[:2] {
+			var record struct{ Key string }
+			if err := json.Unmarshal(raw, &record); err != nil {
+				t.Fatalf("%s: failed to decode record: %v", tcase.name, err)
+			}
+
+			if !tcase.want.MatchString(record.Key) || record.Key[:len(tcase.prefix)] != tcase.prefix {
+				t.Fatalf("%s: expected a key starting with %s, got %q", tcase.name, tcase.prefix, record.Key)
+			}
+
+			keys[record.Key] = true
+		}
+
+		if len(keys) != 2 {
+			t.Fatalf("%s: expected a different key for every record, got %v", tcase.name, keys)
+		}
+	}
+
+	var max [16]byte
+	for idx := range max {
+		max[idx] = 0xff
+	}
+
+	if got := encodeULID(max); got != "7ZZZZZZZZZZZZZZZZZZZZZZZZZ" {
+		t.Fatalf("expected the largest ULID, got %s", got)
+	}
+}
+
+func TestGenerateHashKeys(t *testing.T) {
+	t.Parallel()
+
+	key := &config.SurrogateKey{Column: "key", Generator: config.SurrogateKeyHash, Fields: []string{"venue", "id"}}
+
+	got, err := generateKeys(key, time.Now(), []byte(`[{"id":1,"venue":{"b":1,"a":2}},`+
+		`{"venue":{"a":2, "b":1},"id":1,"key":"old"},{"id":2}]`))
+	if err != nil {
+		t.Fatalf("failed to generate keys: %v", err)
+	}
+
+	var records []struct{ Key string }
+	if err := json.Unmarshal(got, &records); err != nil {
+		t.Fatalf("failed to decode records: %v", err)
+	}
+
+	if len(records) != 3 || len(records[0].Key) != 64 || records[0].Key != records[1].Key ||
+		records[0].Key == records[2].Key {
+		t.Fatalf("expected the same key for the same fields, got %+v", records)
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/internal/proto"
+)
+
+// ErrPrimaryKeyMismatch is returned when the primary keys of a table in storage do not match the configuration.
+var ErrPrimaryKeyMismatch = fmt.Errorf("primary key mismatch")
+
+// checkPrimaryKeys will ensure that the existing SQL tables have the primary keys declared in the "tables"
+// configuration, so that a mismatch fails the run before any data is fetched. Tables that do not exist yet, and
+// NoSQL storage, are not checked.
+func checkPrimaryKeys(ctx context.Context, cfg *config.Config) error {
+	declared := make(map[string][]string)
+
+	for name, table := range cfg.Tables {
+		if len(table.PrimaryKeys) > 0 {
+			declared[name] = table.PrimaryKeys
+		}
+	}
+
+	if len(declared) == 0 {
+		return nil
+	}
+
+	repos, closeRepos, err := repos(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	defer closeRepos()
+
+	for idx, repo := range repos {
+		if repo.IsNoSQL() {
+			continue
+		}
+
+		rsp, err := repo.ListPrimaryKeys(ctx)
+		if err != nil {
+			return fmt.Errorf("unable to list primary keys: %w", err)
+		}
+
+		for name, want := range declared {
+			if table := cfg.Tables[name]; !writesTo(table.ConnectionStrings, cfg.ConnectionStrings[idx]) {
+				continue
+			}
+
+			pks, ok := rsp.GetPKSet()[name]
+			if !ok {
+				continue
+			}
+
+			if got := pks.GetList(); !samePrimaryKeys(got, want) {
+				return fmt.Errorf("%w: table %q on %q has primary keys [%s], the configuration declares [%s]",
+					ErrPrimaryKeyMismatch, name, proto.SchemeFromStorageType(repo.Type()), strings.Join(got, ", "),
+					strings.Join(want, ", "))
+			}
+		}
+	}
+
+	return nil
+}
+
+// samePrimaryKeys returns true if both lists contain the same columns, in any order.
+func samePrimaryKeys(got, want []string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+
+	sortedGot := append([]string(nil), got...)
+	sortedWant := append([]string(nil), want...)
+
+	sort.Strings(sortedGot)
+	sort.Strings(sortedWant)
+
+	for idx := range sortedGot {
+		if sortedGot[idx] != sortedWant[idx] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,73 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSamePrimaryKeys(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		got, want []string
+		same      bool
+	}{
+		{got: []string{"id"}, want: []string{"id"}, same: true},
+		{got: []string{"time", "symbol"}, want: []string{"symbol", "time"}, same: true},
+		{got: []string{"id"}, want: []string{"id", "time"}},
+		{got: []string{"uuid"}, want: []string{"id"}},
+	} {
+		if same := samePrimaryKeys(tcase.got, tcase.want); same != tcase.same {
+			t.Fatalf("expected samePrimaryKeys(%v, %v) to be %v", tcase.got, tcase.want, tcase.same)
+		}
+	}
+}
+
+func TestWritesTo(t *testing.T) {
+	t.Parallel()
+
+	if !writesTo(nil, "mongodb://localhost") {
+		t.Fatalf("expected empty sinks to write to every repository")
+	}
+
+	sinks := []string{"postgresql://localhost"}
+
+	if !writesTo(sinks, "postgresql://localhost") || writesTo(sinks, "mongodb://localhost") {
+		t.Fatalf("expected sinks to only write to the listed repositories")
+	}
+}
+
+func TestTruncations(t *testing.T) {
+	t.Parallel()
+
+	sinks := []string{"postgresql://localhost"}
+	res := &runResources{truncate: map[string][]string{
+		"trades": nil, pagesTable("trades"): nil, "quotes": sinks, "candles": nil,
+	}}
+
+	// The tables of coalesced requests are written by the batch of the request they are coalesced into.
+	batch := []*flattenedRequest{{table: "trades", coalesced: []*flattenedRequest{{table: "quotes"}}}}
+
+	want := map[string][]string{"trades": nil, pagesTable("trades"): nil, "quotes": sinks}
+	if got := res.truncations(batch); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected the tables of the batch to be truncated, got %v", got)
+	}
+
+	if got := res.truncations(batch); len(got) != 0 {
+		t.Fatalf("expected tables to only be truncated by the first batch, got %v", got)
+	}
+
+	later := []*flattenedRequest{{table: "candles"}}
+	if got := res.truncations(later); !reflect.DeepEqual(got, map[string][]string{"candles": nil}) {
+		t.Fatalf("expected the tables of a later batch to be truncated, got %v", got)
+	}
+}
@@ -0,0 +1,573 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alpstable/gidari/config"
+)
+
+var ErrTransform = fmt.Errorf("unable to transform column")
+
+// transformFn converts the value of a column, which is a number or a string holding a number.
+type transformFn func(num *big.Rat, quoted bool) (json.RawMessage, error)
+
+// transformFns are the implementations of the transforms of a configuration.
+var transformFns = map[string]transformFn{
+	config.TransformEpochToRFC3339:       epochTransform(time.Second),
+	config.TransformEpochMillisToRFC3339: epochTransform(time.Millisecond),
+	config.TransformSatoshisToBTC:        decimalTransform(8),
+	config.TransformCentsToCurrency:      decimalTransform(2),
+	config.TransformBytesToMB:            decimalTransform(6),
+}
+
+// epochTransform converts a unix time in units of "unit" to an RFC 3339 time in UTC.
+func epochTransform(unit time.Duration) transformFn {
+	return func(num *big.Rat, _ bool) (json.RawMessage, error) {
+		nanos := new(big.Rat).Mul(num, new(big.Rat).SetInt64(int64(unit)))
+		if !nanos.IsInt() {
+			nanos.SetInt(new(big.Int).Quo(nanos.Num(), nanos.Denom()))
+		}
+
+		if !nanos.Num().IsInt64() {
+			return nil, fmt.Errorf("%s is out of range", num.RatString())
+		}
+
+		data, err := json.Marshal(time.Unix(0, nanos.Num().Int64()).UTC().Format(time.RFC3339Nano))
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode time: %w", err)
+		}
+
+		return data, nil
+	}
+}
+
+// decimalTransform converts an amount of a minor unit to a decimal amount of the unit that is 10^places larger. The
+// decimal is exact, and is kept as a string if it was given as one.
+func decimalTransform(places int) transformFn {
+	scale := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(places)), nil))
+
+	return func(num *big.Rat, quoted bool) (json.RawMessage, error) {
+		str := formatDecimal(new(big.Rat).Quo(num, scale))
+
+		if quoted {
+			return json.Marshal(str)
+		}
+
+		return json.RawMessage(str), nil
+	}
+}
+
+// formatDecimal returns the exact decimal of a number, without trailing zeros.
+func formatDecimal(num *big.Rat) string {
+	str := num.FloatString(decimalPlaces(num.Denom()))
+	if strings.Contains(str, ".") {
+		str = strings.TrimSuffix(strings.TrimRight(str, "0"), ".")
+	}
+
+	return str
+}
+
+// maxDecimalPlaces is the most decimal places of a converted amount, for amounts that have no exact decimal.
+const maxDecimalPlaces = 32
+
+// decimalPlaces returns the number of decimal places of the exact decimal of a fraction with the denominator "denom",
+// which is the larger of its number of factors of 2 and of 5.
+func decimalPlaces(denom *big.Int) int {
+	rem := new(big.Int).Set(denom)
+
+	count := func(factor int64) int {
+		places := 0
+
+		for mod := new(big.Int); ; places++ {
+			quo, _ := new(big.Int).QuoRem(rem, big.NewInt(factor), mod)
+			if mod.Sign() != 0 {
+				return places
+			}
+
+			rem = quo
+		}
+	}
+
+	twos, fives := count(2), count(5)
+	if rem.Cmp(big.NewInt(1)) != 0 {
+		return maxDecimalPlaces
+	}
+
+	if twos > fives {
+		return twos
+	}
+
+	return fives
+}
+
+// transformValue will apply a transform to a JSON value. Null values are left as they are.
+func transformValue(name string, val json.RawMessage) (json.RawMessage, error) {
+	if bytes.Equal(bytes.TrimSpace(val), []byte("null")) {
+		return val, nil
+	}
+
+	text, quoted := string(bytes.TrimSpace(val)), false
+
+	var str string
+	if err := json.Unmarshal(val, &str); err == nil {
+		text, quoted = strings.TrimSpace(str), true
+	}
+
+	num, ok := new(big.Rat).SetString(text)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a number", val)
+	}
+
+	return transformFns[name](num, quoted)
+}
+
+// transformRecord will apply the transforms to the columns of a record. Records that are not objects, and columns
+// that they do not have, are left as they are.
+func transformRecord(transforms map[string]string, data json.RawMessage) (json.RawMessage, error) {
+	var record map[string]json.RawMessage
+	if err := json.Unmarshal(data, &record); err != nil {
+		return data, nil //nolint:nilerr // only objects have columns to transform
+	}
+
+	for column, name := range transforms {
+		val, ok := record[column]
+		if !ok {
+			continue
+		}
+
+		transformed, err := transformValue(name, val)
+		if err != nil {
+			return nil, fmt.Errorf("%w %q with %s: %v", ErrTransform, column, name, err)
+		}
+
+		record[column] = transformed
+	}
+
+	out, err := json.Marshal(record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode record: %w", err)
+	}
+
+	return out, nil
+}
+
+// fieldValue returns the value of a field of a record: the key itself, or else the nested value at its path. Arrays
+// on the path are indexed by number, e.g. "tags.0".
+func fieldValue(record map[string]json.RawMessage, field string) (json.RawMessage, bool) {
+	if val, ok := record[field]; ok {
+		return val, true
+	}
+
+	path := config.FieldPath(field)
+
+	val, ok := record[path[0]]
+	for _, key := range path[1:] {
+		if !ok {
+			return nil, false
+		}
+
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(val, &obj); err == nil {
+			val, ok = obj[key]
+
+			continue
+		}
+
+		var arr []json.RawMessage
+
+		idx, err := strconv.Atoi(key)
+		if err != nil || json.Unmarshal(val, &arr) != nil || idx < 0 || idx >= len(arr) {
+			return nil, false
+		}
+
+		val = arr[idx]
+	}
+
+	return val, ok
+}
+
+// mapRecordFields will rename the keys of a record to their columns, and copy nested values to theirs. Records that
+// are not objects, and fields that they do not have, are left as they are.
+func mapRecordFields(fields map[string]string, data json.RawMessage) (json.RawMessage, error) {
+	var record map[string]json.RawMessage
+	if err := json.Unmarshal(data, &record); err != nil {
+		return data, nil //nolint:nilerr // only objects have fields to map
+	}
+
+	// Every value is read before any is written, so that fields can be swapped.
+	values := make(map[string]json.RawMessage, len(fields))
+
+	for field, column := range fields {
+		if val, ok := fieldValue(record, field); ok {
+			values[column] = val
+		}
+
+		delete(record, field)
+	}
+
+	for column, val := range values {
+		record[column] = val
+	}
+
+	out, err := json.Marshal(record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode record: %w", err)
+	}
+
+	return out, nil
+}
+
+// mapFields will map the fields of the records of JSON data, an array of records or a single record, to their
+// columns.
+func mapFields(fields map[string]string, data []byte) ([]byte, error) {
+	if len(fields) == 0 {
+		return data, nil
+	}
+
+	return mapRecords(data, func(record json.RawMessage) (json.RawMessage, error) {
+		return mapRecordFields(fields, record)
+	})
+}
+
+// filterRecordFields will keep only the "include" columns of a record, if any, and remove the "exclude" columns.
+// Records that are not objects are left as they are.
+func filterRecordFields(include, exclude []string, data json.RawMessage) (json.RawMessage, error) {
+	var record map[string]json.RawMessage
+	if err := json.Unmarshal(data, &record); err != nil {
+		return data, nil //nolint:nilerr // only objects have fields to filter
+	}
+
+	if len(include) != 0 {
+		kept := make(map[string]json.RawMessage, len(include))
+
+		for _, column := range include {
+			if val, ok := record[column]; ok {
+				kept[column] = val
+			}
+		}
+
+		record = kept
+	}
+
+	for _, column := range exclude {
+		delete(record, column)
+	}
+
+	out, err := json.Marshal(record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode record: %w", err)
+	}
+
+	return out, nil
+}
+
+// filterFields will filter the columns of the records of JSON data, an array of records or a single record, by
+// "include" and "exclude".
+func filterFields(include, exclude []string, data []byte) ([]byte, error) {
+	if len(include) == 0 && len(exclude) == 0 {
+		return data, nil
+	}
+
+	return mapRecords(data, func(record json.RawMessage) (json.RawMessage, error) {
+		return filterRecordFields(include, exclude, record)
+	})
+}
+
+// flattenObject will set the values of an object into "flat", with the keys of its nested objects joined to those
+// of their parents by the delimiter, down to the depth of "flatten". Empty objects, and objects nested deeper, are
+// written as they are. Two values that are flattened into the same column are an error.
+func flattenObject(flatten config.Flatten, prefix string, depth int, obj map[string]json.RawMessage,
+	flat map[string]json.RawMessage,
+) error {
+	// Keys are flattened in order so that the same collision always reports the same column.
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		column := key
+		if prefix != "" {
+			column = prefix + flatten.Delimiter + key
+		}
+
+		var nested map[string]json.RawMessage
+		if flatten.MaxDepth == 0 || depth < flatten.MaxDepth {
+			if err := json.Unmarshal(obj[key], &nested); err == nil && len(nested) > 0 {
+				if err := flattenObject(flatten, column, depth+1, nested, flat); err != nil {
+					return err
+				}
+
+				continue
+			}
+		}
+
+		if _, ok := flat[column]; ok {
+			return fmt.Errorf("%w %q: more than one value is flattened into it", ErrTransform, column)
+		}
+
+		flat[column] = obj[key]
+	}
+
+	return nil
+}
+
+// flattenRecord will flatten the nested objects of a record into columns. Records that are not objects are left as
+// they are.
+func flattenRecord(flatten config.Flatten, data json.RawMessage) (json.RawMessage, error) {
+	var record map[string]json.RawMessage
+	if err := json.Unmarshal(data, &record); err != nil {
+		return data, nil //nolint:nilerr // only objects have nested objects to flatten
+	}
+
+	flat := make(map[string]json.RawMessage, len(record))
+	if err := flattenObject(flatten, "", 0, record, flat); err != nil {
+		return nil, err
+	}
+
+	out, err := json.Marshal(flat)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode record: %w", err)
+	}
+
+	return out, nil
+}
+
+// flattenData will flatten the nested objects of the records of JSON data, an array of records or a single record,
+// into columns, unless the delimiter of "flatten" is empty.
+func flattenData(flatten config.Flatten, data []byte) ([]byte, error) {
+	if flatten.Delimiter == "" {
+		return data, nil
+	}
+
+	return mapRecords(data, func(record json.RawMessage) (json.RawMessage, error) {
+		return flattenRecord(flatten, record)
+	})
+}
+
+// localNumber returns the JSON number of a localized number, e.g. "1.234,56" in German. Empty values are null.
+func localNumber(locale *config.NumberLocale, val json.RawMessage) (json.RawMessage, error) {
+	var str string
+	if err := json.Unmarshal(val, &str); err != nil {
+		return val, nil //nolint:nilerr // only strings are localized, numbers are already parsed
+	}
+
+	text := strings.TrimSpace(str)
+	if text == "" {
+		return json.RawMessage("null"), nil
+	}
+
+	for _, group := range locale.Groups {
+		text = strings.ReplaceAll(text, group, "")
+	}
+
+	text = strings.Replace(text, locale.Decimal, ".", 1)
+
+	num, ok := new(big.Rat).SetString(text)
+	if !ok || strings.Contains(text, "/") {
+		return nil, fmt.Errorf("%q is not a number", str)
+	}
+
+	return json.RawMessage(formatDecimal(num)), nil
+}
+
+// localizeRecord will parse the localized numbers of the columns of a record. Records that are not objects, and
+// columns that they do not have, are left as they are.
+func localizeRecord(locales map[string]string, data json.RawMessage) (json.RawMessage, error) {
+	var record map[string]json.RawMessage
+	if err := json.Unmarshal(data, &record); err != nil {
+		return data, nil //nolint:nilerr // only objects have columns to parse
+	}
+
+	for column, name := range locales {
+		val, ok := record[column]
+		if !ok {
+			continue
+		}
+
+		locale, _ := config.LookupNumberLocale(name)
+
+		num, err := localNumber(locale, val)
+		if err != nil {
+			return nil, fmt.Errorf("%w %q as a %s number: %v", ErrTransform, column, name, err)
+		}
+
+		record[column] = num
+	}
+
+	out, err := json.Marshal(record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode record: %w", err)
+	}
+
+	return out, nil
+}
+
+// localizeData will parse the localized numbers of the records of JSON data, an array of records or a single record.
+func localizeData(locales map[string]string, data []byte) ([]byte, error) {
+	if len(locales) == 0 {
+		return data, nil
+	}
+
+	return mapRecords(data, func(record json.RawMessage) (json.RawMessage, error) {
+		return localizeRecord(locales, record)
+	})
+}
+
+// transformData will apply the transforms to the records of JSON data, an array of records or a single record.
+func transformData(transforms map[string]string, data []byte) ([]byte, error) {
+	if len(transforms) == 0 {
+		return data, nil
+	}
+
+	return mapRecords(data, func(record json.RawMessage) (json.RawMessage, error) {
+		return transformRecord(transforms, record)
+	})
+}
+
+// mapRecords will replace each of the records of JSON data, an array of records or a single record, with the result
+// of "fn".
+func mapRecords(data []byte, fn func(json.RawMessage) (json.RawMessage, error)) ([]byte, error) {
+	trimmed := bytes.TrimLeft(data, " \t\r\n")
+	if len(trimmed) == 0 || trimmed[0] != '[' {
+		return fn(data)
+	}
+
+	var records []json.RawMessage
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("failed to decode records: %w", err)
+	}
+
+	for idx, record := range records {
+		mapped, err := fn(record)
+		if err != nil {
+			return nil, err
+		}
+
+		records[idx] = mapped
+	}
+
+	out, err := json.Marshal(records)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode records: %w", err)
+	}
+
+	return out, nil
+}
+
+// setColumns will write "values" to the columns of every record, keyed by column. Records that are not objects are
+// left as they are.
+func setColumns(values map[string]json.RawMessage, records []json.RawMessage) error {
+	if len(values) == 0 {
+		return nil
+	}
+
+	for idx, data := range records {
+		var record map[string]json.RawMessage
+		if err := json.Unmarshal(data, &record); err != nil {
+			continue
+		}
+
+		for column, val := range values {
+			record[column] = val
+		}
+
+		out, err := json.Marshal(record)
+		if err != nil {
+			return fmt.Errorf("failed to encode record: %w", err)
+		}
+
+		records[idx] = out
+	}
+
+	return nil
+}
+
+// setDataColumns will write "values" to the columns of the records of JSON data, an array of records or a single
+// record.
+func setDataColumns(values map[string]json.RawMessage, data []byte) ([]byte, error) {
+	if len(values) == 0 {
+		return data, nil
+	}
+
+	records, err := appendRecords(nil, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := setColumns(values, records); err != nil {
+		return nil, err
+	}
+
+	if trimmed := bytes.TrimLeft(data, " \t\r\n"); len(trimmed) == 0 || trimmed[0] != '[' {
+		return records[0], nil
+	}
+
+	out, err := json.Marshal(records)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode records: %w", err)
+	}
+
+	return out, nil
+}
+
+// chunkValues returns the boundaries of a timeseries chunk keyed by the "columns" that they are written to.
+func chunkValues(columns config.ChunkColumns, chunk *[2]time.Time) (map[string]json.RawMessage, error) {
+	if chunk == nil || (columns.Start == "" && columns.End == "") {
+		return nil, nil
+	}
+
+	bounds := map[string]time.Time{columns.Start: chunk[0], columns.End: chunk[1]}
+	delete(bounds, "")
+
+	values := make(map[string]json.RawMessage, len(bounds))
+
+	for column, bound := range bounds {
+		val, err := json.Marshal(bound.UTC().Format(time.RFC3339Nano))
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode chunk boundary: %w", err)
+		}
+
+		values[column] = val
+	}
+
+	return values, nil
+}
+
+// annotateRecords will write the boundaries of a timeseries chunk to the "columns" of every record. Records that are
+// not objects are left as they are.
+func annotateRecords(columns config.ChunkColumns, chunk *[2]time.Time, records []json.RawMessage) error {
+	values, err := chunkValues(columns, chunk)
+	if err != nil {
+		return err
+	}
+
+	return setColumns(values, records)
+}
+
+// annotateChunk will write the boundaries of a timeseries chunk to the "columns" of the records of JSON data, an
+// array of records or a single record.
+func annotateChunk(columns config.ChunkColumns, chunk *[2]time.Time, data []byte) ([]byte, error) {
+	values, err := chunkValues(columns, chunk)
+	if err != nil {
+		return nil, err
+	}
+
+	return setDataColumns(values, data)
+}
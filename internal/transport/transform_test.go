@@ -0,0 +1,314 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/alpstable/gidari/config"
+)
+
+func TestTransformData(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name       string
+		transforms map[string]string
+		data       string
+		want       string
+		err        error
+	}{
+		{name: "no transforms", data: `{"t": 1}`, want: `{"t": 1}`},
+		{
+			name:       "epoch",
+			transforms: map[string]string{"t": config.TransformEpochToRFC3339},
+			data:       `[{"t":1651363200},{"t":"1651363200.5"},{"t":null},{"id":1}]`,
+			want:       `[{"t":"2022-05-01T00:00:00Z"},{"t":"2022-05-01T00:00:00.5Z"},{"t":null},{"id":1}]`,
+		},
+		{
+			name:       "epoch millis",
+			transforms: map[string]string{"t": config.TransformEpochMillisToRFC3339},
+			data:       `{"t":1651363200123}`,
+			want:       `{"t":"2022-05-01T00:00:00.123Z"}`,
+		},
+		{
+			name:       "satoshis",
+			transforms: map[string]string{"fee": config.TransformSatoshisToBTC, "amount": config.TransformSatoshisToBTC},
+			data:       `{"amount":2100000000000000,"fee":"12345"}`,
+			want:       `{"amount":21000000,"fee":"0.00012345"}`,
+		},
+		{
+			name:       "cents",
+			transforms: map[string]string{"price": config.TransformCentsToCurrency},
+			data:       `[{"price":1999},{"price":-12.5},{"price":2000}]`,
+			want:       `[{"price":19.99},{"price":-0.125},{"price":20}]`,
+		},
+		{
+			name:       "bytes",
+			transforms: map[string]string{"size": config.TransformBytesToMB},
+			data:       `{"size":1.5e6}`,
+			want:       `{"size":1.5}`,
+		},
+		{
+			name:       "not a number",
+			transforms: map[string]string{"price": config.TransformCentsToCurrency},
+			data:       `{"price":"free"}`,
+			err:        ErrTransform,
+		},
+	} {
+		tcase := tcase
+
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := transformData(tcase.transforms, []byte(tcase.data))
+			if !errors.Is(err, tcase.err) {
+				t.Fatalf("expected error %v, got %v", tcase.err, err)
+			}
+
+			if tcase.err == nil && string(got) != tcase.want {
+				t.Fatalf("expected %s, got %s", tcase.want, got)
+			}
+		})
+	}
+}
+
+func TestLocalizeData(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name    string
+		locales map[string]string
+		data    string
+		want    string
+		err     error
+	}{
+		{name: "no locales", data: `{"price":"1.234,56"}`, want: `{"price":"1.234,56"}`},
+		{
+			name:    "german",
+			locales: map[string]string{"price": "de-DE", "qty": "de"},
+			data:    `[{"price":"1.234,56","qty":"-2,50"},{"price":" ","qty":3},{"id":1}]`,
+			want:    `[{"price":1234.56,"qty":-2.5},{"price":null,"qty":3},{"id":1}]`,
+		},
+		{
+			name:    "french",
+			locales: map[string]string{"price": "fr"},
+			data:    "{\"price\":\"1\u00a0234 567,5\"}",
+			want:    `{"price":1234567.5}`,
+		},
+		{
+			name:    "swiss",
+			locales: map[string]string{"price": "de_CH"},
+			data:    `{"price":"1'234.50"}`,
+			want:    `{"price":1234.5}`,
+		},
+		{name: "english", locales: map[string]string{"price": "en"}, data: `{"price":"1,000"}`, want: `{"price":1000}`},
+		{
+			name:    "not a number",
+			locales: map[string]string{"price": "de"},
+			data:    `{"price":"1.234,56 EUR"}`,
+			err:     ErrTransform,
+		},
+	} {
+		got, err := localizeData(tcase.locales, []byte(tcase.data))
+		if !errors.Is(err, tcase.err) {
+			t.Fatalf("%s: expected error %v, got %v", tcase.name, tcase.err, err)
+		}
+
+		if tcase.err == nil && string(got) != tcase.want {
+			t.Fatalf("%s: expected %s, got %s", tcase.name, tcase.want, got)
+		}
+	}
+}
+
+func TestMapFields(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name   string
+		fields map[string]string
+		data   string
+		want   string
+	}{
+		{name: "no fields", data: `{"priceUsd":1}`, want: `{"priceUsd":1}`},
+		{
+			name:   "rename",
+			fields: map[string]string{"priceUsd": "price_usd"},
+			data:   `[{"id":1,"priceUsd":"1.50"},{"id":2},3]`,
+			want:   `[{"id":1,"price_usd":"1.50"},{"id":2},3]`,
+		},
+		{
+			name:   "nested",
+			fields: map[string]string{"quote.USD.price": "price", "tags.1": "tag", "quote.EUR.price": "eur"},
+			data:   `{"quote":{"USD":{"price":0.10000000000000001}},"tags":["a","b"]}`,
+			want:   `{"price":0.10000000000000001,"quote":{"USD":{"price":0.10000000000000001}},"tag":"b","tags":["a","b"]}`,
+		},
+		{
+			name:   "dotted key",
+			fields: map[string]string{"a.b": "ab"},
+			data:   `{"a.b":1,"a":{"b":2}}`,
+			want:   `{"a":{"b":2},"ab":1}`,
+		},
+		{name: "swap", fields: map[string]string{"a": "b", "b": "a"}, data: `{"a":1,"b":2}`, want: `{"a":2,"b":1}`},
+	} {
+		got, err := mapFields(tcase.fields, []byte(tcase.data))
+		if err != nil {
+			t.Fatalf("%s: failed to map fields: %v", tcase.name, err)
+		}
+
+		if string(got) != tcase.want {
+			t.Fatalf("%s: expected %s, got %s", tcase.name, tcase.want, got)
+		}
+	}
+}
+
+func TestFilterFields(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name    string
+		include []string
+		exclude []string
+		data    string
+		want    string
+	}{
+		{name: "no filters", data: `{"id":1,"_links":{}}`, want: `{"id":1,"_links":{}}`},
+		{
+			name:    "include",
+			include: []string{"id", "price", "missing"},
+			data:    `[{"id":1,"price":"1.50","_links":{"self":"/a"}},{"id":2},3]`,
+			want:    `[{"id":1,"price":"1.50"},{"id":2},3]`,
+		},
+		{
+			name:    "exclude",
+			exclude: []string{"_links", "meta"},
+			data:    `{"id":1,"_links":{"self":"/a"},"meta":null}`,
+			want:    `{"id":1}`,
+		},
+	} {
+		got, err := filterFields(tcase.include, tcase.exclude, []byte(tcase.data))
+		if err != nil {
+			t.Fatalf("%s: failed to filter fields: %v", tcase.name, err)
+		}
+
+		if string(got) != tcase.want {
+			t.Fatalf("%s: expected %s, got %s", tcase.name, tcase.want, got)
+		}
+	}
+}
+
+func TestFlattenData(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name    string
+		flatten config.Flatten
+		data    string
+		want    string
+		err     error
+	}{
+		{name: "not flattened", data: `{"user":{"id":1}}`, want: `{"user":{"id":1}}`},
+		{
+			name:    "every level",
+			flatten: config.Flatten{Delimiter: "_"},
+			data:    `[{"user":{"address":{"city":"Oslo"},"tags":[{"a":1}],"meta":{}}},3]`,
+			want:    `[{"user_address_city":"Oslo","user_meta":{},"user_tags":[{"a":1}]},3]`,
+		},
+		{
+			name:    "max depth",
+			flatten: config.Flatten{Delimiter: ".", MaxDepth: 1},
+			data:    `{"id":1,"user":{"name":"a","address":{"city":"Oslo"}}}`,
+			want:    `{"id":1,"user.address":{"city":"Oslo"},"user.name":"a"}`,
+		},
+		{
+			name:    "collision",
+			flatten: config.Flatten{Delimiter: "_"},
+			data:    `{"user_id":1,"user":{"id":2}}`,
+			err:     ErrTransform,
+		},
+	} {
+		got, err := flattenData(tcase.flatten, []byte(tcase.data))
+		if !errors.Is(err, tcase.err) {
+			t.Fatalf("%s: expected error %v, got %v", tcase.name, tcase.err, err)
+		}
+
+		if tcase.err == nil && string(got) != tcase.want {
+			t.Fatalf("%s: expected %s, got %s", tcase.name, tcase.want, got)
+		}
+	}
+}
+
+func TestAnnotateChunk(t *testing.T) {
+	t.Parallel()
+
+	chunk := &[2]time.Time{
+		time.Date(2022, 5, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2022, 5, 1, 1, 0, 0, 0, time.FixedZone("CEST", 2*60*60)),
+	}
+
+	both := config.ChunkColumns{Start: "chunk_start", End: "chunk_end"}
+
+	for _, tcase := range []struct {
+		name    string
+		columns config.ChunkColumns
+		chunk   *[2]time.Time
+		data    string
+		want    string
+	}{
+		{name: "no columns", chunk: chunk, data: `{"id":1}`, want: `{"id":1}`},
+		{name: "no chunk", columns: both, data: `{"id":1}`, want: `{"id":1}`},
+		{
+			name:    "records",
+			columns: both,
+			chunk:   chunk,
+			data:    `[{"id":1,"price":0.10000000000000001},2]`,
+			want: `[{"chunk_end":"2022-04-30T23:00:00Z","chunk_start":"2022-05-01T00:00:00Z","id":1,` +
+				`"price":0.10000000000000001},2]`,
+		},
+		{
+			name:    "start only",
+			columns: config.ChunkColumns{Start: "window"},
+			chunk:   chunk,
+			data:    `{"id":1}`,
+			want:    `{"id":1,"window":"2022-05-01T00:00:00Z"}`,
+		},
+	} {
+		got, err := annotateChunk(tcase.columns, tcase.chunk, []byte(tcase.data))
+		if err != nil {
+			t.Fatalf("%s: failed to annotate chunk: %v", tcase.name, err)
+		}
+
+		if string(got) != tcase.want {
+			t.Fatalf("%s: expected %s, got %s", tcase.name, tcase.want, got)
+		}
+	}
+}
+
+func TestDecimalPlaces(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		rat  string
+		want int
+	}{
+		{rat: "1", want: 0},
+		{rat: "1/8", want: 3},
+		{rat: "1/20", want: 2},
+		{rat: "1/3", want: maxDecimalPlaces},
+	} {
+		num, _ := new(big.Rat).SetString(tcase.rat)
+		if got := decimalPlaces(num.Denom()); got != tcase.want {
+			t.Fatalf("expected %d decimal places for %s, got %d", tcase.want, tcase.rat, got)
+		}
+	}
+}
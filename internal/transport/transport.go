@@ -9,21 +9,34 @@ package transport
 
 import (
 	"context"
+	"crypto/cipher"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"os"
 	"path"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/internal/control"
+	"github.com/alpstable/gidari/internal/events"
+	"github.com/alpstable/gidari/internal/handoff"
+	"github.com/alpstable/gidari/internal/hooks"
+	"github.com/alpstable/gidari/internal/monitor"
 	"github.com/alpstable/gidari/internal/proto"
+	"github.com/alpstable/gidari/internal/provider"
 	"github.com/alpstable/gidari/internal/repository"
+	"github.com/alpstable/gidari/internal/state"
 	"github.com/alpstable/gidari/internal/web"
 	"github.com/alpstable/gidari/internal/web/auth"
+	"github.com/alpstable/gidari/internal/workspace"
 	"github.com/alpstable/gidari/tools"
 	"github.com/sirupsen/logrus"
 )
@@ -31,17 +44,130 @@ import (
 var (
 	ErrInvalidEndTimeSize   = fmt.Errorf("invalid end time size, expected 1")
 	ErrInvalidStartTimeSize = fmt.Errorf("invalid start time size, expected 1")
+
+	// ErrInvalidTimeseriesValue is returned when a timeseries start or end value in a request body is not a string.
+	ErrInvalidTimeseriesValue = fmt.Errorf("invalid timeseries value, expected a string")
 )
 
-// connect will attempt to connect to the web API client. Since there are multiple ways to build a transport given the
-// authentication data, this method will exhaust every transport option in the "Authentication" struct.
+// connect will attempt to connect to the web API client, sending the pinned version of the web API with every
+// request and checking every response for notices about its version. With a cassette, the requests are recorded
+// before they are authenticated, or replayed from it.
 func connect(ctx context.Context, cfg *config.Config) (*web.Client, error) {
+	client, err := connectAuth(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	client.Transport = newVersionTransport(client.Transport, cfg.APIVersion, cfg.Logger)
+
+	if cfg.Cassette != nil {
+		client.Transport = cfg.Cassette.Transport(client.Transport)
+	}
+
+	return client, nil
+}
+
+// clientOptions returns the options of the web clients of a configuration, with the embedder's own client and round
+// tripper if they are set. Otherwise, the requests are made with the proxy and TLS configuration of the
+// configuration, if it has any.
+func clientOptions(cfg *config.Config) ([]web.ClientOption, error) {
+	var opts []web.ClientOption
+
+	if cfg.HTTPClient != nil {
+		opts = append(opts, web.WithHTTPClient(cfg.HTTPClient))
+	}
+
+	switch {
+	case cfg.RoundTripper != nil:
+		opts = append(opts, web.WithRoundTripper(cfg.RoundTripper))
+	case cfg.HTTPClient != nil && cfg.HTTPClient.Transport != nil:
+		// The transport of the embedder's client is used as it is, with its own proxy and TLS configuration.
+	case usesProxy(cfg) || cfg.TLS != nil:
+		transportCfg := web.TransportConfig{Proxy: cfg.Proxy.ProxyURL(), NoProxy: proxyBypass(cfg)}
+
+		if cfg.TLS != nil {
+			tlsConfig, err := cfg.TLS.ClientConfig()
+			if err != nil {
+				return nil, fmt.Errorf("failed to configure tls: %w", err)
+			}
+
+			transportCfg.TLS = tlsConfig
+		}
+
+		opts = append(opts, web.WithRoundTripper(web.NewTransport(transportCfg)))
+	}
+
+	return opts, nil
+}
+
+// usesProxy returns true if the configuration or any of its requests set a proxy.
+func usesProxy(cfg *config.Config) bool {
+	if cfg.Proxy != nil {
+		return true
+	}
+
+	for _, req := range cfg.Requests {
+		if req.Proxy != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// proxyBypass returns the hosts of a configuration that are requested without a proxy.
+func proxyBypass(cfg *config.Config) []string {
+	if cfg.Proxy == nil {
+		return nil
+	}
+
+	return cfg.Proxy.NoProxy
+}
+
+// connectAuth will return a web client with the auth transport of the configuration. Since there are multiple ways to
+// build a transport given the authentication data, this method will exhaust every transport option in the
+// "Authentication" struct.
+func connectAuth(ctx context.Context, cfg *config.Config) (*web.Client, error) {
+	opts, err := clientOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if custom := cfg.Authentication.Custom; custom != nil {
+		authenticator, err := auth.NewAuthenticator(custom.Scheme, custom.Settings)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create custom authentication client: %w", err)
+		}
+
+		client, err := web.NewClient(ctx, auth.NewCustom(authenticator).SetURL(cfg.RawURL), opts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create custom authentication client: %w", err)
+		}
+
+		return client, nil
+	}
+
+	// Providers that do not sign their requests take the API key in headers.
+	if profile, ok := provider.Lookup(cfg.Provider); ok && cfg.Authentication.APIKey != nil && !profile.Auth.Signed {
+		apiKey := cfg.Authentication.APIKey
+
+		client, err := web.NewClient(ctx, auth.NewHeader().
+			SetURL(cfg.RawURL).
+			SetHeader(profile.Auth.KeyHeader, apiKey.Key).
+			SetHeader(profile.Auth.SecretHeader, apiKey.Secret), opts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create API key client: %w", err)
+		}
+
+		return client, nil
+	}
+
 	if apiKey := cfg.Authentication.APIKey; apiKey != nil {
 		client, err := web.NewClient(ctx, auth.NewAPIKey().
 			SetURL(cfg.RawURL).
 			SetKey(apiKey.Key).
 			SetPassphrase(apiKey.Passphrase).
-			SetSecret(apiKey.Secret))
+			SetSecret(apiKey.Secret), opts...)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create API key client: %w", err)
 		}
@@ -50,7 +176,7 @@ func connect(ctx context.Context, cfg *config.Config) (*web.Client, error) {
 	}
 
 	if apiKey := cfg.Authentication.Auth2; apiKey != nil {
-		client, err := web.NewClient(ctx, auth.NewAuth2().SetBearer(apiKey.Bearer).SetURL(cfg.RawURL))
+		client, err := web.NewClient(ctx, newAuth2(cfg, apiKey), opts...)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create client: %w", err)
 		}
@@ -59,7 +185,7 @@ func connect(ctx context.Context, cfg *config.Config) (*web.Client, error) {
 	}
 
 	// In the case of no authentication, create a client without an auth transport.
-	client, err := web.NewClient(ctx, nil)
+	client, err := web.NewClient(ctx, nil, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create client: %w", err)
 	}
@@ -114,10 +240,13 @@ func newFetchConfig(req *config.Request, rurl url.URL, client *web.Client) *web.
 	}
 
 	return &web.FetchConfig{
-		Method:      req.Method,
-		URL:         &rurl,
-		C:           client,
-		RateLimiter: req.RateLimiter,
+		Method:         req.Method,
+		URL:            &rurl,
+		C:              client,
+		RateLimiter:    req.RateLimiter,
+		Proxy:          req.Proxy,
+		AcceptEncoding: req.AcceptEncodingHeader(),
+		Timeout:        req.Timeout,
 	}
 }
 
@@ -127,29 +256,200 @@ type flattenedRequest struct {
 	fetchConfig *web.FetchConfig
 	table       string
 	clobColumn  string
+
+	// recordsPath is the JSON path of the records within the response body, if they are wrapped in an envelope.
+	recordsPath string
+
+	// ndjson is set if the response body is newline-delimited JSON, which is always streamed.
+	ndjson bool
+
+	// csv is how the rows of the response body are read into records, if it is CSV, which is always streamed.
+	csv *config.CSVFormat
+
+	// xml is how the elements of the response body are read into records, if it is XML, which is always streamed.
+	xml *config.XMLFormat
+
+	// onEmpty is the policy for responses without records, which does not apply within the "expectedEmpty" windows.
+	onEmpty       string
+	expectedEmpty config.EmptyWindows
+
+	// coalesced are requests that would make an identical HTTP request to this one. Rather than fetch the same
+	// data more than once, the response to this request is fanned out to the tables of the coalesced requests.
+	coalesced []*flattenedRequest
+
+	// stop is the "stopWhen" condition shared by every chunk of a timeseries request.
+	stop *stopCondition
+
+	// recordPages indicates that page metadata should be recorded in the "<table>_pages" side table.
+	recordPages bool
+
+	// page is the 1-indexed position of the request within its timeseries, and chunk is the timeseries window it
+	// queries. Both are unset for requests that are not timeseries.
+	page  int
+	chunk *[2]time.Time
+
+	// progress tracks the fetched chunks of a timeseries request for advancing its watermark.
+	progress *timeseriesProgress
+
+	// sinks are the connection strings that the request is written to. If empty, it is written to every sink.
+	sinks []string
+
+	// write is how the data of the request is written to its table.
+	write tableWrite
+
+	// maintenance are the windows during which the request is held instead of made.
+	maintenance []*config.MaintenanceWindow
+
+	// cost is what the HTTP request counts towards "limits.maxCost".
+	cost float64
+
+	// metricDefs are the metrics extracted from the response for the summary of the run and its assertions.
+	metricDefs []*config.Metric
+
+	// requestKey is the "StateKey" of the configured request that the flattened request was created from.
+	requestKey string
+
+	// canary requests are made, and committed, before the other requests of the run.
+	canary bool
+
+	// conditional requests send the validator of the last response, and validator is the validator of the response
+	// of this run, which is stored once it has been committed.
+	conditional bool
+	validator   *state.Validator
+
+	// freshness is the "freshnessCheck" of a conditional request, and lastValidator the validator of its last
+	// response that the check compares with.
+	freshness     string
+	lastValidator *state.Validator
+
+	// prefetch is the window of the chunks of a timeseries request that are fetched ahead of their web jobs, which
+	// is shared by the chunks of the request.
+	prefetch *prefetchWindow
+
+	// done is set once the response has been handed off for storage, or the request was skipped by its stop
+	// condition. Requests that are not done when a run is interrupted are not recorded in the checkpoint.
+	done bool
+
+	// failed is set when the request was dead-lettered, which also marks it as done.
+	failed bool
+
+	// rows is the number of records of the response, which is only counted if they are needed, e.g. for the
+	// "Config.Events" of the run.
+	rows int
+}
+
+// fetchKey uniquely identifies the HTTP request that will be made for a flattened request.
+func (req *flattenedRequest) fetchKey() string {
+	return fmt.Sprintf("%s %s %s", req.fetchConfig.Method, req.fetchConfig.URL, req.fetchConfig.Body)
+}
+
+// coalesceRequests will deduplicate the flattened requests that would make an identical HTTP request, so that a
+// single fetch can serve every table that is interested in the response. This saves rate limit budget when requests
+// overlap. Requests with a stop condition are never coalesced, since skipping them must not affect other tables.
+func coalesceRequests(reqs []*flattenedRequest) []*flattenedRequest {
+	unique := make([]*flattenedRequest, 0, len(reqs))
+	seen := make(map[string]*flattenedRequest)
+
+	for _, req := range reqs {
+		if req.stop != nil {
+			unique = append(unique, req)
+
+			continue
+		}
+
+		// Responses are only shared by requests that are made the same way, through the same proxy, and that parse
+		// them the same way.
+		csv, _ := json.Marshal(req.csv)
+		xml, _ := json.Marshal(req.xml)
+		key := fmt.Sprintf("%s %q %s %q %q %t %s %s", req.fetchKey(), req.fetchConfig.Proxy, req.fetchConfig.Timeout,
+			req.fetchConfig.AcceptEncoding, req.recordsPath, req.ndjson, csv, xml)
+		if first, ok := seen[key]; ok {
+			first.coalesced = append(first.coalesced, req)
+
+			continue
+		}
+
+		seen[key] = req
+		unique = append(unique, req)
+	}
+
+	return unique
+}
+
+// encodeBody will encode a request body as JSON.
+func encodeBody(body map[string]interface{}) ([]byte, error) {
+	if body == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request body: %w", err)
+	}
+
+	return data, nil
+}
+
+// requestBody returns the JSON body of a request. The "bodyTemplate" of a request is executed with the bounds of the
+// timeseries chunk, if it has one, and the variables of the state store, and is rendered in place of its "body".
+func requestBody(req *config.Request, chunk *[2]time.Time, vars hooks.Variables) ([]byte, error) {
+	if req.BodyTemplate == "" {
+		return encodeBody(req.Body)
+	}
+
+	data := config.BodyTemplateData{Query: req.Query, State: vars}
+	if chunk != nil && req.Timeseries != nil {
+		data.Start = req.Timeseries.FormatTime(chunk[0])
+		data.End = req.Timeseries.FormatTime(chunk[1])
+	}
+
+	body, err := req.RenderBody(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render request body: %w", err)
+	}
+
+	return body, nil
 }
 
 // flatten will compress the request information into a "web.FetchConfig" request and a "table" name for storage
 // interaction.
-func flattenRequest(req *config.Request, rurl url.URL, client *web.Client) *flattenedRequest {
+func flattenRequest(req *config.Request, rurl url.URL, client *web.Client, vars hooks.Variables,
+) (*flattenedRequest, error) {
 	fetchConfig := newFetchConfig(req, rurl, client)
 
-	return &flattenedRequest{
-		fetchConfig: fetchConfig,
-		table:       req.Table,
-		clobColumn:  req.ClobColumn,
+	body, err := requestBody(req, nil, vars)
+	if err != nil {
+		return nil, err
 	}
+
+	fetchConfig.Body = body
+
+	return &flattenedRequest{
+		fetchConfig:   fetchConfig,
+		table:         req.Table,
+		clobColumn:    req.ClobColumn,
+		recordsPath:   req.RecordsPath,
+		ndjson:        req.NDJSON(),
+		csv:           req.CSVFormat(),
+		xml:           req.XMLFormat(),
+		onEmpty:       req.OnEmpty,
+		expectedEmpty: req.ExpectedEmptyOK,
+		recordPages:   req.RecordPages,
+		sinks:         req.ConnectionStrings,
+		write:         newTableWrite(req),
+		maintenance:   req.Maintenance,
+		cost:          req.RequestCost(),
+		metricDefs:    req.Metrics,
+		requestKey:    req.StateKey(),
+		canary:        req.Canary,
+		conditional:   isConditional(req),
+		freshness:     req.FreshnessCheck,
+	}, nil
 }
 
 // chunkTimeseries will attempt to use the query string of a URL to partition the timeseries into "Chunks" of time for
 // queying a web API.
 func chunkTimeseries(timeseries *config.Timeseries, rurl url.URL) error {
-	// If layout is not set, then default it to be RFC3339
-	if timeseries.Layout == nil {
-		str := time.RFC3339
-		timeseries.Layout = &str
-	}
-
 	query := rurl.Query()
 
 	startSlice := query[timeseries.StartName]
@@ -157,23 +457,117 @@ func chunkTimeseries(timeseries *config.Timeseries, rurl url.URL) error {
 		return ErrInvalidStartTimeSize
 	}
 
-	start, err := time.Parse(*timeseries.Layout, startSlice[0])
-	if err != nil {
-		return fmt.Errorf("failed to parse start time: %w", err)
-	}
-
 	endSlice := query[timeseries.EndName]
 	if len(endSlice) != 1 {
 		return ErrInvalidEndTimeSize
 	}
 
-	end, err := time.Parse(*timeseries.Layout, endSlice[0])
+	return chunkTimeseriesRange(timeseries, startSlice[0], endSlice[0])
+}
+
+// chunkTimeseriesBody will use the JSON paths "StartName" and "EndName" into the request body to partition the
+// timeseries into "Chunks" of time for querying a web API.
+func chunkTimeseriesBody(timeseries *config.Timeseries, body map[string]interface{}) error {
+	start, err := tools.GetJSONPath(body, timeseries.StartName)
+	if err != nil {
+		return fmt.Errorf("failed to find start time in body: %w", err)
+	}
+
+	end, err := tools.GetJSONPath(body, timeseries.EndName)
+	if err != nil {
+		return fmt.Errorf("failed to find end time in body: %w", err)
+	}
+
+	startStr, err := timeseriesValueString(start)
+	if err != nil {
+		return err
+	}
+
+	endStr, err := timeseriesValueString(end)
+	if err != nil {
+		return err
+	}
+
+	return chunkTimeseriesRange(timeseries, startStr, endStr)
+}
+
+// timeseriesValueString will convert a timeseries value from a decoded JSON body into a string for parsing. Numeric
+// values are allowed for the Unix epoch layouts.
+func timeseriesValueString(val interface{}) (string, error) {
+	switch val := val.(type) {
+	case string:
+		return val, nil
+	case int:
+		return strconv.Itoa(val), nil
+	case int64:
+		return strconv.FormatInt(val, 10), nil
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64), nil
+	default:
+		return "", fmt.Errorf("%w: %v", ErrInvalidTimeseriesValue, val)
+	}
+}
+
+// timeseriesBodyValue will format a chunk boundary for a JSON request body. Unix epoch layouts are encoded as numbers,
+// all other layouts are encoded as strings.
+func timeseriesBodyValue(timeseries *config.Timeseries, t time.Time) interface{} {
+	str := timeseries.FormatTime(t)
+	if !timeseries.IsUnixLayout() {
+		return str
+	}
+
+	num, err := strconv.ParseInt(str, 10, 64)
+	if err != nil {
+		return str
+	}
+
+	return num
+}
+
+// chunkTimeseriesRange will partition the time between the start and end values into "Chunks" of time for querying a
+// web API.
+func chunkTimeseriesRange(timeseries *config.Timeseries, startStr, endStr string) error {
+	// If layout is not set, then default it to be RFC3339
+	if timeseries.Layout == nil {
+		str := time.RFC3339
+		timeseries.Layout = &str
+	}
+
+	start, err := timeseries.ParseTime(startStr)
+	if err != nil {
+		return fmt.Errorf("failed to parse start time: %w", err)
+	}
+
+	end, err := timeseries.ParseTime(endStr)
 	if err != nil {
 		return fmt.Errorf("unable to parse end time: %w", err)
 	}
 
+	if err := timeseries.ValidateRange(start, end); err != nil {
+		return err
+	}
+
+	// Incremental runs start from the end of the data ingested by previous runs.
+	if mark := timeseries.Watermark; mark != nil && mark.After(start) {
+		start = *mark
+	}
+
+	// Calendar-aligned chunks are widened to cover whole units at each end of the range.
+	if timeseries.Align != "" {
+		loc, err := timeseries.Location()
+		if err != nil {
+			return err
+		}
+
+		start = timeseries.AlignTime(start, loc)
+
+		if aligned := timeseries.AlignTime(end, loc); aligned.Before(end) {
+			end = timeseries.NextAlignedTime(aligned)
+		}
+	}
+
 	for start.Before(end) {
-		next := start.Add(time.Second * time.Duration(timeseries.Period))
+		next := timeseries.NextAlignedTime(start)
 		if next.Before(end) {
 			timeseries.Chunks = append(timeseries.Chunks, [2]time.Time{start, next})
 		} else {
@@ -186,13 +580,42 @@ func chunkTimeseries(timeseries *config.Timeseries, rurl url.URL) error {
 	return nil
 }
 
+// chunkBody will return the JSON encoded request body with the timeseries start and end fields set to the chunk.
+func chunkBody(body map[string]interface{}, timeseries *config.Timeseries, chunk [2]time.Time) ([]byte, error) {
+	base, err := encodeBody(body)
+	if err != nil {
+		return nil, err
+	}
+
+	// Decode a fresh copy of the body so that chunks do not share nested maps.
+	var chunkBody map[string]interface{}
+	if err := json.Unmarshal(base, &chunkBody); err != nil {
+		return nil, fmt.Errorf("failed to copy request body: %w", err)
+	}
+
+	if err := tools.SetJSONPath(chunkBody, timeseries.StartName, timeseriesBodyValue(timeseries, chunk[0])); err != nil {
+		return nil, fmt.Errorf("failed to set start time in body: %w", err)
+	}
+
+	if err := tools.SetJSONPath(chunkBody, timeseries.EndName, timeseriesBodyValue(timeseries, chunk[1])); err != nil {
+		return nil, fmt.Errorf("failed to set end time in body: %w", err)
+	}
+
+	return encodeBody(chunkBody)
+}
+
 // flattenRequestTimeseries will compress the request information into a "web.FetchConfig" request and a "table" name
 // for storage interaction. This function will create a flattened request for each time series in the request. If no
-// timeseries are defined, this function will return a single flattened request.
-func flattenRequestTimeseries(req *config.Request, rurl url.URL, client *web.Client) ([]*flattenedRequest, error) {
+// timeseries are defined, this function will return a single flattened request. The templates of the request are
+// executed with "vars", the variables of the state store, which may be nil.
+func flattenRequestTimeseries(req *config.Request, rurl url.URL, client *web.Client, vars hooks.Variables,
+) ([]*flattenedRequest, error) {
 	timeseries := req.Timeseries
 	if timeseries == nil {
-		flatReq := flattenRequest(req, rurl, client)
+		flatReq, err := flattenRequest(req, rurl, client, vars)
+		if err != nil {
+			return nil, err
+		}
 
 		return []*flattenedRequest{flatReq}, nil
 	}
@@ -209,30 +632,82 @@ func flattenRequestTimeseries(req *config.Request, rurl url.URL, client *web.Cli
 		rurl.RawQuery = query.Encode()
 	}
 
-	if err := chunkTimeseries(timeseries, rurl); err != nil {
+	inBody := timeseries.Target == config.TimeseriesTargetBody
+	stop := newStopCondition(req.StopWhen)
+
+	if inBody {
+		if err := chunkTimeseriesBody(timeseries, req.Body); err != nil {
+			return nil, fmt.Errorf("failed to set time series chunks: %w", err)
+		}
+	} else if err := chunkTimeseries(timeseries, rurl); err != nil {
 		return nil, fmt.Errorf("failed to set time series chunks: %w", err)
 	}
 
-	for _, chunk := range timeseries.Chunks {
-		// copy the request and update it to reflect the partitioned timeseries
-		chunkReq := req
-		chunkReq.Query[timeseries.StartName] = chunk[0].Format(*timeseries.Layout)
-		chunkReq.Query[timeseries.EndName] = chunk[1].Format(*timeseries.Layout)
+	progress := newTimeseriesProgress(req.StateKey(), timeseries.Chunks)
+
+	for idx, chunk := range timeseries.Chunks {
+		chunk := chunk
+
+		var fetchConfig *web.FetchConfig
+
+		if inBody {
+			body, err := chunkBody(req.Body, timeseries, chunk)
+			if err != nil {
+				return nil, err
+			}
+
+			fetchConfig = newFetchConfig(req, rurl, client)
+			fetchConfig.Body = body
+		} else {
+			// copy the request and update it to reflect the partitioned timeseries
+			chunkReq := req
+			chunkReq.Query[timeseries.StartName] = timeseries.FormatTime(chunk[0])
+			chunkReq.Query[timeseries.EndName] = timeseries.FormatTime(chunk[1])
+
+			body, err := requestBody(chunkReq, &chunk, vars)
+			if err != nil {
+				return nil, err
+			}
 
-		fetchConfig := newFetchConfig(chunkReq, rurl, client)
+			fetchConfig = newFetchConfig(chunkReq, rurl, client)
+			fetchConfig.Body = body
+		}
 
 		requests = append(requests, &flattenedRequest{
-			fetchConfig: fetchConfig,
-			table:       req.Table,
-			clobColumn:  req.ClobColumn,
+			fetchConfig:   fetchConfig,
+			table:         req.Table,
+			clobColumn:    req.ClobColumn,
+			recordsPath:   req.RecordsPath,
+			ndjson:        req.NDJSON(),
+			csv:           req.CSVFormat(),
+			xml:           req.XMLFormat(),
+			onEmpty:       req.OnEmpty,
+			expectedEmpty: req.ExpectedEmptyOK,
+			stop:          stop,
+			recordPages:   req.RecordPages,
+			page:          idx + 1,
+			chunk:         &chunk,
+			progress:      progress,
+			sinks:         req.ConnectionStrings,
+			write:         newTableWrite(req),
+			maintenance:   req.Maintenance,
+			cost:          req.RequestCost(),
+			metricDefs:    req.Metrics,
+			requestKey:    req.StateKey(),
+			canary:        req.Canary,
+			conditional:   isConditional(req),
+			freshness:     req.FreshnessCheck,
 		})
 	}
 
+	newPrefetchWindow(timeseries.Prefetch, requests)
+
 	return requests, nil
 }
 
-// flattenConfigRequests will flatten the requests into a single slice for HTTP requests.
-func flattenConfigRequests(ctx context.Context, cfg *config.Config) ([]*flattenedRequest, error) {
+// flattenConfigRequests will flatten the requests into a single slice for HTTP requests. The templates of the requests
+// read and write the variables of the state store, which may be nil.
+func flattenConfigRequests(ctx context.Context, cfg *config.Config, store *state.Store) ([]*flattenedRequest, error) {
 	client, err := connect(ctx, cfg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to web API: %w", err)
@@ -241,173 +716,1190 @@ func flattenConfigRequests(ctx context.Context, cfg *config.Config) ([]*flattene
 	var flattenedRequests []*flattenedRequest
 
 	for _, req := range cfg.Requests {
-		flatReqs, err := flattenRequestTimeseries(req, *cfg.URL, client)
+		if err := req.RenderQuery(store); err != nil {
+			return nil, fmt.Errorf("failed to render request query: %w", err)
+		}
+
+		flatReqs, err := flattenRequestTimeseries(req, *cfg.URL, client, store)
 		if err != nil {
 			return nil, err
 		}
 
+		for _, flatReq := range flatReqs {
+			flatReq.fetchConfig.Clock = cfg.Clock
+		}
+
+		warnPartialChunk(cfg, req)
+
 		flattenedRequests = append(flattenedRequests, flatReqs...)
 	}
 
-	if len(flattenedRequests) == 0 {
+	// Timeseries requests that are already up to date with their watermark have no chunks to fetch.
+	if len(cfg.Requests) == 0 {
 		return nil, config.ErrNoRequests
 	}
 
 	return flattenedRequests, nil
 }
 
+// warnPartialChunk will warn if the timeseries range of a request is not a whole number of periods long, so that its
+// last chunk is shorter than the others. Ranges that start at a watermark are not checked, since the watermark of an
+// incremental run is rarely a whole number of periods from the end.
+func warnPartialChunk(cfg *config.Config, req *config.Request) {
+	timeseries := req.Timeseries
+	if timeseries == nil || len(timeseries.Chunks) == 0 {
+		return
+	}
+
+	start, end := timeseries.Chunks[0][0], timeseries.Chunks[len(timeseries.Chunks)-1][1]
+	if mark := timeseries.Watermark; mark != nil && mark.Equal(start) {
+		return
+	}
+
+	if partial, ok := timeseries.PartialChunk(start, end); ok {
+		logWarn := tools.LogFormatter{Msg: fmt.Sprintf("the timeseries range of %s is not a multiple of its "+
+			"period %s, its last chunk is %s long", req.Endpoint, timeseries.Period.Duration(), partial)}
+		cfg.Logger.Warn(logWarn.String())
+	}
+}
+
 type repoJob struct {
 	req   http.Request
 	b     []byte
 	table string
-}
 
-type repoConfig struct {
-	repos      []repository.Generic
-	closeRepos func()
-	jobs       chan *repoJob
-	done       chan bool
-	logger     *logrus.Logger
-}
+	// spill is the name of a file holding the response body, set in place of "b" when the transport is running
+	// low on memory.
+	spill string
 
-func newRepoConfig(ctx context.Context, cfg *config.Config, volume int) (*repoConfig, error) {
-	repos, closeRepos, err := repos(ctx, cfg)
-	if err != nil {
-		return nil, err
-	}
+	// sinks are the connection strings to write the data to. If empty, the data is written to every repository.
+	sinks []string
 
-	return &repoConfig{
-		repos:      repos,
-		closeRepos: closeRepos,
-		jobs:       make(chan *repoJob, volume*len(repos)),
-		done:       make(chan bool, volume),
-		logger:     cfg.Logger,
-	}, nil
+	// write is how the data is written to the table.
+	write tableWrite
+
+	// chunk is the timeseries chunk that the data was fetched for, if any.
+	chunk *[2]time.Time
+
+	// provenance is where and when the data was fetched, if it was fetched from the web API.
+	provenance *provenance
 }
 
-func repositoryWorker(_ context.Context, workerID int, cfg *repoConfig) {
-	for job := range cfg.jobs {
-		if job == nil {
-			cfg.done <- false
+// tableWrite is how the data of a request is written to its table by the repositories.
+type tableWrite struct {
+	// mode is the "proto" write mode of the data.
+	mode string
 
-			continue
-		}
+	// conflictKeys are the columns that identify a record, or empty for the primary key of the table.
+	conflictKeys []string
 
-		reqs := []*proto.UpsertRequest{
-			{
-				Table: job.table,
-				Data:  job.b,
-			},
-		}
+	// orderBy is the timestamp column that the records of the batch are written in order of, if it is set.
+	orderBy string
 
-		for _, req := range reqs {
-			for _, repo := range cfg.repos {
-				txfn := func(sctx context.Context, repo repository.Generic) error {
-					start := time.Now()
+	// dedupeKeys are the columns that identify a record within the batch, of which only the latest copy is written,
+	// if they are set.
+	dedupeKeys []string
 
-					rsp, err := repo.Upsert(sctx, req)
-					if err != nil {
-						cfg.logger.Fatalf("error upserting data: %v", err)
+	// fields are the columns that the fields of the records are mapped to before anything else is written, keyed by
+	// field.
+	fields map[string]string
 
-						return fmt.Errorf("error upserting data: %w", err)
-					}
+	// flatten is how the nested objects of the records are flattened into columns once their fields are mapped, with
+	// an empty delimiter if they are not.
+	flatten config.Flatten
 
-					rt := repo.Type()
+	// children are the child tables that the array columns of the records are written to once they are flattened.
+	children []childTable
 
-					msg := fmt.Sprintf("partial upsert completed: %s.%s", proto.SchemeFromStorageType(rt), req.Table)
-					logInfo := tools.LogFormatter{
-						WorkerID:      workerID,
-						WorkerName:    "repository",
-						Duration:      time.Since(start),
-						Msg:           msg,
-						UpsertedCount: rsp.UpsertedCount,
-						MatchedCount:  rsp.MatchedCount,
-					}
+	// includeFields are the only columns of the records that are written, and excludeFields are those that are not,
+	// once their fields are mapped.
+	includeFields, excludeFields []string
 
-					cfg.logger.Infof(logInfo.String())
+	// transforms are the unit conversions applied to the columns of the records, keyed by column.
+	transforms map[string]string
 
-					return nil
-				}
-				// Put the data onto the transaction channel for storage.
-				repo.Transact(txfn)
-			}
-		}
+	// numberLocales are the locales of the columns whose numbers are localized strings, keyed by column.
+	numberLocales map[string]string
 
-		cfg.done <- true
-	}
-}
+	// types are the types that the columns of the records are coerced to once they are transformed, keyed by
+	// column, and onTypeError is what is done with the records whose values cannot be.
+	types       map[string]string
+	onTypeError string
 
-type webJob struct {
-	*flattenedRequest
-	repoJobs chan<- *repoJob
-	logger   *logrus.Logger
+	// masks are the masks that the columns of the records are redacted with once they are coerced, keyed by column.
+	masks map[string]string
+
+	// encrypt is how the columns of the records are encrypted once they are masked, or nil if they are not.
+	encrypt *config.Encrypt
+
+	// ttl is how the records of the table expire on storage that expires records natively, or nil if they do not.
+	ttl *config.TTL
+
+	// autoCreate is how the table is created if it does not exist, or nil if it is not created.
+	autoCreate *config.AutoCreate
+
+	// columns are created with the table in addition to those inferred from its records, for tables whose records
+	// omit empty values.
+	columns []*proto.Column
+
+	// evolve is how the table is changed for the fields of its records that it does not have a column for, or nil
+	// if those fields are dropped.
+	evolve *config.SchemaEvolution
+
+	// partitionKeys are the columns that the records are partitioned by, when the table is written with several
+	// transactions.
+	partitionKeys []string
+
+	// chunkColumns are the columns that the boundaries of the timeseries chunk of the records are written to.
+	chunkColumns config.ChunkColumns
+
+	// provenance are the columns that where and when the records were fetched are written to, if any.
+	provenance *config.Provenance
+
+	// columnStats collects the statistics of the columns of the records that are written.
+	columnStats bool
+
+	// hooks are the names of the transform hooks that are run on the records once they are coerced.
+	hooks []string
+
+	// surrogateKey is the column of synthetic keys that is generated for the records, if any.
+	surrogateKey *config.SurrogateKey
+}
+
+// newTableWrite returns how the data of a request is written. The "replace" write mode truncates the table at the
+// start of the run, and is then written like an upsert.
+func newTableWrite(req *config.Request) tableWrite {
+	write := tableWrite{
+		mode:         proto.WriteModeUpsert,
+		conflictKeys: req.ConflictKeys,
+		orderBy:      req.OrderBy,
+		dedupeKeys:   req.DedupeKeys,
+		fields:       req.Fields,
+		transforms:   req.Transforms,
+		autoCreate:   req.AutoCreate,
+		evolve:       req.SchemaEvolution,
+
+		numberLocales: req.NumberLocales,
+		includeFields: req.IncludeFields,
+		excludeFields: req.ExcludeFields,
+		types:         req.Types,
+		onTypeError:   req.OnTypeError,
+		masks:         req.Masks,
+		encrypt:       req.Encrypt,
+		ttl:           req.TTL,
+		partitionKeys: req.PartitionKeys,
+		provenance:    req.Provenance,
+		columnStats:   req.ColumnStats,
+		hooks:         req.Hooks,
+		surrogateKey:  req.SurrogateKey,
+	}
+
+	if req.Flatten != nil {
+		write.flatten = *req.Flatten
+		if write.flatten.Delimiter == "" {
+			write.flatten.Delimiter = config.DefaultFlattenDelimiter
+		}
+	}
+
+	if req.Timeseries != nil && req.Timeseries.ChunkColumns != nil {
+		write.chunkColumns = *req.Timeseries.ChunkColumns
+	}
+
+	switch req.WriteMode {
+	case config.WriteModeInsert:
+		write.mode = proto.WriteModeInsert
+	case config.WriteModeAppend:
+		write.mode = proto.WriteModeAppend
+	}
+
+	if len(req.ChildTables) != 0 {
+		write.children = newChildTables(write, req.ChildTables)
+	}
+
+	return write
+}
+
+// upsertRequest returns the upsert request for the data of a repository job.
+func (job *repoJob) upsertRequest(data []byte) *proto.UpsertRequest {
+	return &proto.UpsertRequest{
+		Table:        job.table,
+		Data:         data,
+		WriteMode:    job.write.mode,
+		ConflictKeys: job.write.conflictKeys,
+	}
+}
+
+// writesTo returns true if data for "sinks" should be written to the repository with the connection string "dns".
+// Empty sinks are written to every repository.
+func writesTo(sinks []string, dns string) bool {
+	if len(sinks) == 0 {
+		return true
+	}
+
+	for _, sink := range sinks {
+		if sink == dns {
+			return true
+		}
+	}
+
+	return false
+}
+
+type repoConfig struct {
+	// ctx is the context of the run, which the transform hooks are called with.
+	ctx context.Context
+
+	repos      []repository.Generic
+	dns        []string
+	closeRepos func()
+	jobs       chan *repoJob
+	logger     *logrus.Logger
+	monitor    *monitor.Monitor
+
+	// clock is the clock of the run, which the time-based surrogate keys are generated with.
+	clock tools.Clock
+
+	// pending tracks the repository jobs that have been sent but not yet processed.
+	pending *sync.WaitGroup
+
+	// status records what the repository workers are doing.
+	status *runStatus
+
+	// sinks accounts for the writes to every storage target.
+	sinks *sinkLedger
+
+	// manifest records the records written to each table for the manifest of the run.
+	manifest *manifestRecorder
+
+	// failure records the error of a worker that fails the batch.
+	failure *batchFailure
+
+	// handoff hands off the records that are written to every table to the code that embeds the transport.
+	handoff *handoff.Handoff
+
+	// maskKey is the HMAC key of the "hash" masks, or empty for plain hashes.
+	maskKey []byte
+
+	// ciphers are the ciphers that columns are encrypted with, keyed by key ID.
+	ciphers map[string]cipher.AEAD
+
+	// ordered buffers the jobs of the tables with an "orderBy" column until the end of the batch.
+	ordered *orderedJobs
+
+	// created are the tables that have been created for "autoCreate".
+	created *createdTables
+
+	// expiries are the tables whose expiry has been configured for "ttl".
+	expiries *createdTables
+
+	// partitions are the transactions that repositories are written with in addition to their own, keyed by the
+	// index of the repository.
+	partitions map[int][]repository.Generic
+
+	// truncating are the tables that are truncated in the batch, which are not partitioned.
+	truncating map[string]bool
+
+	// stats are the column statistics of the records that the batch writes, which is nil unless a request has
+	// "columnStats".
+	stats *columnStats
+
+	// writeLimit holds back the upserts of the storage targets with a "storageRateLimit".
+	writeLimit *writeLimiter
+}
+
+func newRepoConfig(ctx context.Context, cfg *config.Config, volume int) (*repoConfig, error) {
+	ciphers, err := newCiphers(cfg.EncryptionKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	repos, closeRepos, err := repos(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	partitions, closePartitions, err := partitionRepos(ctx, cfg, repos)
+	if err != nil {
+		closeRepos()
+
+		return nil, err
+	}
+
+	return &repoConfig{
+		ctx:   ctx,
+		repos: repos,
+		dns:   cfg.ConnectionStrings,
+		closeRepos: func() {
+			closePartitions()
+			closeRepos()
+		},
+		jobs:       make(chan *repoJob, volume*len(repos)),
+		pending:    new(sync.WaitGroup),
+		logger:     cfg.Logger,
+		monitor:    cfg.Monitor,
+		clock:      tools.ClockOrReal(cfg.Clock),
+		handoff:    cfg.Handoff,
+		maskKey:    []byte(cfg.MaskKey),
+		ciphers:    ciphers,
+		ordered:    new(orderedJobs),
+		created:    new(createdTables),
+		expiries:   new(createdTables),
+		partitions: partitions,
+		truncating: make(map[string]bool),
+		writeLimit: newWriteLimiter(cfg),
+	}, nil
 }
 
-func newWebJob(cfg *config.Config, req *flattenedRequest, repoJobs chan<- *repoJob) *webJob {
-	return &webJob{
-		flattenedRequest: req,
-		repoJobs:         repoJobs,
-		logger:           cfg.Logger,
+// upsertRepos will put an upsert request onto the transaction channel of every repository that the job is written to.
+func upsertRepos(workerID int, cfg *repoConfig, job *repoJob, req *proto.UpsertRequest) {
+	// Records that cannot be transformed fail the transactions that they are written to, like a failed upsert.
+	data, transformErr := mapFields(job.write.fields, req.Data)
+	if transformErr == nil {
+		data, transformErr = flattenData(job.write.flatten, data)
+	}
+
+	var childData [][]byte
+	if transformErr == nil {
+		data, childData, transformErr = extractChildren(job.write.children, data)
+	}
+
+	if transformErr == nil {
+		data, transformErr = filterFields(job.write.includeFields, job.write.excludeFields, data)
+	}
+
+	if transformErr == nil {
+		data, transformErr = annotateChunk(job.write.chunkColumns, job.chunk, data)
+	}
+
+	if transformErr == nil {
+		data, transformErr = annotateProvenance(job.write.provenance, job.provenance, data)
+	}
+
+	if transformErr == nil {
+		data, transformErr = localizeData(job.write.numberLocales, data)
+	}
+
+	if transformErr == nil {
+		data, transformErr = transformData(job.write.transforms, data)
+	}
+
+	if transformErr == nil {
+		data, transformErr = generateKeys(job.write.surrogateKey, cfg.clock.Now(), data)
+	}
+
+	if transformErr == nil {
+		var skipped int
+
+		data, skipped, transformErr = coerceData(job.write.types, job.write.onTypeError, data)
+		if skipped > 0 {
+			logWarn := tools.LogFormatter{
+				WorkerID:   workerID,
+				WorkerName: "repository",
+				Msg:        fmt.Sprintf("skipped %d records of %s that could not be coerced to their types", skipped, job.table),
+			}
+			cfg.logger.Warn(logWarn.String())
+		}
+	}
+
+	if transformErr == nil {
+		data, transformErr = hookData(cfg.ctx, job.write.hooks, job.table, data)
+	}
+
+	// Masks and encryption are applied last, so that nothing but redacted values is handed off or written.
+	if transformErr == nil {
+		data, transformErr = maskData(job.write.masks, cfg.maskKey, data)
+	}
+
+	if transformErr == nil {
+		data, transformErr = encryptData(job.write.encrypt, cfg.ciphers, data)
+	}
+
+	if transformErr == nil {
+		req.Data = data
+
+		handOff(workerID, cfg, job.table, data)
+		cfg.manifest.wrote(req)
+
+		if err := cfg.stats.observe(job, data); err != nil {
+			logWarn := tools.LogFormatter{
+				WorkerID:   workerID,
+				WorkerName: "repository",
+				Msg:        fmt.Sprintf("unable to collect column statistics of %s: %v", job.table, err),
+			}
+			cfg.logger.Warn(logWarn.String())
+		}
+	}
+
+	for idx, repo := range cfg.repos {
+		if !writesTo(job.sinks, cfg.dns[idx]) {
+			continue
+		}
+
+		idx, sink := idx, sinkName(idx, repo)
+
+		// Tables written with several transactions have their records partitioned between them.
+		txs, reqs := []repository.Generic{repo}, []*proto.UpsertRequest{req}
+		if transformErr == nil {
+			txs, reqs = partitionRequests(cfg, idx, job, req)
+		}
+
+		for part, tx := range txs {
+			req := reqs[part]
+
+			// A failed upsert fails the transaction of its target, which skips the rest of the upserts of the batch
+			// and is reported when it is committed, while the other targets are still written to.
+			txfn := func(sctx context.Context, repo repository.Generic) error {
+				if transformErr != nil {
+					cfg.sinks.upsert(idx, sink, nil, transformErr)
+
+					logWarn := tools.LogFormatter{
+						WorkerID:   workerID,
+						WorkerName: "repository",
+						Msg:        fmt.Sprintf("error transforming data for %s: %v", sink, transformErr),
+					}
+					cfg.logger.Warn(logWarn.String())
+
+					return fmt.Errorf("error transforming data for %s: %w", sink, transformErr)
+				}
+
+				if err := createTable(sctx, workerID, cfg, idx, repo, job.write, req); err != nil {
+					cfg.sinks.upsert(idx, sink, nil, err)
+
+					logWarn := tools.LogFormatter{
+						WorkerID:   workerID,
+						WorkerName: "repository",
+						Msg:        fmt.Sprintf("error creating table on %s: %v", sink, err),
+					}
+					cfg.logger.Warn(logWarn.String())
+
+					return fmt.Errorf("error creating table on %s: %w", sink, err)
+				}
+
+				if err := evolveTable(sctx, workerID, cfg, repo, job.write, req); err != nil {
+					cfg.sinks.upsert(idx, sink, nil, err)
+
+					logWarn := tools.LogFormatter{
+						WorkerID:   workerID,
+						WorkerName: "repository",
+						Msg:        fmt.Sprintf("error evolving table on %s: %v", sink, err),
+					}
+					cfg.logger.Warn(logWarn.String())
+
+					return fmt.Errorf("error evolving table on %s: %w", sink, err)
+				}
+
+				if err := configureExpiry(sctx, workerID, cfg, idx, repo, job.write, req); err != nil {
+					cfg.sinks.upsert(idx, sink, nil, err)
+
+					logWarn := tools.LogFormatter{
+						WorkerID:   workerID,
+						WorkerName: "repository",
+						Msg:        fmt.Sprintf("error configuring expiry on %s: %v", sink, err),
+					}
+					cfg.logger.Warn(logWarn.String())
+
+					return fmt.Errorf("error configuring expiry on %s: %w", sink, err)
+				}
+
+				if err := waitWriteLimit(sctx, workerID, cfg, idx, sink, req); err != nil {
+					cfg.sinks.upsert(idx, sink, nil, err)
+
+					return fmt.Errorf("error upserting data to %s: %w", sink, err)
+				}
+
+				start := time.Now()
+
+				split := new(batchSplit)
+
+				rsp, err := upsertSplit(sctx, repo, job, req, split)
+				cfg.sinks.upsert(idx, sink, rsp, err)
+
+				if split.splits > 0 {
+					logSplit(workerID, cfg, sink, req, split)
+				}
+
+				if err != nil {
+					logWarn := tools.LogFormatter{
+						WorkerID:   workerID,
+						WorkerName: "repository",
+						Msg:        fmt.Sprintf("error upserting data to %s: %v", sink, err),
+					}
+					cfg.logger.Warn(logWarn.String())
+
+					return fmt.Errorf("error upserting data to %s: %w", sink, err)
+				}
+
+				rt := repo.Type()
+
+				cfg.monitor.Upsert(sink, rsp.UpsertedCount+rsp.MatchedCount, len(req.Data))
+
+				msg := fmt.Sprintf("partial upsert completed: %s.%s", proto.SchemeFromStorageType(rt), req.Table)
+				logInfo := tools.LogFormatter{
+					WorkerID:      workerID,
+					WorkerName:    "repository",
+					Duration:      time.Since(start),
+					Msg:           msg,
+					UpsertedCount: rsp.UpsertedCount,
+					MatchedCount:  rsp.MatchedCount,
+				}
+
+				cfg.logger.Infof(logInfo.String())
+
+				return nil
+			}
+			// Put the data onto the transaction channel for storage.
+			tx.Transact(txfn)
+		}
+	}
+
+	upsertChildren(workerID, cfg, job, childData)
+}
+
+// handOff will hand off the records of an upsert request to the code that embeds the transport. Records that cannot
+// be handed off are still written to storage.
+func handOff(workerID int, cfg *repoConfig, table string, data []byte) {
+	if cfg.handoff == nil {
+		return
+	}
+
+	records, err := appendRecords(nil, data)
+	if err == nil {
+		err = cfg.handoff.Write(table, records)
+	}
+
+	if err != nil {
+		logWarn := tools.LogFormatter{
+			WorkerID:   workerID,
+			WorkerName: "repository",
+			Msg:        fmt.Sprintf("error handing off data for %s: %v", table, err),
+		}
+		cfg.logger.Warn(logWarn.String())
+	}
+}
+
+// truncations returns the connection strings of the tables that have yet to be truncated and that a batch writes to,
+// which are no longer pending.
+func (res *runResources) truncations(fetches []*flattenedRequest) map[string][]string {
+	sinks := make(map[string][]string)
+
+	for _, fetch := range fetches {
+		for _, target := range append([]*flattenedRequest{fetch}, fetch.coalesced...) {
+			for _, table := range append([]string{target.table, pagesTable(target.table)}, target.childTables()...) {
+				if tableSinks, ok := res.truncate[table]; ok {
+					sinks[table] = tableSinks
+
+					delete(res.truncate, table)
+				}
+			}
+		}
+	}
+
+	return sinks
+}
+
+// truncateRepos will put a truncate request for the tables that are written to each repository onto its transaction
+// channel, ahead of the upserts of the batch, so that the tables are only emptied if the batch is committed.
+func truncateRepos(cfg *repoConfig, sinks map[string][]string) {
+	tables := make([]string, 0, len(sinks))
+	for table := range sinks {
+		tables = append(tables, table)
+		cfg.truncating[table] = true
+	}
+
+	sort.Strings(tables)
+
+	for idx, repo := range cfg.repos {
+		repoRequest := &proto.TruncateRequest{}
+
+		for _, table := range tables {
+			if writesTo(sinks[table], cfg.dns[idx]) {
+				repoRequest.Tables = append(repoRequest.Tables, table)
+			}
+		}
+
+		if len(repoRequest.Tables) == 0 {
+			continue
+		}
+
+		sink := sinkName(idx, repo)
+
+		repo.Transact(func(sctx context.Context, repo repository.Generic) error {
+			start := time.Now()
+
+			if _, err := repo.Truncate(sctx, repoRequest); err != nil {
+				return fmt.Errorf("unable to truncate tables on %s: %w", sink, err)
+			}
+
+			logInfo := tools.LogFormatter{
+				WorkerName: "repository",
+				Duration:   time.Since(start),
+				Msg:        fmt.Sprintf("truncated tables on %s: %s", sink, strings.Join(repoRequest.Tables, ", ")),
+			}
+			cfg.logger.Infof(logInfo.String())
+
+			return nil
+		})
+	}
+}
+
+func repositoryWorker(_ context.Context, workerID int, cfg *repoConfig) {
+	for job := range cfg.jobs {
+		if job == nil {
+			cfg.pending.Done()
+
+			continue
+		}
+
+		// Ordered and deduplicated jobs are written once every job of the batch has been received.
+		if job.write.orderBy != "" || len(job.write.dedupeKeys) != 0 {
+			cfg.ordered.add(job)
+			cfg.pending.Done()
+
+			continue
+		}
+
+		cfg.status.setWorker("repository", workerID, "upserting "+job.table)
+
+		// Spilled jobs are streamed from disk in small batches to keep memory usage low.
+		if job.spill != "" {
+			err := readSpill(job.spill, degradedBatchSize, func(data []byte) error {
+				upsertRepos(workerID, cfg, job, job.upsertRequest(data))
+
+				return nil
+			})
+			if err != nil {
+				cfg.failure.fail("repository", workerID, fmt.Errorf("error reading spilled data: %w", err))
+			}
+
+			os.Remove(job.spill)
+
+			cfg.pending.Done()
+			cfg.status.setWorker("repository", workerID, workerIdle)
+
+			continue
+		}
+
+		upsertRepos(workerID, cfg, job, job.upsertRequest(job.b))
+
+		cfg.pending.Done()
+		cfg.status.setWorker("repository", workerID, workerIdle)
+	}
+}
+
+// runResources are shared by every batch, and every web job, of a run.
+type runResources struct {
+	threads     int
+	memory      *memoryGovernor
+	ws          *workspace.Workspace
+	budget      *budget
+	metrics     *runMetrics
+	deadLetters *deadLetterFile
+	retries     int
+	monitor     *monitor.Monitor
+	control     *control.Control
+	events      *events.Stream
+
+	// spend estimates what the HTTP requests of the run are billed, which is nil unless a request has a pricing.
+	spend *spendLedger
+
+	// parked tracks the web jobs of paused requests that are held until they are resumed or canceled.
+	parked *sync.WaitGroup
+
+	// status is the progress of the run that is logged on "Config.Dump", which is nil unless it is set.
+	status *runStatus
+
+	// sinks accounts for the writes to every storage target, for the summary of the run.
+	sinks *sinkLedger
+
+	// manifest records what the run wrote to each table, which is nil unless the configuration has a "manifest".
+	manifest *manifestRecorder
+
+	// runID identifies the run in the "provenance" of its records.
+	runID string
+
+	// failure records the error of a worker that fails the current batch, which is set for each batch.
+	failure *batchFailure
+
+	// checkpoint persists the retries of failed requests, which is nil unless checkpointing is enabled.
+	checkpoint *state.Checkpoint
+
+	// truncate are the connection strings of the tables that have yet to be truncated, which are truncated in the
+	// transactions of the first batch that writes to them.
+	truncate map[string][]string
+
+	// provider is the profile of the web API, which is nil unless the configuration selects one.
+	provider *provider.Profile
+
+	// scaler grows and shrinks the number of web workers that fetch from each host at once, which is nil unless
+	// the configuration autoscales them.
+	scaler *autoscaler
+
+	// streamBatchSize is the number of records of each repository job of a streamed response, which is zero
+	// unless responses are streamed.
+	streamBatchSize int
+
+	// cache answers requests with the responses of earlier runs, which is nil unless the configuration has a
+	// "responseCache".
+	cache *responseCache
+
+	// stats are the column statistics of the records that the run has committed, which is nil unless a request has
+	// "columnStats".
+	stats *columnStats
+
+	// deadline aborts the requests and writes of the run once it has to stop, which is nil unless the configuration
+	// has a "maxDuration" or "deadline".
+	deadline *runDeadline
+}
+
+func newRunResources(cfg *config.Config, ws *workspace.Workspace, bgt *budget, metrics *runMetrics,
+	deadLetters *deadLetterFile,
+) *runResources {
+	threads := runtime.NumCPU()
+	scaler := newAutoscaler(cfg.Autoscale, threads, cfg.Logger, cfg.Clock)
+
+	res := &runResources{
+		threads:     threads,
+		memory:      newMemoryGovernor(uint64(cfg.MaxMemory), scaler.workers(threads), cfg.Logger, cfg.Clock),
+		ws:          ws,
+		budget:      bgt,
+		metrics:     metrics,
+		deadLetters: deadLetters,
+		monitor:     cfg.Monitor,
+		control:     cfg.Control,
+		events:      cfg.Events,
+		parked:      new(sync.WaitGroup),
+		sinks:       newSinkLedger(),
+		runID:       tools.ClockOrReal(cfg.Clock).Now().UTC().Format(manifestIDLayout),
+
+		scaler:          scaler,
+		streamBatchSize: cfg.StreamBatchSize,
+		cache:           newResponseCache(cfg),
+		stats:           newColumnStats(cfg),
+	}
+
+	if deadLetters != nil && cfg.DeadLetter != nil {
+		res.retries = cfg.DeadLetter.Retries
+	}
+
+	if profile, ok := provider.Lookup(cfg.Provider); ok {
+		res.provider = profile
+	}
+
+	return res
+}
+
+type webJob struct {
+	*flattenedRequest
+	*runResources
+	repoJobs chan<- *repoJob
+	pending  *sync.WaitGroup
+	logger   *logrus.Logger
+	clock    tools.Clock
+}
+
+func newWebJob(cfg *config.Config, req *flattenedRequest, repoCfg *repoConfig, res *runResources) *webJob {
+	return &webJob{
+		flattenedRequest: req,
+		runResources:     res,
+		repoJobs:         repoCfg.jobs,
+		pending:          repoCfg.pending,
+		logger:           cfg.Logger,
+		clock:            tools.ClockOrReal(cfg.Clock),
+	}
+}
+
+// send will queue a repository job, tracking it as pending until a repository worker has processed it. A nil job
+// signals that a request produced no data.
+func (job *webJob) send(rj *repoJob) {
+	if job.pending != nil {
+		job.pending.Add(1)
+	}
+
+	job.repoJobs <- rj
+}
+
+// markDone will mark the targets of a web job as done.
+func markDone(targets []*flattenedRequest) {
+	for _, target := range targets {
+		target.done = true
+	}
+}
+
+// readBody will read the response body into memory. If the transport is running low on memory, the body is spilled
+// to disk instead and, if it is valid JSON, the name of the spill file is returned in place of the data.
+func readBody(job *webJob, body io.ReadCloser) ([]byte, string, error) {
+	defer body.Close()
+
+	if !job.memory.degraded() {
+		bytes, err := io.ReadAll(body)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read response body: %w", err)
+		}
+
+		return bytes, "", nil
+	}
+
+	name, err := spill(job.ws, body)
+	if err != nil {
+		return nil, "", err
+	}
+
+	valid, err := validSpill(name)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if valid {
+		return nil, name, nil
+	}
+
+	// Invalid JSON needs to be held in memory to be stored in the "clobColumn".
+	defer os.Remove(name)
+
+	bytes, err := os.ReadFile(name)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read spill file: %w", err)
+	}
+
+	return bytes, "", nil
+}
+
+// sendRepoJob will put the response data for a target request onto the repository job channel. If the data is not
+// valid JSON, it will be wrapped in the target's "clobColumn", or discarded if no such column is defined.
+func sendRepoJob(job *webJob, target *flattenedRequest, req *http.Request, prov *provenance, bytes []byte,
+	spilled string, valid bool,
+) {
+	if !valid {
+		if target.clobColumn == "" {
+			job.send(nil)
+			msg := fmt.Sprintf("response body for %s was invalid JSON, "+
+				"discarding data since no 'clobColumn' was defined in the configuration file",
+				job.fetchConfig.URL)
+			logInfo := tools.LogFormatter{Msg: msg}
+			job.logger.Warnf(logInfo.String())
+
+			return
+		}
+
+		data := make(map[string]string)
+		data[target.clobColumn] = string(bytes)
+
+		var err error
+
+		bytes, err = json.Marshal(data)
+		if err != nil {
+			job.send(nil)
+			job.logger.Errorf("failed to marhsal data: %s", err)
+
+			return
+		}
+	}
+
+	job.send(&repoJob{
+		b:     bytes,
+		req:   *req,
+		table: target.table,
+		spill: spilled,
+		sinks: target.sinks,
+		write: target.write,
+		chunk: target.chunk,
+
+		provenance: prov,
+	})
+}
+
+func webWorker(ctx context.Context, workerID int, jobs <-chan *webJob) {
+	for job := range jobs {
+		if job.park(ctx, workerID) || job.holdForMaintenance(ctx, workerID) {
+			continue
+		}
+
+		state := "requesting " + job.requestKey
+		if job.page > 0 {
+			state = fmt.Sprintf("%s, page %d", state, job.page)
+		}
+
+		job.status.setWorker("web", workerID, state)
+		runWebJob(ctx, workerID, job)
+		job.status.setWorker("web", workerID, workerIdle)
+	}
+}
+
+// runWebJob will make the HTTP request of a web job and send the response to the repository workers.
+func runWebJob(ctx context.Context, workerID int, job *webJob) {
+	start := time.Now()
+
+	defer job.status.finish()
+
+	// The chunk may have been fetched ahead of its web job, and its response is closed if it is not used.
+	prefetched := job.prefetch.claim(job.flattenedRequest)
+	defer prefetched.discard(job)
+
+	// Once the run is interrupted, the remaining jobs are left for a resumed run.
+	if ctx.Err() != nil {
+		return
+	}
+
+	// Canceled requests are not marked as done, so that they are left for a resumed run.
+	targets := job.activeTargets()
+	if len(targets) == 0 {
+		logInfo := tools.LogFormatter{
+			WorkerID:   workerID,
+			WorkerName: "web",
+			Msg:        fmt.Sprintf("skipping %s, request canceled", job.fetchConfig.URL),
+		}
+		job.logger.Infof(logInfo.String())
+
+		return
+	}
+
+	if reason := job.stop.met(); reason != "" {
+		job.send(nil)
+		markDone(targets)
+
+		for _, target := range targets {
+			job.monitor.Skip(target.requestKey)
+		}
+
+		logInfo := tools.LogFormatter{
+			WorkerID:   workerID,
+			WorkerName: "web",
+			Msg:        fmt.Sprintf("skipping %s, stop condition met: %s", job.fetchConfig.URL, reason),
+		}
+		job.logger.Infof(logInfo.String())
+
+		return
 	}
-}
 
-func webWorker(ctx context.Context, workerID int, jobs <-chan *webJob) {
-	for job := range jobs {
-		start := time.Now()
+	if prefetched == nil && !job.budget.reserve(job.cost) {
+		return
+	}
 
-		rsp, err := web.Fetch(ctx, job.fetchConfig)
-		if err != nil {
-			job.logger.Fatal(err)
+	if err := job.memory.acquire(ctx); err != nil {
+		if ctx.Err() != nil {
+			return
 		}
 
-		bytes, err := io.ReadAll(rsp.Body)
-		if err != nil {
-			job.logger.Fatal(err)
+		job.failure.fail("web", workerID, err)
+
+		return
+	}
+
+	fetchedAt := job.clock.Now()
+
+	for _, target := range targets {
+		job.monitor.Start(target.requestKey)
+		job.events.Emit(requestEvent(events.ChunkStarted, target))
+	}
+
+	// The freshness of a chunk that was prefetched is not checked, since its data has already been requested.
+	if prefetched == nil {
+		if rsp, ok := job.checkFreshness(ctx, workerID); ok {
+			job.memory.release()
+			job.notModified(workerID, targets, rsp, job.clock.Now().Sub(fetchedAt))
+
+			return
 		}
+	}
+
+	rsp, attempts, err := fetchChunk(ctx, job, prefetched)
+	job.spend.add(job.requestKey, attempts)
 
-		if !json.Valid(bytes) {
-			if job.flattenedRequest.clobColumn == "" {
-				job.repoJobs <- nil
-				msg := fmt.Sprintf("response body for %s was invalid JSON, "+
-					"discarding data since no 'clobColumn' was defined in the configuration file",
-					job.fetchConfig.URL)
-				logInfo := tools.LogFormatter{Msg: msg}
-				job.logger.Warnf(logInfo.String())
+	if err != nil {
+		job.memory.release()
 
-				continue
+		// Requests that were not made before the run was stopped are left for a resumed run.
+		if ctx.Err() != nil {
+			for _, target := range targets {
+				job.monitor.Cancel(target.requestKey)
 			}
 
-			data := make(map[string]string)
-			data[job.flattenedRequest.clobColumn] = string(bytes)
+			return
+		}
+
+		for _, target := range targets {
+			job.monitor.Fail(target.requestKey)
 
-			bytes, err = json.Marshal(data)
-			if err != nil {
-				job.repoJobs <- nil
-				job.logger.Errorf("failed to marhsal data: %s", err)
+			event := requestEvent(events.Error, target)
+			event.Error = err.Error()
+			job.events.Emit(event)
+		}
+
+		if job.deadLetters == nil {
+			job.failure.fail("web", workerID, err)
+
+			return
+		}
+
+		job.deadLetter(workerID, targets, err, attempts, fetchedAt)
+
+		return
+	}
+
+	observeValidator(job.flattenedRequest, rsp)
+
+	if rsp.StatusCode == http.StatusNotModified {
+		job.memory.release()
+		job.notModified(workerID, targets, rsp, job.clock.Now().Sub(fetchedAt))
+
+		return
+	}
+
+	if job.fetchConfig.Method == http.MethodHead {
+		job.memory.release()
+		job.finishWithoutData(workerID, targets, rsp, job.clock.Now().Sub(fetchedAt), "HEAD responses have no data")
+
+		return
+	}
+
+	prov := newProvenance(job, workerID, rsp, fetchedAt)
+
+	// Streamed bodies are sent to the repository workers as they are read.
+	body, streamed, items, err := streamBody(job, targets, rsp, prov)
+
+	var (
+		bytes   []byte
+		spilled string
+	)
+
+	if err == nil && !streamed {
+		bytes, spilled, err = readBody(job, body)
+	}
+
+	if err == nil && !streamed {
+		bytes, spilled, err = job.extractRecords(bytes, spilled)
+	}
+
+	elapsed := job.clock.Now().Sub(fetchedAt)
+
+	if expiry, ok := job.fetchConfig.C.CredentialExpiry(); ok {
+		job.metrics.addCredentialExpiry(expiry.Sub(fetchedAt.Add(elapsed)))
+	}
+
+	job.memory.release()
+
+	if err != nil {
+		// Responses that were cut off by the deadline of the run, before any of their records were written, are
+		// left for a resumed run.
+		if !streamed && job.deadline.passed() {
+			for _, target := range targets {
+				job.monitor.Cancel(target.requestKey)
 			}
+
+			return
 		}
 
-		job.repoJobs <- &repoJob{b: bytes, req: *rsp.Request, table: job.table}
+		job.failure.fail("web", workerID, err)
 
-		// strings.Replace is used to ensure no line endings are present in the user input.
-		escapedPath := strings.ReplaceAll(rsp.Request.URL.Path, "\n", "")
-		escapedPath = strings.ReplaceAll(escapedPath, "\r", "")
+		return
+	}
 
-		escapedHost := strings.ReplaceAll(rsp.Request.URL.Host, "\n", "")
-		escapedHost = strings.ReplaceAll(escapedHost, "\r", "")
+	valid := streamed || spilled != "" || json.Valid(bytes)
 
-		logInfo := tools.LogFormatter{
-			WorkerID:   workerID,
-			WorkerName: "web",
-			Duration:   time.Since(start),
-			Host:       escapedHost,
-			Msg:        fmt.Sprintf("web request completed: %s", escapedPath),
+	if valid && !streamed {
+		if err := job.stop.observe(bytes, spilled); err != nil {
+			job.failure.fail("web", workerID, err)
+
+			return
+		}
+	}
+
+	// Count the records before the data is handed off, since spilled data is removed once it is upserted.
+	if valid && !streamed && (recordsPages(targets) || job.budget.countsRows() || job.metrics != nil ||
+		job.monitor != nil || job.events != nil || handlesEmpty(targets)) {
+		if items, err = countResponseRecords(bytes, spilled); err != nil {
+			job.failure.fail("web", workerID, err)
+
+			return
 		}
-		job.logger.Infof(logInfo.String())
 	}
+
+	job.budget.addRows(items)
+
+	empty := valid && items == 0 && handlesEmpty(targets)
+
+	for _, target := range targets {
+		job.metrics.addRows(target.table, items)
+		target.rows = items
+		job.monitor.Finish(target.requestKey, items, rsp.RateLimitWait)
+
+		if !valid || streamed {
+			continue
+		}
+
+		if err := job.metrics.observe(target.metricDefs, bytes, spilled); err != nil {
+			job.failure.fail("web", workerID, err)
+
+			return
+		}
+	}
+
+	// Fan the response out to every request that was coalesced into this fetch. Each table needs its own copy
+	// of spilled data since the repository worker removes the spill file once it has been upserted.
+	for idx, target := range targets {
+		if streamed {
+			break
+		}
+
+		// Empty responses that are skipped or failed are not written. The last target owns the spill file.
+		if empty && !target.writesEmpty(fetchedAt) {
+			if spilled != "" && idx == len(targets)-1 {
+				os.Remove(spilled)
+			}
+
+			continue
+		}
+
+		targetSpill := spilled
+		if spilled != "" && idx < len(targets)-1 {
+			if targetSpill, err = copySpill(job.ws, spilled); err != nil {
+				job.failure.fail("web", workerID, err)
+
+				return
+			}
+		}
+
+		sendRepoJob(job, target, rsp.Request, prov, bytes, targetSpill, valid)
+	}
+
+	sendPageRecords(job, targets, rsp, items, elapsed, fetchedAt)
+	job.manifest.fetched(targets, elapsed)
+
+	var incomplete map[*flattenedRequest]bool
+	if empty {
+		incomplete = job.handleEmpty(workerID, targets, rsp, attempts, elapsed, fetchedAt)
+	}
+
+	for _, target := range targets {
+		if !incomplete[target] {
+			target.progress.complete(target.page)
+		}
+	}
+
+	markDone(targets)
+
+	// strings.Replace is used to ensure no line endings are present in the user input.
+	escapedPath := strings.ReplaceAll(rsp.Request.URL.Path, "\n", "")
+	escapedPath = strings.ReplaceAll(escapedPath, "\r", "")
+
+	escapedHost := strings.ReplaceAll(rsp.Request.URL.Host, "\n", "")
+	escapedHost = strings.ReplaceAll(escapedHost, "\r", "")
+
+	logInfo := tools.LogFormatter{
+		WorkerID:   workerID,
+		WorkerName: "web",
+		Duration:   time.Since(start),
+		Host:       escapedHost,
+		Msg:        fmt.Sprintf("web request completed: %s", escapedPath),
+	}
+	job.logger.Infof(logInfo.String())
 }
 
-func truncate(ctx context.Context, cfg *config.Config, truncateRequest *proto.TruncateRequest) error {
+// truncate will truncate the tables in the request on every repository. Tables in "sinks" are only truncated on the
+// repositories with the given connection strings.
+func truncate(ctx context.Context, cfg *config.Config, truncateRequest *proto.TruncateRequest,
+	sinks map[string][]string,
+) error {
 	start := time.Now()
 
 	repos, closeRepos, err := repos(ctx, cfg)
@@ -417,16 +1909,24 @@ func truncate(ctx context.Context, cfg *config.Config, truncateRequest *proto.Tr
 
 	defer closeRepos()
 
-	for _, repo := range repos {
+	for idx, repo := range repos {
 		start := time.Now()
 
-		_, err := repo.Truncate(ctx, truncateRequest)
+		repoRequest := &proto.TruncateRequest{}
+
+		for _, table := range truncateRequest.Tables {
+			if writesTo(sinks[table], cfg.ConnectionStrings[idx]) {
+				repoRequest.Tables = append(repoRequest.Tables, table)
+			}
+		}
+
+		_, err := repo.Truncate(ctx, repoRequest)
 		if err != nil {
 			return fmt.Errorf("unable to truncate tables: %w", err)
 		}
 
 		rt := repo.Type()
-		tables := strings.Join(truncateRequest.Tables, ", ")
+		tables := strings.Join(repoRequest.Tables, ", ")
 		msg := fmt.Sprintf("truncated tables on %q: %v", proto.SchemeFromStorageType(rt), tables)
 
 		logInfo := tools.LogFormatter{
@@ -445,28 +1945,41 @@ func truncate(ctx context.Context, cfg *config.Config, truncateRequest *proto.Tr
 	return nil
 }
 
-// Truncate will truncate the defined tables in the configuration.
-func Truncate(ctx context.Context, cfg *config.Config) error {
+// truncateTables returns the tables of the requests that are truncated, and the connection strings of each table.
+func truncateTables(cfg *config.Config) (*proto.TruncateRequest, map[string][]string) {
 	// truncateRequest is a special request that will truncate the table before upserting data.
 	truncateRequest := new(proto.TruncateRequest)
 
-	if cfg.Truncate {
-		for _, req := range cfg.Requests {
-			// Add the table to the list of tables to truncate.
-			if req.Truncate != nil && *req.Truncate {
-				truncateRequest.Tables = append(truncateRequest.Tables, req.Table)
+	// sinks are the connection strings of the tables that are not written to every repository.
+	sinks := make(map[string][]string)
+
+	for _, req := range cfg.Requests {
+		if table := req.Table; req.Truncate != nil && *req.Truncate && table != "" {
+			truncateRequest.Tables = append(truncateRequest.Tables, table)
+			sinks[table] = req.ConnectionStrings
+
+			if req.RecordPages {
+				truncateRequest.Tables = append(truncateRequest.Tables, pagesTable(table))
+				sinks[pagesTable(table)] = req.ConnectionStrings
 			}
-		}
-	} else {
-		// checking for request-specific truncate
-		for _, req := range cfg.Requests {
-			if table := req.Table; req.Truncate != nil && *req.Truncate && table != "" {
-				truncateRequest.Tables = append(truncateRequest.Tables, table)
+
+			// Child tables are truncated with their parent, so that they do not keep the children of replaced
+			// records.
+			for _, child := range req.ChildTables {
+				truncateRequest.Tables = append(truncateRequest.Tables, child.Table)
+				sinks[child.Table] = req.ConnectionStrings
 			}
 		}
 	}
 
-	return truncate(ctx, cfg, truncateRequest)
+	return truncateRequest, sinks
+}
+
+// Truncate will truncate the defined tables in the configuration.
+func Truncate(ctx context.Context, cfg *config.Config) error {
+	truncateRequest, sinks := truncateTables(cfg)
+
+	return truncate(ctx, cfg, truncateRequest, sinks)
 }
 
 // Upsert will use the configuration file to upsert data from the
@@ -474,64 +1987,384 @@ func Truncate(ctx context.Context, cfg *config.Config) error {
 // For each DNS entry in the configuration file, a repository will be created and used to upsert data. For each
 // repository, a transaction will be created and used to upsert data. The transaction will be committed at the end
 // of the upsert operation. If the transaction fails, the transaction will be rolled back. Note that it is possible
-// for some repository transactions to succeed and others to fail.
+// for some repository transactions to succeed and others to fail. If checkpointing is configured, a transaction is
+// committed for every batch of requests instead, and the committed requests are recorded in the checkpoint file.
 func Upsert(ctx context.Context, cfg *config.Config) error {
+	if cfg.Preflight {
+		if err := Preflight(ctx, cfg); err != nil {
+			return err
+		}
+	}
+
+	ws, err := newWorkspace(cfg)
+	if err != nil {
+		return err
+	}
+
+	store, err := openState(cfg)
+	if err != nil {
+		return err
+	}
+
+	manifest := newManifestRecorder(cfg)
+
+	err = upsert(ctx, cfg, ws, store, manifest)
+	saveManifest(cfg, manifest, err)
+	cfg.Events.Summary(manifestStatus(err), err)
+
+	if closeErr := ws.Close(err != nil); closeErr != nil {
+		cfg.Logger.Warn(tools.LogFormatter{Msg: closeErr.Error()}.String())
+	}
+
+	if ws.Retained() {
+		cfg.Logger.Info(tools.LogFormatter{Msg: fmt.Sprintf("workspace retained: %s", ws.Dir())}.String())
+	}
+
+	return err
+}
+
+// newWorkspace will create the workspace for a run from the configuration.
+func newWorkspace(cfg *config.Config) (*workspace.Workspace, error) {
+	var root string
+
+	policy := workspace.RetainNever
+
+	if cfg.Workspace != nil {
+		root = cfg.Workspace.Dir
+
+		switch cfg.Workspace.Retain {
+		case config.WorkspaceRetainOnFailure:
+			policy = workspace.RetainOnFailure
+		case config.WorkspaceRetainAlways:
+			policy = workspace.RetainAlways
+		}
+	}
+
+	ws, err := workspace.New(root, policy)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create workspace: %w", err)
+	}
+
+	return ws, nil
+}
+
+// openState will open the state store from the configuration, returning nil if no store is configured.
+func openState(cfg *config.Config) (*state.Store, error) {
+	if cfg.State == nil || cfg.State.File == "" {
+		return nil, nil
+	}
+
+	store, err := state.Open(cfg.State.File, cfg.Clock)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open state: %w", err)
+	}
+
+	return store, nil
+}
+
+func upsert(ctx context.Context, cfg *config.Config, ws *workspace.Workspace, store *state.Store,
+	manifest *manifestRecorder,
+) error {
 	start := time.Now()
-	threads := runtime.NumCPU()
 
-	if err := Truncate(ctx, cfg); err != nil {
+	// Storage creates its temporary files in the workspace of the run.
+	ctx = workspace.NewContext(ctx, ws)
+
+	// Reaching a limit cancels the run, which then shuts down like an interrupted run.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	budget := newBudget(cfg.Limits, cancel)
+
+	// Reaching the deadline, or that of the context, cancels the run as well, and aborts the requests in-flight.
+	deadline := newRunDeadline(ctx, cfg, tools.ClockOrReal(cfg.Clock).Now(), cancel)
+	defer deadline.stop()
+
+	if err := checkPrimaryKeys(ctx, cfg); err != nil {
+		return err
+	}
+
+	checkpoint, err := openCheckpoint(cfg)
+	if err != nil {
+		return err
+	}
+
+	applyWatermarks(cfg, store)
+
+	flattenedRequests, err := flattenConfigRequests(ctx, cfg, store)
+	if err != nil {
 		return err
 	}
 
-	flattenedRequests, err := flattenConfigRequests(ctx, cfg)
+	remaining := skipCompleted(cfg, flattenedRequests, checkpoint)
+	canaries, others := splitCanaries(remaining)
+
+	// Identical fetches are only made once, with the response fanned out to every table requesting it. Canary
+	// requests are coalesced separately, since they are made before the others.
+	canaryFetches, otherFetches := coalesceRequests(canaries), coalesceRequests(others)
+	fetches := append(append([]*flattenedRequest(nil), canaryFetches...), otherFetches...)
+	if coalesced := len(remaining) - len(fetches); coalesced > 0 {
+		logInfo := tools.LogFormatter{Msg: fmt.Sprintf("coalesced %d duplicate requests", coalesced)}
+		cfg.Logger.Info(logInfo.String())
+	}
+
+	warnBudget(cfg, fetches)
+	applyValidators(fetches, store)
+
+	for _, req := range remaining {
+		cfg.Monitor.Plan(req.requestKey, 1)
+		cfg.Control.Register(req.requestKey)
+	}
+
+	deadLetters, err := openDeadLetters(cfg)
 	if err != nil {
 		return err
 	}
 
-	repoConfig, err := newRepoConfig(ctx, cfg, len(flattenedRequests))
+	defer deadLetters.close(cfg.Logger)
+
+	metrics := newRunMetrics(cfg)
+	res := newRunResources(cfg, ws, budget, metrics, deadLetters)
+	defer res.memory.stop()
+
+	res.checkpoint = checkpoint
+	res.manifest = manifest
+	res.deadline = deadline
+
+	if manifest != nil {
+		res.runID = manifest.manifest.ID
+	}
+
+	// Tables are only truncated at the start of a run, never when resuming a run that has committed data. They are
+	// truncated in the transactions of the first batch, so that they are only emptied once it is committed.
+	if checkpoint.Len() == 0 {
+		_, res.truncate = truncateTables(cfg)
+	}
+
+	res.spend = newSpendLedger(cfg)
+	defer res.spend.report(cfg.Logger)
+
+	defer res.sinks.report(cfg.Logger)
+
+	canaryBatches := batchRequests(canaryFetches, checkpointEvery(cfg))
+	batches := append(append([][]*flattenedRequest(nil), canaryBatches...),
+		batchRequests(otherFetches, checkpointEvery(cfg))...)
+
+	res.status = newRunStatus(cfg, fetches, len(batches))
+
+	stopDumps := res.status.listen(cfg.Dump, cfg.Logger, res)
+	defer stopDumps()
+
+	// canceled is the number of requests that were canceled while the run continued, which are left in the
+	// checkpoint for a resumed run.
+	canceled := 0
+
+	for idx, batch := range batches {
+		if err := upsertBatch(ctx, cfg, res, batch); err != nil {
+			// The writes of a batch that could not be committed within the grace period are aborted.
+			if deadlineErr := deadline.err(); deadlineErr != nil {
+				err = fmt.Errorf("%w: %v", deadlineErr, err)
+			}
+
+			manifest.failedBatch(batch, err)
+
+			return err
+		}
+
+		batchCanceled := canceledRequests(cfg.Control, batch)
+		canceled += batchCanceled
+
+		if ctx.Err() != nil || batchCanceled > 0 {
+			if checkpoint, err = interruptCheckpoint(cfg, checkpoint); err != nil {
+				return err
+			}
+		}
+
+		if err := completeBatch(checkpoint, batch); err != nil {
+			return err
+		}
+
+		emitCommitted(cfg.Events, batch)
+
+		// The spend, and the validators of conditional requests, are saved with the watermarks.
+		res.spend.record(store)
+		recordValidators(batch, store)
+
+		// Only advance the watermarks once the data has been committed.
+		if err := advanceWatermarks(flattenedRequests, store); err != nil {
+			return err
+		}
+
+		if err := budget.err(); err != nil {
+			return interrupted(cfg, checkpoint, start, err)
+		}
+
+		if err := deadline.err(); err != nil {
+			return interrupted(cfg, checkpoint, start, err)
+		}
+
+		if ctx.Err() != nil {
+			return interrupted(cfg, checkpoint, start, ErrInterrupted)
+		}
+
+		// The other requests are only made once the canary requests have been committed and have succeeded.
+		if idx == len(canaryBatches)-1 {
+			if err := checkCanaries(cfg, canaries, metrics, len(otherFetches)); err != nil {
+				return err
+			}
+		}
+	}
+
+	if canceled > 0 {
+		logWarn := tools.LogFormatter{
+			Msg: fmt.Sprintf("%d canceled requests were left in the checkpoint, use --resume to make them", canceled),
+		}
+		cfg.Logger.Warn(logWarn.String())
+	} else if err := checkpoint.Remove(); err != nil {
+		return err
+	}
+
+	msg := "upsert completed"
+	if budget != nil {
+		msg = fmt.Sprintf("upsert completed using %s", budget.summary())
+	}
+
+	if metrics != nil {
+		msg = fmt.Sprintf("%s, metrics: %s", msg, metrics.summary())
+	}
+
+	logInfo := tools.LogFormatter{Duration: time.Since(start), Msg: msg}
+	cfg.Logger.Info(logInfo.String())
+
+	if err := metrics.assert(cfg.Assertions); err != nil {
+		return err
+	}
+
+	// Run hooks are only run once every request has been made, not when canceled requests are left for a resumed
+	// run.
+	if canceled > 0 {
+		return nil
+	}
+
+	return runHooks(ctx, cfg, store)
+}
+
+// upsertBatch will fetch a batch of requests and upsert the responses, committing the data to storage once every
+// request in the batch has been processed. If the context is canceled, no new requests are started, but the requests
+// in-flight are still stored and committed.
+func upsertBatch(ctx context.Context, cfg *config.Config, res *runResources, fetches []*flattenedRequest) error {
+	// A worker that cannot continue cancels the batch, which is then not committed.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	res.failure = newBatchFailure(cancel, cfg.Logger)
+
+	// The transactions outlive a canceled run so that the data that has been fetched can be committed.
+	repoConfig, err := newRepoConfig(res.deadline.detachWrite(ctx), cfg, len(fetches))
 	if err != nil {
 		return err
 	}
 
 	defer repoConfig.closeRepos()
 
+	repoConfig.status = res.status
+	repoConfig.sinks = res.sinks
+	repoConfig.manifest = res.manifest
+	repoConfig.failure = res.failure
+	repoConfig.stats = res.stats.batch()
+
+	truncateRepos(repoConfig, res.truncations(fetches))
+
 	// Start the repository workers.
-	for id := 1; id <= threads; id++ {
-		go repositoryWorker(ctx, id, repoConfig)
+	for id := 1; id <= res.threads; id++ {
+		res.status.setWorker("repository", id, workerIdle)
+
+		go repositoryWorker(res.deadline.detachWrite(ctx), id, repoConfig)
 	}
 
 	cfg.Logger.Info(tools.LogFormatter{Msg: "repository workers started"}.String())
 
 	webWorkerJobs := make(chan *webJob, len(cfg.Requests))
+	res.status.startBatch(webWorkerJobs, repoConfig.jobs)
+
+	var webWorkers sync.WaitGroup
+
+	// Start the same number of web workers as the cores on the machine, or as many as the autoscaler may let fetch
+	// from a host at once.
+	for id := 1; id <= res.scaler.workers(res.threads); id++ {
+		webWorkers.Add(1)
+		res.status.setWorker("web", id, workerIdle)
+
+		go func(id int) {
+			defer webWorkers.Done()
 
-	// Start the same number of web workers as the cores on the machine.
-	for id := 1; id <= threads; id++ {
-		go webWorker(ctx, id, webWorkerJobs)
+			webWorker(ctx, id, webWorkerJobs)
+		}(id)
 	}
 
 	cfg.Logger.Info(tools.LogFormatter{Msg: "web workers started"}.String())
 
 	// Enqueue the worker jobs
-	for _, req := range flattenedRequests {
-		webWorkerJobs <- newWebJob(cfg, req, repoConfig.jobs)
+	for _, req := range fetches {
+		if ctx.Err() != nil {
+			break
+		}
+
+		req.prefetch.queue(req)
+		webWorkerJobs <- newWebJob(cfg, req, repoConfig, res)
 	}
 
+	close(webWorkerJobs)
+
 	cfg.Logger.Info(tools.LogFormatter{Msg: "web worker jobs enqueued"}.String())
 
-	// Wait for all of the data to flush.
-	for a := 1; a <= len(flattenedRequests); a++ {
-		<-repoConfig.done
+	// Wait for the web workers to finish fetching, including the requests that are paused, and then for all of the
+	// data to flush.
+	webWorkers.Wait()
+	res.parked.Wait()
+	repoConfig.pending.Wait()
+	close(repoConfig.jobs)
+
+	if err := res.failure.err(); err != nil {
+		return err
+	}
+
+	if err := writeOrdered(repoConfig); err != nil {
+		return err
 	}
 
-	// Commit the transactions and check for errors.
-	for _, repo := range repoConfig.repos {
-		if err := repo.Commit(); err != nil {
-			return fmt.Errorf("unable to commit transaction: %w", err)
+	if err := writeColumnStats(repoConfig, res.stats, res.runID, tools.ClockOrReal(cfg.Clock).Now()); err != nil {
+		return err
+	}
+
+	// Commit the transactions and check for errors. Every target is committed, even once another has failed, so
+	// that the data of the batch reaches each target that can take it.
+	var (
+		commitErr error
+		failed    int
+	)
+
+	for idx, repo := range repoConfig.repos {
+		err := commitRepo(repoConfig, res.sinks, idx, repo)
+		if err == nil {
+			continue
+		}
+
+		failed++
+
+		res.events.Emit(events.Event{Type: events.Error, Sink: sinkName(idx, repo), Error: err.Error()})
+
+		if commitErr == nil {
+			commitErr = err
 		}
 	}
 
-	logInfo := tools.LogFormatter{Duration: time.Since(start), Msg: "upsert completed"}
-	cfg.Logger.Info(logInfo.String())
+	if commitErr != nil {
+		return fmt.Errorf("unable to commit transaction to %d of %d storage targets: %w", failed,
+			len(repoConfig.repos), commitErr)
+	}
+
+	res.stats.merge(repoConfig.stats)
 
 	return nil
 }
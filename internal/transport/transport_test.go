@@ -12,9 +12,10 @@ package transport
 import (
 	"context"
 	"encoding/json"
-	"fmt"
+	"io"
 	"net/url"
 	"path"
+	"path/filepath"
 	"reflect"
 	"testing"
 	"time"
@@ -187,195 +188,131 @@ func TestTimeseries(t *testing.T) {
 	})
 }
 
-func TestWebWorker(t *testing.T) {
-	t.Parallel()
-	logger := logrus.New()
-	t.Run("normal json response", func(t *testing.T) {
-		t.Parallel()
+// newCassetteJob returns a web job for a request of "rawURL" whose response is replayed from a cassette in
+// "testdata/cassettes", so that the web worker can be tested without the web API.
+func newCassetteJob(t *testing.T, cassette, rawURL string, req *flattenedRequest,
+	repoJobs chan *repoJob,
+) *webJob {
+	t.Helper()
 
-		baseurl := "https://pokeapi.co"
-		endpoint := "/api/v2/pokemon/ditto"
-		table := "test"
-
-		webWorkerJobs := make(chan *webJob, 1)
-		repoJobs := make(chan *repoJob, 1)
-
-		client, err := web.NewClient(context.Background(), nil)
-		if err != nil {
-			t.Fatalf("Error while creating client: %s", err)
-		}
+	replay, err := web.LoadCassette(filepath.Join("testdata", "cassettes", cassette))
+	if err != nil {
+		t.Fatalf("failed to load cassette: %v", err)
+	}
 
-		url, err := url.Parse(baseurl)
-		if err != nil {
-			t.Fatalf("Error while parsing url: %s", err)
-		}
+	client, err := web.NewClient(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("Error while creating client: %s", err)
+	}
 
-		url.Path = path.Join(url.Path, endpoint)
-		rateLimiter := rate.NewLimiter(rate.Every(1), 1)
+	client.Transport = replay.Transport(nil)
 
-		cfg := web.FetchConfig{
-			C:           client,
-			Method:      "GET",
-			URL:         url,
-			RateLimiter: rateLimiter,
-		}
+	url, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatalf("Error while parsing url: %s", err)
+	}
 
-		req := flattenedRequest{
-			fetchConfig: &cfg,
-			table:       table,
-		}
+	req.fetchConfig = &web.FetchConfig{
+		C:           client,
+		Method:      "GET",
+		URL:         url,
+		RateLimiter: rate.NewLimiter(rate.Inf, 1),
+	}
 
-		job := webJob{
-			&req,
-			repoJobs,
-			logger,
-		}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
 
-		go webWorker(context.Background(), 1, webWorkerJobs)
+	cfg := &config.Config{Logger: logger}
+	res := newRunResources(cfg, nil, nil, nil, nil)
 
-		webWorkerJobs <- &job
+	_, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
 
-		close(webWorkerJobs)
+	res.failure = newBatchFailure(cancel, logger)
 
-		for i := 0; i < 1; i++ {
-			result := <-repoJobs
+	return newWebJob(cfg, req, &repoConfig{jobs: repoJobs}, res)
+}
 
-			fmt.Println(result)
-			if result == nil {
-				t.Fatalf("Expected repoJob, go nil")
-			}
-			if result.table != table {
-				t.Fatalf("Expected table to be %s, instead got: %s", table, result.table)
-			}
-		}
-	})
+func TestWebWorker(t *testing.T) {
+	t.Parallel()
 
-	t.Run("html response with clobColumn not set", func(t *testing.T) {
+	t.Run("normal json response", func(t *testing.T) {
 		t.Parallel()
 
-		baseurl := "https://api.cryptonator.com"
-		endpoint := "/api/ticker/btc-usd"
 		table := "test"
 
 		webWorkerJobs := make(chan *webJob, 1)
 		repoJobs := make(chan *repoJob, 1)
 
-		client, err := web.NewClient(context.Background(), nil)
-		if err != nil {
-			t.Fatalf("Error while creating client: %s", err)
-		}
+		webWorkerJobs <- newCassetteJob(t, "pokeapi.json", "https://pokeapi.co/api/v2/pokemon/ditto",
+			&flattenedRequest{table: table}, repoJobs)
 
-		url, err := url.Parse(baseurl)
-		if err != nil {
-			t.Fatalf("Error while parsing url: %s", err)
-		}
+		close(webWorkerJobs)
 
-		url.Path = path.Join(url.Path, endpoint)
-		rateLimiter := rate.NewLimiter(rate.Every(1), 1)
+		go webWorker(context.Background(), 1, webWorkerJobs)
 
-		cfg := web.FetchConfig{
-			C:           client,
-			Method:      "GET",
-			URL:         url,
-			RateLimiter: rateLimiter,
+		result := <-repoJobs
+		if result == nil {
+			t.Fatalf("Expected repoJob, go nil")
 		}
 
-		req := flattenedRequest{
-			fetchConfig: &cfg,
-			table:       table,
+		if result.table != table {
+			t.Fatalf("Expected table to be %s, instead got: %s", table, result.table)
 		}
+	})
 
-		job := webJob{
-			&req,
-			repoJobs,
-			logger,
-		}
+	t.Run("html response with clobColumn not set", func(t *testing.T) {
+		t.Parallel()
 
-		go webWorker(context.Background(), 1, webWorkerJobs)
+		webWorkerJobs := make(chan *webJob, 1)
+		repoJobs := make(chan *repoJob, 1)
 
-		webWorkerJobs <- &job
+		webWorkerJobs <- newCassetteJob(t, "cryptonator.json", "https://api.cryptonator.com/api/ticker/btc-usd",
+			&flattenedRequest{table: "test"}, repoJobs)
 
 		close(webWorkerJobs)
 
-		for i := 0; i < 1; i++ {
-			result := <-repoJobs
+		go webWorker(context.Background(), 1, webWorkerJobs)
 
-			fmt.Println(result)
-			if result != nil {
-				t.Fatalf("Expected repoJob to be nil")
-			}
+		if result := <-repoJobs; result != nil {
+			t.Fatalf("Expected repoJob to be nil")
 		}
 	})
 
 	t.Run("html response with clobColumn set", func(t *testing.T) {
 		t.Parallel()
 
-		baseurl := "https://api.cryptonator.com"
-		endpoint := "/api/ticker/btc-usd"
 		table := "test"
 		clobColumn := "data"
 
 		webWorkerJobs := make(chan *webJob, 1)
 		repoJobs := make(chan *repoJob, 1)
 
-		client, err := web.NewClient(context.Background(), nil)
-		if err != nil {
-			t.Fatalf("Error while creating client: %s", err)
-		}
+		webWorkerJobs <- newCassetteJob(t, "cryptonator.json", "https://api.cryptonator.com/api/ticker/btc-usd",
+			&flattenedRequest{table: table, clobColumn: clobColumn}, repoJobs)
 
-		url, err := url.Parse(baseurl)
-		if err != nil {
-			t.Fatalf("Error while parsing url: %s", err)
-		}
+		close(webWorkerJobs)
 
-		url.Path = path.Join(url.Path, endpoint)
-		rateLimiter := rate.NewLimiter(rate.Every(1), 1)
+		go webWorker(context.Background(), 1, webWorkerJobs)
 
-		cfg := web.FetchConfig{
-			C:           client,
-			Method:      "GET",
-			URL:         url,
-			RateLimiter: rateLimiter,
+		result := <-repoJobs
+		if result == nil {
+			t.Fatalf("Expected repoJob not to be nil")
 		}
 
-		req := flattenedRequest{
-			fetchConfig: &cfg,
-			table:       table,
-			clobColumn:  clobColumn,
+		if result.table != table {
+			t.Fatalf("Expected table to be %s, instead got: %s", table, result.table)
 		}
 
-		job := webJob{
-			&req,
-			repoJobs,
-			logger,
+		var data map[string]interface{}
+		if err := json.Unmarshal(result.b, &data); err != nil {
+			t.Fatalf("failed to unmarshal json data")
 		}
 
-		go webWorker(context.Background(), 1, webWorkerJobs)
-
-		webWorkerJobs <- &job
-
-		close(webWorkerJobs)
-
-		for i := 0; i < 1; i++ {
-			result := <-repoJobs
-
-			if result == nil {
-				t.Fatalf("Expected repoJob not to be nil")
-			}
-			if result.table != table {
-				t.Fatalf("Expected table to be %s, instead got: %s", table, result.table)
-			}
-			var data interface{}
-			if err := json.Unmarshal(result.b, &data); err != nil {
-				t.Fatalf("failed to unmarshal json data")
-			}
-			dataMap := data.(map[string]interface{})
-			if _, ok := dataMap[clobColumn]; !ok {
-				t.Fatalf("expected json data to have a key: %s, but got none", clobColumn)
-			}
+		if _, ok := data[clobColumn]; !ok {
+			t.Fatalf("expected json data to have a key: %s, but got none", clobColumn)
 		}
 	})
-
 }
 
 func TestNewFetchConfig(t *testing.T) {
@@ -504,7 +441,7 @@ func Test_flattenConfigRequests(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotReqs, err := flattenConfigRequests(tt.args.ctx, tt.args.cfg)
+			gotReqs, err := flattenConfigRequests(tt.args.ctx, tt.args.cfg, nil)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("flattenConfigRequests() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -535,3 +472,193 @@ func compareRequests(request1, request2 *flattenedRequest) bool {
 	}
 	return true
 }
+
+func TestCoalesceRequests(t *testing.T) {
+	t.Parallel()
+
+	newReq := func(rawURL, table string) *flattenedRequest {
+		uri, err := url.Parse(rawURL)
+		if err != nil {
+			t.Fatalf("error parsing url: %v", err)
+		}
+
+		return &flattenedRequest{
+			fetchConfig: &web.FetchConfig{Method: "GET", URL: uri},
+			table:       table,
+		}
+	}
+
+	reqs := []*flattenedRequest{
+		newReq("https://api.test.com/a?x=1", "t1"),
+		newReq("https://api.test.com/b", "t2"),
+		newReq("https://api.test.com/a?x=1", "t3"),
+		newReq("https://api.test.com/a?x=2", "t4"),
+		newReq("https://api.test.com/a?x=1", "t5"),
+	}
+
+	unique := coalesceRequests(reqs)
+	if len(unique) != 3 {
+		t.Fatalf("expected 3 unique requests, got %d", len(unique))
+	}
+
+	if unique[0].table != "t1" || len(unique[0].coalesced) != 2 {
+		t.Fatalf("expected t1 to coalesce 2 requests, got %d", len(unique[0].coalesced))
+	}
+
+	if unique[0].coalesced[0].table != "t3" || unique[0].coalesced[1].table != "t5" {
+		t.Fatalf("unexpected coalesced tables")
+	}
+}
+
+func TestCoalesceRequestsByFetch(t *testing.T) {
+	t.Parallel()
+
+	uri, err := url.Parse("https://api.test.com/a")
+	if err != nil {
+		t.Fatalf("error parsing url: %v", err)
+	}
+
+	newReq := func(table string, fetch web.FetchConfig) *flattenedRequest {
+		fetch.Method, fetch.URL = "GET", uri
+
+		return &flattenedRequest{fetchConfig: &fetch, table: table}
+	}
+
+	reqs := []*flattenedRequest{
+		newReq("t1", web.FetchConfig{}),
+		newReq("t2", web.FetchConfig{Proxy: "http://proxy-a.test.com:8080"}),
+		newReq("t3", web.FetchConfig{Proxy: "http://proxy-b.test.com:8080"}),
+		newReq("t4", web.FetchConfig{Timeout: time.Second}),
+		newReq("t5", web.FetchConfig{AcceptEncoding: "gzip"}),
+		newReq("t6", web.FetchConfig{Proxy: "http://proxy-a.test.com:8080"}),
+	}
+
+	unique := coalesceRequests(reqs)
+	if len(unique) != 5 {
+		t.Fatalf("expected 5 unique requests, got %d", len(unique))
+	}
+
+	if unique[1].table != "t2" || len(unique[1].coalesced) != 1 || unique[1].coalesced[0].table != "t6" {
+		t.Fatalf("expected only the requests through the same proxy to be coalesced, got %+v", unique[1].coalesced)
+	}
+
+	for idx, req := range unique {
+		if idx != 1 && len(req.coalesced) != 0 {
+			t.Fatalf("expected %s not to be coalesced, got %d requests", req.table, len(req.coalesced))
+		}
+	}
+}
+
+func TestFlattenRequestTimeseriesBody(t *testing.T) {
+	t.Parallel()
+
+	testURL, err := url.Parse("https://api.test.com")
+	if err != nil {
+		t.Fatalf("error parsing url: %v", err)
+	}
+
+	req := &config.Request{
+		Method:   "POST",
+		Endpoint: "/candles",
+		Body: map[string]interface{}{
+			"symbol": "BTC",
+			"range": map[string]interface{}{
+				"start": "2022-05-10T00:00:00Z",
+				"end":   "2022-05-10T10:00:00Z",
+			},
+		},
+		Timeseries: &config.Timeseries{
+			StartName: "$.range.start",
+			EndName:   "$.range.end",
+			Target:    config.TimeseriesTargetBody,
+			Period:    18000,
+		},
+	}
+
+	reqs, err := flattenRequestTimeseries(req, *testURL, &web.Client{}, nil)
+	if err != nil {
+		t.Fatalf("error flattening request: %v", err)
+	}
+
+	want := []string{
+		`{"range":{"end":"2022-05-10T05:00:00Z","start":"2022-05-10T00:00:00Z"},"symbol":"BTC"}`,
+		`{"range":{"end":"2022-05-10T10:00:00Z","start":"2022-05-10T05:00:00Z"},"symbol":"BTC"}`,
+	}
+
+	if len(reqs) != len(want) {
+		t.Fatalf("expected %d requests, got %d", len(want), len(reqs))
+	}
+
+	for idx, flatReq := range reqs {
+		if string(flatReq.fetchConfig.Body) != want[idx] {
+			t.Fatalf("unexpected body for chunk %d: %s", idx, flatReq.fetchConfig.Body)
+		}
+	}
+}
+
+func TestFlattenRequestBodyTemplate(t *testing.T) {
+	t.Parallel()
+
+	testURL, err := url.Parse("https://api.test.com")
+	if err != nil {
+		t.Fatalf("error parsing url: %v", err)
+	}
+
+	req := &config.Request{
+		Method:       "PUT",
+		Endpoint:     "/search",
+		BodyTemplate: `{"from":{{json .Start}},"to":{{json .End}},"symbol":{{json .Query.symbol}}}`,
+		Query:        map[string]string{"symbol": "BTC", "start": "2022-05-10T00:00:00Z", "end": "2022-05-10T10:00:00Z"},
+		Timeseries:   &config.Timeseries{StartName: "start", EndName: "end", Period: 18000},
+	}
+
+	reqs, err := flattenRequestTimeseries(req, *testURL, &web.Client{}, nil)
+	if err != nil {
+		t.Fatalf("error flattening request: %v", err)
+	}
+
+	want := []string{
+		`{"from":"2022-05-10T00:00:00Z","to":"2022-05-10T05:00:00Z","symbol":"BTC"}`,
+		`{"from":"2022-05-10T05:00:00Z","to":"2022-05-10T10:00:00Z","symbol":"BTC"}`,
+	}
+
+	if len(reqs) != len(want) {
+		t.Fatalf("expected %d requests, got %d", len(want), len(reqs))
+	}
+
+	for idx, flatReq := range reqs {
+		if string(flatReq.fetchConfig.Body) != want[idx] || flatReq.fetchConfig.Method != "PUT" {
+			t.Fatalf("unexpected %s body for chunk %d: %s", flatReq.fetchConfig.Method, idx, flatReq.fetchConfig.Body)
+		}
+	}
+}
+
+func TestChunkTimeseriesAligned(t *testing.T) {
+	t.Parallel()
+
+	timeseries := &config.Timeseries{
+		StartName: "start",
+		EndName:   "end",
+		Align:     config.TimeseriesAlignMonth,
+	}
+
+	if err := chunkTimeseriesRange(timeseries, "2022-01-15T12:00:00Z", "2022-03-02T00:00:00Z"); err != nil {
+		t.Fatalf("error setting chunks: %v", err)
+	}
+
+	expChunks := [][2]time.Time{
+		{time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2022, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2022, 2, 1, 0, 0, 0, 0, time.UTC), time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2022, 4, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	if len(timeseries.Chunks) != len(expChunks) {
+		t.Fatalf("expected %d chunks, got %d", len(expChunks), len(timeseries.Chunks))
+	}
+
+	for idx, chunk := range timeseries.Chunks {
+		if !chunk[0].Equal(expChunks[idx][0]) || !chunk[1].Equal(expChunks[idx][1]) {
+			t.Fatalf("unexpected chunk %d: %v", idx, chunk)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/internal/web/auth"
+	"github.com/alpstable/gidari/tools"
+	"github.com/sirupsen/logrus"
+)
+
+// The response headers that announce that an endpoint is deprecated, and when it is removed.
+const (
+	deprecationHeader = "Deprecation"
+	sunsetHeader      = "Sunset"
+)
+
+// versionTransport sends the pinned version of the web API with every request, and warns when a response is served
+// with another version or announces that its endpoint is deprecated. Each notice is only warned about once a run.
+type versionTransport struct {
+	base    http.RoundTripper
+	version *config.APIVersion
+	logger  *logrus.Logger
+
+	mu      sync.Mutex
+	noticed map[string]bool
+}
+
+func newVersionTransport(base http.RoundTripper, version *config.APIVersion, logger *logrus.Logger,
+) *versionTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return &versionTransport{
+		base:    base,
+		version: version,
+		logger:  logger,
+		noticed: make(map[string]bool),
+	}
+}
+
+// RoundTrip will send the request with the pinned version, and check the headers of its response.
+func (vt *versionTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if vt.version != nil {
+		req = req.Clone(req.Context())
+		req.Header.Set(vt.version.Header, vt.version.Version)
+	}
+
+	rsp, err := vt.base.RoundTrip(req)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // the error of the transport it wraps
+	}
+
+	for _, notice := range versionNotices(vt.version, req, rsp.Header) {
+		vt.warn(notice)
+	}
+
+	return rsp, nil
+}
+
+// Expiry returns when the credentials of the transport that it wraps expire.
+func (vt *versionTransport) Expiry() (time.Time, bool) {
+	if expirer, ok := vt.base.(auth.Expirer); ok {
+		return expirer.Expiry()
+	}
+
+	return time.Time{}, false
+}
+
+// warn will log a notice, unless it has already been logged.
+func (vt *versionTransport) warn(notice string) {
+	vt.mu.Lock()
+	defer vt.mu.Unlock()
+
+	if vt.noticed[notice] {
+		return
+	}
+
+	vt.noticed[notice] = true
+
+	logWarn := tools.LogFormatter{WorkerName: "web", Msg: notice}
+	vt.logger.Warn(logWarn.String())
+}
+
+// versionNotices returns what the headers of a response announce about the version of the web API: that it was
+// served with a version other than the pinned one, that its endpoint is deprecated, or when it is removed.
+func versionNotices(version *config.APIVersion, req *http.Request, header http.Header) []string {
+	var notices []string
+
+	if version != nil {
+		served := header.Get(version.ServedVersionHeader())
+		if served != "" && served != version.Version {
+			notices = append(notices, fmt.Sprintf("web API served version %q rather than the pinned %q",
+				served, version.Version))
+		}
+	}
+
+	endpoint := req.URL.Path
+
+	deprecation, sunset := header.Get(deprecationHeader), header.Get(sunsetHeader)
+
+	switch {
+	case deprecation != "" && sunset != "":
+		notices = append(notices, fmt.Sprintf("%s is deprecated (%s: %s) and will be removed at %s", endpoint,
+			deprecationHeader, deprecation, sunset))
+	case deprecation != "":
+		notices = append(notices, fmt.Sprintf("%s is deprecated (%s: %s)", endpoint, deprecationHeader,
+			deprecation))
+	case sunset != "":
+		notices = append(notices, fmt.Sprintf("%s will be removed at %s", endpoint, sunset))
+	}
+
+	return notices
+}
@@ -0,0 +1,110 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/sirupsen/logrus"
+)
+
+func TestVersionTransport(t *testing.T) {
+	t.Parallel()
+
+	var requested []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.Header.Get("X-Api-Version"))
+
+		w.Header().Set("X-Api-Version-Served", "2023-01-01")
+
+		if r.URL.Path == "/legacy" {
+			w.Header().Set("Deprecation", "true")
+			w.Header().Set("Sunset", "Wed, 11 Nov 2026 23:59:59 GMT")
+		}
+	}))
+	defer server.Close()
+
+	var out bytes.Buffer
+
+	logger := logrus.New()
+	logger.SetOutput(&out)
+
+	version := &config.APIVersion{Header: "X-Api-Version", Version: "2022-11-28", ServedHeader: "X-Api-Version-Served"}
+	client := &http.Client{Transport: newVersionTransport(nil, version, logger)}
+
+	for _, path := range []string{"/candles", "/legacy", "/legacy"} {
+		rsp, err := client.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("failed to get %s: %v", path, err)
+		}
+
+		rsp.Body.Close()
+	}
+
+	for _, got := range requested {
+		if got != "2022-11-28" {
+			t.Fatalf("expected the pinned version to be requested, got %q", got)
+		}
+	}
+
+	logs := out.String()
+	for _, want := range []string{
+		`served version \"2023-01-01\" rather than the pinned \"2022-11-28\"`,
+		"/legacy is deprecated (Deprecation: true) and will be removed at Wed, 11 Nov 2026 23:59:59 GMT",
+	} {
+		if strings.Count(logs, want) != 1 {
+			t.Fatalf("expected to warn once that %s, got %s", want, logs)
+		}
+	}
+}
+
+func TestVersionNotices(t *testing.T) {
+	t.Parallel()
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/trades", nil)
+	version := &config.APIVersion{Header: "X-Api-Version", Version: "2"}
+
+	for _, tcase := range []struct {
+		name    string
+		version *config.APIVersion
+		header  http.Header
+		want    []string
+	}{
+		{name: "no notices", version: version, header: http.Header{"X-Api-Version": {"2"}}},
+		{name: "not pinned", header: http.Header{"X-Api-Version": {"3"}}},
+		{
+			name:    "drift",
+			version: version,
+			header:  http.Header{"X-Api-Version": {"3"}},
+			want:    []string{`web API served version "3" rather than the pinned "2"`},
+		},
+		{
+			name:   "deprecation",
+			header: http.Header{"Deprecation": {"@1688169599"}},
+			want:   []string{"/v1/trades is deprecated (Deprecation: @1688169599)"},
+		},
+		{
+			name:   "sunset",
+			header: http.Header{"Sunset": {"Wed, 11 Nov 2026 23:59:59 GMT"}},
+			want:   []string{"/v1/trades will be removed at Wed, 11 Nov 2026 23:59:59 GMT"},
+		},
+	} {
+		got := versionNotices(tcase.version, req, tcase.header)
+		if strings.Join(got, "\n") != strings.Join(tcase.want, "\n") {
+			t.Fatalf("%s: expected %q, got %q", tcase.name, tcase.want, got)
+		}
+	}
+}
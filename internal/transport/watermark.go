@@ -0,0 +1,121 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/internal/state"
+	"github.com/alpstable/gidari/tools"
+)
+
+// timeseriesProgress tracks which chunks of a timeseries request have been fetched and handed off for storage, so
+// that the watermark can be advanced once the data has been committed.
+type timeseriesProgress struct {
+	key string
+
+	mu     sync.Mutex
+	chunks [][2]time.Time
+	done   []bool
+}
+
+func newTimeseriesProgress(key string, chunks [][2]time.Time) *timeseriesProgress {
+	return &timeseriesProgress{
+		key:    key,
+		chunks: chunks,
+		done:   make([]bool, len(chunks)),
+	}
+}
+
+// complete will mark the 1-indexed chunk "page" as fetched.
+func (progress *timeseriesProgress) complete(page int) {
+	if progress == nil || page < 1 || page > len(progress.done) {
+		return
+	}
+
+	progress.mu.Lock()
+	defer progress.mu.Unlock()
+
+	progress.done[page-1] = true
+}
+
+// watermark returns the end of the last chunk in the contiguous run of completed chunks from the start of the range.
+// Chunks complete out of order, so a gap means every chunk after it has to be fetched again on the next run.
+func (progress *timeseriesProgress) watermark() (time.Time, bool) {
+	if progress == nil {
+		return time.Time{}, false
+	}
+
+	progress.mu.Lock()
+	defer progress.mu.Unlock()
+
+	var (
+		mark time.Time
+		ok   bool
+	)
+
+	for idx, done := range progress.done {
+		if !done {
+			break
+		}
+
+		mark, ok = progress.chunks[idx][1], true
+	}
+
+	return mark, ok
+}
+
+// applyWatermarks will set the watermark of every timeseries request from the state store.
+func applyWatermarks(cfg *config.Config, store *state.Store) {
+	for _, req := range cfg.Requests {
+		// Truncated tables have to be backfilled from the start of the range.
+		if req.Timeseries == nil || (req.Truncate != nil && *req.Truncate) {
+			continue
+		}
+
+		mark, ok := store.Watermark(req.StateKey())
+		if !ok {
+			continue
+		}
+
+		req.Timeseries.Watermark = &mark.Time
+
+		logInfo := tools.LogFormatter{Msg: fmt.Sprintf("resuming %q from watermark %s", req.Table, mark.Time)}
+		cfg.Logger.Info(logInfo.String())
+	}
+}
+
+// advanceWatermarks will advance the watermark of every timeseries request in the state store, and save it.
+func advanceWatermarks(reqs []*flattenedRequest, store *state.Store) error {
+	if store == nil {
+		return nil
+	}
+
+	seen := make(map[*timeseriesProgress]bool)
+
+	for _, req := range reqs {
+		if req.progress == nil || seen[req.progress] {
+			continue
+		}
+
+		seen[req.progress] = true
+
+		if mark, ok := req.progress.watermark(); ok {
+			store.Advance(req.progress.key, mark)
+		}
+	}
+
+	if err := store.Save(); err != nil {
+		return fmt.Errorf("unable to save state: %w", err)
+	}
+
+	return nil
+}
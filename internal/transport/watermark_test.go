@@ -0,0 +1,126 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/sirupsen/logrus"
+)
+
+func TestTimeseriesProgress(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2022, 5, 10, 0, 0, 0, 0, time.UTC)
+	chunks := [][2]time.Time{
+		{start, start.Add(time.Hour)},
+		{start.Add(time.Hour), start.Add(2 * time.Hour)},
+		{start.Add(2 * time.Hour), start.Add(3 * time.Hour)},
+	}
+
+	progress := newTimeseriesProgress("candles", chunks)
+
+	if _, ok := progress.watermark(); ok {
+		t.Fatalf("expected no watermark before any chunks complete")
+	}
+
+	// A gap in the completed chunks holds the watermark back.
+	progress.complete(1)
+	progress.complete(3)
+
+	if mark, _ := progress.watermark(); !mark.Equal(chunks[0][1]) {
+		t.Fatalf("expected watermark %v, got %v", chunks[0][1], mark)
+	}
+
+	progress.complete(2)
+
+	if mark, _ := progress.watermark(); !mark.Equal(chunks[2][1]) {
+		t.Fatalf("expected watermark %v, got %v", chunks[2][1], mark)
+	}
+}
+
+func TestChunkTimeseriesWatermark(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name      string
+		watermark time.Time
+		chunks    int
+	}{
+		{name: "before start", watermark: time.Date(2022, 5, 9, 0, 0, 0, 0, time.UTC), chunks: 5},
+		{name: "within range", watermark: time.Date(2022, 5, 10, 15, 0, 0, 0, time.UTC), chunks: 2},
+		{name: "up to date", watermark: time.Date(2022, 5, 11, 0, 0, 0, 0, time.UTC), chunks: 0},
+	} {
+		tcase := tcase
+
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			timeseries := &config.Timeseries{Period: 18000, Watermark: &tcase.watermark}
+
+			err := chunkTimeseriesRange(timeseries, "2022-05-10T00:00:00Z", "2022-05-11T00:00:00Z")
+			if err != nil {
+				t.Fatalf("error setting chunks: %v", err)
+			}
+
+			if len(timeseries.Chunks) != tcase.chunks {
+				t.Fatalf("expected %d chunks, got %d", tcase.chunks, len(timeseries.Chunks))
+			}
+		})
+	}
+}
+
+func TestWarnPartialChunk(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name      string
+		period    config.TimeseriesPeriod
+		watermark bool
+		warn      bool
+	}{
+		{name: "whole periods", period: 21600},
+		{name: "partial chunk", period: 18000, warn: true},
+		{name: "watermark", period: 21600, watermark: true},
+	} {
+		tcase := tcase
+
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			timeseries := &config.Timeseries{Period: tcase.period}
+			if tcase.watermark {
+				mark := time.Date(2022, 5, 10, 15, 0, 0, 0, time.UTC)
+				timeseries.Watermark = &mark
+			}
+
+			err := chunkTimeseriesRange(timeseries, "2022-05-10T00:00:00Z", "2022-05-11T00:00:00Z")
+			if err != nil {
+				t.Fatalf("error setting chunks: %v", err)
+			}
+
+			var buf bytes.Buffer
+
+			logger := logrus.New()
+			logger.SetOutput(&buf)
+
+			req := &config.Request{Endpoint: "/candles", Timeseries: timeseries}
+			warnPartialChunk(&config.Config{Logger: logger}, req)
+
+			if warned := strings.Contains(buf.String(), "not a multiple of its period"); warned != tcase.warn {
+				t.Fatalf("expected a warning: %t, got %q", tcase.warn, buf.String())
+			}
+		})
+	}
+}
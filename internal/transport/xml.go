@@ -0,0 +1,175 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/alpstable/gidari/config"
+)
+
+// xmlKey returns the column of an element or attribute "name" within the element with column "prefix". The columns
+// of nested elements are joined with underscores, e.g. "author_name".
+func xmlKey(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+
+	return prefix + "_" + name
+}
+
+// matchesXMLPath returns true if the names of the open elements are the record path, where "*" matches any name.
+func matchesXMLPath(open, path []string) bool {
+	if len(open) != len(path) {
+		return false
+	}
+
+	for idx, name := range path {
+		if name != "*" && name != open[idx] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// flattenXML will read the element "start" up to its end into the columns of a record, keyed by "prefix". Attributes
+// are columns of their own, as is the text of elements without children, unless it is empty and the element has
+// attributes. Namespace declarations are skipped.
+func flattenXML(dec *xml.Decoder, start xml.StartElement, prefix string, fields map[string][]interface{}) error {
+	attrs := 0
+
+	for _, attr := range start.Attr {
+		if attr.Name.Space == "xmlns" || attr.Name.Local == "xmlns" {
+			continue
+		}
+
+		key := xmlKey(prefix, attr.Name.Local)
+		fields[key] = append(fields[key], attr.Value)
+		attrs++
+	}
+
+	var (
+		text     strings.Builder
+		children bool
+	)
+
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return fmt.Errorf("failed to decode response body: %w", err)
+		}
+
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			children = true
+
+			if err := flattenXML(dec, tok, xmlKey(prefix, tok.Name.Local), fields); err != nil {
+				return err
+			}
+		case xml.CharData:
+			text.Write(tok)
+		case xml.EndElement:
+			value := strings.TrimSpace(text.String())
+			if !children && prefix != "" && (value != "" || attrs == 0) {
+				fields[prefix] = append(fields[prefix], value)
+			}
+
+			return nil
+		}
+	}
+}
+
+// xmlRecord returns the JSON record of the columns of an element. Columns with several values, such as those of
+// repeated elements, are lists.
+func xmlRecord(fields map[string][]interface{}) (json.RawMessage, error) {
+	record := make(map[string]interface{}, len(fields))
+
+	for key, values := range fields {
+		if len(values) == 1 {
+			record[key] = values[0]
+		} else {
+			record[key] = values
+		}
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal XML record: %w", err)
+	}
+
+	return data, nil
+}
+
+// decodeXML will decode the elements at the record path of an XML body into flat records as they are read, calling
+// "batchFn" with batches of at most "size" records. Like an array, a body without records is a single empty batch.
+// It returns the number of records that were decoded.
+func decodeXML(reader io.Reader, format *config.XMLFormat, size int, batchFn streamBatchFn) (int, error) {
+	dec := xml.NewDecoder(reader)
+	path := format.Path()
+
+	var open []string
+
+	count := 0
+	batch := make([]json.RawMessage, 0, size)
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return count, fmt.Errorf("failed to decode response body: %w", err)
+		}
+
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			open = append(open, tok.Name.Local)
+			if !matchesXMLPath(open, path) {
+				continue
+			}
+
+			// The record is read up to its end element, which closes it.
+			fields := make(map[string][]interface{})
+			if err := flattenXML(dec, tok, "", fields); err != nil {
+				return count, err
+			}
+
+			open = open[:len(open)-1]
+
+			record, err := xmlRecord(fields)
+			if err != nil {
+				return count, err
+			}
+
+			batch = append(batch, record)
+			count++
+
+			if len(batch) == size {
+				if err := batchFn(batch); err != nil {
+					return count, err
+				}
+
+				batch = make([]json.RawMessage, 0, size)
+			}
+		case xml.EndElement:
+			open = open[:len(open)-1]
+		}
+	}
+
+	if len(batch) > 0 || count == 0 {
+		return count, batchFn(batch)
+	}
+
+	return count, nil
+}
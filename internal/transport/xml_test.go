@@ -0,0 +1,100 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package transport
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alpstable/gidari/config"
+)
+
+func TestDecodeXML(t *testing.T) {
+	t.Parallel()
+
+	rss := `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0">
+  <channel>
+    <title>Feed</title>
+    <item><title>One</title><guid isPermaLink="false">1</guid></item>
+    <item>
+      <title><![CDATA[Two, more]]></title>
+      <category>a</category>
+      <category>b</category>
+    </item>
+    <item id="3"><author><name>Ann</name></author></item>
+  </channel>
+</rss>`
+
+	items := []string{
+		`[{"guid":"1","guid_isPermaLink":"false","title":"One"},{"category":["a","b"],"title":"Two, more"}]`,
+		`[{"author_name":"Ann","id":"3"}]`,
+	}
+
+	atom := `<feed xmlns="http://www.w3.org/2005/Atom">
+  <entry><id>urn:1</id><link rel="alternate" href="https://example.com/1"/></entry>
+</feed>`
+
+	for _, tcase := range []struct {
+		name  string
+		path  string
+		body  string
+		want  []string
+		count int
+		err   bool
+	}{
+		{
+			name:  "rss",
+			path:  "rss/channel/item",
+			body:  rss,
+			want:  items,
+			count: 3,
+		},
+		{
+			name:  "atom",
+			path:  "/feed/entry/",
+			body:  atom,
+			want:  []string{`[{"id":"urn:1","link_href":"https://example.com/1","link_rel":"alternate"}]`},
+			count: 1,
+		},
+		{
+			name:  "wildcard",
+			path:  "*/*/item",
+			body:  rss,
+			want:  items,
+			count: 3,
+		},
+		{name: "no records", path: "rss/item", body: rss, want: []string{`[]`}},
+		{name: "truncated", path: "rss/channel/item", body: rss[:len(rss)-20], err: true},
+	} {
+		var got []string
+
+		count, err := decodeXML(strings.NewReader(tcase.body), &config.XMLFormat{RecordPath: tcase.path}, 2,
+			func(batch []json.RawMessage) error {
+				data, err := json.Marshal(batch)
+				got = append(got, string(data))
+
+				return err
+			})
+		if (err != nil) != tcase.err {
+			t.Fatalf("%s: expected error %t, got %v", tcase.name, tcase.err, err)
+		}
+
+		if tcase.err {
+			continue
+		}
+
+		if count != tcase.count || !reflect.DeepEqual(got, tcase.want) {
+			t.Fatalf("%s: expected %d records in %v, got %d in %v", tcase.name, tcase.count, tcase.want, count, got)
+		}
+	}
+}
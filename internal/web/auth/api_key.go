@@ -27,6 +27,8 @@ const (
 // requires each request to be signed (enhanced security measure). Your API keys should be assigned to access only
 // accounts and permission scopes that are necessary for your app to function.
 type APIKey struct {
+	baseTransport
+
 	key        string
 	passphrase string
 	secret     string
@@ -91,7 +93,7 @@ func (auth *APIKey) RoundTrip(req *http.Request) (*http.Response, error) {
 	req.Header.Add("cb-access-sign", sig)
 	req.Header.Add("cb-access-timestamp", timestamp)
 
-	rsp, err := http.DefaultTransport.RoundTrip(req)
+	rsp, err := auth.transport().RoundTrip(req)
 	if err != nil {
 		return nil, fmt.Errorf("error making request: %w", err)
 	}
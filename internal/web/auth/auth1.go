@@ -72,6 +72,8 @@ const (
 // Auth1 is an http.RoundTripper used to authenticate using the OAuth 1.a algorithm defined by twitter:
 // https://developer.twitter.com/en/docs/authentication/oauth-1-0a/creating-a-signature
 type Auth1 struct {
+	baseTransport
+
 	accessToken       string
 	accessTokenSecret string
 	consumerKey       string
@@ -98,7 +100,7 @@ func (auth *Auth1) RoundTrip(req *http.Request) (*http.Response, error) {
 		return nil, err
 	}
 
-	rsp, err := http.DefaultTransport.RoundTrip(req)
+	rsp, err := auth.transport().RoundTrip(req)
 	if err != nil {
 		return nil, fmt.Errorf("%v: %w", err, ErrRequestFailed)
 	}
@@ -11,12 +11,28 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"sync"
+	"time"
+
+	"github.com/alpstable/gidari/tools"
 )
 
-// Auth2 is an OAuth2 http transport.
+// Auth2 is an OAuth2 http transport. The bearer is either set directly or obtained from a token endpoint, in which
+// case it is refreshed before it expires rather than once requests start failing.
 type Auth2 struct {
-	bearer string
-	url    *url.URL
+	baseTransport
+
+	url *url.URL
+
+	mu       sync.Mutex
+	bearer   string
+	issuedAt time.Time
+	expiry   time.Time
+
+	source        *tokenSource
+	refreshBefore time.Duration
+	clock         tools.Clock
+	onRefresh     func(token Token, err error)
 }
 
 // NewAuth2 will return an OAuth2 http transport.
@@ -24,9 +40,14 @@ func NewAuth2() *Auth2 {
 	return new(Auth2)
 }
 
-// SetBearer will set the bearer field on Auth2.
+// SetBearer will set the bearer field on Auth2. The expiry of the bearer is read from its "exp" claim if it is a
+// JWT.
 func (auth *Auth2) SetBearer(val string) *Auth2 {
+	auth.mu.Lock()
+	defer auth.mu.Unlock()
+
 	auth.bearer = val
+	auth.expiry, _ = jwtExpiry(val)
 
 	return auth
 }
@@ -38,7 +59,135 @@ func (auth *Auth2) SetURL(u string) *Auth2 {
 	return auth
 }
 
-// RoundTrip authorizes the request with a signed OAuth1 Authorization header using the author and TokenSource.
+// SetTokenURL will set the token endpoint that bearers are obtained from. Bearers are obtained with the client
+// credentials grant, or with the refresh token grant if a refresh token is set.
+func (auth *Auth2) SetTokenURL(u string) *Auth2 {
+	auth.tokenSource().url = u
+
+	return auth
+}
+
+// SetClient will set the client credentials that are sent to the token endpoint.
+func (auth *Auth2) SetClient(id, secret string) *Auth2 {
+	source := auth.tokenSource()
+	source.clientID = id
+	source.clientSecret = secret
+
+	return auth
+}
+
+// SetRefreshToken will set the refresh token that is exchanged for bearers at the token endpoint.
+func (auth *Auth2) SetRefreshToken(val string) *Auth2 {
+	auth.tokenSource().refreshToken = val
+
+	return auth
+}
+
+// SetScopes will set the scopes that are requested from the token endpoint.
+func (auth *Auth2) SetScopes(scopes []string) *Auth2 {
+	auth.tokenSource().scopes = scopes
+
+	return auth
+}
+
+// SetRefreshBefore will set how long before its expiry a bearer is refreshed. Bearers that are issued for less
+// than twice this long are refreshed halfway through their lifetime.
+func (auth *Auth2) SetRefreshBefore(d time.Duration) *Auth2 {
+	auth.refreshBefore = d
+
+	return auth
+}
+
+// SetClock will set the clock that the expiry of a bearer is measured with. The default is "tools.RealClock".
+func (auth *Auth2) SetClock(clock tools.Clock) *Auth2 {
+	auth.clock = clock
+
+	return auth
+}
+
+// SetOnRefresh will set a function that is called every time a bearer is obtained from the token endpoint, or fails
+// to be.
+func (auth *Auth2) SetOnRefresh(fn func(token Token, err error)) *Auth2 {
+	auth.onRefresh = fn
+
+	return auth
+}
+
+// Expiry returns when the current bearer expires, and false if it is not known.
+func (auth *Auth2) Expiry() (time.Time, bool) {
+	auth.mu.Lock()
+	defer auth.mu.Unlock()
+
+	return auth.expiry, !auth.expiry.IsZero()
+}
+
+func (auth *Auth2) tokenSource() *tokenSource {
+	if auth.source == nil {
+		auth.source = new(tokenSource)
+	}
+
+	return auth.source
+}
+
+// due returns true if the bearer should be refreshed before it is used. The lock must be held.
+func (auth *Auth2) due(now time.Time) bool {
+	if auth.bearer == "" {
+		return true
+	}
+
+	if auth.expiry.IsZero() {
+		return false
+	}
+
+	margin := auth.refreshBefore
+	if lifetime := auth.expiry.Sub(auth.issuedAt); !auth.issuedAt.IsZero() && margin > lifetime/2 {
+		margin = lifetime / 2
+	}
+
+	return !now.Before(auth.expiry.Add(-margin))
+}
+
+// token returns the bearer to authorize a request with, refreshing it first if it is due. A bearer that could not
+// be refreshed is still used until it has expired. With "force", the bearer is refreshed unless it has changed
+// since "used" was sent.
+func (auth *Auth2) token(req *http.Request, force bool, used string) (string, error) {
+	auth.mu.Lock()
+	defer auth.mu.Unlock()
+
+	if auth.source == nil {
+		return auth.bearer, nil
+	}
+
+	now := tools.ClockOrReal(auth.clock).Now()
+
+	if force && auth.bearer != used {
+		return auth.bearer, nil
+	}
+
+	if !force && !auth.due(now) {
+		return auth.bearer, nil
+	}
+
+	token, err := auth.source.fetch(req.Context(), auth.transport(), now)
+	if auth.onRefresh != nil {
+		auth.onRefresh(token, err)
+	}
+
+	if err != nil {
+		if auth.bearer != "" && (auth.expiry.IsZero() || now.Before(auth.expiry)) {
+			return auth.bearer, nil
+		}
+
+		return "", err
+	}
+
+	auth.bearer, auth.issuedAt, auth.expiry = token.AccessToken, token.IssuedAt, token.Expiry
+
+	return auth.bearer, nil
+}
+
+// RoundTrip authorizes the request with an OAuth2 bearer. If the server rejects a bearer from the token endpoint
+// as unauthorized, the bearer is refreshed and the request is retried once.
 func (auth *Auth2) RoundTrip(req *http.Request) (*http.Response, error) {
 	if auth.url == nil {
 		return nil, ErrURLRequired
@@ -46,12 +195,42 @@ func (auth *Auth2) RoundTrip(req *http.Request) (*http.Response, error) {
 
 	req.URL.Scheme = auth.url.Scheme
 	req.URL.Host = auth.url.Host
-	req.Header.Set(authorizationHeaderParam, fmt.Sprintf("%s %s", bearerHeaderPrefix, auth.bearer))
 
-	rsp, err := http.DefaultTransport.RoundTrip(req)
+	rsp, bearer, err := auth.roundTrip(req, false, "")
+	if err != nil || rsp.StatusCode != http.StatusUnauthorized || auth.source == nil {
+		return rsp, err
+	}
+
+	if req.Body != nil && req.GetBody == nil {
+		return rsp, nil
+	}
+
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		if retry.Body, err = req.GetBody(); err != nil {
+			return rsp, nil //nolint:nilerr // the unauthorized response is returned as is
+		}
+	}
+
+	rsp.Body.Close()
+
+	rsp, _, err = auth.roundTrip(retry, true, bearer)
+
+	return rsp, err
+}
+
+func (auth *Auth2) roundTrip(req *http.Request, force bool, used string) (*http.Response, string, error) {
+	bearer, err := auth.token(req, force, used)
+	if err != nil {
+		return nil, "", err
+	}
+
+	req.Header.Set(authorizationHeaderParam, fmt.Sprintf("%s %s", bearerHeaderPrefix, bearer))
+
+	rsp, err := auth.transport().RoundTrip(req)
 	if err != nil {
-		return nil, fmt.Errorf("%w: %v", ErrRequestFailed, err)
+		return nil, "", fmt.Errorf("%w: %v", ErrRequestFailed, err)
 	}
 
-	return rsp, nil
+	return rsp, bearer, nil
 }
@@ -14,6 +14,8 @@ import (
 )
 
 type Basic struct {
+	baseTransport
+
 	email, password string
 	url             *url.URL
 }
@@ -54,7 +56,7 @@ func (auth *Basic) RoundTrip(req *http.Request) (*http.Response, error) {
 	req.URL.Host = auth.url.Host
 	req.SetBasicAuth(auth.email, auth.password)
 
-	rsp, err := http.DefaultTransport.RoundTrip(req)
+	rsp, err := auth.transport().RoundTrip(req)
 	if err != nil {
 		return nil, fmt.Errorf("%v: %w", ErrRequestFailed, err)
 	}
@@ -0,0 +1,188 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalidAuthenticator    = fmt.Errorf("invalid authenticator")
+	ErrUnknownAuthenticator    = fmt.Errorf("unknown authenticator")
+	ErrAuthenticatorRegistered = fmt.Errorf("authenticator already registered")
+)
+
+// Authenticator is a custom authentication scheme, e.g. the request signing of a proprietary web API, that is
+// registered with "RegisterAuthenticator".
+type Authenticator interface {
+	// Authenticate will sign or otherwise modify an outgoing request, e.g. set its headers. It is called again for
+	// a request that is retried.
+	Authenticate(req *http.Request) error
+
+	// Unauthorized is called with a "401 Unauthorized" response, e.g. to refresh the credentials of the scheme. The
+	// request is authenticated and sent again, once, if it returns true.
+	Unauthorized(rsp *http.Response) (bool, error)
+}
+
+// AuthenticatorFactory returns the authenticator of a scheme for the "settings" of a configuration.
+type AuthenticatorFactory func(settings map[string]string) (Authenticator, error)
+
+var (
+	authenticatorsMu sync.RWMutex
+	authenticators   = make(map[string]AuthenticatorFactory)
+)
+
+// RegisterAuthenticator will register the factory of a custom authentication scheme under "name". Schemes are
+// usually registered in an "init" function, and a name can only be registered once.
+func RegisterAuthenticator(name string, factory AuthenticatorFactory) error {
+	authenticatorsMu.Lock()
+	defer authenticatorsMu.Unlock()
+
+	if name == "" || factory == nil {
+		return fmt.Errorf("%w: a name and factory are required", ErrInvalidAuthenticator)
+	}
+
+	if _, ok := authenticators[name]; ok {
+		return fmt.Errorf("%w: %q", ErrAuthenticatorRegistered, name)
+	}
+
+	authenticators[name] = factory
+
+	return nil
+}
+
+// UnregisterAuthenticator will remove the authentication scheme registered under "name", if any, e.g. for a test to
+// clean up after itself.
+func UnregisterAuthenticator(name string) {
+	authenticatorsMu.Lock()
+	defer authenticatorsMu.Unlock()
+
+	delete(authenticators, name)
+}
+
+// Authenticators returns the names of the registered authentication schemes, sorted.
+func Authenticators() []string {
+	authenticatorsMu.RLock()
+	defer authenticatorsMu.RUnlock()
+
+	names := make([]string, 0, len(authenticators))
+	for name := range authenticators {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// NewAuthenticator returns the authenticator of the registered scheme "name" for the settings.
+func NewAuthenticator(name string, settings map[string]string) (Authenticator, error) {
+	authenticatorsMu.RLock()
+	factory, ok := authenticators[name]
+	authenticatorsMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownAuthenticator, name)
+	}
+
+	authenticator, err := factory(settings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create authenticator %q: %w", name, err)
+	}
+
+	return authenticator, nil
+}
+
+// Custom is transport for web APIs that authenticate requests with a custom "Authenticator".
+type Custom struct {
+	baseTransport
+
+	authenticator Authenticator
+	url           *url.URL
+}
+
+// NewCustom will return a Custom authentication transport.
+func NewCustom(authenticator Authenticator) *Custom {
+	return &Custom{authenticator: authenticator}
+}
+
+// SetURL will set the url field on Custom.
+func (auth *Custom) SetURL(val string) *Custom {
+	auth.url, _ = url.Parse(val)
+
+	return auth
+}
+
+// Expiry returns when the credentials of the authenticator expire, if it is an "Expirer".
+func (auth *Custom) Expiry() (time.Time, bool) {
+	if expirer, ok := auth.authenticator.(Expirer); ok {
+		return expirer.Expiry()
+	}
+
+	return time.Time{}, false
+}
+
+// RoundTrip authenticates the request with the authenticator. A request that is unauthorized is sent again once if
+// the authenticator handles the response, and its body can be sent again.
+func (auth *Custom) RoundTrip(req *http.Request) (*http.Response, error) {
+	if auth.url == nil {
+		return nil, ErrURLRequired
+	}
+
+	req.URL.Scheme = auth.url.Scheme
+	req.URL.Host = auth.url.Host
+
+	rsp, err := auth.send(req)
+	if err != nil || rsp.StatusCode != http.StatusUnauthorized {
+		return rsp, err
+	}
+
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return rsp, nil
+	}
+
+	retry, err := auth.authenticator.Unauthorized(rsp)
+	if err != nil {
+		rsp.Body.Close()
+
+		return nil, fmt.Errorf("%v: %w", ErrRequestFailed, err)
+	}
+
+	if !retry {
+		return rsp, nil
+	}
+
+	rsp.Body.Close()
+
+	if req.GetBody != nil {
+		if req.Body, err = req.GetBody(); err != nil {
+			return nil, fmt.Errorf("%v: %w", ErrRequestFailed, err)
+		}
+	}
+
+	return auth.send(req)
+}
+
+// send will authenticate the request and send it.
+func (auth *Custom) send(req *http.Request) (*http.Response, error) {
+	if err := auth.authenticator.Authenticate(req); err != nil {
+		return nil, fmt.Errorf("%v: %w", ErrRequestFailed, err)
+	}
+
+	rsp, err := auth.transport().RoundTrip(req)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", ErrRequestFailed, err)
+	}
+
+	return rsp, nil
+}
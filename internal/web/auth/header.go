@@ -0,0 +1,65 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// Header is transport for web APIs that authenticate requests with credentials in fixed headers, e.g. an
+// "X-MBX-APIKEY" header with the API key.
+type Header struct {
+	baseTransport
+
+	header http.Header
+	url    *url.URL
+}
+
+// NewHeader will return a Header authentication transport.
+func NewHeader() *Header {
+	return &Header{header: make(http.Header)}
+}
+
+// SetHeader will set a header that is sent with every request. Empty names are ignored.
+func (auth *Header) SetHeader(name, val string) *Header {
+	if name != "" {
+		auth.header.Set(name, val)
+	}
+
+	return auth
+}
+
+// SetURL will set the url field on Header.
+func (auth *Header) SetURL(val string) *Header {
+	auth.url, _ = url.Parse(val)
+
+	return auth
+}
+
+// RoundTrip authorizes the request with the headers of the transport.
+func (auth *Header) RoundTrip(req *http.Request) (*http.Response, error) {
+	if auth.url == nil {
+		return nil, ErrURLRequired
+	}
+
+	req.URL.Scheme = auth.url.Scheme
+	req.URL.Host = auth.url.Host
+
+	for name, vals := range auth.header {
+		req.Header[name] = vals
+	}
+
+	rsp, err := auth.transport().RoundTrip(req)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", ErrRequestFailed, err)
+	}
+
+	return rsp, nil
+}
@@ -10,6 +10,7 @@ package auth
 import (
 	"fmt"
 	"net/http"
+	"time"
 )
 
 var (
@@ -20,3 +21,36 @@ var (
 type Transport interface {
 	http.RoundTripper
 }
+
+// Based is a transport that sends its requests with a base transport once they are authenticated, e.g. the
+// transport of an embedder's own "http.Client" with its instrumentation or proxy.
+type Based interface {
+	// SetBase will set the transport that the requests are sent with.
+	SetBase(base http.RoundTripper)
+}
+
+// baseTransport is the base transport of an authentication transport, which is "http.DefaultTransport" unless it is
+// set.
+type baseTransport struct {
+	base http.RoundTripper
+}
+
+// SetBase will set the transport that the requests are sent with once they are authenticated.
+func (bt *baseTransport) SetBase(base http.RoundTripper) {
+	bt.base = base
+}
+
+// transport returns the base transport.
+func (bt *baseTransport) transport() http.RoundTripper {
+	if bt.base == nil {
+		return http.DefaultTransport
+	}
+
+	return bt.base
+}
+
+// Expirer is a transport whose credentials expire.
+type Expirer interface {
+	// Expiry returns when the current credentials expire, and false if it is not known.
+	Expiry() (time.Time, bool)
+}
@@ -0,0 +1,167 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrTokenRefresh is returned when a bearer cannot be obtained from the token endpoint.
+var ErrTokenRefresh = fmt.Errorf("failed to refresh OAuth2 token")
+
+// maxTokenResponseSize is the largest token endpoint response that is read.
+const maxTokenResponseSize = 1 << 20
+
+// Token is a bearer that was obtained from a token endpoint.
+type Token struct {
+	AccessToken string
+
+	// IssuedAt is when the token was obtained.
+	IssuedAt time.Time
+
+	// Expiry is when the token expires, or zero if the token endpoint did not say.
+	Expiry time.Time
+}
+
+// tokenSource obtains bearers from an OAuth2 token endpoint.
+type tokenSource struct {
+	url          string
+	clientID     string
+	clientSecret string
+	refreshToken string
+	scopes       []string
+}
+
+// tokenResponse is the successful response of a token endpoint, see RFC 6749 section 5.1.
+type tokenResponse struct {
+	AccessToken  string      `json:"access_token"`
+	TokenType    string      `json:"token_type"`
+	ExpiresIn    json.Number `json:"expires_in"`
+	RefreshToken string      `json:"refresh_token"`
+}
+
+// tokenError is the error response of a token endpoint, see RFC 6749 section 5.2.
+type tokenError struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// form returns the parameters of the token request.
+func (source *tokenSource) form() url.Values {
+	form := url.Values{}
+
+	if source.refreshToken != "" {
+		form.Set("grant_type", "refresh_token")
+		form.Set("refresh_token", source.refreshToken)
+	} else {
+		form.Set("grant_type", "client_credentials")
+	}
+
+	if len(source.scopes) > 0 {
+		form.Set("scope", strings.Join(source.scopes, " "))
+	}
+
+	return form
+}
+
+// fetch will obtain a new bearer from the token endpoint with the "base" transport. If the endpoint rotates the
+// refresh token, the new one is used for the next refresh.
+func (source *tokenSource) fetch(ctx context.Context, base http.RoundTripper, now time.Time) (Token, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, source.url, strings.NewReader(source.form().Encode()))
+	if err != nil {
+		return Token{}, fmt.Errorf("%w: %v", ErrTokenRefresh, err)
+	}
+
+	req.Header.Set(contentType, formContentType)
+	req.Header.Set("Accept", "application/json")
+
+	if source.clientID != "" {
+		req.SetBasicAuth(url.QueryEscape(source.clientID), url.QueryEscape(source.clientSecret))
+	}
+
+	rsp, err := base.RoundTrip(req)
+	if err != nil {
+		return Token{}, fmt.Errorf("%w: %v", ErrTokenRefresh, err)
+	}
+
+	defer rsp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(rsp.Body, maxTokenResponseSize))
+	if err != nil {
+		return Token{}, fmt.Errorf("%w: %v", ErrTokenRefresh, err)
+	}
+
+	if rsp.StatusCode != http.StatusOK {
+		var terr tokenError
+		if json.Unmarshal(body, &terr) == nil && terr.Error != "" {
+			return Token{}, fmt.Errorf("%w: %s: %s %s", ErrTokenRefresh, rsp.Status, terr.Error, terr.ErrorDescription)
+		}
+
+		return Token{}, fmt.Errorf("%w: %s", ErrTokenRefresh, rsp.Status)
+	}
+
+	var trsp tokenResponse
+	if err := json.Unmarshal(body, &trsp); err != nil {
+		return Token{}, fmt.Errorf("%w: invalid response: %v", ErrTokenRefresh, err)
+	}
+
+	if trsp.AccessToken == "" {
+		return Token{}, fmt.Errorf("%w: response has no access_token", ErrTokenRefresh)
+	}
+
+	if trsp.RefreshToken != "" {
+		source.refreshToken = trsp.RefreshToken
+	}
+
+	token := Token{AccessToken: trsp.AccessToken, IssuedAt: now}
+
+	if secs, err := trsp.ExpiresIn.Float64(); err == nil && secs > 0 {
+		token.Expiry = now.Add(time.Duration(secs * float64(time.Second)))
+	} else if expiry, ok := jwtExpiry(trsp.AccessToken); ok {
+		token.Expiry = expiry
+	}
+
+	return token, nil
+}
+
+// jwtExpiry returns the "exp" claim of a bearer, and false if the bearer is not a JWT with an expiry. The signature
+// is not verified, the claim is only used to know when to refresh the bearer.
+func jwtExpiry(bearer string) (time.Time, bool) {
+	parts := strings.Split(bearer, ".")
+	if len(parts) != 3 {
+		return time.Time{}, false
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	var claims struct {
+		Exp json.Number `json:"exp"`
+	}
+
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return time.Time{}, false
+	}
+
+	exp, err := claims.Exp.Int64()
+	if err != nil || exp <= 0 {
+		return time.Time{}, false
+	}
+
+	return time.Unix(exp, 0), true
+}
@@ -0,0 +1,264 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/alpstable/gidari/internal/web/auth"
+)
+
+// ErrNoInteraction is returned when a cassette that is replayed has no recorded response to a request.
+var ErrNoInteraction = errors.New("no recorded interaction")
+
+// CassetteRequest is a recorded HTTP request. Only what identifies the request is recorded, so the headers of the
+// request, e.g. its credentials, are not written to the cassette.
+type CassetteRequest struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	Body   string `json:"body,omitempty"`
+}
+
+// CassetteResponse is a recorded HTTP response. Bodies that are not valid UTF-8 are recorded as base64 in
+// "BodyBase64" instead of "Body".
+type CassetteResponse struct {
+	Status     int         `json:"status"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+	BodyBase64 []byte      `json:"bodyBase64,omitempty"`
+}
+
+// Interaction is an HTTP request of a cassette and the response that it received.
+type Interaction struct {
+	Request  CassetteRequest  `json:"request"`
+	Response CassetteResponse `json:"response"`
+}
+
+// Cassette is a recording of the HTTP interactions of a run, which can be replayed to run a configuration, or test
+// the transport, without the web API. A cassette either records the interactions of its transport, which are
+// written to its file by "Save", or replays the interactions that were loaded from its file.
+type Cassette struct {
+	path   string
+	record bool
+
+	mu           sync.Mutex
+	Interactions []*Interaction `json:"interactions"`
+
+	// replayed is the number of times that the interactions of each request have been replayed.
+	replayed map[string]int
+}
+
+// NewCassette returns a cassette that records the interactions of its transport, which "Save" writes to "path".
+func NewCassette(path string) *Cassette {
+	return &Cassette{path: path, record: true}
+}
+
+// LoadCassette loads the cassette at "path" to replay its interactions.
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cassette: %w", err)
+	}
+
+	cassette := &Cassette{path: path, replayed: make(map[string]int)}
+	if err := json.Unmarshal(data, cassette); err != nil {
+		return nil, fmt.Errorf("failed to decode cassette %s: %w", path, err)
+	}
+
+	return cassette, nil
+}
+
+// Transport returns the transport of a cassette. A cassette that records makes its requests with "base" and
+// records them, and one that replays answers its requests from the cassette without "base".
+func (cassette *Cassette) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return &cassetteTransport{cassette: cassette, base: base}
+}
+
+// Save will write the recorded interactions to the file of the cassette. A cassette that replays, or a nil cassette,
+// is not written.
+func (cassette *Cassette) Save() error {
+	if cassette == nil || !cassette.record {
+		return nil
+	}
+
+	cassette.mu.Lock()
+	defer cassette.mu.Unlock()
+
+	data, err := json.MarshalIndent(cassette, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode cassette: %w", err)
+	}
+
+	if dir := filepath.Dir(cassette.path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("failed to create cassette directory: %w", err)
+		}
+	}
+
+	if err := os.WriteFile(cassette.path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write cassette: %w", err)
+	}
+
+	return nil
+}
+
+// add will record an interaction.
+func (cassette *Cassette) add(interaction *Interaction) {
+	cassette.mu.Lock()
+	defer cassette.mu.Unlock()
+
+	cassette.Interactions = append(cassette.Interactions, interaction)
+}
+
+// find returns the recorded response to a request. The interactions of a request that was recorded more than once
+// are replayed in the order that they were recorded, and the last of them is replayed once they run out, e.g. for
+// retries.
+func (cassette *Cassette) find(req CassetteRequest) (*CassetteResponse, bool) {
+	cassette.mu.Lock()
+	defer cassette.mu.Unlock()
+
+	var matches []*Interaction
+
+	for _, interaction := range cassette.Interactions {
+		if interaction.Request == req {
+			matches = append(matches, interaction)
+		}
+	}
+
+	if len(matches) == 0 {
+		return nil, false
+	}
+
+	key := fmt.Sprintf("%s %s %s", req.Method, req.URL, req.Body)
+
+	idx := cassette.replayed[key]
+	if idx >= len(matches) {
+		idx = len(matches) - 1
+	}
+
+	cassette.replayed[key]++
+
+	return &matches[idx].Response, true
+}
+
+// cassetteTransport records or replays the interactions of a cassette.
+type cassetteTransport struct {
+	cassette *Cassette
+	base     http.RoundTripper
+}
+
+// Expiry returns when the credentials of the transport that it wraps expire.
+func (ct *cassetteTransport) Expiry() (time.Time, bool) {
+	if expirer, ok := ct.base.(auth.Expirer); ok {
+		return expirer.Expiry()
+	}
+
+	return time.Time{}, false
+}
+
+// newCassetteRequest returns the recording of a request, reading its body so that it can still be sent.
+func newCassetteRequest(req *http.Request) (CassetteRequest, error) {
+	recorded := CassetteRequest{Method: req.Method, URL: req.URL.String()}
+
+	if req.Body == nil || req.Body == http.NoBody {
+		return recorded, nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+
+	if err != nil {
+		return recorded, fmt.Errorf("failed to read request body: %w", err)
+	}
+
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	recorded.Body = string(body)
+
+	return recorded, nil
+}
+
+// RoundTrip will record the interaction of a request, or replay it.
+func (ct *cassetteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	recorded, err := newCassetteRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ct.cassette.record {
+		return ct.replay(req, recorded)
+	}
+
+	rsp, err := ct.base.RoundTrip(req)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // the error of the transport it wraps
+	}
+
+	body, err := io.ReadAll(rsp.Body)
+	rsp.Body.Close()
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	rsp.Body = io.NopCloser(bytes.NewReader(body))
+
+	response := CassetteResponse{Status: rsp.StatusCode, Header: rsp.Header.Clone()}
+	if utf8.Valid(body) {
+		response.Body = string(body)
+	} else {
+		response.BodyBase64 = body
+	}
+
+	ct.cassette.add(&Interaction{Request: recorded, Response: response})
+
+	return rsp, nil
+}
+
+// replay will answer a request with its recorded response.
+func (ct *cassetteTransport) replay(req *http.Request, recorded CassetteRequest) (*http.Response, error) {
+	response, ok := ct.cassette.find(recorded)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s %s in %s", ErrNoInteraction, recorded.Method, recorded.URL, ct.cassette.path)
+	}
+
+	body := []byte(response.Body)
+	if response.BodyBase64 != nil {
+		body = response.BodyBase64
+	}
+
+	header := response.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", response.Status, http.StatusText(response.Status)),
+		StatusCode:    response.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
@@ -0,0 +1,137 @@
+//go:build utest
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/time/rate"
+)
+
+func TestCassette(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "cassettes", "api.json")
+
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+
+		if r.URL.Path == "/binary" {
+			w.Write([]byte{0xff, 0x00, 0xfe})
+
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+
+		w.Header().Set("X-Hit", fmt.Sprint(hits))
+		fmt.Fprintf(w, `{"path":%q,"body":%q,"hit":%d}`, r.URL.Path, body, hits)
+	}))
+
+	fetch := func(client *Client, path string, body []byte) (*FetchResponse, error) {
+		uri, _ := url.Parse(server.URL + path)
+
+		return Fetch(ctx, &FetchConfig{
+			C:           client,
+			Method:      http.MethodPost,
+			URL:         uri,
+			RateLimiter: rate.NewLimiter(rate.Inf, 1),
+			Body:        body,
+		})
+	}
+
+	read := func(rsp *FetchResponse) string {
+		defer rsp.Body.Close()
+
+		body, _ := io.ReadAll(rsp.Body)
+
+		return string(body)
+	}
+
+	recording := NewCassette(path)
+	recorder := &Client{Client: http.Client{Transport: recording.Transport(nil)}}
+
+	var recorded []string
+
+	for _, req := range []struct {
+		path string
+		body string
+	}{
+		{path: "/candles", body: `{"page":1}`},
+		{path: "/candles", body: `{"page":2}`},
+		{path: "/candles", body: `{"page":1}`},
+		{path: "/binary"},
+	} {
+		rsp, err := fetch(recorder, req.path, []byte(req.body))
+		if err != nil {
+			t.Fatalf("failed to record %s: %v", req.path, err)
+		}
+
+		recorded = append(recorded, read(rsp))
+	}
+
+	if err := recording.Save(); err != nil {
+		t.Fatalf("failed to save cassette: %v", err)
+	}
+
+	// Replayed requests do not reach the web API.
+	server.Close()
+
+	replaying, err := LoadCassette(path)
+	if err != nil {
+		t.Fatalf("failed to load cassette: %v", err)
+	}
+
+	replayer := &Client{Client: http.Client{Transport: replaying.Transport(nil)}}
+
+	for idx, req := range []struct {
+		path string
+		body string
+		want string
+	}{
+		{path: "/candles", body: `{"page":1}`, want: recorded[0]},
+		{path: "/candles", body: `{"page":2}`, want: recorded[1]},
+		{path: "/candles", body: `{"page":1}`, want: recorded[2]},
+		{path: "/candles", body: `{"page":1}`, want: recorded[2]},
+		{path: "/binary", want: recorded[3]},
+	} {
+		rsp, err := fetch(replayer, req.path, []byte(req.body))
+		if err != nil {
+			t.Fatalf("%d: failed to replay %s: %v", idx, req.path, err)
+		}
+
+		if got := read(rsp); got != req.want {
+			t.Fatalf("%d: expected %q, got %q", idx, req.want, got)
+		}
+	}
+
+	if _, err := fetch(replayer, "/trades", nil); !errors.Is(err, ErrNoInteraction) {
+		t.Fatalf("expected error %v, got %v", ErrNoInteraction, err)
+	}
+
+	if err := replaying.Save(); err != nil {
+		t.Fatalf("expected a replayed cassette not to be saved, got %v", err)
+	}
+
+	var nilCassette *Cassette
+	if err := nilCassette.Save(); err != nil {
+		t.Fatalf("expected a nil cassette not to be saved, got %v", err)
+	}
+}
@@ -8,14 +8,17 @@
 package web
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"time"
 
 	"github.com/alpstable/gidari/internal/web/auth"
+	"github.com/alpstable/gidari/tools"
 	"golang.org/x/time/rate"
 )
 
@@ -51,33 +54,150 @@ func MissingFetchConfigFieldError(field string) error {
 	return fmt.Errorf("%w: %q", ErrMissingFetchConfigField, field)
 }
 
+// maxErrorBody is the most bytes of the body of an error response that are kept on its "ResponseError".
+const maxErrorBody = 64 << 10
+
+// ResponseError is returned when the server responds with an error status code. It wraps "ErrGettingResponse".
+type ResponseError struct {
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+
+	// Status is the HTTP status of the response, e.g. "404 Not Found".
+	Status string
+
+	// Header is the header of the response, e.g. with the rate limit of the web API.
+	Header http.Header
+
+	// Body is the start of the body of the response, up to "maxErrorBody" bytes, which usually describes the error.
+	Body []byte
+}
+
+func (rerr *ResponseError) Error() string {
+	return fmt.Sprintf("%v: %v", ErrGettingResponse, rerr.Status)
+}
+
+func (rerr *ResponseError) Unwrap() error {
+	return ErrGettingResponse
+}
+
 // GettingResponseError is returned when the response fails to get.
 func GettingResponseError(rsp *http.Response) error {
-	if _, err := io.ReadAll(rsp.Body); err != nil {
-		return fmt.Errorf("%w: %v", ErrGettingResponse, err)
+	rerr := &ResponseError{StatusCode: rsp.StatusCode, Status: rsp.Status, Header: rsp.Header}
+
+	body, err := io.ReadAll(io.LimitReader(rsp.Body, maxErrorBody))
+	if err != nil {
+		return fmt.Errorf("%w: %v", rerr, err)
+	}
+
+	rerr.Body = body
+
+	return rerr
+}
+
+// StatusCode returns the HTTP status code of a failed fetch, or zero if the request did not get a response.
+func StatusCode(err error) int {
+	var rerr *ResponseError
+	if errors.As(err, &rerr) {
+		return rerr.StatusCode
 	}
 
-	return fmt.Errorf("%w: %v", ErrGettingResponse, rsp.Status)
+	return 0
 }
 
 // Client is a wrapper around the http.Client that will handle authentication and rate limiting.
 type Client struct{ http.Client }
 
-// NewClient will return a new client with the given options.
-func NewClient(_ context.Context, roundtripper auth.Transport) (*Client, error) {
+// clientOptions are the options of a new client.
+type clientOptions struct {
+	client *http.Client
+	base   http.RoundTripper
+}
+
+// ClientOption is an option of "NewClient".
+type ClientOption func(*clientOptions)
+
+// WithHTTPClient will base the client on an embedder's own "http.Client", keeping its timeout, cookie jar and
+// redirect policy. Its transport sends the requests once they are authenticated, unless "WithRoundTripper" is set.
+func WithHTTPClient(client *http.Client) ClientOption {
+	return func(opts *clientOptions) {
+		opts.client = client
+	}
+}
+
+// WithRoundTripper will send the requests with "base" once they are authenticated, instead of
+// "http.DefaultTransport", e.g. for instrumentation, a proxy or a test double.
+func WithRoundTripper(base http.RoundTripper) ClientOption {
+	return func(opts *clientOptions) {
+		opts.base = base
+	}
+}
+
+// NewClient will return a new client with the given options. The auth transport authenticates the requests and
+// sends them with the round tripper of the options, or with it directly if there is no auth transport.
+func NewClient(_ context.Context, roundtripper auth.Transport, options ...ClientOption) (*Client, error) {
+	opts := new(clientOptions)
+	for _, option := range options {
+		option(opts)
+	}
+
 	c := new(Client)
+
+	base := opts.base
+	if opts.client != nil {
+		c.Client.Timeout = opts.client.Timeout
+		c.Client.Jar = opts.client.Jar
+		c.Client.CheckRedirect = opts.client.CheckRedirect
+
+		if base == nil {
+			base = opts.client.Transport
+		}
+	}
+
+	if roundtripper == nil {
+		c.Client.Transport = base
+
+		return c, nil
+	}
+
+	if based, ok := roundtripper.(auth.Based); ok && base != nil {
+		based.SetBase(base)
+	}
+
 	c.Client.Transport = roundtripper
 
 	return c, nil
 }
 
-// newHTTPRequest will return a new request.  If the options are set, this function will encode a body if possible.
-func newHTTPRequest(ctx context.Context, method string, uri fmt.Stringer) (*http.Request, error) {
-	req, err := http.NewRequestWithContext(ctx, method, uri.String(), nil)
+// CredentialExpiry returns when the credentials of the client's transport expire, and false if they do not expire
+// or it is not known.
+func (c *Client) CredentialExpiry() (time.Time, bool) {
+	if c == nil {
+		return time.Time{}, false
+	}
+
+	if expirer, ok := c.Transport.(auth.Expirer); ok {
+		return expirer.Expiry()
+	}
+
+	return time.Time{}, false
+}
+
+// newHTTPRequest will return a new request. If a body is set, it will be sent as JSON.
+func newHTTPRequest(ctx context.Context, method string, uri fmt.Stringer, body []byte) (*http.Request, error) {
+	var reader io.Reader
+	if len(body) > 0 {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, uri.String(), reader)
 	if err != nil {
 		return nil, CreateRequestError(err)
 	}
 
+	if reader != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
 	return req, nil
 }
 
@@ -106,6 +226,32 @@ type FetchConfig struct {
 	Method      string
 	URL         *url.URL
 	RateLimiter *rate.Limiter
+
+	// Body is the optional JSON body of the request.
+	Body []byte
+
+	// Header are the optional headers of the request, e.g. the "If-None-Match" of a conditional request.
+	Header http.Header
+
+	// Clock is used to wait on the rate limiter. The default is "tools.RealClock".
+	Clock tools.Clock
+
+	// Reservation is an optional reservation of the rate limiter that was made ahead of the request, which is
+	// waited on in place of the rate limiter.
+	Reservation *rate.Reservation
+
+	// Proxy is the optional URL of the proxy that the request is made through by the transports of "NewTransport",
+	// in place of their own proxy, or "ProxyDirect" to make it without a proxy.
+	Proxy string
+
+	// AcceptEncoding is the optional "Accept-Encoding" header of the request, e.g. "br, gzip", in place of the
+	// "gzip" that the transport advertises. A response is decoded from its content encoding, any of "Encodings",
+	// either way.
+	AcceptEncoding string
+
+	// Timeout is the optional time that the request may take once the rate limiter has let it through, until its
+	// response body has been read and closed.
+	Timeout time.Duration
 }
 
 func (cfg *FetchConfig) validate() error {
@@ -135,12 +281,23 @@ type FetchResponse struct {
 
 	// Body is the response body from the server.
 	Body io.ReadCloser
+
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+
+	// Header are the headers of the response.
+	Header http.Header
+
+	// RateLimitWait is how long the request waited on the rate limiter before it was made.
+	RateLimitWait time.Duration
 }
 
-func newFetchResponse(req *http.Request, body io.ReadCloser) *FetchResponse {
+func newFetchResponse(req *http.Request, rsp *http.Response) *FetchResponse {
 	return &FetchResponse{
-		Request: req,
-		Body:    body,
+		Request:    req,
+		Body:       rsp.Body,
+		StatusCode: rsp.StatusCode,
+		Header:     rsp.Header,
 	}
 }
 
@@ -150,30 +307,88 @@ func Fetch(ctx context.Context, cfg *FetchConfig) (*FetchResponse, error) {
 		return nil, fmt.Errorf("invalid config: %w", err)
 	}
 
-	// If the rate limiter is not set, set it with defaults.
-	if err := cfg.RateLimiter.Wait(ctx); err != nil {
+	clock := tools.ClockOrReal(cfg.Clock)
+	waitStart := clock.Now()
+
+	var err error
+	if cfg.Reservation != nil {
+		err = tools.WaitReservation(ctx, clock, cfg.Reservation)
+	} else {
+		err = tools.WaitRateLimit(ctx, clock, cfg.RateLimiter)
+	}
+
+	if err != nil {
 		return nil, fmt.Errorf("rate limiter error: %w", err)
 	}
 
-	req, err := newHTTPRequest(ctx, cfg.Method, cfg.URL)
+	rateLimitWait := clock.Now().Sub(waitStart)
+
+	// The timeout is canceled once the body of the response is closed, or if there is no response.
+	ctx, cancel := withTimeout(ctx, cfg.Timeout)
+
+	req, err := newHTTPRequest(withProxy(ctx, cfg.Proxy), cfg.Method, cfg.URL, cfg.Body)
 	if err != nil {
+		cancel()
+
 		return nil, fmt.Errorf("error creating request: %w", err)
 	}
 
+	if cfg.AcceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", cfg.AcceptEncoding)
+	}
+
+	for name, vals := range cfg.Header {
+		req.Header[name] = append([]string(nil), vals...)
+	}
+
 	if err != nil {
 		return nil, fmt.Errorf("rate limiter timeout: %w", err)
 	}
 
 	rsp, err := cfg.C.Client.Do(req)
 	if err != nil {
+		cancel()
+
 		return nil, fmt.Errorf("failed to make request: %w", err)
 	}
 
+	rsp.Body = &cancelBody{ReadCloser: rsp.Body, cancel: cancel}
+
+	if err := decompress(rsp); err != nil {
+		rsp.Body.Close()
+
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+
 	if err := validateResponse(rsp); err != nil {
 		rsp.Body.Close()
 
 		return nil, fmt.Errorf("error validating response: %w", err)
 	}
 
-	return newFetchResponse(req, rsp.Body), nil
+	fetchRsp := newFetchResponse(req, rsp)
+	fetchRsp.RateLimitWait = rateLimitWait
+
+	return fetchRsp, nil
+}
+
+// withTimeout returns the context of a request with the timeout of its fetch, if it has one.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}
+
+// cancelBody is a response body that cancels the context of its request once it is closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (body *cancelBody) Close() error {
+	defer body.cancel()
+
+	return body.ReadCloser.Close() //nolint:wrapcheck // the error of the body is returned as it is
 }
@@ -0,0 +1,147 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package web
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// ErrUnsupportedEncoding is returned when a response is compressed with a content encoding that cannot be decoded.
+var ErrUnsupportedEncoding = errors.New("unsupported content encoding")
+
+// Encodings are the content encodings that responses are decoded from, in the order they are advertised by default.
+var Encodings = []string{"gzip", "br", "zstd", "deflate"}
+
+// decoder is a decoded response body, which closes the decoders along with the body.
+type decoder struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (dec *decoder) Close() error {
+	var err error
+
+	for idx := len(dec.closers) - 1; idx >= 0; idx-- {
+		if closeErr := dec.closers[idx].Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+
+	return err
+}
+
+// closerFunc is a function that closes a decoder.
+type closerFunc func() error
+
+func (fn closerFunc) Close() error { return fn() }
+
+// decode returns a reader of the decoded "body" of a content encoding.
+func decode(encoding string, body io.Reader) (io.Reader, io.Closer, error) {
+	switch encoding {
+	case "gzip", "x-gzip":
+		reader, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to decode gzip response: %w", err)
+		}
+
+		return reader, reader, nil
+	case "deflate":
+		return decodeDeflate(body)
+	case "br":
+		return brotli.NewReader(body), nil, nil
+	case "zstd":
+		reader, err := zstd.NewReader(body)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to decode zstd response: %w", err)
+		}
+
+		return reader, closerFunc(func() error {
+			reader.Close()
+
+			return nil
+		}), nil
+	default:
+		return nil, nil, fmt.Errorf("%w: %q", ErrUnsupportedEncoding, encoding)
+	}
+}
+
+// decodeDeflate returns a reader of a "deflate" body, which is zlib data, or raw deflate data for the servers that
+// send it without the zlib header.
+func decodeDeflate(body io.Reader) (io.Reader, io.Closer, error) {
+	buffered := bufio.NewReader(body)
+
+	header, err := buffered.Peek(2)
+	if err == nil && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		reader, err := zlib.NewReader(buffered)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to decode deflate response: %w", err)
+		}
+
+		return reader, reader, nil
+	}
+
+	reader := flate.NewReader(buffered)
+
+	return reader, reader, nil
+}
+
+// decompress will decode the body of a compressed response, in the reverse of the order that its content encodings
+// were applied, and remove the headers that describe the encoded body. Responses that the transport has already
+// decompressed are left as they are.
+func decompress(rsp *http.Response) error {
+	var encodings []string
+
+	for _, value := range rsp.Header.Values("Content-Encoding") {
+		for _, encoding := range strings.Split(value, ",") {
+			encoding = strings.ToLower(strings.TrimSpace(encoding))
+			if encoding != "" && encoding != "identity" {
+				encodings = append(encodings, encoding)
+			}
+		}
+	}
+
+	if len(encodings) == 0 {
+		return nil
+	}
+
+	dec := &decoder{Reader: rsp.Body, closers: []io.Closer{rsp.Body}}
+
+	for idx := len(encodings) - 1; idx >= 0; idx-- {
+		reader, closer, err := decode(encodings[idx], dec.Reader)
+		if err != nil {
+			// The body is closed by the caller, along with the response.
+			_ = (&decoder{closers: dec.closers[1:]}).Close()
+
+			return err
+		}
+
+		dec.Reader = reader
+		if closer != nil {
+			dec.closers = append(dec.closers, closer)
+		}
+	}
+
+	rsp.Body = dec
+	rsp.Header.Del("Content-Encoding")
+	rsp.Header.Del("Content-Length")
+	rsp.ContentLength = -1
+	rsp.Uncompressed = true
+
+	return nil
+}
@@ -0,0 +1,165 @@
+//go:build utest
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package web
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"golang.org/x/time/rate"
+)
+
+// encodeBody will compress "data" with a content encoding, for the test server to respond with.
+func encodeBody(t *testing.T, encoding string, data []byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	var writer io.WriteCloser
+
+	switch encoding {
+	case "gzip":
+		writer = gzip.NewWriter(&buf)
+	case "deflate":
+		writer = zlib.NewWriter(&buf)
+	case "raw deflate":
+		writer, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	case "br":
+		writer = brotli.NewWriter(&buf)
+	case "zstd":
+		enc, err := zstd.NewWriter(&buf)
+		if err != nil {
+			t.Fatalf("failed to create zstd writer: %v", err)
+		}
+
+		writer = enc
+	default:
+		t.Fatalf("unknown encoding %q", encoding)
+	}
+
+	if _, err := writer.Write(data); err != nil {
+		t.Fatalf("failed to encode %s: %v", encoding, err)
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatalf("failed to close %s writer: %v", encoding, err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestFetchDecompress(t *testing.T) {
+	t.Parallel()
+
+	data := []byte(`[{"id":1,"price":"42.1"},{"id":2,"price":"42.3"}]`)
+
+	for _, tcase := range []struct {
+		name           string
+		encodings      []string
+		header         string
+		acceptEncoding string
+		wantAccept     string
+		err            error
+	}{
+		{name: "identity", wantAccept: "gzip"},
+		{name: "gzip", encodings: []string{"gzip"}, header: "gzip", wantAccept: "gzip"},
+		{
+			name:           "gzip requested",
+			encodings:      []string{"gzip"},
+			header:         "gzip",
+			acceptEncoding: "br, gzip",
+			wantAccept:     "br, gzip",
+		},
+		{name: "deflate", encodings: []string{"deflate"}, header: "deflate", acceptEncoding: "deflate"},
+		{name: "raw deflate", encodings: []string{"raw deflate"}, header: "deflate", acceptEncoding: "deflate"},
+		{name: "br", encodings: []string{"br"}, header: "br", acceptEncoding: "br", wantAccept: "br"},
+		{name: "zstd", encodings: []string{"zstd"}, header: "zstd", acceptEncoding: "zstd"},
+		{
+			name:           "layered",
+			encodings:      []string{"br", "gzip"},
+			header:         "br, gzip",
+			acceptEncoding: "br, gzip",
+		},
+		{
+			name:           "unsupported",
+			header:         "compress",
+			acceptEncoding: "compress",
+			err:            ErrUnsupportedEncoding,
+		},
+	} {
+		body := data
+		for _, encoding := range tcase.encodings {
+			body = encodeBody(t, encoding, body)
+		}
+
+		var accept string
+
+		server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+			accept = req.Header.Get("Accept-Encoding")
+
+			if tcase.header != "" {
+				writer.Header().Set("Content-Encoding", tcase.header)
+			}
+
+			_, _ = writer.Write(body)
+		}))
+
+		uri, _ := url.Parse(server.URL + "/trades")
+
+		client, err := NewClient(context.Background(), nil)
+		if err != nil {
+			t.Fatalf("%s: error creating client: %v", tcase.name, err)
+		}
+
+		rsp, err := Fetch(context.Background(), &FetchConfig{
+			C:              client,
+			Method:         http.MethodGet,
+			URL:            uri,
+			RateLimiter:    rate.NewLimiter(rate.Inf, 1),
+			AcceptEncoding: tcase.acceptEncoding,
+		})
+
+		server.Close()
+
+		if !errors.Is(err, tcase.err) {
+			t.Fatalf("%s: expected error %v, got %v", tcase.name, tcase.err, err)
+		}
+
+		if tcase.err != nil {
+			continue
+		}
+
+		got, err := io.ReadAll(rsp.Body)
+		rsp.Body.Close()
+
+		if err != nil {
+			t.Fatalf("%s: error reading body: %v", tcase.name, err)
+		}
+
+		if !bytes.Equal(got, data) {
+			t.Fatalf("%s: expected body %q, got %q", tcase.name, data, got)
+		}
+
+		if tcase.wantAccept != "" && accept != tcase.wantAccept {
+			t.Fatalf("%s: expected Accept-Encoding %q, got %q", tcase.name, tcase.wantAccept, accept)
+		}
+	}
+}
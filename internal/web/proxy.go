@@ -0,0 +1,96 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package web
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ProxyDirect is the proxy of a request that is made without a proxy, in place of the proxy of the client.
+const ProxyDirect = "direct"
+
+// proxyKey is the context key of the proxy of a request.
+type proxyKey struct{}
+
+// withProxy returns a context whose HTTP requests are made through "proxy" by the transports of "NewTransport", in
+// place of their own proxy.
+func withProxy(ctx context.Context, proxy string) context.Context {
+	if proxy == "" {
+		return ctx
+	}
+
+	return context.WithValue(ctx, proxyKey{}, proxy)
+}
+
+// proxyFunc returns the proxy function of the transports of "NewTransport".
+func proxyFunc(proxy *url.URL, noProxy []string) func(*http.Request) (*url.URL, error) {
+	return func(req *http.Request) (*url.URL, error) {
+		if override, ok := req.Context().Value(proxyKey{}).(string); ok {
+			if override == ProxyDirect {
+				return nil, nil
+			}
+
+			uri, err := url.Parse(override)
+			if err != nil {
+				return nil, fmt.Errorf("invalid proxy: %w", err)
+			}
+
+			return uri, nil
+		}
+
+		if bypassProxy(req.URL.Hostname(), noProxy) {
+			return nil, nil
+		}
+
+		if proxy != nil {
+			return proxy, nil
+		}
+
+		return http.ProxyFromEnvironment(req)
+	}
+}
+
+// bypassProxy returns true if a host is requested without a proxy, like "NO_PROXY": "*" bypasses every host, a domain
+// bypasses itself and its subdomains, ".<domain>" only its subdomains, and an IP address or CIDR block the addresses in
+// it.
+func bypassProxy(host string, noProxy []string) bool {
+	host = strings.ToLower(host)
+	ip := net.ParseIP(host)
+
+	for _, entry := range noProxy {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+
+		switch {
+		case entry == "":
+			continue
+		case entry == "*":
+			return true
+		case ip != nil:
+			if _, block, err := net.ParseCIDR(entry); err == nil && block.Contains(ip) {
+				return true
+			}
+
+			if other := net.ParseIP(entry); other != nil && other.Equal(ip) {
+				return true
+			}
+		case strings.HasPrefix(entry, "."):
+			if strings.HasSuffix(host, entry) {
+				return true
+			}
+		case host == entry || strings.HasSuffix(host, "."+entry):
+			return true
+		}
+	}
+
+	return false
+}
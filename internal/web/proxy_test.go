@@ -0,0 +1,111 @@
+//go:build utest
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package web
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"golang.org/x/time/rate"
+)
+
+func TestBypassProxy(t *testing.T) {
+	t.Parallel()
+
+	noProxy := []string{"internal.corp", ".svc", "10.0.0.0/8", "192.168.1.7"}
+
+	for _, tcase := range []struct {
+		host   string
+		bypass bool
+	}{
+		{host: "internal.corp", bypass: true},
+		{host: "API.Internal.corp", bypass: true},
+		{host: "notinternal.corp"},
+		{host: "svc"},
+		{host: "db.svc", bypass: true},
+		{host: "10.1.2.3", bypass: true},
+		{host: "11.1.2.3"},
+		{host: "192.168.1.7", bypass: true},
+		{host: "api.exchange.com"},
+	} {
+		if bypass := bypassProxy(tcase.host, noProxy); bypass != tcase.bypass {
+			t.Fatalf("%s: expected bypass %t, got %t", tcase.host, tcase.bypass, bypass)
+		}
+	}
+
+	if !bypassProxy("api.exchange.com", []string{"*"}) {
+		t.Fatalf("expected * to bypass every host")
+	}
+}
+
+func TestProxyTransport(t *testing.T) {
+	t.Parallel()
+
+	// The proxy answers every request itself with the URL that it was asked for, and the target with "direct".
+	proxy := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(writer, "proxied %s", req.URL)
+	}))
+	defer proxy.Close()
+
+	target := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(writer, "direct")
+	}))
+	defer target.Close()
+
+	proxyURL, _ := url.Parse(proxy.URL)
+	targetURL, _ := url.Parse(target.URL + "/trades")
+
+	for _, tcase := range []struct {
+		name     string
+		proxy    *url.URL
+		noProxy  []string
+		override string
+		body     string
+	}{
+		{name: "proxy", proxy: proxyURL, body: "proxied " + targetURL.String()},
+		{name: "no proxy", proxy: proxyURL, noProxy: []string{targetURL.Hostname()}, body: "direct"},
+		{name: "direct", proxy: proxyURL, override: ProxyDirect, body: "direct"},
+		{name: "override", override: proxy.URL, body: "proxied " + targetURL.String()},
+	} {
+		transport := NewTransport(TransportConfig{Proxy: tcase.proxy, NoProxy: tcase.noProxy})
+
+		client, err := NewClient(context.Background(), nil, WithRoundTripper(transport))
+		if err != nil {
+			t.Fatalf("%s: error creating client: %v", tcase.name, err)
+		}
+
+		rsp, err := Fetch(context.Background(), &FetchConfig{
+			C:           client,
+			Method:      http.MethodGet,
+			URL:         targetURL,
+			RateLimiter: rate.NewLimiter(rate.Inf, 1),
+			Proxy:       tcase.override,
+		})
+		if err != nil {
+			t.Fatalf("%s: fetch error: %v", tcase.name, err)
+		}
+
+		body, err := io.ReadAll(rsp.Body)
+		rsp.Body.Close()
+
+		if err != nil {
+			t.Fatalf("%s: error reading body: %v", tcase.name, err)
+		}
+
+		if string(body) != tcase.body {
+			t.Fatalf("%s: expected body %q, got %q", tcase.name, tcase.body, body)
+		}
+	}
+}
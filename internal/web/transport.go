@@ -0,0 +1,40 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package web
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/url"
+)
+
+// TransportConfig are the settings of the transport of "NewTransport".
+type TransportConfig struct {
+	// Proxy is the "http", "https" or "socks5" URL that requests are made through, except to the hosts of
+	// "NoProxy". The requests are made through the proxies of the "HTTP_PROXY", "HTTPS_PROXY" and "NO_PROXY"
+	// environment variables if it is nil.
+	Proxy   *url.URL
+	NoProxy []string
+
+	// TLS is the TLS configuration of the connections, e.g. with the certificates of mutual TLS. The default is
+	// that of "http.DefaultTransport".
+	TLS *tls.Config
+}
+
+// NewTransport returns a copy of "http.DefaultTransport" with the proxy and TLS configuration of "cfg". A request can
+// be made through another proxy with the "Proxy" of its "FetchConfig".
+func NewTransport(cfg TransportConfig) http.RoundTripper {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxyFunc(cfg.Proxy, cfg.NoProxy)
+
+	if cfg.TLS != nil {
+		transport.TLSClientConfig = cfg.TLS
+	}
+
+	return transport
+}
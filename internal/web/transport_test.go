@@ -0,0 +1,122 @@
+//go:build utest
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package web
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// newClientCertificate returns a self-signed client certificate of "name".
+func newClientCertificate(t *testing.T, name string) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestNewTransportTLS(t *testing.T) {
+	t.Parallel()
+
+	// The server requires a client certificate, and answers with the common name of the one it was sent.
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		writer.Header().Set("X-Client", req.TLS.PeerCertificates[0].Subject.CommonName)
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert, MinVersion: tls.VersionTLS12}
+	server.StartTLS()
+
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+
+	uri, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("error parsing url: %v", err)
+	}
+
+	cert := newClientCertificate(t, "gidari")
+
+	for _, tcase := range []struct {
+		name   string
+		tls    *tls.Config
+		client string
+	}{
+		{name: "untrusted"},
+		{name: "no client certificate", tls: &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}},
+		{
+			name:   "mutual",
+			tls:    &tls.Config{RootCAs: roots, Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12},
+			client: "gidari",
+		},
+	} {
+		client, err := NewClient(context.Background(), nil, WithRoundTripper(NewTransport(TransportConfig{
+			TLS: tcase.tls,
+		})))
+		if err != nil {
+			t.Fatalf("%s: error creating client: %v", tcase.name, err)
+		}
+
+		rsp, err := Fetch(context.Background(), &FetchConfig{
+			C:           client,
+			Method:      http.MethodGet,
+			URL:         uri,
+			RateLimiter: rate.NewLimiter(rate.Inf, 1),
+		})
+		if tcase.client == "" {
+			if err == nil {
+				rsp.Body.Close()
+				t.Fatalf("%s: expected the handshake to fail", tcase.name)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("%s: fetch error: %v", tcase.name, err)
+		}
+
+		rsp.Body.Close()
+
+		if got := rsp.Header.Get("X-Client"); got != tcase.client {
+			t.Fatalf("%s: expected client %q, got %q", tcase.name, tcase.client, got)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package workspace
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// runPattern is the pattern for the name of a run's workspace directory.
+	runPattern = "gidari-run-*"
+
+	// retainMarker is written to a workspace that has been retained on purpose, so that it is not swept by later
+	// runs.
+	retainMarker = ".retain"
+
+	// staleAge is the age after which a workspace that was not retained on purpose, e.g. because the process
+	// exited without cleaning up, is swept by a later run.
+	staleAge = 24 * time.Hour
+)
+
+// Policy determines whether a workspace is kept once the run is over.
+type Policy uint8
+
+const (
+	// RetainNever will always remove the workspace.
+	RetainNever Policy = iota
+
+	// RetainOnFailure will keep the workspace if the run failed.
+	RetainOnFailure
+
+	// RetainAlways will always keep the workspace.
+	RetainAlways
+)
+
+// Workspace is a directory owned by a single run that holds its temporary files, such as spilled response bodies.
+// Subsystems create their files through the workspace rather than in the system temporary directory so that
+// everything a run writes is removed together. A nil "Workspace" creates files in the system temporary directory.
+type Workspace struct {
+	dir    string
+	policy Policy
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// contextKey is the context key of the workspace of a run.
+type contextKey struct{}
+
+// NewContext returns a context that carries the workspace, for the subsystems that are constructed from a context
+// rather than handed the workspace, such as storage.
+func NewContext(ctx context.Context, ws *Workspace) context.Context {
+	return context.WithValue(ctx, contextKey{}, ws)
+}
+
+// FromContext returns the workspace that a context carries, or nil if it carries none.
+func FromContext(ctx context.Context) *Workspace {
+	ws, _ := ctx.Value(contextKey{}).(*Workspace)
+
+	return ws
+}
+
+// New will create a workspace directory for a run inside of "root". If "root" is empty, the system temporary
+// directory is used. Workspaces inside of "root" that were left behind by runs that did not clean up are removed.
+func New(root string, policy Policy) (*Workspace, error) {
+	if root == "" {
+		root = os.TempDir()
+	}
+
+	if err := os.MkdirAll(root, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create workspace root: %w", err)
+	}
+
+	sweep(root)
+
+	dir, err := os.MkdirTemp(root, runPattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create workspace: %w", err)
+	}
+
+	return &Workspace{dir: dir, policy: policy}, nil
+}
+
+// Dir returns the path of the workspace directory.
+func (ws *Workspace) Dir() string {
+	if ws == nil {
+		return os.TempDir()
+	}
+
+	return ws.dir
+}
+
+// CreateTemp will create a new temporary file in the "sub" directory of the workspace, see "os.CreateTemp" for the
+// semantics of "pattern".
+func (ws *Workspace) CreateTemp(sub, pattern string) (*os.File, error) {
+	if ws == nil {
+		return os.CreateTemp("", pattern)
+	}
+
+	dir := filepath.Join(ws.dir, sub)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create workspace directory: %w", err)
+	}
+
+	return os.CreateTemp(dir, pattern)
+}
+
+// Close will remove the workspace according to its retention policy, where "failed" indicates whether the run
+// failed. Calling "Close" more than once is a no-op.
+func (ws *Workspace) Close(failed bool) error {
+	if ws == nil {
+		return nil
+	}
+
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	if ws.closed {
+		return nil
+	}
+
+	ws.closed = true
+
+	if ws.policy == RetainAlways || (ws.policy == RetainOnFailure && failed) {
+		if err := os.WriteFile(filepath.Join(ws.dir, retainMarker), nil, 0o600); err != nil {
+			return fmt.Errorf("failed to mark workspace as retained: %w", err)
+		}
+
+		return nil
+	}
+
+	if err := os.RemoveAll(ws.dir); err != nil {
+		return fmt.Errorf("failed to remove workspace: %w", err)
+	}
+
+	return nil
+}
+
+// Retained returns true if the workspace was kept when it was closed.
+func (ws *Workspace) Retained() bool {
+	if ws == nil {
+		return false
+	}
+
+	_, err := os.Stat(filepath.Join(ws.dir, retainMarker))
+
+	return err == nil
+}
+
+// sweep will remove stale workspaces from "root" on a best-effort basis.
+func sweep(root string) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return
+	}
+
+	prefix := strings.TrimSuffix(runPattern, "*")
+
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), prefix) {
+			continue
+		}
+
+		dir := filepath.Join(root, entry.Name())
+
+		if _, err := os.Stat(filepath.Join(dir, retainMarker)); err == nil {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil || time.Since(info.ModTime()) < staleAge {
+			continue
+		}
+
+		os.RemoveAll(dir)
+	}
+}
@@ -0,0 +1,123 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package workspace
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWorkspace(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name   string
+		policy Policy
+		failed bool
+		retain bool
+	}{
+		{name: "never", policy: RetainNever, failed: true},
+		{name: "on failure success", policy: RetainOnFailure},
+		{name: "on failure failed", policy: RetainOnFailure, failed: true, retain: true},
+		{name: "always", policy: RetainAlways, retain: true},
+	} {
+		tcase := tcase
+
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			ws, err := New(t.TempDir(), tcase.policy)
+			if err != nil {
+				t.Fatalf("failed to create workspace: %v", err)
+			}
+
+			file, err := ws.CreateTemp("spill", "test-*.json")
+			if err != nil {
+				t.Fatalf("failed to create temp file: %v", err)
+			}
+
+			file.Close()
+
+			if filepath.Dir(filepath.Dir(file.Name())) != ws.Dir() {
+				t.Fatalf("expected %q to be in the workspace %q", file.Name(), ws.Dir())
+			}
+
+			if err := ws.Close(tcase.failed); err != nil {
+				t.Fatalf("failed to close workspace: %v", err)
+			}
+
+			_, err = os.Stat(file.Name())
+			if exists := err == nil; exists != tcase.retain {
+				t.Fatalf("expected file to exist: %v, got %v", tcase.retain, exists)
+			}
+
+			if ws.Retained() != tcase.retain {
+				t.Fatalf("expected retained to be %v", tcase.retain)
+			}
+		})
+	}
+}
+
+func TestSweep(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	stale := filepath.Join(root, "gidari-run-stale")
+	retained := filepath.Join(root, "gidari-run-retained")
+	fresh := filepath.Join(root, "gidari-run-fresh")
+	other := filepath.Join(root, "other")
+
+	for _, dir := range []string{stale, retained, fresh, other} {
+		if err := os.Mkdir(dir, 0o700); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(retained, retainMarker), nil, 0o600); err != nil {
+		t.Fatalf("failed to write marker: %v", err)
+	}
+
+	old := time.Now().Add(-2 * staleAge)
+	for _, dir := range []string{stale, retained, other} {
+		if err := os.Chtimes(dir, old, old); err != nil {
+			t.Fatalf("failed to change times: %v", err)
+		}
+	}
+
+	sweep(root)
+
+	for dir, want := range map[string]bool{stale: false, retained: true, fresh: true, other: true} {
+		if _, err := os.Stat(dir); (err == nil) != want {
+			t.Fatalf("expected %q to exist: %v", dir, want)
+		}
+	}
+}
+
+func TestContext(t *testing.T) {
+	t.Parallel()
+
+	ws, err := New(t.TempDir(), RetainNever)
+	if err != nil {
+		t.Fatalf("failed to create workspace: %v", err)
+	}
+
+	defer ws.Close(false)
+
+	if got := FromContext(NewContext(context.Background(), ws)); got != ws {
+		t.Fatalf("expected the workspace of the context to be %v, got %v", ws, got)
+	}
+
+	if got := FromContext(context.Background()); got != nil {
+		t.Fatalf("expected a context without a workspace to have none, got %v", got)
+	}
+}
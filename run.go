@@ -0,0 +1,159 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package gidari
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/alpstable/gidari/config"
+	"github.com/alpstable/gidari/internal/events"
+	"github.com/alpstable/gidari/tools"
+)
+
+const (
+	// RunCompleted, RunInterrupted and RunFailed are the statuses of a "RunResult". Runs that are interrupted, by
+	// the deadline of their context, by one of the configured "limits" or by their "maxDuration" or "deadline", have
+	// committed the data that they fetched and can be resumed.
+	RunCompleted   = "completed"
+	RunInterrupted = "interrupted"
+	RunFailed      = "failed"
+)
+
+// Event is a progress event of "Run", e.g. a chunk that was committed. Its "Type" is one of "chunk_started",
+// "chunk_committed", "error" or "run_summary", the events written by "--events ndjson".
+type Event = events.Event
+
+// Progress is a heartbeat of "Run": the number of chunks and pages, and of rows, that have been committed so far, and
+// how long the run has taken.
+type Progress struct {
+	Requests int
+	Rows     int64
+	Elapsed  time.Duration
+}
+
+// RunResult is the outcome of "Run".
+type RunResult struct {
+	// Status is whether the run "completed", was "interrupted" or "failed".
+	Status string
+
+	// Requests and Rows are the number of chunks and pages, and of rows, that were committed.
+	Requests int
+	Rows     int64
+
+	Duration time.Duration
+}
+
+// RunOption is an option of "Run".
+type RunOption func(*runOptions)
+
+type runOptions struct {
+	progress func(Event)
+
+	heartbeat         func(Progress)
+	heartbeatInterval time.Duration
+}
+
+// WithProgress will pass every progress event of the run to "fn" as it happens. Events are passed one at a time, so
+// "fn" should return quickly.
+func WithProgress(fn func(Event)) RunOption {
+	return func(opts *runOptions) { opts.progress = fn }
+}
+
+// WithHeartbeat will pass the progress of the run to "fn" every "interval" until the run is over, e.g. to keep
+// the task of an orchestrator alive or to report its progress.
+func WithHeartbeat(interval time.Duration, fn func(Progress)) RunOption {
+	return func(opts *runOptions) {
+		opts.heartbeat = fn
+		opts.heartbeatInterval = interval
+	}
+}
+
+// Run will run the transport like "Transport", for the tasks of orchestrators and other code that embeds it. The run
+// is interrupted when the context is canceled or its deadline passes, committing the data that it fetched, and it
+// never exits the process or installs signal handlers. A worker that cannot continue, e.g. a request that fails
+// every retry without a "deadLetter" file, fails the run with its error instead.
+//
+// The progress events of the run are passed to "WithProgress", as well as to "Config.Events" if it is set.
+func Run(ctx context.Context, cfg *config.Config, opts ...RunOption) (RunResult, error) {
+	var options runOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	status := RunFailed
+	forward := cfg.Events
+
+	stream := events.NewFunc(func(event Event) {
+		if event.Type == events.RunSummary {
+			status = event.Status
+		}
+
+		forward.Emit(event)
+
+		if options.progress != nil {
+			options.progress(event)
+		}
+	}, cfg.Clock)
+
+	cfg.Events = stream
+	defer func() { cfg.Events = forward }()
+
+	stop := heartbeat(stream, cfg.Clock, options)
+	err := Transport(ctx, cfg)
+
+	stop()
+
+	requests, rows, elapsed := stream.Progress()
+	result := RunResult{Status: status, Requests: requests, Rows: rows, Duration: elapsed}
+
+	if errors.Is(err, ErrInterrupted) && ctx.Err() != nil {
+		err = fmt.Errorf("%w: %v", err, ctx.Err())
+	}
+
+	return result, err
+}
+
+// heartbeat will pass the progress of the stream to the heartbeat of the options every interval, until the returned
+// function is called.
+func heartbeat(stream *events.Stream, clock tools.Clock, opts runOptions) func() {
+	if opts.heartbeat == nil || opts.heartbeatInterval <= 0 {
+		return func() {}
+	}
+
+	clock = tools.ClockOrReal(clock)
+
+	done := make(chan struct{})
+
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-clock.After(opts.heartbeatInterval):
+			}
+
+			requests, rows, elapsed := stream.Progress()
+			opts.heartbeat(Progress{Requests: requests, Rows: rows, Elapsed: elapsed})
+		}
+	}()
+
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
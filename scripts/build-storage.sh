@@ -36,3 +36,8 @@ docker-compose -f "docker-compose.yml" up -d \
 	--remove-orphans \
 	--force-recreate \
 	--build postgres1
+
+docker-compose -f "docker-compose.yml" up -d \
+	--remove-orphans \
+	--force-recreate \
+	--build mysql1
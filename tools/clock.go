@@ -0,0 +1,169 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package tools
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// ErrRateLimitExceeded is returned when a rate limiter can never allow an event, e.g. because the burst is zero.
+var ErrRateLimitExceeded = fmt.Errorf("rate limit exceeded")
+
+// Clock is a source of time. Code that needs the current time or needs to wait should use a "Clock" rather than the
+// "time" package directly, so that tests can simulate the passage of time.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// After waits for the duration to elapse and then sends the current time on the returned channel.
+	After(d time.Duration) <-chan time.Time
+}
+
+// RealClock is the "Clock" backed by the "time" package.
+var RealClock Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// ClockOrReal returns "clock", or the "RealClock" if "clock" is nil.
+func ClockOrReal(clock Clock) Clock {
+	if clock == nil {
+		return RealClock
+	}
+
+	return clock
+}
+
+// Sleep will wait for the duration to elapse on the clock, or until the context is done.
+func Sleep(ctx context.Context, clock Clock, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("sleep interrupted: %w", ctx.Err())
+	case <-ClockOrReal(clock).After(d):
+		return nil
+	}
+}
+
+// WaitRateLimit will block until the limiter allows an event, measuring time with the clock rather than with the
+// "time" package. This is the clock-aware equivalent of "(*rate.Limiter).Wait".
+func WaitRateLimit(ctx context.Context, clock Clock, limiter *rate.Limiter) error {
+	clock = ClockOrReal(clock)
+	now := clock.Now()
+
+	reservation := limiter.ReserveN(now, 1)
+	if !reservation.OK() {
+		return ErrRateLimitExceeded
+	}
+
+	return WaitReservation(ctx, clock, reservation)
+}
+
+// WaitReservation will wait until a reservation of a rate limiter, e.g. one that was made ahead of a request, can be
+// acted on. The reservation is canceled if the context is done first.
+func WaitReservation(ctx context.Context, clock Clock, reservation *rate.Reservation) error {
+	clock = ClockOrReal(clock)
+
+	if !reservation.OK() {
+		return ErrRateLimitExceeded
+	}
+
+	if err := Sleep(ctx, clock, reservation.DelayFrom(clock.Now())); err != nil {
+		reservation.CancelAt(clock.Now())
+
+		return err
+	}
+
+	return nil
+}
+
+// FakeClock is a "Clock" for tests that only moves when it is advanced.
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+}
+
+type fakeWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+// NewFakeClock returns a "FakeClock" set to "now".
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the current time of the fake clock.
+func (clock *FakeClock) Now() time.Time {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+
+	return clock.now
+}
+
+// After returns a channel that receives the time once the clock has been advanced by at least the duration.
+func (clock *FakeClock) After(d time.Duration) <-chan time.Time {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- clock.now
+
+		return ch
+	}
+
+	clock.waiters = append(clock.waiters, fakeWaiter{at: clock.now.Add(d), ch: ch})
+
+	return ch
+}
+
+// Advance will move the clock forward by the duration, firing any waiters that are due.
+func (clock *FakeClock) Advance(d time.Duration) {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+
+	clock.now = clock.now.Add(d)
+
+	sort.Slice(clock.waiters, func(i, j int) bool { return clock.waiters[i].at.Before(clock.waiters[j].at) })
+
+	remaining := clock.waiters[:0]
+
+	for _, waiter := range clock.waiters {
+		if waiter.at.After(clock.now) {
+			remaining = append(remaining, waiter)
+
+			continue
+		}
+
+		waiter.ch <- clock.now
+	}
+
+	clock.waiters = remaining
+}
+
+// Waiters returns the number of pending "After" calls, which lets tests wait until code under test is blocked on
+// the clock before advancing it.
+func (clock *FakeClock) Waiters() int {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+
+	return len(clock.waiters)
+}
@@ -0,0 +1,116 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package tools
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// waitForWaiters will block until the fake clock has "n" pending waiters.
+func waitForWaiters(t *testing.T, clock *FakeClock, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for clock.Waiters() < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %d waiters", n)
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestFakeClock(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2022, 5, 10, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+
+	early := clock.After(time.Minute)
+	late := clock.After(time.Hour)
+
+	clock.Advance(30 * time.Minute)
+
+	select {
+	case got := <-early:
+		if !got.Equal(start.Add(30 * time.Minute)) {
+			t.Fatalf("unexpected time: %v", got)
+		}
+	default:
+		t.Fatalf("expected the early waiter to fire")
+	}
+
+	select {
+	case <-late:
+		t.Fatalf("expected the late waiter not to fire")
+	default:
+	}
+
+	if clock.Waiters() != 1 {
+		t.Fatalf("expected 1 waiter, got %d", clock.Waiters())
+	}
+}
+
+func TestWaitRateLimit(t *testing.T) {
+	t.Parallel()
+
+	t.Run("waits on the clock", func(t *testing.T) {
+		t.Parallel()
+
+		clock := NewFakeClock(time.Date(2022, 5, 10, 0, 0, 0, 0, time.UTC))
+		limiter := rate.NewLimiter(rate.Every(time.Hour), 1)
+
+		if err := WaitRateLimit(context.Background(), clock, limiter); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		done := make(chan error, 1)
+
+		go func() { done <- WaitRateLimit(context.Background(), clock, limiter) }()
+
+		waitForWaiters(t, clock, 1)
+		clock.Advance(time.Hour)
+
+		if err := <-done; err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("zero burst", func(t *testing.T) {
+		t.Parallel()
+
+		limiter := rate.NewLimiter(rate.Every(time.Hour), 0)
+
+		err := WaitRateLimit(context.Background(), NewFakeClock(time.Now()), limiter)
+		if !errors.Is(err, ErrRateLimitExceeded) {
+			t.Fatalf("expected rate limit exceeded, got %v", err)
+		}
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		t.Parallel()
+
+		clock := NewFakeClock(time.Date(2022, 5, 10, 0, 0, 0, 0, time.UTC))
+		limiter := rate.NewLimiter(rate.Every(time.Hour), 1)
+		limiter.AllowN(clock.Now(), 1)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		if err := WaitRateLimit(ctx, clock, limiter); !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context canceled, got %v", err)
+		}
+	})
+}
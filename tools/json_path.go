@@ -0,0 +1,334 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package tools
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrInvalidJSONPath is returned when a JSON path cannot be parsed.
+	ErrInvalidJSONPath = fmt.Errorf("invalid json path")
+
+	// ErrJSONPathNotFound is returned when a JSON path does not resolve to a value.
+	ErrJSONPathNotFound = fmt.Errorf("json path not found")
+)
+
+// jsonPathSegment is a single step in a JSON path, either an object key or an array index.
+type jsonPathSegment struct {
+	key   string
+	index int
+	isIdx bool
+}
+
+// parseJSONPath will split a JSON path into segments. Paths are a simplified form of JSONPath: an optional "$" root,
+// followed by dot-separated object keys and bracketed array indices, e.g. "$.result.items[0].id" or
+// "result.items[0].id".
+func parseJSONPath(path string) ([]jsonPathSegment, error) {
+	path = strings.TrimPrefix(strings.TrimSpace(path), "$")
+	path = strings.TrimPrefix(path, ".")
+
+	if path == "" {
+		return nil, nil
+	}
+
+	var segments []jsonPathSegment
+
+	for _, part := range strings.Split(path, ".") {
+		key := part
+		if idx := strings.Index(part, "["); idx >= 0 {
+			key = part[:idx]
+		}
+
+		if key != "" {
+			segments = append(segments, jsonPathSegment{key: key})
+		}
+
+		for rest := part[len(key):]; rest != ""; {
+			end := strings.Index(rest, "]")
+			if !strings.HasPrefix(rest, "[") || end < 0 {
+				return nil, fmt.Errorf("%w: %q", ErrInvalidJSONPath, path)
+			}
+
+			index, err := strconv.Atoi(rest[1:end])
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("%w: %q", ErrInvalidJSONPath, path)
+			}
+
+			segments = append(segments, jsonPathSegment{index: index, isIdx: true})
+			rest = rest[end+1:]
+		}
+
+		if key == "" && len(segments) == 0 {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidJSONPath, path)
+		}
+	}
+
+	return segments, nil
+}
+
+// GetJSONPath will return the value at the JSON path in a decoded JSON document, i.e. a tree of
+// "map[string]interface{}" and "[]interface{}" values.
+func GetJSONPath(doc interface{}, path string) (interface{}, error) {
+	segments, err := parseJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cur := doc
+
+	for _, seg := range segments {
+		switch node := cur.(type) {
+		case map[string]interface{}:
+			val, ok := node[seg.key]
+			if seg.isIdx || !ok {
+				return nil, fmt.Errorf("%w: %q", ErrJSONPathNotFound, path)
+			}
+
+			cur = val
+		case []interface{}:
+			if !seg.isIdx || seg.index >= len(node) {
+				return nil, fmt.Errorf("%w: %q", ErrJSONPathNotFound, path)
+			}
+
+			cur = node[seg.index]
+		default:
+			return nil, fmt.Errorf("%w: %q", ErrJSONPathNotFound, path)
+		}
+	}
+
+	return cur, nil
+}
+
+// SetJSONPath will set the value at the JSON path in a decoded JSON object, creating intermediate objects for any
+// keys that do not exist. Array indices must already exist in the document.
+func SetJSONPath(doc map[string]interface{}, path string, value interface{}) error {
+	segments, err := parseJSONPath(path)
+	if err != nil {
+		return err
+	}
+
+	if len(segments) == 0 || segments[0].isIdx {
+		return fmt.Errorf("%w: %q", ErrInvalidJSONPath, path)
+	}
+
+	var cur interface{} = doc
+
+	for idx, seg := range segments {
+		last := idx == len(segments)-1
+
+		switch node := cur.(type) {
+		case map[string]interface{}:
+			if seg.isIdx {
+				return fmt.Errorf("%w: %q", ErrJSONPathNotFound, path)
+			}
+
+			if last {
+				node[seg.key] = value
+
+				return nil
+			}
+
+			next, ok := node[seg.key]
+			if !ok || next == nil {
+				next = make(map[string]interface{})
+				node[seg.key] = next
+			}
+
+			cur = next
+		case []interface{}:
+			if !seg.isIdx || seg.index >= len(node) {
+				return fmt.Errorf("%w: %q", ErrJSONPathNotFound, path)
+			}
+
+			if last {
+				node[seg.index] = value
+
+				return nil
+			}
+
+			cur = node[seg.index]
+		default:
+			return fmt.Errorf("%w: %q", ErrJSONPathNotFound, path)
+		}
+	}
+
+	return nil
+}
+
+// DeleteJSONPath will remove the value at the JSON path from a decoded JSON object. Deleting a path that does not
+// exist is a no-op.
+func DeleteJSONPath(doc map[string]interface{}, path string) error {
+	segments, err := parseJSONPath(path)
+	if err != nil {
+		return err
+	}
+
+	if len(segments) == 0 {
+		return nil
+	}
+
+	parent, err := GetJSONPath(doc, joinJSONPath(segments[:len(segments)-1]))
+	if err != nil {
+		return nil //nolint:nilerr // missing paths are not an error when deleting
+	}
+
+	last := segments[len(segments)-1]
+	if node, ok := parent.(map[string]interface{}); ok && !last.isIdx {
+		delete(node, last.key)
+	}
+
+	return nil
+}
+
+// ValidateJSONPath returns an error if "path" is not a JSON path.
+func ValidateJSONPath(path string) error {
+	_, err := parseJSONPath(path)
+
+	return err
+}
+
+// SeekJSONPath will read the JSON document of "reader" up to the value at the JSON path, returning a reader that
+// begins with the value. The document is read as a stream, skipping the values before the path without holding them
+// in memory, and what follows the value is left unread.
+func SeekJSONPath(reader io.Reader, path string) (io.Reader, error) {
+	segments, err := parseJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(segments) == 0 {
+		return reader, nil
+	}
+
+	dec := json.NewDecoder(reader)
+
+	skip := func() error {
+		var val json.RawMessage
+		if err := dec.Decode(&val); err != nil {
+			return fmt.Errorf("failed to decode json: %w", err)
+		}
+
+		return nil
+	}
+
+	for _, seg := range segments {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode json: %w", err)
+		}
+
+		switch delim, _ := tok.(json.Delim); {
+		case delim == '{' && !seg.isIdx:
+			found := false
+
+			for !found && dec.More() {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, fmt.Errorf("failed to decode json: %w", err)
+				}
+
+				if found = key == seg.key; !found {
+					if err := skip(); err != nil {
+						return nil, err
+					}
+				}
+			}
+
+			if !found {
+				return nil, fmt.Errorf("%w: %q", ErrJSONPathNotFound, path)
+			}
+		case delim == '[' && seg.isIdx:
+			for idx := 0; idx < seg.index && dec.More(); idx++ {
+				if err := skip(); err != nil {
+					return nil, err
+				}
+			}
+
+			if !dec.More() {
+				return nil, fmt.Errorf("%w: %q", ErrJSONPathNotFound, path)
+			}
+		default:
+			return nil, fmt.Errorf("%w: %q", ErrJSONPathNotFound, path)
+		}
+	}
+
+	// The decoder has read ahead, and the separator before the value, a colon or a comma, is not yet consumed.
+	rest := bufio.NewReader(io.MultiReader(dec.Buffered(), reader))
+
+	for {
+		peek, err := rest.Peek(1)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode json: %w", err)
+		}
+
+		if !strings.ContainsAny(string(peek), " \t\r\n:,") {
+			return rest, nil
+		}
+
+		if _, err := rest.Discard(1); err != nil {
+			return nil, fmt.Errorf("failed to decode json: %w", err)
+		}
+	}
+}
+
+// joinJSONPath is the inverse of "parseJSONPath".
+func joinJSONPath(segments []jsonPathSegment) string {
+	var bldr strings.Builder
+
+	for _, seg := range segments {
+		if seg.isIdx {
+			bldr.WriteString(fmt.Sprintf("[%d]", seg.index))
+
+			continue
+		}
+
+		if bldr.Len() > 0 {
+			bldr.WriteString(".")
+		}
+
+		bldr.WriteString(seg.key)
+	}
+
+	return bldr.String()
+}
+
+// NormalizeYAML will convert the "map[interface{}]interface{}" values produced by the YAML decoder into
+// "map[string]interface{}" values so that the data can be encoded as JSON.
+func NormalizeYAML(val interface{}) interface{} {
+	switch node := val.(type) {
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(node))
+		for key, value := range node {
+			out[fmt.Sprintf("%v", key)] = NormalizeYAML(value)
+		}
+
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(node))
+		for key, value := range node {
+			out[key] = NormalizeYAML(value)
+		}
+
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(node))
+		for idx, value := range node {
+			out[idx] = NormalizeYAML(value)
+		}
+
+		return out
+	default:
+		return val
+	}
+}
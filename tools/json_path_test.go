@@ -0,0 +1,138 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package tools
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func decodeJSON(t *testing.T, data string) map[string]interface{} {
+	t.Helper()
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &doc); err != nil {
+		t.Fatalf("failed to decode json: %v", err)
+	}
+
+	return doc
+}
+
+func TestGetJSONPath(t *testing.T) {
+	t.Parallel()
+
+	doc := decodeJSON(t, `{"result":{"items":[{"id":1},{"id":2}]},"name":"x"}`)
+
+	for _, tcase := range []struct {
+		path string
+		want interface{}
+		err  error
+	}{
+		{path: "name", want: "x"},
+		{path: "$.name", want: "x"},
+		{path: "$.result.items[1].id", want: float64(2)},
+		{path: "result.items[0]", want: map[string]interface{}{"id": float64(1)}},
+		{path: "$", want: doc},
+		{path: "result.missing", err: ErrJSONPathNotFound},
+		{path: "result.items[5]", err: ErrJSONPathNotFound},
+		{path: "result.items[x]", err: ErrInvalidJSONPath},
+	} {
+		got, err := GetJSONPath(doc, tcase.path)
+		if !errors.Is(err, tcase.err) {
+			t.Fatalf("%s: expected error %v, got %v", tcase.path, tcase.err, err)
+		}
+
+		if tcase.err == nil && !reflect.DeepEqual(got, tcase.want) {
+			t.Fatalf("%s: expected %v, got %v", tcase.path, tcase.want, got)
+		}
+	}
+}
+
+func TestSetJSONPath(t *testing.T) {
+	t.Parallel()
+
+	doc := decodeJSON(t, `{"range":{"start":"a"},"list":[{"v":1}]}`)
+
+	for path, value := range map[string]interface{}{
+		"$.range.start": "b",
+		"range.end":     "c",
+		"new.nested.v":  1,
+		"list[0].v":     2,
+	} {
+		if err := SetJSONPath(doc, path, value); err != nil {
+			t.Fatalf("%s: unexpected error: %v", path, err)
+		}
+
+		got, err := GetJSONPath(doc, path)
+		if err != nil || !reflect.DeepEqual(got, value) {
+			t.Fatalf("%s: expected %v, got %v (%v)", path, value, got, err)
+		}
+	}
+
+	if err := SetJSONPath(doc, "list[3].v", 1); !errors.Is(err, ErrJSONPathNotFound) {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+
+	if err := DeleteJSONPath(doc, "range.start"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := GetJSONPath(doc, "range.start"); !errors.Is(err, ErrJSONPathNotFound) {
+		t.Fatalf("expected deleted path to be missing, got %v", err)
+	}
+}
+
+func TestSeekJSONPath(t *testing.T) {
+	t.Parallel()
+
+	doc := `{"meta": {"items": "no"}, "result" : {"count": 2, "items": [{"id": 1}, {"id": 2}]}, "next": null}`
+
+	for _, tcase := range []struct {
+		path string
+		want string
+		err  error
+	}{
+		{path: "$.result.items", want: `[{"id":1},{"id":2}]`},
+		{path: "result.items[1]", want: `{"id":2}`},
+		{path: "result.items[0].id", want: `1`},
+		{path: "$", want: `{"meta":{"items":"no"},"result":{"count":2,"items":[{"id":1},{"id":2}]},"next":null}`},
+		{path: "result.missing", err: ErrJSONPathNotFound},
+		{path: "result.items[2]", err: ErrJSONPathNotFound},
+		{path: "result[0]", err: ErrJSONPathNotFound},
+		{path: "result.items[x]", err: ErrInvalidJSONPath},
+	} {
+		reader, err := SeekJSONPath(strings.NewReader(doc), tcase.path)
+		if !errors.Is(err, tcase.err) {
+			t.Fatalf("%s: expected error %v, got %v", tcase.path, tcase.err, err)
+		}
+
+		if tcase.err != nil {
+			continue
+		}
+
+		var val json.RawMessage
+		if err := json.NewDecoder(reader).Decode(&val); err != nil {
+			t.Fatalf("%s: failed to decode value: %v", tcase.path, err)
+		}
+
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, val); err != nil {
+			t.Fatalf("%s: failed to compact value: %v", tcase.path, err)
+		}
+
+		if compact.String() != tcase.want {
+			t.Fatalf("%s: expected %s, got %s", tcase.path, tcase.want, compact.String())
+		}
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package tools
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidSchedule is returned when a cron schedule cannot be parsed.
+var ErrInvalidSchedule = fmt.Errorf("invalid schedule")
+
+// scheduleSearchYears bounds the search for the next time of a schedule, so that schedules that never match, e.g.
+// "0 0 30 2 *", do not search forever.
+const scheduleSearchYears = 5
+
+// scheduleMacros are the shorthands accepted in place of the five fields of a schedule.
+var scheduleMacros = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+	"@yearly":  "0 0 1 1 *",
+}
+
+// Schedule is a cron schedule of the standard five fields: minute, hour, day of the month, month and day of the
+// week, evaluated in a timezone. As with cron, if both the day of the month and the day of the week are restricted,
+// a day matches if either of them does.
+type Schedule struct {
+	minutes, hours, days, months, weekdays uint64
+
+	// anyDay and anyWeekday are true if the day of the month and the day of the week fields are "*".
+	anyDay, anyWeekday bool
+
+	loc *time.Location
+}
+
+// parseScheduleField will parse a comma-separated list of values, ranges, e.g. "1-5", and steps, e.g. "*/15" or
+// "0-30/10", into a set of the values between "min" and "max".
+func parseScheduleField(field string, min, max int) (uint64, error) {
+	var set uint64
+
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+
+		if idx := strings.Index(part, "/"); idx >= 0 {
+			var err error
+			if step, err = strconv.Atoi(part[idx+1:]); err != nil || step <= 0 {
+				return 0, fmt.Errorf("%w: step of %q", ErrInvalidSchedule, part)
+			}
+
+			part = part[:idx]
+		}
+
+		low, high := min, max
+
+		switch idx := strings.Index(part, "-"); {
+		case part == "*":
+		case idx > 0:
+			var errLow, errHigh error
+
+			low, errLow = strconv.Atoi(part[:idx])
+			high, errHigh = strconv.Atoi(part[idx+1:])
+
+			if errLow != nil || errHigh != nil {
+				return 0, fmt.Errorf("%w: range %q", ErrInvalidSchedule, part)
+			}
+		default:
+			val, err := strconv.Atoi(part)
+			if err != nil {
+				return 0, fmt.Errorf("%w: value %q", ErrInvalidSchedule, part)
+			}
+
+			low, high = val, val
+		}
+
+		if low < min || high > max || low > high {
+			return 0, fmt.Errorf("%w: %q is not between %d and %d", ErrInvalidSchedule, part, min, max)
+		}
+
+		for val := low; val <= high; val += step {
+			set |= 1 << uint(val)
+		}
+	}
+
+	return set, nil
+}
+
+// ParseSchedule will parse a cron schedule, e.g. "30 2 * * 1-5" for 02:30 on weekdays, or one of the shorthands
+// "@hourly", "@daily", "@weekly", "@monthly" and "@yearly". The times of the schedule are in "loc", which
+// defaults to UTC.
+func ParseSchedule(spec string, loc *time.Location) (*Schedule, error) {
+	if macro, ok := scheduleMacros[strings.TrimSpace(spec)]; ok {
+		spec = macro
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("%w: %q must have 5 fields", ErrInvalidSchedule, spec)
+	}
+
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	sched := &Schedule{anyDay: fields[2] == "*", anyWeekday: fields[4] == "*", loc: loc}
+
+	for idx, def := range []struct {
+		set      *uint64
+		min, max int
+	}{
+		{set: &sched.minutes, max: 59},
+		{set: &sched.hours, max: 23},
+		{set: &sched.days, min: 1, max: 31},
+		{set: &sched.months, min: 1, max: 12},
+		{set: &sched.weekdays, max: 7},
+	} {
+		set, err := parseScheduleField(fields[idx], def.min, def.max)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", spec, err)
+		}
+
+		*def.set = set
+	}
+
+	// Sunday is both 0 and 7.
+	if sched.weekdays&(1<<7) != 0 {
+		sched.weekdays |= 1
+	}
+
+	return sched, nil
+}
+
+func (sched *Schedule) matchesDay(t time.Time) bool {
+	day := sched.days&(1<<uint(t.Day())) != 0
+	weekday := sched.weekdays&(1<<uint(t.Weekday())) != 0
+
+	switch {
+	case sched.anyDay:
+		return weekday
+	case sched.anyWeekday:
+		return day
+	default:
+		return day || weekday
+	}
+}
+
+// Next returns the first time of the schedule after "t", to the minute, or the zero time if the schedule has no
+// time in the next few years.
+func (sched *Schedule) Next(t time.Time) time.Time {
+	t = t.In(sched.loc)
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0, sched.loc)
+	limit := t.AddDate(scheduleSearchYears, 0, 0)
+
+	for t.Before(limit) {
+		year, month, day := t.Date()
+
+		switch {
+		case sched.months&(1<<uint(month)) == 0:
+			t = time.Date(year, month+1, 1, 0, 0, 0, 0, sched.loc)
+		case !sched.matchesDay(t):
+			t = time.Date(year, month, day+1, 0, 0, 0, 0, sched.loc)
+		case sched.hours&(1<<uint(t.Hour())) == 0:
+			t = time.Date(year, month, day, t.Hour()+1, 0, 0, 0, sched.loc)
+		case sched.minutes&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+
+	return time.Time{}
+}
@@ -0,0 +1,84 @@
+//go:build utests
+
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package tools
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSchedule(t *testing.T) {
+	t.Parallel()
+
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("failed to load location: %v", err)
+	}
+
+	// 2022-05-10 is a Tuesday.
+	from := time.Date(2022, 5, 10, 1, 30, 20, 0, time.UTC)
+
+	for _, tcase := range []struct {
+		name string
+		spec string
+		loc  *time.Location
+		from time.Time
+		want time.Time
+		err  error
+	}{
+		{name: "every minute", spec: "* * * * *", from: from, want: time.Date(2022, 5, 10, 1, 31, 0, 0, time.UTC)},
+		{name: "daily", spec: "@daily", from: from, want: time.Date(2022, 5, 11, 0, 0, 0, 0, time.UTC)},
+		{name: "later today", spec: "0 2 * * *", from: from, want: time.Date(2022, 5, 10, 2, 0, 0, 0, time.UTC)},
+		{name: "steps", spec: "*/20 * * * *", from: from, want: time.Date(2022, 5, 10, 1, 40, 0, 0, time.UTC)},
+		{name: "list", spec: "5,50 1 * * *", from: from, want: time.Date(2022, 5, 10, 1, 50, 0, 0, time.UTC)},
+		{name: "weekend", spec: "0 3 * * 6-7", from: from, want: time.Date(2022, 5, 14, 3, 0, 0, 0, time.UTC)},
+		{name: "sunday", spec: "0 3 * * 7", from: from, want: time.Date(2022, 5, 15, 3, 0, 0, 0, time.UTC)},
+		{name: "next month", spec: "0 0 1 * *", from: from, want: time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{
+			name: "day of month or weekday",
+			spec: "0 0 20 * 5",
+			from: from,
+			want: time.Date(2022, 5, 13, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "timezone",
+			spec: "0 2 * * *",
+			loc:  newYork,
+			from: from,
+			want: time.Date(2022, 5, 10, 6, 0, 0, 0, time.UTC),
+		},
+		{name: "never", spec: "0 0 30 2 *", from: from},
+		{name: "fields", spec: "0 2 * *", err: ErrInvalidSchedule},
+		{name: "out of range", spec: "60 * * * *", err: ErrInvalidSchedule},
+		{name: "step", spec: "*/0 * * * *", err: ErrInvalidSchedule},
+		{name: "range", spec: "0 5-1 * * *", err: ErrInvalidSchedule},
+		{name: "value", spec: "0 x * * *", err: ErrInvalidSchedule},
+	} {
+		tcase := tcase
+
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			sched, err := ParseSchedule(tcase.spec, tcase.loc)
+			if !errors.Is(err, tcase.err) {
+				t.Fatalf("expected error %v, got %v", tcase.err, err)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if got := sched.Next(tcase.from); !got.Equal(tcase.want) {
+				t.Fatalf("expected %v, got %v", tcase.want, got)
+			}
+		})
+	}
+}